@@ -0,0 +1,605 @@
+// Package browserfs provides a billy filesystem persisting its files in the
+// storage of a web browser, so that the wasm builds keep their state across
+// page reloads: the Origin Private File System, or IndexedDB where it isn't
+// available.
+//
+// The storages themselves are only built for js/wasm, with Open. FS works
+// on top of any Storage, which is all the browser specific code needs to
+// provide.
+package browserfs // import "github.com/go-git/go-billy/v5/browserfs"
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Storage is the persistent storage of an FS. The paths are given as their
+// elements, the root being the empty path. The errors are reported with
+// os.ErrNotExist, os.ErrExist and the syscall errnos, such as
+// syscall.ENOTDIR, or wrap them.
+type Storage interface {
+	// Stat returns the entry at path.
+	Stat(path []string) (Entry, error)
+	// List returns the entries of the directory at path.
+	List(path []string) ([]Entry, error)
+	// Read returns the content of the file at path.
+	Read(path []string) ([]byte, error)
+	// Write replaces the content of the file at path, creating it in its
+	// existing directory if needed.
+	Write(path []string, data []byte) error
+	// Mkdir creates the directory at path in its existing parent, doing
+	// nothing if the directory exists.
+	Mkdir(path []string) error
+	// Remove removes the file or directory at path, with everything in it.
+	Remove(path []string) error
+}
+
+// Entry describes a file or a directory of a Storage.
+type Entry struct {
+	Name    string
+	Dir     bool
+	Size    int64
+	ModTime time.Time
+}
+
+// FS is a billy.Filesystem storing its files in a Storage.
+//
+// The content of a file is read from the storage when it is opened, and
+// written back when the file is closed or synced, the browser storages
+// writing whole files. The writes of a file are thus only seen by the other
+// files opened afterwards, and the last file closed wins. Symbolic links
+// aren't supported, and renaming a directory copies its content.
+type FS struct {
+	s Storage
+}
+
+// New returns a filesystem storing its files in s.
+func New(s Storage) *FS {
+	return &FS{s: s}
+}
+
+// split returns the elements of the path of filename, which can't climb
+// above the root.
+func split(filename string) []string {
+	clean := filepath.ToSlash(filepath.Clean(string(filepath.Separator) + filename))
+	return strings.FieldsFunc(clean, func(r rune) bool { return r == '/' })
+}
+
+func (fs *FS) stat(path []string) (Entry, error) {
+	if len(path) == 0 {
+		return Entry{Name: "/", Dir: true}, nil
+	}
+
+	return fs.s.Stat(path)
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file at filename, reading its whole content unless
+// O_TRUNC is given. The missing directories of the files created are
+// created too. The permissions are ignored.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	path := split(filename)
+	e, err := fs.stat(path)
+	switch {
+	case os.IsNotExist(err) && flag&os.O_CREATE != 0:
+		if err := fs.mkdirAll(path[:len(path)-1]); err != nil {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: underlying(err)}
+		}
+		if err := fs.s.Write(path, nil); err != nil {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: underlying(err)}
+		}
+		return newFile(fs, path, nil, flag), nil
+	case err != nil:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: underlying(err)}
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	case e.Dir:
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EISDIR}
+		}
+		f := newFile(fs, path, nil, flag)
+		f.dir = true
+		return f, nil
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		f := newFile(fs, path, nil, flag)
+		f.dirty = true
+		return f, nil
+	}
+
+	data, err := fs.s.Read(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: underlying(err)}
+	}
+
+	return newFile(fs, path, data, flag), nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	e, err := fs.stat(split(filename))
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: filename, Err: underlying(err)}
+	}
+
+	e.Name = filepath.Base(filename)
+	return &fileInfo{e}, nil
+}
+
+// Lstat is Stat, as there are no symbolic links.
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	return fs.Stat(filename)
+}
+
+// Rename moves the file or the directory at from to the path to, replacing
+// the file or the empty directory there if any. The directories are copied to their new path
+// before being removed.
+func (fs *FS) Rename(from, to string) error {
+	fpath, tpath := split(from), split(to)
+	if len(fpath) == 0 || len(tpath) == 0 {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EINVAL}
+	}
+
+	e, err := fs.s.Stat(fpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: underlying(err)}
+	}
+	if strings.Join(fpath, "/") == strings.Join(tpath, "/") {
+		return nil
+	}
+	if e.Dir && isPrefix(fpath, tpath) {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EINVAL}
+	}
+
+	if te, err := fs.s.Stat(tpath); err == nil {
+		if err := fs.checkReplace(e, te, tpath); err != nil {
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+		}
+	}
+
+	if err := fs.mkdirAll(tpath[:len(tpath)-1]); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: underlying(err)}
+	}
+	if err := fs.copy(fpath, tpath, e); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: underlying(err)}
+	}
+	if err := fs.s.Remove(fpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: underlying(err)}
+	}
+
+	return nil
+}
+
+// checkReplace checks that e can replace the entry te at tpath, as
+// rename(2) does.
+func (fs *FS) checkReplace(e, te Entry, tpath []string) error {
+	switch {
+	case te.Dir && !e.Dir:
+		return syscall.EISDIR
+	case !te.Dir && e.Dir:
+		return syscall.ENOTDIR
+	case te.Dir:
+		entries, err := fs.s.List(tpath)
+		if err != nil {
+			return underlying(err)
+		}
+		if len(entries) != 0 {
+			return syscall.ENOTEMPTY
+		}
+	}
+
+	return nil
+}
+
+// copy copies the file or directory e at from to the path to.
+func (fs *FS) copy(from, to []string, e Entry) error {
+	if !e.Dir {
+		data, err := fs.s.Read(from)
+		if err != nil {
+			return err
+		}
+		return fs.s.Write(to, data)
+	}
+
+	if err := fs.s.Mkdir(to); err != nil {
+		return err
+	}
+
+	entries, err := fs.s.List(from)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := fs.copy(join(from, e.Name), join(to, e.Name), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func join(path []string, name string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), name)
+}
+
+// isPrefix reports whether the path prefix holds path.
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Remove removes the file or the empty directory at filename.
+func (fs *FS) Remove(filename string) error {
+	path := split(filename)
+	if len(path) == 0 {
+		return &os.PathError{Op: "remove", Path: filename, Err: syscall.EINVAL}
+	}
+
+	e, err := fs.s.Stat(path)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: filename, Err: underlying(err)}
+	}
+	if e.Dir {
+		entries, err := fs.s.List(path)
+		if err != nil {
+			return &os.PathError{Op: "remove", Path: filename, Err: underlying(err)}
+		}
+		if len(entries) > 0 {
+			return &os.PathError{Op: "remove", Path: filename, Err: syscall.ENOTEMPTY}
+		}
+	}
+
+	if err := fs.s.Remove(path); err != nil {
+		return &os.PathError{Op: "remove", Path: filename, Err: underlying(err)}
+	}
+
+	return nil
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return util.TempFile(fs, dir, prefix)
+}
+
+// ReadDir returns the entries of the directory at path, sorted by name.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	p := split(path)
+	e, err := fs.stat(p)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: underlying(err)}
+	}
+	if !e.Dir {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	entries, err := fs.s.List(p)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: underlying(err)}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	fis := make([]os.FileInfo, len(entries))
+	for i, e := range entries {
+		fis[i] = &fileInfo{e}
+	}
+
+	return fis, nil
+}
+
+// MkdirAll creates the directory at filename and its missing parents. The
+// permissions are ignored.
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	if err := fs.mkdirAll(split(filename)); err != nil {
+		return &os.PathError{Op: "mkdir", Path: filename, Err: underlying(err)}
+	}
+
+	return nil
+}
+
+func (fs *FS) mkdirAll(path []string) error {
+	for i := 1; i <= len(path); i++ {
+		e, err := fs.s.Stat(path[:i])
+		switch {
+		case os.IsNotExist(err):
+			if err := fs.s.Mkdir(path[:i]); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !e.Dir:
+			return syscall.ENOTDIR
+		}
+	}
+
+	return nil
+}
+
+// Symlink fails with billy.ErrNotSupported.
+func (fs *FS) Symlink(target, link string) error {
+	return &os.LinkError{Op: "symlink", Old: target, New: link, Err: billy.ErrNotSupported}
+}
+
+// Readlink fails with billy.ErrNotSupported.
+func (fs *FS) Readlink(link string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: link, Err: billy.ErrNotSupported}
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return string(filepath.Separator)
+}
+
+// Capabilities implements the Capable interface.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.WriteCapability | billy.ReadCapability | billy.ReadAndWriteCapability |
+		billy.SeekCapability | billy.TruncateCapability
+}
+
+// underlying returns the error wrapped by err, if it is an *os.PathError, so
+// that it can be reported for another path or operation.
+func underlying(err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err
+	}
+
+	return err
+}
+
+type fileInfo struct {
+	e Entry
+}
+
+func (fi *fileInfo) Name() string       { return fi.e.Name }
+func (fi *fileInfo) Size() int64        { return fi.e.Size }
+func (fi *fileInfo) ModTime() time.Time { return fi.e.ModTime }
+func (fi *fileInfo) IsDir() bool        { return fi.e.Dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.e.Dir {
+		return os.ModeDir | 0o755
+	}
+
+	return 0o644
+}
+
+// file is a file opened, its content being held in memory until written
+// back to the storage.
+type file struct {
+	fs   *FS
+	name string
+	path []string
+	flag int
+	dir  bool
+
+	m      sync.Mutex
+	data   []byte
+	off    int64
+	dirty  bool
+	closed bool
+}
+
+var _ billy.Syncer = (*file)(nil)
+
+func newFile(fs *FS, path []string, data []byte, flag int) *file {
+	return &file{fs: fs, name: filepath.Join(path...), path: path, data: data, flag: flag}
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	case f.dir:
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	case write && f.flag&(os.O_WRONLY|os.O_RDWR) == 0:
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	case !write && f.flag&os.O_WRONLY != 0:
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+
+	return nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	n, err := f.readAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return f.readAt(p, off)
+}
+
+func (f *file) readAt(p []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.off = int64(len(f.data))
+	}
+
+	if end := f.off + int64(len(p)); end > int64(len(f.data)) {
+		f.grow(end)
+	}
+	n := copy(f.data[f.off:], p)
+	f.off += int64(n)
+	f.dirty = true
+
+	return n, nil
+}
+
+// grow extends the content to size, with zeros.
+func (f *file) grow(size int64) {
+	if size <= int64(cap(f.data)) {
+		f.data = f.data[:size]
+		return
+	}
+
+	data := make([]byte, size, size+size/2)
+	copy(data, f.data)
+	f.data = data
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	f.off = offset
+	return offset, nil
+}
+
+func (f *file) Truncate(size int64) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	if size > int64(len(f.data)) {
+		f.grow(size)
+	} else {
+		f.data = f.data[:size]
+	}
+	f.dirty = true
+
+	return nil
+}
+
+// Sync writes the content of the file back to the storage.
+func (f *file) Sync() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "sync", Path: f.name, Err: os.ErrClosed}
+	}
+
+	return f.flush()
+}
+
+func (f *file) flush() error {
+	if !f.dirty {
+		return nil
+	}
+
+	if err := f.fs.s.Write(f.path, f.data); err != nil {
+		return &os.PathError{Op: "write", Path: f.name, Err: underlying(err)}
+	}
+	f.dirty = false
+
+	return nil
+}
+
+// Close writes the content of the file back to the storage, if it was
+// changed.
+func (f *file) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+
+	f.closed = true
+	err := f.flush()
+	f.data = nil
+	return err
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+// Stat describes the file as it is in memory, as billy.FileStater.
+func (f *file) Stat() (os.FileInfo, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	e := Entry{Name: filepath.Base(f.name), Dir: f.dir, Size: int64(len(f.data)), ModTime: util.Now()}
+	return &fileInfo{e}, nil
+}
@@ -0,0 +1,120 @@
+//go:build js
+// +build js
+
+package browserfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"syscall/js"
+)
+
+// Open returns a filesystem persisted in the Origin Private File System of
+// the page, in a directory named name, or in the IndexedDB database named
+// name if the browser has no such file system.
+func Open(name string) (*FS, error) {
+	s, err := OpenOPFS(name)
+	if err == errUnavailable {
+		s, err = OpenIndexedDB(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return New(s), nil
+}
+
+var errUnavailable = errors.New("storage unavailable")
+
+// await waits for promise to settle, returning its value or its rejection
+// as an error. It must not be called from a js.Func, which would block the
+// event loop resolving the promise.
+func await(promise js.Value) (js.Value, error) {
+	type result struct {
+		v   js.Value
+		err error
+	}
+
+	ch := make(chan result, 1)
+	resolve := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		ch <- result{v: arg(args)}
+		return nil
+	})
+	reject := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		ch <- result{err: fromJSError(arg(args))}
+		return nil
+	})
+	defer resolve.Release()
+	defer reject.Release()
+
+	promise.Call("then", resolve, reject)
+	r := <-ch
+
+	return r.v, r.err
+}
+
+// call calls the method of v returning a promise, and awaits it, the
+// exceptions being thrown as errors.
+func call(v js.Value, method string, args ...interface{}) (res js.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			jerr, ok := r.(js.Error)
+			if !ok {
+				panic(r)
+			}
+			err = fromJSError(jerr.Value)
+		}
+	}()
+
+	return await(v.Call(method, args...))
+}
+
+func arg(args []js.Value) js.Value {
+	if len(args) == 0 {
+		return js.Undefined()
+	}
+
+	return args[0]
+}
+
+// fromJSError translates the DOMException errors of the storages to the
+// errors of the os and syscall packages.
+func fromJSError(v js.Value) error {
+	if v.Type() != js.TypeObject {
+		return fmt.Errorf("browserfs: %s", v.String())
+	}
+
+	switch name := v.Get("name").String(); name {
+	case "NotFoundError":
+		return os.ErrNotExist
+	case "TypeMismatchError":
+		return syscall.ENOTDIR
+	case "InvalidModificationError":
+		return syscall.ENOTEMPTY
+	case "NotAllowedError", "SecurityError":
+		return os.ErrPermission
+	case "QuotaExceededError":
+		return syscall.ENOSPC
+	default:
+		return fmt.Errorf("browserfs: %s: %s", name, v.Get("message").String())
+	}
+}
+
+// bytes copies the content of the ArrayBuffer or the typed array v.
+func bytes(v js.Value) []byte {
+	array := js.Global().Get("Uint8Array").New(v)
+	data := make([]byte, array.Get("length").Int())
+	js.CopyBytesToGo(data, array)
+
+	return data
+}
+
+// uint8Array copies data to a new Uint8Array.
+func uint8Array(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+
+	return array
+}
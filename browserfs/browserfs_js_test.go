@@ -0,0 +1,63 @@
+//go:build js
+// +build js
+
+package browserfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"syscall/js"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestFromJSError(t *testing.T) {
+	for name, want := range map[string]error{
+		"NotFoundError":            os.ErrNotExist,
+		"TypeMismatchError":        syscall.ENOTDIR,
+		"InvalidModificationError": syscall.ENOTEMPTY,
+		"QuotaExceededError":       syscall.ENOSPC,
+	} {
+		exc := js.Global().Get("DOMException").New("failed", name)
+		if err := fromJSError(exc); !errors.Is(err, want) {
+			t.Errorf("%s: got %v, want %v", name, err, want)
+		}
+	}
+}
+
+func TestAwait(t *testing.T) {
+	promise := js.Global().Get("Promise")
+	if v, err := await(promise.Call("resolve", 42)); err != nil || v.Int() != 42 {
+		t.Errorf("got %v, %v", v, err)
+	}
+
+	exc := js.Global().Get("DOMException").New("failed", "NotFoundError")
+	if _, err := await(promise.Call("reject", exc)); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	fs, err := Open("browserfs-test")
+	if err == errUnavailable {
+		t.Skip("no browser storage")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.RemoveAll(fs, "dir")
+
+	if err := util.WriteFile(fs, "dir/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = Open("browserfs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(fs, "dir/foo"); err != nil || string(got) != "foo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
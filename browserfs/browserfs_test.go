@@ -0,0 +1,236 @@
+package browserfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
+)
+
+// storage is a Storage in memory, as the browsers store the files, counting
+// the writes.
+type storage struct {
+	dirs   map[string]bool
+	files  map[string][]byte
+	writes int
+}
+
+func newStorage() *storage {
+	return &storage{dirs: map[string]bool{"": true}, files: make(map[string][]byte)}
+}
+
+func (s *storage) parent(path []string) error {
+	if !s.dirs[strings.Join(path[:len(path)-1], "/")] {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (s *storage) Stat(path []string) (Entry, error) {
+	key := strings.Join(path, "/")
+	name := path[len(path)-1]
+	if s.dirs[key] {
+		return Entry{Name: name, Dir: true, ModTime: time.Unix(1700000000, 0)}, nil
+	}
+	if data, ok := s.files[key]; ok {
+		return Entry{Name: name, Size: int64(len(data)), ModTime: time.Unix(1700000000, 0)}, nil
+	}
+	return Entry{}, os.ErrNotExist
+}
+
+func (s *storage) List(path []string) ([]Entry, error) {
+	var entries []Entry
+	for key := range s.dirs {
+		if name, ok := child(path, key); ok {
+			entries = append(entries, Entry{Name: name, Dir: true})
+		}
+	}
+	for key, data := range s.files {
+		if name, ok := child(path, key); ok {
+			entries = append(entries, Entry{Name: name, Size: int64(len(data))})
+		}
+	}
+	return entries, nil
+}
+
+// child returns the name of the entry at key if it is in the directory at
+// path.
+func child(path []string, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	if len(path) > 0 {
+		prefix := strings.Join(path, "/") + "/"
+		if !strings.HasPrefix(key, prefix) {
+			return "", false
+		}
+		key = key[len(prefix):]
+	}
+	return key, !strings.Contains(key, "/")
+}
+
+func (s *storage) Read(path []string) ([]byte, error) {
+	data, ok := s.files[strings.Join(path, "/")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (s *storage) Write(path []string, data []byte) error {
+	if err := s.parent(path); err != nil {
+		return err
+	}
+	if s.dirs[strings.Join(path, "/")] {
+		return syscall.EISDIR
+	}
+	s.writes++
+	s.files[strings.Join(path, "/")] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *storage) Mkdir(path []string) error {
+	if err := s.parent(path); err != nil {
+		return err
+	}
+	key := strings.Join(path, "/")
+	if _, ok := s.files[key]; ok {
+		return os.ErrExist
+	}
+	s.dirs[key] = true
+	return nil
+}
+
+func (s *storage) Remove(path []string) error {
+	key := strings.Join(path, "/")
+	if _, err := s.Stat(path); err != nil {
+		return err
+	}
+	for k := range s.dirs {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(s.dirs, k)
+		}
+	}
+	for k := range s.files {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(s.files, k)
+		}
+	}
+	return nil
+}
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+// BrowserSuite runs the conformance suites not relying on the modes or on
+// the symbolic links, which the storages don't keep.
+type BrowserSuite struct {
+	test.BasicSuite
+	test.DirSuite
+	test.TempFileSuite
+}
+
+var _ = check.Suite(&BrowserSuite{})
+
+func (s *BrowserSuite) SetUpTest(c *check.C) {
+	fs := New(newStorage())
+	s.BasicSuite.FS = fs
+	s.DirSuite.FS = fs
+	s.TempFileSuite.FS = fs
+}
+
+func (s *BrowserSuite) TestOpenFileWithModes(c *check.C) {
+	c.Skip("modes are not stored")
+}
+
+func (s *BrowserSuite) TestStat(c *check.C) {
+	c.Skip("modes are not stored")
+}
+
+func TestPersistence(t *testing.T) {
+	s := newStorage()
+	fs := New(s)
+
+	f, err := fs.Create("a/b/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.files["a/b/foo"]); got != "" {
+		t.Errorf("got %q before closing, want the file empty", got)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new filesystem over the storage, as after a reload of the page.
+	fs = New(s)
+	if got, err := util.ReadFile(fs, "a/b/foo"); err != nil || string(got) != "hello" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	writes := s.writes
+	f, err = fs.Open("a/b/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if s.writes != writes {
+		t.Error("expected a file only read not to be written back")
+	}
+
+	f, err = fs.OpenFile("a/b/foo", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("j")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.(billy.Syncer).Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.files["a/b/foo"]); got != "jello" {
+		t.Errorf("got %q after syncing", got)
+	}
+	f.Close()
+}
+
+func TestRename(t *testing.T) {
+	s := newStorage()
+	fs := New(s)
+
+	if err := util.WriteFile(fs, "dir/sub/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dir", "other/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(fs, "other/dir/sub/foo"); err != nil || string(got) != "foo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := fs.Stat("dir"); !os.IsNotExist(err) {
+		t.Errorf("got %v, want the directory moved", err)
+	}
+
+	if err := fs.Rename("other", "other/dir/inside"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("got %v, want EINVAL", err)
+	}
+	if err := fs.Remove("other"); !errors.Is(err, syscall.ENOTEMPTY) {
+		t.Errorf("got %v, want ENOTEMPTY", err)
+	}
+	if err := fs.Symlink("foo", "link"); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("got %v, want not supported", err)
+	}
+}
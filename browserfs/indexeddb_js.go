@@ -0,0 +1,301 @@
+//go:build js
+// +build js
+
+package browserfs
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"syscall/js"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	// metaStore holds the entries, keyed by their path, as objects with the
+	// dir, size and mtime properties.
+	metaStore = "meta"
+	// dataStore holds the content of the files, keyed by their path, as
+	// Uint8Array.
+	dataStore = "data"
+)
+
+// indexedDB is a Storage in an IndexedDB database. Each operation runs in
+// its own transactions, the transactions being committed as soon as the
+// Go code waits for a request.
+type indexedDB struct {
+	db js.Value
+}
+
+// OpenIndexedDB returns a Storage in the IndexedDB database named name,
+// created if needed.
+func OpenIndexedDB(name string) (Storage, error) {
+	idb := js.Global().Get("indexedDB")
+	if !idb.Truthy() {
+		return nil, errUnavailable
+	}
+
+	req := idb.Call("open", name, 1)
+	upgrade := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		db := req.Get("result")
+		db.Call("createObjectStore", metaStore)
+		db.Call("createObjectStore", dataStore)
+		return nil
+	})
+	defer upgrade.Release()
+	req.Set("onupgradeneeded", upgrade)
+
+	db, err := wait(req, "success", "error")
+	if err != nil {
+		return nil, err
+	}
+
+	return &indexedDB{db: db.Get("result")}, nil
+}
+
+// wait waits for target to dispatch the event done or fail, returning
+// target, or its error.
+func wait(target js.Value, done, fail string) (js.Value, error) {
+	ch := make(chan error, 1)
+	ok := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		ch <- nil
+		return nil
+	})
+	ko := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		err := target.Get("error")
+		if err.IsNull() || err.IsUndefined() {
+			ch <- syscall.EIO
+		} else {
+			ch <- fromJSError(err)
+		}
+		return nil
+	})
+	defer ok.Release()
+	defer ko.Release()
+
+	target.Set("on"+done, ok)
+	target.Set("on"+fail, ko)
+	if fail == "error" {
+		// The transactions abort without error when one of their request
+		// fails.
+		target.Set("onabort", ko)
+	}
+
+	if err := <-ch; err != nil {
+		return js.Undefined(), err
+	}
+
+	return target, nil
+}
+
+func key(path []string) string {
+	return "/" + strings.Join(path, "/")
+}
+
+// children returns the range of the keys of the entries under path.
+func children(path []string) js.Value {
+	prefix := key(path)
+	if len(path) == 0 {
+		prefix = ""
+	}
+
+	return js.Global().Get("IDBKeyRange").Call("bound", prefix+"/", prefix+"/\uffff")
+}
+
+func (s *indexedDB) get(store, key string) (js.Value, error) {
+	tx := s.db.Call("transaction", store, "readonly")
+	req, err := wait(tx.Call("objectStore", store).Call("get", key), "success", "error")
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	return req.Get("result"), nil
+}
+
+func (s *indexedDB) meta(path []string) (js.Value, error) {
+	v, err := s.get(metaStore, key(path))
+	if err != nil {
+		return js.Undefined(), err
+	}
+	if v.IsUndefined() {
+		return js.Undefined(), os.ErrNotExist
+	}
+
+	return v, nil
+}
+
+func entry(name string, v js.Value) Entry {
+	return Entry{
+		Name:    name,
+		Dir:     v.Get("dir").Bool(),
+		Size:    int64(v.Get("size").Int()),
+		ModTime: time.UnixMilli(int64(v.Get("mtime").Float())),
+	}
+}
+
+// parent checks that the parent directory of path exists.
+func (s *indexedDB) parent(path []string) error {
+	if len(path) <= 1 {
+		return nil
+	}
+
+	v, err := s.meta(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	if !v.Get("dir").Bool() {
+		return syscall.ENOTDIR
+	}
+
+	return nil
+}
+
+func (s *indexedDB) Stat(path []string) (Entry, error) {
+	if err := s.parent(path); err != nil {
+		return Entry{}, err
+	}
+
+	v, err := s.meta(path)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return entry(path[len(path)-1], v), nil
+}
+
+func (s *indexedDB) List(path []string) ([]Entry, error) {
+	if len(path) > 0 {
+		e, err := s.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !e.Dir {
+			return nil, syscall.ENOTDIR
+		}
+	}
+
+	tx := s.db.Call("transaction", metaStore, "readonly")
+	store := tx.Call("objectStore", metaStore)
+	keys := store.Call("getAllKeys", children(path))
+	values := store.Call("getAll", children(path))
+	if _, err := wait(tx, "complete", "error"); err != nil {
+		return nil, err
+	}
+
+	prefix := key(path)
+	if len(path) == 0 {
+		prefix = ""
+	}
+
+	var entries []Entry
+	ks, vs := keys.Get("result"), values.Get("result")
+	for i := 0; i < ks.Length(); i++ {
+		name := strings.TrimPrefix(ks.Index(i).String(), prefix+"/")
+		if strings.Contains(name, "/") {
+			continue
+		}
+		entries = append(entries, entry(name, vs.Index(i)))
+	}
+
+	return entries, nil
+}
+
+func (s *indexedDB) Read(path []string) ([]byte, error) {
+	e, err := s.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if e.Dir {
+		return nil, syscall.EISDIR
+	}
+
+	v, err := s.get(dataStore, key(path))
+	if err != nil {
+		return nil, err
+	}
+	if v.IsUndefined() {
+		return nil, nil
+	}
+
+	return bytes(v), nil
+}
+
+func (s *indexedDB) Write(path []string, data []byte) error {
+	if len(path) == 0 {
+		return syscall.EISDIR
+	}
+	if err := s.parent(path); err != nil {
+		return err
+	}
+
+	e, err := s.Stat(path)
+	if err == nil && e.Dir {
+		return syscall.EISDIR
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return s.put(path, false, data)
+}
+
+// put writes the entry at path and its content, in a single transaction.
+func (s *indexedDB) put(path []string, dir bool, data []byte) error {
+	meta := js.Global().Get("Object").New()
+	meta.Set("dir", dir)
+	meta.Set("size", len(data))
+	meta.Set("mtime", util.Now().UnixMilli())
+
+	stores := []interface{}{metaStore, dataStore}
+	tx := s.db.Call("transaction", stores, "readwrite")
+	tx.Call("objectStore", metaStore).Call("put", meta, key(path))
+	if !dir {
+		tx.Call("objectStore", dataStore).Call("put", uint8Array(data), key(path))
+	}
+
+	_, err := wait(tx, "complete", "error")
+	return err
+}
+
+func (s *indexedDB) Mkdir(path []string) error {
+	if len(path) == 0 {
+		return nil
+	}
+	if err := s.parent(path); err != nil {
+		return err
+	}
+
+	e, err := s.Stat(path)
+	switch {
+	case err == nil && e.Dir:
+		return nil
+	case err == nil:
+		return os.ErrExist
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	return s.put(path, true, nil)
+}
+
+func (s *indexedDB) Remove(path []string) error {
+	if len(path) == 0 {
+		return syscall.EINVAL
+	}
+	if _, err := s.Stat(path); err != nil {
+		return err
+	}
+
+	stores := []interface{}{metaStore, dataStore}
+	tx := s.db.Call("transaction", stores, "readwrite")
+	for _, store := range []string{metaStore, dataStore} {
+		objs := tx.Call("objectStore", store)
+		objs.Call("delete", key(path))
+		objs.Call("delete", children(path))
+	}
+
+	_, err := wait(tx, "complete", "error")
+	return err
+}
@@ -0,0 +1,214 @@
+//go:build js
+// +build js
+
+package browserfs
+
+import (
+	"os"
+	"syscall"
+	"syscall/js"
+	"time"
+)
+
+// opfs is a Storage in a directory of the Origin Private File System.
+type opfs struct {
+	root js.Value
+}
+
+// OpenOPFS returns a Storage in the directory named name of the Origin
+// Private File System, created if needed.
+func OpenOPFS(name string) (Storage, error) {
+	navigator := js.Global().Get("navigator")
+	if !navigator.Truthy() {
+		return nil, errUnavailable
+	}
+	storage := navigator.Get("storage")
+	if !storage.Truthy() || !storage.Get("getDirectory").Truthy() {
+		return nil, errUnavailable
+	}
+
+	root, err := call(storage, "getDirectory")
+	if err != nil {
+		return nil, err
+	}
+	dir, err := call(root, "getDirectoryHandle", name, create())
+	if err != nil {
+		return nil, err
+	}
+
+	return &opfs{root: dir}, nil
+}
+
+func create() js.Value {
+	opts := js.Global().Get("Object").New()
+	opts.Set("create", true)
+	return opts
+}
+
+// dir returns the handle of the directory at path.
+func (s *opfs) dir(path []string) (js.Value, error) {
+	dir := s.root
+	for _, name := range path {
+		var err error
+		if dir, err = call(dir, "getDirectoryHandle", name); err != nil {
+			return js.Undefined(), err
+		}
+	}
+
+	return dir, nil
+}
+
+// entry returns the handle of the file or the directory at path.
+func (s *opfs) entry(path []string) (js.Value, error) {
+	if len(path) == 0 {
+		return s.root, nil
+	}
+
+	parent, err := s.dir(path[:len(path)-1])
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	name := path[len(path)-1]
+	handle, err := call(parent, "getFileHandle", name)
+	if err == syscall.ENOTDIR {
+		handle, err = call(parent, "getDirectoryHandle", name)
+	}
+
+	return handle, err
+}
+
+func (s *opfs) describe(handle js.Value) (Entry, error) {
+	e := Entry{Name: handle.Get("name").String()}
+	if handle.Get("kind").String() == "directory" {
+		e.Dir = true
+		return e, nil
+	}
+
+	f, err := call(handle, "getFile")
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Size = int64(f.Get("size").Int())
+	e.ModTime = time.UnixMilli(int64(f.Get("lastModified").Float()))
+
+	return e, nil
+}
+
+func (s *opfs) Stat(path []string) (Entry, error) {
+	handle, err := s.entry(path)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	return s.describe(handle)
+}
+
+func (s *opfs) List(path []string) ([]Entry, error) {
+	dir, err := s.dir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	it := dir.Call("values")
+	for {
+		next, err := call(it, "next")
+		if err != nil {
+			return nil, err
+		}
+		if next.Get("done").Truthy() {
+			return entries, nil
+		}
+
+		e, err := s.describe(next.Get("value"))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+func (s *opfs) Read(path []string) ([]byte, error) {
+	handle, err := s.entry(path)
+	if err != nil {
+		return nil, err
+	}
+	if handle.Get("kind").String() == "directory" {
+		return nil, syscall.EISDIR
+	}
+
+	f, err := call(handle, "getFile")
+	if err != nil {
+		return nil, err
+	}
+	buf, err := call(f, "arrayBuffer")
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes(buf), nil
+}
+
+func (s *opfs) Write(path []string, data []byte) error {
+	if len(path) == 0 {
+		return syscall.EISDIR
+	}
+
+	parent, err := s.dir(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	handle, err := call(parent, "getFileHandle", path[len(path)-1], create())
+	if err == syscall.ENOTDIR {
+		return syscall.EISDIR
+	}
+	if err != nil {
+		return err
+	}
+
+	w, err := call(handle, "createWritable")
+	if err != nil {
+		return err
+	}
+	if _, err := call(w, "write", uint8Array(data)); err != nil {
+		call(w, "abort")
+		return err
+	}
+
+	_, err = call(w, "close")
+	return err
+}
+
+func (s *opfs) Mkdir(path []string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	parent, err := s.dir(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	_, err = call(parent, "getDirectoryHandle", path[len(path)-1], create())
+	if err == syscall.ENOTDIR {
+		return os.ErrExist
+	}
+
+	return err
+}
+
+func (s *opfs) Remove(path []string) error {
+	if len(path) == 0 {
+		return syscall.EINVAL
+	}
+
+	parent, err := s.dir(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+
+	opts := js.Global().Get("Object").New()
+	opts.Set("recursive", true)
+	_, err = call(parent, "removeEntry", path[len(path)-1], opts)
+	return err
+}
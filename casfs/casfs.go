@@ -0,0 +1,448 @@
+// Package casfs provides a billy filesystem storing the content of its files
+// by digest, so that identical files share their storage, whatever their
+// path and however many copies of a tree are kept.
+package casfs // import "github.com/go-git/go-billy/v5/casfs"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	objectsDir = "objects"
+	tmpDir     = "tmp"
+	indexFile  = "index"
+	maxLinks   = 255
+)
+
+// Digest is the hex encoded SHA-256 of the content of a regular file. It is
+// returned by the Sys method of the os.FileInfo of the regular files.
+type Digest string
+
+// EmptyDigest is the digest of an empty file.
+const EmptyDigest Digest = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// FS is a billy.Filesystem made of an index, mapping the paths to the
+// digests of the files, and of a store holding the contents as immutable
+// objects named after their digest.
+//
+// The index is kept in memory, Flush saving it to the store so that New
+// loads it back. The objects are written to the store as the files opened
+// for writing are closed; objects no longer referenced by the index are
+// only deleted by GC.
+type FS struct {
+	store billy.Filesystem
+	index billy.Filesystem
+
+	m sync.Mutex
+}
+
+// New returns a filesystem storing its objects and index in store, and
+// loading the index saved there by Flush, if any.
+func New(store billy.Filesystem) (*FS, error) {
+	fs := &FS{store: store, index: memfs.New()}
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.store.Lstat(objectPath(EmptyDigest)); os.IsNotExist(err) {
+		err = util.WriteFile(fs.store, objectPath(EmptyDigest), nil, 0o444)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fs, nil
+}
+
+func objectPath(d Digest) string {
+	return filepath.Join(objectsDir, string(d[:2]), string(d))
+}
+
+// digest returns the digest of the regular file name of the index.
+func (fs *FS) digest(name string) (Digest, error) {
+	b, err := util.ReadFile(fs.index, name)
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 0 {
+		return EmptyDigest, nil
+	}
+
+	return Digest(b), nil
+}
+
+// Digest returns the digest of the regular file name.
+func (fs *FS) Digest(name string) (Digest, error) {
+	fi, err := fs.index.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", &os.PathError{Op: "digest", Path: name, Err: syscall.EINVAL}
+	}
+
+	return fs.digest(name)
+}
+
+// info returns the information of the index entry name, described by fi.
+func (fs *FS) info(name string, fi os.FileInfo) (os.FileInfo, error) {
+	if !fi.Mode().IsRegular() {
+		return fi, nil
+	}
+
+	d, err := fs.digest(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ofi, err := fs.store.Stat(objectPath(d))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{FileInfo: fi, size: ofi.Size(), digest: d}, nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the object of the file for reading. Files opened for
+// writing are copied to a temporary file of the store, turned into an
+// object when closed.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	idx, err := fs.index.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.index.Stat(filename)
+	if err != nil || fi.IsDir() {
+		if err != nil {
+			idx.Close()
+		}
+		return idx, err
+	}
+
+	name := idx.Name()
+	if err := idx.Close(); err != nil {
+		return nil, err
+	}
+
+	d, err := fs.digest(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		f, err := fs.store.Open(objectPath(d))
+		if err != nil {
+			return nil, err
+		}
+		return &file{File: f, name: name}, nil
+	}
+
+	tmp, err := fs.store.TempFile(tmpDir, "casfs-")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &writer{
+		file:      file{File: tmp, name: name},
+		fs:        fs,
+		mode:      fi.Mode().Perm(),
+		writeOnly: flag&os.O_WRONLY != 0,
+		append:    flag&os.O_APPEND != 0,
+	}
+	if d != EmptyDigest {
+		err = w.copy(d)
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		w.discard()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	fi, err := fs.index.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(filename, fi)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := fs.index.Lstat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(filename, fi)
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := fs.index.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := fs.resolve(path)
+	for i, fi := range infos {
+		if infos[i], err = fs.info(fs.index.Join(dir, fi.Name()), fi); err != nil {
+			return nil, err
+		}
+	}
+
+	return infos, nil
+}
+
+// resolve follows the symlinks of path, as the index doesn't follow the
+// ones leading to the parent directory of a file.
+func (fs *FS) resolve(path string) string {
+	for i := 0; i < maxLinks; i++ {
+		target, err := fs.index.Readlink(path)
+		if err != nil {
+			return path
+		}
+		if !filepath.IsAbs(target) {
+			target = fs.index.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+
+	return path
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return fs.index.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	return fs.index.Remove(filename)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.index.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return util.TempFile(fs, dir, prefix)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.index.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return fs.index.Symlink(target, link)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	return fs.index.Readlink(link)
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.index.Root()
+}
+
+// Capabilities implements the Capable interface.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.WriteCapability | billy.ReadCapability |
+		billy.ReadAndWriteCapability | billy.SeekCapability |
+		billy.TruncateCapability | billy.SymlinkCapability
+}
+
+// put moves the temporary file tmp to the object d, unless it exists.
+func (fs *FS) put(tmp string, d Digest) error {
+	path := objectPath(d)
+	if _, err := fs.store.Lstat(path); err == nil {
+		return fs.store.Remove(tmp)
+	}
+
+	if err := fs.store.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return fs.store.Rename(tmp, path)
+}
+
+// GC deletes the objects referenced by no file of the index, as well as the
+// temporary files left by the writers, and returns the number of objects
+// deleted. No file must be open for writing during GC.
+func (fs *FS) GC() (int, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	used := map[Digest]bool{EmptyDigest: true}
+	err := util.Walk(fs.index, "/", func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+
+		d, err := fs.digest(path)
+		used[d] = true
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = util.Walk(fs.store, objectsDir, func(path string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || fi.IsDir() || used[Digest(fi.Name())] {
+			return err
+		}
+
+		n++
+		return fs.store.Remove(path)
+	})
+	if err != nil {
+		return n, err
+	}
+
+	return n, util.RemoveAll(fs.store, tmpDir)
+}
+
+type fileInfo struct {
+	os.FileInfo
+	size   int64
+	digest Digest
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
+
+// Sys returns the Digest of the file.
+func (fi *fileInfo) Sys() interface{} {
+	return fi.digest
+}
+
+// file is a file of the store, named after the path it was opened from.
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+// writer is a file opened for writing, whose content is turned into an
+// object when closed.
+type writer struct {
+	file
+	fs        *FS
+	mode      os.FileMode
+	writeOnly bool
+	append    bool
+
+	closed bool
+}
+
+// Write writes p at the offset of the file or, when opened with O_APPEND, at
+// its end.
+func (w *writer) Write(p []byte) (int, error) {
+	if w.append {
+		if _, err := w.File.Seek(0, io.SeekEnd); err != nil {
+			return 0, err
+		}
+	}
+
+	return w.File.Write(p)
+}
+
+func (w *writer) Read(p []byte) (int, error) {
+	if w.writeOnly {
+		return 0, &os.PathError{Op: "read", Path: w.name, Err: syscall.EBADF}
+	}
+
+	return w.File.Read(p)
+}
+
+func (w *writer) ReadAt(p []byte, off int64) (int, error) {
+	if w.writeOnly {
+		return 0, &os.PathError{Op: "read", Path: w.name, Err: syscall.EBADF}
+	}
+
+	return w.File.ReadAt(p, off)
+}
+
+func (w *writer) copy(d Digest) error {
+	src, err := w.fs.store.Open(objectPath(d))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = io.Copy(w.File, src)
+	return err
+}
+
+func (w *writer) discard() {
+	w.File.Close()
+	_ = w.fs.store.Remove(w.File.Name())
+}
+
+// Close stores the content of the file as an object, and points the index
+// to it.
+func (w *writer) Close() error {
+	if w.closed {
+		return os.ErrClosed
+	}
+	w.closed = true
+
+	h := sha256.New()
+	_, err := w.File.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = io.Copy(h, w.File)
+	}
+	if err != nil {
+		w.discard()
+		return err
+	}
+
+	tmp := w.File.Name()
+	if err := w.File.Close(); err != nil {
+		_ = w.fs.store.Remove(tmp)
+		return err
+	}
+
+	w.fs.m.Lock()
+	defer w.fs.m.Unlock()
+
+	d := Digest(hex.EncodeToString(h.Sum(nil)))
+	if err := w.fs.put(tmp, d); err != nil {
+		return err
+	}
+
+	return util.WriteFile(w.fs.index, w.name, []byte(d), w.mode)
+}
@@ -0,0 +1,134 @@
+package casfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		fs, err := New(memfs.New())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+// objects returns the number of objects of the store.
+func objects(t *testing.T, store billy.Filesystem) int {
+	t.Helper()
+
+	var n int
+	err := util.Walk(store, objectsDir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+func TestDedup(t *testing.T) {
+	store := memfs.New()
+	fs, err := New(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", "dir/b", "dir/sub/c"} {
+		if err := util.WriteFile(fs, name, []byte("same"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := util.WriteFile(fs, "other", []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The empty object, "same" and "other".
+	if n := objects(t, store); n != 3 {
+		t.Errorf("expected 3 objects, got %d", n)
+	}
+
+	fi, err := fs.Stat("dir/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Digest("0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5")
+	if fi.Sys() != want || fi.Size() != 4 {
+		t.Errorf("expected digest %s and size 4, got %v and %d", want, fi.Sys(), fi.Size())
+	}
+	if d, err := fs.Digest("a"); err != nil || d != want {
+		t.Errorf("expected digest %s, got %s, %v", want, d, err)
+	}
+
+	if err := util.WriteFile(fs, "a", []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("other"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := util.ReadFile(fs, "dir/sub/c")
+	if err != nil || string(b) != "same" {
+		t.Errorf("expected the shared object to be kept, got %q, %v", b, err)
+	}
+
+	n, err := fs.GC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 object collected, got %d", n)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	store := memfs.New()
+	fs, err := New(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.WriteFile(fs, "dir/with space", []byte("foo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("dir/with space", "link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.MkdirAll("empty", 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = New(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := util.ReadFile(fs, "link")
+	if err != nil || string(b) != "foo" {
+		t.Errorf("expected the file to be loaded, got %q, %v", b, err)
+	}
+	fi, err := fs.Stat("dir/with space")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %s", fi.Mode())
+	}
+	fi, err = fs.Stat("empty")
+	if err != nil || !fi.IsDir() {
+		t.Errorf("expected a directory, got %v, %v", fi, err)
+	}
+}
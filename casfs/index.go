@@ -0,0 +1,132 @@
+package casfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// The index is saved as one line per entry, in lexical order:
+//
+//	<type> <perm> <mtime> <quoted path> <digest or quoted target>
+//
+// where type is one of d, f and l, perm is octal and mtime is in nanoseconds
+// since the Unix epoch.
+const indexFormat = "%c %o %d %q %s\n"
+
+// Flush saves the index to the store, atomically replacing the previous
+// one. The objects themselves are already in the store.
+func (fs *FS) Flush() error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	var buf bytes.Buffer
+	err := util.Walk(fs.index, "/", func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == "/" {
+			return err
+		}
+
+		var typ byte
+		var value string
+		switch {
+		case fi.IsDir():
+			typ, value = 'd', "-"
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := fs.index.Readlink(path)
+			if err != nil {
+				return err
+			}
+			typ, value = 'l', strconv.Quote(target)
+		default:
+			d, err := fs.digest(path)
+			if err != nil {
+				return err
+			}
+			typ, value = 'f', string(d)
+		}
+
+		fmt.Fprintf(&buf, indexFormat, typ, fi.Mode().Perm(), fi.ModTime().UnixNano(), path, value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(fs.store, indexFile, buf.Bytes(), 0o644)
+}
+
+func (fs *FS) load() error {
+	f, err := fs.store.Open(indexFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if err := fs.loadEntry(s.Text()); err != nil {
+			return fmt.Errorf("casfs: index line %d: %w", line, err)
+		}
+	}
+
+	return s.Err()
+}
+
+func (fs *FS) loadEntry(line string) error {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) != 4 || len(fields[0]) != 1 {
+		return fmt.Errorf("malformed entry %q", line)
+	}
+
+	perm, err := strconv.ParseUint(fields[1], 8, 32)
+	if err != nil {
+		return err
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return err
+	}
+
+	quoted, err := strconv.QuotedPrefix(fields[3])
+	if err != nil {
+		return err
+	}
+	path, _ := strconv.Unquote(quoted)
+	value := strings.TrimPrefix(fields[3][len(quoted):], " ")
+
+	mode := os.FileMode(perm)
+	switch fields[0] {
+	case "d":
+		err = fs.index.MkdirAll(path, mode)
+	case "f":
+		err = util.WriteFile(fs.index, path, []byte(value), mode)
+	case "l":
+		var target string
+		if target, err = strconv.Unquote(value); err == nil {
+			err = fs.index.Symlink(target, path)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown entry type %q", fields[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if c, ok := fs.index.(billy.Change); ok {
+		t := time.Unix(0, mtime)
+		return c.Chtimes(path, t, t)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package billy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Layer is a filesystem wrapper stacked by a Composition.
+type Layer struct {
+	// Name identifies the layer in the errors of Build.
+	Name string
+	// Requires lists the capabilities the layer needs from the filesystem
+	// it wraps, such as WriteCapability for a quota.
+	Requires Capability
+	// Wrap returns the filesystem wrapping fs.
+	Wrap func(fs Filesystem) (Filesystem, error)
+}
+
+// Composition stacks wrappers over a base filesystem, see Compose.
+type Composition struct {
+	base   Filesystem
+	layers []Layer
+}
+
+// Compose starts the composition of a filesystem wrapping base. The layers
+// are added from the innermost to the outermost one, and Build checks that
+// each of them gets the capabilities it requires from the layers below:
+//
+//	fs, err := billy.Compose(osfs.New(dir)).
+//		Chroot("clusters").
+//		With(filterfs.IgnoreLayer(filterfs.IgnoreOptions{Files: []string{".sourceignore"}})).
+//		With(instrumented.Layer(prometheus.DefaultRegisterer)).
+//		Build()
+//
+// The helper packages provide the layers of their wrappers; others can be
+// declared with a Layer literal.
+func Compose(base Filesystem) *Composition {
+	return &Composition{base: base}
+}
+
+// With adds the given layers on top of the composition.
+func (c *Composition) With(layers ...Layer) *Composition {
+	c.layers = append(c.layers, layers...)
+	return c
+}
+
+// Chroot adds a layer rooting the filesystem at path, with the Chroot of the
+// filesystem below.
+func (c *Composition) Chroot(path string) *Composition {
+	return c.With(Layer{
+		Name: "chroot " + path,
+		Wrap: func(fs Filesystem) (Filesystem, error) {
+			return fs.Chroot(path)
+		},
+	})
+}
+
+// Build stacks the layers over the base filesystem, and returns the
+// outermost one. A *ComposeError is returned if a layer lacks a capability it
+// requires, or fails to wrap the filesystem below it.
+func (c *Composition) Build() (Filesystem, error) {
+	if c.base == nil {
+		return nil, errors.New("compose: no base filesystem")
+	}
+
+	fs := c.base
+	for i, l := range c.layers {
+		if missing := l.Requires &^ Capabilities(fs); missing != 0 {
+			return nil, &ComposeError{Layer: l.Name, Index: i, Missing: missing}
+		}
+
+		next, err := l.Wrap(fs)
+		if err != nil {
+			return nil, &ComposeError{Layer: l.Name, Index: i, Err: err}
+		}
+		fs = next
+	}
+
+	return fs, nil
+}
+
+// ComposeError records a layer which couldn't be stacked by Build.
+type ComposeError struct {
+	// Layer is the name of the layer, and Index its position from the
+	// innermost one.
+	Layer string
+	Index int
+	// Missing lists the capabilities required by the layer which the
+	// filesystem below it lacks, if that's why it failed.
+	Missing Capability
+	// Err is the error returned by the Wrap of the layer otherwise.
+	Err error
+}
+
+func (e *ComposeError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("compose: layer %d %q: %v", e.Index, e.Layer, e.Err)
+	}
+
+	return fmt.Sprintf("compose: layer %d %q requires %s, missing from the layers below",
+		e.Index, e.Layer, capabilityNames(e.Missing))
+}
+
+func (e *ComposeError) Unwrap() error { return e.Err }
+
+var capabilityName = []struct {
+	c    Capability
+	name string
+}{
+	{WriteCapability, "write"},
+	{ReadCapability, "read"},
+	{ReadAndWriteCapability, "read-and-write"},
+	{SeekCapability, "seek"},
+	{TruncateCapability, "truncate"},
+	{LockCapability, "lock"},
+	{XattrCapability, "xattr"},
+	{SymlinkCapability, "symlink"},
+	{HardlinkCapability, "hardlink"},
+	{ChangeCapability, "change"},
+	{SyncCapability, "sync"},
+	{CaseInsensitiveCapability, "case-insensitive"},
+	{ConcurrentCapability, "concurrent"},
+	{SandboxCapability, "sandbox"},
+}
+
+// capabilityNames returns the names of the capabilities of c, separated by
+// "|".
+func capabilityNames(c Capability) string {
+	var names []string
+	for _, n := range capabilityName {
+		if c&n.c != 0 {
+			names = append(names, n.name)
+			c &^= n.c
+		}
+	}
+	if c != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint64(c)))
+	}
+
+	return strings.Join(names, "|")
+}
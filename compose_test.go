@@ -0,0 +1,67 @@
+package billy_test
+
+import (
+	"errors"
+
+	. "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/filterfs"
+	"github.com/go-git/go-billy/v5/helper/limitfs"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type ComposeSuite struct{}
+
+var _ = Suite(&ComposeSuite{})
+
+func (s *ComposeSuite) TestBuild(c *C) {
+	base := memfs.New()
+	c.Assert(util.WriteFile(base, "dir/small", []byte("foo"), 0644), IsNil)
+
+	fs, err := Compose(base).
+		Chroot("dir").
+		With(limitfs.Layer(4)).
+		Build()
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("small")
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(fs, "large", []byte("12345"), 0644)
+	var serr *util.FileSizeError
+	c.Assert(errors.As(err, &serr), Equals, true)
+
+	fs, err = Compose(fs).With(filterfs.ReadOnlyLayer()).Build()
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(fs, "other", nil, 0644), Equals, ErrReadOnly)
+}
+
+func (s *ComposeSuite) TestBuildMissingCapability(c *C) {
+	_, err := Compose(memfs.New()).
+		With(filterfs.ReadOnlyLayer()).
+		With(limitfs.Layer(4)).
+		Build()
+
+	var cerr *ComposeError
+	c.Assert(errors.As(err, &cerr), Equals, true)
+	c.Assert(cerr.Index, Equals, 1)
+	c.Assert(cerr.Layer, Equals, "max file size")
+	c.Assert(cerr.Missing, Equals, WriteCapability)
+	c.Assert(err, ErrorMatches, `compose: layer 1 "max file size" requires write, missing from the layers below`)
+}
+
+func (s *ComposeSuite) TestBuildWrapError(c *C) {
+	_, err := Compose(memfs.New()).Chroot("../foo").Build()
+	c.Assert(errors.Is(err, ErrCrossedBoundary), Equals, true)
+
+	var cerr *ComposeError
+	c.Assert(errors.As(err, &cerr), Equals, true)
+	c.Assert(cerr.Layer, Equals, "chroot ../foo")
+}
+
+func (s *ComposeSuite) TestBuildNoBase(c *C) {
+	_, err := Compose(nil).Build()
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,188 @@
+// Package artifactserver is a reference implementation of a component
+// publishing tarball artifacts and serving their content over HTTP, built
+// only from billy filesystems and helpers. It shows how they are meant to be
+// composed:
+//
+//   - helper/instrumented records metrics for every storage operation;
+//   - revisions stages each artifact and promotes it atomically, so requests
+//     never observe a partially extracted artifact;
+//   - chroot confines the extraction to the staging directory;
+//   - helper/iofs exposes the current revision to net/http, read-only.
+//
+// The package is importable and tested, and can be embedded as is.
+package artifactserver // import "github.com/go-git/go-billy/v5/examples/artifactserver"
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/instrumented"
+	"github.com/go-git/go-billy/v5/helper/iofs"
+	"github.com/go-git/go-billy/v5/revisions"
+)
+
+// RevisionHeader is the response header holding the name of the revision a
+// file was served from.
+const RevisionHeader = "X-Artifact-Revision"
+
+// Options configures a Server.
+type Options struct {
+	// Dir is the directory of the storage holding the revisions. Defaults to
+	// the root of the storage.
+	Dir string
+	// Keep is the number of previous revisions retained, allowing to roll
+	// back to them.
+	Keep int
+	// Registerer, if not nil, registers the metrics of the storage.
+	Registerer prometheus.Registerer
+}
+
+// Server publishes artifacts to a storage and serves the files of the last
+// published one. It implements http.Handler.
+type Server struct {
+	fs   billy.Filesystem
+	dir  string
+	revs *revisions.Manager
+
+	// m serializes the publications, which are staged in the same
+	// directory.
+	m sync.Mutex
+}
+
+// New returns a Server storing the artifacts in storage.
+func New(storage billy.Filesystem, opts Options) (*Server, error) {
+	fs, err := instrumented.New(storage, opts.Registerer)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := opts.Dir
+	if dir == "" {
+		dir = "/"
+	}
+
+	return &Server{
+		fs:   fs,
+		dir:  dir,
+		revs: revisions.New(fs, dir, opts.Keep),
+	}, nil
+}
+
+// Publish extracts the tarball read from r, optionally gzip compressed, as
+// the revision name and makes it the one served. Only directories and
+// regular files are extracted. On error the current revision is unchanged.
+func (s *Server) Publish(name string, r io.Reader) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	staged, err := s.revs.Stage(name)
+	if err != nil {
+		return err
+	}
+
+	if err := extract(staged, r); err != nil {
+		return fmt.Errorf("extracting artifact %q: %w", name, err)
+	}
+
+	return s.revs.Promote(name)
+}
+
+// Rollback serves the previously published revision again.
+func (s *Server) Rollback() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.revs.Rollback()
+}
+
+// Revision returns the name of the revision being served, or
+// revisions.ErrNoRevision.
+func (s *Server) Revision() (string, error) {
+	return s.revs.Current()
+}
+
+// ServeHTTP serves the files of the current revision.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, err := s.revs.Current()
+	if errors.Is(err, revisions.ErrNoRevision) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// The revision directory is resolved once, so that a concurrent Publish
+	// doesn't switch revisions in the middle of the request.
+	fs, err := s.fs.Chroot(s.fs.Join(s.dir, "revisions", name))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(RevisionHeader, name)
+	http.FileServer(http.FS(iofs.New(fs))).ServeHTTP(w, r)
+}
+
+func extract(fs billy.Filesystem, r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		r = zr
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = fs.MkdirAll(name, hdr.FileInfo().Mode().Perm()|0o700)
+		case tar.TypeReg:
+			err = extractFile(fs, name, hdr.FileInfo().Mode().Perm(), tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(fs billy.Filesystem, name string, perm os.FileMode, r io.Reader) error {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
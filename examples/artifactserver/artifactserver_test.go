@@ -0,0 +1,177 @@
+package artifactserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+)
+
+func tarball(t *testing.T, compress bool, files map[string]string) io.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser = nopCloser{&buf}
+	if compress {
+		w = gzip.NewWriter(&buf)
+	}
+
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func get(t *testing.T, s *Server, path string) (int, string, string) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Header().Get(RevisionHeader), rec.Body.String()
+}
+
+func TestServer(t *testing.T) {
+	for name, newServer := range map[string]func() *Server{
+		"memfs": func() *Server {
+			s, err := New(memfs.New(), Options{Dir: "artifacts", Keep: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		"osfs": func() *Server {
+			s, err := New(osfs.New(t.TempDir()), Options{Keep: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newServer()
+
+			if code, _, _ := get(t, s, "/index.yaml"); code != http.StatusNotFound {
+				t.Errorf("expected 404 before publishing, got %d", code)
+			}
+
+			err := s.Publish("v1", tarball(t, false, map[string]string{"index.yaml": "v1", "charts/a.tgz": "a"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, rev, body := get(t, s, "/index.yaml")
+			if code != http.StatusOK || rev != "v1" || body != "v1" {
+				t.Errorf("unexpected response %d %q %q", code, rev, body)
+			}
+			if _, _, body := get(t, s, "/charts/a.tgz"); body != "a" {
+				t.Errorf("unexpected body %q", body)
+			}
+
+			err = s.Publish("v2", tarball(t, true, map[string]string{"index.yaml": "v2"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, rev, body = get(t, s, "/index.yaml")
+			if code != http.StatusOK || rev != "v2" || body != "v2" {
+				t.Errorf("unexpected response %d %q %q", code, rev, body)
+			}
+			if code, _, _ := get(t, s, "/charts/a.tgz"); code != http.StatusNotFound {
+				t.Errorf("expected files of v1 to be gone, got %d", code)
+			}
+
+			if err := s.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+			if _, rev, body := get(t, s, "/index.yaml"); rev != "v1" || body != "v1" {
+				t.Errorf("unexpected response after rollback %q %q", rev, body)
+			}
+		})
+	}
+}
+
+func TestPublishInvalid(t *testing.T) {
+	s, err := New(memfs.New(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Publish("v1", tarball(t, false, map[string]string{"index.yaml": "v1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Publish("v2", bytes.NewBufferString("not a tarball")); err == nil {
+		t.Fatal("expected an error publishing an invalid artifact")
+	}
+
+	if rev, err := s.Revision(); err != nil || rev != "v1" {
+		t.Errorf("expected v1 to still be served, got %q %v", rev, err)
+	}
+}
+
+func TestPublishEscaping(t *testing.T) {
+	fs := memfs.New()
+	s, err := New(fs, Options{Dir: "artifacts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Publish("v1", tarball(t, false, map[string]string{"../../../escape": "x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat("escape"); err == nil {
+		t.Error("expected the entry to be confined to the revision")
+	}
+	if _, _, body := get(t, s, "/escape"); body != "x" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, err := New(memfs.New(), Options{Registerer: reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Publish("v1", tarball(t, false, map[string]string{"index.yaml": "v1"})); err != nil {
+		t.Fatal(err)
+	}
+	get(t, s, "/index.yaml")
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) == 0 {
+		t.Error("expected storage metrics to be registered")
+	}
+}
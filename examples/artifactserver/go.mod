@@ -0,0 +1,27 @@
+module github.com/go-git/go-billy/v5/examples/artifactserver
+
+go 1.19
+
+require (
+	github.com/go-git/go-billy/v5 v5.6.0
+	github.com/go-git/go-billy/v5/helper/instrumented v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.14.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	github.com/prometheus/client_model v0.3.0 // indirect
+	github.com/prometheus/common v0.37.0 // indirect
+	github.com/prometheus/procfs v0.8.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
+
+replace (
+	github.com/go-git/go-billy/v5 => ../..
+	github.com/go-git/go-billy/v5/helper/instrumented => ../../helper/instrumented
+)
@@ -1,16 +1,23 @@
 package billy
 
 import (
+	"context"
 	"errors"
 	"io"
+	iofs "io/fs"
 	"os"
+	"strings"
+	"syscall"
 	"time"
 )
 
 var (
-	ErrReadOnly        = errors.New("read-only filesystem")
-	ErrNotSupported    = errors.New("feature not supported")
-	ErrCrossedBoundary = errors.New("chroot boundary crossed")
+	ErrReadOnly          = errors.New("read-only filesystem")
+	ErrNotSupported      = errors.New("feature not supported")
+	ErrCrossedBoundary   = errors.New("chroot boundary crossed")
+	ErrNoXattr           = errors.New("extended attribute not found")
+	ErrSymlinkDenied     = errors.New("symbolic link denied")
+	ErrSpecialFileDenied = errors.New("special file denied")
 )
 
 // Capability holds the supported features of a billy filesystem. This does
@@ -32,6 +39,32 @@ const (
 	TruncateCapability
 	// LockCapability is the ability to lock a file.
 	LockCapability
+	// XattrCapability is the ability to get and set extended attributes,
+	// see Xattrer.
+	XattrCapability
+	// SymlinkCapability is the ability to create and read symbolic links.
+	SymlinkCapability
+	// HardlinkCapability is the ability to create hard links, see Linker.
+	HardlinkCapability
+	// ChangeCapability is the ability to change the mode, ownership and
+	// times of files, see Change.
+	ChangeCapability
+	// SyncCapability is the ability to commit files and directories to
+	// stable storage, see Syncer and DirSyncer.
+	SyncCapability
+	// CaseInsensitiveCapability means that file names differing only by
+	// case refer to the same file. It describes a behavior rather than a
+	// feature, so it is part of neither DefaultCapabilities nor
+	// AllCapabilities.
+	CaseInsensitiveCapability
+	// ConcurrentCapability means that the filesystem is safe for concurrent
+	// use by multiple goroutines, as long as they don't share files.
+	ConcurrentCapability
+	// SandboxCapability means that the operating system itself denies the
+	// process access to the files outside of the root of the filesystem, on
+	// top of the checks done by billy. Like CaseInsensitiveCapability it is
+	// a property rather than a feature, and not part of AllCapabilities.
+	SandboxCapability
 
 	// DefaultCapabilities lists all capable features supported by filesystems
 	// without Capability interface. This list should not be changed until a
@@ -43,7 +76,15 @@ const (
 	// AllCapabilities lists all capable features.
 	AllCapabilities Capability = WriteCapability | ReadCapability |
 		ReadAndWriteCapability | SeekCapability | TruncateCapability |
-		LockCapability
+		LockCapability | XattrCapability | SymlinkCapability |
+		HardlinkCapability | ChangeCapability | SyncCapability |
+		ConcurrentCapability
+
+	// InterfaceCapabilities lists the capabilities provided through optional
+	// interfaces rather than the Filesystem and File ones. Wrappers which
+	// don't forward these interfaces must not report them.
+	InterfaceCapabilities Capability = XattrCapability | HardlinkCapability |
+		ChangeCapability | SyncCapability
 )
 
 // Filesystem abstract the operations in a storage-agnostic interface.
@@ -78,6 +119,15 @@ type Basic interface {
 	// Rename renames (moves) oldpath to newpath. If newpath already exists and
 	// is not a directory, Rename replaces it. OS-specific restrictions may
 	// apply when oldpath and newpath are in different directories.
+	//
+	// The filesystems of this module follow POSIX rename(2) for the existing
+	// targets: a file replaces a file atomically, while renaming a file onto
+	// a directory fails, as does renaming a directory onto a file or onto a
+	// non-empty directory; the source is then left as it is. Renaming a
+	// directory onto an empty one replaces it where the platform allows it,
+	// which Windows doesn't. The filesystems created with their
+	// WithRenameNoReplace option fail with an error satisfying os.IsExist on
+	// any existing target instead.
 	Rename(oldpath, newpath string) error
 	// Remove removes the named file or directory.
 	Remove(filename string) error
@@ -100,6 +150,20 @@ type TempFile interface {
 	TempFile(dir, prefix string) (File, error)
 }
 
+// TempCreator is implemented by the filesystems creating temporary files and
+// directories from a pattern, as os.CreateTemp and os.MkdirTemp do. The last
+// "*" of pattern is replaced by a random string, which is appended if there
+// is none. If dir is the empty string, the default directory for temporary
+// files is used. util.CreateTemp and util.MkdirTemp emulate it for the other
+// filesystems.
+type TempCreator interface {
+	// CreateTemp creates a new file in dir, opened for reading and writing.
+	// The caller can use f.Name() to find its path.
+	CreateTemp(dir, pattern string) (File, error)
+	// MkdirTemp creates a new directory in dir and returns its path.
+	MkdirTemp(dir, pattern string) (string, error)
+}
+
 // Dir abstract the dir related operations in a storage-agnostic interface as
 // an extension to the Basic interface.
 type Dir interface {
@@ -128,6 +192,14 @@ type Symlink interface {
 	Readlink(link string) (string, error)
 }
 
+// Linker abstract the hard link related operations in a storage-agnostic
+// interface as an extension to the Basic interface.
+type Linker interface {
+	// Link creates newname as a hard link to the oldname file. Parent
+	// directories of newname are created as necessary.
+	Link(oldname, newname string) error
+}
+
 // Change abstract the FileInfo change related operations in a storage-agnostic
 // interface as an extension to the Basic interface
 type Change interface {
@@ -148,6 +220,191 @@ type Change interface {
 	Chtimes(name string, atime time.Time, mtime time.Time) error
 }
 
+// Xattrer abstract the extended attributes related operations in a
+// storage-agnostic interface as an extension to the Basic interface. The
+// operations follow symbolic links. Getting or removing a missing attribute
+// returns an error wrapping ErrNoXattr.
+type Xattrer interface {
+	// Getxattr returns the value of the extended attribute attr of the named
+	// file.
+	Getxattr(name, attr string) ([]byte, error)
+	// Setxattr sets the value of the extended attribute attr of the named
+	// file, creating it if needed.
+	Setxattr(name, attr string, value []byte) error
+	// Listxattr returns the names of the extended attributes of the named
+	// file.
+	Listxattr(name string) ([]string, error)
+	// Removexattr removes the extended attribute attr of the named file.
+	Removexattr(name, attr string) error
+}
+
+// Syncer is implemented by the files able to commit their content to stable
+// storage, like os.File.Sync.
+type Syncer interface {
+	// Sync commits the current contents of the file to stable storage.
+	Sync() error
+}
+
+// FileStater is implemented by the files able to describe themselves, like
+// os.File.Stat. Unlike a Stat of the file name, it describes the exact file
+// being read or written, even if it was renamed or replaced meanwhile.
+type FileStater interface {
+	// Stat returns the FileInfo structure describing the file.
+	Stat() (os.FileInfo, error)
+}
+
+// Mmapper is implemented by the files able to expose their content as a byte
+// slice, mapped in memory by osfs, without copying it into a buffer first.
+type Mmapper interface {
+	// Mmap returns the whole content of the file, read-only, and a function
+	// releasing it. The slice must not be used once released, and may or
+	// may not reflect the writes made to the file meanwhile.
+	Mmap() ([]byte, func() error, error)
+}
+
+// Descriptor is implemented by the files backed by a file descriptor of the
+// operating system, such as the ones of osfs, giving access to it for the
+// operations billy doesn't cover: ioctls, fadvise, or passing the file to a
+// child process. The descriptor remains owned by the file, and is only
+// valid until the file is closed.
+type Descriptor interface {
+	// Fd returns the descriptor of the file, as os.File.Fd does, or
+	// ^uintptr(0) if the file has none. On Unix it puts the descriptor in
+	// blocking mode.
+	Fd() uintptr
+	// SyscallConn returns a raw connection to the file, through which the
+	// descriptor is used without changing its mode.
+	SyscallConn() (syscall.RawConn, error)
+}
+
+// RWLocker is implemented by the files supporting shared locks and attempts
+// to lock without blocking, in addition to the exclusive locks of File. A
+// file holds a single lock at a time: locking it again converts the lock it
+// holds, and File.Unlock releases it whatever its kind. util.LockContext and
+// util.RLockContext wait for the locks up to the cancellation of a context.
+type RWLocker interface {
+	// RLock locks the file for reading, like flock(2) with LOCK_SH. Any
+	// number of files can hold a shared lock, while an exclusive one can't
+	// be held at the same time.
+	RLock() error
+	// TryLock attempts to lock the file exclusively, returning false if
+	// another file holds a lock on it.
+	TryLock() (bool, error)
+	// TryRLock attempts to lock the file for reading, returning false if
+	// another file holds an exclusive lock on it.
+	TryRLock() (bool, error)
+}
+
+// Publisher is implemented by the temporary files able to be given a name
+// once complete, such as the anonymous ones of osfs, so that a crash before
+// can't leave them behind.
+type Publisher interface {
+	// Publish links the file at name, failing with an error wrapping
+	// os.ErrExist if it exists already. The file keeps being usable, under
+	// its new name.
+	Publish(name string) error
+}
+
+// Allocator is implemented by the files able to reserve storage ahead of the
+// writes, like fallocate(2), avoiding fragmentation and failing early if the
+// disk is full.
+type Allocator interface {
+	// Allocate reserves the storage of the length bytes starting at off,
+	// extending the file with zeros if they go past its end.
+	Allocate(off, length int64) error
+}
+
+// The whence values of File.Seek finding the data and the holes of sparse
+// files, as lseek(2) does on Linux. Seek returns an error wrapping
+// syscall.ENXIO if offset is past the end of the file. The files without
+// holes, or the filesystems unable to find them, report a hole at the end of
+// the file only.
+const (
+	// SeekData seeks to the first byte of data at or after offset.
+	SeekData = 3
+	// SeekHole seeks to the first byte of a hole at or after offset.
+	SeekHole = 4
+)
+
+// DirSyncer is implemented by the filesystems able to commit the entries of a
+// directory to stable storage, which makes the files created or renamed in
+// it durable.
+type DirSyncer interface {
+	// SyncDir commits the entries of the directory at path to stable storage.
+	SyncDir(path string) error
+}
+
+// Prefetcher is implemented by the filesystems able to fetch the content of a
+// file ahead of its reads, such as the remote or cached ones, or the ones
+// hinting the kernel to read it ahead. util.Prefetch ignores the filesystems
+// which don't implement it.
+type Prefetcher interface {
+	// Prefetch hints that the length bytes of the file at path starting at
+	// off are about to be read. A length of zero means up to the end of the
+	// file. It doesn't wait for the content to be fetched.
+	Prefetch(path string, off, length int64) error
+}
+
+// DirIterator is implemented by the filesystems able to list a directory
+// one entry at a time, so that the huge directories are listed without
+// holding all their entries in memory. Use OpenDir, which falls back to
+// ReadDir for the other filesystems.
+//
+// The wrappers changing the entries returned by ReadDir must not forward it.
+type DirIterator interface {
+	// OpenDir opens the directory at path, following the symbolic links.
+	OpenDir(path string) (DirIter, error)
+}
+
+// DirIter iterates over the entries of a directory, as returned by OpenDir.
+// The order of the entries is unspecified, and the entries added or removed
+// while iterating may or may not be returned.
+type DirIter interface {
+	// Next returns the next entry of the directory, or io.EOF once all of
+	// them were returned.
+	Next() (iofs.DirEntry, error)
+	// Close releases the resources held by the iterator.
+	Close() error
+}
+
+// OpenDir returns an iterator over the entries of the directory at path.
+// DirIterator is used when fs implements it, otherwise, or if it returns
+// ErrNotSupported, the entries are read at once with ReadDir.
+func OpenDir(fs Dir, path string) (DirIter, error) {
+	if d, ok := fs.(DirIterator); ok {
+		it, err := d.OpenDir(path)
+		if !errors.Is(err, ErrNotSupported) {
+			return it, err
+		}
+	}
+
+	infos, err := fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sliceDirIter{infos: infos}, nil
+}
+
+type sliceDirIter struct {
+	infos []os.FileInfo
+}
+
+func (it *sliceDirIter) Next() (iofs.DirEntry, error) {
+	if len(it.infos) == 0 {
+		return nil, io.EOF
+	}
+
+	fi := it.infos[0]
+	it.infos = it.infos[1:]
+	return iofs.FileInfoToDirEntry(fi), nil
+}
+
+func (it *sliceDirIter) Close() error {
+	it.infos = nil
+	return nil
+}
+
 // Chroot abstract the chroot related operations in a storage-agnostic interface
 // as an extension to the Basic interface.
 type Chroot interface {
@@ -159,6 +416,103 @@ type Chroot interface {
 	Root() string
 }
 
+// Scoper is implemented by the filesystems able to return a view of one of
+// their directories more cheaply than through Chroot.
+type Scoper interface {
+	// Scoped returns a filesystem rooted at dir, a valid path as defined by
+	// io/fs.ValidPath, other than ".".
+	Scoped(dir string) (Filesystem, error)
+}
+
+// Scoped returns a filesystem rooted at the directory dir of fs, as io/fs.Sub
+// does. dir is a slash separated path relative to the root of fs, without
+// "." nor ".." elements; "." returns fs itself. Scoper is used when fs
+// implements it, otherwise the result of fs.Chroot is returned.
+func Scoped(fs Filesystem, dir string) (Filesystem, error) {
+	if !iofs.ValidPath(dir) {
+		return nil, &os.PathError{Op: "scoped", Path: dir, Err: os.ErrInvalid}
+	}
+	if dir == "." {
+		return fs, nil
+	}
+
+	if s, ok := fs.(Scoper); ok {
+		return s.Scoped(dir)
+	}
+
+	return fs.Chroot(dir)
+}
+
+// EventOp describes the change reported by an Event.
+type EventOp uint32
+
+const (
+	// EventCreate reports a new file, directory or link, including one
+	// renamed into the watched tree.
+	EventCreate EventOp = 1 << iota
+	// EventWrite reports new content written to a file.
+	EventWrite
+	// EventRemove reports a removed entry.
+	EventRemove
+	// EventRename reports an entry renamed, the new name being reported by
+	// an EventCreate if it is watched.
+	EventRename
+	// EventChmod reports changed attributes, such as the mode.
+	EventChmod
+	// EventOverflow reports that events were lost, the watched tree should
+	// be rescanned.
+	EventOverflow
+)
+
+var eventOps = []string{"CREATE", "WRITE", "REMOVE", "RENAME", "CHMOD", "OVERFLOW"}
+
+func (op EventOp) String() string {
+	var s []string
+	for i, name := range eventOps {
+		if op&(1<<i) != 0 {
+			s = append(s, name)
+		}
+	}
+
+	return strings.Join(s, "|")
+}
+
+// Event is a change reported by a Watcher.
+type Event struct {
+	// Name is the path of the changed entry, in the namespace of the
+	// watched filesystem. It is empty for EventOverflow.
+	Name string
+	Op   EventOp
+	// Err is set on the last event sent before the channel is closed, when
+	// watching fails.
+	Err error
+}
+
+// Watcher is implemented by the filesystems able to report their changes.
+type Watcher interface {
+	// Watch reports the changes of path and, for a directory, of
+	// everything below it. The channel is closed once the returned
+	// function, which stops watching, is called, or when watching fails.
+	Watch(path string) (<-chan Event, func())
+}
+
+// FilesystemCtx abstract the context-aware variants of the Basic and Dir
+// operations, allowing to cancel them or enforce deadlines when working with
+// slow or remote storages. A cancelled operation returns the context error,
+// possibly wrapped in an *os.PathError or *os.LinkError.
+type FilesystemCtx interface {
+	Filesystem
+	CreateContext(ctx context.Context, filename string) (File, error)
+	OpenContext(ctx context.Context, filename string) (File, error)
+	OpenFileContext(ctx context.Context, filename string, flag int, perm os.FileMode) (File, error)
+	StatContext(ctx context.Context, filename string) (os.FileInfo, error)
+	LstatContext(ctx context.Context, filename string) (os.FileInfo, error)
+	RenameContext(ctx context.Context, oldpath, newpath string) error
+	RemoveContext(ctx context.Context, filename string) error
+	ReadDirContext(ctx context.Context, path string) ([]os.FileInfo, error)
+	MkdirAllContext(ctx context.Context, filename string, perm os.FileMode) error
+}
+
 // File represent a file, being a subset of the os.File
 type File interface {
 	// Name returns the name of the file as presented to Open.
@@ -183,6 +537,46 @@ type Capable interface {
 	Capabilities() Capability
 }
 
+// StatFS is implemented by the filesystems able to report the space and the
+// inodes left on the volume holding a path, as statfs(2) does, so that work
+// can be refused upfront instead of failing halfway through.
+type StatFS interface {
+	StatFS(path string) (*FSInfo, error)
+}
+
+// FSInfo holds the usage of a volume, as returned by StatFS. The counts the
+// volume doesn't track, such as the inodes on Windows, are zero.
+type FSInfo struct {
+	// Total is the size of the volume in bytes.
+	Total uint64
+	// Free is the number of bytes available to unprivileged users.
+	Free uint64
+	// Used is the number of bytes in use.
+	Used uint64
+	// Inodes is the number of inodes of the volume.
+	Inodes uint64
+	// FreeInodes is the number of inodes left.
+	FreeInodes uint64
+}
+
+// Closer is implemented by the filesystems holding resources, such as
+// connections, clients or temporary files, to release them deterministically.
+// A filesystem can't be used once closed. The wrappers forward Close to the
+// filesystems they wrap.
+type Closer interface {
+	Close() error
+}
+
+// Close closes fs if it implements Closer, and does nothing otherwise.
+func Close(fs Basic) error {
+	c, ok := fs.(Closer)
+	if !ok {
+		return nil
+	}
+
+	return c.Close()
+}
+
 // Capabilities returns the features supported by a filesystem. If the FS
 // does not implement Capable interface it returns all features.
 func Capabilities(fs Basic) Capability {
@@ -1,10 +1,16 @@
 package billy_test
 
 import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
 
 	. "gopkg.in/check.v1"
 )
@@ -38,3 +44,71 @@ func (s *FSSuite) TestCapabilities(c *C) {
 	dummy := new(test.BasicMock)
 	c.Assert(Capabilities(dummy), Equals, DefaultCapabilities)
 }
+
+func (s *FSSuite) TestScoped(c *C) {
+	fs := memfs.New()
+	c.Assert(util.WriteFile(fs, "foo/bar/qux", []byte("qux"), 0644), IsNil)
+
+	scoped, err := Scoped(fs, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(scoped.Root(), Equals, filepath.Join(string(filepath.Separator), "foo"))
+
+	scoped, err = Scoped(scoped, "bar")
+	c.Assert(err, IsNil)
+
+	b, err := util.ReadFile(scoped, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "qux")
+
+	same, err := Scoped(fs, ".")
+	c.Assert(err, IsNil)
+	c.Assert(same, Equals, fs)
+
+	for _, dir := range []string{"", "/foo", "../foo", "foo/../bar", "foo/"} {
+		_, err := Scoped(fs, dir)
+		c.Assert(errors.Is(err, os.ErrInvalid), Equals, true, Commentf("dir %q", dir))
+	}
+}
+
+func (s *FSSuite) TestScopedChroot(c *C) {
+	mem := memfs.New()
+	c.Assert(util.WriteFile(mem, "foo/bar/qux", []byte("qux"), 0644), IsNil)
+
+	// Hide the Scoper implementation, leaving Chroot.
+	fs := struct{ Filesystem }{mem}
+	_, ok := Filesystem(fs).(Scoper)
+	c.Assert(ok, Equals, false)
+
+	scoped, err := Scoped(fs, "foo/bar")
+	c.Assert(err, IsNil)
+
+	b, err := util.ReadFile(scoped, "qux")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "qux")
+}
+
+func (s *FSSuite) TestOpenDirReadDir(c *C) {
+	mem := memfs.New()
+	c.Assert(util.WriteFile(mem, "foo/qux", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(mem, "foo/bar", nil, 0644), IsNil)
+
+	// Hide the DirIterator implementation, leaving ReadDir.
+	fs := struct{ Filesystem }{mem}
+	_, ok := Filesystem(fs).(DirIterator)
+	c.Assert(ok, Equals, false)
+
+	d, err := OpenDir(fs, "foo")
+	c.Assert(err, IsNil)
+	defer d.Close()
+
+	var names []string
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		names = append(names, e.Name())
+	}
+	c.Assert(names, DeepEquals, []string{"bar", "qux"})
+}
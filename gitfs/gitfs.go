@@ -0,0 +1,628 @@
+// Package gitfs provides a read-only billy filesystem exposing a git tree,
+// the one of a commit for instance, or the entries of an index, without
+// checking them out. The trees and the blobs are read from the repository
+// as they are reached.
+//
+// The repository is accessed through Objects, which doesn't tie the package
+// to a git implementation. With go-git, it takes a few lines on top of a
+// *git.Repository, its TreeObject and BlobObject methods providing the
+// entries and the content of the objects:
+//
+//	func (r objects) Tree(hash string) ([]gitfs.Entry, error) {
+//		t, err := r.TreeObject(plumbing.NewHash(hash))
+//		if err != nil {
+//			return nil, err
+//		}
+//		entries := make([]gitfs.Entry, len(t.Entries))
+//		for i, e := range t.Entries {
+//			entries[i] = gitfs.Entry{Name: e.Name, Mode: uint32(e.Mode), Hash: e.Hash.String()}
+//		}
+//		return entries, nil
+//	}
+package gitfs // import "github.com/go-git/go-billy/v5/gitfs"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// The modes of the git tree entries.
+const (
+	ModeDir        uint32 = 0o040000
+	ModeRegular    uint32 = 0o100644
+	ModeExecutable uint32 = 0o100755
+	ModeSymlink    uint32 = 0o120000
+	ModeSubmodule  uint32 = 0o160000
+)
+
+// maxLinks is the number of symbolic links followed resolving a path.
+const maxLinks = 255
+
+// Entry is an entry of a git tree, or of an index.
+type Entry struct {
+	// Name is the name of the entry in its tree, or its slash separated
+	// path for an index.
+	Name string
+	// Mode is the git mode of the entry, such as ModeRegular.
+	Mode uint32
+	// Hash is the id of the object of the entry.
+	Hash string
+}
+
+// Objects reads the objects of a repository.
+type Objects interface {
+	// Tree returns the entries of the tree with the given id.
+	Tree(hash string) ([]Entry, error)
+	// BlobSize returns the size of the content of the blob with the given
+	// id.
+	BlobSize(hash string) (int64, error)
+	// Blob returns a reader of the content of the blob with the given id.
+	Blob(hash string) (io.ReadCloser, error)
+}
+
+// Options configures an FS.
+type Options struct {
+	// ModTime is the modification time of every file, such as the time of
+	// the commit, git trees having none.
+	ModTime time.Time
+}
+
+// FS is a read-only billy.Filesystem made of the entries of a git tree.
+// Every write operation fails with billy.ErrReadOnly.
+//
+// The submodules show as empty directories, as they are when not
+// initialized. The absolute targets of the symbolic links are resolved from
+// the root of the tree. FS is safe for concurrent use.
+type FS struct {
+	objs Objects
+	opts Options
+	root *node
+
+	// m guards the nodes loaded lazily.
+	m sync.Mutex
+}
+
+// node is an entry of the tree.
+type node struct {
+	Entry
+	// children are the entries of a directory, nil until loaded.
+	children map[string]*node
+	// size is the size of a blob, negative until known.
+	size int64
+	// target is the target of a symbolic link, read once needed.
+	target *string
+}
+
+func newNode(e Entry) *node {
+	return &node{Entry: e, size: -1}
+}
+
+// New returns the filesystem of the tree with the given id.
+func New(objs Objects, tree string, opts Options) *FS {
+	return &FS{objs: objs, opts: opts, root: newNode(Entry{Mode: ModeDir, Hash: tree})}
+}
+
+// NewIndex returns the filesystem made of the entries of an index, their
+// names being their slash separated paths, such as "dir/file". Only the
+// blobs are read from the repository.
+func NewIndex(objs Objects, entries []Entry, opts Options) (*FS, error) {
+	root := newNode(Entry{Mode: ModeDir})
+	root.children = make(map[string]*node)
+
+	for _, e := range entries {
+		elems := strings.Split(e.Name, "/")
+		for _, elem := range elems {
+			if elem == "" || elem == "." || elem == ".." {
+				return nil, &os.PathError{Op: "index", Path: e.Name, Err: os.ErrInvalid}
+			}
+		}
+
+		dir := root
+		for _, elem := range elems[:len(elems)-1] {
+			child, ok := dir.children[elem]
+			if !ok {
+				child = newNode(Entry{Name: elem, Mode: ModeDir})
+				child.children = make(map[string]*node)
+				dir.children[elem] = child
+			}
+			if child.children == nil {
+				return nil, &os.PathError{Op: "index", Path: e.Name, Err: syscall.ENOTDIR}
+			}
+			dir = child
+		}
+
+		n := newNode(Entry{Name: elems[len(elems)-1], Mode: e.Mode, Hash: e.Hash})
+		if e.Mode == ModeDir || e.Mode == ModeSubmodule {
+			n.children = make(map[string]*node)
+		}
+		dir.children[n.Name] = n
+	}
+
+	return &FS{objs: objs, opts: opts, root: root}, nil
+}
+
+// load returns the entries of the directory n, reading its tree if needed.
+func (fs *FS) load(n *node) (map[string]*node, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if n.children != nil {
+		return n.children, nil
+	}
+
+	children := make(map[string]*node)
+	if n.Mode == ModeDir {
+		entries, err := fs.objs.Tree(n.Hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			children[e.Name] = newNode(e)
+		}
+	}
+
+	n.children = children
+	return children, nil
+}
+
+// readlink returns the target of the symbolic link n.
+func (fs *FS) readlink(n *node) (string, error) {
+	fs.m.Lock()
+	target := n.target
+	fs.m.Unlock()
+	if target != nil {
+		return *target, nil
+	}
+
+	r, err := fs.objs.Blob(n.Hash)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	s := string(b)
+	fs.m.Lock()
+	n.target = &s
+	fs.m.Unlock()
+
+	return s, nil
+}
+
+// size returns the size of the content of n.
+func (fs *FS) size(n *node) (int64, error) {
+	if isDir(n.Mode) {
+		return 0, nil
+	}
+
+	fs.m.Lock()
+	size := n.size
+	fs.m.Unlock()
+	if size >= 0 {
+		return size, nil
+	}
+
+	size, err := fs.objs.BlobSize(n.Hash)
+	if err != nil {
+		return 0, err
+	}
+
+	fs.m.Lock()
+	n.size = size
+	fs.m.Unlock()
+
+	return size, nil
+}
+
+// resolve returns the node at name, following the symbolic links leading to
+// it, as well as the final one if follow is true.
+func (fs *FS) resolve(op, name string, follow bool) (*node, error) {
+	pending := splitPath(filepath.ToSlash(name))
+	n := fs.root
+	var parents []*node
+
+	links := 0
+	for len(pending) > 0 {
+		elem := pending[0]
+		pending = pending[1:]
+
+		switch elem {
+		case ".":
+			continue
+		case "..":
+			if len(parents) > 0 {
+				n = parents[len(parents)-1]
+				parents = parents[:len(parents)-1]
+			}
+			continue
+		}
+
+		if !isDir(n.Mode) {
+			return nil, &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+
+		children, err := fs.load(n)
+		if err != nil {
+			return nil, &os.PathError{Op: op, Path: name, Err: err}
+		}
+
+		child, ok := children[elem]
+		if !ok {
+			return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+
+		if child.Mode == ModeSymlink && (follow || len(pending) > 0) {
+			if links++; links > maxLinks {
+				return nil, &os.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+			}
+
+			target, err := fs.readlink(child)
+			if err != nil {
+				return nil, &os.PathError{Op: op, Path: name, Err: err}
+			}
+			if path.IsAbs(target) {
+				n, parents = fs.root, nil
+			}
+			pending = append(splitPath(target), pending...)
+			continue
+		}
+
+		parents = append(parents, n)
+		n = child
+	}
+
+	return n, nil
+}
+
+func splitPath(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool { return r == '/' })
+}
+
+func isDir(mode uint32) bool {
+	return mode == ModeDir || mode == ModeSubmodule
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the regular file or directory at filename for reading, its
+// content being read from the repository by the first read. The flags
+// opening it for writing make it fail with billy.ErrReadOnly.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+
+	n, err := fs.resolve("open", filename, true)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := fs.size(n)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	return &file{fs: fs, name: filename, n: n, size: size}, nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	return fs.stat("stat", filename, true)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	return fs.stat("lstat", filename, false)
+}
+
+func (fs *FS) stat(op, filename string, follow bool) (os.FileInfo, error) {
+	n, err := fs.resolve(op, filename, follow)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.newFileInfo(filepath.Base(filename), n)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: filename, Err: err}
+	}
+
+	return fi, nil
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	n, err := fs.resolve("readlink", link, false)
+	if err != nil {
+		return "", err
+	}
+	if n.Mode != ModeSymlink {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: syscall.EINVAL}
+	}
+
+	target, err := fs.readlink(n)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: err}
+	}
+
+	return target, nil
+}
+
+// ReadDir returns the entries of the directory at path, sorted by name.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	n, err := fs.resolve("readdir", path, true)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir(n.Mode) {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	children, err := fs.load(n)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: err}
+	}
+
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fis := make([]os.FileInfo, len(names))
+	for i, name := range names {
+		fi, err := fs.newFileInfo(name, children[name])
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: path, Err: err}
+		}
+		fis[i] = fi
+	}
+
+	return fis, nil
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *FS) Root() string {
+	return "/"
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// Capabilities implements the Capable interface.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability | billy.SymlinkCapability | billy.ConcurrentCapability
+}
+
+type fileInfo struct {
+	name    string
+	entry   Entry
+	size    int64
+	modTime time.Time
+}
+
+func (fs *FS) newFileInfo(name string, n *node) (*fileInfo, error) {
+	size, err := fs.size(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{name: name, entry: n.Entry, size: size, modTime: fs.opts.ModTime}, nil
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return isDir(fi.entry.Mode) }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	switch fi.entry.Mode {
+	case ModeDir, ModeSubmodule:
+		return os.ModeDir | 0o755
+	case ModeSymlink:
+		return os.ModeSymlink | 0o777
+	case ModeExecutable:
+		return 0o755
+	default:
+		return 0o644
+	}
+}
+
+// Sys returns the Entry of the file in its tree.
+func (fi *fileInfo) Sys() interface{} {
+	return fi.entry
+}
+
+// file is a regular file or a directory opened for reading.
+type file struct {
+	fs   *FS
+	name string
+	n    *node
+	size int64
+
+	off    int64
+	closed bool
+	// r reads the content of the blob from roff, reused by the
+	// sequential reads.
+	r    io.ReadCloser
+	roff int64
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) check(op string) error {
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	if isDir(f.n.Mode) {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+
+	return nil
+}
+
+// reader returns a reader of the content from off, reading the blob again
+// from its start to go backwards.
+func (f *file) reader(off int64) (io.Reader, error) {
+	if f.r == nil || f.roff > off {
+		if f.r != nil {
+			f.r.Close()
+		}
+
+		r, err := f.fs.objs.Blob(f.n.Hash)
+		if err != nil {
+			f.r = nil
+			return nil, err
+		}
+		f.r, f.roff = r, 0
+	}
+
+	if skip := off - f.roff; skip > 0 {
+		n, err := io.CopyN(io.Discard, f.r, skip)
+		f.roff += n
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f.r, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if f.off >= f.size {
+		return 0, io.EOF
+	}
+
+	r, err := f.reader(f.off)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+
+	n, err := r.Read(p)
+	f.off += int64(n)
+	f.roff += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	r, err := f.reader(off)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+
+	n, err := io.ReadFull(r, p)
+	f.roff += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	f.off = offset
+	return offset, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: billy.ErrReadOnly}
+}
+
+func (f *file) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: f.name, Err: billy.ErrReadOnly}
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+
+	f.closed = true
+	if f.r != nil {
+		return f.r.Close()
+	}
+
+	return nil
+}
+
+// Stat returns the FileInfo of the file, as billy.FileStater.
+func (f *file) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: filepath.Base(f.name), entry: f.n.Entry, size: f.size, modTime: f.fs.opts.ModTime}, nil
+}
@@ -0,0 +1,197 @@
+package gitfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// objects is a repository in memory, counting the objects read.
+type objects struct {
+	trees map[string][]Entry
+	blobs map[string]string
+	reads map[string]int
+}
+
+func (o *objects) Tree(hash string) ([]Entry, error) {
+	o.reads[hash]++
+	entries, ok := o.trees[hash]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return entries, nil
+}
+
+func (o *objects) BlobSize(hash string) (int64, error) {
+	blob, ok := o.blobs[hash]
+	if !ok {
+		return 0, errors.New("object not found")
+	}
+	return int64(len(blob)), nil
+}
+
+func (o *objects) Blob(hash string) (io.ReadCloser, error) {
+	o.reads[hash]++
+	blob, ok := o.blobs[hash]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(strings.NewReader(blob)), nil
+}
+
+func newObjects() *objects {
+	return &objects{
+		trees: map[string][]Entry{
+			"root": {
+				{Name: "README", Mode: ModeRegular, Hash: "readme"},
+				{Name: "bin", Mode: ModeDir, Hash: "bin"},
+				{Name: "docs", Mode: ModeSymlink, Hash: "docs-link"},
+				{Name: "vendor", Mode: ModeSubmodule, Hash: "commit"},
+			},
+			"bin": {
+				{Name: "run", Mode: ModeExecutable, Hash: "run"},
+				{Name: "up", Mode: ModeSymlink, Hash: "up-link"},
+			},
+		},
+		blobs: map[string]string{
+			"readme":    "0123456789",
+			"run":       "#!/bin/sh",
+			"docs-link": "bin",
+			"up-link":   "../README",
+		},
+		reads: make(map[string]int),
+	}
+}
+
+func TestTree(t *testing.T) {
+	objs := newObjects()
+	mtime := time.Unix(1700000000, 0)
+	fs := New(objs, "root", Options{ModTime: mtime})
+
+	if objs.reads["root"] != 0 {
+		t.Error("expected the tree to be read lazily")
+	}
+
+	for name, want := range map[string]string{
+		"README":         "0123456789",
+		"bin/run":        "#!/bin/sh",
+		"docs/run":       "#!/bin/sh",
+		"/bin/up":        "0123456789",
+		"bin/../bin/run": "#!/bin/sh",
+	} {
+		got, err := util.ReadFile(fs, name)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+	if objs.reads["root"] != 1 || objs.reads["bin"] != 1 {
+		t.Errorf("got %v, want each tree read once", objs.reads)
+	}
+
+	fi, err := fs.Stat("bin/run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0o755 || fi.Size() != 9 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("got %s, %d bytes, %s", fi.Mode(), fi.Size(), fi.ModTime())
+	}
+	if e, ok := fi.Sys().(Entry); !ok || e.Hash != "run" {
+		t.Errorf("got %#v, want the entry", fi.Sys())
+	}
+
+	fis, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	if got := strings.Join(names, " "); got != "README bin docs vendor" {
+		t.Errorf("got %q", got)
+	}
+
+	if fis, err := fs.ReadDir("vendor"); err != nil || len(fis) != 0 {
+		t.Errorf("submodule: got %v, %v", fis, err)
+	}
+	if target, err := fs.Readlink("docs"); err != nil || target != "bin" {
+		t.Errorf("got %q, %v", target, err)
+	}
+	if _, err := fs.Stat("missing"); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+	if _, err := fs.ReadDir("README"); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got %v, want ENOTDIR", err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	objs := newObjects()
+	fs := New(objs, "root", Options{})
+
+	f, err := fs.Open("README")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := make([]byte, 3)
+	if n, err := f.ReadAt(p, 7); n != 3 || err != nil || string(p) != "789" {
+		t.Errorf("got %d, %v, %q", n, err, p)
+	}
+	if n, err := f.ReadAt(p, 2); n != 3 || err != nil || string(p) != "234" {
+		t.Errorf("got %d, %v, %q", n, err, p)
+	}
+	if n, err := f.ReadAt(p, 8); n != 2 || err != io.EOF {
+		t.Errorf("got %d, %v, want EOF", n, err)
+	}
+
+	if _, err := f.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(f); err != nil || string(got) != "6789" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if _, err := f.Write([]byte("foo")); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("got %v, want read-only", err)
+	}
+	if _, err := fs.Create("foo"); err != billy.ErrReadOnly {
+		t.Errorf("got %v, want read-only", err)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	objs := newObjects()
+	fs, err := NewIndex(objs, []Entry{
+		{Name: "a/b/README", Mode: ModeRegular, Hash: "readme"},
+		{Name: "a/run", Mode: ModeExecutable, Hash: "run"},
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := util.ReadFile(fs, "a/b/README"); err != nil || string(got) != "0123456789" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if fis, err := fs.ReadDir("a"); err != nil || len(fis) != 2 || !fis[0].IsDir() {
+		t.Errorf("got %v, %v", fis, err)
+	}
+
+	if _, err := NewIndex(objs, []Entry{{Name: "../escape", Mode: ModeRegular}}, Options{}); err == nil {
+		t.Error("expected an invalid path to fail")
+	}
+	if _, err := NewIndex(objs, []Entry{
+		{Name: "a", Mode: ModeRegular, Hash: "run"},
+		{Name: "a/b", Mode: ModeRegular, Hash: "run"},
+	}, Options{}); err == nil {
+		t.Error("expected a file under a file to fail")
+	}
+}
@@ -3,8 +3,13 @@ module github.com/go-git/go-billy/v5
 go 1.19
 
 require (
+	github.com/go-logr/logr v1.2.3
 	github.com/onsi/gomega v1.27.2
+	github.com/opencontainers/go-digest v1.0.0
+	golang.org/x/net v0.7.0
 	golang.org/x/sys v0.5.0
+	golang.org/x/text v0.7.0
+	golang.org/x/time v0.3.0
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
 )
 
@@ -12,7 +17,5 @@ require (
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/kr/pretty v0.2.1 // indirect
 	github.com/kr/text v0.2.0 // indirect
-	golang.org/x/net v0.7.0 // indirect
-	golang.org/x/text v0.7.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
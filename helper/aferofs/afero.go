@@ -0,0 +1,377 @@
+package aferofs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+var (
+	_ afero.Fs        = (*Afero)(nil)
+	_ afero.Symlinker = (*Afero)(nil)
+)
+
+// Afero is an afero.Fs backed by a billy.Filesystem.
+type Afero struct {
+	fs billy.Filesystem
+}
+
+// NewAfero returns an afero.Fs backed by the given billy filesystem, wrapped
+// in an afero.ReadOnlyFs if it lacks billy.WriteCapability. The filesystems
+// returned by New are unwrapped.
+func NewAfero(fs billy.Filesystem) afero.Fs {
+	if f, ok := fs.(*FS); ok {
+		return f.fs
+	}
+
+	a := &Afero{fs: fs}
+	if !billy.CapabilityCheck(fs, billy.WriteCapability) {
+		return afero.NewReadOnlyFs(a)
+	}
+
+	return a
+}
+
+func (a *Afero) Create(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir creates the directory at name only, unlike billy.Dir.MkdirAll,
+// failing if it exists or if its parent doesn't.
+func (a *Afero) Mkdir(name string, perm os.FileMode) error {
+	if _, err := a.fs.Lstat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	if dir := filepath.Dir(name); dir != name {
+		fi, err := a.fs.Stat(dir)
+		if err != nil && !isRoot(a.fs, dir) {
+			return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+		}
+		if err == nil && !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+	}
+
+	return a.fs.MkdirAll(name, perm)
+}
+
+func (a *Afero) MkdirAll(path string, perm os.FileMode) error {
+	return a.fs.MkdirAll(path, perm)
+}
+
+func (a *Afero) Open(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the directories for reading only, to list their entries.
+func (a *Afero) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	fi, err := a.fs.Stat(name)
+	if err == nil && fi.IsDir() || os.IsNotExist(err) && isRoot(a.fs, name) {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+
+		return &aferoDir{fs: a.fs, name: name, info: fi}, nil
+	}
+
+	f, err := a.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aferoFile{File: f, fs: a.fs}, nil
+}
+
+func (a *Afero) Remove(name string) error {
+	return a.fs.Remove(name)
+}
+
+func (a *Afero) RemoveAll(path string) error {
+	return util.RemoveAll(a.fs, path)
+}
+
+func (a *Afero) Rename(oldname, newname string) error {
+	return a.fs.Rename(oldname, newname)
+}
+
+func (a *Afero) Stat(name string) (os.FileInfo, error) {
+	return a.fs.Stat(name)
+}
+
+// Name returns the name of the filesystem, as afero.Fs.
+func (a *Afero) Name() string {
+	return "billy"
+}
+
+// Chmod changes the mode of the file if the billy filesystem implements
+// billy.Change, as do Chown and Chtimes.
+func (a *Afero) Chmod(name string, mode os.FileMode) error {
+	ch, err := a.change("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	return ch.Chmod(name, mode)
+}
+
+func (a *Afero) Chown(name string, uid, gid int) error {
+	ch, err := a.change("chown", name)
+	if err != nil {
+		return err
+	}
+
+	return ch.Chown(name, uid, gid)
+}
+
+func (a *Afero) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	ch, err := a.change("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return ch.Chtimes(name, atime, mtime)
+}
+
+func (a *Afero) change(op, name string) (billy.Change, error) {
+	ch, ok := a.fs.(billy.Change)
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: billy.ErrNotSupported}
+	}
+
+	return ch, nil
+}
+
+// LstatIfPossible implements afero.Lstater, always calling Lstat.
+func (a *Afero) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	fi, err := a.fs.Lstat(name)
+	return fi, true, err
+}
+
+// SymlinkIfPossible implements afero.Linker, failing with afero.ErrNoSymlink
+// if the billy filesystem doesn't support symbolic links.
+func (a *Afero) SymlinkIfPossible(oldname, newname string) error {
+	err := a.fs.Symlink(oldname, newname)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: afero.ErrNoSymlink}
+	}
+
+	return err
+}
+
+// ReadlinkIfPossible implements afero.LinkReader, failing with
+// afero.ErrNoReadlink if the billy filesystem doesn't support symbolic links.
+func (a *Afero) ReadlinkIfPossible(name string) (string, error) {
+	target, err := a.fs.Readlink(name)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
+	}
+
+	return target, err
+}
+
+// isRoot reports whether name is the root of fs, which exists even if fs,
+// like an empty memfs, reports it missing.
+func isRoot(fs billy.Filesystem, name string) bool {
+	clean := fs.Join(string(filepath.Separator), name)
+	return clean == string(filepath.Separator) || clean == "."
+}
+
+// aferoFile is a billy.File, as an afero.File.
+type aferoFile struct {
+	billy.File
+	fs billy.Filesystem
+
+	// m serializes the writes at an offset, done by seeking.
+	m sync.Mutex
+}
+
+func (f *aferoFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.Name(), Err: syscall.ENOTDIR}
+}
+
+func (f *aferoFile) Readdirnames(n int) ([]string, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.Name(), Err: syscall.ENOTDIR}
+}
+
+// Stat describes the file with billy.FileStater if implemented, and with a
+// Stat of its name otherwise.
+func (f *aferoFile) Stat() (os.FileInfo, error) {
+	if s, ok := f.File.(billy.FileStater); ok {
+		return s.Stat()
+	}
+
+	return f.fs.Stat(f.Name())
+}
+
+// Sync commits the file with billy.Syncer, being a no-op for the files
+// which don't implement it.
+func (f *aferoFile) Sync() error {
+	if s, ok := f.File.(billy.Syncer); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+// WriteAt writes p at off, billy.File having no WriteAt, by seeking there
+// and back.
+func (f *aferoFile) WriteAt(p []byte, off int64) (int, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	cur, err := f.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.File.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := f.File.Write(p)
+	if _, serr := f.File.Seek(cur, io.SeekStart); err == nil {
+		err = serr
+	}
+
+	return n, err
+}
+
+func (f *aferoFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// aferoDir is a directory opened to list its entries, as an afero.File.
+type aferoDir struct {
+	fs   billy.Filesystem
+	name string
+	// info is nil for the root missing from the filesystem.
+	info os.FileInfo
+
+	entries []os.FileInfo
+	offset  int
+	read    bool
+}
+
+func (d *aferoDir) Name() string {
+	return d.name
+}
+
+func (d *aferoDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.read {
+		fis, err := d.fs.ReadDir(d.name)
+		if err != nil && !(d.info == nil && os.IsNotExist(err)) {
+			return nil, err
+		}
+
+		sortInfos(fis)
+		d.entries, d.read = fis, true
+	}
+
+	return readdir(d.entries, &d.offset, count)
+}
+
+func (d *aferoDir) Readdirnames(n int) ([]string, error) {
+	fis, err := d.Readdir(n)
+	names := make([]string, len(fis))
+	for i, fi := range fis {
+		names[i] = fi.Name()
+	}
+
+	return names, err
+}
+
+func (d *aferoDir) Stat() (os.FileInfo, error) {
+	if d.info == nil {
+		return rootInfo{}, nil
+	}
+
+	return d.info, nil
+}
+
+func (d *aferoDir) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *aferoDir) ReadAt([]byte, int64) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *aferoDir) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *aferoDir) WriteAt([]byte, int64) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *aferoDir) WriteString(string) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *aferoDir) Truncate(int64) error {
+	return &os.PathError{Op: "truncate", Path: d.name, Err: syscall.EISDIR}
+}
+
+// Seek only supports going back to the start, to list the entries again.
+func (d *aferoDir) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, &os.PathError{Op: "seek", Path: d.name, Err: syscall.EINVAL}
+	}
+
+	d.entries, d.offset, d.read = nil, 0, false
+	return 0, nil
+}
+
+func (d *aferoDir) Sync() error {
+	return nil
+}
+
+func (d *aferoDir) Close() error {
+	return nil
+}
+
+// rootInfo describes the root missing from the billy filesystem.
+type rootInfo struct{}
+
+func (rootInfo) Name() string       { return string(filepath.Separator) }
+func (rootInfo) Size() int64        { return 0 }
+func (rootInfo) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (rootInfo) ModTime() time.Time { return time.Time{} }
+func (rootInfo) IsDir() bool        { return true }
+func (rootInfo) Sys() interface{}   { return nil }
+
+// sortInfos sorts fis by name, as os.ReadDir does.
+func sortInfos(fis []os.FileInfo) {
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+}
+
+// readdir returns the count next entries of fis from *offset, with the
+// semantics of os.File.Readdir.
+func readdir(fis []os.FileInfo, offset *int, count int) ([]os.FileInfo, error) {
+	rest := fis[*offset:]
+	if count <= 0 {
+		*offset = len(fis)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(rest) {
+		count = len(rest)
+	}
+	*offset += count
+
+	return rest[:count], nil
+}
@@ -0,0 +1,212 @@
+// Package aferofs adapts the filesystems of github.com/spf13/afero to billy
+// and the other way around, for the projects using both.
+//
+// New exposes an afero.Fs as a billy.Filesystem, and NewAfero a
+// billy.Filesystem as an afero.Fs. Each side gets the symbolic links and the
+// changes of attributes the other side supports, and the read-only
+// filesystems stay read-only.
+//
+// It is a module of its own, so that the users of billy don't depend on
+// afero.
+package aferofs // import "github.com/go-git/go-billy/v5/helper/aferofs"
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+var (
+	_ billy.Filesystem = (*FS)(nil)
+	_ billy.Change     = (*FS)(nil)
+	_ billy.Capable    = (*FS)(nil)
+)
+
+// FS is a billy.Filesystem backed by an afero.Fs. The symbolic links are
+// supported if the afero.Fs implements afero.Symlinker, Lstat being Stat
+// otherwise.
+type FS struct {
+	fs afero.Fs
+}
+
+// New returns a billy.Filesystem backed by the given afero filesystem. The
+// filesystems returned by NewAfero are unwrapped.
+func New(fs afero.Fs) billy.Filesystem {
+	if a, ok := fs.(*Afero); ok {
+		return a.fs
+	}
+
+	return &FS{fs: fs}
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile creates the missing parent directories of the files created, as
+// the other billy filesystems do.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		if err := fs.fs.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := fs.fs.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f}, nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	return fs.fs.Stat(filename)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	if l, ok := fs.fs.(afero.Lstater); ok {
+		fi, _, err := l.LstatIfPossible(filename)
+		return fi, err
+	}
+
+	return fs.fs.Stat(filename)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	if err := fs.fs.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+
+	return fs.fs.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	return fs.fs.Remove(filename)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return util.TempFile(fs, dir, prefix)
+}
+
+// ReadDir returns the entries of the directory at path, sorted by name.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	return afero.ReadDir(fs.fs, path)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.fs.MkdirAll(filename, perm)
+}
+
+// Symlink creates a symbolic link if the afero.Fs implements afero.Linker,
+// failing with billy.ErrNotSupported otherwise.
+func (fs *FS) Symlink(target, link string) error {
+	l, ok := fs.fs.(afero.Linker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: billy.ErrNotSupported}
+	}
+
+	if err := fs.fs.MkdirAll(filepath.Dir(link), 0777); err != nil {
+		return err
+	}
+
+	return fromAferoError(l.SymlinkIfPossible(target, link))
+}
+
+// Readlink reads a symbolic link if the afero.Fs implements
+// afero.LinkReader, failing with billy.ErrNotSupported otherwise.
+func (fs *FS) Readlink(link string) (string, error) {
+	r, ok := fs.fs.(afero.LinkReader)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: billy.ErrNotSupported}
+	}
+
+	target, err := r.ReadlinkIfPossible(link)
+	return target, fromAferoError(err)
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return string(filepath.Separator)
+}
+
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	return fs.fs.Chmod(name, mode)
+}
+
+// Lchown fails with billy.ErrNotSupported, afero having no such operation.
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	return &os.PathError{Op: "lchown", Path: name, Err: billy.ErrNotSupported}
+}
+
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.fs.Chown(name, uid, gid)
+}
+
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.fs.Chtimes(name, atime, mtime)
+}
+
+// Capabilities implements the Capable interface. The files can't be locked,
+// and an afero.ReadOnlyFs can't be written.
+func (fs *FS) Capabilities() billy.Capability {
+	c := billy.ReadCapability | billy.SeekCapability | billy.ChangeCapability
+	if _, ok := fs.fs.(*afero.ReadOnlyFs); !ok {
+		c |= billy.WriteCapability | billy.ReadAndWriteCapability | billy.TruncateCapability
+	}
+	if _, ok := fs.fs.(afero.Symlinker); ok {
+		c |= billy.SymlinkCapability
+	}
+
+	return c
+}
+
+// file is an afero.File, as a billy.File.
+type file struct {
+	afero.File
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+// fromAferoError replaces the errors of afero telling that symbolic links
+// aren't supported by billy.ErrNotSupported.
+func fromAferoError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var lerr *os.LinkError
+	if errors.As(err, &lerr) && lerr.Err == afero.ErrNoSymlink {
+		return &os.LinkError{Op: lerr.Op, Old: lerr.Old, New: lerr.New, Err: billy.ErrNotSupported}
+	}
+	var perr *os.PathError
+	if errors.As(err, &perr) && perr.Err == afero.ErrNoReadlink {
+		return &os.PathError{Op: perr.Op, Path: perr.Path, Err: billy.ErrNotSupported}
+	}
+
+	return err
+}
@@ -0,0 +1,112 @@
+package aferofs
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return chroot.New(New(afero.NewOsFs()), t.TempDir())
+	})
+}
+
+func TestConformance_RoundTrip(t *testing.T) {
+	// The billy filesystem is seen through an afero.Fs first.
+	test.Run(t, func() billy.Filesystem {
+		return &FS{fs: &Afero{fs: memfs.New()}}
+	})
+}
+
+func TestAfero(t *testing.T) {
+	fs := NewAfero(memfs.New())
+
+	if err := fs.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Mkdir("dir", 0755); !os.IsExist(err) {
+		t.Errorf("got %v, want exist", err)
+	}
+	if err := fs.Mkdir("missing/dir", 0755); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+
+	if err := afero.WriteFile(fs, "dir/file", []byte("foo bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fs.OpenFile("dir/file", os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("baz"), 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("qux"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := afero.ReadFile(fs, "dir/file"); err != nil || string(got) != "qux baz" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if err := fs.(afero.Linker).SymlinkIfPossible("file", "dir/link"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := fs.(afero.LinkReader).ReadlinkIfPossible("dir/link"); err != nil || target != "file" {
+		t.Errorf("got %q, %v", target, err)
+	}
+
+	var walked []string
+	err = afero.Walk(fs, "/", func(path string, fi os.FileInfo, err error) error {
+		walked = append(walked, path)
+		return err
+	})
+	if err != nil || len(walked) != 4 {
+		t.Errorf("got %v, %v", walked, err)
+	}
+
+	if err := fs.RemoveAll("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("dir"); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	ro := New(afero.NewReadOnlyFs(afero.NewMemMapFs()))
+	if billy.CapabilityCheck(ro, billy.WriteCapability) {
+		t.Error("got WriteCapability for a read-only afero.Fs")
+	}
+	if _, ok := NewAfero(ro).(*afero.ReadOnlyFs); !ok {
+		t.Error("expected an afero.ReadOnlyFs")
+	}
+
+	mem := New(afero.NewMemMapFs())
+	if billy.CapabilityCheck(mem, billy.SymlinkCapability) {
+		t.Error("got SymlinkCapability for afero.MemMapFs")
+	}
+	if err := mem.Symlink("foo", "bar"); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("got %v, want not supported", err)
+	}
+
+	// The adapters unwrap each other.
+	bfs := memfs.New()
+	if New(NewAfero(bfs)) != bfs {
+		t.Error("expected the billy filesystem back")
+	}
+	if err := util.WriteFile(bfs, "foo", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
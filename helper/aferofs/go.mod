@@ -0,0 +1,18 @@
+module github.com/go-git/go-billy/v5/helper/aferofs
+
+go 1.19
+
+require (
+	github.com/go-git/go-billy/v5 v5.6.0
+	github.com/spf13/afero v1.9.5
+)
+
+require (
+	github.com/kr/pretty v0.2.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	golang.org/x/text v0.7.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+)
+
+replace github.com/go-git/go-billy/v5 => ../..
@@ -0,0 +1,218 @@
+// Package audit provides a billy filesystem wrapper emitting a structured
+// log record for every operation modifying the filesystem.
+package audit // import "github.com/go-git/go-billy/v5/helper/audit"
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-logr/logr"
+)
+
+// Message is the message of the log records.
+const Message = "filesystem operation"
+
+// FS is a billy.Filesystem which forwards every call to the wrapped
+// filesystem, logging the mutating ones. Each record has the keys "op",
+// "path" and "caller", and depending on the operation "newPath", "target",
+// "flags", "perm" and "bytes". Failed operations are logged as errors.
+//
+// Writes are not logged individually; the number of bytes written to a file
+// is logged when it is closed.
+type FS struct {
+	underlying billy.Filesystem
+	log        logr.Logger
+}
+
+// New creates a new filesystem wrapping up the given 'fs', logging to log.
+func New(fs billy.Filesystem, log logr.Logger) *FS {
+	return &FS{underlying: fs, log: log}
+}
+
+func (fs *FS) record(err error, op, path string, kv ...interface{}) {
+	kv = append([]interface{}{"op", op, "path", path}, kv...)
+	kv = append(kv, "caller", caller())
+
+	if err != nil {
+		fs.log.Error(err, Message, kv...)
+		return
+	}
+
+	fs.log.Info(Message, kv...)
+}
+
+const modulePrefix = "github.com/go-git/go-billy/v5/"
+
+// wrappers are the packages whose frames are skipped to find the caller.
+var wrappers = []string{
+	modulePrefix + "helper/audit.",
+	modulePrefix + "helper/chroot.",
+	modulePrefix + "helper/polyfill.",
+}
+
+// caller returns the location of the first function outside of this package
+// and of the wrappers it is usually composed with.
+func caller() string {
+	pc := make([]uintptr, 16)
+	n := runtime.Callers(3, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		frame, more := frames.Next()
+		if !isWrapper(frame) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isWrapper(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+
+	for _, prefix := range wrappers {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	f, err := fs.underlying.Create(filename)
+	fs.record(err, "create", filename)
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.underlying.Open(filename)
+}
+
+// OpenFile logs the call only if flag allows to modify the file.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := fs.underlying.OpenFile(filename, flag, perm)
+	if !isWrite(flag) {
+		return f, err
+	}
+
+	fs.record(err, "openfile", filename, "flags", flag, "perm", perm.String())
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	return fs.underlying.Stat(filename)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	err := fs.underlying.Rename(from, to)
+	fs.record(err, "rename", from, "newPath", to)
+	return err
+}
+
+func (fs *FS) Remove(filename string) error {
+	err := fs.underlying.Remove(filename)
+	fs.record(err, "remove", filename)
+	return err
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.underlying.TempFile(dir, prefix)
+	path := dir
+	if err == nil {
+		path = f.Name()
+	}
+
+	fs.record(err, "tempfile", path)
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	return fs.underlying.ReadDir(path)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	err := fs.underlying.MkdirAll(filename, perm)
+	fs.record(err, "mkdirall", filename, "perm", perm.String())
+	return err
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	return fs.underlying.Lstat(filename)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	err := fs.underlying.Symlink(target, link)
+	fs.record(err, "symlink", link, "target", target)
+	return err
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	return fs.underlying.Readlink(link)
+}
+
+// Chroot returns a chrooted view of fs; the operations are logged with the
+// paths as seen from the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface. Optional interfaces such
+// as billy.Change aren't forwarded, as they couldn't be audited.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) wrap(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+type file struct {
+	billy.File
+	fs      *FS
+	written int64
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.written += int64(n)
+	if err != nil {
+		f.fs.record(err, "write", f.Name(), "bytes", n)
+	}
+
+	return n, err
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.fs.record(err, "close", f.Name(), "bytes", f.written)
+	return err
+}
+
+func (f *file) Truncate(size int64) error {
+	err := f.File.Truncate(size)
+	f.fs.record(err, "truncate", f.Name(), "bytes", size)
+	return err
+}
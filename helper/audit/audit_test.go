@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), logr.Discard())
+	})
+}
+
+func TestRecords(t *testing.T) {
+	var records []string
+	log := funcr.New(func(prefix, args string) {
+		records = append(records, args)
+	}, funcr.Options{})
+
+	fs := New(memfs.New(), log)
+	if err := util.WriteFile(fs, "dir/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.ReadFile(fs, "dir/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dir/foo", "dir/bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("missing"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	expected := []string{
+		`"op"="openfile" "path"="dir/foo" "flags"=577 "perm"="-rw-r--r--"`,
+		`"op"="close" "path"="dir/foo" "bytes"=3`,
+		`"op"="rename" "path"="dir/foo" "newPath"="dir/bar"`,
+		`"op"="remove" "path"="missing"`,
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d:\n%s", len(expected), len(records), strings.Join(records, "\n"))
+	}
+
+	for i, r := range records {
+		if !strings.Contains(r, expected[i]) {
+			t.Errorf("record %d: expected %s in %s", i, expected[i], r)
+		}
+		if !strings.Contains(r, "audit_test.go") && !strings.Contains(r, "util/util.go") {
+			t.Errorf("record %d: unexpected caller in %s", i, r)
+		}
+	}
+
+	if !strings.Contains(records[3], `"error"=`) {
+		t.Errorf("expected the failure to be logged as an error: %s", records[3])
+	}
+}
@@ -0,0 +1,537 @@
+// Package cachefs provides a billy filesystem wrapper caching the content of
+// the files, and the results of Stat, Lstat and ReadDir, of a slow
+// filesystem, such as a remote one, into a fast one, such as memfs.
+package cachefs // import "github.com/go-git/go-billy/v5/helper/cachefs"
+
+import (
+	"container/list"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	objectsDir = "objects"
+	tmpDir     = "tmp"
+)
+
+// Options configures an FS.
+type Options struct {
+	// TTL is how long the cached entries are used before being checked
+	// against the source again. Zero means that they are used until they
+	// are invalidated or evicted.
+	TTL time.Duration
+	// MaxSize is the total size, in bytes, of the file contents kept in the
+	// cache, the least recently used ones being evicted past it. The files
+	// larger than MaxSize are read from the source. Zero means no limit.
+	MaxSize int64
+}
+
+// FS is a read-through cache of a source filesystem. The files opened for
+// reading are copied whole to the cache filesystem the first time, the
+// later reads being served from there; the attributes and directory
+// listings are kept in memory. Once their TTL expires, the cached contents
+// are checked against the size and modification time of the source files,
+// and fetched again if they differ.
+//
+// The writes are forwarded to the source, invalidating the entries they
+// affect. The changes made to the source by other means are only seen once
+// the TTL expires, or once the entries are invalidated with Invalidate.
+//
+// The cache filesystem must be dedicated to FS, which takes it over.
+type FS struct {
+	wrapper.Base
+	cache billy.Filesystem
+	opts  Options
+
+	m        sync.Mutex
+	stats    map[statKey]*statEntry
+	dirs     map[string]*dirEntry
+	contents map[string]*contentEntry
+	lru      *list.List
+	size     int64
+	// pending holds the paths being downloaded, downloaded being
+	// signaled once they are.
+	pending    map[string]bool
+	downloaded *sync.Cond
+}
+
+type statKey struct {
+	path   string
+	follow bool
+}
+
+type statEntry struct {
+	fi      os.FileInfo
+	err     error
+	expires time.Time
+}
+
+type dirEntry struct {
+	fis     []os.FileInfo
+	expires time.Time
+}
+
+type contentEntry struct {
+	path    string
+	object  string
+	size    int64
+	modTime time.Time
+	elem    *list.Element
+}
+
+// New returns a filesystem caching the files of source into cache.
+func New(source, cache billy.Filesystem, opts Options) *FS {
+	fs := &FS{
+		Base:     wrapper.NewBase(source),
+		cache:    cache,
+		opts:     opts,
+		stats:    make(map[statKey]*statEntry),
+		dirs:     make(map[string]*dirEntry),
+		contents: make(map[string]*contentEntry),
+		lru:      list.New(),
+		pending:  make(map[string]bool),
+	}
+	fs.downloaded = sync.NewCond(&fs.m)
+
+	return fs
+}
+
+// key returns the cache key of name, a clean slash separated absolute path.
+func key(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+func (fs *FS) expires() time.Time {
+	if fs.opts.TTL <= 0 {
+		return time.Time{}
+	}
+
+	return util.Now().Add(fs.opts.TTL)
+}
+
+func expired(t time.Time) bool {
+	return !t.IsZero() && !util.Now().Before(t)
+}
+
+// Invalidate drops the cached entries of the file or directory at name, and
+// of everything below it, as well as the listing of its parent directory.
+func (fs *FS) Invalidate(name string) {
+	k := key(name)
+	under := func(p string) bool {
+		return p == k || strings.HasPrefix(p, k+"/") || k == "/"
+	}
+
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	for sk := range fs.stats {
+		if under(sk.path) {
+			delete(fs.stats, sk)
+		}
+	}
+	for p := range fs.dirs {
+		if under(p) {
+			delete(fs.dirs, p)
+		}
+	}
+	delete(fs.dirs, path.Dir(k))
+	for p, e := range fs.contents {
+		if under(p) {
+			fs.evict(e)
+		}
+	}
+}
+
+// InvalidateAll drops every cached entry.
+func (fs *FS) InvalidateAll() {
+	fs.Invalidate("/")
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	return fs.stat(filename, true)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	return fs.stat(filename, false)
+}
+
+// stat returns the cached attributes of filename, fetching them from the
+// source if needed. The files which don't exist are cached as well.
+func (fs *FS) stat(filename string, follow bool) (os.FileInfo, error) {
+	sk := statKey{path: key(filename), follow: follow}
+
+	fs.m.Lock()
+	e, ok := fs.stats[sk]
+	fs.m.Unlock()
+	if ok && !expired(e.expires) {
+		if e.err != nil {
+			return nil, &os.PathError{Op: statOp(follow), Path: filename, Err: os.ErrNotExist}
+		}
+		return e.fi, nil
+	}
+
+	var fi os.FileInfo
+	var err error
+	if follow {
+		fi, err = fs.Base.Stat(filename)
+	} else {
+		fi, err = fs.Base.Lstat(filename)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	fs.m.Lock()
+	fs.stats[sk] = &statEntry{fi: fi, err: err, expires: fs.expires()}
+	fs.m.Unlock()
+
+	return fi, err
+}
+
+func statOp(follow bool) string {
+	if follow {
+		return "stat"
+	}
+
+	return "lstat"
+}
+
+// ReadDir returns the cached listing of the directory at path, fetching it
+// from the source if needed.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	k := key(path)
+
+	fs.m.Lock()
+	e, ok := fs.dirs[k]
+	fs.m.Unlock()
+	if !ok || expired(e.expires) {
+		fis, err := fs.Base.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		e = &dirEntry{fis: fis, expires: fs.expires()}
+		fs.m.Lock()
+		fs.dirs[k] = e
+		fs.m.Unlock()
+	}
+
+	return append([]os.FileInfo(nil), e.fis...), nil
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile opens the files for reading from the cache, copying them there
+// first if needed. The files opened for writing are opened from the source,
+// their entries being invalidated when they are opened and closed.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		fs.Invalidate(filename)
+		f, err := fs.Base.OpenFile(filename, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &writeFile{File: f, fs: fs, name: filename}, nil
+	}
+
+	fi, err := fs.Stat(filename)
+	if pe, ok := err.(*os.PathError); ok {
+		return nil, &os.PathError{Op: "open", Path: pe.Path, Err: pe.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() || fs.opts.MaxSize > 0 && fi.Size() > fs.opts.MaxSize {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	object, err := fs.fetch(filename, fi)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.cache.Open(object)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: filename}, nil
+}
+
+// fetch returns the object holding the content of filename, described by
+// fi, copying it from the source if it isn't cached or is stale.
+func (fs *FS) fetch(filename string, fi os.FileInfo) (string, error) {
+	fs.m.Lock()
+	object, ok := fs.cached(filename, fi)
+	fs.m.Unlock()
+	if ok {
+		return object, nil
+	}
+
+	return fs.download(filename, fi)
+}
+
+// cached returns the object holding the valid content of filename, waiting
+// for it to be downloaded if it is being. Otherwise, the download is left
+// to the caller, which must call download. fs.m must be held.
+func (fs *FS) cached(filename string, fi os.FileInfo) (string, bool) {
+	k := key(filename)
+	for fs.pending[k] {
+		fs.downloaded.Wait()
+	}
+
+	e, ok := fs.contents[k]
+	if ok && e.size == fi.Size() && e.modTime.Equal(fi.ModTime()) {
+		// The content is valid as long as the attributes match, these
+		// being fetched again once their TTL expires.
+		fs.lru.MoveToFront(e.elem)
+		return e.object, true
+	}
+	if ok {
+		fs.evict(e)
+	}
+
+	fs.pending[k] = true
+	return "", false
+}
+
+// download copies the content of filename to the cache, once cached
+// reported it missing.
+func (fs *FS) download(filename string, fi os.FileInfo) (string, error) {
+	k := key(filename)
+	object, err := fs.copy(filename)
+
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	delete(fs.pending, k)
+	fs.downloaded.Broadcast()
+	if err != nil {
+		return "", err
+	}
+
+	e := &contentEntry{
+		path:    k,
+		object:  object,
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+	}
+	e.elem = fs.lru.PushFront(e)
+	fs.contents[k] = e
+	fs.size += e.size
+
+	for fs.opts.MaxSize > 0 && fs.size > fs.opts.MaxSize && fs.lru.Len() > 1 {
+		fs.evict(fs.lru.Back().Value.(*contentEntry))
+	}
+
+	return object, nil
+}
+
+// Prefetch implements billy.Prefetcher, copying the file at path to the
+// cache in the background, unless it is there already. The range is
+// ignored, the files being cached whole; the hint is forwarded to the
+// source for the files which aren't cached.
+func (fs *FS) Prefetch(path string, off, length int64) error {
+	fi, err := fs.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() || fs.opts.MaxSize > 0 && fi.Size() > fs.opts.MaxSize {
+		return util.Prefetch(fs.Unwrap(), path, off, length)
+	}
+
+	fs.m.Lock()
+	_, ok := fs.cached(path, fi)
+	fs.m.Unlock()
+	if !ok {
+		go func() { _, _ = fs.download(path, fi) }()
+	}
+
+	return nil
+}
+
+// copy copies the content of filename to a new object of the cache.
+func (fs *FS) copy(filename string) (string, error) {
+	src, err := fs.Base.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := fs.cache.MkdirAll(tmpDir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := fs.cache.TempFile(tmpDir, "fetch-")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = fs.cache.Remove(tmp.Name())
+		return "", err
+	}
+
+	object := fs.cache.Join(objectsDir, filepath.Base(tmp.Name()))
+	if err := fs.cache.MkdirAll(objectsDir, 0o755); err != nil {
+		_ = fs.cache.Remove(tmp.Name())
+		return "", err
+	}
+	if err := fs.cache.Rename(tmp.Name(), object); err != nil {
+		_ = fs.cache.Remove(tmp.Name())
+		return "", err
+	}
+
+	return object, nil
+}
+
+// evict removes the content e from the cache. fs.m must be held.
+func (fs *FS) evict(e *contentEntry) {
+	if fs.contents[e.path] == e {
+		delete(fs.contents, e.path)
+	}
+	fs.lru.Remove(e.elem)
+	fs.size -= e.size
+	_ = fs.cache.Remove(e.object)
+}
+
+// Size returns the total size of the file contents held by the cache.
+func (fs *FS) Size() int64 {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	return fs.size
+}
+
+func (fs *FS) Rename(from, to string) error {
+	defer fs.Invalidate(to)
+	defer fs.Invalidate(from)
+	return fs.Base.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	defer fs.Invalidate(filename)
+	return fs.Base.Remove(filename)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	defer fs.Invalidate(filename)
+	return fs.Base.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	defer fs.Invalidate(link)
+	return fs.Base.Symlink(target, link)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.Base.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.Invalidate(f.Name())
+	return &writeFile{File: f, fs: fs, name: f.Name()}, nil
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := fs.Base.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.Invalidate(f.Name())
+	return &writeFile{File: f, fs: fs, name: f.Name()}, nil
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := fs.Base.MkdirTemp(dir, pattern)
+	if err == nil {
+		fs.Invalidate(name)
+	}
+
+	return name, err
+}
+
+func (fs *FS) Link(oldname, newname string) error {
+	defer fs.Invalidate(newname)
+	defer fs.Invalidate(oldname)
+	return fs.Base.Link(oldname, newname)
+}
+
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	defer fs.Invalidate(name)
+	return fs.Base.Chmod(name, mode)
+}
+
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	defer fs.Invalidate(name)
+	return fs.Base.Lchown(name, uid, gid)
+}
+
+func (fs *FS) Chown(name string, uid, gid int) error {
+	defer fs.Invalidate(name)
+	return fs.Base.Chown(name, uid, gid)
+}
+
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	defer fs.Invalidate(name)
+	return fs.Base.Chtimes(name, atime, mtime)
+}
+
+// Exchange implements the Exchange of util.SwapDirs, invalidating both
+// paths.
+func (fs *FS) Exchange(a, b string) error {
+	defer fs.Invalidate(b)
+	defer fs.Invalidate(a)
+	return fs.Base.Exchange(a, b)
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a file read from the cache, named after the path it was opened
+// from.
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+// writeFile is a file of the source opened for writing, invalidating its
+// entries when closed.
+type writeFile struct {
+	billy.File
+	fs   *FS
+	name string
+}
+
+func (f *writeFile) Close() error {
+	defer f.fs.Invalidate(f.name)
+	return f.File.Close()
+}
@@ -0,0 +1,297 @@
+package cachefs
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), memfs.New(), Options{})
+	})
+}
+
+func TestConformance_MaxSize(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), memfs.New(), Options{TTL: time.Minute, MaxSize: 16})
+	})
+}
+
+// counting counts the calls reaching the source.
+type counting struct {
+	wrapper.Base
+
+	m     sync.Mutex
+	calls map[string]int
+}
+
+func newCounting(fs billy.Filesystem) *counting {
+	return &counting{Base: wrapper.NewBase(fs), calls: make(map[string]int)}
+}
+
+func (fs *counting) count(op string) int {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+	return fs.calls[op]
+}
+
+func (fs *counting) inc(op string) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+	fs.calls[op]++
+}
+
+func (fs *counting) Open(filename string) (billy.File, error) {
+	fs.inc("open")
+	return fs.Base.Open(filename)
+}
+
+func (fs *counting) Stat(filename string) (os.FileInfo, error) {
+	fs.inc("stat")
+	return fs.Base.Stat(filename)
+}
+
+func (fs *counting) ReadDir(path string) ([]os.FileInfo, error) {
+	fs.inc("readdir")
+	return fs.Base.ReadDir(path)
+}
+
+func (fs *counting) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// clock is a util.Source whose time is moved by the tests.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+func (c *clock) Uint32() uint32 { c.now = c.now.Add(time.Nanosecond); return uint32(c.now.UnixNano()) }
+
+func TestReadThrough(t *testing.T) {
+	c := &clock{now: time.Unix(1700000000, 0)}
+	util.SetSource(c)
+	defer util.SetSource(nil)
+
+	src := newCounting(memfs.New())
+	if err := util.WriteFile(src, "dir/file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(src, memfs.New(), Options{TTL: time.Minute})
+	for i := 0; i < 3; i++ {
+		got, err := util.ReadFile(fs, "dir/file")
+		if err != nil || string(got) != "foo" {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		if _, err := fs.ReadDir("dir"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat("missing"); !os.IsNotExist(err) {
+			t.Fatalf("got %v, want not exist", err)
+		}
+	}
+	if n := src.count("open"); n != 1 {
+		t.Errorf("got %d opens, want 1", n)
+	}
+	if n := src.count("readdir"); n != 1 {
+		t.Errorf("got %d listings, want 1", n)
+	}
+	if n := src.count("stat"); n != 2 {
+		t.Errorf("got %d stats, want 2", n)
+	}
+
+	// Changed behind the back of the cache, the content is only seen once
+	// the TTL expires.
+	if err := util.WriteFile(src, "dir/file", []byte("quux"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := util.ReadFile(fs, "dir/file"); string(got) != "foo" {
+		t.Errorf("got %q, want the cached content", got)
+	}
+
+	c.now = c.now.Add(time.Minute)
+	if got, _ := util.ReadFile(fs, "dir/file"); string(got) != "quux" {
+		t.Errorf("got %q, want the new content", got)
+	}
+
+	// The unchanged content is revalidated, not fetched again.
+	opens := src.count("open")
+	c.now = c.now.Add(time.Minute)
+	if got, _ := util.ReadFile(fs, "dir/file"); string(got) != "quux" {
+		t.Errorf("got %q", got)
+	}
+	if n := src.count("open"); n != opens {
+		t.Errorf("got %d opens, want %d", n, opens)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	src := memfs.New()
+	if err := util.WriteFile(src, "dir/file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(src, memfs.New(), Options{})
+	if got, _ := util.ReadFile(fs, "dir/file"); string(got) != "foo" {
+		t.Fatalf("got %q", got)
+	}
+	if _, err := fs.ReadDir("dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.WriteFile(src, "dir/file", []byte("quux"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(src, "dir/new", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Invalidate("dir")
+	if got, _ := util.ReadFile(fs, "dir/file"); string(got) != "quux" {
+		t.Errorf("got %q, want the new content", got)
+	}
+	if fis, _ := fs.ReadDir("dir"); len(fis) != 2 {
+		t.Errorf("got %d entries, want 2", len(fis))
+	}
+
+	// The writes through the cache invalidate it.
+	if err := util.WriteFile(fs, "dir/file", []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := util.ReadFile(fs, "dir/file"); string(got) != "bar" {
+		t.Errorf("got %q, want the written content", got)
+	}
+	if err := fs.Remove("dir/new"); err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := fs.ReadDir("dir"); len(fis) != 1 {
+		t.Errorf("got %d entries, want 1", len(fis))
+	}
+
+	fs.InvalidateAll()
+	if fs.Size() != 0 {
+		t.Errorf("got %d bytes cached, want none", fs.Size())
+	}
+}
+
+func TestInvalidateTemp(t *testing.T) {
+	fs := New(memfs.New(), memfs.New(), Options{})
+	if err := fs.MkdirAll("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := fs.ReadDir("dir"); len(fis) != 0 {
+		t.Fatalf("got %d entries, want none", len(fis))
+	}
+
+	f, err := fs.CreateTemp("dir", "tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if fis, _ := fs.ReadDir("dir"); len(fis) != 1 {
+		t.Errorf("got %d entries, want the temporary file", len(fis))
+	}
+
+	name, err := fs.MkdirTemp("dir", "tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := fs.ReadDir("dir"); len(fis) != 2 {
+		t.Errorf("got %d entries, want the temporary directory too", len(fis))
+	}
+	if fi, err := fs.Stat(name); err != nil || !fi.IsDir() {
+		t.Errorf("got %v, %v, want the temporary directory", fi, err)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	src := newCounting(memfs.New())
+	for _, name := range []string{"a", "b", "c"} {
+		if err := util.WriteFile(src, name, []byte("0123456789"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := util.WriteFile(src, "large", make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := memfs.New()
+	fs := New(src, cache, Options{MaxSize: 25})
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		if _, err := util.ReadFile(fs, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fs.Size() != 20 {
+		t.Errorf("got %d bytes cached, want 20", fs.Size())
+	}
+
+	// b being the least recently used, it was evicted.
+	opens := src.count("open")
+	for _, name := range []string{"a", "c"} {
+		if _, err := util.ReadFile(fs, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := src.count("open"); n != opens {
+		t.Errorf("got %d opens, want %d", n, opens)
+	}
+	if _, err := util.ReadFile(fs, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if n := src.count("open"); n != opens+1 {
+		t.Errorf("got %d opens, want %d", n, opens+1)
+	}
+
+	if got, err := util.ReadFile(fs, "large"); err != nil || len(got) != 100 {
+		t.Errorf("got %d bytes, %v", len(got), err)
+	}
+	if fs.Size() > 25 {
+		t.Errorf("got %d bytes cached, want at most 25", fs.Size())
+	}
+
+	fis, err := cache.ReadDir(objectsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 2 {
+		t.Errorf("got %d objects, want 2", len(fis))
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	src := newCounting(memfs.New())
+	if err := util.WriteFile(src, "file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(src, memfs.New(), Options{})
+	if err := fs.Prefetch("file", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// The reads wait for the download pending instead of starting another.
+	if got, err := util.ReadFile(fs, "file"); err != nil || string(got) != "foo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if err := fs.Prefetch("file", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := src.count("open"); n != 1 {
+		t.Errorf("got %d opens, want 1", n)
+	}
+
+	if err := fs.Prefetch("missing", 0, 0); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+}
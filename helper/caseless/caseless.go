@@ -0,0 +1,249 @@
+// Package caseless provides a billy filesystem wrapper behaving like the
+// case-insensitive, case-preserving filesystems of macOS and Windows, over
+// any backend. It reports the names which would collide on such systems, so
+// that the contents of a repository can be validated on Linux.
+package caseless // import "github.com/go-git/go-billy/v5/helper/caseless"
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// FS is a billy.Filesystem looking up names regardless of their case. The
+// names of new files are kept as given, but creating a file, a link or
+// renaming to a name differing only by case from an existing entry fails
+// with EEXIST, as does looking up a name matching several entries.
+type FS struct {
+	underlying billy.Filesystem
+}
+
+// New creates a new filesystem wrapping up the given 'fs'.
+func New(fs billy.Filesystem) *FS {
+	return &FS{underlying: fs}
+}
+
+// resolve returns the name of the file matching filename on the underlying
+// filesystem. Each element is tried as is, then looked up in the listing of
+// its directory. Once an element doesn't exist, the rest of filename is
+// returned unchanged.
+func (fs *FS) resolve(filename string) (string, error) {
+	name := filepath.Clean(filename)
+	if name == "." || name == string(filepath.Separator) {
+		return name, nil
+	}
+
+	var cur string
+	if filepath.IsAbs(name) {
+		cur = string(filepath.Separator)
+	}
+
+	elems := strings.Split(strings.TrimPrefix(name, string(filepath.Separator)), string(filepath.Separator))
+	for i, elem := range elems {
+		next := fs.underlying.Join(cur, elem)
+		if elem == ".." {
+			cur = next
+			continue
+		}
+
+		if _, err := fs.underlying.Lstat(next); err == nil || !os.IsNotExist(err) {
+			cur = next
+			continue
+		}
+
+		found := fs.matches(cur, elem)
+		switch len(found) {
+		case 0:
+			return fs.underlying.Join(append([]string{cur}, elems[i:]...)...), nil
+		case 1:
+			cur = fs.underlying.Join(cur, found[0])
+		default:
+			return "", &os.PathError{Op: "lookup", Path: filename, Err: syscall.EEXIST}
+		}
+	}
+
+	return cur, nil
+}
+
+// matches returns the names of the entries of dir equal to elem under case
+// folding.
+func (fs *FS) matches(dir, elem string) []string {
+	infos, err := fs.underlying.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var found []string
+	for _, fi := range infos {
+		if strings.EqualFold(fi.Name(), elem) {
+			found = append(found, fi.Name())
+		}
+	}
+
+	return found
+}
+
+// create returns the underlying name to create filename at. It fails with
+// EEXIST if the directory holds an entry whose name only differs by case,
+// unless it is self, the underlying name of the file being renamed.
+func (fs *FS) create(op, filename, self string) (string, error) {
+	dir, base := filepath.Split(filepath.Clean(filename))
+	dir, err := fs.resolve(dir)
+	if err != nil {
+		return "", err
+	}
+	if dir == "." {
+		dir = ""
+	}
+
+	name := fs.underlying.Join(dir, base)
+	for _, m := range fs.matches(dir, base) {
+		if m != base && fs.underlying.Join(dir, m) != self {
+			return "", &os.PathError{Op: op, Path: filename, Err: syscall.EEXIST}
+		}
+	}
+
+	return name, nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens filename, looked up regardless of case. With os.O_CREATE,
+// it fails with EEXIST if filename only exists with another case.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	var name string
+	var err error
+	if flag&os.O_CREATE != 0 {
+		name, err = fs.create("open", filename, "")
+	} else {
+		name, err = fs.resolve(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.OpenFile(name, flag, perm)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.Stat(name)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.Lstat(name)
+}
+
+// Rename moves from, looked up regardless of case, to to. It fails with
+// EEXIST if to only exists with another case, unless that is from itself,
+// so that the case of a name can be changed.
+func (fs *FS) Rename(from, to string) error {
+	oldname, err := fs.resolve(from)
+	if err != nil {
+		return err
+	}
+
+	newname, err := fs.create("rename", to, oldname)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.Rename(oldname, newname)
+}
+
+func (fs *FS) Remove(filename string) error {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.Remove(name)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	name, err := fs.resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.TempFile(name, prefix)
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	name, err := fs.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.ReadDir(name)
+}
+
+// MkdirAll creates the missing directories of filename, reusing the existing
+// ones regardless of their case.
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.MkdirAll(name, perm)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	name, err := fs.create("symlink", link, "")
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.Symlink(target, name)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	name, err := fs.resolve(link)
+	if err != nil {
+		return "", err
+	}
+
+	return fs.underlying.Readlink(name)
+}
+
+// Chroot returns a chrooted view of fs, whose path is looked up regardless
+// of case too.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, adding
+// billy.CaseInsensitiveCapability. The capabilities of the optional
+// interfaces are removed, as they aren't forwarded.
+func (fs *FS) Capabilities() billy.Capability {
+	caps := billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+	return caps | billy.CaseInsensitiveCapability
+}
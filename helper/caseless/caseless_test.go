@@ -0,0 +1,114 @@
+package caseless
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New())
+	})
+}
+
+func TestLookup(t *testing.T) {
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "Docs/README.md", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(mem)
+	for _, name := range []string{"Docs/README.md", "docs/readme.md", "DOCS/ReadMe.MD"} {
+		b, err := util.ReadFile(fs, name)
+		if err != nil || string(b) != "foo" {
+			t.Errorf("ReadFile(%q): got %q, %v", name, b, err)
+		}
+	}
+
+	fi, err := fs.Stat("docs/readme.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "README.md" {
+		t.Errorf("expected the stored name, got %q", fi.Name())
+	}
+
+	// Existing directories are reused, new names keep their case.
+	if err := util.WriteFile(fs, "docs/Guide.md", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Stat("Docs/Guide.md"); err != nil {
+		t.Error(err)
+	}
+	if err := fs.MkdirAll("DOCS/images", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Stat("Docs/images"); err != nil {
+		t.Error(err)
+	}
+
+	if err := fs.Remove("docs/guide.MD"); err != nil {
+		t.Fatal(err)
+	}
+
+	if billy.Capabilities(fs)&billy.CaseInsensitiveCapability == 0 {
+		t.Error("missing CaseInsensitiveCapability")
+	}
+}
+
+func TestCollision(t *testing.T) {
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "dir/Makefile", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(mem, "dir/other", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(mem)
+
+	_, err := fs.Create("dir/makefile")
+	assertExist(t, err)
+	_, err = fs.OpenFile("DIR/MAKEFILE", os.O_WRONLY|os.O_CREATE, 0o644)
+	assertExist(t, err)
+	assertExist(t, fs.Symlink("other", "dir/MakeFile"))
+	assertExist(t, fs.Rename("dir/other", "dir/makefile"))
+
+	// The exact name can still be written, and a file renamed to its own
+	// name in another case.
+	if err := util.WriteFile(fs, "dir/Makefile", []byte("all:"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dir/makefile", "dir/MAKEFILE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Stat("dir/MAKEFILE"); err != nil {
+		t.Error(err)
+	}
+
+	// Names colliding on the underlying filesystem are ambiguous.
+	if err := util.WriteFile(mem, "dir/Other", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = fs.Stat("dir/OTHER")
+	assertExist(t, err)
+}
+
+func assertExist(t *testing.T, err error) {
+	t.Helper()
+
+	if !os.IsExist(err) || !isErrno(err, syscall.EEXIST) {
+		t.Errorf("expected EEXIST, got %v", err)
+	}
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	pe, ok := err.(*os.PathError)
+	return ok && pe.Err == errno
+}
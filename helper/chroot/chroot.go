@@ -1,28 +1,62 @@
 package chroot
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/helper/polyfill"
+	"github.com/go-git/go-billy/v5/util"
 )
 
 // ChrootHelper is a helper to implement billy.Chroot.
 type ChrootHelper struct {
 	underlying billy.Filesystem
 	base       string
+	// vfs resolves the symbolic links of the paths, when not nil.
+	vfs util.VFS
+}
+
+// Options configures a ChrootHelper created with NewWithOptions.
+type Options struct {
+	// ResolveSymlinks makes the helper resolve the symbolic links of every
+	// path against the underlying filesystem, with util.SecureJoinVFS, so
+	// that they can't lead outside of the base directory. By default the
+	// paths are only scoped lexically, and a link pointing at /etc gives
+	// access to the /etc of the underlying filesystem.
+	//
+	// The links are resolved on each call, so a link swapped in between two
+	// calls is honoured, but a swap happening while a call is in progress
+	// can still escape; it is only fully contained by an underlying
+	// filesystem resolving paths itself, like osfs2.
+	ResolveSymlinks bool
 }
 
 // New creates a new filesystem wrapping up the given 'fs'.
 // The created filesystem has its base in the given ChrootHelperectory of the
 // underlying filesystem.
 func New(fs billy.Basic, base string) billy.Filesystem {
-	return &ChrootHelper{
+	return NewWithOptions(fs, base, Options{})
+}
+
+// NewWithOptions is like New, using the given options. ResolveSymlinks is
+// ignored if fs doesn't implement billy.Symlink.
+func NewWithOptions(fs billy.Basic, base string, opts Options) billy.Filesystem {
+	h := &ChrootHelper{
 		underlying: polyfill.New(fs),
 		base:       base,
 	}
+
+	if _, ok := fs.(billy.Symlink); ok && opts.ResolveSymlinks {
+		h.vfs = h.underlying
+	}
+
+	return h
 }
 
 func (fs *ChrootHelper) underlyingPath(filename string) (string, error) {
@@ -30,9 +64,66 @@ func (fs *ChrootHelper) underlyingPath(filename string) (string, error) {
 		return "", billy.ErrCrossedBoundary
 	}
 
+	if fs.vfs != nil {
+		return util.SecureJoinVFS(fs.base, filepath.FromSlash(filename), fs.vfs)
+	}
+
 	return fs.Join(fs.Root(), filename), nil
 }
 
+// underlyingPathNoFollow is like underlyingPath, except that the last element
+// of filename isn't resolved when it is a symbolic link, for the operations
+// acting on the link itself.
+func (fs *ChrootHelper) underlyingPathNoFollow(filename string) (string, error) {
+	if fs.vfs == nil || isCrossBoundaries(filename) {
+		return fs.underlyingPath(filename)
+	}
+
+	dir, name := filepath.Split(filepath.Clean(string(filepath.Separator) + filepath.FromSlash(filename)))
+	parent, err := fs.underlyingPath(dir)
+	if err != nil || name == "" {
+		return parent, err
+	}
+
+	return fs.Join(parent, name), nil
+}
+
+// restore rewrites the paths of err, an *os.PathError or *os.LinkError
+// returned by the underlying filesystem, to the ones seen by the caller. The
+// names are pairs of underlying and caller paths, the first ones being
+// replaced by the second ones; the other paths below the base are made
+// relative to it.
+func (fs *ChrootHelper) restore(err error, names ...string) error {
+	switch e := err.(type) {
+	case *os.PathError:
+		return &os.PathError{Op: e.Op, Path: fs.visiblePath(e.Path, names), Err: e.Err}
+	case *os.LinkError:
+		return &os.LinkError{
+			Op:  e.Op,
+			Old: fs.visiblePath(e.Old, names),
+			New: fs.visiblePath(e.New, names),
+			Err: e.Err,
+		}
+	}
+
+	return err
+}
+
+func (fs *ChrootHelper) visiblePath(path string, names []string) string {
+	for i := 0; i+1 < len(names); i += 2 {
+		if path == names[i] {
+			return names[i+1]
+		}
+	}
+
+	rel, err := filepath.Rel(fs.base, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return rel
+}
+
 func isCrossBoundaries(path string) bool {
 	path = filepath.ToSlash(path)
 	path = filepath.Clean(path)
@@ -48,7 +139,7 @@ func (fs *ChrootHelper) Create(filename string) (billy.File, error) {
 
 	f, err := fs.underlying.Create(fullpath)
 	if err != nil {
-		return nil, err
+		return nil, fs.restore(err, fullpath, filename)
 	}
 
 	return newFile(fs, f, filename), nil
@@ -62,7 +153,7 @@ func (fs *ChrootHelper) Open(filename string) (billy.File, error) {
 
 	f, err := fs.underlying.Open(fullpath)
 	if err != nil {
-		return nil, err
+		return nil, fs.restore(err, fullpath, filename)
 	}
 
 	return newFile(fs, f, filename), nil
@@ -76,7 +167,7 @@ func (fs *ChrootHelper) OpenFile(filename string, flag int, mode os.FileMode) (b
 
 	f, err := fs.underlying.OpenFile(fullpath, flag, mode)
 	if err != nil {
-		return nil, err
+		return nil, fs.restore(err, fullpath, filename)
 	}
 
 	return newFile(fs, f, filename), nil
@@ -88,31 +179,32 @@ func (fs *ChrootHelper) Stat(filename string) (os.FileInfo, error) {
 		return nil, err
 	}
 
-	return fs.underlying.Stat(fullpath)
+	fi, err := fs.underlying.Stat(fullpath)
+	return fi, fs.restore(err, fullpath, filename)
 }
 
 func (fs *ChrootHelper) Rename(from, to string) error {
-	var err error
-	from, err = fs.underlyingPath(from)
+	fullfrom, err := fs.underlyingPathNoFollow(from)
 	if err != nil {
 		return err
 	}
 
-	to, err = fs.underlyingPath(to)
+	fullto, err := fs.underlyingPathNoFollow(to)
 	if err != nil {
 		return err
 	}
 
-	return fs.underlying.Rename(from, to)
+	err = fs.underlying.Rename(fullfrom, fullto)
+	return fs.restore(err, fullfrom, from, fullto, to)
 }
 
 func (fs *ChrootHelper) Remove(path string) error {
-	fullpath, err := fs.underlyingPath(path)
+	fullpath, err := fs.underlyingPathNoFollow(path)
 	if err != nil {
 		return err
 	}
 
-	return fs.underlying.Remove(fullpath)
+	return fs.restore(fs.underlying.Remove(fullpath), fullpath, path)
 }
 
 func (fs *ChrootHelper) Join(elem ...string) string {
@@ -126,11 +218,68 @@ func (fs *ChrootHelper) TempFile(dir, prefix string) (billy.File, error) {
 	}
 
 	f, err := fs.underlying.(billy.TempFile).TempFile(fullpath, prefix)
+	if err != nil {
+		return nil, fs.restore(err, fullpath, dir)
+	}
+
+	return newFile(fs, f, fs.tempName(dir, f.Name())), nil
+}
+
+// tempName returns the name of the temporary entry created by the
+// underlying filesystem at path, in dir. The underlying filesystem may put
+// it elsewhere when dir is empty, into its directory for temporary files.
+func (fs *ChrootHelper) tempName(dir, path string) string {
+	if dir == "" {
+		if rel := fs.visiblePath(path, nil); !filepath.IsAbs(rel) {
+			return rel
+		}
+	}
+
+	return fs.Join(dir, filepath.Base(path))
+}
+
+// CreateTemp implements billy.TempCreator, forwarding the call to the
+// underlying filesystem. billy.ErrNotSupported is returned if it doesn't
+// implement billy.TempCreator.
+func (fs *ChrootHelper) CreateTemp(dir, pattern string) (billy.File, error) {
+	tc, ok := fs.underlying.(billy.TempCreator)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := fs.underlyingPath(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	return newFile(fs, f, fs.Join(dir, filepath.Base(f.Name()))), nil
+	f, err := tc.CreateTemp(fullpath, pattern)
+	if err != nil {
+		return nil, fs.restore(err, fullpath, dir)
+	}
+
+	return newFile(fs, f, fs.tempName(dir, f.Name())), nil
+}
+
+// MkdirTemp implements billy.TempCreator, forwarding the call to the
+// underlying filesystem. billy.ErrNotSupported is returned if it doesn't
+// implement billy.TempCreator.
+func (fs *ChrootHelper) MkdirTemp(dir, pattern string) (string, error) {
+	tc, ok := fs.underlying.(billy.TempCreator)
+	if !ok {
+		return "", billy.ErrNotSupported
+	}
+
+	fullpath, err := fs.underlyingPath(dir)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := tc.MkdirTemp(fullpath, pattern)
+	if err != nil {
+		return "", fs.restore(err, fullpath, dir)
+	}
+
+	return fs.tempName(dir, path), nil
 }
 
 func (fs *ChrootHelper) ReadDir(path string) ([]os.FileInfo, error) {
@@ -139,7 +288,8 @@ func (fs *ChrootHelper) ReadDir(path string) ([]os.FileInfo, error) {
 		return nil, err
 	}
 
-	return fs.underlying.(billy.Dir).ReadDir(fullpath)
+	fis, err := fs.underlying.(billy.Dir).ReadDir(fullpath)
+	return fis, fs.restore(err, fullpath, path)
 }
 
 func (fs *ChrootHelper) MkdirAll(filename string, perm os.FileMode) error {
@@ -148,16 +298,17 @@ func (fs *ChrootHelper) MkdirAll(filename string, perm os.FileMode) error {
 		return err
 	}
 
-	return fs.underlying.(billy.Dir).MkdirAll(fullpath, perm)
+	return fs.restore(fs.underlying.(billy.Dir).MkdirAll(fullpath, perm), fullpath, filename)
 }
 
 func (fs *ChrootHelper) Lstat(filename string) (os.FileInfo, error) {
-	fullpath, err := fs.underlyingPath(filename)
+	fullpath, err := fs.underlyingPathNoFollow(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	return fs.underlying.(billy.Symlink).Lstat(fullpath)
+	fi, err := fs.underlying.(billy.Symlink).Lstat(fullpath)
+	return fi, fs.restore(err, fullpath, filename)
 }
 
 func (fs *ChrootHelper) Symlink(target, link string) error {
@@ -169,23 +320,24 @@ func (fs *ChrootHelper) Symlink(target, link string) error {
 		target = filepath.Clean(filepath.FromSlash(target))
 	}
 
-	link, err := fs.underlyingPath(link)
+	fullpath, err := fs.underlyingPathNoFollow(link)
 	if err != nil {
 		return err
 	}
 
-	return fs.underlying.(billy.Symlink).Symlink(target, link)
+	err = fs.underlying.(billy.Symlink).Symlink(target, fullpath)
+	return fs.restore(err, fullpath, link)
 }
 
 func (fs *ChrootHelper) Readlink(link string) (string, error) {
-	fullpath, err := fs.underlyingPath(link)
+	fullpath, err := fs.underlyingPathNoFollow(link)
 	if err != nil {
 		return "", err
 	}
 
 	target, err := fs.underlying.(billy.Symlink).Readlink(fullpath)
 	if err != nil {
-		return "", err
+		return "", fs.restore(err, fullpath, link)
 	}
 
 	if !filepath.IsAbs(target) && !strings.HasPrefix(target, string(filepath.Separator)) {
@@ -206,13 +358,329 @@ func (fs *ChrootHelper) Chroot(path string) (billy.Filesystem, error) {
 		return nil, err
 	}
 
-	return New(fs.underlying, fullpath), nil
+	return &ChrootHelper{
+		underlying: fs.underlying,
+		base:       fullpath,
+		vfs:        fs.vfs,
+	}, nil
+}
+
+// Scoped implements billy.Scoper. Unlike Chroot, the view shares the
+// underlying filesystem of fs instead of wrapping it again, so scoping
+// repeatedly doesn't stack helpers.
+func (fs *ChrootHelper) Scoped(dir string) (billy.Filesystem, error) {
+	base := fs.underlying.Join(fs.base, filepath.FromSlash(dir))
+	if fs.vfs != nil {
+		var err error
+		if base, err = fs.underlyingPath(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ChrootHelper{
+		underlying: fs.underlying,
+		base:       base,
+		vfs:        fs.vfs,
+	}, nil
 }
 
 func (fs *ChrootHelper) Root() string {
 	return fs.base
 }
 
+// Link implements billy.Linker, forwarding the call to the underlying
+// filesystem. billy.ErrNotSupported is returned if it doesn't implement
+// billy.Linker.
+func (fs *ChrootHelper) Link(oldname, newname string) error {
+	l, ok := fs.underlying.(billy.Linker)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	oldpath, err := fs.underlyingPathNoFollow(oldname)
+	if err != nil {
+		return err
+	}
+
+	newpath, err := fs.underlyingPathNoFollow(newname)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(l.Link(oldpath, newpath), oldpath, oldname, newpath, newname)
+}
+
+// StatFS implements billy.StatFS, forwarding the call to the underlying
+// filesystem. billy.ErrNotSupported is returned if it doesn't implement
+// billy.StatFS.
+func (fs *ChrootHelper) StatFS(path string) (*billy.FSInfo, error) {
+	s, ok := fs.underlying.(billy.StatFS)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := fs.underlyingPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.StatFS(fullpath)
+	return info, fs.restore(err, fullpath, path)
+}
+
+// Close implements billy.Closer, closing the underlying filesystem, which is
+// shared with the other chroots of it.
+func (fs *ChrootHelper) Close() error {
+	return billy.Close(fs.underlying)
+}
+
+// SyncDir implements billy.DirSyncer, forwarding the call to the underlying
+// filesystem. billy.ErrNotSupported is returned if it doesn't implement
+// billy.DirSyncer.
+func (fs *ChrootHelper) SyncDir(path string) error {
+	s, ok := fs.underlying.(billy.DirSyncer)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := fs.underlyingPath(path)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(s.SyncDir(fullpath), fullpath, path)
+}
+
+// OpenDir implements billy.DirIterator, forwarding the call to the
+// underlying filesystem. billy.ErrNotSupported is returned if it doesn't
+// implement billy.DirIterator.
+func (fs *ChrootHelper) OpenDir(path string) (billy.DirIter, error) {
+	d, ok := fs.underlying.(billy.DirIterator)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := fs.underlyingPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := d.OpenDir(fullpath)
+	return it, fs.restore(err, fullpath, path)
+}
+
+// Prefetch implements billy.Prefetcher, forwarding the call to the
+// underlying filesystem. billy.ErrNotSupported is returned if it doesn't
+// implement billy.Prefetcher.
+func (fs *ChrootHelper) Prefetch(path string, off, length int64) error {
+	p, ok := fs.underlying.(billy.Prefetcher)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := fs.underlyingPath(path)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(p.Prefetch(fullpath, off, length), fullpath, path)
+}
+
+// Watch implements billy.Watcher, forwarding the call to the underlying
+// filesystem, the names of the events being made relative to the root. If
+// it doesn't implement billy.Watcher, the channel returned is closed after a
+// single event holding billy.ErrNotSupported.
+func (fs *ChrootHelper) Watch(path string) (<-chan billy.Event, func()) {
+	w, ok := fs.underlying.(billy.Watcher)
+	if !ok {
+		return watchError(billy.ErrNotSupported)
+	}
+
+	fullpath, err := fs.underlyingPath(path)
+	if err != nil {
+		return watchError(err)
+	}
+
+	in, stop := w.Watch(fullpath)
+	out := make(chan billy.Event)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+		for e := range in {
+			if e.Name != "" {
+				e.Name, _ = filepath.Rel(fs.base, e.Name)
+			}
+
+			select {
+			case out <- e:
+			case <-done:
+				// Drain in until stop closes it.
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			stop()
+		})
+	}
+}
+
+func watchError(err error) (<-chan billy.Event, func()) {
+	ch := make(chan billy.Event, 1)
+	ch <- billy.Event{Err: err}
+	close(ch)
+
+	return ch, func() {}
+}
+
+func (fs *ChrootHelper) change() (billy.Change, error) {
+	c, ok := fs.underlying.(billy.Change)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return c, nil
+}
+
+// Chmod implements billy.Change, forwarding the call to the underlying
+// filesystem. billy.ErrNotSupported is returned if it doesn't implement
+// billy.Change.
+func (fs *ChrootHelper) Chmod(name string, mode os.FileMode) error {
+	c, err := fs.change()
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(c.Chmod(fullpath, mode), fullpath, name)
+}
+
+// Lchown implements billy.Change, see Chmod.
+func (fs *ChrootHelper) Lchown(name string, uid, gid int) error {
+	c, err := fs.change()
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fs.underlyingPathNoFollow(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(c.Lchown(fullpath, uid, gid), fullpath, name)
+}
+
+// Chown implements billy.Change, see Chmod.
+func (fs *ChrootHelper) Chown(name string, uid, gid int) error {
+	c, err := fs.change()
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(c.Chown(fullpath, uid, gid), fullpath, name)
+}
+
+// Chtimes implements billy.Change, see Chmod.
+func (fs *ChrootHelper) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	c, err := fs.change()
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(c.Chtimes(fullpath, atime, mtime), fullpath, name)
+}
+
+func (fs *ChrootHelper) xattrer() (billy.Xattrer, error) {
+	x, ok := fs.underlying.(billy.Xattrer)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return x, nil
+}
+
+// Getxattr implements billy.Xattrer, forwarding the call to the underlying
+// filesystem. billy.ErrNotSupported is returned if it doesn't implement
+// billy.Xattrer.
+func (fs *ChrootHelper) Getxattr(name, attr string) ([]byte, error) {
+	x, err := fs.xattrer()
+	if err != nil {
+		return nil, err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := x.Getxattr(fullpath, attr)
+	return value, fs.restore(err, fullpath, name)
+}
+
+// Setxattr implements billy.Xattrer, see Getxattr.
+func (fs *ChrootHelper) Setxattr(name, attr string, value []byte) error {
+	x, err := fs.xattrer()
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(x.Setxattr(fullpath, attr, value), fullpath, name)
+}
+
+// Listxattr implements billy.Xattrer, see Getxattr.
+func (fs *ChrootHelper) Listxattr(name string) ([]string, error) {
+	x, err := fs.xattrer()
+	if err != nil {
+		return nil, err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := x.Listxattr(fullpath)
+	return attrs, fs.restore(err, fullpath, name)
+}
+
+// Removexattr implements billy.Xattrer, see Getxattr.
+func (fs *ChrootHelper) Removexattr(name, attr string) error {
+	x, err := fs.xattrer()
+	if err != nil {
+		return err
+	}
+
+	fullpath, err := fs.underlyingPath(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.restore(x.Removexattr(fullpath, attr), fullpath, name)
+}
+
 func (fs *ChrootHelper) Underlying() billy.Basic {
 	return fs.underlying
 }
@@ -224,15 +692,17 @@ func (fs *ChrootHelper) Capabilities() billy.Capability {
 
 type file struct {
 	billy.File
+	fs   *ChrootHelper
 	name string
 }
 
-func newFile(fs billy.Filesystem, f billy.File, filename string) billy.File {
+func newFile(fs *ChrootHelper, f billy.File, filename string) billy.File {
 	filename = fs.Join(fs.Root(), filename)
 	filename, _ = filepath.Rel(fs.Root(), filename)
 
 	return &file{
 		File: f,
+		fs:   fs,
 		name: filename,
 	}
 }
@@ -240,3 +710,195 @@ func newFile(fs billy.Filesystem, f billy.File, filename string) billy.File {
 func (f *file) Name() string {
 	return f.name
 }
+
+// restore rewrites the path of err, returned by the underlying file, to the
+// name of f.
+func (f *file) restore(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return f.fs.restore(err, f.File.Name(), f.name)
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	return n, f.restore(err)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	return n, f.restore(err)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	return n, f.restore(err)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.File.Seek(offset, whence)
+	return n, f.restore(err)
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.restore(f.File.Truncate(size))
+}
+
+func (f *file) Close() error {
+	return f.restore(f.File.Close())
+}
+
+func (f *file) Lock() error {
+	return f.restore(f.File.Lock())
+}
+
+func (f *file) Unlock() error {
+	return f.restore(f.File.Unlock())
+}
+
+// Stat implements billy.FileStater, forwarding the call to the underlying
+// file. billy.ErrNotSupported is returned if the underlying file can't be
+// stated.
+func (f *file) Stat() (os.FileInfo, error) {
+	s, ok := f.File.(billy.FileStater)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	fi, err := s.Stat()
+	return fi, f.restore(err)
+}
+
+// ReadFrom implements io.ReaderFrom, forwarding the call to the underlying
+// file when it implements it, so that io.Copy keeps its fast paths.
+func (f *file) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := f.File.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+
+	return io.Copy(struct{ io.Writer }{f.File}, r)
+}
+
+// WriteTo implements io.WriterTo, forwarding the call to the underlying file
+// when it implements it.
+func (f *file) WriteTo(w io.Writer) (int64, error) {
+	if wt, ok := f.File.(io.WriterTo); ok {
+		return wt.WriteTo(w)
+	}
+
+	return io.Copy(w, struct{ io.Reader }{f.File})
+}
+
+// Mmap implements billy.Mmapper, forwarding the call to the underlying file.
+// billy.ErrNotSupported is returned if the underlying file can't be mapped.
+func (f *file) Mmap() ([]byte, func() error, error) {
+	m, ok := f.File.(billy.Mmapper)
+	if !ok {
+		return nil, nil, billy.ErrNotSupported
+	}
+
+	return m.Mmap()
+}
+
+// Fd implements billy.Descriptor, returning the descriptor of the underlying
+// file, or ^uintptr(0) if it has none.
+func (f *file) Fd() uintptr {
+	d, ok := f.File.(billy.Descriptor)
+	if !ok {
+		return ^uintptr(0)
+	}
+
+	return d.Fd()
+}
+
+// SyscallConn implements billy.Descriptor, forwarding the call to the
+// underlying file. billy.ErrNotSupported is returned if it has no
+// descriptor.
+func (f *file) SyscallConn() (syscall.RawConn, error) {
+	d, ok := f.File.(billy.Descriptor)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return d.SyscallConn()
+}
+
+// RLock implements billy.RWLocker, forwarding the call to the underlying file,
+// or returning billy.ErrNotSupported if it has no shared locks.
+func (f *file) RLock() error {
+	l, ok := f.File.(billy.RWLocker)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return f.restore(l.RLock())
+}
+
+// TryLock implements billy.RWLocker, forwarding the call to the underlying
+// file.
+func (f *file) TryLock() (bool, error) {
+	l, ok := f.File.(billy.RWLocker)
+	if !ok {
+		return false, billy.ErrNotSupported
+	}
+
+	ok, err := l.TryLock()
+	return ok, f.restore(err)
+}
+
+// TryRLock implements billy.RWLocker, forwarding the call to the underlying
+// file.
+func (f *file) TryRLock() (bool, error) {
+	l, ok := f.File.(billy.RWLocker)
+	if !ok {
+		return false, billy.ErrNotSupported
+	}
+
+	ok, err := l.TryRLock()
+	return ok, f.restore(err)
+}
+
+// Allocate implements billy.Allocator, forwarding the call to the underlying
+// file, or returning billy.ErrNotSupported if it can't reserve storage.
+func (f *file) Allocate(off, length int64) error {
+	a, ok := f.File.(billy.Allocator)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return f.restore(a.Allocate(off, length))
+}
+
+// Publish implements billy.Publisher, forwarding the call to the underlying
+// file with name resolved within the chroot. billy.ErrNotSupported is
+// returned if the underlying file can't be published.
+func (f *file) Publish(name string) error {
+	p, ok := f.File.(billy.Publisher)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := f.fs.underlyingPathNoFollow(name)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Publish(fullpath); err != nil {
+		return f.fs.restore(err, fullpath, name)
+	}
+
+	f.name = filepath.Clean(name)
+	return nil
+}
+
+// Sync implements billy.Syncer, forwarding the call to the underlying file.
+// billy.ErrNotSupported is returned if the underlying file can't be synced.
+func (f *file) Sync() error {
+	s, ok := f.File.(billy.Syncer)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return f.restore(s.Sync())
+}
@@ -1,9 +1,12 @@
 package chroot
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/test"
@@ -29,6 +32,116 @@ func (s *ChrootSuite) TestCreate(c *C) {
 	c.Assert(m.CreateArgs[0], Equals, "/foo/bar/qux")
 }
 
+func (s *ChrootSuite) TestFileStatNotSupported(c *C) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	_, err = f.(billy.FileStater).Stat()
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+
+func (s *ChrootSuite) TestReadFromWriteTo(c *C) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	n, err := f.(io.ReaderFrom).ReadFrom(strings.NewReader("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(3))
+
+	var buf strings.Builder
+	n, err = f.(io.WriterTo).WriteTo(&buf)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(3))
+	c.Assert(buf.String(), Equals, "foo")
+}
+
+func (s *ChrootSuite) TestMmapNotSupported(c *C) {
+	m := &test.BasicMock{}
+
+	fs := New(m, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	_, _, err = f.(billy.Mmapper).Mmap()
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+
+func (s *ChrootSuite) TestDescriptorNotSupported(c *C) {
+	fs := New(&test.BasicMock{}, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	d := f.(billy.Descriptor)
+	c.Assert(d.Fd(), Equals, ^uintptr(0))
+	_, err = d.SyscallConn()
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+func (s *ChrootSuite) TestAllocateNotSupported(c *C) {
+	fs := New(&test.BasicMock{}, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(f.(billy.Allocator).Allocate(0, 10), Equals, billy.ErrNotSupported)
+}
+func (s *ChrootSuite) TestPublishNotSupported(c *C) {
+	fs := New(&test.BasicMock{}, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(f.(billy.Publisher).Publish("baz"), Equals, billy.ErrNotSupported)
+}
+func (s *ChrootSuite) TestStatFSNotSupported(c *C) {
+	fs := New(&test.BasicMock{}, "/foo")
+
+	_, err := fs.(billy.StatFS).StatFS("bar")
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+func (s *ChrootSuite) TestRWLockNotSupported(c *C) {
+	fs := New(&test.BasicMock{}, "/foo")
+	f, err := fs.Create("bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(f.(billy.RWLocker).RLock(), Equals, billy.ErrNotSupported)
+	_, err = f.(billy.RWLocker).TryLock()
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+
+
+type closerMock struct {
+	test.BasicMock
+	closed bool
+}
+
+func (m *closerMock) Close() error {
+	m.closed = true
+	return nil
+}
+
+func (s *ChrootSuite) TestClose(c *C) {
+	m := &closerMock{}
+	c.Assert(billy.Close(New(m, "/foo")), IsNil)
+	c.Assert(m.closed, Equals, true)
+
+	c.Assert(billy.Close(New(&test.BasicMock{}, "/foo")), IsNil)
+}
+
+
+
+
+func (s *ChrootSuite) TestChangeNotSupported(c *C) {
+	fs := New(&test.BasicMock{}, "/foo")
+
+	ch := fs.(billy.Change)
+	c.Assert(ch.Chmod("bar", 0644), Equals, billy.ErrNotSupported)
+	c.Assert(ch.Chtimes("bar", time.Time{}, time.Time{}), Equals, billy.ErrNotSupported)
+}
+
 func (s *ChrootSuite) TestCreateErrCrossedBoundary(c *C) {
 	m := &test.BasicMock{}
 
@@ -366,3 +479,19 @@ func testCapabilities(c *C, basic billy.Basic) {
 
 	c.Assert(capabilities, Equals, baseCapabilities)
 }
+
+func (s *ChrootSuite) TestScoped(c *C) {
+	m := &test.BasicMock{}
+
+	root := New(m, "/foo")
+	fs, err := root.(billy.Scoper).Scoped("baz/qux")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Root(), Equals, filepath.Join("/foo", "baz", "qux"))
+	c.Assert(fs.(*ChrootHelper).underlying, Equals, root.(*ChrootHelper).underlying)
+
+	_, err = fs.Open("bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(m.OpenArgs, HasLen, 1)
+	c.Assert(m.OpenArgs[0], Equals, "/foo/baz/qux/bar")
+}
@@ -0,0 +1,132 @@
+package chroot_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func newJail(t *testing.T, resolve bool) (billy.Filesystem, billy.Filesystem) {
+	t.Helper()
+
+	host := memfs.New()
+	if err := util.WriteFile(host, "/etc/passwd", []byte("root"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(host, "/jail/etc/passwd", []byte("jailed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Symlink("/etc", "/jail/abs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Symlink("../../etc", "/jail/sub/rel"); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Symlink("/etc/passwd", "/jail/pw"); err != nil {
+		t.Fatal(err)
+	}
+
+	return host, chroot.NewWithOptions(host, "/jail", chroot.Options{ResolveSymlinks: resolve})
+}
+
+func TestResolveSymlinksContained(t *testing.T) {
+	_, fs := newJail(t, true)
+
+	for _, name := range []string{"pw", "abs/passwd", "sub/rel/passwd", "sub/../abs/passwd"} {
+		b, err := util.ReadFile(fs, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(b) != "jailed" {
+			t.Errorf("%s: read %q, escaping the chroot", name, b)
+		}
+	}
+
+	sub, err := fs.Chroot("abs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := util.ReadFile(sub, "passwd"); err != nil || string(b) != "jailed" {
+		t.Errorf("chroot through a link: read %q, %v", b, err)
+	}
+}
+
+func TestResolveSymlinksDisabled(t *testing.T) {
+	_, fs := newJail(t, false)
+
+	b, err := util.ReadFile(fs, "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "root" {
+		t.Errorf("expected the lexical chroot to follow the link, read %q", b)
+	}
+}
+
+func TestResolveSymlinksLinkItself(t *testing.T) {
+	host, fs := newJail(t, true)
+
+	fi, err := fs.Lstat("abs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat followed the link: %v", fi.Mode())
+	}
+
+	target, err := fs.Readlink("sub/rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "../../etc" {
+		t.Errorf("unexpected target %q", target)
+	}
+
+	if err := fs.Remove("abs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := host.Stat("/etc/passwd"); err != nil {
+		t.Errorf("removing the link affected its target: %v", err)
+	}
+}
+
+// TestResolveSymlinksSwapped checks that the links are resolved on every
+// call, a directory replaced by a link between two calls not leading outside
+// of the chroot.
+func TestResolveSymlinksSwapped(t *testing.T) {
+	host, fs := newJail(t, true)
+
+	if err := util.WriteFile(fs, "dir/passwd", []byte("dir"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := util.ReadFile(fs, "dir/passwd"); err != nil || string(b) != "dir" {
+		t.Fatalf("read %q, %v", b, err)
+	}
+
+	if err := util.RemoveAll(host, "/jail/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Symlink("/etc", "/jail/dir"); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := util.ReadFile(fs, "dir/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "jailed" {
+		t.Errorf("read %q after the swap, escaping the chroot", b)
+	}
+
+	if err := util.WriteFile(fs, "dir/passwd", []byte("overwritten"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := util.ReadFile(host, "/etc/passwd"); string(b) != "root" {
+		t.Errorf("write escaped the chroot: %q", b)
+	}
+}
@@ -0,0 +1,279 @@
+// Package ctxfs adapts any billy filesystem to billy.FilesystemCtx, and
+// binds a billy.FilesystemCtx to a context to use it as a billy.Filesystem.
+package ctxfs // import "github.com/go-git/go-billy/v5/helper/ctxfs"
+
+import (
+	"context"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// Adapter implements billy.FilesystemCtx on top of a billy.Filesystem which
+// isn't context-aware. Each operation checks the context before starting and
+// runs in its own goroutine, so that the caller is released as soon as the
+// context is done, even if the wrapped filesystem is hung. The abandoned
+// operation keeps running in the background; files it opens are closed when
+// it completes.
+type Adapter struct {
+	billy.Filesystem
+}
+
+// New returns a billy.FilesystemCtx for fs. If fs already implements
+// billy.FilesystemCtx it is returned as is.
+func New(fs billy.Filesystem) billy.FilesystemCtx {
+	if c, ok := fs.(billy.FilesystemCtx); ok {
+		return c
+	}
+
+	return &Adapter{Filesystem: fs}
+}
+
+func run(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func open(ctx context.Context, op, filename string, fn func() (billy.File, error)) (billy.File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &os.PathError{Op: op, Path: filename, Err: err}
+	}
+
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		f   billy.File
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		f, err := fn()
+		done <- result{f, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.f, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.f != nil {
+				_ = r.f.Close()
+			}
+		}()
+		return nil, &os.PathError{Op: op, Path: filename, Err: ctx.Err()}
+	}
+}
+
+func pathErr(op, filename string, err error) error {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return &os.PathError{Op: op, Path: filename, Err: err}
+	}
+
+	return err
+}
+
+func (a *Adapter) CreateContext(ctx context.Context, filename string) (billy.File, error) {
+	return open(ctx, "create", filename, func() (billy.File, error) {
+		return a.Filesystem.Create(filename)
+	})
+}
+
+func (a *Adapter) OpenContext(ctx context.Context, filename string) (billy.File, error) {
+	return open(ctx, "open", filename, func() (billy.File, error) {
+		return a.Filesystem.Open(filename)
+	})
+}
+
+func (a *Adapter) OpenFileContext(ctx context.Context, filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return open(ctx, "open", filename, func() (billy.File, error) {
+		return a.Filesystem.OpenFile(filename, flag, perm)
+	})
+}
+
+func (a *Adapter) StatContext(ctx context.Context, filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := run(ctx, func() (err error) {
+		fi, err = a.Filesystem.Stat(filename)
+		return err
+	})
+	if err != nil {
+		return nil, pathErr("stat", filename, err)
+	}
+
+	return fi, nil
+}
+
+func (a *Adapter) LstatContext(ctx context.Context, filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := run(ctx, func() (err error) {
+		fi, err = a.Filesystem.Lstat(filename)
+		return err
+	})
+	if err != nil {
+		return nil, pathErr("lstat", filename, err)
+	}
+
+	return fi, nil
+}
+
+func (a *Adapter) RenameContext(ctx context.Context, oldpath, newpath string) error {
+	err := run(ctx, func() error {
+		return a.Filesystem.Rename(oldpath, newpath)
+	})
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	return err
+}
+
+func (a *Adapter) RemoveContext(ctx context.Context, filename string) error {
+	return pathErr("remove", filename, run(ctx, func() error {
+		return a.Filesystem.Remove(filename)
+	}))
+}
+
+func (a *Adapter) ReadDirContext(ctx context.Context, path string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	err := run(ctx, func() (err error) {
+		infos, err = a.Filesystem.ReadDir(path)
+		return err
+	})
+	if err != nil {
+		return nil, pathErr("readdir", path, err)
+	}
+
+	return infos, nil
+}
+
+func (a *Adapter) MkdirAllContext(ctx context.Context, filename string, perm os.FileMode) error {
+	return pathErr("mkdir", filename, run(ctx, func() error {
+		return a.Filesystem.MkdirAll(filename, perm)
+	}))
+}
+
+// Chroot returns an Adapter for the chrooted view of the wrapped filesystem.
+func (a *Adapter) Chroot(path string) (billy.Filesystem, error) {
+	fs, err := a.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(fs), nil
+}
+
+// Capabilities implements the Capable interface. The optional interfaces
+// of the wrapped filesystem are hidden by the Adapter.
+func (a *Adapter) Capabilities() billy.Capability {
+	return billy.Capabilities(a.Filesystem) &^ billy.InterfaceCapabilities
+}
+
+// Bound is a billy.Filesystem performing the operations of a
+// billy.FilesystemCtx within a fixed context.
+type Bound struct {
+	fs  billy.FilesystemCtx
+	ctx context.Context
+}
+
+// WithContext returns a billy.Filesystem calling the context-aware methods
+// of fs with ctx, so that code unaware of contexts can be cancelled.
+func WithContext(ctx context.Context, fs billy.FilesystemCtx) *Bound {
+	return &Bound{fs: fs, ctx: ctx}
+}
+
+func (b *Bound) Create(filename string) (billy.File, error) {
+	return b.fs.CreateContext(b.ctx, filename)
+}
+
+func (b *Bound) Open(filename string) (billy.File, error) {
+	return b.fs.OpenContext(b.ctx, filename)
+}
+
+func (b *Bound) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return b.fs.OpenFileContext(b.ctx, filename, flag, perm)
+}
+
+func (b *Bound) Stat(filename string) (os.FileInfo, error) {
+	return b.fs.StatContext(b.ctx, filename)
+}
+
+func (b *Bound) Rename(from, to string) error {
+	return b.fs.RenameContext(b.ctx, from, to)
+}
+
+func (b *Bound) Remove(filename string) error {
+	return b.fs.RemoveContext(b.ctx, filename)
+}
+
+func (b *Bound) Join(elem ...string) string {
+	return b.fs.Join(elem...)
+}
+
+func (b *Bound) TempFile(dir, prefix string) (billy.File, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, &os.PathError{Op: "tempfile", Path: dir, Err: err}
+	}
+
+	return b.fs.TempFile(dir, prefix)
+}
+
+func (b *Bound) ReadDir(path string) ([]os.FileInfo, error) {
+	return b.fs.ReadDirContext(b.ctx, path)
+}
+
+func (b *Bound) MkdirAll(filename string, perm os.FileMode) error {
+	return b.fs.MkdirAllContext(b.ctx, filename, perm)
+}
+
+func (b *Bound) Lstat(filename string) (os.FileInfo, error) {
+	return b.fs.LstatContext(b.ctx, filename)
+}
+
+func (b *Bound) Symlink(target, link string) error {
+	if err := b.ctx.Err(); err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: err}
+	}
+
+	return b.fs.Symlink(target, link)
+}
+
+func (b *Bound) Readlink(link string) (string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: err}
+	}
+
+	return b.fs.Readlink(link)
+}
+
+// Chroot returns a chrooted view of b, bound to the same context.
+func (b *Bound) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(b, path), nil
+}
+
+func (b *Bound) Root() string {
+	return b.fs.Root()
+}
+
+// Capabilities implements the Capable interface, see Adapter.Capabilities.
+func (b *Bound) Capabilities() billy.Capability {
+	return billy.Capabilities(b.fs) &^ billy.InterfaceCapabilities
+}
@@ -0,0 +1,76 @@
+package ctxfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// hung is a filesystem whose Stat and Open block until release is closed.
+type hung struct {
+	billy.Filesystem
+	release chan struct{}
+}
+
+func (fs *hung) Stat(filename string) (os.FileInfo, error) {
+	<-fs.release
+	return fs.Filesystem.Stat(filename)
+}
+
+func (fs *hung) Open(filename string) (billy.File, error) {
+	<-fs.release
+	return fs.Filesystem.Open(filename)
+}
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return WithContext(context.Background(), New(memfs.New()))
+	})
+}
+
+func TestDeadline(t *testing.T) {
+	fs := &hung{Filesystem: memfs.New(), release: make(chan struct{})}
+	defer close(fs.release)
+	util.WriteFile(fs, "foo", nil, 0644)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := New(fs).StatContext(ctx, "foo")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	_, err = WithContext(ctx, New(fs)).Open("foo")
+	var perr *os.PathError
+	if !errors.As(err, &perr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a PathError with DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestCancelledBeforeStart(t *testing.T) {
+	fs := memfs.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := New(fs).MkdirAllContext(ctx, "dir", 0755); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Canceled, got %v", err)
+	}
+	if _, err := fs.Stat("dir"); !os.IsNotExist(err) {
+		t.Errorf("expected the directory not to be created, got %v", err)
+	}
+}
+
+func TestNative(t *testing.T) {
+	a := New(memfs.New())
+	if New(a) != a {
+		t.Error("expected a billy.FilesystemCtx to be returned as is")
+	}
+}
@@ -0,0 +1,237 @@
+// Package debugfs provides a billy filesystem wrapper tracking the files
+// opened through it, to find the ones never closed.
+package debugfs // import "github.com/go-git/go-billy/v5/helper/debugfs"
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+)
+
+// Leak is a file opened through an FS and not closed yet.
+type Leak struct {
+	// Name is the name of the file, as returned by its Name method.
+	Name string
+	// Stack is the stack trace of the goroutine which opened the file.
+	Stack string
+}
+
+func (l Leak) String() string {
+	return l.Name + " opened at:\n" + l.Stack
+}
+
+// LeakError is returned by FS.Close when files are left open.
+type LeakError struct {
+	Leaks []Leak
+	// Err is the error closing the wrapped filesystem, if any.
+	Err error
+}
+
+func (e *LeakError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d files left open", len(e.Leaks))
+	if e.Err != nil {
+		fmt.Fprintf(&b, ", closing the filesystem: %s", e.Err)
+	}
+	for _, l := range e.Leaks {
+		b.WriteString("\n\n")
+		b.WriteString(l.String())
+	}
+
+	return b.String()
+}
+
+func (e *LeakError) Unwrap() error {
+	return e.Err
+}
+
+// FS is a filesystem wrapper recording the stack trace of every file opened
+// through it, until the file is closed. The files still open are returned
+// by Leaks, and reported by Close.
+//
+// Recording the stack traces is costly: FS is meant for tests and
+// debugging sessions, not to be left in production.
+type FS struct {
+	wrapper.Base
+
+	m    sync.Mutex
+	open map[*file]Leak
+}
+
+// New returns a filesystem wrapping fs, tracking the files opened.
+func New(fs billy.Filesystem) *FS {
+	return &FS{
+		Base: wrapper.NewBase(fs),
+		open: make(map[*file]Leak),
+	}
+}
+
+// Layer returns the billy.Layer of New, for billy.Compose.
+func Layer() billy.Layer {
+	return billy.Layer{
+		Name: "debug",
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs), nil
+		},
+	}
+}
+
+// Leaks returns the files opened through fs and not closed yet, sorted by
+// name.
+func (fs *FS) Leaks() []Leak {
+	fs.m.Lock()
+	leaks := make([]Leak, 0, len(fs.open))
+	for _, l := range fs.open {
+		leaks = append(leaks, l)
+	}
+	fs.m.Unlock()
+
+	sort.SliceStable(leaks, func(i, j int) bool {
+		return leaks[i].Name < leaks[j].Name
+	})
+
+	return leaks
+}
+
+// Close implements billy.Closer, closing the wrapped filesystem. It returns
+// a *LeakError if files are left open, which are then forgotten.
+func (fs *FS) Close() error {
+	err := fs.Base.Close()
+
+	leaks := fs.Leaks()
+	if len(leaks) == 0 {
+		return err
+	}
+
+	fs.m.Lock()
+	fs.open = make(map[*file]Leak)
+	fs.m.Unlock()
+
+	return &LeakError{Leaks: leaks, Err: err}
+}
+
+// TB is the subset of testing.TB used by AssertNoLeaks.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertNoLeaks fails t, reporting where they were opened, if files opened
+// through fs are left open. It is usually deferred, or registered with
+// t.Cleanup, once the files opened by the code under test are expected to
+// be closed.
+func AssertNoLeaks(t TB, fs *FS) {
+	t.Helper()
+
+	leaks := fs.Leaks()
+	if len(leaks) == 0 {
+		return
+	}
+
+	t.Errorf("%s", &LeakError{Leaks: leaks})
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	f, err := fs.Base.Create(filename)
+	return fs.track(f, err)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	f, err := fs.Base.Open(filename)
+	return fs.track(f, err)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := fs.Base.OpenFile(filename, flag, perm)
+	return fs.track(f, err)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.Base.TempFile(dir, prefix)
+	return fs.track(f, err)
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := fs.Base.CreateTemp(dir, pattern)
+	return fs.track(f, err)
+}
+
+// Chroot returns a chrooted view of fs, whose files are tracked by fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) track(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &file{File: f, fs: fs}
+	fs.m.Lock()
+	fs.open[tf] = Leak{Name: f.Name(), Stack: stack()}
+	fs.m.Unlock()
+
+	return tf, nil
+}
+
+func (fs *FS) untrack(f *file) {
+	fs.m.Lock()
+	delete(fs.open, f)
+	fs.m.Unlock()
+}
+
+// stack returns the stack trace of the calling goroutine, without the
+// frames of this package.
+func stack() string {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	// The first line names the goroutine, and each frame takes two lines,
+	// the function and its location.
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	out := append(make([]string, 0, len(lines)), lines[0])
+	for i := 1; i+1 < len(lines); i += 2 {
+		if isWrapper(lines[i], lines[i+1]) {
+			continue
+		}
+		out = append(out, lines[i], lines[i+1])
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// isWrapper reports whether the frame of function, at location, is one of
+// this package.
+func isWrapper(function, location string) bool {
+	if strings.Contains(location, "_test.go:") {
+		return false
+	}
+
+	return strings.HasPrefix(function, "github.com/go-git/go-billy/v5/helper/debugfs.")
+}
+
+// file is a file tracked by an FS until closed.
+type file struct {
+	billy.File
+	fs *FS
+}
+
+func (f *file) Close() error {
+	f.fs.untrack(f)
+	return f.File.Close()
+}
@@ -0,0 +1,77 @@
+package debugfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New())
+	})
+}
+
+// recorder is a TB recording the failures.
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func leak(fs billy.Filesystem, name string) error {
+	_, err := fs.Create(name)
+	return err
+}
+
+func TestLeaks(t *testing.T) {
+	fs := New(memfs.New())
+	if err := util.WriteFile(fs, "closed", []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	AssertNoLeaks(t, fs)
+
+	if err := fs.MkdirAll("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leak(sub, "open"); err != nil {
+		t.Fatal(err)
+	}
+
+	leaks := fs.Leaks()
+	if len(leaks) != 1 || leaks[0].Name != "dir/open" {
+		t.Fatalf("Leaks = %v", leaks)
+	}
+	if !strings.Contains(leaks[0].Stack, "debugfs.leak(") {
+		t.Errorf("stack doesn't name the opener:\n%s", leaks[0].Stack)
+	}
+	if strings.Contains(leaks[0].Stack, "debugfs.(*FS)") {
+		t.Errorf("stack holds the frames of the wrapper:\n%s", leaks[0].Stack)
+	}
+
+	r := &recorder{}
+	AssertNoLeaks(r, fs)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "1 files left open") {
+		t.Errorf("AssertNoLeaks reported %q", r.errors)
+	}
+
+	var lerr *LeakError
+	if err := fs.Close(); !errors.As(err, &lerr) || len(lerr.Leaks) != 1 {
+		t.Fatalf("Close = %v, want a *LeakError", err)
+	}
+	AssertNoLeaks(t, fs)
+}
@@ -0,0 +1,226 @@
+// Package filterfs provides billy filesystem wrappers exposing a filtered,
+// read-only view of another filesystem. The files left out of the view don't
+// show in ReadDir, and can't be opened nor stat'ed.
+package filterfs // import "github.com/go-git/go-billy/v5/helper/filterfs"
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// FS is a read-only billy.Filesystem hiding some of the files of the
+// filesystem it wraps. Hidden files behave as if they didn't exist, and
+// every write operation fails with billy.ErrReadOnly.
+type FS struct {
+	underlying billy.Filesystem
+	hidden     func(name string, fi os.FileInfo) (bool, error)
+}
+
+// New returns a view of fs only showing the files for which keep returns
+// true. keep is called with the slash separated path of the file, relative to
+// the root of fs, and the result of Lstat. It is called for directories too,
+// rejecting a directory hides everything below it, so a predicate selecting
+// files by name usually keeps every directory:
+//
+//	filterfs.New(fs, func(path string, fi os.FileInfo) bool {
+//		return fi.IsDir() || strings.HasSuffix(path, ".yaml")
+//	})
+func New(fs billy.Filesystem, keep func(path string, fi os.FileInfo) bool) *FS {
+	return &FS{underlying: fs, hidden: func(name string, fi os.FileInfo) (bool, error) {
+		elems := splitPath(filepath.ToSlash(name))
+		for i := 1; i < len(elems); i++ {
+			dir := path.Join(elems[:i]...)
+			dfi, err := fs.Lstat(dir)
+			if err != nil {
+				return false, err
+			}
+			if !keep(dir, dfi) {
+				return true, nil
+			}
+		}
+
+		return len(elems) > 0 && !keep(path.Join(elems...), fi), nil
+	}}
+}
+
+// NewIgnore returns a view of fs hiding the files matching gitignore-style
+// patterns, either given in opts.Patterns or read from the ignore files
+// named in opts.Files, in the same way git does:
+//
+//   - blank lines and lines starting with "#" are skipped;
+//   - a pattern starting with "!" re-includes the files excluded by the
+//     previous patterns, though not those in an excluded directory;
+//   - a pattern ending with "/" only matches directories;
+//   - a pattern with a "/" at the beginning or in the middle is relative to
+//     the directory of the ignore file, otherwise it matches at any depth;
+//   - "**" matches any number of directories.
+//
+// When several patterns match a file, the last one wins, the patterns of the
+// deeper ignore files coming last. The ignore files are read once, the first
+// time their directory is accessed.
+func NewIgnore(fs billy.Filesystem, opts IgnoreOptions) *FS {
+	m := newIgnoreMatcher(fs, opts)
+	return &FS{underlying: fs, hidden: func(name string, fi os.FileInfo) (bool, error) {
+		return m.ignored(filepath.ToSlash(name), fi.IsDir())
+	}}
+}
+
+// IgnoreLayer returns the billy.Layer of NewIgnore, for billy.Compose.
+func IgnoreLayer(opts IgnoreOptions) billy.Layer {
+	return billy.Layer{
+		Name:     "ignore",
+		Requires: billy.ReadCapability,
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return NewIgnore(fs, opts), nil
+		},
+	}
+}
+
+// ReadOnlyLayer returns a billy.Layer showing all the files of the
+// filesystem below, but failing every write with billy.ErrReadOnly.
+func ReadOnlyLayer() billy.Layer {
+	return billy.Layer{
+		Name:     "read-only",
+		Requires: billy.ReadCapability,
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs, func(string, os.FileInfo) bool { return true }), nil
+		},
+	}
+}
+
+// check returns an error if the file at name is hidden, or doesn't exist.
+func (fs *FS) check(op, name string) error {
+	fi, err := fs.underlying.Lstat(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.checkInfo(op, name, fi)
+}
+
+func (fs *FS) checkInfo(op, name string, fi os.FileInfo) error {
+	hidden, err := fs.hidden(name, fi)
+	if err != nil {
+		return err
+	}
+	if hidden {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	return nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+	if err := fs.check("open", filename); err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.OpenFile(filename, flag, perm)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	if err := fs.check("stat", filename); err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.Stat(filename)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := fs.underlying.Lstat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.checkInfo("lstat", filename, fi); err != nil {
+		return nil, err
+	}
+
+	return fi, nil
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	if err := fs.check("readlink", link); err != nil {
+		return "", err
+	}
+
+	return fs.underlying.Readlink(link)
+}
+
+// ReadDir returns the entries of the directory at path which aren't hidden.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	if err := fs.check("readdir", path); err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.underlying.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := entries[:0]
+	for _, fi := range entries {
+		hidden, err := fs.hidden(fs.underlying.Join(path, fi.Name()), fi)
+		if err != nil {
+			return nil, err
+		}
+		if !hidden {
+			visible = append(visible, fi)
+		}
+	}
+
+	return visible, nil
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// Capabilities implements the Capable interface. The view is read-only, and
+// none of the optional interfaces of the wrapped filesystem are exposed.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^
+		(billy.WriteCapability | billy.ReadAndWriteCapability | billy.TruncateCapability | billy.InterfaceCapabilities)
+}
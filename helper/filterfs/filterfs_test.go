@@ -0,0 +1,211 @@
+package filterfs
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func newIgnoreFS(t *testing.T, files map[string]string, opts IgnoreOptions) *FS {
+	t.Helper()
+
+	mem := memfs.New()
+	for name, content := range files {
+		if err := util.WriteFile(mem, name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewIgnore(mem, opts)
+}
+
+func readDirNames(t *testing.T, fs billy.Filesystem, path string) []string {
+	t.Helper()
+
+	infos, err := fs.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	fs := newIgnoreFS(t, map[string]string{
+		".sourceignore":          "# build output\nbuild/\n*.log\n!keep.log\n",
+		"main.go":                "",
+		"debug.log":              "",
+		"keep.log":               "",
+		"build/out":              "",
+		"src/build/out":          "",
+		"src/app.go":             "",
+		"src/.sourceignore":      "/app.go\n!debug.log\n",
+		"src/debug.log":          "",
+		"src/vendor/app.go":      "",
+		"docs/.gitignore":        "*",
+		"docs/readme.md":         "",
+		"deploy/.sourceignore":   "!/build/\n",
+		"deploy/build/kustomize": "",
+	}, IgnoreOptions{Files: []string{".sourceignore"}})
+
+	for _, name := range []string{"debug.log", "build", "build/out", "src/build/out", "src/app.go"} {
+		if _, err := fs.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("Stat(%q): expected not exist, got %v", name, err)
+		}
+		if _, err := fs.Open(name); !os.IsNotExist(err) {
+			t.Errorf("Open(%q): expected not exist, got %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"main.go", "keep.log", "src/debug.log", "src/vendor/app.go", "docs/readme.md", "deploy/build/kustomize"} {
+		if _, err := fs.Stat(name); err != nil {
+			t.Errorf("Stat(%q): %v", name, err)
+		}
+		f, err := fs.Open(name)
+		if err != nil {
+			t.Errorf("Open(%q): %v", name, err)
+			continue
+		}
+		f.Close()
+	}
+
+	want := []string{".sourceignore", "deploy", "docs", "keep.log", "main.go", "src"}
+	if got := readDirNames(t, fs, "/"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(/): got %v, want %v", got, want)
+	}
+
+	want = []string{".sourceignore", "debug.log", "vendor"}
+	if got := readDirNames(t, fs, "src"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(src): got %v, want %v", got, want)
+	}
+
+	if _, err := fs.ReadDir("build"); !os.IsNotExist(err) {
+		t.Errorf("ReadDir(build): expected not exist, got %v", err)
+	}
+}
+
+func TestIgnorePatterns(t *testing.T) {
+	fs := newIgnoreFS(t, map[string]string{
+		".gitignore": "!*.md\n",
+		"a.md":       "",
+		"b.txt":      "",
+		"c.yaml":     "",
+	}, IgnoreOptions{
+		Patterns: []string{"*", "!*.yaml"},
+		Files:    []string{".gitignore"},
+	})
+
+	want := []string{"a.md", "c.yaml"}
+	if got := readDirNames(t, fs, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIgnoreChroot(t *testing.T) {
+	fs := newIgnoreFS(t, map[string]string{
+		"sub/a.log": "",
+		"sub/a.go":  "",
+	}, IgnoreOptions{Patterns: []string{"/sub/*.log"}})
+
+	sub, err := fs.Chroot("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sub.Stat("a.log"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist, got %v", err)
+	}
+
+	want := []string{"a.go"}
+	if got := readDirNames(t, sub, "/"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fs := newIgnoreFS(t, map[string]string{"a": ""}, IgnoreOptions{})
+
+	_, err := fs.Create("b")
+	if !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Create: expected ErrReadOnly, got %v", err)
+	}
+	_, err = fs.OpenFile("a", os.O_WRONLY, 0)
+	if !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("OpenFile: expected ErrReadOnly, got %v", err)
+	}
+	if err := fs.Remove("a"); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Remove: expected ErrReadOnly, got %v", err)
+	}
+	if err := fs.Rename("a", "b"); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Rename: expected ErrReadOnly, got %v", err)
+	}
+	if err := fs.MkdirAll("d", 0o755); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("MkdirAll: expected ErrReadOnly, got %v", err)
+	}
+
+	if caps := fs.Capabilities(); caps&billy.WriteCapability != 0 {
+		t.Errorf("unexpected write capability: %v", caps)
+	}
+}
+
+func TestNew(t *testing.T) {
+	mem := memfs.New()
+	for name, size := range map[string]int{
+		"a.yaml":           10,
+		"b.json":           10,
+		"big.yaml":         100,
+		"dir/c.yaml":       10,
+		"dir/d.txt":        10,
+		"vendor/e.yaml":    10,
+		"vendor/sub/f.yml": 10,
+	} {
+		if err := util.WriteFile(mem, name, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var paths []string
+	fs := New(mem, func(path string, fi os.FileInfo) bool {
+		paths = append(paths, path)
+		if fi.IsDir() {
+			return path != "vendor"
+		}
+		return strings.HasSuffix(path, ".yaml") && fi.Size() < 50
+	})
+
+	want := []string{"a.yaml", "dir"}
+	if got := readDirNames(t, fs, "/"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(/): got %v, want %v", got, want)
+	}
+	want = []string{"c.yaml"}
+	if got := readDirNames(t, fs, "dir"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadDir(dir): got %v, want %v", got, want)
+	}
+
+	for _, name := range []string{"b.json", "big.yaml", "dir/d.txt", "vendor", "vendor/e.yaml", "vendor/sub/f.yml"} {
+		if _, err := fs.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("Stat(%q): expected not exist, got %v", name, err)
+		}
+	}
+
+	paths = nil
+	if _, err := fs.Stat("/dir/c.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dir", "dir/c.yaml"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("predicate called with %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,255 @@
+package filterfs
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// IgnoreOptions configures the patterns hiding files in NewIgnore.
+type IgnoreOptions struct {
+	// Patterns are gitignore-style patterns, relative to the root of the
+	// filesystem. They have a lower precedence than the ones of the ignore
+	// files.
+	Patterns []string
+	// Files are the names of the ignore files, such as ".gitignore" or
+	// ".sourceignore", read in every directory. Their patterns are relative
+	// to the directory holding them, and take precedence over the patterns
+	// of the parent directories.
+	Files []string
+}
+
+// ignorePattern is a parsed gitignore pattern.
+type ignorePattern struct {
+	domain   []string // directory of the ignore file, relative to the root
+	elems    []string // path elements of the pattern
+	negate   bool     // the pattern starts with "!"
+	dirOnly  bool     // the pattern ends with "/"
+	anchored bool     // the pattern is matched from the domain only
+}
+
+// parseIgnorePattern parses line, read from an ignore file of the domain
+// directory. It returns false for blank lines and comments.
+func parseIgnorePattern(line string, domain []string) (ignorePattern, bool) {
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	p := ignorePattern{domain: domain}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash at the beginning or in the middle anchors the pattern to the
+	// directory of the ignore file, otherwise it matches at any depth.
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	p.elems = strings.Split(line, "/")
+	if len(p.elems) > 1 && p.elems[0] == "**" {
+		// "**/foo" is the same as an unanchored "foo" if it has no other
+		// slashes, and matches foo at any depth otherwise.
+		p.elems = p.elems[1:]
+		p.anchored = len(p.elems) > 1
+		if p.anchored {
+			p.elems = append([]string{"**"}, p.elems...)
+		}
+	}
+
+	return p, true
+}
+
+// trimTrailingSpaces removes the trailing spaces of line, unless they are
+// escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	return strings.Replace(line, `\ `, " ", -1)
+}
+
+// match reports whether the pattern matches elems, the path elements of a
+// file relative to the root.
+func (p ignorePattern) match(elems []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if len(elems) <= len(p.domain) {
+		return false
+	}
+	for i, d := range p.domain {
+		if elems[i] != d {
+			return false
+		}
+	}
+	rel := elems[len(p.domain):]
+
+	if !p.anchored {
+		ok, _ := path.Match(p.elems[0], rel[len(rel)-1])
+		return ok
+	}
+
+	return matchElems(p.elems, rel)
+}
+
+// matchElems matches the path elements name against the pattern elements,
+// "**" matching zero or more elements, or one or more when trailing.
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// ignoreMatcher decides whether the files of a filesystem are ignored,
+// reading the ignore files of the directories on demand.
+type ignoreMatcher struct {
+	fs    billy.Filesystem
+	opts  IgnoreOptions
+	base  []ignorePattern
+	m     sync.Mutex
+	cache map[string][]ignorePattern
+}
+
+func newIgnoreMatcher(fs billy.Filesystem, opts IgnoreOptions) *ignoreMatcher {
+	m := &ignoreMatcher{fs: fs, opts: opts, cache: make(map[string][]ignorePattern)}
+	for _, line := range opts.Patterns {
+		if p, ok := parseIgnorePattern(line, nil); ok {
+			m.base = append(m.base, p)
+		}
+	}
+
+	return m
+}
+
+// patterns returns the patterns of the ignore files of the directory dir.
+func (m *ignoreMatcher) patterns(dir []string) ([]ignorePattern, error) {
+	key := path.Join(dir...)
+
+	m.m.Lock()
+	patterns, ok := m.cache[key]
+	m.m.Unlock()
+	if ok {
+		return patterns, nil
+	}
+
+	for _, name := range m.opts.Files {
+		p, err := m.read(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p...)
+	}
+
+	m.m.Lock()
+	m.cache[key] = patterns
+	m.m.Unlock()
+
+	return patterns, nil
+}
+
+func (m *ignoreMatcher) read(dir []string, name string) ([]ignorePattern, error) {
+	elems := make([]string, 0, len(dir)+1)
+	elems = append(append(elems, dir...), name)
+
+	f, err := m.fs.Open(m.fs.Join(elems...))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []ignorePattern
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if p, ok := parseIgnorePattern(s.Text(), dir); ok {
+			patterns = append(patterns, p)
+		}
+	}
+
+	return patterns, s.Err()
+}
+
+// ignored reports whether the file at name, a slash separated path relative
+// to the root, is ignored. A file is ignored if it matches the patterns, or
+// if one of its parent directories does.
+func (m *ignoreMatcher) ignored(name string, isDir bool) (bool, error) {
+	elems := splitPath(name)
+	patterns := m.base
+	for i := 1; i <= len(elems); i++ {
+		dirPatterns, err := m.patterns(elems[:i-1])
+		if err != nil {
+			return false, err
+		}
+		patterns = append(patterns[:len(patterns):len(patterns)], dirPatterns...)
+
+		if matchIgnore(patterns, elems[:i], i < len(elems) || isDir) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchIgnore returns the result of the last pattern matching elems.
+func matchIgnore(patterns []ignorePattern, elems []string, isDir bool) bool {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if patterns[i].match(elems, isDir) {
+			return !patterns[i].negate
+		}
+	}
+
+	return false
+}
+
+func splitPath(name string) []string {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		return nil
+	}
+
+	return strings.Split(name, "/")
+}
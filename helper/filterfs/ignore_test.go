@@ -0,0 +1,60 @@
+package filterfs
+
+import (
+	"testing"
+)
+
+func TestIgnorePatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		domain  []string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", nil, "a.log", false, true},
+		{"*.log", nil, "dir/sub/a.log", false, true},
+		{"*.log", nil, "a.txt", false, false},
+		{"/a.log", nil, "a.log", false, true},
+		{"/a.log", nil, "dir/a.log", false, false},
+		{"build/", nil, "build", true, true},
+		{"build/", nil, "build", false, false},
+		{"build/", nil, "src/build", true, true},
+		{"doc/*.txt", nil, "doc/a.txt", false, true},
+		{"doc/*.txt", nil, "doc/sub/a.txt", false, false},
+		{"**/foo", nil, "a/b/foo", false, true},
+		{"**/foo/bar", nil, "a/foo/bar", false, true},
+		{"**/foo/bar", nil, "foo/bar", false, true},
+		{"a/**/b", nil, "a/b", false, true},
+		{"a/**/b", nil, "a/x/y/b", false, true},
+		{"abc/**", nil, "abc/x", false, true},
+		{"abc/**", nil, "abc", true, false},
+		{"*.log", []string{"sub"}, "sub/a.log", false, true},
+		{"*.log", []string{"sub"}, "a.log", false, false},
+		{"/a.log", []string{"sub"}, "sub/a.log", false, true},
+		{"/a.log", []string{"sub"}, "sub/x/a.log", false, false},
+		{`\#file`, nil, "#file", false, true},
+		{`\!file`, nil, "!file", false, true},
+		{`trailing\ `, nil, "trailing ", false, true},
+		{"spaces   ", nil, "spaces", false, true},
+	}
+
+	for _, tc := range tests {
+		p, ok := parseIgnorePattern(tc.pattern, tc.domain)
+		if !ok {
+			t.Errorf("%q: not parsed", tc.pattern)
+			continue
+		}
+		if got := p.match(splitPath(tc.path), tc.isDir); got != tc.want {
+			t.Errorf("%q (domain %v) matching %q: got %v, want %v", tc.pattern, tc.domain, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParseIgnorePatternSkipped(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "/", "!"} {
+		if _, ok := parseIgnorePattern(line, nil); ok {
+			t.Errorf("%q: expected to be skipped", line)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package fusefs mounts any billy filesystem on the host with FUSE, to
+// inspect or modify a live memfs or overlay with the usual tools while
+// debugging.
+//
+// It depends on bazil.org/fuse, in a module of its own so that the users of
+// billy don't, and is only built with the "fuse" build tag, on Linux,
+// FreeBSD and macOS; it is empty otherwise.
+package fusefs // import "github.com/go-git/go-billy/v5/helper/fusefs"
@@ -0,0 +1,392 @@
+//go:build fuse && (linux || freebsd || darwin)
+// +build fuse
+// +build linux freebsd darwin
+
+package fusefs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// attrValid is how long the kernel caches the attributes of the files. It is
+// kept short, the wrapped filesystem being likely changed behind its back.
+const attrValid = time.Second
+
+var (
+	_ fusefs.FS                 = (*FS)(nil)
+	_ fusefs.NodeStringLookuper = (*node)(nil)
+	_ fusefs.NodeOpener         = (*node)(nil)
+	_ fusefs.NodeCreater        = (*node)(nil)
+	_ fusefs.NodeMkdirer        = (*node)(nil)
+	_ fusefs.NodeRemover        = (*node)(nil)
+	_ fusefs.NodeRenamer        = (*node)(nil)
+	_ fusefs.NodeSetattrer      = (*node)(nil)
+	_ fusefs.NodeSymlinker      = (*node)(nil)
+	_ fusefs.NodeReadlinker     = (*node)(nil)
+	_ fusefs.HandleReadDirAller = (*node)(nil)
+	_ fusefs.HandleReader       = (*handle)(nil)
+	_ fusefs.HandleWriter       = (*handle)(nil)
+	_ fusefs.HandleReleaser     = (*handle)(nil)
+)
+
+// FS exposes a billy.Filesystem as a bazil.org/fuse/fs.FS, to be served on a
+// FUSE connection. The files are looked up by path on every operation, so
+// the changes made to the wrapped filesystem directly show up in the mount.
+//
+// Symbolic links are supported if the wrapped filesystem implements
+// billy.Symlink, and the mode and times of the files can be changed if it
+// implements billy.Change. The errors are reported to the kernel as their
+// errno, EIO being used for the ones which have none.
+type FS struct {
+	fs billy.Filesystem
+}
+
+// New returns a fs.FS backed by the given billy filesystem.
+func New(fs billy.Filesystem) *FS {
+	return &FS{fs: fs}
+}
+
+// Root implements fs.FS.
+func (fs *FS) Root() (fusefs.Node, error) {
+	return &node{fs: fs, path: string(filepath.Separator)}, nil
+}
+
+// Server serves a billy filesystem mounted with Mount.
+type Server struct {
+	conn *fuse.Conn
+	dir  string
+	done chan struct{}
+	err  error
+}
+
+// Mount mounts fs on the directory dir and serves it in the background,
+// until Unmount is called or the mountpoint is unmounted by other means,
+// such as fusermount -u.
+func Mount(fs billy.Filesystem, dir string, options ...fuse.MountOption) (*Server, error) {
+	conn, err := fuse.Mount(dir, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{conn: conn, dir: dir, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		s.err = fusefs.Serve(conn, New(fs))
+	}()
+
+	return s, nil
+}
+
+// Unmount unmounts the filesystem and waits for the server to stop.
+func (s *Server) Unmount() error {
+	if err := fuse.Unmount(s.dir); err != nil {
+		return err
+	}
+
+	return s.Wait()
+}
+
+// Wait waits for the filesystem to be unmounted, and returns the error which
+// stopped serving it, if any.
+func (s *Server) Wait() error {
+	<-s.done
+	if err := s.conn.Close(); err != nil && s.err == nil {
+		s.err = err
+	}
+
+	return s.err
+}
+
+// node is a file, a directory or a symbolic link, identified by its path.
+type node struct {
+	fs   *FS
+	path string
+}
+
+func (n *node) child(name string) *node {
+	return &node{fs: n.fs, path: n.fs.fs.Join(n.path, name)}
+}
+
+func (n *node) isRoot() bool {
+	return n.path == string(filepath.Separator)
+}
+
+func (n *node) Attr(ctx context.Context, attr *fuse.Attr) error {
+	fi, err := n.fs.fs.Lstat(n.path)
+	if err != nil {
+		// Some filesystems, like an empty memfs, have no root until
+		// something is created in it.
+		if n.isRoot() && os.IsNotExist(err) {
+			attr.Mode = os.ModeDir | 0o755
+			attr.Valid = attrValid
+			return nil
+		}
+		return errno(err)
+	}
+
+	fillAttr(attr, fi)
+	return nil
+}
+
+func fillAttr(attr *fuse.Attr, fi os.FileInfo) {
+	attr.Valid = attrValid
+	attr.Mode = fi.Mode()
+	attr.Size = uint64(fi.Size())
+	attr.Blocks = (attr.Size + 511) / 512
+	attr.Mtime = fi.ModTime()
+	attr.Ctime = fi.ModTime()
+	attr.Atime = fi.ModTime()
+	attr.Nlink = 1
+}
+
+func (n *node) Lookup(ctx context.Context, name string) (fusefs.Node, error) {
+	c := n.child(name)
+	if _, err := n.fs.fs.Lstat(c.path); err != nil {
+		return nil, errno(err)
+	}
+
+	return c, nil
+}
+
+func (n *node) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	fis, err := n.fs.fs.ReadDir(n.path)
+	if err != nil {
+		if n.isRoot() && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errno(err)
+	}
+
+	dirents := make([]fuse.Dirent, len(fis))
+	for i, fi := range fis {
+		dirents[i] = fuse.Dirent{Name: fi.Name(), Type: direntType(fi.Mode())}
+	}
+
+	return dirents, nil
+}
+
+func direntType(mode os.FileMode) fuse.DirentType {
+	switch {
+	case mode.IsDir():
+		return fuse.DT_Dir
+	case mode&os.ModeSymlink != 0:
+		return fuse.DT_Link
+	case mode.IsRegular():
+		return fuse.DT_File
+	default:
+		return fuse.DT_Unknown
+	}
+}
+
+// Open opens the file for the handle reading or writing it. The directories
+// are their own handle, listing their entries.
+func (n *node) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fusefs.Handle, error) {
+	if req.Dir {
+		return n, nil
+	}
+
+	flag := int(req.Flags) & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_TRUNC)
+	f, err := n.fs.fs.OpenFile(n.path, flag, 0)
+	if err != nil {
+		return nil, errno(err)
+	}
+
+	return &handle{f: f}, nil
+}
+
+func (n *node) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fusefs.Node, fusefs.Handle, error) {
+	c := n.child(req.Name)
+	flag := int(req.Flags) & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR | os.O_APPEND | os.O_TRUNC | os.O_EXCL)
+	f, err := n.fs.fs.OpenFile(c.path, flag|os.O_CREATE, req.Mode&^req.Umask)
+	if err != nil {
+		return nil, nil, errno(err)
+	}
+
+	return c, &handle{f: f}, nil
+}
+
+// Mkdir fails with EEXIST if the directory exists, billy.Dir having no
+// operation to create a single directory.
+func (n *node) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fusefs.Node, error) {
+	c := n.child(req.Name)
+	if _, err := n.fs.fs.Lstat(c.path); err == nil {
+		return nil, fuse.EEXIST
+	}
+
+	if err := n.fs.fs.MkdirAll(c.path, req.Mode&^req.Umask); err != nil {
+		return nil, errno(err)
+	}
+
+	return c, nil
+}
+
+func (n *node) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	return errno(n.fs.fs.Remove(n.child(req.Name).path))
+}
+
+func (n *node) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fusefs.Node) error {
+	dir, ok := newDir.(*node)
+	if !ok {
+		return fuse.Errno(syscall.EXDEV)
+	}
+
+	return errno(n.fs.fs.Rename(n.child(req.OldName).path, dir.child(req.NewName).path))
+}
+
+func (n *node) Symlink(ctx context.Context, req *fuse.SymlinkRequest) (fusefs.Node, error) {
+	c := n.child(req.NewName)
+	if err := n.fs.fs.Symlink(req.Target, c.path); err != nil {
+		return nil, errno(err)
+	}
+
+	return c, nil
+}
+
+func (n *node) Readlink(ctx context.Context, req *fuse.ReadlinkRequest) (string, error) {
+	target, err := n.fs.fs.Readlink(n.path)
+	if err != nil {
+		return "", errno(err)
+	}
+
+	return target, nil
+}
+
+// Setattr truncates the file to the size requested, and changes its mode
+// and times with billy.Change.
+func (n *node) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Size() {
+		if err := n.truncate(int64(req.Size)); err != nil {
+			return errno(err)
+		}
+	}
+
+	if req.Valid.Mode() || req.Valid.Mtime() || req.Valid.Atime() {
+		ch, ok := n.fs.fs.(billy.Change)
+		if !ok {
+			return fuse.Errno(syscall.ENOTSUP)
+		}
+
+		if req.Valid.Mode() {
+			if err := ch.Chmod(n.path, req.Mode.Perm()); err != nil {
+				return errno(err)
+			}
+		}
+		if req.Valid.Mtime() || req.Valid.Atime() {
+			if err := n.chtimes(ch, req); err != nil {
+				return errno(err)
+			}
+		}
+	}
+
+	return n.Attr(ctx, &resp.Attr)
+}
+
+func (n *node) truncate(size int64) error {
+	f, err := n.fs.fs.OpenFile(n.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	err = f.Truncate(size)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// chtimes sets the times requested, keeping the current ones for the times
+// not given.
+func (n *node) chtimes(ch billy.Change, req *fuse.SetattrRequest) error {
+	atime, mtime := req.Atime, req.Mtime
+	if !req.Valid.Atime() || !req.Valid.Mtime() {
+		fi, err := n.fs.fs.Stat(n.path)
+		if err != nil {
+			return err
+		}
+		if !req.Valid.Atime() {
+			atime = fi.ModTime()
+		}
+		if !req.Valid.Mtime() {
+			mtime = fi.ModTime()
+		}
+	}
+
+	return ch.Chtimes(n.path, atime, mtime)
+}
+
+// handle is an open file. billy.File having no WriteAt, the writes seek to
+// their offset first, under a lock.
+type handle struct {
+	m sync.Mutex
+	f billy.File
+}
+
+func (h *handle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	buf := make([]byte, req.Size)
+	n, err := h.f.ReadAt(buf, req.Offset)
+	if err != nil && err != io.EOF {
+		return errno(err)
+	}
+
+	resp.Data = buf[:n]
+	return nil
+}
+
+func (h *handle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, err := h.f.Seek(req.Offset, io.SeekStart); err != nil {
+		return errno(err)
+	}
+
+	n, err := h.f.Write(req.Data)
+	resp.Size = n
+	return errno(err)
+}
+
+func (h *handle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	return errno(h.f.Close())
+}
+
+// errno returns the fuse.Errno reporting err to the kernel.
+func errno(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e syscall.Errno
+	switch {
+	case errors.As(err, &e):
+		return fuse.Errno(e)
+	case errors.Is(err, billy.ErrReadOnly):
+		return fuse.Errno(syscall.EROFS)
+	case errors.Is(err, billy.ErrNotSupported):
+		return fuse.Errno(syscall.ENOTSUP)
+	case errors.Is(err, billy.ErrCrossedBoundary):
+		return fuse.EPERM
+	case errors.Is(err, os.ErrNotExist):
+		return fuse.ENOENT
+	case errors.Is(err, os.ErrExist):
+		return fuse.EEXIST
+	case errors.Is(err, os.ErrPermission):
+		return fuse.Errno(syscall.EACCES)
+	case errors.Is(err, os.ErrClosed):
+		return fuse.Errno(syscall.EBADF)
+	default:
+		return fuse.EIO
+	}
+}
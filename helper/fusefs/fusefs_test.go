@@ -0,0 +1,128 @@
+//go:build fuse && linux
+// +build fuse,linux
+
+package fusefs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"bazil.org/fuse"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestNodes(t *testing.T) {
+	mem := memfs.New()
+	fs := New(mem)
+	ctx := context.Background()
+
+	root, err := fs.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := root.(*node)
+
+	var attr fuse.Attr
+	if err := dir.Attr(ctx, &attr); err != nil || !attr.Mode.IsDir() {
+		t.Fatalf("empty root: got %s, %v", attr.Mode, err)
+	}
+
+	if _, err := dir.Mkdir(ctx, &fuse.MkdirRequest{Name: "dir", Mode: os.ModeDir | 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.Mkdir(ctx, &fuse.MkdirRequest{Name: "dir", Mode: os.ModeDir | 0o755}); err != fuse.EEXIST {
+		t.Errorf("got %v, want EEXIST", err)
+	}
+
+	n, h, err := dir.Create(ctx, &fuse.CreateRequest{Name: "file", Flags: fuse.OpenReadWrite, Mode: 0o644}, &fuse.CreateResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &fuse.WriteResponse{}
+	if err := h.(*handle).Write(ctx, &fuse.WriteRequest{Offset: 3, Data: []byte("bar")}, w); err != nil || w.Size != 3 {
+		t.Fatalf("got %d, %v", w.Size, err)
+	}
+	if err := h.(*handle).Write(ctx, &fuse.WriteRequest{Data: []byte("foo")}, w); err != nil {
+		t.Fatal(err)
+	}
+	r := &fuse.ReadResponse{}
+	if err := h.(*handle).Read(ctx, &fuse.ReadRequest{Offset: 2, Size: 10}, r); err != nil || string(r.Data) != "obar" {
+		t.Errorf("got %q, %v", r.Data, err)
+	}
+	if err := h.(*handle).Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	sr := &fuse.SetattrResponse{}
+	if err := n.(*node).Setattr(ctx, &fuse.SetattrRequest{Valid: fuse.SetattrSize, Size: 2}, sr); err != nil || sr.Attr.Size != 2 {
+		t.Errorf("got size %d, %v", sr.Attr.Size, err)
+	}
+
+	if err := dir.Rename(ctx, &fuse.RenameRequest{OldName: "file", NewName: "moved"}, dir.child("dir")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(mem, "dir/moved"); err != nil || string(got) != "fo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	dirents, err := dir.ReadDirAll(ctx)
+	if err != nil || len(dirents) != 1 || dirents[0].Name != "dir" || dirents[0].Type != fuse.DT_Dir {
+		t.Errorf("got %v, %v", dirents, err)
+	}
+
+	if _, err := dir.Lookup(ctx, "missing"); err != fuse.ENOENT {
+		t.Errorf("got %v, want ENOENT", err)
+	}
+	if err := dir.Remove(ctx, &fuse.RemoveRequest{Name: "dir", Dir: true}); err == nil {
+		t.Error("expected removing a non-empty directory to fail")
+	}
+}
+
+func TestErrno(t *testing.T) {
+	for err, want := range map[error]error{
+		billy.ErrReadOnly: fuse.Errno(syscall.EROFS),
+		&os.PathError{Op: "open", Err: os.ErrNotExist}:     fuse.ENOENT,
+		&os.PathError{Op: "mkdir", Err: os.ErrExist}:       fuse.EEXIST,
+		&os.LinkError{Op: "rename", Err: os.ErrPermission}: fuse.Errno(syscall.EACCES),
+	} {
+		if got := errno(err); got != want {
+			t.Errorf("%v: got %v, want %v", err, got, want)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip("FUSE not available:", err)
+	}
+
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "dir/file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	s, err := Mount(mem, dir)
+	if err != nil {
+		t.Skip("mounting failed:", err)
+	}
+	defer s.Unmount()
+
+	got, err := os.ReadFile(filepath.Join(dir, "dir", "file"))
+	if err != nil || string(got) != "foo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "dir", "new"), []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(mem, "dir/new"); err != nil || string(got) != "bar" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
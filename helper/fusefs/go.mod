@@ -0,0 +1,12 @@
+module github.com/go-git/go-billy/v5/helper/fusefs
+
+go 1.19
+
+require (
+	bazil.org/fuse v0.0.0-20200117225306-7b5117fecadc
+	github.com/go-git/go-billy/v5 v5.6.0
+)
+
+require github.com/opencontainers/go-digest v1.0.0 // indirect
+
+replace github.com/go-git/go-billy/v5 => ../..
@@ -0,0 +1,436 @@
+// Package hashname provides a billy filesystem wrapper which transparently
+// shortens path components that exceed the limits of the underlying storage.
+package hashname // import "github.com/go-git/go-billy/v5/helper/hashname"
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+const (
+	// DefaultMaxNameLength is the maximum length in bytes of a single path
+	// component on most filesystems.
+	DefaultMaxNameLength = 255
+	// DefaultMaxPathLength is the maximum length in bytes of a path on Linux.
+	DefaultMaxPathLength = 4096
+	// DefaultMappingFile is the name of the file, at the root of the
+	// underlying filesystem, in which the original names are recorded.
+	DefaultMappingFile = ".hashnames"
+
+	hashPrefix    = "~"
+	hashLength    = 32
+	maxExtLength  = 16
+	mappingFormat = "%s\t%s\n"
+)
+
+// Options configures a HashName filesystem. The zero value uses the
+// default limits, SHA-256 and the default mapping file.
+type Options struct {
+	// MaxNameLength is the maximum length of a path component.
+	MaxNameLength int
+	// MaxPathLength is the maximum length of a full path.
+	MaxPathLength int
+	// Hash is used to derive the short names.
+	Hash func() hash.Hash
+	// MappingFile is the name of the file where the mapping between short
+	// and original names is kept.
+	MappingFile string
+}
+
+// HashName is a filesystem wrapper which replaces path components longer
+// than the configured limit by a fixed length digest of the original name.
+// The original names are recorded in a mapping file so that ReadDir and
+// File.Name return them unchanged.
+type HashName struct {
+	underlying billy.Filesystem
+	opts       Options
+
+	m       sync.Mutex
+	mapping map[string]string
+}
+
+// New creates a new filesystem wrapping up the given 'fs'. The mapping file
+// is loaded from fs, if it exists.
+func New(fs billy.Filesystem, opts Options) (billy.Filesystem, error) {
+	if opts.MaxNameLength <= 0 {
+		opts.MaxNameLength = DefaultMaxNameLength
+	}
+	if opts.MaxPathLength <= 0 {
+		opts.MaxPathLength = DefaultMaxPathLength
+	}
+	if opts.Hash == nil {
+		opts.Hash = sha256.New
+	}
+	if opts.MappingFile == "" {
+		opts.MappingFile = DefaultMappingFile
+	}
+
+	h := &HashName{
+		underlying: fs,
+		opts:       opts,
+		mapping:    make(map[string]string),
+	}
+
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *HashName) load() error {
+	f, err := h.underlying.Open(h.opts.MappingFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		short, quoted, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+
+		original, err := strconv.Unquote(quoted)
+		if err != nil {
+			return fmt.Errorf("hashname: invalid mapping entry %q: %w", scanner.Text(), err)
+		}
+
+		h.mapping[short] = original
+	}
+
+	return scanner.Err()
+}
+
+func (h *HashName) record(short, original string) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if _, ok := h.mapping[short]; ok {
+		return nil
+	}
+
+	f, err := h.underlying.OpenFile(h.opts.MappingFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, mappingFormat, short, strconv.Quote(original))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	h.mapping[short] = original
+	return nil
+}
+
+func (h *HashName) shorten(name string) string {
+	d := h.opts.Hash()
+	d.Write([]byte(name))
+	sum := hex.EncodeToString(d.Sum(nil))
+	if len(sum) > hashLength {
+		sum = sum[:hashLength]
+	}
+
+	short := hashPrefix + sum
+	if ext := filepath.Ext(name); len(ext) <= maxExtLength {
+		short += ext
+	}
+
+	return short
+}
+
+// encode returns the path to be used on the underlying filesystem. If
+// persist is true, the new short names are recorded in the mapping file.
+func (h *HashName) encode(path string, persist bool) (string, error) {
+	parts := strings.Split(filepath.Clean(path), string(filepath.Separator))
+
+	shortened := make(map[int]string)
+	length := len(path)
+	for i, p := range parts {
+		if len(p) > h.opts.MaxNameLength {
+			shortened[i] = h.shorten(p)
+			length += len(shortened[i]) - len(p)
+		}
+	}
+
+	if length > h.opts.MaxPathLength {
+		// Shorten the longest remaining components until the path fits.
+		idx := make([]int, 0, len(parts))
+		for i := range parts {
+			if _, ok := shortened[i]; !ok {
+				idx = append(idx, i)
+			}
+		}
+		sort.SliceStable(idx, func(a, b int) bool {
+			return len(parts[idx[a]]) > len(parts[idx[b]])
+		})
+
+		for _, i := range idx {
+			if length <= h.opts.MaxPathLength {
+				break
+			}
+
+			short := h.shorten(parts[i])
+			if len(short) >= len(parts[i]) {
+				break
+			}
+
+			shortened[i] = short
+			length += len(short) - len(parts[i])
+		}
+
+		if length > h.opts.MaxPathLength {
+			return "", &os.PathError{Op: "hashname", Path: path, Err: syscall.ENAMETOOLONG}
+		}
+	}
+
+	for i, short := range shortened {
+		if persist {
+			if err := h.record(short, parts[i]); err != nil {
+				return "", err
+			}
+		}
+		parts[i] = short
+	}
+
+	return strings.Join(parts, string(filepath.Separator)), nil
+}
+
+// decode returns the original name of a path component.
+func (h *HashName) decode(name string) string {
+	if !strings.HasPrefix(name, hashPrefix) {
+		return name
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if original, ok := h.mapping[name]; ok {
+		return original
+	}
+
+	return name
+}
+
+func (h *HashName) decodePath(path string) string {
+	parts := strings.Split(path, string(filepath.Separator))
+	for i, p := range parts {
+		parts[i] = h.decode(p)
+	}
+
+	return strings.Join(parts, string(filepath.Separator))
+}
+
+func isRoot(path string) bool {
+	path = filepath.Clean(path)
+	return path == "." || path == string(filepath.Separator)
+}
+
+func (h *HashName) Create(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (h *HashName) Open(filename string) (billy.File, error) {
+	return h.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (h *HashName) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fullpath, err := h.encode(filename, flag&os.O_CREATE != 0)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := h.underlying.OpenFile(fullpath, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: h.decodePath(f.Name())}, nil
+}
+
+func (h *HashName) Stat(filename string) (os.FileInfo, error) {
+	fullpath, err := h.encode(filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := h.underlying.Stat(fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.fileInfo(fi), nil
+}
+
+func (h *HashName) Lstat(filename string) (os.FileInfo, error) {
+	fullpath, err := h.encode(filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := h.underlying.Lstat(fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.fileInfo(fi), nil
+}
+
+func (h *HashName) Rename(from, to string) error {
+	f, err := h.encode(from, false)
+	if err != nil {
+		return err
+	}
+
+	t, err := h.encode(to, true)
+	if err != nil {
+		return err
+	}
+
+	return h.underlying.Rename(f, t)
+}
+
+func (h *HashName) Remove(filename string) error {
+	fullpath, err := h.encode(filename, false)
+	if err != nil {
+		return err
+	}
+
+	return h.underlying.Remove(fullpath)
+}
+
+func (h *HashName) Join(elem ...string) string {
+	return h.underlying.Join(elem...)
+}
+
+func (h *HashName) TempFile(dir, prefix string) (billy.File, error) {
+	fullpath, err := h.encode(dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := h.underlying.TempFile(fullpath, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: h.decodePath(f.Name())}, nil
+}
+
+func (h *HashName) ReadDir(path string) ([]os.FileInfo, error) {
+	fullpath, err := h.encode(path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := h.underlying.ReadDir(fullpath)
+	if err != nil {
+		return nil, err
+	}
+
+	root := isRoot(path)
+	entries := make([]os.FileInfo, 0, len(infos))
+	for _, fi := range infos {
+		if root && fi.Name() == h.opts.MappingFile {
+			continue
+		}
+
+		entries = append(entries, h.fileInfo(fi))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (h *HashName) MkdirAll(filename string, perm os.FileMode) error {
+	fullpath, err := h.encode(filename, true)
+	if err != nil {
+		return err
+	}
+
+	return h.underlying.MkdirAll(fullpath, perm)
+}
+
+func (h *HashName) Symlink(target, link string) error {
+	fullpath, err := h.encode(link, true)
+	if err != nil {
+		return err
+	}
+
+	return h.underlying.Symlink(target, fullpath)
+}
+
+func (h *HashName) Readlink(link string) (string, error) {
+	fullpath, err := h.encode(link, false)
+	if err != nil {
+		return "", err
+	}
+
+	return h.underlying.Readlink(fullpath)
+}
+
+func (h *HashName) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(h, path), nil
+}
+
+func (h *HashName) Root() string {
+	return h.underlying.Root()
+}
+
+// Capabilities implements the Capable interface. The optional interfaces
+// aren't forwarded, the names they take would have to be encoded too.
+func (h *HashName) Capabilities() billy.Capability {
+	return billy.Capabilities(h.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (h *HashName) fileInfo(fi os.FileInfo) os.FileInfo {
+	name := h.decode(fi.Name())
+	if name == fi.Name() {
+		return fi
+	}
+
+	return &fileInfo{FileInfo: fi, name: name}
+}
+
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+type fileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
@@ -0,0 +1,98 @@
+package hashname
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		fs, err := New(memfs.New(), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+func TestLongName(t *testing.T) {
+	underlying := memfs.New()
+	fs, err := New(underlying, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("a", 300) + ".yaml"
+	name := fs.Join("dir", long)
+	if err := util.WriteFile(fs, name, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := underlying.ReadDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || len(infos[0].Name()) > DefaultMaxNameLength || !strings.HasSuffix(infos[0].Name(), ".yaml") {
+		t.Fatalf("unexpected underlying entries: %v", infos)
+	}
+
+	infos, err = fs.ReadDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != long {
+		t.Fatalf("expected original name, got %v", infos)
+	}
+
+	// The mapping must survive a new wrapper over the same storage.
+	fs, err = New(underlying, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := util.ReadFile(fs, name)
+	if err != nil || string(content) != "foo" {
+		t.Fatalf("ReadFile = %q, %v", content, err)
+	}
+
+	fi, err := fs.Stat(name)
+	if err != nil || fi.Name() != long {
+		t.Fatalf("Stat = %v, %v", fi, err)
+	}
+
+	root, err := fs.ReadDir("/")
+	if err != nil || len(root) != 1 || root[0].Name() != "dir" {
+		t.Fatalf("mapping file must be hidden, got %v, %v", root, err)
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	fs, err := New(memfs.New(), Options{MaxNameLength: 64, MaxPathLength: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := fs.Join(strings.Repeat("a", 60), strings.Repeat("b", 60), strings.Repeat("c", 60))
+	if err := util.WriteFile(fs, name, []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := util.ReadFile(fs, name)
+	if err != nil || string(content) != "foo" {
+		t.Fatalf("ReadFile = %q, %v", content, err)
+	}
+
+	name = strings.Repeat(fs.Join("a", "b")+"/", 100)
+	_, err = fs.Create(name)
+	if !errors.Is(err, syscall.ENAMETOOLONG) {
+		t.Errorf("expected ENAMETOOLONG, got %v", err)
+	}
+}
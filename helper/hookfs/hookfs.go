@@ -0,0 +1,356 @@
+// Package hookfs provides a billy filesystem wrapper calling registered
+// functions before and after its operations, to veto some of them or to
+// observe their outcome, without modifying the wrapped filesystem.
+package hookfs // import "github.com/go-git/go-billy/v5/helper/hookfs"
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+)
+
+// Op identifies an operation of the filesystem.
+type Op string
+
+// The operations reported to the hooks. Create and Open are reported as
+// OpOpenFile, with the flags they imply, and Lstat as OpStat.
+const (
+	OpOpenFile    Op = "openfile"
+	OpStat        Op = "stat"
+	OpReadDir     Op = "readdir"
+	OpReadlink    Op = "readlink"
+	OpRename      Op = "rename"
+	OpRemove      Op = "remove"
+	OpTempFile    Op = "tempfile"
+	OpCreateTemp  Op = "createtemp"
+	OpMkdirTemp   Op = "mkdirtemp"
+	OpMkdirAll    Op = "mkdirall"
+	OpSymlink     Op = "symlink"
+	OpLink        Op = "link"
+	OpChmod       Op = "chmod"
+	OpChown       Op = "chown"
+	OpChtimes     Op = "chtimes"
+	OpSetxattr    Op = "setxattr"
+	OpRemovexattr Op = "removexattr"
+	OpExchange    Op = "exchange"
+)
+
+// Call describes an operation given to the hooks.
+type Call struct {
+	Op Op
+	// Path is the name the operation applies to: the source of a rename,
+	// the link created by Symlink and Link, the directory given to TempFile,
+	// CreateTemp and MkdirTemp. Their after hooks get the name of the created
+	// file or directory instead.
+	Path string
+	// Target is the destination of a rename, the target of a link, or the
+	// second path given to Exchange.
+	Target string
+	// Flag holds the flags of OpOpenFile.
+	Flag int
+	// Perm holds the permissions of OpOpenFile, OpMkdirAll and OpChmod.
+	Perm os.FileMode
+	// Attr holds the extended attribute of OpSetxattr and OpRemovexattr.
+	Attr string
+}
+
+// Write reports whether the call may modify the filesystem: every operation
+// other than the stats, reads and lookups, and OpOpenFile only with flags
+// allowing to create or modify the file.
+func (c *Call) Write() bool {
+	switch c.Op {
+	case OpStat, OpReadDir, OpReadlink:
+		return false
+	case OpOpenFile:
+		return c.Flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	}
+
+	return true
+}
+
+// BeforeFunc is called before an operation. Returning an error vetoes it:
+// the operation fails with the error, wrapped in an *os.PathError, and the
+// remaining hooks aren't called.
+type BeforeFunc func(c *Call) error
+
+// AfterFunc is called after an operation, with its error. It is called for
+// the operations vetoed by a BeforeFunc.
+type AfterFunc func(c *Call, err error)
+
+// FS is a billy.Filesystem calling its hooks around the calls to the
+// wrapped filesystem, in the order they were added. Hooks may be added at
+// any time, and must be safe for concurrent use if the filesystem is.
+type FS struct {
+	wrapper.Base
+
+	m      sync.RWMutex
+	before []beforeHook
+	after  []afterHook
+}
+
+type beforeHook struct {
+	fn  BeforeFunc
+	ops map[Op]bool
+}
+
+type afterHook struct {
+	fn  AfterFunc
+	ops map[Op]bool
+}
+
+// New creates a new filesystem wrapping up the given 'fs', without hooks.
+func New(fs billy.Filesystem) *FS {
+	return &FS{Base: wrapper.NewBase(fs)}
+}
+
+// Before adds fn to the hooks called before the given operations, or before
+// all of them if none is given.
+func (fs *FS) Before(fn BeforeFunc, ops ...Op) {
+	fs.m.Lock()
+	fs.before = append(fs.before, beforeHook{fn: fn, ops: opSet(ops)})
+	fs.m.Unlock()
+}
+
+// After adds fn to the hooks called after the given operations, or after
+// all of them if none is given.
+func (fs *FS) After(fn AfterFunc, ops ...Op) {
+	fs.m.Lock()
+	fs.after = append(fs.after, afterHook{fn: fn, ops: opSet(ops)})
+	fs.m.Unlock()
+}
+
+func opSet(ops []Op) map[Op]bool {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	set := make(map[Op]bool, len(ops))
+	for _, op := range ops {
+		set[op] = true
+	}
+
+	return set
+}
+
+// run calls the before hooks of c, then fn if none of them failed, then the
+// after hooks.
+func (fs *FS) run(c *Call, fn func() error) error {
+	fs.m.RLock()
+	before, after := fs.before, fs.after
+	fs.m.RUnlock()
+
+	var err error
+	for _, h := range before {
+		if h.ops != nil && !h.ops[c.Op] {
+			continue
+		}
+		if err = h.fn(c); err != nil {
+			err = &os.PathError{Op: string(c.Op), Path: c.Path, Err: err}
+			break
+		}
+	}
+
+	if err == nil {
+		err = fn()
+	}
+
+	for _, h := range after {
+		if h.ops == nil || h.ops[c.Op] {
+			h.fn(c, err)
+		}
+	}
+
+	return err
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	var f billy.File
+	err := fs.run(&Call{Op: OpOpenFile, Path: filename, Flag: flag, Perm: perm}, func() (err error) {
+		f, err = fs.Base.OpenFile(filename, flag, perm)
+		return err
+	})
+
+	return f, err
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := fs.run(&Call{Op: OpStat, Path: filename}, func() (err error) {
+		fi, err = fs.Base.Stat(filename)
+		return err
+	})
+
+	return fi, err
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := fs.run(&Call{Op: OpStat, Path: filename}, func() (err error) {
+		fi, err = fs.Base.Lstat(filename)
+		return err
+	})
+
+	return fi, err
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	err := fs.run(&Call{Op: OpReadDir, Path: path}, func() (err error) {
+		infos, err = fs.Base.ReadDir(path)
+		return err
+	})
+
+	return infos, err
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	var target string
+	err := fs.run(&Call{Op: OpReadlink, Path: link}, func() (err error) {
+		target, err = fs.Base.Readlink(link)
+		return err
+	})
+
+	return target, err
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return fs.run(&Call{Op: OpRename, Path: from, Target: to}, func() error {
+		return fs.Base.Rename(from, to)
+	})
+}
+
+func (fs *FS) Remove(filename string) error {
+	return fs.run(&Call{Op: OpRemove, Path: filename}, func() error {
+		return fs.Base.Remove(filename)
+	})
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	var f billy.File
+	c := &Call{Op: OpTempFile, Path: dir}
+	err := fs.run(c, func() (err error) {
+		f, err = fs.Base.TempFile(dir, prefix)
+		if err == nil {
+			c.Path = f.Name()
+		}
+		return err
+	})
+
+	return f, err
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	var f billy.File
+	c := &Call{Op: OpCreateTemp, Path: dir}
+	err := fs.run(c, func() (err error) {
+		f, err = fs.Base.CreateTemp(dir, pattern)
+		if err == nil {
+			c.Path = f.Name()
+		}
+		return err
+	})
+
+	return f, err
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	var name string
+	c := &Call{Op: OpMkdirTemp, Path: dir}
+	err := fs.run(c, func() (err error) {
+		name, err = fs.Base.MkdirTemp(dir, pattern)
+		if err == nil {
+			c.Path = name
+		}
+		return err
+	})
+
+	return name, err
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.run(&Call{Op: OpMkdirAll, Path: filename, Perm: perm}, func() error {
+		return fs.Base.MkdirAll(filename, perm)
+	})
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return fs.run(&Call{Op: OpSymlink, Path: link, Target: target}, func() error {
+		return fs.Base.Symlink(target, link)
+	})
+}
+
+// Link implements billy.Linker.
+func (fs *FS) Link(oldname, newname string) error {
+	return fs.run(&Call{Op: OpLink, Path: newname, Target: oldname}, func() error {
+		return fs.Base.Link(oldname, newname)
+	})
+}
+
+// Chmod implements billy.Change.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	return fs.run(&Call{Op: OpChmod, Path: name, Perm: mode}, func() error {
+		return fs.Base.Chmod(name, mode)
+	})
+}
+
+// Chown implements billy.Change.
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.run(&Call{Op: OpChown, Path: name}, func() error {
+		return fs.Base.Chown(name, uid, gid)
+	})
+}
+
+// Lchown implements billy.Change, reported as OpChown.
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	return fs.run(&Call{Op: OpChown, Path: name}, func() error {
+		return fs.Base.Lchown(name, uid, gid)
+	})
+}
+
+// Chtimes implements billy.Change.
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.run(&Call{Op: OpChtimes, Path: name}, func() error {
+		return fs.Base.Chtimes(name, atime, mtime)
+	})
+}
+
+// Setxattr implements billy.Xattrer.
+func (fs *FS) Setxattr(name, attr string, value []byte) error {
+	return fs.run(&Call{Op: OpSetxattr, Path: name, Attr: attr}, func() error {
+		return fs.Base.Setxattr(name, attr, value)
+	})
+}
+
+// Removexattr implements billy.Xattrer.
+func (fs *FS) Removexattr(name, attr string) error {
+	return fs.run(&Call{Op: OpRemovexattr, Path: name, Attr: attr}, func() error {
+		return fs.Base.Removexattr(name, attr)
+	})
+}
+
+// Exchange swaps the paths a and b, if supported by the wrapped filesystem.
+func (fs *FS) Exchange(a, b string) error {
+	return fs.run(&Call{Op: OpExchange, Path: a, Target: b}, func() error {
+		return fs.Base.Exchange(a, b)
+	})
+}
+
+// Chroot returns a chrooted view of fs; the hooks get the paths as seen from
+// the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
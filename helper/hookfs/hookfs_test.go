@@ -0,0 +1,158 @@
+package hookfs
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		fs := New(memfs.New())
+		fs.Before(func(*Call) error { return nil })
+		fs.After(func(*Call, error) {})
+		return fs
+	})
+}
+
+func TestVeto(t *testing.T) {
+	errReadOnly := errors.New("read-only")
+
+	fs := New(memfs.New())
+	fs.Before(func(c *Call) error {
+		if c.Write() && strings.HasPrefix(c.Path, "locked") {
+			return errReadOnly
+		}
+		return nil
+	})
+
+	var vetoed []Op
+	fs.After(func(c *Call, err error) {
+		if errors.Is(err, errReadOnly) {
+			vetoed = append(vetoed, c.Op)
+		}
+	})
+
+	if err := util.WriteFile(fs, "file", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := util.WriteFile(fs, "locked/file", nil, 0o644)
+	if pe, ok := err.(*os.PathError); !ok || pe.Op != "openfile" || pe.Err != errReadOnly {
+		t.Errorf("expected the veto, got %v", err)
+	}
+	if err := fs.MkdirAll("locked", 0o755); !errors.Is(err, errReadOnly) {
+		t.Errorf("expected the veto, got %v", err)
+	}
+	if err := fs.Rename("file", "locked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.ReadFile(fs, "locked"); err != nil {
+		t.Errorf("reads aren't vetoed: %v", err)
+	}
+
+	want := []Op{OpOpenFile, OpMkdirAll}
+	if !reflect.DeepEqual(vetoed, want) {
+		t.Errorf("expected %v, got %v", want, vetoed)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	fs := New(memfs.New())
+
+	var renames []string
+	fs.After(func(c *Call, err error) {
+		if err == nil {
+			renames = append(renames, c.Path+" -> "+c.Target)
+		}
+	}, OpRename)
+
+	var temp string
+	fs.After(func(c *Call, err error) {
+		temp = c.Path
+	}, OpTempFile)
+
+	if err := util.WriteFile(fs, "dir/a", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dir/a", "dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dir/a", "dir/c"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	sub, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Rename("b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"dir/a -> dir/b", "dir/b -> dir/c"}
+	if !reflect.DeepEqual(renames, want) {
+		t.Errorf("expected %v, got %v", want, renames)
+	}
+
+	f, err := fs.TempFile("dir", "tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if temp != f.Name() {
+		t.Errorf("expected %q, got %q", f.Name(), temp)
+	}
+}
+
+func TestTempCreator(t *testing.T) {
+	errReadOnly := errors.New("read-only")
+
+	fs := New(memfs.New())
+	fs.Before(func(c *Call) error {
+		if c.Path == "locked" {
+			return errReadOnly
+		}
+		return nil
+	}, OpCreateTemp, OpMkdirTemp)
+
+	var created []string
+	fs.After(func(c *Call, err error) {
+		if err == nil {
+			created = append(created, c.Path)
+		}
+	}, OpCreateTemp, OpMkdirTemp)
+
+	f, err := fs.CreateTemp("dir", "tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	name, err := fs.MkdirTemp("dir", "tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{f.Name(), name}
+	if !reflect.DeepEqual(created, want) {
+		t.Errorf("expected %v, got %v", want, created)
+	}
+
+	if _, err := fs.CreateTemp("locked", "tmp-*"); !errors.Is(err, errReadOnly) {
+		t.Errorf("expected the veto, got %v", err)
+	}
+	if _, err := fs.MkdirTemp("locked", "tmp-*"); !errors.Is(err, errReadOnly) {
+		t.Errorf("expected the veto, got %v", err)
+	}
+	if _, err := fs.Stat("locked"); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be created, got %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+// Package instrumented provides a billy filesystem wrapper exposing
+// Prometheus metrics about the operations performed on it.
+//
+// It is a module of its own, so that the users of billy don't depend on the
+// Prometheus client.
+package instrumented // import "github.com/go-git/go-billy/v5/helper/instrumented"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric names.
+const (
+	OperationsMetric = "billy_fs_operations_total"
+	ErrorsMetric     = "billy_fs_operation_errors_total"
+	DurationMetric   = "billy_fs_operation_duration_seconds"
+)
+
+type metrics struct {
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+}
+
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: OperationsMetric,
+			Help: "Number of filesystem operations, by operation.",
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: ErrorsMetric,
+			Help: "Number of failed filesystem operations, by operation.",
+		}, []string{"op"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    DurationMetric,
+			Help:    "Duration of the filesystem operations, by operation.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"op"}),
+	}
+
+	if reg == nil {
+		return m, nil
+	}
+
+	var ok bool
+	c, err := register(reg, m.operations)
+	if err == nil {
+		m.operations, ok = c.(*prometheus.CounterVec)
+	}
+	if err == nil && ok {
+		c, err = register(reg, m.errors)
+		m.errors, ok = c.(*prometheus.CounterVec)
+	}
+	if err == nil && ok {
+		c, err = register(reg, m.duration)
+		m.duration, ok = c.(*prometheus.HistogramVec)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("instrumented: conflicting collector already registered")
+	}
+
+	return m, nil
+}
+
+// register registers c, or returns the collector already registered in its
+// place, so that several filesystems share the same metrics.
+func register(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector, nil
+	}
+
+	return c, err
+}
+
+func (m *metrics) observe(op string, start time.Time, err error) {
+	m.operations.WithLabelValues(op).Inc()
+	m.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(op).Inc()
+	}
+}
+
+// FS is a billy.Filesystem which forwards every call to the wrapped
+// filesystem, recording its count, error and duration.
+type FS struct {
+	underlying billy.Filesystem
+	m          *metrics
+}
+
+// New creates a new filesystem wrapping up the given 'fs', registering its
+// metrics in reg. Filesystems created with the same Registerer share their
+// metrics. If reg is nil the metrics are not registered.
+func New(fs billy.Filesystem, reg prometheus.Registerer) (*FS, error) {
+	m, err := newMetrics(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FS{underlying: fs, m: m}, nil
+}
+
+// Layer returns the billy.Layer of New, for billy.Compose.
+func Layer(reg prometheus.Registerer) billy.Layer {
+	return billy.Layer{
+		Name: "instrumented",
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs, reg)
+		},
+	}
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	start := time.Now()
+	f, err := fs.underlying.Create(filename)
+	return fs.wrap("create", start, f, err)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	start := time.Now()
+	f, err := fs.underlying.Open(filename)
+	return fs.wrap("open", start, f, err)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	start := time.Now()
+	f, err := fs.underlying.OpenFile(filename, flag, perm)
+	return fs.wrap("openfile", start, f, err)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	start := time.Now()
+	fi, err := fs.underlying.Stat(filename)
+	fs.m.observe("stat", start, err)
+	return fi, err
+}
+
+func (fs *FS) Rename(from, to string) error {
+	start := time.Now()
+	err := fs.underlying.Rename(from, to)
+	fs.m.observe("rename", start, err)
+	return err
+}
+
+func (fs *FS) Remove(filename string) error {
+	start := time.Now()
+	err := fs.underlying.Remove(filename)
+	fs.m.observe("remove", start, err)
+	return err
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	start := time.Now()
+	f, err := fs.underlying.TempFile(dir, prefix)
+	return fs.wrap("tempfile", start, f, err)
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	start := time.Now()
+	infos, err := fs.underlying.ReadDir(path)
+	fs.m.observe("readdir", start, err)
+	return infos, err
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	start := time.Now()
+	err := fs.underlying.MkdirAll(filename, perm)
+	fs.m.observe("mkdirall", start, err)
+	return err
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	start := time.Now()
+	fi, err := fs.underlying.Lstat(filename)
+	fs.m.observe("lstat", start, err)
+	return fi, err
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	start := time.Now()
+	err := fs.underlying.Symlink(target, link)
+	fs.m.observe("symlink", start, err)
+	return err
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	start := time.Now()
+	target, err := fs.underlying.Readlink(link)
+	fs.m.observe("readlink", start, err)
+	return target, err
+}
+
+// Chroot returns a chrooted view of fs, sharing its metrics.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, without
+// billy.InterfaceCapabilities as the optional interfaces aren't forwarded.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) wrap(op string, start time.Time, f billy.File, err error) (billy.File, error) {
+	fs.m.observe(op, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, m: fs.m}, nil
+}
+
+type file struct {
+	billy.File
+	m *metrics
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(p)
+	f.m.observe("read", start, ignoreEOF(err))
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := f.File.ReadAt(p, off)
+	f.m.observe("readat", start, ignoreEOF(err))
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Write(p)
+	f.m.observe("write", start, err)
+	return n, err
+}
+
+func (f *file) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.m.observe("close", start, err)
+	return err
+}
+
+func (f *file) Truncate(size int64) error {
+	start := time.Now()
+	err := f.File.Truncate(size)
+	f.m.observe("truncate", start, err)
+	return err
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package instrumented
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		fs, err := New(memfs.New(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	fs, err := New(memfs.New(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.WriteFile(fs, "foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("bar"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// A second filesystem shares the metrics of the first one.
+	other, err := New(memfs.New(), reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Stat("foo")
+
+	m := fs.m
+	if v := testutil.ToFloat64(m.operations.WithLabelValues("stat")); v != 3 {
+		t.Errorf("expected 3 stat operations, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.errors.WithLabelValues("stat")); v != 2 {
+		t.Errorf("expected 2 stat errors, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.operations.WithLabelValues("write")); v != 1 {
+		t.Errorf("expected 1 write operation, got %v", v)
+	}
+
+	if n := testutil.CollectAndCount(m.duration, DurationMetric); n != 4 {
+		t.Errorf("expected histograms for 4 operations, got %d", n)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	underlying := memfs.New()
+	fs, err := New(underlying, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := billy.Capabilities(underlying) &^ billy.InterfaceCapabilities
+	if caps := billy.Capabilities(fs); caps != expected {
+		t.Errorf("expected capabilities %b, got %b", expected, caps)
+	}
+	if _, ok := billy.Filesystem(fs).(billy.Change); ok {
+		t.Errorf("unexpected billy.Change implementation")
+	}
+}
@@ -0,0 +1,311 @@
+// Package iofs provides an adapter from billy.Filesystem to the io/fs
+// interfaces of the standard library.
+package iofs // import "github.com/go-git/go-billy/v5/helper/iofs"
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+var (
+	_ fs.GlobFS     = (*Adapter)(nil)
+	_ fs.ReadDirFS  = (*Adapter)(nil)
+	_ fs.ReadFileFS = (*Adapter)(nil)
+	_ fs.StatFS     = (*Adapter)(nil)
+	_ fs.SubFS      = (*Adapter)(nil)
+)
+
+// globber is implemented by filesystems with a native Glob.
+type globber interface {
+	Glob(pattern string) ([]string, error)
+}
+
+// fileReader is implemented by filesystems able to read a whole file more
+// efficiently than through Open and Read.
+type fileReader interface {
+	ReadFile(filename string) ([]byte, error)
+}
+
+// Adapter exposes a billy.Filesystem as a read-only fs.FS. Names are slash
+// separated and unrooted, as required by fs.ValidPath, and are resolved from
+// the root of the wrapped filesystem.
+type Adapter struct {
+	fs billy.Filesystem
+}
+
+// New returns an fs.FS backed by the given billy filesystem.
+func New(fs billy.Filesystem) *Adapter {
+	return &Adapter{fs: fs}
+}
+
+// Open implements fs.FS.
+func (a *Adapter) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	fi, err := a.fs.Stat(a.path(name))
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+
+	if fi.IsDir() {
+		return &dir{adapter: a, name: name, info: renamed(fi, name)}, nil
+	}
+
+	f, err := a.fs.Open(a.path(name))
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+
+	return &file{File: f, adapter: a, name: name}, nil
+}
+
+// Stat implements fs.StatFS.
+func (a *Adapter) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	fi, err := a.fs.Stat(a.path(name))
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+
+	return renamed(fi, name), nil
+}
+
+// ReadFile implements fs.ReadFileFS, using the ReadFile method of the
+// wrapped filesystem if available.
+func (a *Adapter) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	if r, ok := a.fs.(fileReader); ok {
+		content, err = r.ReadFile(a.path(name))
+	} else {
+		content, err = util.ReadFile(a.fs, a.path(name))
+	}
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+
+	return content, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (a *Adapter) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, err := a.readDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (a *Adapter) readDir(name string) ([]fs.DirEntry, error) {
+	infos, err := a.fs.ReadDir(a.path(name))
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, len(infos))
+	for i, fi := range infos {
+		entries[i] = fs.FileInfoToDirEntry(fi)
+	}
+
+	return entries, nil
+}
+
+// Glob implements fs.GlobFS, using the Glob method of the wrapped filesystem
+// if available, and util.Glob otherwise.
+func (a *Adapter) Glob(pattern string) ([]string, error) {
+	// Check the pattern is well formed, as fs.Glob does.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	// util.Glob extends the syntax of path.Match with braces and "**",
+	// which have no special meaning for fs.Glob.
+	if strings.Contains(pattern, "{") || strings.Contains(pattern, "**") {
+		return fs.Glob(readDirFS{a}, pattern)
+	}
+
+	var (
+		matches []string
+		err     error
+	)
+	if g, ok := a.fs.(globber); ok {
+		matches, err = g.Glob(filepath.FromSlash(pattern))
+	} else {
+		matches, err = util.Glob(a.fs, filepath.FromSlash(pattern))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range matches {
+		matches[i] = filepath.ToSlash(m)
+	}
+
+	return matches, nil
+}
+
+// readDirFS hides the Glob method of an Adapter, to use the generic
+// implementation of fs.Glob.
+type readDirFS struct {
+	fs.ReadDirFS
+}
+
+// Sub implements fs.SubFS, returning an Adapter for the chrooted filesystem.
+func (a *Adapter) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+
+	if dir == "." {
+		return a, nil
+	}
+
+	fi, err := a.fs.Stat(a.path(dir))
+	if err != nil {
+		return nil, pathError("sub", dir, err)
+	}
+	if !fi.IsDir() {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: errors.New("not a directory")}
+	}
+
+	sub, err := a.fs.Chroot(a.path(dir))
+	if err != nil {
+		return nil, pathError("sub", dir, err)
+	}
+
+	return New(sub), nil
+}
+
+func (a *Adapter) path(name string) string {
+	if name == "." {
+		return string(os.PathSeparator)
+	}
+
+	return a.fs.Join(string(os.PathSeparator), name)
+}
+
+type file struct {
+	billy.File
+	adapter *Adapter
+	name    string
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	fi, err := f.adapter.fs.Stat(f.adapter.path(f.name))
+	if err != nil {
+		return nil, pathError("stat", f.name, err)
+	}
+
+	return renamed(fi, f.name), nil
+}
+
+type dir struct {
+	adapter *Adapter
+	name    string
+	info    fs.FileInfo
+
+	entries []fs.DirEntry
+	offset  int
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.adapter.readDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries = entries
+		d.read = true
+	}
+
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+
+	return rest[:n], nil
+}
+
+// renamed makes sure the FileInfo reports the base name of the requested
+// path, as some backends report the name of the link target or the full
+// path.
+func renamed(fi fs.FileInfo, name string) fs.FileInfo {
+	base := path.Base(name)
+	if fi.Name() == base {
+		return fi
+	}
+
+	return &fileInfo{FileInfo: fi, name: base}
+}
+
+type fileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+func pathError(op, name string, err error) error {
+	var perr *fs.PathError
+	if errors.As(err, &perr) {
+		return &fs.PathError{Op: op, Path: name, Err: perr.Err}
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
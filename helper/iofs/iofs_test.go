@@ -0,0 +1,136 @@
+package iofs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestOpenInvalidPath(t *testing.T) {
+	a := New(memfs.New())
+	for _, name := range []string{"/foo", "../foo", "foo/", ""} {
+		if _, err := a.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q): expected ErrInvalid, got %v", name, err)
+		}
+	}
+}
+
+func TestOpenNotExist(t *testing.T) {
+	_, err := New(memfs.New()).Open("foo")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	bfs := memfs.New()
+	util.WriteFile(bfs, "dir/foo", []byte("foo"), 0644)
+
+	content, err := fs.ReadFile(New(bfs), "dir/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "foo" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestReadDirPaging(t *testing.T) {
+	bfs := memfs.New()
+	for _, name := range []string{"a", "b", "c"} {
+		util.WriteFile(bfs, "dir/"+name, nil, 0644)
+	}
+
+	f, err := New(bfs).Open("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	d := f.(fs.ReadDirFile)
+	var names []string
+	for {
+		entries, err := d.ReadDir(2)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+	}
+
+	if len(names) != 3 || names[0] != "a" || names[2] != "c" {
+		t.Errorf("unexpected entries %v", names)
+	}
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	bfs := memfs.New()
+	util.WriteFile(bfs, "dir/a.yaml", []byte("a"), 0644)
+	util.WriteFile(bfs, "dir/b.json", []byte("b"), 0644)
+	util.WriteFile(bfs, "dir/sub/c.yaml", []byte("c"), 0644)
+	a := New(bfs)
+
+	matches, err := a.Glob("dir/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0] != "dir/a.yaml" {
+		t.Errorf("unexpected matches %v", matches)
+	}
+	// The extensions of util.Glob don't apply to fs.GlobFS.
+	util.WriteFile(bfs, "dir/{a,b}.yaml", []byte("a"), 0644)
+	matches, err = a.Glob("*/{a,b}.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0] != "dir/{a,b}.yaml" {
+		t.Errorf("unexpected matches %v", matches)
+	}
+	if _, err := a.Glob("["); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("expected ErrBadPattern, got %v", err)
+	}
+
+	fi, err := a.Stat("dir/b.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "b.json" || fi.Size() != 1 {
+		t.Errorf("unexpected info %s %d", fi.Name(), fi.Size())
+	}
+
+	sub, err := a.Sub("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := fs.ReadFile(sub, "sub/c.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "c" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := a.Sub("dir/a.yaml"); err == nil {
+		t.Error("expected error for Sub on a file")
+	}
+}
+
+func TestFSTest(t *testing.T) {
+	bfs := memfs.New()
+	util.WriteFile(bfs, "dir/a", []byte("a"), 0644)
+	util.WriteFile(bfs, "dir/sub/b", []byte("b"), 0644)
+
+	if err := fstest.TestFS(New(bfs), "dir/a", "dir/sub/b"); err != nil {
+		t.Fatal(err)
+	}
+}
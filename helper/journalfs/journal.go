@@ -0,0 +1,481 @@
+package journalfs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const journalFile = "journal"
+
+// The operations recorded in the journal.
+const (
+	// opSave records the state of a path before the batch changes it: kept
+	// in Blob, renamed there if Move is set and linked or copied otherwise,
+	// or missing if Blob is empty.
+	opSave    = "save"
+	opWrite   = "write"
+	opMkdir   = "mkdir"
+	opRemove  = "remove"
+	opRename  = "rename"
+	opSymlink = "symlink"
+	opLink    = "link"
+	opChmod   = "chmod"
+	opChtimes = "chtimes"
+	opCommit  = "commit"
+)
+
+// record is a line of the journal. The records of the operations are
+// followed by one with Done, or Failed, set to their Seq once applied.
+type record struct {
+	Seq  int    `json:"seq,omitempty"`
+	Op   string `json:"op,omitempty"`
+	Path string `json:"path,omitempty"`
+	// Arg is the destination of a rename, the target of a symlink, or the
+	// existing name of a hard link.
+	Arg  string      `json:"arg,omitempty"`
+	Blob string      `json:"blob,omitempty"`
+	Move bool        `json:"move,omitempty"`
+	Perm os.FileMode `json:"perm,omitempty"`
+	// OldPerm and OldMtime hold the attributes changed by chmod and chtimes.
+	OldPerm  os.FileMode `json:"oldPerm,omitempty"`
+	Atime    time.Time   `json:"atime,omitempty"`
+	Mtime    time.Time   `json:"mtime,omitempty"`
+	OldMtime time.Time   `json:"oldMtime,omitempty"`
+
+	Done   int `json:"done,omitempty"`
+	Failed int `json:"failed,omitempty"`
+}
+
+// batch is the batch being journaled.
+type batch struct {
+	fs      billy.Filesystem
+	dir     string
+	journal billy.File
+	seq     int
+	// blobs counts the blobs named by the FS, whose names must not be
+	// reused while its files are open.
+	blobs *int
+	// saved holds the paths whose state before the batch is recorded,
+	// which covers the paths below them.
+	saved map[string]bool
+	// refs lists the blobs named by the records, removed with the journal.
+	refs []string
+}
+
+func (fs *FS) begin() (*batch, error) {
+	if err := fs.underlying.MkdirAll(fs.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	j, err := fs.underlying.OpenFile(filepath.Join(fs.dir, journalFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch{
+		fs:      fs.underlying,
+		dir:     fs.dir,
+		journal: j,
+		blobs:   &fs.blobs,
+		saved:   make(map[string]bool),
+	}, nil
+}
+
+// append writes r to the journal, and commits it to stable storage.
+func (b *batch) append(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return util.SyncFile(b.journal)
+}
+
+func (b *batch) blob(kind string) string {
+	return blobName(b.dir, kind, b.blobs)
+}
+
+func blobName(dir, kind string, n *int) string {
+	*n++
+	return filepath.Join(dir, kind+strconv.Itoa(*n))
+}
+
+// do journals r, then applies it with fn, recording whether it succeeded.
+func (b *batch) do(r record, fn func() error) error {
+	b.seq++
+	r.Seq = b.seq
+	if r.Blob != "" {
+		b.refs = append(b.refs, r.Blob)
+	}
+	if err := b.append(r); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		if jerr := b.append(record{Failed: r.Seq}); jerr != nil {
+			return jerr
+		}
+		return err
+	}
+
+	return b.append(record{Done: r.Seq})
+}
+
+// covered reports whether the state of name before the batch is recorded.
+func (b *batch) covered(name string) bool {
+	for p := name; ; p = filepath.Dir(p) {
+		if b.saved[p] {
+			return true
+		}
+		if p == "." || p == string(filepath.Separator) {
+			return false
+		}
+	}
+}
+
+// save records the state of name before the batch, unless it already is.
+// The entry at name is moved to the journal if move is set, and linked or
+// copied otherwise, which is only done for regular files.
+func (b *batch) save(name string, move bool) error {
+	name = clean(name)
+	if b.covered(name) {
+		return nil
+	}
+
+	r := record{Op: opSave, Path: name, Move: move}
+	_, err := b.fs.Lstat(name)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		r.Blob = b.blob("old")
+	}
+
+	err = b.do(r, func() error {
+		switch {
+		case r.Blob == "":
+			return nil
+		case move:
+			return b.fs.Rename(name, r.Blob)
+		}
+
+		if l, ok := b.fs.(billy.Linker); ok {
+			if err := l.Link(name, r.Blob); err == nil {
+				return nil
+			}
+		}
+		return util.CopyFile(b.fs, r.Blob, b.fs, name)
+	})
+	if err == nil {
+		b.saved[name] = true
+	}
+
+	return err
+}
+
+// created records that name, created by the batch outside of the journal,
+// didn't exist before it.
+func (b *batch) created(name string) error {
+	name = clean(name)
+	if b.covered(name) {
+		return nil
+	}
+
+	err := b.do(record{Op: opSave, Path: name}, func() error { return nil })
+	if err == nil {
+		b.saved[name] = true
+	}
+
+	return err
+}
+
+// saveParents records the state of the topmost missing parent of name, which
+// creating name creates.
+func (b *batch) saveParents(name string) error {
+	missing := ""
+	for p := filepath.Dir(clean(name)); p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+		if _, err := b.fs.Lstat(p); err == nil {
+			break
+		}
+		missing = p
+	}
+	if missing == "" {
+		return nil
+	}
+
+	return b.save(missing, false)
+}
+
+// commit marks the batch as committed, then removes the journal.
+func (b *batch) commit() error {
+	if err := b.append(record{Op: opCommit}); err != nil {
+		b.journal.Close()
+		return err
+	}
+
+	return b.close()
+}
+
+// close closes the journal, then removes it along with the saved states,
+// without reading it back.
+func (b *batch) close() error {
+	b.journal.Close()
+	return removeJournal(b.fs, b.dir, b.refs)
+}
+
+// cleanup removes the journal in dir and the blobs it refers to, the
+// journal last, so that an interrupted cleanup can be resumed.
+func cleanup(fs billy.Filesystem, dir string) error {
+	records, err := readJournal(fs, dir)
+	if err != nil {
+		return err
+	}
+
+	var refs []string
+	for _, r := range records {
+		if r.Blob != "" {
+			refs = append(refs, r.Blob)
+		}
+	}
+
+	return removeJournal(fs, dir, refs)
+}
+
+func removeJournal(fs billy.Filesystem, dir string, blobs []string) error {
+	for _, blob := range blobs {
+		if err := util.RemoveAll(fs, blob); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.Remove(filepath.Join(dir, journalFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return util.SyncDir(fs, dir)
+}
+
+// readJournal returns the records of the journal in dir, ignoring a
+// truncated last line.
+func readJournal(fs billy.Filesystem, dir string) ([]record, error) {
+	f, err := fs.Open(filepath.Join(dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []record
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var r record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			break
+		}
+		records = append(records, r)
+	}
+
+	return records, s.Err()
+}
+
+// ErrIncomplete is returned by New when the journal holds a batch neither
+// committed nor rolled back, with the zero value of Options.Recover.
+var ErrIncomplete = errors.New("journal holds an incomplete batch")
+
+// recoverJournal completes or undoes the batch of the journal in dir, if any.
+func recoverJournal(fs billy.Filesystem, dir string, mode Mode) error {
+	records, err := readJournal(fs, dir)
+	if err != nil || len(records) == 0 {
+		return err
+	}
+
+	ended := make(map[int]bool)
+	done := make(map[int]bool)
+	var ops []record
+	for _, r := range records {
+		switch {
+		case r.Op == opCommit:
+			return cleanup(fs, dir)
+		case r.Done != 0:
+			ended[r.Done], done[r.Done] = true, true
+		case r.Failed != 0:
+			ended[r.Failed] = true
+		default:
+			ops = append(ops, r)
+		}
+	}
+
+	var pending *record
+	if n := len(ops); n > 0 && !ended[ops[n-1].Seq] {
+		pending = &ops[n-1]
+	}
+
+	switch mode {
+	case Replay:
+		if pending != nil {
+			if err := redo(fs, *pending); err != nil {
+				return err
+			}
+		}
+	case Rollback:
+		if pending != nil {
+			if err := undoPending(fs, *pending); err != nil {
+				return err
+			}
+		}
+		for i := len(ops) - 1; i >= 0; i-- {
+			if done[ops[i].Seq] {
+				if err := undo(fs, ops[i]); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return ErrIncomplete
+	}
+
+	return cleanup(fs, dir)
+}
+
+func exists(fs billy.Filesystem, name string) bool {
+	_, err := fs.Lstat(name)
+	return err == nil
+}
+
+// restore puts back the entry at name, from the blob it was saved to.
+func restore(fs billy.Filesystem, r record) error {
+	if err := util.RemoveAll(fs, r.Path); err != nil {
+		return err
+	}
+	if r.Blob == "" {
+		return nil
+	}
+
+	if err := fs.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	return fs.Rename(r.Blob, r.Path)
+}
+
+// undo reverts the operation of r, which was applied.
+func undo(fs billy.Filesystem, r record) error {
+	switch r.Op {
+	case opSave:
+		return restore(fs, r)
+	case opRename:
+		if !exists(fs, r.Arg) || exists(fs, r.Path) {
+			return nil
+		}
+		if err := fs.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+			return err
+		}
+		return fs.Rename(r.Arg, r.Path)
+	case opChmod:
+		return chmod(fs, r.Path, r.OldPerm)
+	case opChtimes:
+		return chtimes(fs, r.Path, r.OldMtime, r.OldMtime)
+	}
+
+	// The other operations are reverted by restoring the paths they
+	// changed, saved beforehand.
+	return nil
+}
+
+// undoPending reverts the operation of r, which may or may not have been
+// applied.
+func undoPending(fs billy.Filesystem, r record) error {
+	switch r.Op {
+	case opSave:
+		if r.Move && r.Blob != "" && exists(fs, r.Blob) {
+			return restore(fs, r)
+		}
+		if r.Blob != "" {
+			return util.RemoveAll(fs, r.Blob)
+		}
+		return nil
+	case opWrite:
+		return util.RemoveAll(fs, r.Blob)
+	}
+
+	return undo(fs, r)
+}
+
+// redo applies the operation of r, which may or may not have been applied.
+// A save is undone instead, as the operation it precedes wasn't journaled.
+func redo(fs billy.Filesystem, r record) error {
+	switch r.Op {
+	case opSave:
+		return undoPending(fs, r)
+	case opWrite:
+		if !exists(fs, r.Blob) {
+			return nil
+		}
+		if err := fs.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+			return err
+		}
+		return fs.Rename(r.Blob, r.Path)
+	case opMkdir:
+		return fs.MkdirAll(r.Path, r.Perm)
+	case opRemove:
+		return util.RemoveAll(fs, r.Path)
+	case opRename:
+		if !exists(fs, r.Path) || exists(fs, r.Arg) {
+			return nil
+		}
+		return fs.Rename(r.Path, r.Arg)
+	case opSymlink:
+		if exists(fs, r.Path) {
+			return nil
+		}
+		return fs.Symlink(r.Arg, r.Path)
+	case opLink:
+		if exists(fs, r.Path) {
+			return nil
+		}
+		l, ok := fs.(billy.Linker)
+		if !ok {
+			return billy.ErrNotSupported
+		}
+		return l.Link(r.Arg, r.Path)
+	case opChmod:
+		return chmod(fs, r.Path, r.Perm)
+	case opChtimes:
+		return chtimes(fs, r.Path, r.Atime, r.Mtime)
+	}
+
+	return nil
+}
+
+func chmod(fs billy.Filesystem, name string, mode os.FileMode) error {
+	c, ok := fs.(billy.Change)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return c.Chmod(name, mode)
+}
+
+func chtimes(fs billy.Filesystem, name string, atime, mtime time.Time) error {
+	c, ok := fs.(billy.Change)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return c.Chtimes(name, atime, mtime)
+}
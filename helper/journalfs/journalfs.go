@@ -0,0 +1,647 @@
+// Package journalfs provides a billy filesystem wrapper journaling the
+// changes made through it, so that a batch of changes interrupted by a crash
+// can be replayed or rolled back when the filesystem is opened again,
+// instead of leaving a half-written tree behind.
+package journalfs // import "github.com/go-git/go-billy/v5/helper/journalfs"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// DefaultDir is the directory of the journal used when Options.Dir is empty.
+const DefaultDir = ".journal"
+
+// ErrBatch is returned by Begin when a batch is already in progress, and by
+// Commit and Rollback when none is.
+var ErrBatch = errors.New("journalfs: no batch, or one already in progress")
+
+// Mode tells New what to do with an incomplete batch found in the journal.
+type Mode int
+
+const (
+	// Fail makes New fail with ErrIncomplete.
+	Fail Mode = iota
+	// Replay completes the change interrupted by the crash, keeping all the
+	// changes of the batch made before it.
+	Replay
+	// Rollback reverts all the changes of the batch.
+	Rollback
+)
+
+// Options configures an FS.
+type Options struct {
+	// Dir is the directory of the wrapped filesystem holding the journal,
+	// and the files being written. DefaultDir is used if it is empty. It
+	// must not be modified other than by the FS, and is left out of the
+	// listings of its parent.
+	Dir string
+	// Recover tells what to do with the incomplete batch of a previous
+	// process, if any.
+	Recover Mode
+}
+
+// FS is a filesystem wrapper journaling its changes in batches. Each change
+// is recorded in the journal, along with what is needed to revert it, and
+// committed to stable storage before it is applied, so that a batch can be
+// completed or reverted whatever the point a crash interrupted it. The
+// changes made outside of a batch started by Begin are journaled as batches
+// of their own.
+//
+// The files opened for writing are written to the journal directory, the
+// new content replacing the file on Close, so that it is never seen half
+// written. The states of the files and directories changed are kept in the
+// journal until the end of the batch: the replaced files as hard links, or
+// copies if the wrapped filesystem can't link them, and the removed ones
+// renamed there.
+//
+// Chown, Lchown, the extended attributes and Exchange aren't journaled, and
+// fail with billy.ErrNotSupported.
+type FS struct {
+	wrapper.Base
+	underlying billy.Filesystem
+	dir        string
+
+	m     sync.Mutex
+	blobs int
+	batch *batch
+	// explicit is set for the batches started by Begin.
+	explicit bool
+}
+
+// New returns a filesystem wrapping fs, journaling its changes. The
+// incomplete batch left by a previous process is dealt with according to
+// opts.Recover, before the journal directory is emptied.
+func New(fs billy.Filesystem, opts Options) (*FS, error) {
+	dir := clean(opts.Dir)
+	if dir == "." {
+		dir = DefaultDir
+	}
+
+	if err := recoverJournal(fs, dir, opts.Recover); err != nil {
+		return nil, err
+	}
+	if err := util.RemoveAll(fs, dir); err != nil {
+		return nil, err
+	}
+	if err := fs.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FS{Base: wrapper.NewBase(fs), underlying: fs, dir: dir}, nil
+}
+
+// clean returns name relative to the root of the filesystem.
+func clean(name string) string {
+	sep := string(filepath.Separator)
+	name = filepath.Clean(sep + filepath.FromSlash(name))
+	if name == sep {
+		return "."
+	}
+
+	return strings.TrimPrefix(name, sep)
+}
+
+// Begin starts a batch, ended by Commit or Rollback. The changes of the
+// batch can be rolled back as a whole, and are either replayed or rolled
+// back together after a crash, depending on Options.Recover.
+func (fs *FS) Begin() error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.batch != nil {
+		return ErrBatch
+	}
+
+	b, err := fs.begin()
+	if err != nil {
+		return err
+	}
+
+	fs.batch, fs.explicit = b, true
+	return nil
+}
+
+// Commit ends the batch started by Begin, keeping its changes.
+func (fs *FS) Commit() error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.batch == nil || !fs.explicit {
+		return ErrBatch
+	}
+
+	b := fs.batch
+	fs.batch = nil
+	return b.commit()
+}
+
+// Rollback ends the batch started by Begin, reverting its changes. The files
+// of the batch still open for writing are left out, their content being
+// written on Close, in a batch of their own.
+func (fs *FS) Rollback() error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.batch == nil || !fs.explicit {
+		return ErrBatch
+	}
+
+	b := fs.batch
+	fs.batch = nil
+	b.journal.Close()
+	if err := recoverJournal(fs.underlying, fs.dir, Rollback); err != nil {
+		return err
+	}
+
+	return util.SyncDir(fs.underlying, ".")
+}
+
+// run calls fn with the current batch, or with a batch of its own, committed
+// if fn succeeds and rolled back otherwise.
+func (fs *FS) run(fn func(b *batch) error) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.batch != nil {
+		return fn(fs.batch)
+	}
+
+	b, err := fs.begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(b); err != nil {
+		b.journal.Close()
+		if rerr := recoverJournal(fs.underlying, fs.dir, Rollback); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+
+	return b.commit()
+}
+
+// internal reports whether name is in the journal directory.
+func (fs *FS) internal(name string) bool {
+	name = clean(name)
+	return name == fs.dir || strings.HasPrefix(name, fs.dir+string(filepath.Separator))
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// OpenFile opens the named file. The files opened for writing are written
+// to the journal directory, replacing the file on Close.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	name := clean(filename)
+	if resolved, err := util.EvalSymlinks(fs.underlying, name); err == nil {
+		name = resolved
+	}
+
+	fi, err := fs.underlying.Stat(name)
+	switch {
+	case os.IsNotExist(err) && flag&os.O_CREATE != 0:
+	case err != nil, fi.IsDir():
+		// Let the wrapped filesystem fail, without side effects.
+		return fs.Base.OpenFile(filename, flag&^os.O_CREATE, perm)
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	default:
+		perm = fi.Mode().Perm()
+	}
+
+	f, err := fs.openBlob(name, fi, flag, perm)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	return &file{File: f, fs: fs, name: clean(filename), path: name, flag: flag}, nil
+}
+
+// openBlob creates the file holding the new content of name, with its
+// current content unless it is truncated.
+func (fs *FS) openBlob(name string, fi os.FileInfo, flag int, perm os.FileMode) (billy.File, error) {
+	fs.m.Lock()
+	blob := blobName(fs.dir, "new", &fs.blobs)
+	fs.m.Unlock()
+
+	f, err := fs.underlying.OpenFile(blob, os.O_RDWR|os.O_CREATE|os.O_EXCL|flag&os.O_APPEND, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi != nil && flag&os.O_TRUNC == 0 {
+		err = copyContent(f, fs.underlying, name)
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			f.Close()
+			fs.underlying.Remove(blob)
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func copyContent(w io.Writer, fs billy.Basic, name string) error {
+	r, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// pathError returns err as an *os.PathError of op on name.
+func pathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: unwrapPathError(err)}
+}
+
+func unwrapPathError(err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+
+	return err
+}
+
+// TempFile creates a temporary file, removed if the batch is rolled back.
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.Base.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.created(f)
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := fs.Base.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.created(f)
+}
+
+// created journals the creation of the temporary file f, reopened to be
+// written as any other.
+func (fs *FS) created(f billy.File) (billy.File, error) {
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := fs.run(func(b *batch) error { return b.created(name) }); err != nil {
+		fs.underlying.Remove(name)
+		return nil, err
+	}
+
+	return fs.OpenFile(name, os.O_RDWR|os.O_TRUNC, 0)
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := fs.Base.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	if err := fs.run(func(b *batch) error { return b.created(name) }); err != nil {
+		util.RemoveAll(fs.underlying, name)
+		return "", err
+	}
+
+	return name, nil
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	name := clean(filename)
+	if fi, err := fs.underlying.Stat(name); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		// Let the wrapped filesystem fail, without side effects.
+		return fs.Base.MkdirAll(filename, perm)
+	}
+
+	return fs.run(func(b *batch) error {
+		if err := b.saveParents(name); err != nil {
+			return err
+		}
+		if err := b.save(name, false); err != nil {
+			return err
+		}
+
+		return b.do(record{Op: opMkdir, Path: name, Perm: perm}, func() error {
+			return fs.underlying.MkdirAll(name, perm)
+		})
+	})
+}
+
+func (fs *FS) Remove(filename string) error {
+	name := clean(filename)
+	fi, err := fs.underlying.Lstat(name)
+	if err != nil {
+		return pathError("remove", filename, err)
+	}
+	if fi.IsDir() {
+		infos, err := fs.underlying.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(infos) > 0 {
+			return &os.PathError{Op: "remove", Path: filename, Err: syscall.ENOTEMPTY}
+		}
+	}
+
+	return fs.run(func(b *batch) error {
+		if b.covered(name) {
+			return b.do(record{Op: opRemove, Path: name}, func() error {
+				return fs.underlying.Remove(name)
+			})
+		}
+
+		if err := b.save(name, true); err != nil {
+			return err
+		}
+		return b.do(record{Op: opRemove, Path: name}, func() error { return nil })
+	})
+}
+
+func (fs *FS) Rename(from, to string) error {
+	oldname, newname := clean(from), clean(to)
+	ofi, err := fs.underlying.Lstat(oldname)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: unwrapPathError(err)}
+	}
+
+	if nfi, err := fs.underlying.Lstat(newname); err == nil {
+		var errno error
+		switch {
+		case nfi.IsDir() && !ofi.IsDir():
+			errno = syscall.EISDIR
+		case !nfi.IsDir() && ofi.IsDir():
+			errno = syscall.ENOTDIR
+		case nfi.IsDir():
+			if infos, err := fs.underlying.ReadDir(newname); err != nil || len(infos) > 0 {
+				errno = syscall.ENOTEMPTY
+			}
+		}
+		if errno != nil {
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: errno}
+		}
+	}
+
+	return fs.run(func(b *batch) error {
+		if err := b.saveParents(newname); err != nil {
+			return err
+		}
+		if !b.covered(newname) {
+			if err := b.save(newname, true); err != nil {
+				return err
+			}
+		}
+
+		return b.do(record{Op: opRename, Path: oldname, Arg: newname}, func() error {
+			return fs.underlying.Rename(oldname, newname)
+		})
+	})
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	name := clean(link)
+	if _, err := fs.underlying.Lstat(name); err == nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrExist}
+	}
+
+	return fs.run(func(b *batch) error {
+		if err := b.saveParents(name); err != nil {
+			return err
+		}
+		if err := b.save(name, false); err != nil {
+			return err
+		}
+
+		return b.do(record{Op: opSymlink, Path: name, Arg: target}, func() error {
+			return fs.underlying.Symlink(target, name)
+		})
+	})
+}
+
+// Link implements billy.Linker.
+func (fs *FS) Link(oldname, newname string) error {
+	name := clean(newname)
+	if _, err := fs.underlying.Lstat(name); err == nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+
+	return fs.run(func(b *batch) error {
+		if err := b.save(name, false); err != nil {
+			return err
+		}
+
+		return b.do(record{Op: opLink, Path: name, Arg: clean(oldname)}, func() error {
+			return fs.Base.Link(clean(oldname), name)
+		})
+	})
+}
+
+// Chmod implements billy.Change.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	fi, err := fs.underlying.Stat(name)
+	if err != nil {
+		return pathError("chmod", name, err)
+	}
+
+	return fs.run(func(b *batch) error {
+		r := record{Op: opChmod, Path: clean(name), Perm: mode, OldPerm: fi.Mode().Perm()}
+		return b.do(r, func() error {
+			return fs.Base.Chmod(r.Path, mode)
+		})
+	})
+}
+
+// Chtimes implements billy.Change. The access time isn't restored by a
+// rollback, the modification time being used instead.
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fi, err := fs.underlying.Stat(name)
+	if err != nil {
+		return pathError("chtimes", name, err)
+	}
+
+	return fs.run(func(b *batch) error {
+		r := record{Op: opChtimes, Path: clean(name), Atime: atime, Mtime: mtime, OldMtime: fi.ModTime()}
+		return b.do(r, func() error {
+			return fs.Base.Chtimes(r.Path, atime, mtime)
+		})
+	})
+}
+
+// Lchown fails with billy.ErrNotSupported.
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	return billy.ErrNotSupported
+}
+
+// Chown fails with billy.ErrNotSupported.
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return billy.ErrNotSupported
+}
+
+// Setxattr fails with billy.ErrNotSupported.
+func (fs *FS) Setxattr(name, attr string, value []byte) error {
+	return billy.ErrNotSupported
+}
+
+// Removexattr fails with billy.ErrNotSupported.
+func (fs *FS) Removexattr(name, attr string) error {
+	return billy.ErrNotSupported
+}
+
+// Exchange fails with billy.ErrNotSupported.
+func (fs *FS) Exchange(x, y string) error {
+	return billy.ErrNotSupported
+}
+
+// ReadDir lists the directory at path, the journal directory left out.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := fs.Base.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := infos[:0]
+	for _, fi := range infos {
+		if !fs.internal(filepath.Join(path, fi.Name())) {
+			kept = append(kept, fi)
+		}
+	}
+
+	return kept, nil
+}
+
+// Capabilities returns the capabilities of the wrapped filesystem, but the
+// extended attributes.
+func (fs *FS) Capabilities() billy.Capability {
+	return fs.Base.Capabilities() &^ billy.XattrCapability
+}
+
+// Chroot returns a chrooted view of fs, journaled by fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a file opened for writing by an FS, written to the journal
+// directory until Close.
+type file struct {
+	billy.File
+	fs   *FS
+	name string
+	path string
+	flag int
+
+	closed bool
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) readable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY
+}
+
+func (f *file) writable() bool {
+	return f.flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if !f.readable() {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if !f.readable() {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	}
+
+	return f.File.ReadAt(p, off)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if !f.writable() {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *file) Truncate(size int64) error {
+	if !f.writable() {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EBADF}
+	}
+
+	return f.File.Truncate(size)
+}
+
+// Close replaces the file with the content written, in the current batch or
+// in a batch of its own.
+func (f *file) Close() error {
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+
+	blob := f.File.Name()
+	err := util.SyncFile(f.File)
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		f.fs.underlying.Remove(blob)
+		return err
+	}
+
+	return f.fs.run(func(b *batch) error {
+		if err := b.saveParents(f.path); err != nil {
+			return err
+		}
+		if err := b.save(f.path, false); err != nil {
+			return err
+		}
+
+		return b.do(record{Op: opWrite, Path: f.path, Blob: blob}, func() error {
+			if err := f.fs.underlying.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+				return err
+			}
+			return f.fs.underlying.Rename(blob, f.path)
+		})
+	})
+}
@@ -0,0 +1,214 @@
+package journalfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		fs, err := New(memfs.New(), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+func newFS(t *testing.T, fs billy.Filesystem, mode Mode) *FS {
+	t.Helper()
+	j, err := New(fs, Options{Recover: mode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+// crash makes changes to a tree holding "keep" and "dir/old", in a batch
+// left incomplete.
+func crash(t *testing.T) billy.Filesystem {
+	t.Helper()
+	m := memfs.New()
+	if err := util.WriteFile(m, "keep", []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(m, "dir/old", []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newFS(t, m, Fail)
+	if err := fs.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "keep", []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "new/file", []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("dir/old"); err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func assertContent(t *testing.T, fs billy.Filesystem, name, content string) {
+	t.Helper()
+	b, err := util.ReadFile(fs, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != content {
+		t.Errorf("%s: got %q, want %q", name, b, content)
+	}
+}
+
+func assertMissing(t *testing.T, fs billy.Filesystem, name string) {
+	t.Helper()
+	if _, err := fs.Lstat(name); !os.IsNotExist(err) {
+		t.Errorf("%s: got %v, want not exist", name, err)
+	}
+}
+
+func TestRecoverFail(t *testing.T) {
+	m := crash(t)
+	if _, err := New(m, Options{}); !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("got %v, want ErrIncomplete", err)
+	}
+}
+
+func TestRecoverRollback(t *testing.T) {
+	m := crash(t)
+	newFS(t, m, Rollback)
+
+	assertContent(t, m, "keep", "keep")
+	assertContent(t, m, "dir/old", "old")
+	assertMissing(t, m, "new")
+
+	infos, err := m.ReadDir(DefaultDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 {
+		t.Errorf("journal directory not emptied: %d entries", len(infos))
+	}
+}
+
+func TestRecoverReplay(t *testing.T) {
+	m := crash(t)
+
+	// Add the record of a write interrupted before its blob was renamed.
+	f, err := m.OpenFile(filepath.Join(DefaultDir, journalFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(`{"seq":100,"op":"write","path":"replayed","blob":".journal/pending"}` + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := util.WriteFile(m, filepath.Join(DefaultDir, "pending"), []byte("replayed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	newFS(t, m, Replay)
+
+	assertContent(t, m, "keep", "changed")
+	assertContent(t, m, "new/file", "new")
+	assertContent(t, m, "replayed", "replayed")
+	assertMissing(t, m, "dir/old")
+}
+
+func TestRecoverTruncated(t *testing.T) {
+	m := crash(t)
+
+	f, err := m.OpenFile(filepath.Join(DefaultDir, journalFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`{"seq":100,"op":"remo`))
+	f.Close()
+
+	newFS(t, m, Rollback)
+	assertContent(t, m, "keep", "keep")
+	assertContent(t, m, "dir/old", "old")
+}
+
+func TestRecoverCommitted(t *testing.T) {
+	m := memfs.New()
+	fs := newFS(t, m, Fail)
+	if err := fs.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "file", []byte("committed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	newFS(t, m, Fail)
+	assertContent(t, m, "file", "committed")
+}
+
+func TestRollback(t *testing.T) {
+	m := memfs.New()
+	if err := util.WriteFile(m, "a", []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newFS(t, m, Fail)
+	if err := fs.Commit(); err != ErrBatch {
+		t.Fatalf("Commit without batch: got %v, want ErrBatch", err)
+	}
+	if err := fs.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Begin(); err != ErrBatch {
+		t.Fatalf("nested Begin: got %v, want ErrBatch", err)
+	}
+
+	if err := fs.Rename("a", "dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("dir/b", "link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Chmod("dir/b", 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fs, "link", "a")
+
+	if err := fs.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, m, "a", "a")
+	assertMissing(t, m, "dir")
+	assertMissing(t, m, "link")
+	if fi, err := m.Stat("a"); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Errorf("got %v, %v; want mode 0644", fi, err)
+	}
+}
+
+func TestReadDirHidesJournal(t *testing.T) {
+	fs := newFS(t, memfs.New(), Fail)
+	if err := util.WriteFile(fs, "file", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "file" {
+		t.Errorf("got %d entries, want only file", len(infos))
+	}
+}
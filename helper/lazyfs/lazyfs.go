@@ -0,0 +1,314 @@
+// Package lazyfs provides a read-only billy filesystem wrapper materializing
+// the files of a remote, or otherwise slow, filesystem into a local one the
+// first time they are opened, so that reading a few files of a large tree
+// doesn't require extracting all of it.
+package lazyfs // import "github.com/go-git/go-billy/v5/helper/lazyfs"
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+)
+
+// FS is a read-only view of a remote filesystem whose regular files are
+// copied to a local filesystem, at the same path, when first opened, and
+// read from there afterwards. The attributes, directory listings and
+// symbolic links are read from the remote filesystem, which is never
+// written to.
+//
+// A file is copied to a temporary file of its local directory, renamed
+// once complete, so that the local tree only holds whole files and can be
+// kept from a run to the next. The local files are only checked against the
+// size of the remote ones: a local tree materialized from another version
+// of the remote tree must be discarded.
+//
+// Every write fails with billy.ErrReadOnly.
+type FS struct {
+	wrapper.Base
+	local billy.Filesystem
+
+	m sync.Mutex
+	// touched holds the paths opened, keyed by their slash separated path
+	// relative to the root.
+	touched map[string]bool
+	// pending holds the paths being materialized, materialized being
+	// signaled once they are.
+	pending      map[string]bool
+	materialized *sync.Cond
+}
+
+// New returns a read-only filesystem serving the files of remote, which are
+// materialized into local when opened.
+func New(remote, local billy.Filesystem) *FS {
+	fs := &FS{
+		Base:    wrapper.NewBase(remote),
+		local:   local,
+		touched: make(map[string]bool),
+		pending: make(map[string]bool),
+	}
+	fs.materialized = sync.NewCond(&fs.m)
+
+	return fs
+}
+
+// clean returns name as a slash separated path relative to the root.
+func clean(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// Touched returns the paths of the files opened so far, sorted, as slash
+// separated paths relative to the root.
+func (fs *FS) Touched() []string {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	names := make([]string, 0, len(fs.touched))
+	for name := range fs.touched {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the local copy of the file, materializing it first if
+// needed. The files other than regular ones are opened from the remote
+// filesystem.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+
+	fi, err := fs.Base.Stat(filename)
+	if pe, ok := err.(*os.PathError); ok {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: pe.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	name := clean(filename)
+	if err := fs.materialize(name, fi); err != nil {
+		return nil, err
+	}
+
+	f, err := fs.local.OpenFile(filepath.FromSlash(name), flag, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.m.Lock()
+	fs.touched[name] = true
+	fs.m.Unlock()
+
+	return &file{File: f, name: filename}, nil
+}
+
+// materialize copies the file at name, described by fi, to the local
+// filesystem unless it is there already, waiting for it if it is being
+// copied.
+func (fs *FS) materialize(name string, fi os.FileInfo) error {
+	fs.m.Lock()
+	for fs.pending[name] {
+		fs.materialized.Wait()
+	}
+
+	if lfi, err := fs.local.Stat(filepath.FromSlash(name)); err == nil && lfi.Mode().IsRegular() && lfi.Size() == fi.Size() {
+		fs.m.Unlock()
+		return nil
+	}
+
+	fs.pending[name] = true
+	fs.m.Unlock()
+
+	err := fs.copy(name, fi)
+
+	fs.m.Lock()
+	delete(fs.pending, name)
+	fs.materialized.Broadcast()
+	fs.m.Unlock()
+
+	return err
+}
+
+// copy writes the content of the remote file at name to a temporary file,
+// renamed to name once complete.
+func (fs *FS) copy(name string, fi os.FileInfo) error {
+	src, err := fs.Base.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	localName := filepath.FromSlash(name)
+	dir := filepath.Dir(localName)
+	if err := fs.local.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := fs.local.TempFile(dir, "."+filepath.Base(localName)+".lazy-")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = chmod(fs.local, tmp.Name(), fi.Mode().Perm())
+	}
+	if err == nil {
+		err = fs.local.Rename(tmp.Name(), localName)
+	}
+	if err != nil {
+		_ = fs.local.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// chmod gives the local copy the permissions of the remote file, when the
+// local filesystem supports it.
+func chmod(fs billy.Filesystem, name string, mode os.FileMode) error {
+	c, ok := fs.(billy.Change)
+	if !ok {
+		return nil
+	}
+
+	return c.Chmod(name, mode)
+}
+
+// Prefetch implements billy.Prefetcher, materializing the file at path in
+// the background. The range is ignored, the files being copied whole, and
+// the file isn't reported by Touched until it is opened.
+func (fs *FS) Prefetch(path string, off, length int64) error {
+	fi, err := fs.Base.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	go func() { _ = fs.materialize(clean(path), fi) }()
+	return nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	return "", billy.ErrReadOnly
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+// Link implements billy.Linker.
+func (fs *FS) Link(oldname, newname string) error {
+	return billy.ErrReadOnly
+}
+
+// Chmod implements billy.Change.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+// Lchown implements billy.Change.
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	return billy.ErrReadOnly
+}
+
+// Chown implements billy.Change.
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return billy.ErrReadOnly
+}
+
+// Chtimes implements billy.Change.
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return billy.ErrReadOnly
+}
+
+// Setxattr implements billy.Xattrer.
+func (fs *FS) Setxattr(name, attr string, value []byte) error {
+	return billy.ErrReadOnly
+}
+
+// Removexattr implements billy.Xattrer.
+func (fs *FS) Removexattr(name, attr string) error {
+	return billy.ErrReadOnly
+}
+
+// Exchange implements the Exchange of util.SwapDirs.
+func (fs *FS) Exchange(x, y string) error {
+	return billy.ErrReadOnly
+}
+
+// SyncDir implements billy.DirSyncer; there is nothing to sync, nothing
+// being written through FS.
+func (fs *FS) SyncDir(path string) error {
+	return nil
+}
+
+// Capabilities implements the Capable interface, the view being read-only.
+func (fs *FS) Capabilities() billy.Capability {
+	return fs.Base.Capabilities() &^
+		(billy.WriteCapability | billy.ReadAndWriteCapability | billy.TruncateCapability)
+}
+
+// Chroot returns a chrooted view of fs, sharing its local files, whose
+// touched paths are reported relative to the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a local copy, named after the path it was opened from.
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
@@ -0,0 +1,180 @@
+package lazyfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// counting counts the files opened on the remote filesystem.
+type counting struct {
+	wrapper.Base
+
+	m     sync.Mutex
+	opens int
+}
+
+func (fs *counting) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fs.m.Lock()
+	fs.opens++
+	fs.m.Unlock()
+	return fs.Base.OpenFile(filename, flag, perm)
+}
+
+func (fs *counting) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *counting) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func newRemote(t *testing.T) *counting {
+	t.Helper()
+	remote := memfs.New()
+	for name, content := range map[string]string{
+		"a":             "a",
+		"dir/b":         "bb",
+		"dir/sub/c":     "ccc",
+		"large/archive": "a large archive",
+	} {
+		if err := util.WriteFile(remote, name, []byte(content), 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := remote.Symlink("dir/b", "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	return &counting{Base: wrapper.NewBase(remote)}
+}
+
+func TestFSTest(t *testing.T) {
+	fs := New(newRemote(t), memfs.New())
+	test.FSTest(t, fs, "a", "dir/b", "dir/sub/c", "large/archive", "link")
+}
+
+func TestMaterialize(t *testing.T) {
+	remote := newRemote(t)
+	local := memfs.New()
+	fs := New(remote, local)
+
+	if _, err := fs.ReadDir("dir"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.Stat("dir"); !os.IsNotExist(err) {
+		t.Fatalf("listing materialized the directory: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		b, err := util.ReadFile(fs, "dir/sub/c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "ccc" {
+			t.Fatalf("got %q", b)
+		}
+	}
+	if remote.opens != 1 {
+		t.Errorf("remote file opened %d times, want 1", remote.opens)
+	}
+
+	fi, err := local.Stat("dir/sub/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o640 {
+		t.Errorf("local copy has mode %v", fi.Mode())
+	}
+	if infos, _ := local.ReadDir("dir/sub"); len(infos) != 1 {
+		t.Errorf("temporary files left behind: %d entries", len(infos))
+	}
+
+	f, err := fs.Open("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "link" {
+		t.Errorf("got name %q", f.Name())
+	}
+	f.Close()
+
+	if touched := fs.Touched(); !reflect.DeepEqual(touched, []string{"dir/sub/c", "link"}) {
+		t.Errorf("unexpected touched paths: %v", touched)
+	}
+	if _, err := local.Stat("large/archive"); !os.IsNotExist(err) {
+		t.Errorf("untouched file materialized: %v", err)
+	}
+}
+
+func TestMaterializeReused(t *testing.T) {
+	local := memfs.New()
+	if _, err := util.ReadFile(New(newRemote(t), local), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := newRemote(t)
+	if _, err := util.ReadFile(New(remote, local), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if remote.opens != 0 {
+		t.Errorf("remote file opened %d times, want 0", remote.opens)
+	}
+}
+
+func TestMaterializeConcurrent(t *testing.T) {
+	remote := newRemote(t)
+	fs := New(remote, memfs.New())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := fs.Open("large/archive")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer f.Close()
+			if b, _ := io.ReadAll(f); string(b) != "a large archive" {
+				t.Errorf("got %q", b)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if remote.opens != 1 {
+		t.Errorf("remote file opened %d times, want 1", remote.opens)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fs := New(newRemote(t), memfs.New())
+
+	if _, err := fs.Create("b"); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Create: expected ErrReadOnly, got %v", err)
+	}
+	if _, err := fs.OpenFile("a", os.O_RDWR, 0); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("OpenFile: expected ErrReadOnly, got %v", err)
+	}
+	if err := fs.Remove("a"); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Remove: expected ErrReadOnly, got %v", err)
+	}
+	if err := fs.Rename("a", "b"); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Rename: expected ErrReadOnly, got %v", err)
+	}
+	if caps := fs.Capabilities(); caps&billy.WriteCapability != 0 {
+		t.Errorf("unexpected write capability: %v", caps)
+	}
+}
@@ -0,0 +1,171 @@
+// Package limitfs provides a billy filesystem wrapper capping the size of the
+// files written through it, as a defense against the decompression bombs of
+// the archives being extracted to it.
+package limitfs // import "github.com/go-git/go-billy/v5/helper/limitfs"
+
+import (
+	"errors"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// FS is a filesystem wrapper whose files can't be written past a size, as
+// with util.LimitWriter.
+type FS struct {
+	wrapper.Base
+	max int64
+}
+
+// WithMaxFileSize returns a filesystem wrapping fs, whose files can't grow
+// past max bytes: the writes exceeding it fail with a *util.FileSizeError.
+// The files opened without O_TRUNC count their existing content against
+// max. The files created through FS, by OpenFile with O_CREATE or by
+// TempFile, are removed on Close once a write was rejected; the content of
+// the other files is restored to what it was before the first write.
+//
+// Only the writes through the files are limited, not the files produced by
+// other means, such as the CopyFile of some filesystems.
+func WithMaxFileSize(fs billy.Filesystem, max int64) *FS {
+	return &FS{Base: wrapper.NewBase(fs), max: max}
+}
+
+// Layer returns the billy.Layer of WithMaxFileSize, for billy.Compose.
+func Layer(max int64) billy.Layer {
+	return billy.Layer{
+		Name:     "max file size",
+		Requires: billy.WriteCapability,
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return WithMaxFileSize(fs, max), nil
+		},
+	}
+}
+
+// Create creates or truncates the named file, limited in size.
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OpenFile opens the named file, limited in size if it is opened for
+// writing.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	created := false
+	if flag&os.O_CREATE != 0 {
+		_, err := fs.Lstat(filename)
+		created = os.IsNotExist(err)
+	}
+
+	f, err := fs.Base.OpenFile(filename, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	left := fs.max
+	if flag&os.O_TRUNC == 0 && !created {
+		fi, err := fs.Stat(filename)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		left -= fi.Size()
+	}
+
+	return fs.limit(f, filename, left, created), nil
+}
+
+// TempFile creates a temporary file, limited in size.
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.Base.TempFile(dir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.limit(f, f.Name(), fs.max, true), nil
+}
+
+// CreateTemp implements billy.TempCreator, the files being limited in size.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := fs.Base.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.limit(f, f.Name(), fs.max, true), nil
+}
+
+func (fs *FS) limit(f billy.File, name string, left int64, created bool) billy.File {
+	if left < 0 {
+		left = 0
+	}
+
+	return &file{
+		File:    f,
+		limited: util.LimitWriter(f, left),
+		fs:      fs.Unwrap(),
+		name:    name,
+		max:     fs.max,
+		created: created,
+	}
+}
+
+// Chroot returns a chrooted view of fs, whose files are limited in size.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a file opened for writing by an FS.
+type file struct {
+	billy.File
+	limited billy.File
+	fs      billy.Basic
+	name    string
+	max     int64
+	created bool
+
+	exceeded bool
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.limited.Write(p)
+	return n, f.check(err)
+}
+
+func (f *file) Truncate(size int64) error {
+	if size > f.max {
+		return &util.FileSizeError{Path: f.Name(), Max: f.max}
+	}
+
+	return f.File.Truncate(size)
+}
+
+// check returns err, reporting the limit of the FS rather than the room
+// left in the file when it was opened.
+func (f *file) check(err error) error {
+	var serr *util.FileSizeError
+	if !errors.As(err, &serr) {
+		return err
+	}
+
+	f.exceeded = true
+	return &util.FileSizeError{Path: f.Name(), Max: f.max}
+}
+
+// Close closes the file, removing it if it was created by the FS and
+// exceeded the limit.
+func (f *file) Close() error {
+	err := f.File.Close()
+	if f.exceeded && f.created {
+		if rerr := f.fs.Remove(f.name); err == nil && !os.IsNotExist(rerr) {
+			err = rerr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package limitfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return WithMaxFileSize(memfs.New(), 1<<20)
+	})
+}
+
+func TestWithMaxFileSize(t *testing.T) {
+	fs := WithMaxFileSize(memfs.New(), 8)
+
+	if err := util.WriteFile(fs, "small", []byte("12345678"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := util.WriteFile(fs, "large", []byte("123456789"), 0644)
+	var serr *util.FileSizeError
+	if !errors.As(err, &serr) || serr.Max != 8 || serr.Path != "large" {
+		t.Fatalf("got %v, want a FileSizeError", err)
+	}
+	if _, err := fs.Stat("large"); !os.IsNotExist(err) {
+		t.Errorf("partial file not removed: %v", err)
+	}
+}
+
+func TestWithMaxFileSize_Existing(t *testing.T) {
+	fs := WithMaxFileSize(memfs.New(), 8)
+	if err := util.WriteFile(fs, "file", []byte("123456"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fs.OpenFile("file", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("78")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("9")); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("got %v, want EFBIG", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := util.ReadFile(fs, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "123456" {
+		t.Errorf("got %q, want the content before the writes", got)
+	}
+
+	if err := util.WriteFile(fs, "file", []byte("87654321"), 0644); err != nil {
+		t.Errorf("truncated: %v", err)
+	}
+}
+
+func TestWithMaxFileSize_Truncate(t *testing.T) {
+	fs := WithMaxFileSize(memfs.New(), 8)
+	f, err := fs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(8); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(9); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("got %v, want EFBIG", err)
+	}
+}
+
+func TestWithMaxFileSize_TempFile(t *testing.T) {
+	fs := WithMaxFileSize(memfs.New(), 8)
+
+	tmp, err := fs.TempFile("", "tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmp.Write(make([]byte, 9)); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("got %v, want EFBIG", err)
+	}
+	if err := tmp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(tmp.Name()); !os.IsNotExist(err) {
+		t.Errorf("temp file not removed: %v", err)
+	}
+}
+
+func TestWithMaxFileSize_Chroot(t *testing.T) {
+	fs := WithMaxFileSize(memfs.New(), 8)
+	ch, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.WriteFile(ch, "large", make([]byte, 9), 0644); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("got %v, want EFBIG", err)
+	}
+	if _, err := fs.Stat("dir/large"); !os.IsNotExist(err) {
+		t.Errorf("partial file not removed: %v", err)
+	}
+}
@@ -1,12 +1,14 @@
 package mount
 
 import (
-	"io"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-
-	"fmt"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/helper/polyfill"
@@ -17,20 +19,102 @@ var separator = string(filepath.Separator)
 // Mount is a helper that allows to emulate the behavior of mount in memory.
 // Very usufull to create a temporal dir, on filesystem where is a performance
 // penalty in doing so.
+//
+// Several filesystems can be mounted, at any path, including inside another
+// mounted filesystem. Every call is sent to the filesystem mounted at the
+// longest mountpoint containing the path, or to the underlying filesystem if
+// there is none. As with mount(8), renaming across filesystems fails with
+// syscall.EXDEV.
 type Mount struct {
 	underlying billy.Filesystem
-	source     billy.Filesystem
-	mountpoint string
+
+	m      sync.RWMutex
+	mounts []*mounted
+}
+
+type mounted struct {
+	path string
+	fs   billy.Filesystem
 }
 
 // New creates a new filesystem wrapping up 'fs' the intercepts all the calls
-// made to `mountpoint` path and redirecting it to `source` filesystem.
+// made to `mountpoint` path and redirecting it to `source` filesystem. More
+// filesystems can be added later with Mount. Unlike Mount, New accepts the
+// root as mountpoint, every path below it being then sent to source.
 func New(fs billy.Basic, mountpoint string, source billy.Basic) *Mount {
 	return &Mount{
 		underlying: polyfill.New(fs),
-		source:     polyfill.New(source),
-		mountpoint: cleanPath(mountpoint),
+		mounts:     []*mounted{{path: cleanPath(mountpoint), fs: polyfill.New(source)}},
+	}
+}
+
+// Mount mounts fs at mountpoint, which can be inside a filesystem already
+// mounted. It fails with syscall.EBUSY if another filesystem is mounted at
+// the same path, and os.ErrInvalid when mounting at the root.
+func (h *Mount) Mount(mountpoint string, fs billy.Basic) error {
+	path := cleanPath(mountpoint)
+	if path == "." {
+		return &os.PathError{Op: "mount", Path: mountpoint, Err: os.ErrInvalid}
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for _, mp := range h.mounts {
+		if mp.path == path {
+			return &os.PathError{Op: "mount", Path: mountpoint, Err: syscall.EBUSY}
+		}
 	}
+
+	h.mounts = append(h.mounts, &mounted{path: path, fs: polyfill.New(fs)})
+	return nil
+}
+
+// Unmount removes the filesystem mounted at mountpoint. It fails with
+// syscall.EINVAL if nothing is mounted there, and syscall.EBUSY if other
+// filesystems are mounted below it.
+func (h *Mount) Unmount(mountpoint string) error {
+	path := cleanPath(mountpoint)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	i := -1
+	for j, mp := range h.mounts {
+		if mp.path == path {
+			i = j
+		} else if _, ok := below(mp.path, path); ok {
+			return &os.PathError{Op: "unmount", Path: mountpoint, Err: syscall.EBUSY}
+		}
+	}
+	if i < 0 {
+		return &os.PathError{Op: "unmount", Path: mountpoint, Err: syscall.EINVAL}
+	}
+
+	h.mounts = append(h.mounts[:i], h.mounts[i+1:]...)
+	return nil
+}
+
+// Close implements billy.Closer, closing the mounted filesystems, the last
+// mounted first, and then the underlying one. Every filesystem is closed
+// even if some fail, the first error being returned.
+func (h *Mount) Close() error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	var err error
+	for i := len(h.mounts) - 1; i >= 0; i-- {
+		if cerr := billy.Close(h.mounts[i].fs); err == nil {
+			err = cerr
+		}
+	}
+	h.mounts = nil
+
+	if cerr := billy.Close(h.underlying); err == nil {
+		err = cerr
+	}
+
+	return err
 }
 
 func (h *Mount) Create(path string) (billy.File, error) {
@@ -63,59 +147,90 @@ func (h *Mount) OpenFile(path string, flag int, mode os.FileMode) (billy.File, e
 	return wrapFile(f, path), err
 }
 
+// Rename renames from to to, if both are in the same filesystem. Otherwise
+// a *os.LinkError wrapping syscall.EXDEV is returned, callers wanting to
+// move files across filesystems have to copy them.
 func (h *Mount) Rename(from, to string) error {
-	fromInSource := h.isMountpoint(from)
-	toInSource := h.isMountpoint(to)
-
-	var fromFS, toFS billy.Basic
-
-	switch {
-	case fromInSource && toInSource:
-		from = h.mustRelToMountpoint(from)
-		to = h.mustRelToMountpoint(to)
-		return h.source.Rename(from, to)
-	case !fromInSource && !toInSource:
-		return h.underlying.Rename(from, to)
-	case fromInSource && !toInSource:
-		fromFS = h.source
-		from = h.mustRelToMountpoint(from)
-		toFS = h.underlying
-		to = cleanPath(to)
-	case !fromInSource && toInSource:
-		fromFS = h.underlying
-		from = cleanPath(from)
-		toFS = h.source
-		to = h.mustRelToMountpoint(to)
-	}
-
-	if err := copyPath(fromFS, toFS, from, to); err != nil {
-		return err
+	h.m.RLock()
+	fromMount, fromPath := h.resolve(from)
+	toMount, toPath := h.resolve(to)
+	busy := h.busy(from) || h.busy(to)
+	h.m.RUnlock()
+
+	if fromPath == "." || toPath == "." {
+		return os.ErrInvalid
+	}
+	if fromMount != toMount {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EXDEV}
+	}
+	if busy {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EBUSY}
 	}
 
-	return fromFS.Remove(from)
+	return h.filesystem(fromMount).Rename(fromPath, toPath)
 }
 
 func (h *Mount) Stat(path string) (os.FileInfo, error) {
 	fs, fullpath := h.getBasicAndPath(path)
-	return fs.Stat(fullpath)
+	fi, err := fs.Stat(fullpath)
+	if os.IsNotExist(err) {
+		return h.implicitDir(path, err)
+	}
+
+	return fi, err
 }
 
+// Remove removes the file at path. A directory containing a mountpoint can't
+// be removed, syscall.EBUSY is returned instead.
 func (h *Mount) Remove(path string) error {
-	fs, fullpath := h.getBasicAndPath(path)
+	h.m.RLock()
+	mp, fullpath := h.resolve(path)
+	busy := h.busy(path)
+	h.m.RUnlock()
+
 	if fullpath == "." {
 		return os.ErrInvalid
 	}
+	if busy {
+		return &os.PathError{Op: "remove", Path: path, Err: syscall.EBUSY}
+	}
 
-	return fs.Remove(fullpath)
+	return h.filesystem(mp).Remove(fullpath)
 }
 
+// ReadDir returns the entries of the directory at path, including the
+// mountpoints it contains. Mountpoints hide the entries with the same name,
+// and directories are reported for the missing parents of the mountpoints.
 func (h *Mount) ReadDir(path string) ([]os.FileInfo, error) {
 	fs, fullpath, err := h.getDirAndPath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return fs.ReadDir(fullpath)
+	infos, err := fs.ReadDir(fullpath)
+	children := h.children(path)
+	if err != nil && !(os.IsNotExist(err) && len(children) > 0) {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return infos, nil
+	}
+
+	merged := make([]os.FileInfo, 0, len(infos)+len(children))
+	for _, fi := range infos {
+		if _, ok := children[fi.Name()]; !ok {
+			merged = append(merged, fi)
+		}
+	}
+	for _, fi := range children {
+		merged = append(merged, fi)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+
+	return merged, nil
 }
 
 func (h *Mount) MkdirAll(filename string, perm os.FileMode) error {
@@ -128,17 +243,16 @@ func (h *Mount) MkdirAll(filename string, perm os.FileMode) error {
 }
 
 func (h *Mount) Symlink(target, link string) error {
-	fs, fullpath, err := h.getSymlinkAndPath(link)
-	if err != nil {
-		return err
-	}
+	h.m.RLock()
+	mp, fullpath := h.resolve(link)
+	targetMount, _ := h.resolve(filepath.Join(filepath.Dir(link), target))
+	h.m.RUnlock()
 
-	resolved := filepath.Join(filepath.Dir(link), target)
-	if h.isMountpoint(resolved) != h.isMountpoint(link) {
+	if fullpath == "." || targetMount != mp {
 		return fmt.Errorf("invalid symlink, target is crossing filesystems")
 	}
 
-	return fs.Symlink(target, fullpath)
+	return h.filesystem(mp).Symlink(target, fullpath)
 }
 
 func (h *Mount) Join(elem ...string) string {
@@ -160,58 +274,160 @@ func (h *Mount) Lstat(path string) (os.FileInfo, error) {
 		return nil, err
 	}
 
-	return fs.Lstat(fullpath)
+	fi, err := fs.Lstat(fullpath)
+	if os.IsNotExist(err) {
+		return h.implicitDir(path, err)
+	}
+
+	return fi, err
 }
 
 func (h *Mount) Underlying() billy.Basic {
 	return h.underlying
 }
 
-// Capabilities implements the Capable interface.
+// Capabilities implements the Capable interface: the capabilities shared by
+// all the filesystems, except the ones of optional interfaces, which Mount
+// doesn't forward.
 func (fs *Mount) Capabilities() billy.Capability {
-	return billy.Capabilities(fs.underlying) & billy.Capabilities(fs.source)
+	caps := billy.Capabilities(fs.underlying)
+
+	fs.m.RLock()
+	for _, mp := range fs.mounts {
+		caps &= billy.Capabilities(mp.fs)
+	}
+	fs.m.RUnlock()
+
+	return caps &^ billy.InterfaceCapabilities
 }
 
 func (fs *Mount) getBasicAndPath(path string) (billy.Basic, string) {
-	path = cleanPath(path)
-	if !fs.isMountpoint(path) {
-		return fs.underlying, path
-	}
+	fs.m.RLock()
+	defer fs.m.RUnlock()
 
-	return fs.source, fs.mustRelToMountpoint(path)
+	mp, fullpath := fs.resolve(path)
+	return fs.filesystem(mp), fullpath
 }
 
 func (fs *Mount) getDirAndPath(path string) (billy.Dir, string, error) {
-	path = cleanPath(path)
-	if !fs.isMountpoint(path) {
-		return fs.underlying.(billy.Dir), path, nil
-	}
+	fs.m.RLock()
+	defer fs.m.RUnlock()
 
-	return fs.source.(billy.Dir), fs.mustRelToMountpoint(path), nil
+	mp, fullpath := fs.resolve(path)
+	return fs.filesystem(mp), fullpath, nil
 }
 
 func (fs *Mount) getSymlinkAndPath(path string) (billy.Symlink, string, error) {
+	fs.m.RLock()
+	defer fs.m.RUnlock()
+
+	mp, fullpath := fs.resolve(path)
+	return fs.filesystem(mp), fullpath, nil
+}
+
+// resolve returns the mountpoint with the longest path containing path, or
+// nil if path belongs to the underlying filesystem, and path relative to it.
+func (fs *Mount) resolve(path string) (*mounted, string) {
 	path = cleanPath(path)
-	if !fs.isMountpoint(path) {
-		return fs.underlying.(billy.Symlink), path, nil
+
+	var match *mounted
+	rel := path
+	for _, mp := range fs.mounts {
+		if match != nil && len(mp.path) <= len(match.path) {
+			continue
+		}
+
+		if mp.path == path {
+			match, rel = mp, "."
+		} else if r, ok := below(path, mp.path); ok {
+			match, rel = mp, r
+		}
+	}
+
+	return match, rel
+}
+
+func (fs *Mount) filesystem(mp *mounted) billy.Filesystem {
+	if mp == nil {
+		return fs.underlying
 	}
 
-	return fs.source.(billy.Symlink), fs.mustRelToMountpoint(path), nil
+	return mp.fs
 }
 
-func (fs *Mount) mustRelToMountpoint(path string) string {
+// busy reports whether path is, or contains, a mountpoint.
+func (fs *Mount) busy(path string) bool {
 	path = cleanPath(path)
-	fullpath, err := filepath.Rel(fs.mountpoint, path)
-	if err != nil {
-		panic(err)
+	for _, mp := range fs.mounts {
+		if _, ok := below(mp.path, path); ok || mp.path == path {
+			return true
+		}
 	}
 
-	return fullpath
+	return false
 }
 
-func (fs *Mount) isMountpoint(path string) bool {
+// children returns the entries of the directory at path created by the
+// mountpoints: the root of the filesystems mounted right in it, and the
+// directories leading to the ones mounted deeper.
+func (fs *Mount) children(path string) map[string]os.FileInfo {
 	path = cleanPath(path)
-	return strings.HasPrefix(path, fs.mountpoint)
+
+	fs.m.RLock()
+	defer fs.m.RUnlock()
+
+	var children map[string]os.FileInfo
+	for _, mp := range fs.mounts {
+		rel, ok := below(mp.path, path)
+		if !ok {
+			continue
+		}
+		if children == nil {
+			children = make(map[string]os.FileInfo)
+		}
+
+		name := strings.SplitN(rel, separator, 2)[0]
+		if name != rel {
+			if _, ok := children[name]; !ok {
+				children[name] = dirInfo(name)
+			}
+			continue
+		}
+
+		var fi os.FileInfo = dirInfo(name)
+		if root, err := mp.fs.Stat("."); err == nil {
+			fi = &namedInfo{FileInfo: root, name: name}
+		}
+		children[name] = fi
+	}
+
+	return children
+}
+
+// implicitDir returns a directory for path if it doesn't exist but contains
+// mountpoints, and err otherwise.
+func (fs *Mount) implicitDir(path string, err error) (os.FileInfo, error) {
+	fs.m.RLock()
+	busy := fs.busy(path)
+	fs.m.RUnlock()
+
+	if !busy {
+		return nil, err
+	}
+
+	return dirInfo(filepath.Base(cleanPath(path))), nil
+}
+
+// below returns path relative to dir if it is inside of it.
+func below(path, dir string) (string, bool) {
+	if dir == "." {
+		return path, path != "."
+	}
+	if !strings.HasPrefix(path, dir+separator) {
+		return "", false
+	}
+
+	return path[len(dir)+len(separator):], true
 }
 
 func cleanPath(path string) string {
@@ -224,31 +440,25 @@ func cleanPath(path string) string {
 	return filepath.Clean(path)
 }
 
-// copyPath copies a file across filesystems.
-func copyPath(src, dst billy.Basic, srcPath, dstPath string) error {
-	dstFile, err := dst.Create(dstPath)
-	if err != nil {
-		return err
-	}
-
-	srcFile, err := src.Open(srcPath)
-	if err != nil {
-		return err
-	}
+type namedInfo struct {
+	os.FileInfo
+	name string
+}
 
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return err
-	}
+func (fi *namedInfo) Name() string {
+	return fi.name
+}
 
-	err = dstFile.Close()
-	if err != nil {
-		_ = srcFile.Close()
-		return err
-	}
+// dirInfo describes a directory which only exists as the parent of a
+// mountpoint.
+type dirInfo string
 
-	return srcFile.Close()
-}
+func (fi dirInfo) Name() string       { return string(fi) }
+func (fi dirInfo) Size() int64        { return 0 }
+func (fi dirInfo) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (fi dirInfo) ModTime() time.Time { return time.Time{} }
+func (fi dirInfo) IsDir() bool        { return true }
+func (fi dirInfo) Sys() interface{}   { return nil }
 
 type file struct {
 	billy.File
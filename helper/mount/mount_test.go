@@ -1,8 +1,10 @@
 package mount
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
 
 	"github.com/go-git/go-billy/v5"
@@ -165,22 +167,28 @@ func (s *MountSuite) TestRenameCross(c *C) {
 
 	fs := New(underlying, "/foo", source)
 	err := fs.Rename("file", "foo/file")
-	c.Assert(err, IsNil)
+	c.Assert(errors.Is(err, syscall.EXDEV), Equals, true)
 
 	_, err = underlying.Stat("file")
-	c.Assert(err, Equals, os.ErrNotExist)
+	c.Assert(err, IsNil)
 
 	_, err = source.Stat("file")
-	c.Assert(err, IsNil)
+	c.Assert(os.IsNotExist(err), Equals, true)
 
 	err = fs.Rename("foo/file", "file")
-	c.Assert(err, IsNil)
+	c.Assert(errors.Is(err, syscall.EXDEV), Equals, true)
+}
 
-	_, err = underlying.Stat("file")
+func (s *MountSuite) TestRenameMountPoint(c *C) {
+	err := s.Helper.Rename("foo", "bar")
+	c.Assert(err, Equals, os.ErrInvalid)
+
+	err = s.Helper.Mount("bar/qux", memfs.New())
 	c.Assert(err, IsNil)
 
-	_, err = source.Stat("file")
-	c.Assert(err, Equals, os.ErrNotExist)
+	err = s.Helper.Rename("bar", "baz")
+	c.Assert(errors.Is(err, syscall.EBUSY), Equals, true)
+	c.Assert(s.Underlying.RenameArgs, HasLen, 0)
 }
 
 func (s *MountSuite) TestRemove(c *C) {
@@ -368,3 +376,149 @@ func testCapabilities(c *C, a, b billy.Basic) {
 
 	c.Assert(capabilities, Equals, unionCapabilities)
 }
+
+func (s *MountSuite) TestPrefixNotMountPoint(c *C) {
+	_, err := s.Helper.Stat("foobar/qux")
+	c.Assert(err, IsNil)
+
+	c.Assert(s.Underlying.StatArgs, HasLen, 1)
+	c.Assert(s.Underlying.StatArgs[0], Equals, filepath.Join("foobar", "qux"))
+	c.Assert(s.Source.StatArgs, HasLen, 0)
+}
+
+func (s *MountSuite) TestNested(c *C) {
+	root := memfs.New()
+	outer := memfs.New()
+	inner := memfs.New()
+
+	fs := New(root, "/a", outer)
+	c.Assert(fs.Mount("/a/b/c", inner), IsNil)
+
+	c.Assert(util.WriteFile(fs, "a/x", []byte("outer"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "a/b/c/y", []byte("inner"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "a/b/c2", []byte("outer"), 0644), IsNil)
+
+	_, err := outer.Stat("x")
+	c.Assert(err, IsNil)
+	_, err = outer.Stat(filepath.Join("b", "c2"))
+	c.Assert(err, IsNil)
+	_, err = inner.Stat("y")
+	c.Assert(err, IsNil)
+
+	err = fs.Rename("a/x", "a/b/c/x")
+	c.Assert(errors.Is(err, syscall.EXDEV), Equals, true)
+	c.Assert(fs.Rename("a/x", "a/b/x"), IsNil)
+
+	err = fs.Remove("a/b")
+	c.Assert(errors.Is(err, syscall.EBUSY), Equals, true)
+}
+
+func (s *MountSuite) TestReadDirMerge(c *C) {
+	root := memfs.New()
+	c.Assert(util.WriteFile(root, "qux", nil, 0644), IsNil)
+	c.Assert(util.WriteFile(root, "foo/hidden", nil, 0644), IsNil)
+
+	source := memfs.New()
+	c.Assert(util.WriteFile(source, "file", nil, 0644), IsNil)
+
+	fs := New(root, "/foo", source)
+	c.Assert(fs.Mount("/bar/baz", memfs.New()), IsNil)
+
+	c.Assert(readDirNames(c, fs, "/"), DeepEquals, []string{"bar", "foo", "qux"})
+	c.Assert(readDirNames(c, fs, "foo"), DeepEquals, []string{"file"})
+	c.Assert(readDirNames(c, fs, "bar"), DeepEquals, []string{"baz"})
+	c.Assert(readDirNames(c, fs, "bar/baz"), HasLen, 0)
+
+	fi, err := fs.Stat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	_, err = fs.Stat("baz")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MountSuite) TestUnmount(c *C) {
+	root := memfs.New()
+	c.Assert(util.WriteFile(root, "foo/file", []byte("root"), 0644), IsNil)
+
+	source := memfs.New()
+	c.Assert(util.WriteFile(source, "file", []byte("source"), 0644), IsNil)
+
+	fs := New(root, "/foo", source)
+	c.Assert(fs.Mount("foo/bar", memfs.New()), IsNil)
+
+	err := fs.Mount("/foo", memfs.New())
+	c.Assert(errors.Is(err, syscall.EBUSY), Equals, true)
+	err = fs.Mount("/", memfs.New())
+	c.Assert(errors.Is(err, os.ErrInvalid), Equals, true)
+
+	err = fs.Unmount("foo")
+	c.Assert(errors.Is(err, syscall.EBUSY), Equals, true)
+	c.Assert(fs.Unmount("foo/bar"), IsNil)
+
+	err = fs.Unmount("foo/bar")
+	c.Assert(errors.Is(err, syscall.EINVAL), Equals, true)
+
+	b, err := util.ReadFile(fs, "foo/file")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "source")
+
+	c.Assert(fs.Unmount("/foo"), IsNil)
+
+	b, err = util.ReadFile(fs, "foo/file")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "root")
+}
+
+func readDirNames(c *C, fs *Mount, path string) []string {
+	infos, err := fs.ReadDir(path)
+	c.Assert(err, IsNil)
+
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	return names
+}
+
+type closer struct {
+	billy.Filesystem
+	name   string
+	closed *[]string
+	err    error
+}
+
+func (c closer) Close() error {
+	*c.closed = append(*c.closed, c.name)
+	return c.err
+}
+
+func (s *MountSuite) TestClose(c *C) {
+	var closed []string
+	errBar := errors.New("bar")
+
+	h := New(closer{memfs.New(), "root", &closed, nil}, "/foo", closer{memfs.New(), "foo", &closed, nil})
+	c.Assert(h.Mount("/foo/bar", closer{memfs.New(), "bar", &closed, errBar}), IsNil)
+	c.Assert(h.Mount("/qux", memfs.New()), IsNil)
+
+	c.Assert(h.Close(), Equals, errBar)
+	c.Assert(closed, DeepEquals, []string{"bar", "foo", "root"})
+}
+
+func (s *MountSuite) TestNewAtRoot(c *C) {
+	for _, mountpoint := range []string{"/", ""} {
+		underlying, source := memfs.New(), memfs.New()
+		fs := New(underlying, mountpoint, source)
+
+		c.Assert(util.WriteFile(fs, "foo", []byte("foo"), 0644), IsNil)
+		_, err := source.Stat("foo")
+		c.Assert(err, IsNil)
+		_, err = underlying.Stat("foo")
+		c.Assert(os.IsNotExist(err), Equals, true)
+
+		// Mount itself still refuses the root.
+		err = fs.Mount(mountpoint, memfs.New())
+		c.Assert(errors.Is(err, os.ErrInvalid), Equals, true)
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/go-git/go-billy/v5/helper/nfsfs
+
+go 1.19
+
+require (
+	github.com/go-git/go-billy/v5 v5.6.0
+	github.com/willscott/go-nfs v0.0.4
+	github.com/willscott/go-nfs-client v0.0.0-20240104095149-b44639837b00
+)
+
+require (
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	github.com/rasky/go-xdr v0.0.0-20170124162913-1a41d1a06c93 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+)
+
+replace github.com/go-git/go-billy/v5 => ../..
@@ -0,0 +1,194 @@
+// Package nfsfs exports billy filesystems over NFSv3, with
+// github.com/willscott/go-nfs, so that in-memory or composed filesystems can
+// be mounted by containers or virtual machines.
+//
+// It is a module of its own, so that the users of billy don't depend on
+// go-nfs. Handles, which gives the files stable NFS file handles, implements
+// the ToHandle, FromHandle, InvalidateHandle and HandleLimit methods of a
+// go-nfs Handler, and can be embedded in handlers of other kinds than the
+// Handler of this package.
+package nfsfs // import "github.com/go-git/go-billy/v5/helper/nfsfs"
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+)
+
+// MaxHandleSize is the maximum size of an NFSv3 file handle.
+const MaxHandleSize = 64
+
+// DefaultHandleLimit is the number of handles remembered by the Handles
+// created with a limit of zero.
+const DefaultHandleLimit = 1 << 16
+
+// ErrStaleHandle is returned by FromHandle for the handles which don't
+// identify a file anymore, to be reported to the clients as NFS3ERR_STALE.
+var ErrStaleHandle = errors.New("stale NFS file handle")
+
+// The kinds of handles, given by their first byte.
+const (
+	// pathHandle holds the path of the file.
+	pathHandle byte = 1 + iota
+	// hashHandle holds the SHA-256 of a path too long to fit.
+	hashHandle
+	// inodeHandle holds the memfs.Inode identifying the file.
+	inodeHandle
+)
+
+// Handles gives the files of a filesystem stable NFS file handles.
+//
+// The files of a memfs are identified by their inode, so that a handle never
+// refers to another file created at the same path once the file is removed
+// or renamed: such a handle is stale. The files of the other filesystems are
+// identified by their path, held in the handle itself if it fits. These
+// handles are valid across restarts of the server, as long as the path
+// exists. The other ones are remembered for the last HandleLimit files
+// given one.
+type Handles struct {
+	fs    billy.Filesystem
+	limit int
+
+	m       sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type handleEntry struct {
+	handle string
+	path   []string
+}
+
+// NewHandles returns the handles of the files of fs, remembering the last
+// limit ones which can't be resolved alone, or DefaultHandleLimit if limit
+// is zero.
+func NewHandles(fs billy.Filesystem, limit int) *Handles {
+	if limit <= 0 {
+		limit = DefaultHandleLimit
+	}
+
+	return &Handles{
+		fs:      fs,
+		limit:   limit,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// ToHandle returns the handle of the file at path, given as its elements,
+// the root being the empty path. The filesystem given is ignored, the files
+// being always the ones of the filesystem of the Handles.
+func (h *Handles) ToHandle(_ billy.Filesystem, path []string) []byte {
+	var fh []byte
+	if ino, ok := h.inode(path); ok {
+		fh = make([]byte, 17)
+		fh[0] = inodeHandle
+		binary.BigEndian.PutUint64(fh[1:], ino.Dev)
+		binary.BigEndian.PutUint64(fh[9:], ino.Ino)
+	} else {
+		joined := strings.Join(path, "/")
+		if len(joined) < MaxHandleSize {
+			return append([]byte{pathHandle}, joined...)
+		}
+
+		sum := sha256.Sum256([]byte(joined))
+		fh = append([]byte{hashHandle}, sum[:]...)
+	}
+
+	h.remember(string(fh), path)
+	return fh
+}
+
+// FromHandle returns the filesystem and the path of the file identified by
+// fh, or ErrStaleHandle if there is none.
+func (h *Handles) FromHandle(fh []byte) (billy.Filesystem, []string, error) {
+	if len(fh) == 0 {
+		return nil, nil, ErrStaleHandle
+	}
+
+	switch fh[0] {
+	case pathHandle:
+		if len(fh) == 1 {
+			return h.fs, []string{}, nil
+		}
+		return h.fs, strings.Split(string(fh[1:]), "/"), nil
+	case hashHandle, inodeHandle:
+	default:
+		return nil, nil, ErrStaleHandle
+	}
+
+	h.m.Lock()
+	e, ok := h.entries[string(fh)]
+	if ok {
+		h.lru.MoveToFront(e)
+	}
+	h.m.Unlock()
+	if !ok {
+		return nil, nil, ErrStaleHandle
+	}
+
+	path := e.Value.(*handleEntry).path
+	if fh[0] == inodeHandle {
+		// The path might hold another file by now.
+		ino, ok := h.inode(path)
+		if !ok || ino.Dev != binary.BigEndian.Uint64(fh[1:]) || ino.Ino != binary.BigEndian.Uint64(fh[9:]) {
+			_ = h.InvalidateHandle(h.fs, fh)
+			return nil, nil, ErrStaleHandle
+		}
+	}
+
+	return h.fs, path, nil
+}
+
+// InvalidateHandle forgets fh, once its file is removed.
+func (h *Handles) InvalidateHandle(_ billy.Filesystem, fh []byte) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if e, ok := h.entries[string(fh)]; ok {
+		h.lru.Remove(e)
+		delete(h.entries, string(fh))
+	}
+
+	return nil
+}
+
+// HandleLimit returns the number of handles remembered.
+func (h *Handles) HandleLimit() int {
+	return h.limit
+}
+
+func (h *Handles) remember(fh string, path []string) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if e, ok := h.entries[fh]; ok {
+		e.Value.(*handleEntry).path = path
+		h.lru.MoveToFront(e)
+		return
+	}
+
+	h.entries[fh] = h.lru.PushFront(&handleEntry{handle: fh, path: path})
+	for h.lru.Len() > h.limit {
+		e := h.lru.Back()
+		h.lru.Remove(e)
+		delete(h.entries, e.Value.(*handleEntry).handle)
+	}
+}
+
+// inode returns the memfs.Inode of the file at path, if it has one.
+func (h *Handles) inode(path []string) (*memfs.Inode, bool) {
+	fi, err := h.fs.Lstat(h.fs.Join(append([]string{"/"}, path...)...))
+	if err != nil {
+		return nil, false
+	}
+
+	ino, ok := fi.Sys().(*memfs.Inode)
+	return ino, ok
+}
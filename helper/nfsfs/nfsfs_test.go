@@ -0,0 +1,94 @@
+package nfsfs
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestPathHandles(t *testing.T) {
+	fs := osfs.New(t.TempDir())
+	long := strings.Repeat("x", MaxHandleSize)
+	for _, name := range []string{"dir/file", long + "/file"} {
+		if err := util.WriteFile(fs, name, []byte("foo"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewHandles(fs, 0)
+	for _, path := range [][]string{{}, {"dir", "file"}, {long, "file"}} {
+		fh := h.ToHandle(fs, path)
+		if len(fh) > MaxHandleSize {
+			t.Errorf("%v: got a %d bytes handle", path, len(fh))
+		}
+		if again := h.ToHandle(fs, path); !bytes.Equal(fh, again) {
+			t.Errorf("%v: got %x, then %x", path, fh, again)
+		}
+
+		_, got, err := h.FromHandle(fh)
+		if err != nil || strings.Join(got, "/") != strings.Join(path, "/") {
+			t.Errorf("%v: got %v, %v", path, got, err)
+		}
+	}
+
+	// The short paths are resolved by a new server, unlike the long ones.
+	restarted := NewHandles(fs, 0)
+	if _, _, err := restarted.FromHandle(h.ToHandle(fs, []string{"dir", "file"})); err != nil {
+		t.Errorf("got %v", err)
+	}
+	if _, _, err := restarted.FromHandle(h.ToHandle(fs, []string{long, "file"})); err != ErrStaleHandle {
+		t.Errorf("got %v, want stale", err)
+	}
+}
+
+func TestInodeHandles(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandles(fs, 0)
+	fh := h.ToHandle(fs, []string{"file"})
+	if fh[0] != inodeHandle {
+		t.Fatalf("got handle %x, want an inode one", fh)
+	}
+
+	// Another file at the same path doesn't take the handle over.
+	if err := fs.Remove("file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "file", []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.FromHandle(fh); err != ErrStaleHandle {
+		t.Errorf("got %v, want stale", err)
+	}
+	if fh2 := h.ToHandle(fs, []string{"file"}); bytes.Equal(fh, fh2) {
+		t.Error("expected a new handle for the new file")
+	}
+}
+
+func TestHandleLimit(t *testing.T) {
+	fs := memfs.New()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := util.WriteFile(fs, name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewHandles(fs, 2)
+	a := h.ToHandle(fs, []string{"a"})
+	h.ToHandle(fs, []string{"b"})
+	h.ToHandle(fs, []string{"c"})
+
+	if _, _, err := h.FromHandle(a); err != ErrStaleHandle {
+		t.Errorf("got %v, want the oldest handle forgotten", err)
+	}
+	if h.HandleLimit() != 2 {
+		t.Errorf("got limit %d", h.HandleLimit())
+	}
+}
@@ -0,0 +1,67 @@
+package nfsfs
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/go-git/go-billy/v5"
+	nfs "github.com/willscott/go-nfs"
+)
+
+// Handler is a go-nfs handler exporting a filesystem, whatever the path
+// asked by the clients mounting it, to the clients without credentials. The
+// files are identified by the Handles of the filesystem.
+type Handler struct {
+	*Handles
+	fs billy.Filesystem
+}
+
+var _ nfs.Handler = (*Handler)(nil)
+
+// NewHandler returns a handler exporting fs.
+func NewHandler(fs billy.Filesystem) *Handler {
+	return &Handler{Handles: NewHandles(fs, 0), fs: fs}
+}
+
+// Mount accepts every mount request, with AUTH_NULL.
+func (h *Handler) Mount(context.Context, net.Conn, nfs.MountRequest) (nfs.MountStatus, billy.Filesystem, []nfs.AuthFlavor) {
+	return nfs.MountStatusOk, h.fs, []nfs.AuthFlavor{nfs.AuthFlavorNull}
+}
+
+// Change returns the filesystem if it implements billy.Change, and nil
+// otherwise, in which case the attributes can't be changed by the clients.
+func (h *Handler) Change(billy.Filesystem) billy.Change {
+	c, _ := h.fs.(billy.Change)
+	return c
+}
+
+// FSStat fills s with the usage of the volume of the filesystem, where it
+// implements billy.StatFS.
+func (h *Handler) FSStat(_ context.Context, _ billy.Filesystem, s *nfs.FSStat) error {
+	sf, ok := h.fs.(billy.StatFS)
+	if !ok {
+		return nil
+	}
+
+	info, err := sf.StatFS("/")
+	if errors.Is(err, billy.ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.TotalSize, s.FreeSize, s.AvailableSize = info.Total, info.Total-info.Used, info.Free
+	s.TotalFiles, s.FreeFiles, s.AvailableFiles = info.Inodes, info.FreeInodes, info.FreeInodes
+	return nil
+}
+
+// Serve exports fs over NFSv3 to the clients connecting to l, until l is
+// closed. The MOUNT and NFS programs are both served on l, without the
+// portmapper, so that the clients are given its port for both:
+//
+//	mount -t nfs -o vers=3,proto=tcp,port=2049,mountport=2049,nolock host:/ /mnt
+func Serve(l net.Listener, fs billy.Filesystem) error {
+	return nfs.Serve(l, NewHandler(fs))
+}
@@ -0,0 +1,90 @@
+package nfsfs
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	nfsc "github.com/willscott/go-nfs-client/nfs"
+	"github.com/willscott/go-nfs-client/nfs/rpc"
+)
+
+func TestServe(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "dir/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(l, fs)
+
+	c, err := rpc.DialTCP("tcp", l.Addr().String(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	mount := nfsc.Mount{Client: c}
+	target, err := mount.Mount("/", rpc.AuthNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mount.Unmount()
+
+	f, err := target.Open("/dir/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "foo" {
+		t.Errorf("read %q, expected %q", b, "foo")
+	}
+
+	w, err := target.OpenFile("/dir/bar", 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = util.ReadFile(fs, "dir/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "bar" {
+		t.Errorf("wrote %q, expected %q", b, "bar")
+	}
+
+	entries, err := target.ReadDirPlus("/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	if !names["foo"] || !names["bar"] {
+		t.Errorf("listed %v, expected foo and bar", names)
+	}
+
+	if err := target.Remove("/dir/foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("dir/foo"); err == nil {
+		t.Error("dir/foo expected to be removed")
+	}
+}
@@ -0,0 +1,317 @@
+// Package normfs provides a billy filesystem wrapper normalizing the Unicode
+// form of path names, so that a tree written on macOS, which decomposes
+// names (NFD), resolves the same way once copied to Linux, where names are
+// usually composed (NFC), and the other way around.
+package normfs // import "github.com/go-git/go-billy/v5/helper/normfs"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// CollisionError is returned when several names of a directory normalize to
+// the same name, which makes the lookup of that name ambiguous. It matches
+// os.ErrExist.
+type CollisionError struct {
+	// Dir is the directory holding the names, as stored.
+	Dir string
+	// Names are the colliding names, as stored.
+	Names []string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("normfs: names %q of %q have the same normal form", e.Names, e.Dir)
+}
+
+func (e *CollisionError) Unwrap() error {
+	return os.ErrExist
+}
+
+// FS is a billy.Filesystem normalizing the names it is given, and returns,
+// to a Unicode normal form. Each element of a path is looked up by its
+// normal form, so that the existing files are found whatever the form of
+// their stored name, while new files are stored with the normal form.
+type FS struct {
+	underlying billy.Filesystem
+	form       norm.Form
+}
+
+// New creates a new filesystem wrapping up the given 'fs', normalizing the
+// names to form, usually norm.NFC or norm.NFD.
+func New(fs billy.Filesystem, form norm.Form) *FS {
+	return &FS{underlying: fs, form: form}
+}
+
+// resolve returns the name of the file matching filename on the underlying
+// filesystem. The elements of filename are looked up as they are first, and
+// by listing their directory if they don't exist. Once an element doesn't
+// exist, the rest of filename is returned normalized.
+func (fs *FS) resolve(filename string) (string, error) {
+	name := filepath.Clean(fs.form.String(filename))
+	if name == "." || name == string(filepath.Separator) {
+		return name, nil
+	}
+
+	var cur string
+	if filepath.IsAbs(name) {
+		cur = string(filepath.Separator)
+	}
+
+	elems := strings.Split(strings.TrimPrefix(name, string(filepath.Separator)), string(filepath.Separator))
+	for i, elem := range elems {
+		next := fs.underlying.Join(cur, elem)
+		if elem == ".." {
+			cur = next
+			continue
+		}
+
+		if _, err := fs.underlying.Lstat(next); err == nil || !os.IsNotExist(err) {
+			cur = next
+			continue
+		}
+
+		stored, err := fs.lookup(cur, elem)
+		if err != nil {
+			return "", err
+		}
+		if stored == "" {
+			return fs.underlying.Join(append([]string{cur}, elems[i:]...)...), nil
+		}
+
+		cur = fs.underlying.Join(cur, stored)
+	}
+
+	return cur, nil
+}
+
+// lookup returns the name of the entry of dir whose normal form is elem, or
+// an empty string if there is none.
+func (fs *FS) lookup(dir, elem string) (string, error) {
+	infos, err := fs.underlying.ReadDir(dir)
+	if err != nil {
+		return "", nil
+	}
+
+	var found []string
+	for _, fi := range infos {
+		if fs.form.String(fi.Name()) == elem {
+			found = append(found, fi.Name())
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	}
+
+	return "", &CollisionError{Dir: dir, Names: found}
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.underlying.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: fs.form.String(f.Name())}, nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.underlying.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(fi), nil
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.underlying.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fs.info(fi), nil
+}
+
+// Rename moves from to to. If to names an existing file with another form
+// of the same name, that file is replaced.
+func (fs *FS) Rename(from, to string) error {
+	oldname, err := fs.resolve(from)
+	if err != nil {
+		return err
+	}
+
+	newname, err := fs.resolve(to)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.Rename(oldname, newname)
+}
+
+func (fs *FS) Remove(filename string) error {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.Remove(name)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	name, err := fs.resolve(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.underlying.TempFile(name, fs.form.String(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, name: fs.form.String(f.Name())}, nil
+}
+
+// ReadDir returns the entries of path with normalized names. It fails with
+// a *CollisionError if several entries have the same normal form.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	name, err := fs.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := fs.underlying.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]string, len(infos))
+	result := make([]os.FileInfo, 0, len(infos))
+	for _, fi := range infos {
+		n := fs.form.String(fi.Name())
+		if prev, ok := seen[n]; ok {
+			names := []string{prev, fi.Name()}
+			sort.Strings(names)
+			return nil, &CollisionError{Dir: name, Names: names}
+		}
+
+		seen[n] = fi.Name()
+		result = append(result, fs.info(fi))
+	}
+
+	return result, nil
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	name, err := fs.resolve(filename)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.MkdirAll(name, perm)
+}
+
+// Symlink creates link, pointing to target normalized. The target is
+// resolved by the underlying filesystem, so it should only go through names
+// in normal form.
+func (fs *FS) Symlink(target, link string) error {
+	name, err := fs.resolve(link)
+	if err != nil {
+		return err
+	}
+
+	return fs.underlying.Symlink(fs.form.String(target), name)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	name, err := fs.resolve(link)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := fs.underlying.Readlink(name)
+	if err != nil {
+		return "", err
+	}
+
+	return fs.form.String(target), nil
+}
+
+// Chroot returns a chrooted view of fs, normalizing the names the same way.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, minus the capabilities of
+// the optional interfaces, which aren't forwarded.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) info(fi os.FileInfo) os.FileInfo {
+	if name := fs.form.String(fi.Name()); name != fi.Name() {
+		return &namedInfo{FileInfo: fi, name: name}
+	}
+
+	return fi
+}
+
+type namedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *namedInfo) Name() string {
+	return fi.name
+}
+
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
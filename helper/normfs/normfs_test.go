@@ -0,0 +1,117 @@
+package normfs
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	nfc = "café"
+	nfd = "café"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), norm.NFC)
+	})
+}
+
+func TestLookup(t *testing.T) {
+	mem := memfs.New()
+	if err := util.WriteFile(mem, nfd+"/"+nfd+".txt", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(mem, norm.NFC)
+
+	for _, name := range []string{nfc + "/" + nfc + ".txt", nfd + "/" + nfc + ".txt", nfd + "/" + nfd + ".txt"} {
+		b, err := util.ReadFile(fs, name)
+		if err != nil || string(b) != "foo" {
+			t.Errorf("ReadFile(%q): got %q, %v", name, b, err)
+		}
+	}
+
+	infos, err := fs.ReadDir(nfc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != nfc+".txt" {
+		t.Errorf("unexpected entries %v", infos)
+	}
+
+	fi, err := fs.Stat(nfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != nfc {
+		t.Errorf("expected name %q, got %q", nfc, fi.Name())
+	}
+
+	// Writing through another form replaces the existing file.
+	if err := util.WriteFile(fs, nfc+"/"+nfc+".txt", []byte("bar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	infos, err = mem.ReadDir(nfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != nfd+".txt" {
+		t.Errorf("unexpected stored entries %v", infos)
+	}
+
+	// New files are stored in normal form.
+	f, err := fs.Create(nfd + "/new-" + nfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != nfc+"/new-"+nfc {
+		t.Errorf("unexpected file name %q", f.Name())
+	}
+	f.Close()
+	if _, err := mem.Stat(nfd + "/new-" + nfc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollision(t *testing.T) {
+	mem := memfs.New()
+	for _, name := range []string{"dir/" + nfc, "dir/" + nfd} {
+		if err := util.WriteFile(mem, name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := New(mem, norm.NFD)
+
+	var collision *CollisionError
+	_, err := fs.ReadDir("dir")
+	if !errors.As(err, &collision) || len(collision.Names) != 2 {
+		t.Errorf("expected a collision, got %v", err)
+	}
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+
+	// The stored normal form is found directly, while looking up a name
+	// stored in other forms only is ambiguous.
+	if _, err := fs.Stat("dir/" + nfd); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"other/e\u0301\u0323", "other/e\u0323\u0301"} {
+		if err := util.WriteFile(mem, name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := New(mem, norm.NFC).Stat("other/e\u0301\u0323"); !errors.As(err, &collision) {
+		t.Errorf("expected a collision, got %v", err)
+	}
+}
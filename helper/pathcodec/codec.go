@@ -0,0 +1,125 @@
+package pathcodec
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Codec encodes the elements of the paths, the names of the files, into
+// names the underlying filesystem can store, and decodes them back. Encode
+// must be injective, and Decode its inverse.
+type Codec interface {
+	// Encode returns the name under which the file name is stored. name is
+	// never empty, "." or "..".
+	Encode(name string) string
+	// Decode returns the name of the file stored under name, or an error if
+	// name isn't the encoding of any name.
+	Decode(name string) (string, error)
+}
+
+// ErrInvalidName is wrapped by the errors of Decode when the stored name
+// isn't the encoding of any name.
+var ErrInvalidName = errors.New("not an encoded name")
+
+// Escape returns a Codec percent-encoding the bytes of illegal, along with
+// the control characters and the percent sign itself, as "%XX" with X an
+// upper case hexadecimal digit.
+func Escape(illegal string) Codec {
+	return &escapeCodec{illegal: illegal}
+}
+
+// Windows is a Codec for the names Windows refuses: the characters
+// <>:"\|?*, the control characters, a trailing dot or space, and the device
+// names, such as "CON" or "nul.txt", whose first character is encoded.
+var Windows Codec = &escapeCodec{illegal: `<>:"\|?*`, trailing: ". ", reserved: isReservedName}
+
+const upperhex = "0123456789ABCDEF"
+
+type escapeCodec struct {
+	illegal string
+	// trailing are the characters encoded at the end of a name.
+	trailing string
+	// reserved reports the names whose first character is encoded.
+	reserved func(name string) bool
+}
+
+func (c *escapeCodec) escaped(b byte) bool {
+	return b < 0x20 || b == 0x7f || b == '%' || strings.IndexByte(c.illegal, b) >= 0
+}
+
+func (c *escapeCodec) Encode(name string) string {
+	var sb strings.Builder
+	sb.Grow(len(name))
+
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		switch {
+		case c.escaped(b),
+			i == 0 && c.reserved != nil && c.reserved(name),
+			i == len(name)-1 && strings.IndexByte(c.trailing, b) >= 0:
+			sb.WriteByte('%')
+			sb.WriteByte(upperhex[b>>4])
+			sb.WriteByte(upperhex[b&0xf])
+		default:
+			sb.WriteByte(b)
+		}
+	}
+
+	return sb.String()
+}
+
+// Decode decodes name, refusing the encodings Encode doesn't produce, so
+// that every name is stored under a single encoding.
+func (c *escapeCodec) Decode(name string) (string, error) {
+	if strings.IndexByte(name, '%') < 0 {
+		if c.Encode(name) != name {
+			return "", fmt.Errorf("%q: %w", name, ErrInvalidName)
+		}
+		return name, nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(name))
+
+	for i := 0; i < len(name); i++ {
+		if name[i] != '%' {
+			sb.WriteByte(name[i])
+			continue
+		}
+
+		if i+2 >= len(name) {
+			return "", fmt.Errorf("%q: %w", name, ErrInvalidName)
+		}
+		hi, lo := strings.IndexByte(upperhex, name[i+1]), strings.IndexByte(upperhex, name[i+2])
+		if hi < 0 || lo < 0 {
+			return "", fmt.Errorf("%q: %w", name, ErrInvalidName)
+		}
+		sb.WriteByte(byte(hi<<4 | lo))
+		i += 2
+	}
+
+	decoded := sb.String()
+	if c.Encode(decoded) != name {
+		return "", fmt.Errorf("%q: %w", name, ErrInvalidName)
+	}
+
+	return decoded, nil
+}
+
+// isReservedName reports whether name is a device name on Windows, which
+// ignores the trailing spaces and the extensions.
+func isReservedName(name string) bool {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+
+	switch strings.ToUpper(strings.TrimRight(name, " ")) {
+	case "CON", "PRN", "AUX", "NUL", "CONIN$", "CONOUT$",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,260 @@
+// Package pathcodec provides a billy filesystem wrapper encoding the names
+// of the files, so that trees holding names a backend can't store, such as
+// the ones with characters illegal in its namespace, can be stored there
+// all the same.
+package pathcodec // import "github.com/go-git/go-billy/v5/helper/pathcodec"
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// FS is a billy.Filesystem storing every element of the paths it is given
+// under its encoding by a Codec, and decoding the names it returns, from
+// ReadDir, Stat, Readlink, File.Name and the errors, so that the encoding
+// round-trips transparently.
+//
+// The entries of the underlying filesystem whose names aren't encodings,
+// which weren't written through FS, are left out of ReadDir.
+//
+// FS only encodes the names; the ones too long for the backend once encoded
+// can be shortened by wrapping a hashname filesystem:
+//
+//	short, err := hashname.New(backend, hashname.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	fs := pathcodec.New(short, pathcodec.Windows)
+type FS struct {
+	underlying billy.Filesystem
+	codec      Codec
+}
+
+// New creates a new filesystem wrapping up the given 'fs', encoding the
+// names with codec.
+func New(fs billy.Filesystem, codec Codec) *FS {
+	return &FS{underlying: fs, codec: codec}
+}
+
+// Layer returns the billy.Layer of New, for billy.Compose.
+func Layer(codec Codec) billy.Layer {
+	return billy.Layer{
+		Name: "pathcodec",
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs, codec), nil
+		},
+	}
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == filepath.Separator
+}
+
+// mapPath applies fn to the elements of name other than ".", ".." and the
+// volume name, keeping the separators.
+func mapPath(name string, fn func(string) (string, error)) (string, error) {
+	vol := filepath.VolumeName(name)
+
+	var sb strings.Builder
+	sb.WriteString(vol)
+
+	rest := name[len(vol):]
+	for rest != "" {
+		i := strings.IndexFunc(rest, isSeparator)
+		if i == 0 {
+			sb.WriteByte(rest[0])
+			rest = rest[1:]
+			continue
+		}
+
+		elem := rest
+		if i > 0 {
+			elem = rest[:i]
+		}
+		rest = rest[len(elem):]
+
+		if elem != "." && elem != ".." {
+			var err error
+			if elem, err = fn(elem); err != nil {
+				return "", err
+			}
+		}
+		sb.WriteString(elem)
+	}
+
+	return sb.String(), nil
+}
+
+func (fs *FS) encode(name string) string {
+	encoded, _ := mapPath(name, func(elem string) (string, error) {
+		return fs.codec.Encode(elem), nil
+	})
+
+	return encoded
+}
+
+func (fs *FS) decode(name string) (string, error) {
+	return mapPath(name, fs.codec.Decode)
+}
+
+// decodeOrKeep decodes name, or returns it as it is if it isn't encoded.
+func (fs *FS) decodeOrKeep(name string) string {
+	if decoded, err := fs.decode(name); err == nil {
+		return decoded
+	}
+
+	return name
+}
+
+// restore decodes the paths of err, an *os.PathError or *os.LinkError
+// returned by the underlying filesystem.
+func (fs *FS) restore(err error) error {
+	switch e := err.(type) {
+	case *os.PathError:
+		return &os.PathError{Op: e.Op, Path: fs.decodeOrKeep(e.Path), Err: e.Err}
+	case *os.LinkError:
+		return &os.LinkError{Op: e.Op, Old: fs.decodeOrKeep(e.Old), New: fs.decodeOrKeep(e.New), Err: e.Err}
+	}
+
+	return err
+}
+
+func (fs *FS) wrap(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, fs.restore(err)
+	}
+
+	return &file{File: f, name: fs.decodeOrKeep(f.Name())}, nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.wrap(fs.underlying.Create(fs.encode(filename)))
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.wrap(fs.underlying.Open(fs.encode(filename)))
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.wrap(fs.underlying.OpenFile(fs.encode(filename), flag, perm))
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	fi, err := fs.underlying.Stat(fs.encode(filename))
+	if err != nil {
+		return nil, fs.restore(err)
+	}
+
+	return fs.info(fi), nil
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := fs.underlying.Lstat(fs.encode(filename))
+	if err != nil {
+		return nil, fs.restore(err)
+	}
+
+	return fs.info(fi), nil
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return fs.restore(fs.underlying.Rename(fs.encode(from), fs.encode(to)))
+}
+
+func (fs *FS) Remove(filename string) error {
+	return fs.restore(fs.underlying.Remove(fs.encode(filename)))
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	// The prefix is encoded on its own, the random suffix appended by the
+	// underlying filesystem being made of digits.
+	return fs.wrap(fs.underlying.TempFile(fs.encode(dir), fs.codec.Encode(prefix)))
+}
+
+// ReadDir returns the entries of path with their decoded names, leaving out
+// the ones whose names aren't encodings.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := fs.underlying.ReadDir(fs.encode(path))
+	if err != nil {
+		return nil, fs.restore(err)
+	}
+
+	result := make([]os.FileInfo, 0, len(infos))
+	for _, fi := range infos {
+		if _, err := fs.codec.Decode(fi.Name()); err != nil {
+			continue
+		}
+		result = append(result, fs.info(fi))
+	}
+
+	return result, nil
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.restore(fs.underlying.MkdirAll(fs.encode(filename), perm))
+}
+
+// Symlink creates link, pointing to target encoded, so that the underlying
+// filesystem resolves it to the encoded names.
+func (fs *FS) Symlink(target, link string) error {
+	return fs.restore(fs.underlying.Symlink(fs.encode(target), fs.encode(link)))
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	target, err := fs.underlying.Readlink(fs.encode(link))
+	if err != nil {
+		return "", fs.restore(err)
+	}
+
+	return fs.decodeOrKeep(target), nil
+}
+
+// Chroot returns a chrooted view of fs, encoding the names the same way.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, minus the capabilities of
+// the optional interfaces, which aren't forwarded.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) info(fi os.FileInfo) os.FileInfo {
+	name, err := fs.codec.Decode(fi.Name())
+	if err != nil || name == fi.Name() {
+		return fi
+	}
+
+	return &namedInfo{FileInfo: fi, name: name}
+}
+
+type namedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *namedInfo) Name() string {
+	return fi.name
+}
+
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
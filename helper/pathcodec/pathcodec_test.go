@@ -0,0 +1,95 @@
+package pathcodec
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), Windows)
+	})
+}
+
+func TestWindows(t *testing.T) {
+	for name, encoded := range map[string]string{
+		"plain.txt":  "plain.txt",
+		"a:b?c":      "a%3Ab%3Fc",
+		"100%":       "100%25",
+		"trailing. ": "trailing.%20",
+		"dot.":       "dot%2E",
+		"CON":        "%43ON",
+		"nul.txt":    "%6Eul.txt",
+		"console":    "console",
+		"tab\there":  "tab%09here",
+	} {
+		if got := Windows.Encode(name); got != encoded {
+			t.Errorf("Encode(%q) = %q, want %q", name, got, encoded)
+		}
+		if got, err := Windows.Decode(encoded); err != nil || got != name {
+			t.Errorf("Decode(%q) = %q, %v, want %q", encoded, got, err, name)
+		}
+	}
+
+	for _, name := range []string{"a:b", "%3a", "%3", "%41", "CON", "dot."} {
+		if _, err := Windows.Decode(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Decode(%q) = %v, want %v", name, err, ErrInvalidName)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	mem := memfs.New()
+	fs := New(mem, Escape("*"))
+
+	if err := util.WriteFile(fs, "a*b/c*d", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("c*d", "a*b/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mem.Stat("a%2Ab/c%2Ad"); err != nil {
+		t.Fatalf("encoded name not stored: %v", err)
+	}
+	if err := util.WriteFile(mem, "a%2Ab/c%2ad", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := fs.ReadDir("a*b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+	if len(names) != 2 || names[0] != "c*d" || names[1] != "link" {
+		t.Errorf("ReadDir = %q", names)
+	}
+
+	data, err := util.ReadFile(fs, "a*b/link")
+	if err != nil || string(data) != "foo" {
+		t.Errorf("ReadFile(link) = %q, %v", data, err)
+	}
+	if target, err := fs.Readlink("a*b/link"); err != nil || target != "c*d" {
+		t.Errorf("Readlink = %q, %v", target, err)
+	}
+
+	fi, err := fs.Stat("a*b/c*d")
+	if err != nil || fi.Name() != "c*d" {
+		t.Errorf("Stat = %v, %v", fi, err)
+	}
+
+	_, err = fs.Stat("a*b/missing*")
+	var pe *os.PathError
+	if !errors.As(err, &pe) || pe.Path != "a*b/missing*" {
+		t.Errorf("Stat(missing) = %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+// Package policyfs provides a billy filesystem wrapper allowing or denying
+// its operations by path, with glob rules per class of operation, such as
+// forbidding the writes outside of a directory of an otherwise writable
+// filesystem.
+package policyfs // import "github.com/go-git/go-billy/v5/helper/policyfs"
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Class is a set of classes of operations the rules apply to.
+type Class int
+
+const (
+	// Read covers the operations reading files, directories, links and
+	// their attributes, and the files opened without write flags.
+	Read Class = 1 << iota
+	// Write covers the operations creating or modifying files and
+	// directories, their attributes and hard links, as well as the
+	// destination of Rename.
+	Write
+	// Delete covers Remove and the source of Rename.
+	Delete
+	// Symlink covers the creation of symbolic links, checked against the
+	// path of the link.
+	Symlink
+
+	// All covers every class of operations.
+	All = Read | Write | Delete | Symlink
+)
+
+var classNames = []struct {
+	c    Class
+	name string
+}{
+	{Read, "read"},
+	{Write, "write"},
+	{Delete, "delete"},
+	{Symlink, "symlink"},
+}
+
+func (c Class) String() string {
+	var names []string
+	for _, n := range classNames {
+		if c&n.c != 0 {
+			names = append(names, n.name)
+			c &^= n.c
+		}
+	}
+	if c != 0 || len(names) == 0 {
+		names = append(names, "Class("+strconv.Itoa(int(c))+")")
+	}
+
+	return strings.Join(names, "|")
+}
+
+// Rule allows or denies the operations of some classes on the paths matching
+// a pattern.
+type Rule struct {
+	// Classes lists the classes of the operations the rule applies to.
+	Classes Class
+	// Pattern selects the paths the rule applies to, relative to the root of
+	// the FS. It has the syntax of util.Glob: "clusters/**" matches the
+	// clusters directory and everything below it.
+	Pattern string
+	// Allow allows the operations, which are denied otherwise.
+	Allow bool
+}
+
+func (r Rule) String() string {
+	effect := "deny"
+	if r.Allow {
+		effect = "allow"
+	}
+
+	return effect + " " + r.Classes.String() + " " + strconv.Quote(r.Pattern)
+}
+
+// Error is returned by the operations denied by a rule. It matches
+// fs.ErrPermission.
+type Error struct {
+	Op   string
+	Path string
+	// Class is the class of the operation.
+	Class Class
+	// Rule is the rule which denied it.
+	Rule Rule
+}
+
+func (e *Error) Error() string {
+	return e.Op + " " + strconv.Quote(e.Path) + ": " + e.Class.String() + " denied by rule " + e.Rule.String()
+}
+
+func (e *Error) Unwrap() error {
+	return fs.ErrPermission
+}
+
+// FS is a filesystem wrapper checking its operations against rules. The first
+// rule matching both the class of an operation and its path decides whether
+// it is allowed; the operations matched by no rule are allowed. Denying
+// everything but the writes in clusters takes two rules:
+//
+//	policyfs.New(fs,
+//		policyfs.Rule{Classes: policyfs.Write | policyfs.Delete | policyfs.Symlink, Pattern: "clusters/**", Allow: true},
+//		policyfs.Rule{Classes: policyfs.Write | policyfs.Delete | policyfs.Symlink, Pattern: "**"},
+//	)
+//
+// The paths are matched lexically: a symbolic link in an allowed directory
+// gives access to where it points. Denying Symlink, or wrapping a filesystem
+// which doesn't follow the links out of a directory, avoids that.
+//
+// The temporary files are checked against the name of their directory joined
+// to their prefix or pattern, before the random part of their name is known.
+type FS struct {
+	wrapper.Base
+	rules []Rule
+}
+
+// New returns a filesystem wrapping fs, checking its operations against
+// rules. It fails with filepath.ErrBadPattern if one of the patterns is
+// malformed.
+func New(fs billy.Filesystem, rules ...Rule) (*FS, error) {
+	for _, r := range rules {
+		if _, err := util.MatchPath(r.Pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &FS{Base: wrapper.NewBase(fs), rules: rules}, nil
+}
+
+// Layer returns the billy.Layer of New, for billy.Compose.
+func Layer(rules ...Rule) billy.Layer {
+	return billy.Layer{
+		Name: "policy",
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs, rules...)
+		},
+	}
+}
+
+// Check returns an *Error if the operation op, of the class c, is denied on
+// name.
+func (fs *FS) Check(op string, c Class, name string) error {
+	clean := filepath.Clean(string(filepath.Separator) + name)
+	for _, r := range fs.rules {
+		if r.Classes&c == 0 {
+			continue
+		}
+		if ok, _ := util.MatchPath(r.Pattern, clean); !ok {
+			continue
+		}
+		if r.Allow {
+			return nil
+		}
+
+		return &Error{Op: op, Path: name, Class: c, Rule: r}
+	}
+
+	return nil
+}
+
+func openClass(flag int) Class {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return Write
+	}
+
+	return Read
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	if err := fs.Check("create", Write, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Create(filename)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	if err := fs.Check("open", Read, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Open(filename)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if err := fs.Check("open", openClass(flag), filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.OpenFile(filename, flag, perm)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	if err := fs.Check("stat", Read, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Stat(filename)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	if err := fs.Check("lstat", Read, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Lstat(filename)
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	if err := fs.Check("readdir", Read, path); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.ReadDir(path)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	if err := fs.Check("readlink", Read, link); err != nil {
+		return "", err
+	}
+
+	return fs.Base.Readlink(link)
+}
+
+// Rename checks from against Delete and to against Write.
+func (fs *FS) Rename(from, to string) error {
+	if err := fs.Check("rename", Delete, from); err != nil {
+		return err
+	}
+	if err := fs.Check("rename", Write, to); err != nil {
+		return err
+	}
+
+	return fs.Base.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	if err := fs.Check("remove", Delete, filename); err != nil {
+		return err
+	}
+
+	return fs.Base.Remove(filename)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.Check("tempfile", Write, fs.Join(dir, prefix)); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.TempFile(dir, prefix)
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	if err := fs.Check("createtemp", Write, fs.Join(dir, pattern)); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	if err := fs.Check("mkdirtemp", Write, fs.Join(dir, pattern)); err != nil {
+		return "", err
+	}
+
+	return fs.Base.MkdirTemp(dir, pattern)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	if err := fs.Check("mkdir", Write, filename); err != nil {
+		return err
+	}
+
+	return fs.Base.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	if err := fs.Check("symlink", Symlink, link); err != nil {
+		return err
+	}
+
+	return fs.Base.Symlink(target, link)
+}
+
+// Link implements billy.Linker, checking newname against Write.
+func (fs *FS) Link(oldname, newname string) error {
+	if err := fs.Check("link", Write, newname); err != nil {
+		return err
+	}
+
+	return fs.Base.Link(oldname, newname)
+}
+
+// Chmod implements billy.Change.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	if err := fs.Check("chmod", Write, name); err != nil {
+		return err
+	}
+
+	return fs.Base.Chmod(name, mode)
+}
+
+// Lchown implements billy.Change.
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	if err := fs.Check("lchown", Write, name); err != nil {
+		return err
+	}
+
+	return fs.Base.Lchown(name, uid, gid)
+}
+
+// Chown implements billy.Change.
+func (fs *FS) Chown(name string, uid, gid int) error {
+	if err := fs.Check("chown", Write, name); err != nil {
+		return err
+	}
+
+	return fs.Base.Chown(name, uid, gid)
+}
+
+// Chtimes implements billy.Change.
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.Check("chtimes", Write, name); err != nil {
+		return err
+	}
+
+	return fs.Base.Chtimes(name, atime, mtime)
+}
+
+// Getxattr implements billy.Xattrer.
+func (fs *FS) Getxattr(name, attr string) ([]byte, error) {
+	if err := fs.Check("getxattr", Read, name); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Getxattr(name, attr)
+}
+
+// Listxattr implements billy.Xattrer.
+func (fs *FS) Listxattr(name string) ([]string, error) {
+	if err := fs.Check("listxattr", Read, name); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Listxattr(name)
+}
+
+// Setxattr implements billy.Xattrer.
+func (fs *FS) Setxattr(name, attr string, value []byte) error {
+	if err := fs.Check("setxattr", Write, name); err != nil {
+		return err
+	}
+
+	return fs.Base.Setxattr(name, attr, value)
+}
+
+// Removexattr implements billy.Xattrer.
+func (fs *FS) Removexattr(name, attr string) error {
+	if err := fs.Check("removexattr", Write, name); err != nil {
+		return err
+	}
+
+	return fs.Base.Removexattr(name, attr)
+}
+
+// Exchange checks both paths against Write and Delete, each of them being
+// replaced by the other.
+func (fs *FS) Exchange(x, y string) error {
+	for _, name := range []string{x, y} {
+		if err := fs.Check("exchange", Delete, name); err != nil {
+			return err
+		}
+		if err := fs.Check("exchange", Write, name); err != nil {
+			return err
+		}
+	}
+
+	return fs.Base.Exchange(x, y)
+}
+
+// Prefetch implements billy.Prefetcher.
+func (fs *FS) Prefetch(path string, off, length int64) error {
+	if err := fs.Check("prefetch", Read, path); err != nil {
+		return err
+	}
+
+	return fs.Base.Prefetch(path, off, length)
+}
+
+// Chroot returns a chrooted view of fs, whose paths are checked as paths of
+// fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
@@ -0,0 +1,116 @@
+package policyfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		fs, err := New(memfs.New(), Rule{Classes: All, Pattern: "**", Allow: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fs
+	})
+}
+
+func newClustersFS(t *testing.T) billy.Filesystem {
+	base := memfs.New()
+	for _, name := range []string{"clusters/prod/app.yaml", "apps/app.yaml", "secret"} {
+		if err := util.WriteFile(base, name, []byte("foo"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writes := Write | Delete | Symlink
+	fs, err := New(base,
+		Rule{Classes: All, Pattern: "secret"},
+		Rule{Classes: writes, Pattern: "clusters/**", Allow: true},
+		Rule{Classes: writes, Pattern: "**"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func assertDenied(t *testing.T, err error, class Class, pattern string) {
+	t.Helper()
+
+	var perr *Error
+	if !errors.As(err, &perr) || !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("got %v, want a permission error", err)
+	}
+	if perr.Class != class || perr.Rule.Pattern != pattern || perr.Rule.Allow {
+		t.Errorf("denied %s by %s, want %s by %q", perr.Class, perr.Rule, class, pattern)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	fs := newClustersFS(t)
+
+	if err := util.WriteFile(fs, "clusters/prod/new.yaml", nil, 0644); err != nil {
+		t.Error(err)
+	}
+	if err := fs.Rename("clusters/prod/new.yaml", "clusters/new.yaml"); err != nil {
+		t.Error(err)
+	}
+	if _, err := util.ReadFile(fs, "apps/app.yaml"); err != nil {
+		t.Error(err)
+	}
+
+	assertDenied(t, util.WriteFile(fs, "apps/app.yaml", nil, 0644), Write, "**")
+	assertDenied(t, fs.Remove("apps/app.yaml"), Delete, "**")
+	assertDenied(t, fs.Rename("clusters/new.yaml", "apps/new.yaml"), Write, "**")
+	assertDenied(t, fs.Rename("apps/app.yaml", "clusters/app.yaml"), Delete, "**")
+	assertDenied(t, fs.Symlink("clusters", "link"), Symlink, "**")
+	assertDenied(t, fs.MkdirAll("clusters/../apps/dir", 0755), Write, "**")
+
+	_, err := fs.Open("secret")
+	assertDenied(t, err, Read, "secret")
+	_, err = fs.OpenFile("apps/app.yaml", os.O_RDWR, 0)
+	assertDenied(t, err, Write, "**")
+	_, err = fs.TempFile("apps", "tmp")
+	assertDenied(t, err, Write, "**")
+}
+
+func TestPolicyChroot(t *testing.T) {
+	fs, err := newClustersFS(t).Chroot("clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.WriteFile(fs, "prod/other.yaml", nil, 0644); err != nil {
+		t.Error(err)
+	}
+
+	_, err = fs.Create(filepath.Join("..", "apps", "other.yaml"))
+	if !errors.Is(err, billy.ErrCrossedBoundary) {
+		t.Errorf("got %v, want ErrCrossedBoundary", err)
+	}
+}
+
+func TestNewBadPattern(t *testing.T) {
+	_, err := New(memfs.New(), Rule{Classes: Read, Pattern: "[a"})
+	if err != filepath.ErrBadPattern {
+		t.Errorf("got %v, want ErrBadPattern", err)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	err := &Error{Op: "remove", Path: "apps/app.yaml", Class: Delete, Rule: Rule{Classes: Write | Delete, Pattern: "**"}}
+	want := `remove "apps/app.yaml": delete denied by rule deny write|delete "**"`
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,123 @@
+package polyfill
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// path returns the path of filename in the underlying filesystem. Within an
+// emulated chroot, filename is checked not to lead outside of it, through
+// ".." elements or symbolic links.
+func (h *Polyfill) path(filename string) (string, error) {
+	if h.base == "" {
+		return filename, nil
+	}
+	if isCrossBoundaries(filename) {
+		return "", billy.ErrCrossedBoundary
+	}
+	if h.c.symlink {
+		root := filepath.Join(string(filepath.Separator), h.base)
+		_, err := util.SecureJoinVFSStrict(root, filepath.FromSlash(filename), util.NewVFS(h.Basic))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(h.base, filepath.Clean(string(filepath.Separator)+filepath.FromSlash(filename))), nil
+}
+
+// pathNoFollow is like path, the last element of filename not being
+// checked, for the operations acting on a symbolic link itself.
+func (h *Polyfill) pathNoFollow(filename string) (string, error) {
+	if h.base == "" || !h.c.symlink || isCrossBoundaries(filename) {
+		return h.path(filename)
+	}
+
+	dir, name := filepath.Split(filepath.Clean(string(filepath.Separator) + filepath.FromSlash(filename)))
+	parent, err := h.path(dir)
+	if err != nil || name == "" {
+		return parent, err
+	}
+
+	return filepath.Join(parent, name), nil
+}
+
+// restore rewrites the paths of err, an *os.PathError or *os.LinkError
+// returned by the underlying filesystem, relative to the emulated chroot.
+func (h *Polyfill) restore(err error) error {
+	if h.base == "" {
+		return err
+	}
+
+	switch e := err.(type) {
+	case *os.PathError:
+		return &os.PathError{Op: e.Op, Path: h.rel(e.Path), Err: e.Err}
+	case *os.LinkError:
+		return &os.LinkError{Op: e.Op, Old: h.rel(e.Old), New: h.rel(e.New), Err: e.Err}
+	}
+
+	return err
+}
+
+// rel returns path, a path of the underlying filesystem, relative to the
+// emulated chroot.
+func (h *Polyfill) rel(path string) string {
+	return strings.TrimPrefix(h.visible(path), string(filepath.Separator))
+}
+
+// visible returns the absolute path seen through the emulated chroot of
+// path, a path of the underlying filesystem.
+func (h *Polyfill) visible(path string) string {
+	rel, err := filepath.Rel(filepath.Join(string(filepath.Separator), h.base), filepath.Join(string(filepath.Separator), path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return filepath.Join(string(filepath.Separator), rel)
+}
+
+func isCrossBoundaries(path string) bool {
+	path = filepath.Clean(filepath.ToSlash(path))
+	return path == ".." || strings.HasPrefix(path, "../")
+}
+
+// file returns f, named as seen through the emulated chroot if there is
+// one, and locked within the process if LockCapability is emulated.
+func (h *Polyfill) file(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, h.restore(err)
+	}
+
+	if h.emulates(billy.LockCapability) {
+		f = &lockFile{File: f, locks: h.locks, path: h.lockPath(f.Name())}
+	}
+	if h.base != "" {
+		f = &file{File: f, name: h.rel(f.Name())}
+	}
+
+	return f, nil
+}
+
+type file struct {
+	billy.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+// Stat describes the file if the underlying one implements
+// billy.FileStater.
+func (f *file) Stat() (os.FileInfo, error) {
+	s, ok := f.File.(billy.FileStater)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return s.Stat()
+}
@@ -0,0 +1,101 @@
+package polyfill_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/polyfill"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
+)
+
+// The interfaces are embedded under other names, so that their fields don't
+// shadow the TempFile and Symlink methods.
+type (
+	tempFile  = billy.TempFile
+	symlinker = billy.Symlink
+)
+
+// noChroot is a memfs filesystem hiding its billy.Chroot implementation.
+type noChroot struct {
+	billy.Basic
+	billy.Dir
+	tempFile
+	symlinker
+}
+
+func newNoChroot() *noChroot {
+	fs := memfs.New()
+	return &noChroot{Basic: fs, Dir: fs, tempFile: fs, symlinker: fs}
+}
+
+// ChrootSuite runs the conformance suites on a Chroot emulated by polyfill.
+type ChrootSuite struct {
+	test.FilesystemSuite
+}
+
+var _ = check.Suite(&ChrootSuite{})
+
+func (s *ChrootSuite) SetUpTest(c *check.C) {
+	fs, err := polyfill.New(newNoChroot()).Chroot("base")
+	c.Assert(err, check.IsNil)
+	s.FilesystemSuite = test.NewFilesystemSuite(fs)
+}
+
+// TestSymlinkWithChrootCrossBounders checks that the link leading outside of
+// the nested chroot is refused, where the other filesystems follow it.
+func (s *ChrootSuite) TestSymlinkWithChrootCrossBounders(c *check.C) {
+	qux, err := s.FS.Chroot("/qux")
+	c.Assert(err, check.IsNil)
+	c.Assert(util.WriteFile(s.FS, "file", []byte("foo"), 0644), check.IsNil)
+
+	c.Assert(qux.Symlink("../../file", "qux/link"), check.IsNil)
+	_, err = qux.Stat("qux/link")
+	c.Assert(errors.Is(err, billy.ErrCrossedBoundary), check.Equals, true)
+}
+
+func TestChrootSymlinks(t *testing.T) {
+	underlying := newNoChroot()
+	if err := util.WriteFile(underlying.Basic, "secret", []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(underlying.Basic, "base/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := polyfill.New(underlying).Chroot("base")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Symlink("/foo", "abs"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := underlying.Readlink("base/abs"); err != nil || target != filepath.Join(string(filepath.Separator), "base", "foo") {
+		t.Errorf("got %q, %v in the underlying filesystem", target, err)
+	}
+	if target, err := fs.Readlink("abs"); err != nil || target != filepath.Join(string(filepath.Separator), "foo") {
+		t.Errorf("got %q, %v", target, err)
+	}
+
+	// The links can't lead outside of the chroot.
+	if err := fs.Symlink("../secret", "escape"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.ReadFile(fs, "escape"); !errors.Is(err, billy.ErrCrossedBoundary) {
+		t.Errorf("got %v, want crossed boundary", err)
+	}
+
+	f, err := fs.Open("abs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Name() != "abs" {
+		t.Errorf("got %q, want the name in the chroot", f.Name())
+	}
+}
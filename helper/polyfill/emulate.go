@@ -0,0 +1,214 @@
+package polyfill
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// EmulatedCapabilities lists the capabilities Options.Emulate can select.
+const EmulatedCapabilities = billy.SymlinkCapability | billy.HardlinkCapability | billy.LockCapability
+
+// Options configures the filesystems returned by NewWithOptions.
+type Options struct {
+	// Emulate selects the capabilities emulated when the wrapped filesystem
+	// lacks them, instead of failing with billy.ErrNotSupported. The
+	// capabilities not in EmulatedCapabilities are ignored. Each emulation
+	// has its trade-offs:
+	//
+	//   - SymlinkCapability: Symlink copies the file or the directory it
+	//     points to, so later changes of either side aren't seen by the
+	//     other one. Lstat is Stat, and Readlink fails with EINVAL as for a
+	//     file which isn't a link.
+	//   - HardlinkCapability: Link copies the file, which is then
+	//     independent of the original one.
+	//   - LockCapability: the files are locked within the process, by path,
+	//     the other processes not being excluded, nor the files opened
+	//     without going through this filesystem or its chroots. Lock blocks
+	//     until the file is unlocked or closed by its holder.
+	Emulate billy.Capability
+}
+
+// NewWithOptions is like New, emulating the capabilities selected by opts.
+// The filesystems implementing billy.Filesystem are wrapped too if they
+// lack one of these capabilities.
+func NewWithOptions(fs billy.Basic, opts Options) billy.Filesystem {
+	h := newPolyfill(fs)
+
+	e := opts.Emulate & EmulatedCapabilities
+	if h.c.symlink {
+		e &^= billy.SymlinkCapability
+	}
+	if h.c.link {
+		e &^= billy.HardlinkCapability
+	}
+	if billy.CapabilityCheck(fs, billy.LockCapability) {
+		e &^= billy.LockCapability
+	}
+	if e == 0 {
+		return New(fs)
+	}
+
+	h.emulate = e
+	if e&billy.LockCapability != 0 {
+		h.locks = &locker{held: make(map[string]*pathLock)}
+	}
+
+	return h
+}
+
+func (h *Polyfill) emulates(c billy.Capability) bool {
+	return h.emulate&c != 0
+}
+
+// lockPath returns the path the file named name by the wrapped filesystem is
+// locked by, the same for all the chroots sharing the locks.
+func (h *Polyfill) lockPath(name string) string {
+	if h.c.chroot {
+		return filepath.Join(string(filepath.Separator), h.Basic.(billy.Chroot).Root(), name)
+	}
+
+	return filepath.Join(string(filepath.Separator), name)
+}
+
+// copySymlink emulates Symlink, copying target to link.
+func (h *Polyfill) copySymlink(target, link string) error {
+	src := target
+	if !filepath.IsAbs(target) && !filepath.IsAbs(filepath.FromSlash(target)) {
+		src = filepath.Join(filepath.Dir(link), target)
+	}
+
+	if _, err := h.Stat(link); err == nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrExist}
+	}
+
+	return h.copy(src, link)
+}
+
+// copy copies the file or the directory at src to dst.
+func (h *Polyfill) copy(src, dst string) error {
+	fi, err := h.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return util.CopyFile(h, dst, h, src)
+	}
+
+	if err := h.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	fis, err := h.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if err := h.copy(filepath.Join(src, fi.Name()), filepath.Join(dst, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// locker holds the locks of the files, by path, when LockCapability is
+// emulated.
+type locker struct {
+	m    sync.Mutex
+	held map[string]*pathLock
+}
+
+type pathLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+func (l *locker) lock(path string) {
+	l.m.Lock()
+	pl, ok := l.held[path]
+	if !ok {
+		pl = &pathLock{ch: make(chan struct{}, 1)}
+		l.held[path] = pl
+	}
+	pl.refs++
+	l.m.Unlock()
+
+	pl.ch <- struct{}{}
+}
+
+func (l *locker) unlock(path string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	pl := l.held[path]
+	<-pl.ch
+	if pl.refs--; pl.refs == 0 {
+		delete(l.held, path)
+	}
+}
+
+// lockFile is a file locked within the process.
+type lockFile struct {
+	billy.File
+	locks *locker
+	path  string
+
+	m      sync.Mutex
+	locked bool
+}
+
+// Lock locks the file, doing nothing if its lock is already held.
+func (f *lockFile) Lock() error {
+	f.m.Lock()
+	locked := f.locked
+	f.m.Unlock()
+	if locked {
+		return nil
+	}
+
+	f.locks.lock(f.path)
+
+	f.m.Lock()
+	f.locked = true
+	f.m.Unlock()
+
+	return nil
+}
+
+// Unlock releases the lock of the file, doing nothing if it isn't held.
+func (f *lockFile) Unlock() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.locked {
+		f.locked = false
+		f.locks.unlock(f.path)
+	}
+
+	return nil
+}
+
+// Close closes the file, releasing its lock.
+func (f *lockFile) Close() error {
+	f.Unlock()
+	return f.File.Close()
+}
+
+// Stat describes the file if the underlying one implements
+// billy.FileStater.
+func (f *lockFile) Stat() (os.FileInfo, error) {
+	s, ok := f.File.(billy.FileStater)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return s.Stat()
+}
+
+func errNotLink(link string) error {
+	return &os.PathError{Op: "readlink", Path: link, Err: syscall.EINVAL}
+}
@@ -0,0 +1,140 @@
+package polyfill_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/polyfill"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// noLinks is a memfs filesystem without symbolic nor hard links.
+type noLinks struct {
+	billy.Basic
+	billy.Dir
+}
+
+func newNoLinks() *noLinks {
+	fs := memfs.New()
+	return &noLinks{Basic: fs, Dir: fs}
+}
+
+// noLocks is a memfs filesystem which doesn't report LockCapability.
+type noLocks struct {
+	billy.Basic
+	billy.Dir
+}
+
+func newNoLocks() *noLocks {
+	fs := memfs.New()
+	return &noLocks{Basic: fs, Dir: fs}
+}
+
+func (fs *noLocks) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.Basic) &^ billy.LockCapability
+}
+
+func TestEmulateSymlink(t *testing.T) {
+	fs := polyfill.NewWithOptions(newNoLinks(), polyfill.Options{Emulate: billy.SymlinkCapability})
+	if !billy.CapabilityCheck(fs, billy.SymlinkCapability) {
+		t.Error("expected the symbolic links to be reported")
+	}
+
+	if err := util.WriteFile(fs, "dir/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("foo", "dir/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("dir", "copy"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/link", "copy/foo", "copy/link"} {
+		if got, err := util.ReadFile(fs, name); err != nil || string(got) != "foo" {
+			t.Errorf("%s: got %q, %v", name, got, err)
+		}
+	}
+
+	if _, err := fs.Readlink("dir/link"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("got %v, want EINVAL", err)
+	}
+	if fi, err := fs.Lstat("dir/link"); err != nil || fi.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("got %v, %v", fi, err)
+	}
+	if err := fs.Symlink("foo", "dir/link"); !os.IsExist(err) {
+		t.Errorf("got %v, want exist", err)
+	}
+}
+
+func TestEmulateHardlink(t *testing.T) {
+	fs := polyfill.NewWithOptions(newNoLinks(), polyfill.Options{Emulate: billy.HardlinkCapability})
+	if billy.CapabilityCheck(fs, billy.SymlinkCapability) {
+		t.Error("expected the symbolic links not to be emulated")
+	}
+	if err := fs.Symlink("foo", "link"); err != billy.ErrNotSupported {
+		t.Errorf("got %v, want not supported", err)
+	}
+
+	if err := util.WriteFile(fs, "foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.(billy.Linker).Link("foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(fs, "bar"); err != nil || string(got) != "foo" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestEmulateLock(t *testing.T) {
+	fs := polyfill.NewWithOptions(newNoLocks(), polyfill.Options{Emulate: billy.LockCapability})
+	if !billy.CapabilityCheck(fs, billy.LockCapability) {
+		t.Error("expected the locks to be reported")
+	}
+
+	a, err := fs.Create("dir/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chroot, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := chroot.Open("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if err := a.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan error)
+	go func() { locked <- b.Lock() }()
+	select {
+	case err := <-locked:
+		t.Fatalf("got %v, want the lock to be held", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected closing the file to release its lock")
+	}
+	if err := b.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,17 +3,39 @@ package polyfill
 import (
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 )
 
 // Polyfill is a helper that implements all missing method from billy.Filesystem.
+//
+// Chroot is emulated for the filesystems not implementing billy.Chroot, the
+// paths being prefixed with the directory chrooted to. If the filesystem
+// implements billy.Symlink, the symbolic links are resolved with
+// util.SecureJoinVFS so that they can't lead outside of that directory. The
+// files opened through such a chroot only implement billy.File and
+// billy.FileStater.
 type Polyfill struct {
 	billy.Basic
 	c capabilities
+
+	// base is the directory of the underlying filesystem the paths are
+	// relative to, set by Chroot when the underlying filesystem doesn't
+	// implement billy.Chroot. It is empty at the root.
+	base string
+
+	// emulate holds the capabilities emulated, see Options.
+	emulate billy.Capability
+	locks   *locker
 }
 
-type capabilities struct{ tempfile, dir, symlink, chroot bool }
+type capabilities struct {
+	tempfile, dir, symlink, chroot, change, xattr, link, syncdir, prefetch, opendir bool
+}
 
 // New creates a new filesystem wrapping up 'fs' the intercepts all the calls
 // made and errors if fs doesn't implement any of the billy interfaces.
@@ -22,21 +44,156 @@ func New(fs billy.Basic) billy.Filesystem {
 		return original
 	}
 
+	return newPolyfill(fs)
+}
+
+func newPolyfill(fs billy.Basic) *Polyfill {
 	h := &Polyfill{Basic: fs}
 
 	_, h.c.tempfile = h.Basic.(billy.TempFile)
 	_, h.c.dir = h.Basic.(billy.Dir)
 	_, h.c.symlink = h.Basic.(billy.Symlink)
 	_, h.c.chroot = h.Basic.(billy.Chroot)
+	_, h.c.change = h.Basic.(billy.Change)
+	_, h.c.xattr = h.Basic.(billy.Xattrer)
+	_, h.c.link = h.Basic.(billy.Linker)
+	_, h.c.syncdir = h.Basic.(billy.DirSyncer)
+	_, h.c.prefetch = h.Basic.(billy.Prefetcher)
+	_, h.c.opendir = h.Basic.(billy.DirIterator)
 	return h
 }
 
+func (h *Polyfill) Create(filename string) (billy.File, error) {
+	fullpath, err := h.path(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.file(h.Basic.Create(fullpath))
+}
+
+func (h *Polyfill) Open(filename string) (billy.File, error) {
+	fullpath, err := h.path(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.file(h.Basic.Open(fullpath))
+}
+
+func (h *Polyfill) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fullpath, err := h.path(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.file(h.Basic.OpenFile(fullpath, flag, perm))
+}
+
+func (h *Polyfill) Stat(filename string) (os.FileInfo, error) {
+	fullpath, err := h.path(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := h.Basic.Stat(fullpath)
+	return fi, h.restore(err)
+}
+
+func (h *Polyfill) Rename(from, to string) error {
+	from, err := h.pathNoFollow(from)
+	if err != nil {
+		return err
+	}
+	to, err = h.pathNoFollow(to)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.Rename(from, to))
+}
+
+func (h *Polyfill) Remove(filename string) error {
+	fullpath, err := h.pathNoFollow(filename)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.Remove(fullpath))
+}
+
 func (h *Polyfill) TempFile(dir, prefix string) (billy.File, error) {
 	if !h.c.tempfile {
 		return nil, billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.TempFile).TempFile(dir, prefix)
+	fullpath, err := h.path(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.file(h.Basic.(billy.TempFile).TempFile(fullpath, prefix))
+}
+
+// CreateTemp implements billy.TempCreator, with util.CreateTemp if the
+// underlying filesystem doesn't create temporary files itself.
+func (h *Polyfill) CreateTemp(dir, pattern string) (billy.File, error) {
+	if h.base == "" {
+		return util.CreateTemp(h.Basic, dir, pattern)
+	}
+
+	fullpath, err := h.path(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.file(util.CreateTemp(h.Basic, fullpath, pattern))
+}
+
+// MkdirTemp implements billy.TempCreator, with util.MkdirTemp if the
+// underlying filesystem doesn't create temporary directories itself.
+func (h *Polyfill) MkdirTemp(dir, pattern string) (string, error) {
+	if !h.c.dir {
+		return "", billy.ErrNotSupported
+	}
+
+	if h.base == "" {
+		return util.MkdirTemp(h.Basic.(billy.Dir), dir, pattern)
+	}
+
+	fullpath, err := h.path(dir)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := util.MkdirTemp(h.Basic.(billy.Dir), fullpath, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return h.rel(name), nil
+}
+
+// StatFS implements billy.StatFS, returning billy.ErrNotSupported if the
+// underlying filesystem doesn't report its usage.
+func (h *Polyfill) StatFS(path string) (*billy.FSInfo, error) {
+	s, ok := h.Basic.(billy.StatFS)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.StatFS(fullpath)
+}
+
+// Close implements billy.Closer, closing the underlying filesystem if it
+// holds resources.
+func (h *Polyfill) Close() error {
+	return billy.Close(h.Basic)
 }
 
 func (h *Polyfill) ReadDir(path string) ([]os.FileInfo, error) {
@@ -44,7 +201,13 @@ func (h *Polyfill) ReadDir(path string) ([]os.FileInfo, error) {
 		return nil, billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.Dir).ReadDir(path)
+	fullpath, err := h.path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fis, err := h.Basic.(billy.Dir).ReadDir(fullpath)
+	return fis, h.restore(err)
 }
 
 func (h *Polyfill) MkdirAll(filename string, perm os.FileMode) error {
@@ -52,54 +215,361 @@ func (h *Polyfill) MkdirAll(filename string, perm os.FileMode) error {
 		return billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.Dir).MkdirAll(filename, perm)
+	fullpath, err := h.path(filename)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Dir).MkdirAll(fullpath, perm))
 }
 
 func (h *Polyfill) Symlink(target, link string) error {
 	if !h.c.symlink {
+		if h.emulates(billy.SymlinkCapability) {
+			return h.copySymlink(target, link)
+		}
 		return billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.Symlink).Symlink(target, link)
+	if h.base != "" && (filepath.IsAbs(target) || strings.HasPrefix(target, string(filepath.Separator))) {
+		target = filepath.Join(string(filepath.Separator), h.base, target)
+	}
+
+	fullpath, err := h.pathNoFollow(link)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Symlink).Symlink(target, fullpath))
 }
 
 func (h *Polyfill) Readlink(link string) (string, error) {
 	if !h.c.symlink {
+		if h.emulates(billy.SymlinkCapability) {
+			if _, err := h.Stat(link); err != nil {
+				return "", err
+			}
+			return "", errNotLink(link)
+		}
 		return "", billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.Symlink).Readlink(link)
+	fullpath, err := h.pathNoFollow(link)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := h.Basic.(billy.Symlink).Readlink(fullpath)
+	if err != nil {
+		return "", h.restore(err)
+	}
+	if h.base == "" || !filepath.IsAbs(target) && !strings.HasPrefix(target, string(filepath.Separator)) {
+		return target, nil
+	}
+
+	return h.visible(target), nil
 }
 
 func (h *Polyfill) Lstat(path string) (os.FileInfo, error) {
 	if !h.c.symlink {
+		if h.emulates(billy.SymlinkCapability) {
+			return h.Stat(path)
+		}
 		return nil, billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.Symlink).Lstat(path)
+	fullpath, err := h.pathNoFollow(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := h.Basic.(billy.Symlink).Lstat(fullpath)
+	return fi, h.restore(err)
 }
 
+// Chroot returns a filesystem rooted at path. If the underlying filesystem
+// doesn't implement billy.Chroot, it is emulated by prefixing the paths with
+// path, see Polyfill.
 func (h *Polyfill) Chroot(path string) (billy.Filesystem, error) {
-	if !h.c.chroot {
-		return nil, billy.ErrNotSupported
+	if h.c.chroot {
+		fs, err := h.Basic.(billy.Chroot).Chroot(path)
+		if err != nil || h.emulate == 0 {
+			return fs, err
+		}
+		fs = NewWithOptions(fs, Options{Emulate: h.emulate})
+		if p, ok := fs.(*Polyfill); ok && p.locks != nil {
+			p.locks = h.locks
+		}
+		return fs, nil
+	}
+	if isCrossBoundaries(path) {
+		return nil, billy.ErrCrossedBoundary
+	}
+
+	fullpath, err := h.path(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return h.Basic.(billy.Chroot).Chroot(path)
+	return &Polyfill{Basic: h.Basic, c: h.c, base: fullpath, emulate: h.emulate, locks: h.locks}, nil
 }
 
+// Root returns the root of the underlying filesystem, or the directory a
+// Chroot emulated is rooted at.
 func (h *Polyfill) Root() string {
-	if !h.c.chroot {
-		return string(filepath.Separator)
+	if h.c.chroot {
+		return h.Basic.(billy.Chroot).Root()
+	}
+	if h.base != "" {
+		return h.base
+	}
+
+	return string(filepath.Separator)
+}
+
+func (h *Polyfill) Link(oldname, newname string) error {
+	if !h.c.link {
+		if h.emulates(billy.HardlinkCapability) {
+			if _, err := h.Stat(newname); err == nil {
+				return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+			}
+			return util.CopyFile(h, newname, h, oldname)
+		}
+		return billy.ErrNotSupported
+	}
+
+	oldname, err := h.pathNoFollow(oldname)
+	if err != nil {
+		return err
+	}
+	newname, err = h.pathNoFollow(newname)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Linker).Link(oldname, newname))
+}
+
+func (h *Polyfill) SyncDir(path string) error {
+	if !h.c.syncdir {
+		return billy.ErrNotSupported
 	}
 
-	return h.Basic.(billy.Chroot).Root()
+	fullpath, err := h.path(path)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.DirSyncer).SyncDir(fullpath))
+}
+
+func (h *Polyfill) OpenDir(path string) (billy.DirIter, error) {
+	if !h.c.opendir {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := h.Basic.(billy.DirIterator).OpenDir(fullpath)
+	return it, h.restore(err)
+}
+
+func (h *Polyfill) Prefetch(path string, off, length int64) error {
+	if !h.c.prefetch {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(path)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Prefetcher).Prefetch(fullpath, off, length))
+}
+
+func (h *Polyfill) Chmod(name string, mode os.FileMode) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Change).Chmod(fullpath, mode))
+}
+
+func (h *Polyfill) Lchown(name string, uid, gid int) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.pathNoFollow(name)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Change).Lchown(fullpath, uid, gid))
+}
+
+func (h *Polyfill) Chown(name string, uid, gid int) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Change).Chown(fullpath, uid, gid))
+}
+
+func (h *Polyfill) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !h.c.change {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return err
+	}
+
+	return h.restore(h.Basic.(billy.Change).Chtimes(fullpath, atime, mtime))
+}
+
+func (h *Polyfill) Getxattr(name, attr string) ([]byte, error) {
+	if !h.c.xattr {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Basic.(billy.Xattrer).Getxattr(fullpath, attr)
+}
+
+func (h *Polyfill) Setxattr(name, attr string, value []byte) error {
+	if !h.c.xattr {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return err
+	}
+
+	return h.Basic.(billy.Xattrer).Setxattr(fullpath, attr, value)
+}
+
+func (h *Polyfill) Listxattr(name string) ([]string, error) {
+	if !h.c.xattr {
+		return nil, billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Basic.(billy.Xattrer).Listxattr(fullpath)
+}
+
+func (h *Polyfill) Removexattr(name, attr string) error {
+	if !h.c.xattr {
+		return billy.ErrNotSupported
+	}
+
+	fullpath, err := h.path(name)
+	if err != nil {
+		return err
+	}
+
+	return h.Basic.(billy.Xattrer).Removexattr(fullpath, attr)
+}
+
+// Watch implements billy.Watcher. If the wrapped filesystem doesn't
+// implement it, the channel returned is closed after a single event holding
+// billy.ErrNotSupported.
+func (h *Polyfill) Watch(path string) (<-chan billy.Event, func()) {
+	w, ok := h.Basic.(billy.Watcher)
+	if !ok {
+		return watchError(billy.ErrNotSupported)
+	}
+
+	fullpath, err := h.path(path)
+	if err != nil {
+		return watchError(err)
+	}
+	if h.base == "" {
+		return w.Watch(fullpath)
+	}
+
+	in, stop := w.Watch(fullpath)
+	out := make(chan billy.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+		for e := range in {
+			if e.Name != "" {
+				e.Name = strings.TrimPrefix(h.visible(e.Name), string(filepath.Separator))
+			}
+
+			select {
+			case out <- e:
+			case <-done:
+				// Drain in until stop closes it.
+			}
+		}
+	}()
+
+	var once sync.Once
+	return out, func() {
+		once.Do(func() {
+			close(done)
+			stop()
+		})
+	}
+}
+
+func watchError(err error) (<-chan billy.Event, func()) {
+	ch := make(chan billy.Event, 1)
+	ch <- billy.Event{Err: err}
+	close(ch)
+
+	return ch, func() {}
 }
 
 func (h *Polyfill) Underlying() billy.Basic {
 	return h.Basic
 }
 
-// Capabilities implements the Capable interface.
+// Capabilities implements the Capable interface. The capabilities depending
+// on an interface not implemented by the wrapped filesystem are removed,
+// unless they are emulated.
 func (h *Polyfill) Capabilities() billy.Capability {
-	return billy.Capabilities(h.Basic)
+	caps := billy.Capabilities(h.Basic)
+	if !h.c.symlink {
+		caps &^= billy.SymlinkCapability
+	}
+	if !h.c.change {
+		caps &^= billy.ChangeCapability
+	}
+	if !h.c.xattr {
+		caps &^= billy.XattrCapability
+	}
+	if !h.c.link {
+		caps &^= billy.HardlinkCapability
+	}
+	if !h.c.syncdir {
+		caps &^= billy.SyncCapability
+	}
+
+	return caps | h.emulate
 }
@@ -3,6 +3,7 @@ package polyfill
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/test"
@@ -28,11 +29,48 @@ func (s *PolyfillSuite) TestTempFile(c *C) {
 	c.Assert(err, Equals, billy.ErrNotSupported)
 }
 
+func (s *PolyfillSuite) TestCreateTemp(c *C) {
+	f, err := s.Helper.(billy.TempCreator).CreateTemp("foo", "bar*")
+	c.Assert(err, IsNil)
+	c.Assert(f.Name(), Matches, filepath.Join("foo", "bar")+"[0-9]+")
+
+	_, err = s.Helper.(billy.TempCreator).MkdirTemp("foo", "bar*")
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+
 func (s *PolyfillSuite) TestReadDir(c *C) {
 	_, err := s.Helper.ReadDir("")
 	c.Assert(err, Equals, billy.ErrNotSupported)
 }
 
+func (s *PolyfillSuite) TestChange(c *C) {
+	ch := s.Helper.(billy.Change)
+	c.Assert(ch.Chmod("", 0), Equals, billy.ErrNotSupported)
+	c.Assert(ch.Lchown("", 0, 0), Equals, billy.ErrNotSupported)
+	c.Assert(ch.Chown("", 0, 0), Equals, billy.ErrNotSupported)
+	c.Assert(ch.Chtimes("", time.Time{}, time.Time{}), Equals, billy.ErrNotSupported)
+}
+
+type allCapFs struct {
+	test.BasicMock
+}
+
+func (*allCapFs) Capabilities() billy.Capability {
+	return billy.AllCapabilities
+}
+
+func (s *PolyfillSuite) TestCapabilitiesMasked(c *C) {
+	caps := billy.Capabilities(New(&allCapFs{}))
+	c.Assert(caps&billy.SymlinkCapability, Equals, billy.Capability(0))
+	c.Assert(caps&billy.InterfaceCapabilities, Equals, billy.Capability(0))
+	c.Assert(caps&billy.LockCapability, Equals, billy.LockCapability)
+}
+
+func (s *PolyfillSuite) TestLink(c *C) {
+	err := s.Helper.(billy.Linker).Link("", "")
+	c.Assert(err, Equals, billy.ErrNotSupported)
+}
+
 func (s *PolyfillSuite) TestMkdirAll(c *C) {
 	err := s.Helper.MkdirAll("", 0)
 	c.Assert(err, Equals, billy.ErrNotSupported)
@@ -54,8 +92,12 @@ func (s *PolyfillSuite) TestLstat(c *C) {
 }
 
 func (s *PolyfillSuite) TestChroot(c *C) {
-	_, err := s.Helper.Chroot("")
-	c.Assert(err, Equals, billy.ErrNotSupported)
+	fs, err := s.Helper.Chroot("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fs.Root(), Equals, "foo")
+
+	_, err = s.Helper.Chroot("../foo")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
 }
 
 func (s *PolyfillSuite) TestRoot(c *C) {
@@ -0,0 +1,235 @@
+// Package redact provides a billy filesystem wrapper removing the host path
+// of its root from the errors it returns, so that error messages only show
+// paths relative to the root, e.g. "open /foo: no such file or directory"
+// instead of "open /srv/tenants/a/foo: no such file or directory".
+package redact // import "github.com/go-git/go-billy/v5/helper/redact"
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// FS is a billy.Filesystem which forwards every call to the wrapped
+// filesystem, redacting the errors returned.
+type FS struct {
+	underlying billy.Filesystem
+	root       string
+}
+
+// New creates a new filesystem wrapping up the given 'fs', redacting its
+// root, as returned by fs.Root(), from the errors.
+func New(fs billy.Filesystem) *FS {
+	return &FS{underlying: fs, root: filepath.Clean(fs.Root())}
+}
+
+// Error returns err with every occurrence of root removed from the paths of
+// the *os.PathError, *os.LinkError and *os.SyscallError in its chain.
+// Other errors mentioning root are wrapped into an error with a redacted
+// message, which still unwraps to the original error.
+func Error(err error, root string) error {
+	if err == nil {
+		return nil
+	}
+
+	root = filepath.Clean(root)
+	if root == "." || filepath.Dir(root) == root {
+		return err
+	}
+
+	return redactInner(err, root)
+}
+
+func redact(err error, root string) error {
+	switch e := err.(type) {
+	case *os.PathError:
+		return &os.PathError{Op: e.Op, Path: scrub(e.Path, root), Err: redactInner(e.Err, root)}
+	case *os.LinkError:
+		return &os.LinkError{Op: e.Op, Old: scrub(e.Old, root), New: scrub(e.New, root), Err: redactInner(e.Err, root)}
+	case *os.SyscallError:
+		return &os.SyscallError{Syscall: e.Syscall, Err: redactInner(e.Err, root)}
+	}
+
+	msg := err.Error()
+	if redacted := scrub(msg, root); redacted != msg {
+		return &redactedError{msg: redacted, err: err}
+	}
+
+	return err
+}
+
+func redactInner(err error, root string) error {
+	if err == nil || !strings.Contains(err.Error(), root) {
+		return err
+	}
+
+	return redact(err, root)
+}
+
+// scrub removes root from s where it appears as a whole path prefix.
+func scrub(s, root string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, root)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+
+		rest := s[i+len(root):]
+		b.WriteString(s[:i])
+		if rest == "" || rest[0] != filepath.Separator && !isPathChar(rest[0]) {
+			b.WriteByte(filepath.Separator)
+		} else if !os.IsPathSeparator(rest[0]) {
+			// root is only a prefix of another name, keep it.
+			b.WriteString(root)
+		}
+
+		s = rest
+	}
+}
+
+func isPathChar(c byte) bool {
+	return c == '.' || c == '-' || c == '_' || c == '~' ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+func (fs *FS) redact(err error) error {
+	return Error(err, fs.root)
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.wrap(fs.underlying.Create(filename))
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.wrap(fs.underlying.Open(filename))
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.wrap(fs.underlying.OpenFile(filename, flag, perm))
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	fi, err := fs.underlying.Stat(filename)
+	return fi, fs.redact(err)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return fs.redact(fs.underlying.Rename(from, to))
+}
+
+func (fs *FS) Remove(filename string) error {
+	return fs.redact(fs.underlying.Remove(filename))
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.wrap(fs.underlying.TempFile(dir, prefix))
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := fs.underlying.ReadDir(path)
+	return infos, fs.redact(err)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.redact(fs.underlying.MkdirAll(filename, perm))
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := fs.underlying.Lstat(filename)
+	return fi, fs.redact(err)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return fs.redact(fs.underlying.Symlink(target, link))
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	target, err := fs.underlying.Readlink(link)
+	return target, fs.redact(err)
+}
+
+// Chroot returns a chrooted view of fs, redacting the same root.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, minus the capabilities of
+// the optional interfaces, which aren't forwarded.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) wrap(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, fs.redact(err)
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+type file struct {
+	billy.File
+	fs *FS
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	return n, f.fs.redact(err)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	return n, f.fs.redact(err)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	return n, f.fs.redact(err)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.File.Seek(offset, whence)
+	return n, f.fs.redact(err)
+}
+
+func (f *file) Close() error {
+	return f.fs.redact(f.File.Close())
+}
+
+func (f *file) Lock() error {
+	return f.fs.redact(f.File.Lock())
+}
+
+func (f *file) Unlock() error {
+	return f.fs.redact(f.File.Unlock())
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.fs.redact(f.File.Truncate(size))
+}
@@ -0,0 +1,62 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/test"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New())
+	})
+}
+
+func TestRedactOS(t *testing.T) {
+	dir := t.TempDir()
+	fs := New(osfs.New(dir))
+
+	_, err := fs.Open("foo/bar")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+	if strings.Contains(err.Error(), dir) {
+		t.Errorf("error leaks the root: %v", err)
+	}
+	if !strings.Contains(err.Error(), "foo/bar") {
+		t.Errorf("error misses the relative path: %v", err)
+	}
+
+	err = fs.Rename("foo", "bar")
+	if strings.Contains(err.Error(), dir) {
+		t.Errorf("error leaks the root: %v", err)
+	}
+}
+
+func TestError(t *testing.T) {
+	root := "/srv/tenant"
+	for _, tc := range []struct {
+		err      error
+		expected string
+	}{
+		{&os.PathError{Op: "open", Path: "/srv/tenant/foo", Err: os.ErrNotExist}, "open /foo: file does not exist"},
+		{&os.PathError{Op: "open", Path: "/srv/tenant2/foo", Err: os.ErrNotExist}, "open /srv/tenant2/foo: file does not exist"},
+		{fmt.Errorf("walking /srv/tenant: %w", os.ErrPermission), "walking /: permission denied"},
+		{errors.New("unrelated"), "unrelated"},
+	} {
+		err := Error(tc.err, root)
+		if err.Error() != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, err)
+		}
+		if cause := errors.Unwrap(tc.err); cause != nil && !errors.Is(err, cause) {
+			t.Errorf("%q: expected to unwrap to the original cause", err)
+		}
+	}
+}
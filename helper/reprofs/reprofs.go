@@ -0,0 +1,334 @@
+// Package reprofs provides a billy filesystem wrapper normalizing the
+// modification times and the permissions of the entries written through it,
+// so that the trees it renders are reproducible: written twice, from the
+// same content, on any platform, they archive and hash identically.
+package reprofs // import "github.com/go-git/go-billy/v5/helper/reprofs"
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+)
+
+const (
+	defaultFileMode = 0o644
+	defaultExecMode = 0o755
+	defaultDirMode  = 0o755
+)
+
+// Options configures the normalization of an FS. The zero value gives the
+// entries the Unix epoch as modification time, and the modes Git restores.
+type Options struct {
+	// ModTime is the modification, and access, time of every entry
+	// written. The Unix epoch is used if it is zero.
+	ModTime time.Time
+	// FileMode is the mode of the regular files, 0644 if zero.
+	FileMode os.FileMode
+	// ExecMode is the mode of the regular files created, or changed, with
+	// any executable bit, 0755 if zero.
+	ExecMode os.FileMode
+	// DirMode is the mode of the directories, 0755 if zero.
+	DirMode os.FileMode
+}
+
+// FS is a filesystem wrapper normalizing what it writes:
+//
+//   - The regular files get FileMode, or ExecMode if they are created or
+//     changed with any executable bit, the umask notwithstanding, and the
+//     directories DirMode. The special bits are dropped.
+//   - The files written, once closed, the entries created, renamed or
+//     removed, and their parent directories up to the root, get ModTime.
+//     Chtimes always sets ModTime.
+//
+// The root itself and the symbolic links, whose times billy can't set, are
+// left as they are. Normalizing the times and modes requires the wrapped
+// filesystem to implement billy.Change; without it, only the modes given at
+// creation are normalized.
+type FS struct {
+	wrapper.Base
+	modTime                     time.Time
+	fileMode, execMode, dirMode os.FileMode
+}
+
+// New returns a filesystem wrapping fs, normalizing the entries written as
+// opts tells.
+func New(fs billy.Filesystem, opts Options) *FS {
+	r := &FS{
+		Base:     wrapper.NewBase(fs),
+		modTime:  opts.ModTime,
+		fileMode: opts.FileMode.Perm(),
+		execMode: opts.ExecMode.Perm(),
+		dirMode:  opts.DirMode.Perm(),
+	}
+	if r.modTime.IsZero() {
+		r.modTime = time.Unix(0, 0)
+	}
+	if r.fileMode == 0 {
+		r.fileMode = defaultFileMode
+	}
+	if r.execMode == 0 {
+		r.execMode = defaultExecMode
+	}
+	if r.dirMode == 0 {
+		r.dirMode = defaultDirMode
+	}
+
+	return r
+}
+
+// Layer returns the billy.Layer of New, for billy.Compose.
+func Layer(opts Options) billy.Layer {
+	return billy.Layer{
+		Name:     "repro",
+		Requires: billy.WriteCapability,
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs, opts), nil
+		},
+	}
+}
+
+// mode returns the normalized mode of an entry given perm.
+func (fs *FS) mode(perm os.FileMode, dir bool) os.FileMode {
+	switch {
+	case dir:
+		return fs.dirMode
+	case perm&0o111 != 0:
+		return fs.execMode
+	}
+
+	return fs.fileMode
+}
+
+// clean returns name as a slash separated path relative to the root.
+func clean(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// ignore drops billy.ErrNotSupported, returned by the filesystems which
+// can't change the attributes of their entries.
+func ignore(err error) error {
+	if err == billy.ErrNotSupported {
+		return nil
+	}
+
+	return err
+}
+
+// clamp gives name, unless it is a symbolic link, and its parent
+// directories, up to the root, the normalized times.
+func (fs *FS) clamp(name string) error {
+	for p := clean(name); p != "" && p != "."; p = path.Dir(p) {
+		fi, err := fs.Base.Lstat(filepath.FromSlash(p))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		if err := ignore(fs.Base.Chtimes(filepath.FromSlash(p), fs.modTime, fs.modTime)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clampParent is clamp for the parent directory of name.
+func (fs *FS) clampParent(name string) error {
+	return fs.clamp(path.Dir(clean(name)))
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file, creating it with the normalized mode given perm.
+// The files opened for writing get the normalized times when closed.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) == 0 {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	created := false
+	if flag&os.O_CREATE != 0 {
+		_, err := fs.Base.Lstat(filename)
+		created = os.IsNotExist(err)
+	}
+
+	mode := fs.mode(perm, false)
+	f, err := fs.Base.OpenFile(filename, flag, mode)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := ignore(fs.Base.Chmod(filename, mode)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return &file{File: f, fs: fs, name: filename}, nil
+}
+
+// TempFile creates a temporary file with the normalized mode of the regular
+// files, rather than 0600, since it is usually renamed into the tree.
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := fs.Base.TempFile(dir, prefix)
+	return fs.temp(f, err)
+}
+
+// CreateTemp implements billy.TempCreator, as TempFile does.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	f, err := fs.Base.CreateTemp(dir, pattern)
+	return fs.temp(f, err)
+}
+
+func (fs *FS) temp(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := ignore(fs.Base.Chmod(f.Name(), fs.fileMode)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs, name: f.Name()}, nil
+}
+
+// MkdirAll creates the directory and its missing parents with the
+// normalized mode of the directories.
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	// The missing directories, from the deepest.
+	var missing []string
+	for p := clean(filename); p != "" && p != "."; p = path.Dir(p) {
+		if _, err := fs.Base.Lstat(filepath.FromSlash(p)); err == nil {
+			break
+		}
+		missing = append(missing, p)
+	}
+
+	if err := fs.Base.MkdirAll(filename, fs.dirMode); err != nil {
+		return err
+	}
+	for _, p := range missing {
+		if err := ignore(fs.Base.Chmod(filepath.FromSlash(p), fs.dirMode)); err != nil {
+			return err
+		}
+	}
+
+	return fs.clamp(filename)
+}
+
+// MkdirTemp implements billy.TempCreator, creating the directory with the
+// normalized mode of the directories.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := fs.Base.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := ignore(fs.Base.Chmod(name, fs.dirMode)); err != nil {
+		return "", err
+	}
+
+	return name, fs.clamp(name)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	if err := fs.Base.Rename(from, to); err != nil {
+		return err
+	}
+	if err := fs.clampParent(from); err != nil {
+		return err
+	}
+
+	return fs.clamp(to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	if err := fs.Base.Remove(filename); err != nil {
+		return err
+	}
+
+	return fs.clampParent(filename)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	if err := fs.Base.Symlink(target, link); err != nil {
+		return err
+	}
+
+	return fs.clampParent(link)
+}
+
+// Link implements billy.Linker.
+func (fs *FS) Link(oldname, newname string) error {
+	if err := fs.Base.Link(oldname, newname); err != nil {
+		return err
+	}
+
+	return fs.clamp(newname)
+}
+
+// Exchange implements the Exchange of util.SwapDirs.
+func (fs *FS) Exchange(x, y string) error {
+	if err := fs.Base.Exchange(x, y); err != nil {
+		return err
+	}
+	if err := fs.clamp(x); err != nil {
+		return err
+	}
+
+	return fs.clamp(y)
+}
+
+// Chmod implements billy.Change, setting the normalized mode given mode.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	fi, err := fs.Base.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	return fs.Base.Chmod(name, fs.mode(mode, fi.IsDir()))
+}
+
+// Chtimes implements billy.Change, setting the normalized times whatever
+// the times given.
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.Base.Chtimes(name, fs.modTime, fs.modTime)
+}
+
+// Chroot returns a chrooted view of fs, normalizing the entries written the
+// same way.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a file opened for writing, which gets the normalized times once
+// closed.
+type file struct {
+	billy.File
+	fs   *FS
+	name string
+}
+
+func (f *file) Close() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+
+	return f.fs.clamp(f.name)
+}
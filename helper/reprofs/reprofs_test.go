@@ -0,0 +1,107 @@
+package reprofs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), Options{})
+	})
+}
+
+// render writes a small tree to a new filesystem through an FS, returning
+// the underlying filesystem.
+func render(t *testing.T) billy.Filesystem {
+	t.Helper()
+
+	fs := memfs.New()
+	r := New(fs, Options{})
+
+	for _, err := range []error{
+		r.MkdirAll("dir/sub", 0o700),
+		util.WriteFile(r, "dir/file", []byte("file"), 0o600),
+		util.WriteFile(r, "dir/sub/exec", []byte("exec"), 0o700),
+		util.WriteFileAtomic(r, "atomic", []byte("atomic"), 0o640),
+		r.Symlink("dir/file", "link"),
+		util.WriteFile(r, "removed", nil, 0o644),
+		r.Remove("removed"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fs
+}
+
+func TestReproducible(t *testing.T) {
+	first := render(t)
+	time.Sleep(10 * time.Millisecond)
+	second := render(t)
+
+	var a, b bytes.Buffer
+	if err := util.WriteTar(&a, first, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteTar(&b, second, "/"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("expected both renderings to give the same tarball")
+	}
+
+	for name, mode := range map[string]os.FileMode{
+		"dir":          0o755 | os.ModeDir,
+		"dir/sub":      0o755 | os.ModeDir,
+		"dir/file":     0o644,
+		"dir/sub/exec": 0o755,
+		"atomic":       0o644,
+	} {
+		fi, err := first.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != mode {
+			t.Errorf("%s: expected mode %s, got %s", name, mode, fi.Mode())
+		}
+		if !fi.ModTime().Equal(time.Unix(0, 0)) {
+			t.Errorf("%s: expected the epoch, got %s", name, fi.ModTime())
+		}
+	}
+}
+
+func TestChange(t *testing.T) {
+	fs := memfs.New()
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := New(fs, Options{ModTime: modTime, FileMode: 0o640, ExecMode: 0o750})
+
+	if err := util.WriteFile(r, "file", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Chmod("file", 0o4711); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Chtimes("file", time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := fs.Stat("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0o750 {
+		t.Errorf("expected mode 0750, got %s", fi.Mode())
+	}
+	if !fi.ModTime().Equal(modTime) {
+		t.Errorf("expected %s, got %s", modTime, fi.ModTime())
+	}
+}
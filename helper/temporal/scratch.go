@@ -0,0 +1,125 @@
+package temporal
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Scratch is a filesystem rooted at a unique directory created on another
+// filesystem, removed with all its content when Close is called or when the
+// context it was created with is done.
+type Scratch struct {
+	billy.Filesystem
+	parent billy.Filesystem
+	path   string
+
+	once sync.Once
+	err  error
+	done chan struct{}
+}
+
+// NewScratch creates a new directory in dir of fs, with a name following
+// pattern as util.MkdirTemp does, and returns a filesystem rooted at it. It is
+// removed by Close, or once ctx is done, whichever comes first.
+//
+// The scratch areas not closed yet are reported by Leaked.
+func NewScratch(ctx context.Context, fs billy.Filesystem, dir, pattern string) (*Scratch, error) {
+	path, err := util.MkdirTemp(fs, dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := fs.Chroot(path)
+	if err != nil {
+		_ = util.RemoveAll(fs, path)
+		return nil, err
+	}
+
+	s := &Scratch{
+		Filesystem: root,
+		parent:     fs,
+		path:       path,
+		done:       make(chan struct{}),
+	}
+	track(s)
+
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.Close()
+			case <-s.done:
+			}
+		}()
+	}
+
+	return s, nil
+}
+
+// Path returns the path of the scratch area on the filesystem it was created
+// on.
+func (s *Scratch) Path() string {
+	return s.path
+}
+
+// Close removes the scratch area. It is safe to call it more than once, the
+// later calls returning the result of the first one.
+func (s *Scratch) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		s.err = util.RemoveAll(s.parent, s.path)
+		untrack(s)
+	})
+
+	return s.err
+}
+
+var (
+	scratchesMu sync.Mutex
+	// scratches holds the scratch areas not closed yet, with the location
+	// they were created at.
+	scratches = map[*Scratch]string{}
+)
+
+func track(s *Scratch) {
+	where := "unknown"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		where = fmt.Sprintf("%s:%d", file, line)
+	}
+
+	scratchesMu.Lock()
+	defer scratchesMu.Unlock()
+	scratches[s] = where
+}
+
+func untrack(s *Scratch) {
+	scratchesMu.Lock()
+	defer scratchesMu.Unlock()
+	delete(scratches, s)
+}
+
+// Leaked returns the scratch areas not closed yet, each described by its
+// path and the location of the call to NewScratch, sorted. It is meant for
+// the tests, to check that none remain once they are done:
+//
+//	if leaked := temporal.Leaked(); len(leaked) != 0 {
+//		t.Errorf("scratch areas leaked: %v", leaked)
+//	}
+func Leaked() []string {
+	scratchesMu.Lock()
+	defer scratchesMu.Unlock()
+
+	leaked := make([]string, 0, len(scratches))
+	for s, where := range scratches {
+		leaked = append(leaked, fmt.Sprintf("%s (created at %s)", s.path, where))
+	}
+	sort.Strings(leaked)
+
+	return leaked
+}
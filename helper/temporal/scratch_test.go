@@ -0,0 +1,58 @@
+package temporal
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type ScratchSuite struct{}
+
+var _ = Suite(&ScratchSuite{})
+
+func (s *ScratchSuite) TearDownTest(c *C) {
+	c.Assert(Leaked(), HasLen, 0)
+}
+
+func (s *ScratchSuite) TestClose(c *C) {
+	fs := memfs.New()
+
+	sc, err := NewScratch(context.Background(), fs, "work", "job-*")
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(sc.Path(), fs.Join("work", "job-")), Equals, true)
+
+	c.Assert(util.WriteFile(sc, "foo/bar", []byte("bar"), 0644), IsNil)
+	_, err = fs.Stat(fs.Join(sc.Path(), "foo", "bar"))
+	c.Assert(err, IsNil)
+
+	leaked := Leaked()
+	c.Assert(leaked, HasLen, 1)
+	c.Assert(leaked[0], Matches, ".*scratch_test.go:[0-9]+\\)")
+
+	c.Assert(sc.Close(), IsNil)
+	c.Assert(sc.Close(), IsNil)
+
+	_, err = fs.Stat(sc.Path())
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *ScratchSuite) TestContextDone(c *C) {
+	fs := memfs.New()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sc, err := NewScratch(ctx, fs, "", "job")
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(sc, "foo", nil, 0644), IsNil)
+
+	cancel()
+	<-sc.done
+	c.Assert(sc.Close(), IsNil)
+
+	_, err = fs.Stat(sc.Path())
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
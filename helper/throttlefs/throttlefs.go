@@ -0,0 +1,330 @@
+// Package throttlefs provides a billy filesystem wrapper rate limiting the
+// mutations made through it, so that the tenants of a shared volume can't
+// starve each other, e.g. while extracting large artifacts.
+package throttlefs // import "github.com/go-git/go-billy/v5/helper/throttlefs"
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+)
+
+// Limit configures a token bucket. Its zero value doesn't limit anything.
+type Limit struct {
+	// Ops is the number of mutations allowed per second, zero meaning no
+	// limit. Each call modifying the filesystem counts as one, as does each
+	// Write and Truncate of a file.
+	Ops float64
+	// OpsBurst is the number of mutations allowed at once, after a pause.
+	// Ops rounded up is used if it is zero.
+	OpsBurst int
+	// Bytes is the number of bytes written per second, zero meaning no
+	// limit.
+	Bytes float64
+	// BytesBurst is the number of bytes allowed at once, after a pause.
+	// Bytes rounded up is used if it is zero. The writes larger than it are
+	// split.
+	BytesBurst int
+}
+
+// Options configures the limits of an FS.
+type Options struct {
+	// Global limits all the mutations.
+	Global Limit
+	// Prefixes limits the mutations below some directories, keyed by their
+	// slash separated path, relative to the root of the FS. Each prefix has
+	// its own bucket, shared by every path below it; only the longest prefix
+	// of a path applies, in addition to Global.
+	Prefixes map[string]Limit
+}
+
+// bucket holds the limiters of a Limit, nil when they don't limit anything.
+type bucket struct {
+	ops   *rate.Limiter
+	bytes *rate.Limiter
+}
+
+func newBucket(l Limit) *bucket {
+	return &bucket{
+		ops:   newLimiter(l.Ops, l.OpsBurst),
+		bytes: newLimiter(l.Bytes, l.BytesBurst),
+	}
+}
+
+func newLimiter(r float64, burst int) *rate.Limiter {
+	if r <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(r))
+	}
+
+	return rate.NewLimiter(rate.Limit(r), burst)
+}
+
+// FS is a filesystem wrapper blocking the mutations exceeding its limits
+// until they are allowed. Reads aren't limited, neither are the files opened
+// without write flags.
+type FS struct {
+	wrapper.Base
+	global   *bucket
+	prefixes map[string]*bucket
+}
+
+// New returns a filesystem wrapping fs, whose mutations are limited by opts.
+func New(fs billy.Filesystem, opts Options) *FS {
+	t := &FS{
+		Base:     wrapper.NewBase(fs),
+		global:   newBucket(opts.Global),
+		prefixes: make(map[string]*bucket, len(opts.Prefixes)),
+	}
+	for prefix, l := range opts.Prefixes {
+		t.prefixes[clean(prefix)] = newBucket(l)
+	}
+
+	return t
+}
+
+// Layer returns the billy.Layer of New, for billy.Compose.
+func Layer(opts Options) billy.Layer {
+	return billy.Layer{
+		Name:     "throttle",
+		Requires: billy.WriteCapability,
+		Wrap: func(fs billy.Filesystem) (billy.Filesystem, error) {
+			return New(fs, opts), nil
+		},
+	}
+}
+
+// buckets returns the buckets limiting the mutations of the given paths.
+func (fs *FS) buckets(names ...string) []*bucket {
+	buckets := []*bucket{fs.global}
+	for _, name := range names {
+		if b := fs.prefix(name); b != nil {
+			buckets = appendBucket(buckets, b)
+		}
+	}
+
+	return buckets
+}
+
+func appendBucket(buckets []*bucket, b *bucket) []*bucket {
+	for _, o := range buckets {
+		if o == b {
+			return buckets
+		}
+	}
+
+	return append(buckets, b)
+}
+
+// prefix returns the bucket of the longest prefix of name, if any.
+func (fs *FS) prefix(name string) *bucket {
+	if len(fs.prefixes) == 0 {
+		return nil
+	}
+
+	p := clean(name)
+	for {
+		if b, ok := fs.prefixes[p]; ok {
+			return b
+		}
+		if p == "" {
+			return nil
+		}
+
+		i := strings.LastIndexByte(p, '/')
+		if i < 0 {
+			i = 0
+		}
+		p = p[:i]
+	}
+}
+
+// clean returns name as a slash separated path relative to the root, empty
+// for the root itself.
+func clean(name string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean(string(filepath.Separator)+name)), "/")
+}
+
+// wait blocks until the buckets allow a mutation.
+func wait(buckets []*bucket) {
+	for _, b := range buckets {
+		if b.ops != nil {
+			b.ops.Wait(context.Background())
+		}
+	}
+}
+
+// waitBytes blocks until the buckets allow writing up to n bytes, returning
+// how many may be written.
+func waitBytes(buckets []*bucket, n int) int {
+	for _, b := range buckets {
+		if b.bytes != nil && b.bytes.Burst() < n {
+			n = b.bytes.Burst()
+		}
+	}
+	for _, b := range buckets {
+		if b.bytes != nil {
+			b.bytes.WaitN(context.Background(), n)
+		}
+	}
+
+	return n
+}
+
+func (fs *FS) file(f billy.File, err error, name string) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, buckets: fs.buckets(name)}, nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	wait(fs.buckets(filename))
+	f, err := fs.Base.Create(filename)
+	return fs.file(f, err, filename)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	wait(fs.buckets(filename))
+	f, err := fs.Base.OpenFile(filename, flag, perm)
+	return fs.file(f, err, filename)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	wait(fs.buckets(dir))
+	f, err := fs.Base.TempFile(dir, prefix)
+	return fs.file(f, err, dir)
+}
+
+// CreateTemp implements billy.TempCreator.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	wait(fs.buckets(dir))
+	f, err := fs.Base.CreateTemp(dir, pattern)
+	return fs.file(f, err, dir)
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	wait(fs.buckets(dir))
+	return fs.Base.MkdirTemp(dir, pattern)
+}
+
+// Rename counts against the limits of both paths.
+func (fs *FS) Rename(from, to string) error {
+	wait(fs.buckets(from, to))
+	return fs.Base.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	wait(fs.buckets(filename))
+	return fs.Base.Remove(filename)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	wait(fs.buckets(filename))
+	return fs.Base.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	wait(fs.buckets(link))
+	return fs.Base.Symlink(target, link)
+}
+
+// Link implements billy.Linker.
+func (fs *FS) Link(oldname, newname string) error {
+	wait(fs.buckets(newname))
+	return fs.Base.Link(oldname, newname)
+}
+
+// Chmod implements billy.Change.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	wait(fs.buckets(name))
+	return fs.Base.Chmod(name, mode)
+}
+
+// Lchown implements billy.Change.
+func (fs *FS) Lchown(name string, uid, gid int) error {
+	wait(fs.buckets(name))
+	return fs.Base.Lchown(name, uid, gid)
+}
+
+// Chown implements billy.Change.
+func (fs *FS) Chown(name string, uid, gid int) error {
+	wait(fs.buckets(name))
+	return fs.Base.Chown(name, uid, gid)
+}
+
+// Chtimes implements billy.Change.
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	wait(fs.buckets(name))
+	return fs.Base.Chtimes(name, atime, mtime)
+}
+
+// Setxattr implements billy.Xattrer.
+func (fs *FS) Setxattr(name, attr string, value []byte) error {
+	wait(fs.buckets(name))
+	return fs.Base.Setxattr(name, attr, value)
+}
+
+// Removexattr implements billy.Xattrer.
+func (fs *FS) Removexattr(name, attr string) error {
+	wait(fs.buckets(name))
+	return fs.Base.Removexattr(name, attr)
+}
+
+// Exchange counts against the limits of both paths.
+func (fs *FS) Exchange(x, y string) error {
+	wait(fs.buckets(x, y))
+	return fs.Base.Exchange(x, y)
+}
+
+// Chroot returns a chrooted view of fs, sharing its limits, whose prefixes
+// stay relative to the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a file opened for writing by an FS, limited by the buckets of its
+// path.
+type file struct {
+	billy.File
+	buckets []*bucket
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	wait(f.buckets)
+
+	var n int
+	for len(p) > 0 {
+		chunk := waitBytes(f.buckets, len(p))
+		m, err := f.File.Write(p[:chunk])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[chunk:]
+	}
+
+	return n, nil
+}
+
+func (f *file) Truncate(size int64) error {
+	wait(f.buckets)
+	return f.File.Truncate(size)
+}
@@ -0,0 +1,116 @@
+package throttlefs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), Options{Global: Limit{Ops: 1e6, Bytes: 1 << 30}})
+	})
+}
+
+func TestBytes(t *testing.T) {
+	fs := New(memfs.New(), Options{Global: Limit{Bytes: 10000, BytesBurst: 1000}})
+
+	start := time.Now()
+	if err := util.WriteFile(fs, "file", make([]byte, 3000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("wrote 3000 bytes in %s, want about 200ms", d)
+	}
+
+	fi, err := fs.Stat("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 3000 {
+		t.Errorf("got size %d, want 3000", fi.Size())
+	}
+}
+
+func TestOps(t *testing.T) {
+	fs := New(memfs.New(), Options{Global: Limit{Ops: 20, OpsBurst: 1}})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := fs.MkdirAll(fmt.Sprintf("dir%d", i), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("made 5 directories in %s, want about 200ms", d)
+	}
+
+	start = time.Now()
+	for i := 0; i < 50; i++ {
+		if _, err := fs.Stat("dir0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("reads throttled, took %s", d)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	fs := New(memfs.New(), Options{Prefixes: map[string]Limit{
+		"tenants/slow": {Ops: 20, OpsBurst: 1},
+	}})
+
+	chroot, err := fs.Chroot("tenants")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := chroot.MkdirAll(fmt.Sprintf("slow/dir%d", i), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("made 5 directories in %s, want about 200ms", d)
+	}
+
+	start = time.Now()
+	for i := 0; i < 50; i++ {
+		if err := chroot.MkdirAll(fmt.Sprintf("fast/dir%d", i), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("other tenant throttled, took %s", d)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	fs := New(memfs.New(), Options{Prefixes: map[string]Limit{
+		"":    {Ops: 1},
+		"a":   {Ops: 2},
+		"a/b": {Ops: 3},
+	}})
+
+	cases := map[string]string{
+		"":        "",
+		"x":       "",
+		"a":       "a",
+		"/a/c":    "a",
+		"a/b":     "a/b",
+		"a/b/c/d": "a/b",
+		"ab":      "",
+	}
+	for name, prefix := range cases {
+		if got, want := fs.prefix(name), fs.prefixes[prefix]; got != want {
+			t.Errorf("%q: got bucket %v, want the one of %q", name, got, prefix)
+		}
+	}
+}
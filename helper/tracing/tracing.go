@@ -0,0 +1,244 @@
+// Package tracing provides a billy filesystem wrapper starting an
+// OpenTelemetry span for every operation.
+//
+// It is a module of its own, so that the users of billy don't depend on
+// OpenTelemetry.
+package tracing // import "github.com/go-git/go-billy/v5/helper/tracing"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the tracer used by FS.
+const InstrumentationName = "github.com/go-git/go-billy/v5/helper/tracing"
+
+// Span attributes.
+const (
+	PathKey    = attribute.Key("billy.path")
+	NewPathKey = attribute.Key("billy.new_path")
+	BytesKey   = attribute.Key("billy.bytes")
+)
+
+// FS is a billy.Filesystem which forwards every call to the wrapped
+// filesystem within a span. The spans are children of the span found in the
+// context given to WithContext, if any.
+type FS struct {
+	underlying billy.Filesystem
+	tracer     trace.Tracer
+	ctx        context.Context
+}
+
+// New creates a new filesystem wrapping up the given 'fs', creating its
+// spans with a tracer from tp, or from the global TracerProvider if tp is
+// nil.
+func New(fs billy.Filesystem, tp trace.TracerProvider) *FS {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	return &FS{
+		underlying: fs,
+		tracer:     tp.Tracer(InstrumentationName),
+		ctx:        context.Background(),
+	}
+}
+
+// WithContext returns a view of fs whose spans are children of the span
+// carried by ctx. The operations on the files opened through the view are
+// traced within ctx too.
+func (fs *FS) WithContext(ctx context.Context) *FS {
+	c := *fs
+	c.ctx = ctx
+	return &c
+}
+
+func start(ctx context.Context, tracer trace.Tracer, op string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := tracer.Start(ctx, "billy."+op, trace.WithAttributes(attrs...))
+	return span
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, io.EOF) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (fs *FS) start(op string, attrs ...attribute.KeyValue) trace.Span {
+	return start(fs.ctx, fs.tracer, op, attrs...)
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	span := fs.start("Create", PathKey.String(filename))
+	f, err := fs.underlying.Create(filename)
+	end(span, err)
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	span := fs.start("Open", PathKey.String(filename))
+	f, err := fs.underlying.Open(filename)
+	end(span, err)
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	span := fs.start("OpenFile", PathKey.String(filename),
+		attribute.Int("billy.flag", flag), attribute.String("billy.perm", perm.String()))
+	f, err := fs.underlying.OpenFile(filename, flag, perm)
+	end(span, err)
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	span := fs.start("Stat", PathKey.String(filename))
+	fi, err := fs.underlying.Stat(filename)
+	end(span, err)
+	return fi, err
+}
+
+func (fs *FS) Rename(from, to string) error {
+	span := fs.start("Rename", PathKey.String(from), NewPathKey.String(to))
+	err := fs.underlying.Rename(from, to)
+	end(span, err)
+	return err
+}
+
+func (fs *FS) Remove(filename string) error {
+	span := fs.start("Remove", PathKey.String(filename))
+	err := fs.underlying.Remove(filename)
+	end(span, err)
+	return err
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	span := fs.start("TempFile", PathKey.String(dir))
+	f, err := fs.underlying.TempFile(dir, prefix)
+	if err == nil {
+		span.SetAttributes(NewPathKey.String(f.Name()))
+	}
+	end(span, err)
+	return fs.wrap(f, err)
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	span := fs.start("ReadDir", PathKey.String(path))
+	infos, err := fs.underlying.ReadDir(path)
+	end(span, err)
+	return infos, err
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	span := fs.start("MkdirAll", PathKey.String(filename))
+	err := fs.underlying.MkdirAll(filename, perm)
+	end(span, err)
+	return err
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	span := fs.start("Lstat", PathKey.String(filename))
+	fi, err := fs.underlying.Lstat(filename)
+	end(span, err)
+	return fi, err
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	span := fs.start("Symlink", PathKey.String(link), attribute.String("billy.target", target))
+	err := fs.underlying.Symlink(target, link)
+	end(span, err)
+	return err
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	span := fs.start("Readlink", PathKey.String(link))
+	target, err := fs.underlying.Readlink(link)
+	end(span, err)
+	return target, err
+}
+
+// Chroot returns a chrooted view of fs, tracing within the same context.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, minus
+// billy.InterfaceCapabilities: the optional interfaces aren't traced.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) wrap(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+type file struct {
+	billy.File
+	fs *FS
+}
+
+func (f *file) start(op string, attrs ...attribute.KeyValue) trace.Span {
+	attrs = append(attrs, PathKey.String(f.Name()))
+	return start(f.fs.ctx, f.fs.tracer, "File."+op, attrs...)
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	span := f.start("Read")
+	n, err := f.File.Read(p)
+	span.SetAttributes(BytesKey.Int(n))
+	end(span, err)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	span := f.start("ReadAt", attribute.Int64("billy.offset", off))
+	n, err := f.File.ReadAt(p, off)
+	span.SetAttributes(BytesKey.Int(n))
+	end(span, err)
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	span := f.start("Write")
+	n, err := f.File.Write(p)
+	span.SetAttributes(BytesKey.Int(n))
+	end(span, err)
+	return n, err
+}
+
+func (f *file) Close() error {
+	span := f.start("Close")
+	err := f.File.Close()
+	end(span, err)
+	return err
+}
+
+func (f *file) Truncate(size int64) error {
+	span := f.start("Truncate", attribute.Int64("billy.size", size))
+	err := f.File.Truncate(size)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,61 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), nil)
+	})
+}
+
+func TestSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "reconcile")
+	fs := New(memfs.New(), tp).WithContext(ctx)
+
+	if err := util.WriteFile(fs, "foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("bar"); err == nil {
+		t.Fatal("expected error")
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		byName[s.Name] = s
+		if s.Name != "reconcile" && s.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s: expected to be a child of the reconcile span", s.Name)
+		}
+	}
+
+	write, ok := byName["billy.File.Write"]
+	if !ok {
+		t.Fatalf("missing write span in %v", spans)
+	}
+	attrs := map[string]interface{}{}
+	for _, kv := range write.Attributes {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs[string(BytesKey)] != int64(3) || attrs[string(PathKey)] != "foo" {
+		t.Errorf("unexpected write attributes %v", attrs)
+	}
+
+	if stat := byName["billy.Stat"]; stat.Status.Code != codes.Error {
+		t.Errorf("expected the failed stat span to have an error status, got %v", stat.Status)
+	}
+}
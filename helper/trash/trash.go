@@ -0,0 +1,369 @@
+// Package trash provides a billy filesystem wrapper moving the removed
+// files to a trash area, from which they can be restored or purged, rather
+// than deleting them.
+package trash // import "github.com/go-git/go-billy/v5/helper/trash"
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// DefaultDir is the directory of the trash when it lives in the wrapped
+// filesystem.
+const DefaultDir = ".trash"
+
+const (
+	filesDir   = "files"
+	infoDir    = "info"
+	infoSuffix = ".trashinfo"
+	timeFormat = "2006-01-02T15:04:05"
+)
+
+// Options configures a FS.
+type Options struct {
+	// Trash is the filesystem holding the removed entries. If nil, they are
+	// kept in the directory Dir of the wrapped filesystem, and moved there
+	// with a rename; otherwise they are copied to Trash before being
+	// removed.
+	Trash billy.Filesystem
+	// Dir is the directory of the wrapped filesystem holding the trash when
+	// Trash is nil, DefaultDir if empty. The entries below Dir are removed
+	// for good.
+	Dir string
+}
+
+// Entry describes a removed file or directory.
+type Entry struct {
+	// ID identifies the entry in the trash: the base name of the removed
+	// path, with a numeric suffix if needed to make it unique.
+	ID string
+	// Path is where the entry was removed from.
+	Path string
+	// Time is when it was removed, according to util.Now.
+	Time time.Time
+}
+
+// FS is a billy.Filesystem whose Remove and RemoveAll move the entries to
+// the trash. util.RemoveAll uses the RemoveAll method of FS, moving whole
+// trees at once.
+//
+// The trash follows the layout of the freedesktop.org trash specification:
+// the entries are stored in the directory "files" and described by a file
+// "info/<ID>.trashinfo" holding their original path and removal time.
+type FS struct {
+	wrapper.Base
+	trash billy.Filesystem
+	dir   string
+
+	m sync.Mutex
+}
+
+// New creates a new filesystem wrapping up the given 'fs', with its trash
+// configured by opts.
+func New(fs billy.Filesystem, opts Options) *FS {
+	t := &FS{Base: wrapper.NewBase(fs), trash: opts.Trash}
+	if t.trash == nil {
+		t.dir = clean(opts.Dir)
+		if t.dir == "" {
+			t.dir = DefaultDir
+		}
+		t.trash = chroot.New(fs, t.dir)
+	}
+
+	return t
+}
+
+func clean(name string) string {
+	sep := string(filepath.Separator)
+	return strings.TrimPrefix(filepath.Clean(sep+filepath.FromSlash(name)), sep)
+}
+
+// inTrash reports whether name is the trash directory or is below it.
+func (fs *FS) inTrash(name string) bool {
+	if fs.dir == "" {
+		return false
+	}
+
+	name = clean(name)
+	return name == fs.dir || strings.HasPrefix(name, fs.dir+string(filepath.Separator))
+}
+
+// Remove moves the file or empty directory name to the trash.
+func (fs *FS) Remove(filename string) error {
+	if fs.inTrash(filename) {
+		return fs.Base.Remove(filename)
+	}
+
+	fi, err := fs.Base.Lstat(filename)
+	if pe, ok := err.(*os.PathError); ok {
+		return &os.PathError{Op: "remove", Path: filename, Err: pe.Err}
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		infos, err := fs.Base.ReadDir(filename)
+		if err != nil {
+			return err
+		}
+		if len(infos) != 0 {
+			return &os.PathError{Op: "remove", Path: filename, Err: syscall.ENOTEMPTY}
+		}
+	}
+
+	return fs.discard(filename)
+}
+
+// RemoveAll moves path and its children to the trash. Like os.RemoveAll,
+// it returns nil if path doesn't exist.
+func (fs *FS) RemoveAll(path string) error {
+	if fs.inTrash(path) {
+		return util.RemoveAll(fs.Unwrap(), path)
+	}
+
+	if _, err := fs.Base.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	return fs.discard(path)
+}
+
+func (fs *FS) discard(name string) (err error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	id, err := fs.reserve(clean(name), util.Now())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = fs.trash.Remove(infoPath(id))
+		}
+	}()
+
+	dst := fs.trash.Join(filesDir, id)
+	if err := fs.trash.MkdirAll(filesDir, 0o700); err != nil {
+		return err
+	}
+
+	if fs.dir != "" {
+		return fs.Base.Rename(name, fs.Base.Join(fs.dir, dst))
+	}
+
+	if _, err := util.Sync(fs.trash, dst, fs.Unwrap(), name, nil); err != nil {
+		_ = util.RemoveAll(fs.trash, dst)
+		return err
+	}
+
+	return util.RemoveAll(fs.Unwrap(), name)
+}
+
+func infoPath(id string) string {
+	return filepath.Join(infoDir, id+infoSuffix)
+}
+
+// reserve picks the ID of name and writes its information file, created
+// exclusively to ensure the ID is unique.
+func (fs *FS) reserve(name string, now time.Time) (string, error) {
+	if err := fs.trash.MkdirAll(infoDir, 0o700); err != nil {
+		return "", err
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: filepath.ToSlash(name)}).EscapedPath(), now.Format(timeFormat))
+
+	base := filepath.Base(name)
+	for i := 1; ; i++ {
+		id := base
+		if i > 1 {
+			id += "." + strconv.Itoa(i)
+		}
+
+		f, err := fs.trash.OpenFile(infoPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, err = f.Write([]byte(info))
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+		if err != nil {
+			_ = fs.trash.Remove(infoPath(id))
+			return "", err
+		}
+
+		return id, nil
+	}
+}
+
+// List returns the entries of the trash, from the oldest to the most
+// recently removed one.
+func (fs *FS) List() ([]Entry, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	infos, err := fs.trash.ReadDir(infoDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, fi := range infos {
+		if !strings.HasSuffix(fi.Name(), infoSuffix) {
+			continue
+		}
+
+		e, err := fs.entry(strings.TrimSuffix(fi.Name(), infoSuffix))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+func (fs *FS) entry(id string) (Entry, error) {
+	f, err := fs.trash.Open(infoPath(id))
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+
+	e := Entry{ID: id}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		key, value, ok := strings.Cut(s.Text(), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "Path":
+			p, err := url.PathUnescape(value)
+			if err != nil {
+				return e, fmt.Errorf("trash: invalid path in %s: %w", infoPath(id), err)
+			}
+			e.Path = filepath.FromSlash(p)
+		case "DeletionDate":
+			t, err := time.ParseInLocation(timeFormat, value, time.Local)
+			if err != nil {
+				return e, fmt.Errorf("trash: invalid date in %s: %w", infoPath(id), err)
+			}
+			e.Time = t
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return e, err
+	}
+	if e.Path == "" {
+		return e, fmt.Errorf("trash: missing path in %s", infoPath(id))
+	}
+
+	return e, nil
+}
+
+// Restore moves the entry id back to its original path, creating its parent
+// directories if needed. It fails if the path exists.
+func (fs *FS) Restore(id string) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	e, err := fs.entry(id)
+	if err != nil {
+		return err
+	}
+
+	src := fs.trash.Join(filesDir, id)
+	if _, err := fs.Base.Lstat(e.Path); err == nil {
+		return &os.LinkError{Op: "restore", Old: src, New: e.Path, Err: os.ErrExist}
+	}
+	if err := fs.Base.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
+		return err
+	}
+
+	if fs.dir != "" {
+		err = fs.Base.Rename(fs.Base.Join(fs.dir, src), e.Path)
+	} else if _, err = util.Sync(fs.Unwrap(), e.Path, fs.trash, src, nil); err == nil {
+		err = util.RemoveAll(fs.trash, src)
+	}
+	if err != nil {
+		return err
+	}
+
+	return fs.trash.Remove(infoPath(id))
+}
+
+// Purge deletes the entry id for good.
+func (fs *FS) Purge(id string) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	return fs.purge(id)
+}
+
+func (fs *FS) purge(id string) error {
+	if err := util.RemoveAll(fs.trash, fs.trash.Join(filesDir, id)); err != nil {
+		return err
+	}
+
+	return fs.trash.Remove(infoPath(id))
+}
+
+// PurgeBefore deletes for good the entries removed before t, returning how
+// many were.
+func (fs *FS) PurgeBefore(t time.Time) (int, error) {
+	entries, err := fs.List()
+	if err != nil {
+		return 0, err
+	}
+
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	var n int
+	for _, e := range entries {
+		if !e.Time.Before(t) {
+			continue
+		}
+		if err := fs.purge(e.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// Chroot returns a chrooted view of fs, sharing its trash. The entries
+// removed through it are recorded with their path from the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
@@ -0,0 +1,159 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), Options{Trash: memfs.New()})
+	})
+}
+
+func TestRestore(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"same":      {},
+		"side":      {Trash: memfs.New()},
+		"customDir": {Dir: "var/trash"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mem := memfs.New()
+			fs := New(mem, opts)
+
+			for _, name := range []string{"dir/a", "dir/sub/b", "other/a"} {
+				if err := util.WriteFile(fs, name, []byte(name), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := fs.Remove("dir"); err == nil {
+				t.Error("expected non-empty directories to be kept")
+			}
+			if err := util.RemoveAll(fs, "dir"); err != nil {
+				t.Fatal(err)
+			}
+			sub, err := fs.Chroot("other")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sub.Remove("a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := util.RemoveAll(fs, "missing"); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range []string{"dir", "other/a"} {
+				if _, err := mem.Lstat(name); !os.IsNotExist(err) {
+					t.Errorf("%s: expected to be removed, got %v", name, err)
+				}
+			}
+
+			entries, err := fs.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 {
+				t.Fatalf("expected 2 entries, got %v", entries)
+			}
+
+			ids := make(map[string]string)
+			for _, e := range entries {
+				ids[filepath.ToSlash(e.Path)] = e.ID
+			}
+			if ids["dir"] != "dir" || ids["other/a"] != "a" {
+				t.Errorf("unexpected entries %v", entries)
+			}
+
+			// A new file with the same base name gets another ID.
+			if err := util.WriteFile(fs, "a", nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.Remove("a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.Purge("a.2"); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fs.Restore("dir"); err != nil {
+				t.Fatal(err)
+			}
+			b, err := util.ReadFile(mem, "dir/sub/b")
+			if err != nil || string(b) != "dir/sub/b" {
+				t.Errorf("expected dir/sub/b to be restored, got %q, %v", b, err)
+			}
+
+			if err := util.WriteFile(fs, "other/a", nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := fs.Restore("a"); !os.IsExist(err) {
+				t.Errorf("expected restoring over a file to fail, got %v", err)
+			}
+
+			n, err := fs.PurgeBefore(time.Now().Add(time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Errorf("expected 1 entry purged, got %d", n)
+			}
+			if entries, _ := fs.List(); len(entries) != 0 {
+				t.Errorf("expected an empty trash, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestDeletionDate(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	util.SetSource(util.NewFixedSource(1, now))
+	defer util.SetSource(nil)
+
+	fs := New(memfs.New(), Options{})
+	if err := util.WriteFile(fs, "a", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fs.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !entries[0].Time.Equal(now) {
+		t.Errorf("expected a single entry removed at %v, got %v", now, entries)
+	}
+}
+
+func TestRemoveInTrash(t *testing.T) {
+	mem := memfs.New()
+	fs := New(mem, Options{})
+
+	if err := util.WriteFile(fs, "file", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("file"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the trash itself deletes it.
+	if err := util.RemoveAll(fs, DefaultDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Lstat(DefaultDir); !os.IsNotExist(err) {
+		t.Errorf("expected the trash to be deleted, got %v", err)
+	}
+	if entries, _ := fs.List(); len(entries) != 0 {
+		t.Errorf("expected an empty trash, got %v", entries)
+	}
+}
@@ -0,0 +1,334 @@
+package txfs
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// CommitError is returned by Commit when applying the changes failed and
+// the ones already applied could not all be undone, leaving the wrapped
+// filesystem partially updated.
+type CommitError struct {
+	Err         error
+	RollbackErr error
+}
+
+func (e *CommitError) Error() string {
+	return "commit failed: " + e.Err.Error() + ", rollback failed: " + e.RollbackErr.Error()
+}
+
+func (e *CommitError) Unwrap() error {
+	return e.Err
+}
+
+// Commit applies the staged changes to the wrapped filesystem, in three
+// steps:
+//
+//   - Every new or modified entry is written beside its final path, under a
+//     temporary name, derived from the time given by util.Now. New
+//     directories are written as a whole.
+//   - The temporary entries are renamed to their final path, and the
+//     removed ones renamed aside. A file replacing a file is renamed over
+//     it, so that readers never see it missing; its previous version is
+//     kept as a hard link, or a copy, until the end of the commit.
+//   - The previous versions are removed.
+//
+// If the first step fails, the wrapped filesystem is left unchanged. If the
+// second one fails, the renames already done are undone, a *CommitError
+// being returned if that fails too. Either way, the transaction is over
+// once Commit returns.
+func (fs *FS) Commit() error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return ErrDone
+	}
+
+	c := &committer{fs: fs, suffix: strconv.FormatInt(util.Now().UnixNano(), 36)}
+	err := c.prepare(".")
+	if err == nil {
+		err = c.apply()
+	}
+	if err != nil {
+		if rerr := c.undo(); rerr != nil {
+			err = &CommitError{Err: err, RollbackErr: rerr}
+		}
+	}
+
+	fs.done = true
+	fs.staged, fs.removed = nil, nil
+	if err != nil {
+		return err
+	}
+
+	return c.cleanup()
+}
+
+// change replaces or removes the entry at path.
+type change struct {
+	path string
+	// temp holds the new entry, if any.
+	temp string
+	// backup is where the previous entry is kept, if any. It is renamed
+	// there if moved, and otherwise linked or copied.
+	backup string
+	moved  bool
+
+	movedOut, movedIn bool
+}
+
+type committer struct {
+	fs      *FS
+	suffix  string
+	changes []*change
+	// dryRun plans the changes without writing anything.
+	dryRun bool
+}
+
+func (c *committer) name(dir, base, kind string) string {
+	return filepath.Join(dir, "."+base+".txfs-"+kind+"-"+c.suffix)
+}
+
+// prepare plans the changes below dir, a directory of both layers, writing
+// the new entries under temporary names.
+func (c *committer) prepare(dir string) error {
+	u, s := c.fs.underlying, c.fs.staged
+
+	names := make(map[string]bool)
+	if infos, err := s.ReadDir(dir); err == nil {
+		for _, fi := range infos {
+			names[fi.Name()] = true
+		}
+	}
+	// The removed entries below dir are reached through their first element
+	// under it, which may be a directory the staged layer doesn't hold.
+	for name := range c.fs.removed {
+		if base, ok := childOf(dir, name); ok {
+			names[base] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, base := range sorted {
+		path := filepath.Join(dir, base)
+		sfi, serr := s.Lstat(path)
+		ufi, uerr := u.Lstat(path)
+		if uerr != nil && !os.IsNotExist(uerr) {
+			return uerr
+		}
+
+		exists := uerr == nil
+		switch {
+		case serr == nil && sfi.IsDir() && exists && ufi.IsDir() && !c.fs.removed[path]:
+			if err := c.prepare(path); err != nil {
+				return err
+			}
+		case serr == nil:
+			ch := &change{path: path, temp: c.name(dir, base, "new")}
+			c.changes = append(c.changes, ch)
+			if !c.dryRun {
+				if err := copyTree(u, ch.temp, s, path); err != nil {
+					return err
+				}
+			}
+			if !exists {
+				continue
+			}
+
+			ch.backup = c.name(dir, base, "old")
+			ch.moved = sfi.IsDir() || ufi.IsDir()
+			if !ch.moved && !c.dryRun {
+				if err := c.backup(path, ch.backup); err != nil {
+					return err
+				}
+			}
+		case os.IsNotExist(serr) && exists && ufi.IsDir() && !c.fs.removed[path]:
+			if err := c.prepare(path); err != nil {
+				return err
+			}
+		case exists && c.fs.removed[path]:
+			c.changes = append(c.changes, &change{
+				path:   path,
+				backup: c.name(dir, base, "old"),
+				moved:  true,
+			})
+		}
+	}
+
+	return nil
+}
+
+// childOf returns the first element of name below dir, if name is below
+// it.
+func childOf(dir, name string) (string, bool) {
+	rel, err := filepath.Rel(dir, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	if i := strings.IndexRune(rel, filepath.Separator); i >= 0 {
+		rel = rel[:i]
+	}
+
+	return rel, true
+}
+
+// DryRun returns the changes Commit would make to the wrapped filesystem
+// if it was called now, without making them, nor ending the transaction.
+// The entries replaced by a directory, or replacing one, are removed first,
+// and the new directories are listed with their content. The temporary
+// entries Commit goes through are left out.
+func (fs *FS) DryRun() ([]util.PlannedOp, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return nil, ErrDone
+	}
+
+	c := &committer{fs: fs, dryRun: true}
+	if err := c.prepare("."); err != nil {
+		return nil, err
+	}
+
+	var ops []util.PlannedOp
+	for _, ch := range c.changes {
+		if ch.moved {
+			ops = append(ops, util.PlannedOp{Action: util.ActionRemove, Path: ch.path})
+		}
+		if ch.temp == "" {
+			continue
+		}
+		if err := planTree(fs.staged, ch.path, &ops); err != nil {
+			return nil, err
+		}
+	}
+
+	return ops, nil
+}
+
+// planTree appends the operations writing the staged tree at path.
+func planTree(fs billy.Filesystem, path string, ops *[]util.PlannedOp) error {
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := fs.Readlink(path)
+		if err != nil {
+			return err
+		}
+		*ops = append(*ops, util.PlannedOp{Action: util.ActionSymlink, Path: path, Target: target})
+	case fi.IsDir():
+		*ops = append(*ops, util.PlannedOp{Action: util.ActionMkdir, Path: path})
+
+		infos, err := fs.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+		for _, fi := range infos {
+			if err := planTree(fs, filepath.Join(path, fi.Name()), ops); err != nil {
+				return err
+			}
+		}
+	default:
+		*ops = append(*ops, util.PlannedOp{Action: util.ActionWrite, Path: path})
+	}
+
+	return nil
+}
+
+func (c *committer) backup(path, backup string) error {
+	if l, ok := c.fs.underlying.(billy.Linker); ok {
+		if err := l.Link(path, backup); err == nil {
+			return nil
+		}
+	}
+
+	return copyTree(c.fs.underlying, backup, c.fs.underlying, path)
+}
+
+func (c *committer) apply() error {
+	u := c.fs.underlying
+	for _, ch := range c.changes {
+		if ch.moved {
+			if err := u.Rename(ch.path, ch.backup); err != nil {
+				return err
+			}
+			ch.movedOut = true
+		}
+
+		if ch.temp != "" {
+			if err := u.Rename(ch.temp, ch.path); err != nil {
+				return err
+			}
+			ch.movedIn = true
+		}
+	}
+
+	return nil
+}
+
+// undo restores the previous entries and removes the temporary ones,
+// returning the first error.
+func (c *committer) undo() error {
+	u := c.fs.underlying
+
+	var first error
+	keep := func(err error) {
+		if first == nil && err != nil {
+			first = err
+		}
+	}
+
+	for i := len(c.changes) - 1; i >= 0; i-- {
+		ch := c.changes[i]
+		switch {
+		case ch.movedIn && ch.backup != "" && !ch.moved:
+			keep(u.Rename(ch.backup, ch.path))
+			continue
+		case ch.movedIn:
+			keep(util.RemoveAll(u, ch.path))
+		case ch.temp != "":
+			keep(util.RemoveAll(u, ch.temp))
+		}
+
+		switch {
+		case ch.movedOut:
+			keep(u.Rename(ch.backup, ch.path))
+		case ch.backup != "" && !ch.moved:
+			keep(util.RemoveAll(u, ch.backup))
+		}
+	}
+
+	return first
+}
+
+func (c *committer) cleanup() error {
+	var first error
+	for _, ch := range c.changes {
+		if ch.backup == "" {
+			continue
+		}
+		if err := util.RemoveAll(c.fs.underlying, ch.backup); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,618 @@
+// Package txfs provides a billy filesystem wrapper staging its changes in
+// memory, to apply them all to the wrapped filesystem on Commit or to drop
+// them on Rollback.
+package txfs // import "github.com/go-git/go-billy/v5/helper/txfs"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// ErrDone is returned by the operations of a FS already committed or rolled
+// back.
+var ErrDone = errors.New("transaction already committed or rolled back")
+
+const maxLinks = 255
+
+// FS is a billy.Filesystem layering an in-memory overlay on top of the
+// wrapped filesystem. Reads see the wrapped filesystem with the staged
+// changes applied, while the wrapped filesystem is left untouched until
+// Commit.
+//
+// Files are copied to the overlay when opened for writing, and trees when
+// renamed, so the overlay may hold large amounts of data. Files opened from
+// the overlay must be closed before Commit; the writes made to them
+// afterwards are lost.
+type FS struct {
+	underlying billy.Filesystem
+
+	m       sync.Mutex
+	staged  billy.Filesystem
+	removed map[string]bool
+	done    bool
+}
+
+// New creates a new transaction on top of the given 'fs'.
+func New(fs billy.Filesystem) *FS {
+	return &FS{
+		underlying: fs,
+		staged:     memfs.New(),
+		removed:    make(map[string]bool),
+	}
+}
+
+// clean returns name relative to the root of the filesystem.
+func clean(name string) string {
+	sep := string(filepath.Separator)
+	name = filepath.Clean(sep + filepath.FromSlash(name))
+	if name == sep {
+		return "."
+	}
+
+	return strings.TrimPrefix(name, sep)
+}
+
+// hidden reports whether name, or one of its parents, was removed from the
+// wrapped filesystem.
+func (fs *FS) hidden(name string) bool {
+	for p := name; p != "."; p = filepath.Dir(p) {
+		if fs.removed[p] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (fs *FS) visible(name string) bool {
+	if fs.hidden(name) {
+		return false
+	}
+
+	_, err := fs.underlying.Lstat(name)
+	return err == nil
+}
+
+// lstat returns the information of name, and whether its content lives in
+// the overlay. The directories present in both layers are merged, and
+// described by the wrapped filesystem.
+func (fs *FS) lstat(name string) (os.FileInfo, bool, error) {
+	sfi, serr := fs.staged.Lstat(name)
+	if serr == nil && !sfi.IsDir() {
+		return sfi, true, nil
+	}
+
+	if !fs.hidden(name) {
+		ufi, err := fs.underlying.Lstat(name)
+		if err == nil && (serr != nil || ufi.IsDir()) {
+			return ufi, false, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, false, err
+		}
+	}
+
+	if serr == nil {
+		return sfi, true, nil
+	}
+
+	return nil, false, &os.PathError{Op: "lstat", Path: name, Err: os.ErrNotExist}
+}
+
+func (fs *FS) readlink(name string, staged bool) (string, error) {
+	if staged {
+		return fs.staged.Readlink(name)
+	}
+
+	return fs.underlying.Readlink(name)
+}
+
+// resolve follows the symlinks of name, returning the name of the final
+// entry, even when it doesn't exist.
+func (fs *FS) resolve(name string) (string, os.FileInfo, bool, error) {
+	for i := 0; i < maxLinks; i++ {
+		fi, staged, err := fs.lstat(name)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return name, fi, staged, err
+		}
+
+		target, err := fs.readlink(name, staged)
+		if err != nil {
+			return name, nil, false, err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(name), target)
+		}
+		name = clean(target)
+	}
+
+	return name, nil, false, &os.PathError{Op: "stat", Path: name, Err: syscall.ELOOP}
+}
+
+func (fs *FS) readDir(name string) ([]os.FileInfo, error) {
+	entries := make(map[string]os.FileInfo)
+	if !fs.hidden(name) {
+		infos, err := fs.underlying.ReadDir(name)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, fi := range infos {
+			if !fs.removed[filepath.Join(name, fi.Name())] {
+				entries[fi.Name()] = fi
+			}
+		}
+	}
+
+	if infos, err := fs.staged.ReadDir(name); err == nil {
+		for _, fi := range infos {
+			if u, ok := entries[fi.Name()]; ok && u.IsDir() && fi.IsDir() {
+				continue
+			}
+			entries[fi.Name()] = fi
+		}
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, fi := range entries {
+		infos = append(infos, fi)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
+
+// stageParent creates the parent directory of name in the overlay. Like
+// the other billy filesystems, the missing parents are created.
+func (fs *FS) stageParent(name string) error {
+	dir := filepath.Dir(name)
+	if dir == "." {
+		return nil
+	}
+
+	fi, _, err := fs.lstat(dir)
+	if os.IsNotExist(err) {
+		return fs.mkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &os.PathError{Op: "open", Path: dir, Err: syscall.ENOTDIR}
+	}
+
+	return fs.staged.MkdirAll(dir, fi.Mode().Perm())
+}
+
+// copyUp copies the tree at name to the overlay, unless it is there already.
+func (fs *FS) copyUp(name string) error {
+	fi, staged, err := fs.lstat(name)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		if staged {
+			return nil
+		}
+		if err := fs.stageParent(name); err != nil {
+			return err
+		}
+		return copyTree(fs.staged, name, fs.underlying, name)
+	}
+
+	if err := fs.staged.MkdirAll(name, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	infos, err := fs.readDir(name)
+	if err != nil {
+		return err
+	}
+	for _, fi := range infos {
+		if err := fs.copyUp(filepath.Join(name, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove removes name from both layers, name being an existing file or an
+// empty directory.
+func (fs *FS) remove(name string) error {
+	if _, err := fs.staged.Lstat(name); err == nil {
+		if err := fs.staged.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	if fs.visible(name) {
+		fs.removed[name] = true
+	}
+
+	return nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file from the layer holding it. A file of the wrapped
+// filesystem opened for writing is copied to the overlay first, unless it
+// is truncated.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return nil, ErrDone
+	}
+
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0
+	name, fi, staged, err := fs.resolve(clean(filename))
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return nil, err
+	case err != nil:
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+		}
+		if err := fs.stageParent(name); err != nil {
+			return nil, err
+		}
+	case flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	case fi.IsDir() && write:
+		return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EISDIR}
+	case !write && !staged:
+		return fs.underlying.OpenFile(name, flag, perm)
+	case !write || staged:
+	case flag&os.O_TRUNC != 0:
+		if err := fs.stageParent(name); err != nil {
+			return nil, err
+		}
+		perm = fi.Mode().Perm()
+	default:
+		if err := fs.copyUp(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs.staged.OpenFile(name, flag, perm)
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return nil, ErrDone
+	}
+
+	name := clean(filename)
+	target, fi, _, err := fs.resolve(name)
+	if pe, ok := err.(*os.PathError); ok {
+		return nil, &os.PathError{Op: "stat", Path: filename, Err: pe.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if target != name {
+		fi = &fileInfo{FileInfo: fi, name: filepath.Base(name)}
+	}
+
+	return fi, nil
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return nil, ErrDone
+	}
+
+	fi, _, err := fs.lstat(clean(filename))
+	return fi, err
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return nil, ErrDone
+	}
+
+	name, fi, _, err := fs.resolve(clean(path))
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	return fs.readDir(name)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return "", ErrDone
+	}
+
+	name := clean(link)
+	_, staged, err := fs.lstat(name)
+	if err != nil {
+		return "", err
+	}
+
+	return fs.readlink(name, staged)
+}
+
+func (fs *FS) Remove(filename string) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return ErrDone
+	}
+
+	name := clean(filename)
+	fi, _, err := fs.lstat(name)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: filename, Err: os.ErrNotExist}
+	}
+	if fi.IsDir() {
+		infos, err := fs.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(infos) != 0 {
+			return &os.PathError{Op: "remove", Path: filename, Err: syscall.ENOTEMPTY}
+		}
+	}
+
+	return fs.remove(name)
+}
+
+// Rename moves the tree at from to the overlay, and hides it from the
+// wrapped filesystem.
+func (fs *FS) Rename(from, to string) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return ErrDone
+	}
+
+	src, dst := clean(from), clean(to)
+	sfi, _, err := fs.lstat(src)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrNotExist}
+	}
+	if src == dst {
+		return nil
+	}
+	if strings.HasPrefix(dst, src+string(filepath.Separator)) {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EINVAL}
+	}
+
+	if dfi, _, err := fs.lstat(dst); err == nil {
+		switch {
+		case sfi.IsDir() && !dfi.IsDir():
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.ENOTDIR}
+		case !sfi.IsDir() && dfi.IsDir():
+			return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EISDIR}
+		case dfi.IsDir():
+			infos, err := fs.readDir(dst)
+			if err != nil {
+				return err
+			}
+			if len(infos) != 0 {
+				return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.ENOTEMPTY}
+			}
+		}
+		if err := fs.remove(dst); err != nil {
+			return err
+		}
+	}
+
+	if err := fs.stageParent(dst); err != nil {
+		return err
+	}
+	if err := fs.copyUp(src); err != nil {
+		return err
+	}
+
+	visible := fs.visible(src)
+	if err := fs.staged.Rename(src, dst); err != nil {
+		return err
+	}
+	if visible {
+		fs.removed[src] = true
+	}
+
+	return nil
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return ErrDone
+	}
+
+	return fs.mkdirAll(clean(filename), perm)
+}
+
+func (fs *FS) mkdirAll(name string, perm os.FileMode) error {
+	var missing bool
+	for _, p := range parents(name) {
+		_, fi, _, err := fs.resolve(p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err != nil {
+			missing = true
+			break
+		}
+		if !fi.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+		}
+	}
+
+	if !missing {
+		return nil
+	}
+
+	return fs.staged.MkdirAll(name, perm)
+}
+
+// parents returns name and its parents, starting from the topmost one.
+func parents(name string) []string {
+	var names []string
+	for p := name; p != "."; p = filepath.Dir(p) {
+		names = append([]string{p}, names...)
+	}
+
+	return names
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return ErrDone
+	}
+
+	name := clean(link)
+	if _, _, err := fs.lstat(name); err == nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrExist}
+	}
+	if err := fs.stageParent(name); err != nil {
+		return err
+	}
+
+	return fs.staged.Symlink(target, name)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return util.TempFile(fs, dir, prefix)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+// Chroot returns a chrooted view of fs, whose changes are part of the
+// transaction.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface. The optional interfaces of
+// the wrapped filesystem aren't forwarded, as their changes couldn't be
+// staged.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) & billy.Capabilities(fs.staged) &^
+		billy.InterfaceCapabilities
+}
+
+// Rollback drops the staged changes, leaving the wrapped filesystem as it
+// was.
+func (fs *FS) Rollback() error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	if fs.done {
+		return ErrDone
+	}
+
+	fs.done = true
+	fs.staged, fs.removed = nil, nil
+	return nil
+}
+
+type fileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+// copyTree copies the tree at src in srcFS to dst in dstFS, which must not
+// exist.
+func copyTree(dstFS billy.Filesystem, dst string, srcFS billy.Filesystem, src string) error {
+	fi, err := srcFS.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := srcFS.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return dstFS.Symlink(target, dst)
+	case fi.IsDir():
+		if err := dstFS.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		infos, err := srcFS.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, fi := range infos {
+			if err := copyTree(dstFS, dstFS.Join(dst, fi.Name()), srcFS, srcFS.Join(src, fi.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r, err := srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dstFS.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	if err1 := w.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
@@ -0,0 +1,305 @@
+package txfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/hookfs"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New())
+	})
+}
+
+// fixture returns a filesystem holding a small tree.
+func fixture(t *testing.T) billy.Filesystem {
+	t.Helper()
+
+	fs := memfs.New()
+	for name, content := range map[string]string{
+		"keep":          "keep",
+		"modify":        "before",
+		"remove":        "remove",
+		"dir/a":         "a",
+		"dir/sub/b":     "b",
+		"replaced/file": "file",
+	} {
+		if err := util.WriteFile(fs, name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fs
+}
+
+func stage(t *testing.T, tx *FS) {
+	t.Helper()
+
+	f, err := tx.OpenFile("modify", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(" after")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, err := range []error{
+		tx.Remove("remove"),
+		tx.Rename("dir", "moved"),
+		util.WriteFile(tx, "moved/sub/c", []byte("c"), 0o644),
+		util.RemoveAll(tx, "replaced"),
+		util.WriteFile(tx, "replaced", []byte("replaced"), 0o644),
+		util.WriteFile(tx, "new/file", []byte("new"), 0o644),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// assertTree checks the content of the given files, nil meaning that the
+// file must not exist.
+func assertTree(t *testing.T, fs billy.Filesystem, files map[string]interface{}) {
+	t.Helper()
+
+	for name, want := range files {
+		b, err := util.ReadFile(fs, name)
+		if want == nil {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s: expected not to exist, got %v", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if string(b) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, b)
+		}
+	}
+}
+
+var (
+	before = map[string]interface{}{
+		"keep":          "keep",
+		"modify":        "before",
+		"remove":        "remove",
+		"dir/a":         "a",
+		"dir/sub/b":     "b",
+		"replaced/file": "file",
+		"moved/a":       nil,
+		"new/file":      nil,
+	}
+	after = map[string]interface{}{
+		"keep":          "keep",
+		"modify":        "before after",
+		"remove":        nil,
+		"dir/a":         nil,
+		"moved/a":       "a",
+		"moved/sub/b":   "b",
+		"moved/sub/c":   "c",
+		"replaced":      "replaced",
+		"replaced/file": nil,
+		"new/file":      "new",
+	}
+)
+
+func TestCommit(t *testing.T) {
+	fs := fixture(t)
+	tx := New(fs)
+	stage(t, tx)
+
+	assertTree(t, tx, after)
+	assertTree(t, fs, before)
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertTree(t, fs, after)
+
+	infos, err := fs.ReadDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+	want := "[keep modify moved new replaced]"
+	if got := fmt.Sprint(names); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, err := tx.Stat("keep"); !errors.Is(err, ErrDone) {
+		t.Errorf("expected ErrDone, got %v", err)
+	}
+}
+
+func TestCommitNestedRemove(t *testing.T) {
+	fs := fixture(t)
+	tx := New(fs)
+
+	// Nothing else is staged below dir.
+	if err := tx.Remove("dir/sub/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertTree(t, fs, map[string]interface{}{
+		"dir/a":     "a",
+		"dir/sub/b": nil,
+	})
+}
+
+func TestRollback(t *testing.T) {
+	fs := fixture(t)
+	tx := New(fs)
+	stage(t, tx)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	assertTree(t, fs, before)
+
+	if err := tx.Commit(); !errors.Is(err, ErrDone) {
+		t.Errorf("expected ErrDone, got %v", err)
+	}
+}
+
+func TestCommitFailure(t *testing.T) {
+	errVeto := errors.New("veto")
+
+	fs := fixture(t)
+	hooked := hookfs.New(fs)
+	hooked.Before(func(c *hookfs.Call) error {
+		if c.Target == "new" {
+			return errVeto
+		}
+		return nil
+	}, hookfs.OpRename)
+
+	tx := New(hooked)
+	stage(t, tx)
+
+	if err := tx.Commit(); !errors.Is(err, errVeto) {
+		t.Fatalf("expected the veto, got %v", err)
+	}
+	assertTree(t, fs, before)
+
+	infos, err := fs.ReadDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 5 {
+		t.Errorf("expected the temporary files to be removed, got %d entries", len(infos))
+	}
+}
+
+func TestCommitSource(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	util.SetSource(util.NewFixedSource(1, now))
+	defer util.SetSource(nil)
+
+	fs := fixture(t)
+	hooked := hookfs.New(fs)
+
+	var temps []string
+	hooked.After(func(c *hookfs.Call, err error) {
+		for _, name := range []string{c.Path, c.Target} {
+			if strings.Contains(name, ".txfs-") {
+				temps = append(temps, name)
+			}
+		}
+	}, hookfs.OpRename)
+
+	tx := New(hooked)
+	stage(t, tx)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(temps) == 0 {
+		t.Fatal("expected temporary names")
+	}
+	suffix := "-" + strconv.FormatInt(now.UnixNano(), 36)
+	for _, name := range temps {
+		if !strings.HasSuffix(name, suffix) {
+			t.Errorf("expected %q to end with %q", name, suffix)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	fs := fixture(t)
+	tx := New(fs)
+	stage(t, tx)
+
+	ops, err := tx.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, op := range ops {
+		got = append(got, filepath.ToSlash(op.String()))
+	}
+	want := "[remove dir write modify mkdir moved write moved/a mkdir moved/sub write moved/sub/b write moved/sub/c " +
+		"mkdir new write new/file remove remove remove replaced write replaced]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	assertTree(t, fs, before)
+	infos, err := fs.ReadDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 5 {
+		t.Errorf("expected no temporary file, got %d entries", len(infos))
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertTree(t, fs, after)
+
+	if _, err := tx.DryRun(); !errors.Is(err, ErrDone) {
+		t.Errorf("expected ErrDone, got %v", err)
+	}
+}
+
+func TestDryRunNestedRemove(t *testing.T) {
+	tx := New(fixture(t))
+	if err := tx.Remove("dir/sub/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	ops, err := tx.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, op := range ops {
+		got = append(got, filepath.ToSlash(op.String()))
+	}
+	if want := "[remove dir/sub/b]"; fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,279 @@
+// Package validate provides a billy filesystem wrapper refusing to create
+// files whose names would break on other platforms or filesystems: names too
+// long, not valid UTF-8, holding NUL bytes or, optionally, characters or
+// device names reserved by Windows.
+package validate // import "github.com/go-git/go-billy/v5/helper/validate"
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+)
+
+// Options configures the checks of a FS. The zero value only checks that the
+// names are valid UTF-8 without NUL bytes.
+type Options struct {
+	// MaxPathLength is the maximum length in bytes of a path, relative to
+	// the root of the FS. Zero means no limit.
+	MaxPathLength int
+	// MaxNameLength is the maximum length in bytes of a path component.
+	// Zero means no limit.
+	MaxNameLength int
+	// Windows rejects the names which can't be created on Windows: the ones
+	// holding control characters or any of <>:"\|?*, ending with a dot or a
+	// space, or naming a device such as CON, NUL or COM1.
+	Windows bool
+}
+
+// Reason tells why a path was rejected.
+type Reason int
+
+const (
+	// PathTooLong is returned for paths longer than MaxPathLength.
+	PathTooLong Reason = iota + 1
+	// NameTooLong is returned for components longer than MaxNameLength.
+	NameTooLong
+	// InvalidUTF8 is returned for names which aren't valid UTF-8.
+	InvalidUTF8
+	// NULByte is returned for names holding a NUL byte.
+	NULByte
+	// InvalidWindowsName is returned, with Options.Windows, for names holding
+	// a character invalid on Windows or ending with a dot or a space.
+	InvalidWindowsName
+	// ReservedWindowsName is returned, with Options.Windows, for the names
+	// of devices, with or without extension.
+	ReservedWindowsName
+)
+
+var reasons = map[Reason]string{
+	PathTooLong:         "path too long",
+	NameTooLong:         "name too long",
+	InvalidUTF8:         "invalid UTF-8",
+	NULByte:             "NUL byte in name",
+	InvalidWindowsName:  "name invalid on windows",
+	ReservedWindowsName: "name reserved on windows",
+}
+
+func (r Reason) String() string {
+	if s, ok := reasons[r]; ok {
+		return s
+	}
+
+	return "Reason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// Error is returned by the FS operations given a name which doesn't pass the
+// checks. It matches syscall.ENAMETOOLONG for length errors and os.ErrInvalid
+// otherwise.
+type Error struct {
+	Op   string
+	Path string
+	// Name is the offending path component, empty for PathTooLong.
+	Name   string
+	Reason Reason
+}
+
+func (e *Error) Error() string {
+	msg := e.Op + " " + strconv.Quote(e.Path) + ": " + e.Reason.String()
+	if e.Name != "" && e.Name != e.Path {
+		msg += " " + strconv.Quote(e.Name)
+	}
+
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	if e.Reason == PathTooLong || e.Reason == NameTooLong {
+		return syscall.ENAMETOOLONG
+	}
+
+	return os.ErrInvalid
+}
+
+// FS is a filesystem wrapper checking the names of the files, directories and
+// links it creates. The other operations are forwarded unchanged, so that
+// existing files with invalid names can still be read, renamed or removed.
+type FS struct {
+	wrapper.Base
+	opts Options
+}
+
+// New creates a new filesystem wrapping up the given 'fs', checking the
+// created names against opts.
+func New(fs billy.Filesystem, opts Options) *FS {
+	return &FS{Base: wrapper.NewBase(fs), opts: opts}
+}
+
+// Check returns an *Error if name doesn't pass the checks of opts.
+func Check(op, name string, opts Options) error {
+	if opts.MaxPathLength > 0 && len(name) > opts.MaxPathLength {
+		return &Error{Op: op, Path: name, Reason: PathTooLong}
+	}
+
+	for _, elem := range split(name) {
+		if elem == "" || elem == "." || elem == ".." {
+			continue
+		}
+
+		if r := checkName(elem, opts); r != 0 {
+			return &Error{Op: op, Path: name, Name: elem, Reason: r}
+		}
+	}
+
+	return nil
+}
+
+func split(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/' || os.IsPathSeparator(uint8(r))
+	})
+}
+
+func checkName(name string, opts Options) Reason {
+	switch {
+	case opts.MaxNameLength > 0 && len(name) > opts.MaxNameLength:
+		return NameTooLong
+	case strings.IndexByte(name, 0) >= 0:
+		return NULByte
+	case !utf8.ValidString(name):
+		return InvalidUTF8
+	case !opts.Windows:
+		return 0
+	case strings.IndexFunc(name, invalidWindowsRune) >= 0,
+		strings.HasSuffix(name, "."), strings.HasSuffix(name, " "):
+		return InvalidWindowsName
+	case reservedWindowsName(name):
+		return ReservedWindowsName
+	}
+
+	return 0
+}
+
+func invalidWindowsRune(r rune) bool {
+	return r < 32 || strings.ContainsRune(`<>:"\|?*`, r)
+}
+
+// reservedWindowsName reports whether name is a device name, which Windows
+// reserves in every directory and with any extension.
+func reservedWindowsName(name string) bool {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+
+	switch strings.ToUpper(strings.TrimRight(name, " ")) {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+
+	return false
+}
+
+func (fs *FS) check(op, name string) error {
+	return Check(op, filepath.Clean(name), fs.opts)
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	if err := fs.check("create", filename); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.Create(filename)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		if err := fs.check("open", filename); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs.Base.OpenFile(filename, flag, perm)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	if err := fs.check("rename", to); err != nil {
+		return err
+	}
+
+	return fs.Base.Rename(from, to)
+}
+
+// TempFile checks dir and prefix, the random suffix added to the name being
+// left out of the length checks.
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.check("tempfile", fs.Join(dir, prefix)); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.TempFile(dir, prefix)
+}
+
+// CreateTemp implements billy.TempCreator, checking dir and pattern like
+// TempFile, without the "*" replaced by the random string.
+func (fs *FS) CreateTemp(dir, pattern string) (billy.File, error) {
+	if err := fs.check("createtemp", tempName(dir, pattern)); err != nil {
+		return nil, err
+	}
+
+	return fs.Base.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp implements billy.TempCreator, checking dir and pattern like
+// CreateTemp.
+func (fs *FS) MkdirTemp(dir, pattern string) (string, error) {
+	if err := fs.check("mkdirtemp", tempName(dir, pattern)); err != nil {
+		return "", err
+	}
+
+	return fs.Base.MkdirTemp(dir, pattern)
+}
+
+// tempName returns the name checked for a temporary file or directory
+// created in dir from pattern: the pattern without its last "*".
+func tempName(dir, pattern string) string {
+	if i := strings.LastIndexByte(pattern, '*'); i >= 0 {
+		pattern = pattern[:i] + pattern[i+1:]
+	}
+
+	return filepath.Join(dir, pattern)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	if err := fs.check("mkdir", filename); err != nil {
+		return err
+	}
+
+	return fs.Base.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	if err := fs.check("symlink", link); err != nil {
+		return err
+	}
+
+	return fs.Base.Symlink(target, link)
+}
+
+// Link implements billy.Linker.
+func (fs *FS) Link(oldname, newname string) error {
+	if err := fs.check("link", newname); err != nil {
+		return err
+	}
+
+	return fs.Base.Link(oldname, newname)
+}
+
+// Chroot returns a chrooted view of fs, whose paths are checked as paths of
+// fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
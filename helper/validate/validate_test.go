@@ -0,0 +1,148 @@
+package validate
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), Options{})
+	})
+}
+
+func TestCheck(t *testing.T) {
+	opts := Options{MaxPathLength: 20, MaxNameLength: 8, Windows: true}
+
+	for _, tc := range []struct {
+		name   string
+		reason Reason
+		elem   string
+	}{
+		{name: "foo/bar.txt"},
+		{name: "../foo/./bar"},
+		{name: "héllo"},
+		{name: "CONSOLE"},
+		{name: "foo/bar/baz/qux/quuux", reason: PathTooLong},
+		{name: "foo/verylongname", reason: NameTooLong, elem: "verylongname"},
+		{name: "foo\x00", reason: NULByte, elem: "foo\x00"},
+		{name: "foo/\xff", reason: InvalidUTF8, elem: "\xff"},
+		{name: "a:b", reason: InvalidWindowsName, elem: "a:b"},
+		{name: "foo/bar.", reason: InvalidWindowsName, elem: "bar."},
+		{name: "dir /x", reason: InvalidWindowsName, elem: "dir "},
+		{name: "x/nul.txt", reason: ReservedWindowsName, elem: "nul.txt"},
+		{name: "Com1", reason: ReservedWindowsName, elem: "Com1"},
+	} {
+		err := Check("create", tc.name, opts)
+		if tc.reason == 0 {
+			if err != nil {
+				t.Errorf("Check(%q): unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+
+		var verr *Error
+		if !errors.As(err, &verr) {
+			t.Errorf("Check(%q): expected *Error, got %v", tc.name, err)
+			continue
+		}
+		if verr.Reason != tc.reason || verr.Name != tc.elem || verr.Path != tc.name {
+			t.Errorf("Check(%q): unexpected error %#v", tc.name, verr)
+		}
+	}
+
+	if err := Check("create", "a:b/nul", Options{}); err != nil {
+		t.Errorf("windows names rejected without the option: %v", err)
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	err := Check("mkdir", strings.Repeat("a", 10), Options{MaxNameLength: 5})
+	if !errors.Is(err, syscall.ENAMETOOLONG) {
+		t.Errorf("expected ENAMETOOLONG, got %v", err)
+	}
+
+	err = Check("mkdir", "a\x00", Options{})
+	if !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+	if msg := err.Error(); msg != `mkdir "a\x00": NUL byte in name` {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestFS(t *testing.T) {
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "aux", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := New(mem, Options{Windows: true})
+
+	_, err := fs.Create("dir/con.txt")
+	assertReason(t, err, ReservedWindowsName)
+	_, err = fs.OpenFile("a?b", os.O_CREATE|os.O_WRONLY, 0o644)
+	assertReason(t, err, InvalidWindowsName)
+	assertReason(t, fs.MkdirAll("x/y.", 0o755), InvalidWindowsName)
+	assertReason(t, fs.Symlink("target", "lpt1"), ReservedWindowsName)
+	assertReason(t, fs.Rename("aux", "prn"), ReservedWindowsName)
+
+	sub, err := fs.Chroot("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sub.Create("nul")
+	assertReason(t, err, ReservedWindowsName)
+
+	// Existing files are still reachable, and can be renamed to a valid name.
+	f, err := fs.Open("aux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := fs.Rename("aux", "aux_"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Create("dir/file.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTempCreator(t *testing.T) {
+	fs := New(memfs.New(), Options{Windows: true, MaxNameLength: 8})
+
+	_, err := fs.CreateTemp("con", "tmp-*")
+	assertReason(t, err, ReservedWindowsName)
+	_, err = fs.MkdirTemp("dir", "a?b*")
+	assertReason(t, err, InvalidWindowsName)
+	_, err = fs.CreateTemp("dir", "too-long-*")
+	assertReason(t, err, NameTooLong)
+
+	// The "*" replaced by the random string isn't checked.
+	f, err := fs.CreateTemp("dir", "tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := fs.MkdirTemp("dir", "tmp-*"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertReason(t *testing.T, err error, reason Reason) {
+	t.Helper()
+
+	var verr *Error
+	if !errors.As(err, &verr) || verr.Reason != reason {
+		t.Errorf("expected %s error, got %v", reason, err)
+	}
+}
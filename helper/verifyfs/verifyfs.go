@@ -0,0 +1,197 @@
+// Package verifyfs provides a billy filesystem wrapper verifying the content
+// of the files it opens against a manifest of digests, so that a tree
+// verified when downloaded can't be tampered with before being used.
+package verifyfs // import "github.com/go-git/go-billy/v5/helper/verifyfs"
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// ErrUnlisted is returned, with Options.Strict, when opening a file missing
+// from the manifest.
+var ErrUnlisted = errors.New("file not in manifest")
+
+// Manifest holds the expected digests of files, keyed by their slash
+// separated path relative to the root of the filesystem.
+type Manifest map[string]digest.Digest
+
+// BuildManifest returns the manifest of the regular files of fs, with
+// digests computed with alg.
+func BuildManifest(fs billy.Filesystem, alg digest.Algorithm) (Manifest, error) {
+	m := make(Manifest)
+	err := util.Walk(fs, "", func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+
+		d, err := digestFile(fs, path, alg)
+		if err != nil {
+			return err
+		}
+		m[clean(path)] = d
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func digestFile(fs billy.Basic, name string, alg digest.Algorithm) (digest.Digest, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return alg.FromReader(f)
+}
+
+// clean returns name as a slash separated path relative to the root.
+func clean(name string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(string(filepath.Separator)+name)), "/")
+}
+
+// Options configures an FS.
+type Options struct {
+	// Strict refuses to open the files missing from the manifest, with
+	// ErrUnlisted, instead of opening them unverified.
+	Strict bool
+}
+
+// FS is a filesystem wrapper verifying the files listed in its manifest when
+// they are opened for reading: their whole content is hashed first, opening
+// them failing with a *util.DigestError if it doesn't match. The files
+// opened read-only are hashed once more while read sequentially, so that
+// tampering after Open is reported too, by the Read reaching the end of the
+// file instead of io.EOF.
+//
+// The files opened for writing only aren't verified, nor are the other
+// operations checked, the files modified through FS failing verification
+// as any other.
+type FS struct {
+	wrapper.Base
+	manifest Manifest
+	opts     Options
+}
+
+// New returns a filesystem wrapping fs, verifying its files against m.
+func New(fs billy.Filesystem, m Manifest, opts Options) *FS {
+	return &FS{Base: wrapper.NewBase(fs), manifest: m, opts: opts}
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_WRONLY != 0 || flag&os.O_TRUNC != 0 {
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	expected, ok := fs.manifest[clean(filename)]
+	if !ok {
+		if fs.opts.Strict {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: ErrUnlisted}
+		}
+		return fs.Base.OpenFile(filename, flag, perm)
+	}
+
+	if err := fs.Verify(filename, expected); err != nil {
+		return nil, err
+	}
+
+	f, err := fs.Base.OpenFile(filename, flag, perm)
+	if err != nil || flag&os.O_RDWR != 0 {
+		return f, err
+	}
+
+	return &file{File: f, expected: expected, digester: expected.Algorithm().Digester()}, nil
+}
+
+// Verify returns a *util.DigestError if the content of the named file
+// doesn't match expected.
+func (fs *FS) Verify(filename string, expected digest.Digest) error {
+	if err := expected.Validate(); err != nil {
+		return &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+
+	actual, err := digestFile(fs.Unwrap(), filename, expected.Algorithm())
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return &util.DigestError{Path: filename, Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// Chroot returns a chrooted view of fs, whose files are looked up in the
+// manifest by their path in fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file is a file opened read-only by an FS, verified again when read
+// sequentially to its end.
+type file struct {
+	billy.File
+	expected digest.Digest
+	// digester hashes the content read since the beginning of the file, nil
+	// once it isn't read sequentially.
+	digester digest.Digester
+	err      error
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	n, err := f.File.Read(p)
+	if f.digester == nil {
+		return n, err
+	}
+
+	f.digester.Hash().Write(p[:n])
+	if err == io.EOF {
+		actual := f.digester.Digest()
+		f.digester = nil
+		if actual != f.expected {
+			f.err = &util.DigestError{Path: f.Name(), Expected: f.expected, Actual: actual}
+			return n, f.err
+		}
+	}
+
+	return n, err
+}
+
+// Seek seeks the file, its content being verified again when read from the
+// beginning.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.File.Seek(offset, whence)
+	if err != nil || (offset == 0 && whence == io.SeekCurrent) {
+		return pos, err
+	}
+
+	if pos == 0 && f.err == nil {
+		f.digester = f.expected.Algorithm().Digester()
+	} else {
+		f.digester = nil
+	}
+
+	return pos, nil
+}
@@ -0,0 +1,117 @@
+package verifyfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func newVerifiedFS(t *testing.T, opts Options) (billy.Filesystem, *FS) {
+	base := memfs.New()
+	for name, content := range map[string]string{
+		"clusters/app.yaml": "kind: App",
+		"README.md":         "foo",
+	} {
+		if err := util.WriteFile(base, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := BuildManifest(base, digest.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m["clusters/app.yaml"] != digest.FromString("kind: App") {
+		t.Fatalf("unexpected manifest %v", m)
+	}
+
+	return base, New(base, m, opts)
+}
+
+func assertDigestError(t *testing.T, err error) {
+	t.Helper()
+
+	var derr *util.DigestError
+	if !errors.As(err, &derr) {
+		t.Fatalf("got %v, want a DigestError", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	base, fs := newVerifiedFS(t, Options{})
+
+	content, err := util.ReadFile(fs, "clusters/app.yaml")
+	if err != nil || string(content) != "kind: App" {
+		t.Fatalf("got %q, %v", content, err)
+	}
+
+	if err := util.WriteFile(base, "clusters/app.yaml", []byte("kind: Evil"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = fs.Open("clusters/app.yaml")
+	assertDigestError(t, err)
+
+	if err := util.WriteFile(base, "unlisted", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.ReadFile(fs, "unlisted"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTamperedAfterOpen(t *testing.T) {
+	base, fs := newVerifiedFS(t, Options{})
+
+	f, err := fs.Open("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := util.WriteFile(base, "README.md", []byte("bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(f)
+	assertDigestError(t, err)
+}
+
+func TestStrict(t *testing.T) {
+	base, fs := newVerifiedFS(t, Options{Strict: true})
+	if err := util.WriteFile(base, "unlisted", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := fs.Open("unlisted")
+	if !errors.Is(err, ErrUnlisted) {
+		t.Errorf("got %v, want ErrUnlisted", err)
+	}
+
+	if _, err := fs.OpenFile("unlisted", os.O_WRONLY|os.O_TRUNC, 0); err != nil {
+		t.Errorf("opening for writing: %v", err)
+	}
+}
+
+func TestChroot(t *testing.T) {
+	base, fs := newVerifiedFS(t, Options{})
+
+	chroot, err := fs.Chroot("clusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.ReadFile(chroot, "app.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.WriteFile(base, "clusters/app.yaml", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = chroot.Open("app.yaml")
+	assertDigestError(t, err)
+}
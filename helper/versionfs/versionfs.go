@@ -0,0 +1,349 @@
+// Package versionfs provides a billy filesystem wrapper keeping the previous
+// versions of the files it overwrites or removes, to inspect what changed
+// between two runs of a program without a full version control system.
+package versionfs // import "github.com/go-git/go-billy/v5/helper/versionfs"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/wrapper"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Suffix is appended to the path of a file to name the directory holding
+// its versions in the history filesystem.
+const Suffix = ".versions"
+
+// Options configures a FS.
+type Options struct {
+	// MaxVersions is the number of versions kept for each file, the older
+	// ones being removed as new ones are recorded. Zero means no limit.
+	MaxVersions int
+}
+
+// PruneOptions selects the versions removed by Prune. A version is removed
+// if it matches any of the criteria.
+type PruneOptions struct {
+	// MaxVersions is the number of versions kept for each file. Zero means
+	// no limit.
+	MaxVersions int
+	// MaxAge is the age past which versions are removed. Zero means no
+	// limit.
+	MaxAge time.Duration
+}
+
+// Version describes a previous version of a file.
+type Version struct {
+	// ID identifies the version among the ones of the file; it grows with
+	// each version recorded.
+	ID int
+	// Time is when the version was replaced or removed.
+	Time time.Time
+	// Size and Mode are the ones of the file at the time.
+	Size int64
+	Mode os.FileMode
+}
+
+// FS is a billy.Filesystem recording a version of a regular file in the
+// history filesystem each time it is truncated, modified through a file
+// opened for writing, removed, or replaced by a rename. The versions of
+// dir/file are stored as dir/file.versions/<ID> in the history filesystem.
+//
+// The history is tied to the paths: a renamed file keeps its previous
+// versions under its former name.
+type FS struct {
+	wrapper.Base
+	history billy.Filesystem
+	opts    Options
+
+	m sync.Mutex
+}
+
+// New creates a new filesystem wrapping up the given 'fs', storing the
+// versions of its files in history, which must not be fs nor be below it.
+func New(fs, history billy.Filesystem, opts Options) *FS {
+	return &FS{Base: wrapper.NewBase(fs), history: history, opts: opts}
+}
+
+func clean(name string) string {
+	sep := string(filepath.Separator)
+	return strings.TrimPrefix(filepath.Clean(sep+filepath.FromSlash(name)), sep)
+}
+
+func versionDir(name string) string {
+	return clean(name) + Suffix
+}
+
+func versionName(id int) string {
+	return fmt.Sprintf("%06d", id)
+}
+
+// record saves the current content of name, if it is a regular file.
+func (fs *FS) record(name string) error {
+	fi, err := fs.Base.Lstat(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	versions, err := fs.versions(name)
+	if err != nil {
+		return err
+	}
+
+	id := 1
+	if len(versions) != 0 {
+		id = versions[len(versions)-1].ID + 1
+	}
+
+	if err := fs.copy(name, id, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if fs.opts.MaxVersions > 0 {
+		_, err = fs.prune(name, append(versions, Version{ID: id}), PruneOptions{MaxVersions: fs.opts.MaxVersions}, time.Time{})
+	}
+
+	return err
+}
+
+func (fs *FS) copy(name string, id int, perm os.FileMode) (err error) {
+	dir := versionDir(name)
+	if err := fs.history.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	src, err := fs.Base.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	path := fs.history.Join(dir, versionName(id))
+	dst, err := fs.history.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = fs.history.Remove(path)
+		}
+	}()
+
+	_, err = io.Copy(dst, src)
+	if err1 := dst.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+// Versions returns the versions recorded for name, from the oldest to the
+// most recent one.
+func (fs *FS) Versions(name string) ([]Version, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	return fs.versions(name)
+}
+
+func (fs *FS) versions(name string) ([]Version, error) {
+	infos, err := fs.history.ReadDir(versionDir(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []Version
+	for _, fi := range infos {
+		id, err := strconv.Atoi(fi.Name())
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+
+		versions = append(versions, Version{
+			ID:   id,
+			Time: fi.ModTime(),
+			Size: fi.Size(),
+			Mode: fi.Mode(),
+		})
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ID < versions[j].ID
+	})
+
+	return versions, nil
+}
+
+// OpenVersion opens the given version of name for reading.
+func (fs *FS) OpenVersion(name string, id int) (billy.File, error) {
+	return fs.history.Open(fs.history.Join(versionDir(name), versionName(id)))
+}
+
+// Prune removes the versions matching opts, for every file of the history.
+// It returns the number of versions removed.
+func (fs *FS) Prune(opts PruneOptions) (int, error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	var names []string
+	err := util.Walk(fs.history, ".", func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && strings.HasSuffix(path, Suffix) {
+			names = append(names, strings.TrimSuffix(path, Suffix))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var removed int
+	for _, name := range names {
+		versions, err := fs.versions(name)
+		if err != nil {
+			return removed, err
+		}
+
+		n, err := fs.prune(name, versions, opts, now)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+func (fs *FS) prune(name string, versions []Version, opts PruneOptions, now time.Time) (int, error) {
+	var removed int
+	for i, v := range versions {
+		tooMany := opts.MaxVersions > 0 && len(versions)-i > opts.MaxVersions
+		tooOld := opts.MaxAge > 0 && now.Sub(v.Time) > opts.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := fs.history.Remove(fs.history.Join(versionDir(name), versionName(v.ID))); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	if removed == len(versions) {
+		_ = fs.history.Remove(versionDir(name))
+	}
+
+	return removed, nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// OpenFile records a version of the file before truncating it. Files opened
+// for writing without truncation record one before their first change.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_TRUNC != 0 {
+		if err := fs.record(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	// New files have no previous version.
+	lazy := flag&(os.O_WRONLY|os.O_RDWR) != 0 && flag&os.O_TRUNC == 0
+	if _, err := fs.Base.Lstat(filename); err != nil {
+		lazy = false
+	}
+
+	f, err := fs.Base.OpenFile(filename, flag, perm)
+	if err != nil || !lazy {
+		return f, err
+	}
+
+	return &file{File: f, fs: fs, name: filename}, nil
+}
+
+func (fs *FS) Remove(filename string) error {
+	if err := fs.record(filename); err != nil {
+		return err
+	}
+
+	return fs.Base.Remove(filename)
+}
+
+// Rename records a version of the file replaced by the rename, if any.
+func (fs *FS) Rename(from, to string) error {
+	if clean(from) != clean(to) {
+		if err := fs.record(to); err != nil {
+			return err
+		}
+	}
+
+	return fs.Base.Rename(from, to)
+}
+
+// Chroot returns a chrooted view of fs. Its files are versioned, under their
+// path from the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// file records a version of the file it was opened from before its first
+// change.
+type file struct {
+	billy.File
+	fs   *FS
+	name string
+
+	once sync.Once
+	err  error
+}
+
+func (f *file) record() error {
+	f.once.Do(func() {
+		f.err = f.fs.record(f.name)
+	})
+
+	return f.err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if err := f.record(); err != nil {
+		return 0, err
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *file) Truncate(size int64) error {
+	if err := f.record(); err != nil {
+		return err
+	}
+
+	return f.File.Truncate(size)
+}
@@ -0,0 +1,151 @@
+package versionfs
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), memfs.New(), Options{})
+	})
+}
+
+// contents returns the content of every version of name.
+func contents(t *testing.T, fs *FS, name string) []string {
+	t.Helper()
+
+	versions, err := fs.Versions(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []string
+	for _, v := range versions {
+		f, err := fs.OpenVersion(name, v.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, string(b))
+	}
+
+	return all
+}
+
+func assertContents(t *testing.T, fs *FS, name string, want ...string) {
+	t.Helper()
+
+	got := contents(t, fs, name)
+	if len(got) != len(want) {
+		t.Fatalf("%s: expected versions %q, got %q", name, want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: expected versions %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	fs := New(memfs.New(), memfs.New(), Options{})
+
+	if err := util.WriteFile(fs, "dir/file", []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, fs, "dir/file")
+
+	if err := util.WriteFile(fs, "dir/file", []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening a file for writing only records a version once modified.
+	f, err := fs.OpenFile("dir/file", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, fs, "dir/file", "v1")
+	if _, err := f.Write([]byte("+")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("+")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, fs, "dir/file", "v1", "v2")
+
+	if err := util.WriteFile(fs, "other", []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("other", "dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, fs, "dir/file", "v1", "v2", "v2++", "other")
+	assertContents(t, fs, "other")
+
+	sub, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(sub, "file", []byte("v3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Remove("file"); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, fs, "dir/file", "v1", "v2", "v2++", "other", "v3")
+}
+
+func TestPrune(t *testing.T) {
+	fs := New(memfs.New(), memfs.New(), Options{MaxVersions: 2})
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if err := util.WriteFile(fs, "file", []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := util.WriteFile(fs, "dir/file", []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertContents(t, fs, "file", "v2", "v3")
+
+	versions, err := fs.Versions("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if versions[0].ID != 2 || versions[1].ID != 3 {
+		t.Errorf("expected the IDs to be kept, got %v", versions)
+	}
+
+	n, err := fs.Prune(PruneOptions{MaxVersions: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 versions removed, got %d", n)
+	}
+	assertContents(t, fs, "file", "v3")
+	assertContents(t, fs, "dir/file", "v3")
+
+	if _, err := fs.Prune(PruneOptions{MaxAge: time.Nanosecond}); err != nil {
+		t.Fatal(err)
+	}
+	assertContents(t, fs, "file")
+	assertContents(t, fs, "dir/file")
+}
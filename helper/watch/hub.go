@@ -0,0 +1,104 @@
+// Package watch helps implementing billy.Watcher: Hub dispatches the events
+// of the backends aware of their own changes, while Poll and NewPolling
+// detect the changes of any filesystem by scanning it periodically.
+package watch // import "github.com/go-git/go-billy/v5/helper/watch"
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// QueueSize is the number of events buffered for each watcher of a Hub.
+// When a watcher falls further behind, the next events are dropped and an
+// EventOverflow is reported instead.
+const QueueSize = 256
+
+// Hub dispatches the changes notified by a filesystem to its watchers. The
+// zero value is ready to use. Notify never blocks, so that it can be called
+// while holding the locks of the filesystem.
+type Hub struct {
+	m        sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+type watcher struct {
+	path     string
+	ch       chan billy.Event
+	overflow bool
+}
+
+// Watch implements billy.Watcher for the paths given to Notify.
+func (h *Hub) Watch(path string) (<-chan billy.Event, func()) {
+	w := &watcher{
+		path: filepath.Clean(path),
+		ch:   make(chan billy.Event, QueueSize),
+	}
+
+	h.m.Lock()
+	if h.watchers == nil {
+		h.watchers = make(map[*watcher]struct{})
+	}
+	h.watchers[w] = struct{}{}
+	h.m.Unlock()
+
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			h.m.Lock()
+			delete(h.watchers, w)
+			h.m.Unlock()
+			close(w.ch)
+		})
+	}
+}
+
+// Notify reports op on name to the watchers of name or of one of its parent
+// directories.
+func (h *Hub) Notify(op billy.EventOp, name string) {
+	name = filepath.Clean(name)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for w := range h.watchers {
+		if !Below(w.path, name) {
+			continue
+		}
+
+		if w.overflow {
+			if !w.send(billy.Event{Op: billy.EventOverflow}) {
+				continue
+			}
+			w.overflow = false
+		}
+
+		if !w.send(billy.Event{Name: name, Op: op}) {
+			w.overflow = true
+		}
+	}
+}
+
+func (w *watcher) send(e billy.Event) bool {
+	select {
+	case w.ch <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// Below reports whether the clean path name is dir or is below it.
+func Below(dir, name string) bool {
+	sep := string(filepath.Separator)
+	switch dir {
+	case ".", "":
+		return !strings.HasPrefix(name, sep)
+	case sep:
+		return strings.HasPrefix(name, sep)
+	}
+
+	return name == dir || strings.HasPrefix(name, dir+sep)
+}
@@ -0,0 +1,158 @@
+package watch
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// DefaultInterval is the interval between two scans used when none is given.
+const DefaultInterval = time.Second
+
+// Scanner is the part of a filesystem Poll needs to scan a tree.
+type Scanner interface {
+	Lstat(filename string) (os.FileInfo, error)
+	ReadDir(path string) ([]os.FileInfo, error)
+	Join(elem ...string) string
+}
+
+// Poll implements billy.Watcher on top of any filesystem, by scanning path
+// every interval and comparing the mode, size and modification time of its
+// entries. Renames are reported as an EventRemove and an EventCreate, and
+// changes undone between two scans go unnoticed.
+func Poll(fs Scanner, path string, interval time.Duration) (<-chan billy.Event, func()) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ch := make(chan billy.Event)
+	done := make(chan struct{})
+	prev := scan(fs, path)
+
+	go func() {
+		defer close(ch)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+			}
+
+			cur := scan(fs, path)
+			for _, e := range diff(prev, cur) {
+				select {
+				case ch <- e:
+				case <-done:
+					return
+				}
+			}
+			prev = cur
+		}
+	}()
+
+	var once sync.Once
+	return ch, func() { once.Do(func() { close(done) }) }
+}
+
+type state struct {
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+}
+
+func scan(fs Scanner, path string) map[string]state {
+	states := make(map[string]state)
+
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		return states
+	}
+
+	var walk func(name string, fi os.FileInfo)
+	walk = func(name string, fi os.FileInfo) {
+		states[name] = state{mode: fi.Mode(), size: fi.Size(), modTime: fi.ModTime()}
+		if !fi.IsDir() {
+			return
+		}
+
+		infos, err := fs.ReadDir(name)
+		if err != nil {
+			return
+		}
+		for _, child := range infos {
+			walk(fs.Join(name, child.Name()), child)
+		}
+	}
+	walk(path, fi)
+
+	return states
+}
+
+// diff returns the events turning prev into cur, sorted by name.
+func diff(prev, cur map[string]state) []billy.Event {
+	var events []billy.Event
+	for name, s := range cur {
+		p, ok := prev[name]
+		switch {
+		case !ok:
+			events = append(events, billy.Event{Name: name, Op: billy.EventCreate})
+		case p.mode.Type() != s.mode.Type():
+			events = append(events,
+				billy.Event{Name: name, Op: billy.EventRemove},
+				billy.Event{Name: name, Op: billy.EventCreate})
+		case p.mode != s.mode:
+			events = append(events, billy.Event{Name: name, Op: billy.EventChmod})
+		case !s.mode.IsDir() && (p.size != s.size || !p.modTime.Equal(s.modTime)):
+			events = append(events, billy.Event{Name: name, Op: billy.EventWrite})
+		}
+	}
+
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			events = append(events, billy.Event{Name: name, Op: billy.EventRemove})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Name < events[j].Name
+	})
+
+	return events
+}
+
+// Polling is a filesystem wrapper implementing billy.Watcher with Poll, for
+// the backends unable to report their changes. It doesn't build on
+// helper/wrapper, as memfs depends on this package.
+type Polling struct {
+	billy.Filesystem
+	interval time.Duration
+}
+
+// NewPolling creates a new filesystem wrapping up the given 'fs', scanning
+// the watched paths every interval, or DefaultInterval if it is zero.
+func NewPolling(fs billy.Filesystem, interval time.Duration) *Polling {
+	return &Polling{Filesystem: fs, interval: interval}
+}
+
+// Watch implements billy.Watcher.
+func (p *Polling) Watch(path string) (<-chan billy.Event, func()) {
+	return Poll(p.Filesystem, path, p.interval)
+}
+
+// Chroot returns a chrooted view of p, which can be watched too.
+func (p *Polling) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(p, path), nil
+}
+
+// Capabilities implements the Capable interface.
+func (p *Polling) Capabilities() billy.Capability {
+	return billy.Capabilities(p.Filesystem)
+}
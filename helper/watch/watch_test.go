@@ -0,0 +1,158 @@
+package watch_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/helper/watch"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// expect reads events from ch until one matches each of want, in order.
+func expect(t *testing.T, ch <-chan billy.Event, want ...billy.Event) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for len(want) > 0 {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed, waiting for %v", want)
+			}
+			if e.Err != nil {
+				t.Fatal(e.Err)
+			}
+			if e.Name == filepath.FromSlash(want[0].Name) && e.Op == want[0].Op {
+				want = want[1:]
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for %v", want)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	fs := memfs.New()
+	if err := fs.MkdirAll("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, stop := fs.(billy.Watcher).Watch("dir")
+	defer stop()
+
+	if err := util.WriteFile(fs, "other", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "dir/file", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dir/file", "dir/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("dir/moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, ch,
+		billy.Event{Name: "dir/file", Op: billy.EventCreate},
+		billy.Event{Name: "dir/file", Op: billy.EventWrite},
+		billy.Event{Name: "dir/file", Op: billy.EventRename},
+		billy.Event{Name: "dir/moved", Op: billy.EventCreate},
+		billy.Event{Name: "dir/moved", Op: billy.EventRemove},
+	)
+
+	stop()
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed by stop")
+	}
+}
+
+func TestHubOverflow(t *testing.T) {
+	var h watch.Hub
+	ch, stop := h.Watch("/")
+	defer stop()
+
+	for i := 0; i < watch.QueueSize+10; i++ {
+		h.Notify(billy.EventWrite, "/file")
+	}
+	for i := 0; i < watch.QueueSize; i++ {
+		<-ch
+	}
+
+	h.Notify(billy.EventRemove, "/file")
+	if e := <-ch; e.Op != billy.EventOverflow {
+		t.Errorf("expected an overflow, got %v", e)
+	}
+	if e := <-ch; e.Op != billy.EventRemove {
+		t.Errorf("expected the removal, got %v", e)
+	}
+}
+
+func TestPolling(t *testing.T) {
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "dir/file", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := watch.NewPolling(mem, 10*time.Millisecond)
+	sub, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, stop := sub.(billy.Watcher).Watch("")
+	defer stop()
+
+	if err := util.WriteFile(mem, "dir/new", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, ch, billy.Event{Name: "new", Op: billy.EventCreate})
+
+	if err := util.WriteFile(mem, "dir/file", []byte("foobar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, ch, billy.Event{Name: "file", Op: billy.EventWrite})
+
+	if err := mem.Remove("dir/new"); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, ch, billy.Event{Name: "new", Op: billy.EventRemove})
+}
+
+func TestNotSupported(t *testing.T) {
+	fs := chroot.New(struct{ billy.Basic }{memfs.New()}, "dir")
+
+	ch, stop := fs.(billy.Watcher).Watch("")
+	defer stop()
+
+	e := <-ch
+	if !errors.Is(e.Err, billy.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", e)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed")
+	}
+}
+
+func TestBelow(t *testing.T) {
+	for _, tc := range []struct {
+		dir, name string
+		below     bool
+	}{
+		{"/", "/foo", true},
+		{"/foo", "/foo", true},
+		{"/foo", "/foo/bar", true},
+		{"/foo", "/foobar", false},
+		{".", "foo", true},
+		{".", "/foo", false},
+	} {
+		if got := watch.Below(tc.dir, tc.name); got != tc.below {
+			t.Errorf("Below(%q, %q) = %v", tc.dir, tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Package webdavfs provides an adapter from billy.Filesystem to the
+// FileSystem of golang.org/x/net/webdav, to serve any billy filesystem, such
+// as a memfs or a bounded osfs, over WebDAV.
+package webdavfs // import "github.com/go-git/go-billy/v5/helper/webdavfs"
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+var _ webdav.FileSystem = (*Adapter)(nil)
+
+// Adapter exposes a billy.Filesystem as a webdav.FileSystem. The names are
+// slash separated and resolved from the root of the wrapped filesystem,
+// which can't be removed nor renamed.
+//
+// The contexts of the requests are ignored, the operations of a billy
+// filesystem not being cancellable.
+type Adapter struct {
+	fs billy.Filesystem
+}
+
+// New returns a webdav.FileSystem backed by the given billy filesystem.
+func New(fs billy.Filesystem) *Adapter {
+	return &Adapter{fs: fs}
+}
+
+// NewHandler returns a webdav.Handler serving fs, with the locks held in
+// memory.
+func NewHandler(fs billy.Filesystem) *webdav.Handler {
+	return &webdav.Handler{
+		FileSystem: New(fs),
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// Mkdir implements webdav.FileSystem, creating the directory at name only,
+// its parent having to exist.
+func (a *Adapter) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p, err := a.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.fs.Lstat(p); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if err := a.checkParent("mkdir", name, p); err != nil {
+		return err
+	}
+
+	if err := a.fs.MkdirAll(p, perm); err != nil {
+		return pathError("mkdir", name, err)
+	}
+
+	return nil
+}
+
+// OpenFile implements webdav.FileSystem. The directories can be opened for
+// reading only, to list their entries. A file is only created if its parent
+// directory exists.
+func (a *Adapter) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p, err := a.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := a.stat(p)
+	switch {
+	case err == nil && fi.IsDir():
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+
+		return &dir{adapter: a, name: name, path: p, info: fi}, nil
+	case os.IsNotExist(err) && flag&os.O_CREATE != 0:
+		if err := a.checkParent("open", name, p); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := a.fs.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+
+	return &file{File: f, adapter: a, name: name, path: p}, nil
+}
+
+// RemoveAll implements webdav.FileSystem.
+func (a *Adapter) RemoveAll(ctx context.Context, name string) error {
+	p, err := a.path("removeall", name)
+	if err != nil {
+		return err
+	}
+	if isRoot(p) {
+		return &os.PathError{Op: "removeall", Path: name, Err: os.ErrInvalid}
+	}
+
+	if err := util.RemoveAll(a.fs, p); err != nil {
+		return pathError("removeall", name, err)
+	}
+
+	return nil
+}
+
+// Rename implements webdav.FileSystem.
+func (a *Adapter) Rename(ctx context.Context, oldName, newName string) error {
+	from, err := a.path("rename", oldName)
+	if err != nil {
+		return err
+	}
+	to, err := a.path("rename", newName)
+	if err != nil {
+		return err
+	}
+	if isRoot(from) || isRoot(to) {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: os.ErrInvalid}
+	}
+
+	if err := a.fs.Rename(from, to); err != nil {
+		var lerr *os.LinkError
+		if errors.As(err, &lerr) {
+			err = lerr.Err
+		}
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: unwrapPathError(err)}
+	}
+
+	return nil
+}
+
+// Stat implements webdav.FileSystem.
+func (a *Adapter) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p, err := a.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := a.stat(p)
+	if err != nil {
+		return nil, pathError("stat", name, err)
+	}
+
+	return fi, nil
+}
+
+// stat returns the FileInfo of p. The root always exists, even if the
+// wrapped filesystem, like a memfs holding nothing yet, reports it missing.
+func (a *Adapter) stat(p string) (os.FileInfo, error) {
+	fi, err := a.fs.Stat(p)
+	if os.IsNotExist(err) && isRoot(p) {
+		return rootInfo{}, nil
+	}
+
+	return fi, err
+}
+
+// path returns the path in the wrapped filesystem of the slash separated
+// name, the ".." elements not climbing above its root.
+func (a *Adapter) path(op, name string) (string, error) {
+	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) ||
+		strings.ContainsRune(name, 0) {
+		return "", &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	clean := path.Clean("/" + name)
+	return a.fs.Join(string(os.PathSeparator), filepath.FromSlash(clean)), nil
+}
+
+// checkParent fails with os.ErrNotExist if the parent of p isn't an existing
+// directory, as expected by WebDAV to answer 409 Conflict.
+func (a *Adapter) checkParent(op, name, p string) error {
+	fi, err := a.stat(filepath.Dir(p))
+	if err != nil {
+		return pathError(op, name, err)
+	}
+	if !fi.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+
+	return nil
+}
+
+func isRoot(p string) bool {
+	return p == string(os.PathSeparator) || p == ""
+}
+
+// file is a regular file, as a webdav.File.
+type file struct {
+	billy.File
+	adapter *Adapter
+	name    string
+	path    string
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+// Stat describes the file with billy.FileStater if implemented, and with a
+// Stat of its path otherwise.
+func (f *file) Stat() (os.FileInfo, error) {
+	if s, ok := f.File.(billy.FileStater); ok {
+		return s.Stat()
+	}
+
+	fi, err := f.adapter.fs.Stat(f.path)
+	if err != nil {
+		return nil, pathError("stat", f.name, err)
+	}
+
+	return fi, nil
+}
+
+// dir is a directory opened to list its entries, as a webdav.File.
+type dir struct {
+	adapter *Adapter
+	name    string
+	path    string
+	info    os.FileInfo
+
+	entries []os.FileInfo
+	offset  int
+	read    bool
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *dir) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: d.name, Err: syscall.EISDIR}
+}
+
+// Seek only supports going back to the start, to list the entries again.
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, &os.PathError{Op: "seek", Path: d.name, Err: syscall.EINVAL}
+	}
+
+	d.entries, d.offset, d.read = nil, 0, false
+	return 0, nil
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+// Readdir behaves like the one of os.File: it returns the count next
+// entries, and io.EOF at the end, or all of them if count isn't positive.
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.read {
+		entries, err := d.adapter.fs.ReadDir(d.path)
+		if _, ok := d.info.(rootInfo); ok && os.IsNotExist(err) {
+			entries, err = nil, nil
+		}
+		if err != nil {
+			return nil, pathError("readdir", d.name, err)
+		}
+
+		d.entries, d.read = entries, true
+	}
+
+	rest := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(rest) {
+		count = len(rest)
+	}
+	d.offset += count
+
+	return rest[:count], nil
+}
+
+// rootInfo describes the root missing from the wrapped filesystem.
+type rootInfo struct{}
+
+func (rootInfo) Name() string       { return "/" }
+func (rootInfo) Size() int64        { return 0 }
+func (rootInfo) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (rootInfo) ModTime() time.Time { return time.Time{} }
+func (rootInfo) IsDir() bool        { return true }
+func (rootInfo) Sys() interface{}   { return nil }
+
+// pathError reports err for name, as the caller knows it, rather than for
+// the path in the wrapped filesystem.
+func pathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: unwrapPathError(err)}
+}
+
+func unwrapPathError(err error) error {
+	var perr *os.PathError
+	if errors.As(err, &perr) {
+		return perr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,123 @@
+package webdavfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string, header ...string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestHandler(t *testing.T) {
+	fs := memfs.New()
+	srv := httptest.NewServer(NewHandler(fs))
+	defer srv.Close()
+
+	for _, c := range []struct {
+		method, path, body string
+		header             []string
+		status             int
+	}{
+		{"MKCOL", "/dir", "", nil, http.StatusCreated},
+		{"MKCOL", "/dir", "", nil, http.StatusMethodNotAllowed},
+		{"MKCOL", "/missing/dir", "", nil, http.StatusConflict},
+		{"PUT", "/dir/file", "foo", nil, http.StatusCreated},
+		{"PUT", "/missing/file", "foo", nil, http.StatusNotFound},
+		{"MOVE", "/dir/file", "", []string{"Destination", "/dir/moved"}, http.StatusCreated},
+		{"COPY", "/dir", "", []string{"Destination", "/copy"}, http.StatusCreated},
+		{"DELETE", "/dir", "", nil, http.StatusNoContent},
+		{"DELETE", "/dir", "", nil, http.StatusNotFound},
+	} {
+		resp := do(t, srv, c.method, c.path, c.body, c.header...)
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %s: got %s, want %d", c.method, c.path, resp.Status, c.status)
+		}
+	}
+
+	resp := do(t, srv, "GET", "/copy/moved", "")
+	if got, _ := io.ReadAll(resp.Body); string(got) != "foo" {
+		t.Errorf("GET: got %q", got)
+	}
+	if _, err := fs.Stat("dir"); !os.IsNotExist(err) {
+		t.Errorf("got %v, want dir removed", err)
+	}
+
+	resp = do(t, srv, "PROPFIND", "/copy", "", "Depth", "1")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(string(body), "/copy/moved") {
+		t.Errorf("PROPFIND: got %s, %s", resp.Status, body)
+	}
+}
+
+func TestAdapter(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "dir/a", []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "dir/b", []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(chroot.New(fs, "/"))
+	ctx := context.Background()
+
+	f, err := a.OpenFile(ctx, "/dir/../dir", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fis, err := f.Readdir(1); err != nil || len(fis) != 1 {
+		t.Errorf("got %v, %v", fis, err)
+	}
+	if fis, err := f.Readdir(-1); err != nil || len(fis) != 1 {
+		t.Errorf("got %v, %v", fis, err)
+	}
+	if _, err := f.Readdir(1); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); err == nil {
+		t.Error("expected reading a directory to fail")
+	}
+
+	if _, err := a.OpenFile(ctx, "/dir", os.O_RDWR, 0); err == nil {
+		t.Error("expected opening a directory for writing to fail")
+	}
+	if err := a.RemoveAll(ctx, "/"); err == nil {
+		t.Error("expected removing the root to fail")
+	}
+	if err := a.Rename(ctx, "/dir", "/"); err == nil {
+		t.Error("expected replacing the root to fail")
+	}
+
+	if _, err := a.Stat(ctx, "/../../dir/a"); err != nil {
+		t.Errorf("got %v, want the path kept under the root", err)
+	}
+	if _, err := a.Stat(ctx, "/nope"); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Package wrapper helps writing billy filesystem wrappers which don't lose
+// the optional capabilities of the filesystem they wrap.
+package wrapper // import "github.com/go-git/go-billy/v5/helper/wrapper"
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// Base is meant to be embedded by filesystem wrappers. It forwards every
+// method of billy.Filesystem, except Chroot, to the wrapped filesystem, as
+// well as the optional interfaces known to billy:
+//
+//   - billy.Change
+//   - billy.Xattrer
+//   - billy.Linker
+//   - billy.Capable
+//   - billy.TempCreator
+//   - billy.StatFS
+//   - billy.Closer
+//   - billy.DirSyncer
+//   - billy.Prefetcher
+//   - billy.Watcher
+//   - Exchange(a, b string) error, used by util.SwapDirs
+//
+// The optional methods return billy.ErrNotSupported when the wrapped
+// filesystem doesn't implement them, except Close, which does nothing then,
+// and Watch, whose channel is closed after a single event holding it.
+// Wrappers only define the methods they intercept; new optional interfaces
+// added to Base are picked up by every wrapper embedding it.
+//
+// Base doesn't implement Chroot, as it can't return a view going through the
+// embedding wrapper. Wrappers usually implement it with chroot.New:
+//
+//	func (fs *MyFS) Chroot(path string) (billy.Filesystem, error) {
+//		return chroot.New(fs, path), nil
+//	}
+type Base struct {
+	underlying billy.Filesystem
+}
+
+// NewBase returns a Base forwarding the calls to fs.
+func NewBase(fs billy.Filesystem) Base {
+	return Base{underlying: fs}
+}
+
+// Unwrap returns the wrapped filesystem.
+func (b Base) Unwrap() billy.Filesystem {
+	return b.underlying
+}
+
+func (b Base) Create(filename string) (billy.File, error) {
+	return b.underlying.Create(filename)
+}
+
+func (b Base) Open(filename string) (billy.File, error) {
+	return b.underlying.Open(filename)
+}
+
+func (b Base) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return b.underlying.OpenFile(filename, flag, perm)
+}
+
+func (b Base) Stat(filename string) (os.FileInfo, error) {
+	return b.underlying.Stat(filename)
+}
+
+func (b Base) Rename(from, to string) error {
+	return b.underlying.Rename(from, to)
+}
+
+func (b Base) Remove(filename string) error {
+	return b.underlying.Remove(filename)
+}
+
+func (b Base) Join(elem ...string) string {
+	return b.underlying.Join(elem...)
+}
+
+func (b Base) TempFile(dir, prefix string) (billy.File, error) {
+	return b.underlying.TempFile(dir, prefix)
+}
+
+func (b Base) ReadDir(path string) ([]os.FileInfo, error) {
+	return b.underlying.ReadDir(path)
+}
+
+func (b Base) MkdirAll(filename string, perm os.FileMode) error {
+	return b.underlying.MkdirAll(filename, perm)
+}
+
+func (b Base) Lstat(filename string) (os.FileInfo, error) {
+	return b.underlying.Lstat(filename)
+}
+
+func (b Base) Symlink(target, link string) error {
+	return b.underlying.Symlink(target, link)
+}
+
+func (b Base) Readlink(link string) (string, error) {
+	return b.underlying.Readlink(link)
+}
+
+func (b Base) Root() string {
+	return b.underlying.Root()
+}
+
+// Capabilities implements the Capable interface.
+func (b Base) Capabilities() billy.Capability {
+	return billy.Capabilities(b.underlying)
+}
+
+func (b Base) change() (billy.Change, error) {
+	c, ok := b.underlying.(billy.Change)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return c, nil
+}
+
+// Chmod implements billy.Change.
+func (b Base) Chmod(name string, mode os.FileMode) error {
+	c, err := b.change()
+	if err != nil {
+		return err
+	}
+
+	return c.Chmod(name, mode)
+}
+
+// Lchown implements billy.Change.
+func (b Base) Lchown(name string, uid, gid int) error {
+	c, err := b.change()
+	if err != nil {
+		return err
+	}
+
+	return c.Lchown(name, uid, gid)
+}
+
+// Chown implements billy.Change.
+func (b Base) Chown(name string, uid, gid int) error {
+	c, err := b.change()
+	if err != nil {
+		return err
+	}
+
+	return c.Chown(name, uid, gid)
+}
+
+// Chtimes implements billy.Change.
+func (b Base) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	c, err := b.change()
+	if err != nil {
+		return err
+	}
+
+	return c.Chtimes(name, atime, mtime)
+}
+
+func (b Base) xattrer() (billy.Xattrer, error) {
+	x, ok := b.underlying.(billy.Xattrer)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return x, nil
+}
+
+// Getxattr implements billy.Xattrer.
+func (b Base) Getxattr(name, attr string) ([]byte, error) {
+	x, err := b.xattrer()
+	if err != nil {
+		return nil, err
+	}
+
+	return x.Getxattr(name, attr)
+}
+
+// Setxattr implements billy.Xattrer.
+func (b Base) Setxattr(name, attr string, value []byte) error {
+	x, err := b.xattrer()
+	if err != nil {
+		return err
+	}
+
+	return x.Setxattr(name, attr, value)
+}
+
+// Listxattr implements billy.Xattrer.
+func (b Base) Listxattr(name string) ([]string, error) {
+	x, err := b.xattrer()
+	if err != nil {
+		return nil, err
+	}
+
+	return x.Listxattr(name)
+}
+
+// Removexattr implements billy.Xattrer.
+func (b Base) Removexattr(name, attr string) error {
+	x, err := b.xattrer()
+	if err != nil {
+		return err
+	}
+
+	return x.Removexattr(name, attr)
+}
+
+// Link implements billy.Linker.
+func (b Base) Link(oldname, newname string) error {
+	l, ok := b.underlying.(billy.Linker)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return l.Link(oldname, newname)
+}
+
+// CreateTemp implements billy.TempCreator.
+func (b Base) CreateTemp(dir, pattern string) (billy.File, error) {
+	tc, ok := b.underlying.(billy.TempCreator)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return tc.CreateTemp(dir, pattern)
+}
+
+// MkdirTemp implements billy.TempCreator.
+func (b Base) MkdirTemp(dir, pattern string) (string, error) {
+	tc, ok := b.underlying.(billy.TempCreator)
+	if !ok {
+		return "", billy.ErrNotSupported
+	}
+
+	return tc.MkdirTemp(dir, pattern)
+}
+
+// StatFS implements billy.StatFS.
+func (b Base) StatFS(path string) (*billy.FSInfo, error) {
+	s, ok := b.underlying.(billy.StatFS)
+	if !ok {
+		return nil, billy.ErrNotSupported
+	}
+
+	return s.StatFS(path)
+}
+
+// Close implements billy.Closer.
+func (b Base) Close() error {
+	return billy.Close(b.underlying)
+}
+
+// SyncDir implements billy.DirSyncer.
+func (b Base) SyncDir(path string) error {
+	s, ok := b.underlying.(billy.DirSyncer)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return s.SyncDir(path)
+}
+
+// Prefetch implements billy.Prefetcher.
+func (b Base) Prefetch(path string, off, length int64) error {
+	p, ok := b.underlying.(billy.Prefetcher)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return p.Prefetch(path, off, length)
+}
+
+// Watch implements billy.Watcher.
+func (b Base) Watch(path string) (<-chan billy.Event, func()) {
+	w, ok := b.underlying.(billy.Watcher)
+	if !ok {
+		ch := make(chan billy.Event, 1)
+		ch <- billy.Event{Err: billy.ErrNotSupported}
+		close(ch)
+		return ch, func() {}
+	}
+
+	return w.Watch(path)
+}
+
+type exchanger interface {
+	Exchange(a, b string) error
+}
+
+// Exchange atomically swaps the paths a and b, if supported by the wrapped
+// filesystem.
+func (b Base) Exchange(x, y string) error {
+	e, ok := b.underlying.(exchanger)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return e.Exchange(x, y)
+}
@@ -0,0 +1,131 @@
+package wrapper
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+)
+
+// counting is a wrapper intercepting only Create.
+type counting struct {
+	Base
+	creates int
+}
+
+func (fs *counting) Create(filename string) (billy.File, error) {
+	fs.creates++
+	return fs.Base.Create(filename)
+}
+
+func (fs *counting) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// changing is a filesystem implementing billy.Change.
+type changing struct {
+	billy.Filesystem
+	chmod string
+}
+
+func (fs *changing) Chmod(name string, mode os.FileMode) error {
+	fs.chmod = name
+	return nil
+}
+
+func (fs *changing) Lchown(name string, uid, gid int) error            { return nil }
+func (fs *changing) Chown(name string, uid, gid int) error             { return nil }
+func (fs *changing) Chtimes(name string, atime, mtime time.Time) error { return nil }
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return &counting{Base: NewBase(memfs.New())}
+	})
+}
+
+func TestOverride(t *testing.T) {
+	fs := &counting{Base: NewBase(memfs.New())}
+
+	f, err := fs.Create("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if fs.creates != 1 {
+		t.Errorf("expected 1 create, got %d", fs.creates)
+	}
+}
+
+func TestForwardChange(t *testing.T) {
+	underlying := &changing{Filesystem: memfs.New()}
+	var fs billy.Filesystem = &counting{Base: NewBase(underlying)}
+
+	c, ok := fs.(billy.Change)
+	if !ok {
+		t.Fatal("expected the wrapper to implement billy.Change")
+	}
+	if err := c.Chmod("foo", 0600); err != nil {
+		t.Fatal(err)
+	}
+	if underlying.chmod != "foo" {
+		t.Errorf("expected Chmod to be forwarded, got %q", underlying.chmod)
+	}
+
+	fs = &counting{Base: NewBase(struct{ billy.Filesystem }{memfs.New()})}
+	if err := fs.(billy.Change).Chmod("foo", 0600); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestForwardWatch(t *testing.T) {
+	fs := &counting{Base: NewBase(memfs.New())}
+	if err := fs.MkdirAll("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	events, stop := fs.Watch("dir")
+	defer stop()
+
+	f, err := fs.Create("dir/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	select {
+	case e := <-events:
+		if e.Name != filepath.Join("dir", "foo") || e.Op&billy.EventCreate == 0 {
+			t.Errorf("expected the creation of dir/foo, got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event")
+	}
+
+	fs = &counting{Base: NewBase(struct{ billy.Filesystem }{memfs.New()})}
+	events, stop = fs.Watch("")
+	defer stop()
+
+	e, ok := <-events
+	if !errors.Is(e.Err, billy.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", e.Err)
+	}
+	if _, ok = <-events; ok {
+		t.Error("expected the channel to be closed")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	underlying := memfs.New()
+	fs := &counting{Base: NewBase(underlying)}
+
+	if billy.Capabilities(fs) != billy.Capabilities(underlying) {
+		t.Errorf("expected the capabilities of the wrapped filesystem")
+	}
+}
@@ -0,0 +1,184 @@
+package httpfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// file is a resource opened for reading.
+type file struct {
+	fs   *FS
+	name string
+	url  string
+	meta *meta
+
+	off    int64
+	closed bool
+	// buf holds the content read ahead from bufOff.
+	buf    []byte
+	bufOff int64
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: os.ErrClosed}
+	}
+	if f.off >= f.meta.size {
+		return 0, io.EOF
+	}
+
+	if f.off < f.bufOff || f.off >= f.bufOff+int64(len(f.buf)) {
+		n := f.fs.readAhead
+		if len(p) > n {
+			n = len(p)
+		}
+		if left := f.meta.size - f.off; int64(n) > left {
+			n = int(left)
+		}
+
+		buf := make([]byte, n)
+		n, err := f.fetch(buf, f.off)
+		if n == 0 {
+			return 0, err
+		}
+		f.buf, f.bufOff = buf[:n], f.off
+	}
+
+	n := copy(p, f.buf[f.off-f.bufOff:])
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: os.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= f.meta.size {
+		return 0, io.EOF
+	}
+
+	want := len(p)
+	if left := f.meta.size - off; int64(want) > left {
+		want = int(left)
+	}
+
+	n, err := f.fetch(p[:want], off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// fetch fills p with the content at off, requesting its range.
+func (f *file) fetch(p []byte, off int64) (int, error) {
+	req, err := f.fs.newRequest(http.MethodGet, f.url)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(len(p))-1))
+	if strongETag(f.meta.etag) {
+		req.Header.Set("If-Match", f.meta.etag)
+	}
+
+	resp, err := f.fs.client.Do(req)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		if start != off {
+			return 0, &os.PathError{Op: "read", Path: f.name, Err: fmt.Errorf("got range from %d, want %d", start, off)}
+		}
+	case http.StatusOK:
+		// The range is ignored by the server, sending the whole content.
+		if etag := resp.Header.Get("ETag"); f.meta.etag != "" && etag != "" && etag != f.meta.etag {
+			return 0, &os.PathError{Op: "read", Path: f.name, Err: ErrModified}
+		}
+		if _, err := io.CopyN(io.Discard, resp.Body, off); err != nil {
+			return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, io.EOF
+	default:
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: checkStatus(f.url, resp)}
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	} else if err != nil {
+		err = &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.meta.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	f.off = offset
+	return offset, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: billy.ErrReadOnly}
+}
+
+func (f *file) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: f.name, Err: billy.ErrReadOnly}
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+
+	f.closed, f.buf = true, nil
+	return nil
+}
+
+// Stat returns the attributes the file had when opened, as billy.FileStater.
+func (f *file) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: filepath.Base(f.name), meta: f.meta}, nil
+}
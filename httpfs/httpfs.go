@@ -0,0 +1,319 @@
+// Package httpfs provides a read-only billy filesystem reading the files
+// served over HTTP(S) under a base URL, such as the artifacts of a remote
+// storage, fetching only the ranges of their content being read.
+package httpfs // import "github.com/go-git/go-billy/v5/httpfs"
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// DefaultReadAhead is the default of Options.ReadAhead.
+const DefaultReadAhead = 1 << 20
+
+// ErrModified is returned by the reads of a file whose content changed on
+// the server since it was opened, as told by its ETag.
+var ErrModified = errors.New("remote file modified")
+
+// Options configures an FS.
+type Options struct {
+	// Client sends the requests, http.DefaultClient being used if nil.
+	Client *http.Client
+	// Header holds the headers added to every request, such as the
+	// credentials.
+	Header http.Header
+	// ReadAhead is the size of the ranges requested by the sequential
+	// reads of the files, DefaultReadAhead being used if zero. The reads
+	// at an offset, with ReadAt, request the range read only.
+	ReadAhead int
+}
+
+// StatusError is returned for the responses with an unexpected status code,
+// other than the ones telling that the file doesn't exist, or that access
+// to it is denied, reported as os.ErrNotExist and os.ErrPermission.
+type StatusError struct {
+	// URL is the URL requested.
+	URL string
+	// StatusCode is the status code of the response.
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// FS is a read-only billy.Filesystem whose files are the resources under a
+// base URL, fetched with GET and HEAD requests. Every write operation fails
+// with billy.ErrReadOnly.
+//
+// HTTP having no way to list a directory, ReadDir isn't supported, and
+// every path is reported as a regular file, the server deciding whether it
+// exists. There are no symbolic links either.
+//
+// The attributes of the files are cached along with their ETag, being
+// revalidated with a conditional request by Stat and Open. The reads of a
+// file opened are bound to the ETag it had then: they fail with ErrModified
+// once the content changes. The files are read with Range requests, the
+// servers not supporting them sending the whole content instead.
+type FS struct {
+	base      *url.URL
+	client    *http.Client
+	header    http.Header
+	readAhead int
+
+	m     sync.Mutex
+	cache map[string]*meta
+}
+
+// meta holds the attributes of a resource.
+type meta struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// New returns a filesystem reading the resources under the http or https
+// URL base.
+func New(base string, opts Options) (*FS, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("httpfs: unsupported URL scheme %q", u.Scheme)
+	}
+
+	fs := &FS{
+		base:      u,
+		client:    opts.Client,
+		header:    opts.Header,
+		readAhead: opts.ReadAhead,
+		cache:     make(map[string]*meta),
+	}
+	if fs.client == nil {
+		fs.client = http.DefaultClient
+	}
+	if fs.readAhead <= 0 {
+		fs.readAhead = DefaultReadAhead
+	}
+
+	return fs, nil
+}
+
+// url returns the URL of the resource at name. The ".." elements can't
+// climb above the base URL.
+func (fs *FS) url(name string) string {
+	clean := path.Clean("/" + filepath.ToSlash(name))
+	return fs.base.JoinPath(clean).String()
+}
+
+func (fs *FS) newRequest(method, url string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range fs.header {
+		req.Header[k] = v
+	}
+
+	return req, nil
+}
+
+// stat returns the attributes of the resource at name, revalidating the
+// cached ones.
+func (fs *FS) stat(op, name string) (*meta, error) {
+	u := fs.url(name)
+	req, err := fs.newRequest(http.MethodHead, u)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	fs.m.Lock()
+	cached := fs.cache[u]
+	fs.m.Unlock()
+	if cached != nil && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := fs.client.Do(req)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached, nil
+	}
+	if err := checkStatus(u, resp); err != nil {
+		fs.m.Lock()
+		delete(fs.cache, u)
+		fs.m.Unlock()
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+
+	m := &meta{size: resp.ContentLength, etag: resp.Header.Get("ETag")}
+	if m.size < 0 {
+		m.size = 0
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		m.modTime, _ = http.ParseTime(lm)
+	}
+
+	fs.m.Lock()
+	fs.cache[u] = m
+	fs.m.Unlock()
+
+	return m, nil
+}
+
+// checkStatus returns the error telling why resp isn't successful, if it
+// isn't.
+func checkStatus(url string, resp *http.Response) error {
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		return nil
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return os.ErrNotExist
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return os.ErrPermission
+	case code == http.StatusPreconditionFailed:
+		return ErrModified
+	default:
+		return &StatusError{URL: url, StatusCode: code}
+	}
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file at filename for reading, its attributes being
+// fetched right away. The flags opening it for writing make it fail with
+// billy.ErrReadOnly.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+
+	m, err := fs.stat("open", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{fs: fs, name: filename, url: fs.url(filename), meta: m}, nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	m, err := fs.stat("stat", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{name: filepath.Base(filename), meta: m}, nil
+}
+
+// Lstat is Stat, as there are no symbolic links.
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	return fs.Stat(filename)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	if _, err := fs.stat("readlink", link); err != nil {
+		return "", err
+	}
+
+	return "", &os.PathError{Op: "readlink", Path: link, Err: syscall.EINVAL}
+}
+
+// ReadDir fails with billy.ErrNotSupported.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: path, Err: billy.ErrNotSupported}
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *FS) Root() string {
+	return "/"
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// Capabilities implements the Capable interface.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability | billy.ConcurrentCapability
+}
+
+type fileInfo struct {
+	name string
+	meta *meta
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.meta.size }
+func (fi *fileInfo) Mode() os.FileMode  { return 0o444 }
+func (fi *fileInfo) ModTime() time.Time { return fi.meta.modTime }
+func (fi *fileInfo) IsDir() bool        { return false }
+
+// Sys returns the ETag of the file, empty if the server sent none.
+func (fi *fileInfo) Sys() interface{} {
+	return fi.meta.etag
+}
+
+// strongETag reports whether etag can be used for the If-Match and If-Range
+// conditions, which weak ETags never satisfy.
+func strongETag(etag string) bool {
+	return etag != "" && !strings.HasPrefix(etag, "W/")
+}
+
+// parseContentRange returns the first byte of the Content-Range of a 206
+// response.
+func parseContentRange(h string) (int64, error) {
+	start, _, ok := strings.Cut(strings.TrimPrefix(h, "bytes "), "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+
+	return strconv.ParseInt(start, 10, 64)
+}
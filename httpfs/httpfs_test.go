@@ -0,0 +1,243 @@
+package httpfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// server serves files from memory, with their ETag, counting the requests.
+type server struct {
+	m        sync.Mutex
+	files    map[string]string
+	etags    map[string]string
+	requests map[string]int
+	noRange  bool
+}
+
+func newServer(t *testing.T, files map[string]string) (*server, *httptest.Server) {
+	s := &server{files: files, etags: make(map[string]string), requests: make(map[string]int)}
+	for name := range files {
+		s.etags[name] = `"v1"`
+	}
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func (s *server) set(name, content, etag string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.files[name], s.etags[name] = content, etag
+}
+
+func (s *server) count(method string) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.requests[method]
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.m.Lock()
+	s.requests[r.Method]++
+	content, ok := s.files[r.URL.Path]
+	etag := s.etags[r.URL.Path]
+	s.m.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	if s.noRange {
+		r.Header.Del("Range")
+	}
+	http.ServeContent(w, r, r.URL.Path, time.Unix(1700000000, 0), strings.NewReader(content))
+}
+
+func newTestFS(t *testing.T, url string, readAhead int) *FS {
+	fs, err := New(url+"/artifacts", Options{
+		Header:    http.Header{"Authorization": {"Bearer token"}},
+		ReadAhead: readAhead,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestStat(t *testing.T) {
+	s, ts := newServer(t, map[string]string{"/artifacts/dir/file": "hello world"})
+	fs := newTestFS(t, ts.URL, 0)
+
+	fi, err := fs.Stat("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "file" || fi.Size() != 11 || fi.IsDir() || fi.Sys() != `"v1"` {
+		t.Errorf("got %s of %d bytes, ETag %v", fi.Name(), fi.Size(), fi.Sys())
+	}
+	if !fi.ModTime().Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("got modification time %s", fi.ModTime())
+	}
+
+	if _, err := fs.Stat("missing"); !os.IsNotExist(err) {
+		t.Errorf("got %v, want not exist", err)
+	}
+	if _, err := fs.Stat("../../dir/file"); err != nil {
+		t.Errorf("got %v, want the path kept under the base", err)
+	}
+
+	// The cached attributes are revalidated.
+	if _, err := fs.Stat("dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	s.set("/artifacts/dir/file", "changed", `"v2"`)
+	fi, err = fs.Stat("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 7 || fi.Sys() != `"v2"` {
+		t.Errorf("got %d bytes, ETag %v, want the new content", fi.Size(), fi.Sys())
+	}
+
+	unauthorized, err := New(ts.URL, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unauthorized.Stat("artifacts/dir/file"); !os.IsPermission(err) {
+		t.Errorf("got %v, want permission denied", err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	for _, noRange := range []bool{false, true} {
+		s, ts := newServer(t, map[string]string{"/artifacts/file": content})
+		s.noRange = noRange
+		fs := newTestFS(t, ts.URL, 64)
+
+		f, err := fs.Open("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := make([]byte, 5)
+		if n, err := f.ReadAt(p, 995); n != 5 || err != nil || string(p) != "56789" {
+			t.Errorf("no range %v: ReadAt: got %d, %v, %q", noRange, n, err, p)
+		}
+		if n, err := f.ReadAt(p, 998); n != 2 || err != io.EOF {
+			t.Errorf("no range %v: ReadAt at the end: got %d, %v", noRange, n, err)
+		}
+
+		gets := s.count(http.MethodGet)
+		got, err := io.ReadAll(iotest.OneByteReader(f))
+		if err != nil || !bytes.Equal(got, []byte(content)) {
+			t.Errorf("no range %v: got %d bytes, %v", noRange, len(got), err)
+		}
+		if n := s.count(http.MethodGet) - gets; n != 16 {
+			t.Errorf("no range %v: got %d requests, want one per 64 bytes", noRange, n)
+		}
+
+		if _, err := f.Seek(-3, io.SeekEnd); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(f); err != nil || string(got) != "789" {
+			t.Errorf("no range %v: got %q, %v", noRange, got, err)
+		}
+
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModified(t *testing.T) {
+	s, ts := newServer(t, map[string]string{"/artifacts/file": "hello world"})
+	fs := newTestFS(t, ts.URL, 0)
+
+	f, err := fs.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s.set("/artifacts/file", "HELLO WORLD", `"v2"`)
+	if _, err := io.ReadAll(f); !errors.Is(err, ErrModified) {
+		t.Errorf("got %v, want ErrModified", err)
+	}
+
+	g, err := fs.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+
+	if got, err := io.ReadAll(g); err != nil || string(got) != "HELLO WORLD" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	_, ts := newServer(t, map[string]string{"/artifacts/file": "hello world"})
+	fs := newTestFS(t, ts.URL, 0)
+
+	if _, err := fs.Create("foo"); err != billy.ErrReadOnly {
+		t.Errorf("Create: got %v", err)
+	}
+	if _, err := fs.OpenFile("file", os.O_WRONLY, 0); err != billy.ErrReadOnly {
+		t.Errorf("OpenFile: got %v", err)
+	}
+	if _, err := fs.ReadDir("/"); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("ReadDir: got %v", err)
+	}
+	if billy.CapabilityCheck(fs, billy.WriteCapability) {
+		t.Error("WriteCapability reported")
+	}
+
+	f, err := fs.Open("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("foo")); !errors.Is(err, billy.ErrReadOnly) {
+		t.Errorf("Write: got %v", err)
+	}
+}
+
+func TestChroot(t *testing.T) {
+	_, ts := newServer(t, map[string]string{"/artifacts/dir/file": "hello world"})
+	fs := newTestFS(t, ts.URL, 0)
+
+	ch, err := fs.Chroot("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(ch, "file"); err != nil || string(got) != "hello world" {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("ftp://example.com", Options{}); err == nil {
+		t.Error("expected an error for the ftp scheme")
+	}
+}
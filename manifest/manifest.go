@@ -0,0 +1,386 @@
+// Package manifest generates and verifies mtree-style manifests of billy
+// filesystems. A manifest records the path, type, permissions, size and
+// digest of every entry in a tree, so the tree can later be checked for
+// modifications.
+package manifest // import "github.com/go-git/go-billy/v5/manifest"
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Type is the type of a manifest entry.
+type Type string
+
+const (
+	File    Type = "file"
+	Dir     Type = "dir"
+	Symlink Type = "link"
+	Other   Type = "other"
+)
+
+// Entry describes a single node of a tree.
+type Entry struct {
+	// Path is the slash separated path of the entry relative to the root of
+	// the tree. The root itself is represented by ".".
+	Path string
+	Type Type
+	// Mode holds the permission bits of the entry.
+	Mode os.FileMode
+	// Size is only set for regular files.
+	Size int64
+	// Digest is the hex encoded SHA-256 of the content, only set for regular
+	// files.
+	Digest string
+	// Target is the link target, only set for symlinks.
+	Target string
+}
+
+// Manifest is a lexically sorted list of entries.
+type Manifest struct {
+	Entries []Entry
+}
+
+// Generate walks the tree rooted at root and returns its manifest. Symlinks
+// are recorded but never followed.
+func Generate(fs billy.Filesystem, root string) (*Manifest, error) {
+	m := &Manifest{}
+	err := util.Walk(fs, root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		e, err := newEntry(fs, p, filepath.ToSlash(rel), fi)
+		if err != nil {
+			return err
+		}
+
+		m.Entries = append(m.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.sort()
+	return m, nil
+}
+
+func newEntry(fs billy.Filesystem, fullpath, rel string, fi os.FileInfo) (Entry, error) {
+	e := Entry{Path: rel, Type: entryType(fi.Mode()), Mode: fi.Mode().Perm()}
+	switch e.Type {
+	case File:
+		e.Size = fi.Size()
+		digest, err := digestFile(fs, fullpath)
+		if err != nil {
+			return e, err
+		}
+		e.Digest = digest
+	case Symlink:
+		target, err := fs.Readlink(fullpath)
+		if err != nil {
+			return e, err
+		}
+		e.Target = filepath.ToSlash(target)
+	}
+
+	return e, nil
+}
+
+func entryType(m os.FileMode) Type {
+	switch {
+	case m.IsRegular():
+		return File
+	case m.IsDir():
+		return Dir
+	case m&os.ModeSymlink != 0:
+		return Symlink
+	default:
+		return Other
+	}
+}
+
+func digestFile(fs billy.Basic, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (m *Manifest) sort() {
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+}
+
+// Digests returns the digests of the regular files of m, keyed by path, as
+// expected by util.SyncOptions.
+func (m *Manifest) Digests() map[string]string {
+	digests := make(map[string]string)
+	for _, e := range m.Entries {
+		if e.Type == File && e.Digest != "" {
+			digests[e.Path] = e.Digest
+		}
+	}
+
+	return digests
+}
+
+// Verify checks the tree rooted at root against the manifest. Entries
+// missing from the tree, entries not present in the manifest and entries
+// whose attributes differ are returned in the report. The error is only
+// non-nil if the filesystem could not be read.
+func (m *Manifest) Verify(fs billy.Filesystem, root string) (util.Report, error) {
+	var report util.Report
+
+	expected := make(map[string]Entry, len(m.Entries))
+	for _, want := range m.Entries {
+		expected[want.Path] = want
+
+		fullpath := fs.Join(root, filepath.FromSlash(want.Path))
+		fi, err := fs.Lstat(fullpath)
+		if os.IsNotExist(err) {
+			report.Missing = append(report.Missing, want.Path)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		got, err := newEntry(fs, fullpath, want.Path, fi)
+		if err != nil {
+			return report, err
+		}
+
+		report.Mismatches = append(report.Mismatches, compare(want, got)...)
+	}
+
+	err := util.Walk(fs, root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if _, ok := expected[rel]; !ok {
+			report.Extra = append(report.Extra, rel)
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func compare(want, got Entry) []util.Mismatch {
+	var ms []util.Mismatch
+	add := func(field string, expected, actual interface{}) {
+		ms = append(ms, util.Mismatch{
+			Path:     want.Path,
+			Field:    field,
+			Expected: fmt.Sprint(expected),
+			Actual:   fmt.Sprint(actual),
+		})
+	}
+
+	if want.Type != got.Type {
+		add("type", want.Type, got.Type)
+		return ms
+	}
+
+	if want.Type != Symlink && want.Mode != got.Mode {
+		add("mode", want.Mode, got.Mode)
+	}
+
+	switch want.Type {
+	case File:
+		if want.Size != got.Size {
+			add("size", want.Size, got.Size)
+		}
+		if want.Digest != got.Digest {
+			add("digest", want.Digest, got.Digest)
+		}
+	case Symlink:
+		if want.Target != got.Target {
+			add("target", want.Target, got.Target)
+		}
+	}
+
+	return ms
+}
+
+// WriteTo writes the manifest to w in a line based, mtree-like format:
+//
+//	dir/file type=file mode=0644 size=3 sha256digest=2c26b46b...
+//
+// Paths and link targets are escaped so they never contain whitespace.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, e := range m.Entries {
+		fields := []string{
+			escape(e.Path),
+			"type=" + string(e.Type),
+			fmt.Sprintf("mode=%#o", uint32(e.Mode)),
+		}
+
+		switch e.Type {
+		case File:
+			fields = append(fields, fmt.Sprintf("size=%d", e.Size), "sha256digest="+e.Digest)
+		case Symlink:
+			fields = append(fields, "link="+escape(e.Target))
+		}
+
+		n, err := io.WriteString(w, strings.Join(fields, " ")+"\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Parse reads a manifest previously written with WriteTo. Empty lines and
+// lines starting with '#' are ignored.
+func Parse(r io.Reader) (*Manifest, error) {
+	m := &Manifest{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		e, err := parseEntry(strings.Fields(text))
+		if err != nil {
+			return nil, fmt.Errorf("manifest: line %d: %w", line, err)
+		}
+
+		m.Entries = append(m.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	m.sort()
+	return m, nil
+}
+
+func parseEntry(fields []string) (Entry, error) {
+	var e Entry
+	p, err := unescape(fields[0])
+	if err != nil {
+		return e, err
+	}
+	e.Path = path.Clean(p)
+
+	for _, kv := range fields[1:] {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return e, fmt.Errorf("malformed keyword %q", kv)
+		}
+
+		key, value := kv[:i], kv[i+1:]
+		switch key {
+		case "type":
+			e.Type = Type(value)
+		case "mode":
+			mode, err := strconv.ParseUint(value, 0, 32)
+			if err != nil {
+				return e, err
+			}
+			e.Mode = os.FileMode(mode)
+		case "size":
+			if e.Size, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return e, err
+			}
+		case "sha256digest":
+			e.Digest = value
+		case "link":
+			if e.Target, err = unescape(value); err != nil {
+				return e, err
+			}
+		default:
+			return e, fmt.Errorf("unknown keyword %q", key)
+		}
+	}
+
+	if e.Type == "" {
+		return e, fmt.Errorf("missing type for %q", e.Path)
+	}
+
+	return e, nil
+}
+
+// escape encodes whitespace, control characters, '\\', '#' and '=' as
+// backslash octal sequences, in the same way mtree(8) does.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '\\' || c == '#' || c == '=' {
+			fmt.Fprintf(&b, "\\%03o", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+func unescape(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+4 > len(s) {
+			return "", fmt.Errorf("invalid escape sequence in %q", s)
+		}
+
+		c, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence in %q", s)
+		}
+		b.WriteByte(byte(c))
+		i += 3
+	}
+
+	return b.String(), nil
+}
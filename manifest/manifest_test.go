@@ -0,0 +1,158 @@
+package manifest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func buildTree(t *testing.T) billy.Filesystem {
+	fs := memfs.New()
+	for name, content := range map[string]string{
+		"src/foo":            "foo",
+		"src/dir/bar":        "bar",
+		"src/with space#tag": "qux",
+	} {
+		if err := util.WriteFile(fs, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Symlink("dir/bar", "src/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestGenerate(t *testing.T) {
+	m, err := Generate(buildTree(t), "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, e := range m.Entries {
+		paths = append(paths, e.Path)
+	}
+
+	expected := []string{".", "dir", "dir/bar", "foo", "link", "with space#tag"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %v, got %v", expected, paths)
+	}
+
+	foo := m.Entries[3]
+	if foo.Type != File || foo.Size != 3 || foo.Mode != 0644 ||
+		foo.Digest != "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae" {
+		t.Errorf("unexpected entry: %+v", foo)
+	}
+
+	link := m.Entries[4]
+	if link.Type != Symlink || link.Target != "dir/bar" {
+		t.Errorf("unexpected entry: %+v", link)
+	}
+}
+
+func TestWriteToParse(t *testing.T) {
+	m, err := Generate(buildTree(t), "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, parsed) {
+		t.Errorf("round trip mismatch:\n%+v\n%+v", m, parsed)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(bytes.NewBufferString("foo type=file bogus\n"))
+	if err == nil {
+		t.Error("expected error for malformed keyword")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	fs := buildTree(t)
+	m, err := Generate(fs, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.Verify(fs, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected clean report, got %+v", report)
+	}
+
+	util.WriteFile(fs, "src/foo", []byte("changed"), 0644)
+	util.WriteFile(fs, "src/new/file", nil, 0644)
+	fs.Remove("src/dir/bar")
+
+	report, err = m.Verify(fs, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report.Missing, []string{"dir/bar"}) {
+		t.Errorf("unexpected missing: %v", report.Missing)
+	}
+	if !reflect.DeepEqual(report.Extra, []string{"new"}) {
+		t.Errorf("unexpected extra: %v", report.Extra)
+	}
+
+	var fields []string
+	for _, mm := range report.Mismatches {
+		fields = append(fields, mm.Path+":"+mm.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"foo:size", "foo:digest"}) {
+		t.Errorf("unexpected mismatches: %v", report.Mismatches)
+	}
+}
+
+func TestSyncWithDigests(t *testing.T) {
+	src := buildTree(t)
+	dst := memfs.New()
+	if _, err := util.Sync(dst, "dst", src, "src", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	srcManifest, err := Generate(src, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstManifest, err := Generate(dst, "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digests := srcManifest.Digests()
+	if len(digests) != 3 || digests["dir/bar"] == "" {
+		t.Fatalf("unexpected digests %v", digests)
+	}
+
+	res, err := util.Sync(dst, "dst", src, "src", &util.SyncOptions{
+		SrcDigests: digests,
+		DstDigests: dstManifest.Digests(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 0 || res.Skipped != 4 {
+		t.Errorf("expected nothing to be copied, got %+v", res)
+	}
+}
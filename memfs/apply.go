@@ -0,0 +1,370 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// OpKind is the kind of an Op.
+type OpKind int
+
+const (
+	// OpWrite sets the content of the file at Path to Data, creating it
+	// with Mode, along with its missing parents, if it doesn't exist. The
+	// mode of an existing file is kept.
+	OpWrite OpKind = iota
+	// OpMkdir creates the directory at Path with Mode, along with its
+	// missing parents, as MkdirAll does.
+	OpMkdir
+	// OpSymlink creates a symbolic link at Path, pointing to Target.
+	OpSymlink
+	// OpRename renames Path to Target, replacing the entry found there.
+	OpRename
+	// OpRemove removes the file, or empty directory, at Path.
+	OpRemove
+)
+
+var opKindNames = [...]string{"write", "mkdir", "symlink", "rename", "remove"}
+
+func (k OpKind) String() string {
+	if k < 0 || int(k) >= len(opKindNames) {
+		return fmt.Sprintf("OpKind(%d)", int(k))
+	}
+
+	return opKindNames[k]
+}
+
+// Op is an operation of a batch applied by Memory.Apply.
+type Op struct {
+	Kind OpKind
+	Path string
+	// Target is the new path of OpRename, and the target of OpSymlink.
+	Target string
+	// Data is the content written by OpWrite.
+	Data []byte
+	// Mode is the mode of the entry created by OpWrite or OpMkdir.
+	Mode os.FileMode
+}
+
+// ApplyError is returned by Apply when one of the operations failed, none of
+// them being applied.
+type ApplyError struct {
+	// Index is the index of the operation which failed.
+	Index int
+	Op    Op
+	Err   error
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("memfs: apply: operation %d (%s %s): %v", e.Index, e.Op.Kind, e.Op.Path, e.Err)
+}
+
+func (e *ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// Apply applies ops in order, as a single change of the filesystem: the
+// tree is locked once for all of them, and no other operation sees the
+// filesystem in between. If one of them fails, the ones before it are
+// rolled back, and an *ApplyError is returned.
+//
+// The parents created by the operations get the mode 0755. The paths aren't
+// resolved through symbolic links, an operation on a path below one failing
+// with syscall.ENOTDIR. The watchers are notified once all the operations
+// are applied, and not at all if they are rolled back.
+//
+// The writes to the existing files are seen by the files open on them,
+// including a rolled back write, so that the batches are best applied to
+// the files not in use.
+func (fs *Memory) Apply(ops []Op) error {
+	return fs.s.Apply(ops)
+}
+
+// applier applies a batch of operations to a storage, whose lock is held
+// for writing.
+type applier struct {
+	s *storage
+	// undo reverts the operations applied, in reverse order.
+	undo []func()
+	// unlinked holds the nodes removed from the tree, released once the
+	// batch succeeds.
+	unlinked []*node
+	events   []billy.Event
+}
+
+func (s *storage) Apply(ops []Op) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	a := &applier{s: s}
+	for i, op := range ops {
+		if err := a.apply(op); err != nil {
+			for j := len(a.undo) - 1; j >= 0; j-- {
+				a.undo[j]()
+			}
+			return &ApplyError{Index: i, Op: op, Err: err}
+		}
+	}
+
+	for _, n := range a.unlinked {
+		n.removed = true
+		n.file.content.unlink()
+	}
+	for _, e := range a.events {
+		s.notify(e.Op, e.Name)
+	}
+
+	return nil
+}
+
+func (a *applier) apply(op Op) error {
+	path := clean(op.Path)
+	switch op.Kind {
+	case OpWrite:
+		return a.write(path, op.Data, op.Mode)
+	case OpMkdir:
+		_, err := a.dir(path, op.Mode.Perm()|os.ModeDir)
+		return err
+	case OpSymlink:
+		return a.symlink(op.Target, path)
+	case OpRename:
+		return a.rename(path, clean(op.Target))
+	case OpRemove:
+		return a.remove(path)
+	}
+
+	return syscall.EINVAL
+}
+
+func (a *applier) notify(op billy.EventOp, path string) {
+	a.events = append(a.events, billy.Event{Name: path, Op: op})
+}
+
+// dir returns the directory at path, creating it with mode, along with its
+// missing parents, if needed.
+func (a *applier) dir(path string, mode os.FileMode) (*node, error) {
+	s := a.s
+	if s.root.file == nil {
+		s.root.file = s.newFile(string(separator), os.ModeDir|0o755, 0)
+		a.undo = append(a.undo, func() { s.root.file = nil })
+		a.notify(billy.EventCreate, string(separator))
+	}
+
+	// The paths of the elements, for the notifications.
+	names := split(path)
+	paths := make([]string, len(names))
+	for i, p := len(names)-1, path; i >= 0; i-- {
+		paths[i], p = p, filepath.Dir(p)
+	}
+
+	n := s.root
+	for i, name := range names {
+		c, ok := n.children[name]
+		if !ok {
+			m := os.ModeDir | 0o755
+			if i == len(names)-1 {
+				m = mode
+			}
+			c = a.add(n, name, paths[i], m)
+		}
+		if !c.file.loadMode().IsDir() {
+			return nil, syscall.ENOTDIR
+		}
+		n = c
+	}
+
+	return n, nil
+}
+
+// add adds a new child to n, with an empty file of mode.
+func (a *applier) add(n *node, name, path string, mode os.FileMode) *node {
+	c := &node{file: a.s.newFile(name, mode, 0)}
+	if mode.IsDir() {
+		c.children = make(map[string]*node)
+	}
+
+	n.children[name] = c
+	a.undo = append(a.undo, func() {
+		delete(n.children, name)
+		c.file.content.release()
+	})
+	a.notify(billy.EventCreate, path)
+	return c
+}
+
+// lookup returns the parent directory of path, created if create is set,
+// and the name of path in it.
+func (a *applier) lookup(path string, create bool) (*node, string, error) {
+	names := split(path)
+	if len(names) == 0 {
+		return nil, "", syscall.EINVAL
+	}
+
+	dir := filepath.Dir(path)
+	if create {
+		parent, err := a.dir(dir, os.ModeDir|0o755)
+		return parent, names[len(names)-1], err
+	}
+
+	parent := a.s.node(dir)
+	if parent == nil {
+		return nil, "", os.ErrNotExist
+	}
+	if parent.file == nil || !parent.file.loadMode().IsDir() {
+		return nil, "", syscall.ENOTDIR
+	}
+
+	return parent, names[len(names)-1], nil
+}
+
+func (a *applier) write(path string, data []byte, mode os.FileMode) error {
+	parent, name, err := a.lookup(path, true)
+	if err != nil {
+		return err
+	}
+
+	c, ok := parent.children[name]
+	if !ok {
+		c = a.add(parent, name, path, mode.Perm())
+	} else {
+		m := c.file.loadMode()
+		switch {
+		case m.IsDir():
+			return syscall.EISDIR
+		case !m.IsRegular():
+			return syscall.EINVAL
+		}
+
+		content := c.file.content
+		old := []byte(content.String())
+		a.undo = append(a.undo, func() {
+			_ = content.Truncate(0)
+			_, _ = content.WriteAt(old, 0)
+			content.seal()
+		})
+	}
+
+	if err := c.file.content.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := c.file.content.WriteAt(data, 0); err != nil {
+		return err
+	}
+	c.file.content.seal()
+
+	a.notify(billy.EventWrite, path)
+	return nil
+}
+
+func (a *applier) symlink(target, path string) error {
+	parent, name, err := a.lookup(path, true)
+	if err != nil {
+		return err
+	}
+	if _, ok := parent.children[name]; ok {
+		return os.ErrExist
+	}
+
+	c := a.add(parent, name, path, os.ModeSymlink|0o777)
+	if _, err := c.file.content.WriteAt([]byte(target), 0); err != nil {
+		return err
+	}
+	c.file.content.seal()
+
+	return nil
+}
+
+func (a *applier) rename(from, to string) error {
+	oldParent, oldName, err := a.lookup(from, false)
+	if err != nil {
+		return err
+	}
+	n, ok := oldParent.children[oldName]
+	if !ok {
+		return os.ErrNotExist
+	}
+
+	if from == to {
+		return nil
+	}
+	if strings.HasPrefix(to, from+string(separator)) {
+		return syscall.EINVAL
+	}
+
+	parent, name, err := a.lookup(to, true)
+	if err != nil {
+		return err
+	}
+
+	old, replaced := parent.children[name]
+	if replaced && old.file == n.file {
+		// Both are hard links to the same file, left as they are.
+		return nil
+	}
+	if replaced {
+		if err := checkReplace(n, old); err != nil {
+			return err
+		}
+		a.unlinked = append(a.unlinked, old)
+	}
+
+	delete(oldParent.children, oldName)
+	parent.children[name] = n
+	a.undo = append(a.undo, func() {
+		delete(parent.children, name)
+		if replaced {
+			parent.children[name] = old
+			a.unlinked = a.unlinked[:len(a.unlinked)-1]
+		}
+		oldParent.children[oldName] = n
+	})
+
+	a.notify(billy.EventRename, from)
+	a.notify(billy.EventCreate, to)
+	return nil
+}
+
+// checkReplace checks that the entry n can replace old, as rename(2) does.
+func checkReplace(n, old *node) error {
+	isDir, oldIsDir := n.file.loadMode().IsDir(), old.file.loadMode().IsDir()
+	switch {
+	case oldIsDir && !isDir:
+		return syscall.EISDIR
+	case !oldIsDir && isDir:
+		return syscall.ENOTDIR
+	case oldIsDir && len(old.children) != 0:
+		return syscall.ENOTEMPTY
+	}
+
+	return nil
+}
+
+func (a *applier) remove(path string) error {
+	parent, name, err := a.lookup(path, false)
+	if err != nil {
+		return err
+	}
+
+	n, ok := parent.children[name]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if n.file.loadMode().IsDir() && len(n.children) != 0 {
+		return syscall.ENOTEMPTY
+	}
+
+	delete(parent.children, name)
+	a.unlinked = append(a.unlinked, n)
+	a.undo = append(a.undo, func() {
+		parent.children[name] = n
+		a.unlinked = a.unlinked[:len(a.unlinked)-1]
+	})
+
+	a.notify(billy.EventRemove, path)
+	return nil
+}
@@ -0,0 +1,122 @@
+package memfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type ApplySuite struct{}
+
+var _ = Suite(&ApplySuite{})
+
+func (s *ApplySuite) TestApply(c *C) {
+	fs := New()
+	m := memory(c, fs)
+	c.Assert(util.WriteFile(fs, "old", []byte("old"), 0o644), IsNil)
+	c.Assert(util.WriteFile(fs, "replaced", []byte("replaced"), 0o600), IsNil)
+
+	snap := m.Snapshot()
+	err := m.Apply([]Op{
+		{Kind: OpWrite, Path: "/dir/sub/file", Data: []byte("file"), Mode: 0o640},
+		{Kind: OpWrite, Path: "/replaced", Data: []byte("new")},
+		{Kind: OpMkdir, Path: "/empty", Mode: 0o700},
+		{Kind: OpSymlink, Path: "/link", Target: "dir/sub/file"},
+		{Kind: OpRename, Path: "/old", Target: "/dir/renamed"},
+		{Kind: OpRemove, Path: "/empty"},
+	})
+	c.Assert(err, IsNil)
+
+	b, err := util.ReadFile(fs, "link")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "file")
+
+	b, err = util.ReadFile(fs, "dir/renamed")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "old")
+
+	fi, err := fs.Stat("replaced")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0o600))
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	fi, err = fs.Stat("dir/sub/file")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0o640))
+
+	for _, name := range []string{"old", "empty"} {
+		_, err = fs.Lstat(name)
+		c.Assert(os.IsNotExist(err), Equals, true)
+	}
+
+	c.Assert(m.Changes(snap), DeepEquals, []Change{
+		{Path: abs("dir"), Op: billy.EventCreate},
+		{Path: abs("dir/renamed"), Op: billy.EventCreate},
+		{Path: abs("dir/sub"), Op: billy.EventCreate},
+		{Path: abs("dir/sub/file"), Op: billy.EventCreate | billy.EventWrite},
+		{Path: abs("empty"), Op: billy.EventCreate | billy.EventRemove},
+		{Path: abs("link"), Op: billy.EventCreate},
+		{Path: abs("old"), Op: billy.EventRename},
+		{Path: abs("replaced"), Op: billy.EventWrite},
+	})
+}
+
+func (s *ApplySuite) TestApplyRollback(c *C) {
+	fs := New()
+	m := memory(c, fs)
+	c.Assert(util.WriteFile(fs, "a", []byte("a"), 0o644), IsNil)
+	c.Assert(util.WriteFile(fs, "b", []byte("b"), 0o644), IsNil)
+	c.Assert(util.WriteFile(fs, "dir/c", []byte("c"), 0o644), IsNil)
+
+	snap := m.Snapshot()
+	err := m.Apply([]Op{
+		{Kind: OpWrite, Path: "/a", Data: []byte("changed")},
+		{Kind: OpRename, Path: "/b", Target: "/a"},
+		{Kind: OpRemove, Path: "/dir/c"},
+		{Kind: OpWrite, Path: "/new/file", Data: []byte("new")},
+		{Kind: OpRemove, Path: "/missing"},
+	})
+
+	var aerr *ApplyError
+	c.Assert(errors.As(err, &aerr), Equals, true)
+	c.Assert(aerr.Index, Equals, 4)
+	c.Assert(errors.Is(err, os.ErrNotExist), Equals, true)
+
+	for name, content := range map[string]string{"a": "a", "b": "b", "dir/c": "c"} {
+		b, err := util.ReadFile(fs, name)
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, content)
+	}
+	_, err = fs.Lstat("new")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	c.Assert(m.Changes(snap), HasLen, 0)
+}
+
+func (s *ApplySuite) TestApplyErrors(c *C) {
+	fs := New()
+	m := memory(c, fs)
+	c.Assert(util.WriteFile(fs, "file", nil, 0o644), IsNil)
+	c.Assert(util.WriteFile(fs, "dir/file", nil, 0o644), IsNil)
+
+	for _, t := range []struct {
+		op  Op
+		err error
+	}{
+		{Op{Kind: OpWrite, Path: "/file/below"}, syscall.ENOTDIR},
+		{Op{Kind: OpWrite, Path: "/dir"}, syscall.EISDIR},
+		{Op{Kind: OpSymlink, Path: "/file", Target: "x"}, os.ErrExist},
+		{Op{Kind: OpRemove, Path: "/dir"}, syscall.ENOTEMPTY},
+		{Op{Kind: OpRename, Path: "/dir", Target: "/dir/sub"}, syscall.EINVAL},
+		{Op{Kind: OpRename, Path: "/file", Target: "/dir"}, syscall.EISDIR},
+		{Op{Kind: OpKind(42), Path: "/file"}, syscall.EINVAL},
+	} {
+		err := m.Apply([]Op{t.op})
+		c.Assert(errors.Is(err, t.err), Equals, true, Commentf("%v", err))
+	}
+}
@@ -0,0 +1,116 @@
+package memfs
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// treeSizes are the numbers of files of the trees of the benchmarks, spread
+// over directories of 1000 files.
+var treeSizes = []int{1000, 100000, 1000000}
+
+func newTree(b *testing.B, files int) billy.Filesystem {
+	b.Helper()
+
+	fs := New()
+	for i := 0; i < files; i++ {
+		f, err := fs.Create(fmt.Sprintf("dir%d/file%d", i/1000, i%1000))
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+
+	return fs
+}
+
+func benchmarkTree(b *testing.B, fn func(b *testing.B, fs billy.Filesystem)) {
+	for _, n := range treeSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			fs := newTree(b, n)
+			b.ResetTimer()
+			fn(b, fs)
+		})
+	}
+}
+
+func BenchmarkReadDir(b *testing.B) {
+	benchmarkTree(b, func(b *testing.B, fs billy.Filesystem) {
+		for i := 0; i < b.N; i++ {
+			if _, err := fs.ReadDir("dir0"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkStat(b *testing.B) {
+	benchmarkTree(b, func(b *testing.B, fs billy.Filesystem) {
+		for i := 0; i < b.N; i++ {
+			if _, err := fs.Stat("dir0/file500"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRenameDir(b *testing.B) {
+	benchmarkTree(b, func(b *testing.B, fs billy.Filesystem) {
+		from, to := "dir0", "moved"
+		for i := 0; i < b.N; i++ {
+			if err := fs.Rename(from, to); err != nil {
+				b.Fatal(err)
+			}
+			from, to = to, from
+		}
+	})
+}
+
+func BenchmarkRemoveAll(b *testing.B) {
+	benchmarkTree(b, func(b *testing.B, fs billy.Filesystem) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			for j := 0; j < 100; j++ {
+				if err := util.WriteFile(fs, fmt.Sprintf("tmp/file%d", j), nil, os.ModePerm); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StartTimer()
+
+			if err := util.RemoveAll(fs, "tmp"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkMixedParallel runs nine lookups for every file written, each
+// goroutine working in a directory of its own.
+func BenchmarkMixedParallel(b *testing.B) {
+	fs := newTree(b, 100000)
+
+	var workers uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		dir := fmt.Sprintf("dir%d", atomic.AddUint64(&workers, 1)%100)
+
+		var i int
+		for pb.Next() {
+			var err error
+			if i%10 == 0 {
+				err = util.WriteFile(fs, fmt.Sprintf("%s/new%d", dir, i), nil, 0644)
+			} else {
+				_, err = fs.Stat(fmt.Sprintf("%s/file%d", dir, i%1000))
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,20 @@
+package memfs
+
+// buffer holds the bytes of a file. Its methods are called with the lock of
+// the content held.
+type buffer interface {
+	// ReadAt reads from off, which is less than Len, returning io.EOF if
+	// len(p) bytes are not available.
+	ReadAt(p []byte, off int64) (int, error)
+	// WriteAt writes p at off, extending the buffer with zeros if off is
+	// past its end.
+	WriteAt(p []byte, off int64) error
+	Len() int64
+	// Truncate changes the size of the buffer, extending it with zeros.
+	Truncate(size int64) error
+	// Seal is called when a file opened for writing is closed.
+	Seal()
+	// Release is called when the file is removed from the filesystem. The
+	// buffer remains readable.
+	Release()
+}
@@ -0,0 +1,144 @@
+package memfs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// ChangeLogSize is the number of changes kept for Changes, unless changed
+// with WithChangeLog.
+const ChangeLogSize = 4096
+
+// Snapshot marks a point in the history of the changes of a Memory.
+type Snapshot uint64
+
+// Change is a path changed since a Snapshot, along with the operations made
+// on it, as reported by Watch.
+type Change struct {
+	Path string
+	Op   billy.EventOp
+}
+
+// changeLog keeps the last changes of a storage.
+type changeLog struct {
+	m sync.Mutex
+	// seq is the number of changes made, the last one being events[len-1].
+	seq uint64
+	// snapped is the seq of the last Snapshot taken.
+	snapped uint64
+	size    int
+	events  []billy.Event
+}
+
+func (l *changeLog) record(op billy.EventOp, name string) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	// The consecutive changes of a path, such as the writes of a copy, are
+	// merged unless a Snapshot was taken in between, Changes reporting the
+	// union of the operations anyway.
+	if n := len(l.events); n > 0 && l.seq > l.snapped && l.events[n-1].Name == name {
+		l.events[n-1].Op |= op
+		return
+	}
+
+	l.seq++
+	if l.size <= 0 {
+		return
+	}
+
+	if len(l.events) == 2*l.size {
+		// Drop the oldest half at once, instead of shifting every time.
+		l.events = append(l.events[:0], l.events[l.size:]...)
+	}
+	l.events = append(l.events, billy.Event{Name: name, Op: op})
+}
+
+// reset forgets the changes made so far, as after Close.
+func (l *changeLog) reset() {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.seq++
+	l.events = nil
+}
+
+func (l *changeLog) snapshot() Snapshot {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.snapped = l.seq
+	return Snapshot(l.seq)
+}
+
+func (l *changeLog) since(s Snapshot) []Change {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if uint64(s) == l.seq {
+		return nil
+	}
+
+	first := l.seq - uint64(len(l.events))
+	if uint64(s) < first || uint64(s) > l.seq {
+		return []Change{{Op: billy.EventOverflow}}
+	}
+
+	ops := make(map[string]billy.EventOp)
+	for _, e := range l.events[uint64(s)-first:] {
+		ops[e.Name] |= e.Op
+	}
+
+	changes := make([]Change, 0, len(ops))
+	for name, op := range ops {
+		changes = append(changes, Change{Path: name, Op: op})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// Snapshot returns a marker of the current state of the filesystem, for
+// Changes.
+func (fs *Memory) Snapshot() Snapshot {
+	return fs.s.changes.snapshot()
+}
+
+// Changes returns the paths changed since the Snapshot since, sorted, each
+// with the union of the operations made on it, such as billy.EventCreate
+// with billy.EventWrite for a file created then written. The paths are the
+// ones reported by Watch: the renames are reported as a billy.EventRename of
+// the old path and a billy.EventCreate of the new one, without listing the
+// entries of the directory renamed.
+//
+// At least the last ChangeLogSize changes are kept, the consecutive changes
+// of a path, such as the writes to a file, counting as one. A Snapshot older than
+// the changes kept, or taken before Close, gives a single Change of
+// billy.EventOverflow, the whole tree having to be considered changed.
+//
+// The changes are recorded as they are made, so that listing them takes a
+// time proportional to their number, not to the size of the tree.
+func (fs *Memory) Changes(since Snapshot) []Change {
+	return fs.s.changes.since(since)
+}
+
+// Unwrap returns the Memory under fs, such as the filesystem returned by New,
+// looking through the helpers exposing the filesystem they wrap with an
+// Underlying method. The paths of the Memory are the absolute paths of the
+// filesystem returned by New, whatever the root of fs.
+func Unwrap(fs billy.Basic) (*Memory, bool) {
+	for {
+		switch f := fs.(type) {
+		case *Memory:
+			return f, true
+		case interface{ Underlying() billy.Basic }:
+			fs = f.Underlying()
+		default:
+			return nil, false
+		}
+	}
+}
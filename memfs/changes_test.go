@@ -0,0 +1,118 @@
+package memfs
+
+import (
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type ChangesSuite struct{}
+
+var _ = Suite(&ChangesSuite{})
+
+func memory(c *C, fs billy.Filesystem) *Memory {
+	m, ok := Unwrap(fs)
+	c.Assert(ok, Equals, true)
+	return m
+}
+
+func abs(name string) string {
+	return filepath.Join(string(separator), name)
+}
+
+func (s *ChangesSuite) TestChanges(c *C) {
+	fs := New()
+	m := memory(c, fs)
+	c.Assert(util.WriteFile(fs, "keep", []byte("keep"), 0o644), IsNil)
+	c.Assert(util.WriteFile(fs, "old", []byte("old"), 0o644), IsNil)
+
+	snap := m.Snapshot()
+	c.Assert(m.Changes(snap), HasLen, 0)
+
+	c.Assert(util.WriteFile(fs, "dir/new", []byte("new"), 0o644), IsNil)
+	c.Assert(fs.Rename("old", "renamed"), IsNil)
+	c.Assert(fs.Remove("keep"), IsNil)
+
+	c.Assert(m.Changes(snap), DeepEquals, []Change{
+		{Path: abs("dir"), Op: billy.EventCreate},
+		{Path: abs("dir/new"), Op: billy.EventCreate | billy.EventWrite},
+		{Path: abs("keep"), Op: billy.EventRemove},
+		{Path: abs("old"), Op: billy.EventRename},
+		{Path: abs("renamed"), Op: billy.EventCreate},
+	})
+
+	next := m.Snapshot()
+	c.Assert(fs.(billy.Change).Chmod("renamed", 0o600), IsNil)
+	c.Assert(m.Changes(next), DeepEquals, []Change{
+		{Path: abs("renamed"), Op: billy.EventChmod},
+	})
+}
+
+func (s *ChangesSuite) TestChangesOverflow(c *C) {
+	fs := New(WithChangeLog(2))
+	m := memory(c, fs)
+	c.Assert(fs.MkdirAll("a", 0o755), IsNil)
+
+	snap := m.Snapshot()
+	c.Assert(fs.MkdirAll("b", 0o755), IsNil)
+	c.Assert(fs.MkdirAll("c", 0o755), IsNil)
+	c.Assert(m.Changes(snap), HasLen, 2)
+
+	for _, name := range []string{"d", "e", "f"} {
+		c.Assert(fs.MkdirAll(name, 0o755), IsNil)
+	}
+	c.Assert(m.Changes(snap), DeepEquals, []Change{{Op: billy.EventOverflow}})
+
+	next := m.Snapshot()
+	c.Assert(m.Close(), IsNil)
+	c.Assert(m.Changes(next), DeepEquals, []Change{{Op: billy.EventOverflow}})
+}
+
+func (s *ChangesSuite) TestChangesMergeWrites(c *C) {
+	fs := New(WithChangeLog(2))
+	m := memory(c, fs)
+	c.Assert(fs.MkdirAll("dir", 0o755), IsNil)
+
+	snap := m.Snapshot()
+	f, err := fs.Create("big")
+	c.Assert(err, IsNil)
+	for i := 0; i < 100; i++ {
+		_, err := f.Write([]byte("data"))
+		c.Assert(err, IsNil)
+	}
+
+	// The writes made since a later Snapshot are still reported.
+	next := m.Snapshot()
+	_, err = f.Write([]byte("data"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(m.Changes(snap), DeepEquals, []Change{
+		{Path: abs("big"), Op: billy.EventCreate | billy.EventWrite},
+	})
+	c.Assert(m.Changes(next), DeepEquals, []Change{
+		{Path: abs("big"), Op: billy.EventWrite},
+	})
+}
+
+func (s *ChangesSuite) TestUnwrap(c *C) {
+	fs, err := New().Chroot("dir")
+	c.Assert(err, IsNil)
+
+	m := memory(c, fs)
+	snap := m.Snapshot()
+	c.Assert(util.WriteFile(fs, "file", []byte("file"), 0o644), IsNil)
+	c.Assert(m.Changes(snap), DeepEquals, []Change{
+		{Path: abs(""), Op: billy.EventCreate},
+		{Path: abs("dir"), Op: billy.EventCreate},
+		{Path: abs("dir/file"), Op: billy.EventCreate | billy.EventWrite},
+	})
+
+	wrapped, ok := Unwrap(chroot.New(fs, "sub"))
+	c.Assert(ok, Equals, true)
+	c.Assert(wrapped, Equals, m)
+}
@@ -0,0 +1,269 @@
+package memfs
+
+import (
+	"crypto/sha256"
+	"io"
+	"sync"
+)
+
+// DefaultChunkSize is the chunk size of the stores created with a zero size.
+const DefaultChunkSize = 64 << 10
+
+// ChunkStore holds the contents of the files of the Memory filesystems
+// created WithDedup, cut in chunks of a fixed size. Identical chunks are
+// stored once, whether they belong to the same file, to different files or
+// to different filesystems, such as several revisions of the same tree.
+// The chunks referenced by no file are dropped. It is safe for concurrent
+// use.
+type ChunkStore struct {
+	size int
+
+	m      sync.Mutex
+	chunks map[[sha256.Size]byte]*chunk
+	bytes  int64
+}
+
+// NewChunkStore returns an empty store, cutting the files in chunks of size
+// bytes, or DefaultChunkSize if size is zero. Smaller chunks find more
+// duplicates, at the cost of more bookkeeping.
+func NewChunkStore(size int) *ChunkStore {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	return &ChunkStore{size: size, chunks: make(map[[sha256.Size]byte]*chunk)}
+}
+
+// ChunkStats describes the content of a ChunkStore.
+type ChunkStats struct {
+	// Chunks is the number of distinct chunks.
+	Chunks int
+	// Bytes is their total size.
+	Bytes int64
+}
+
+// Stats returns the number and total size of the chunks of s.
+func (s *ChunkStore) Stats() ChunkStats {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return ChunkStats{Chunks: len(s.chunks), Bytes: s.bytes}
+}
+
+// chunk is a part of a file. Shared chunks are immutable and referenced by
+// refs buffers; the other ones belong to a single buffer.
+type chunk struct {
+	b      []byte
+	sum    [sha256.Size]byte
+	shared bool
+	refs   int
+}
+
+// intern returns the shared chunk holding the bytes of c, c itself becoming
+// shared if there is none.
+func (s *ChunkStore) intern(c *chunk) *chunk {
+	sum := sha256.Sum256(c.b)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if e, ok := s.chunks[sum]; ok {
+		e.refs++
+		return e
+	}
+
+	if cap(c.b) > len(c.b) {
+		c.b = append([]byte(nil), c.b...)
+	}
+	c.sum, c.shared, c.refs = sum, true, 1
+	s.chunks[sum] = c
+	s.bytes += int64(len(c.b))
+	return c
+}
+
+func (s *ChunkStore) release(c *chunk) {
+	if !c.shared {
+		return
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if c.refs--; c.refs == 0 {
+		delete(s.chunks, c.sum)
+		s.bytes -= int64(len(c.b))
+	}
+}
+
+// chunkedBuffer is a buffer made of chunks of the same size, but the last
+// one, so that growing a file never copies more than a chunk. The chunks
+// written are private until sealed, when they are shared through the store,
+// if any.
+type chunkedBuffer struct {
+	store    *ChunkStore
+	size     int64
+	chunks   []*chunk
+	released bool
+}
+
+func (b *chunkedBuffer) chunkSize() int64 {
+	if b.store == nil {
+		return DefaultChunkSize
+	}
+
+	return int64(b.store.size)
+}
+
+func (b *chunkedBuffer) ReadAt(p []byte, off int64) (int, error) {
+	cs := b.chunkSize()
+
+	var n int
+	for n < len(p) && off < b.size {
+		c := b.chunks[off/cs]
+		m := copy(p[n:], c.b[off%cs:])
+		n += m
+		off += int64(m)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (b *chunkedBuffer) WriteAt(p []byte, off int64) error {
+	if end := off + int64(len(p)); end > b.size {
+		b.grow(end)
+	}
+
+	cs := b.chunkSize()
+	for n := 0; n < len(p); {
+		c := b.writable(int(off / cs))
+		m := copy(c.b[off%cs:], p[n:])
+		n += m
+		off += int64(m)
+	}
+
+	return nil
+}
+
+func (b *chunkedBuffer) Len() int64 {
+	return b.size
+}
+
+func (b *chunkedBuffer) Truncate(size int64) error {
+	if size >= b.size {
+		b.grow(size)
+		return nil
+	}
+
+	cs := b.chunkSize()
+	keep := int((size + cs - 1) / cs)
+	for _, c := range b.chunks[keep:] {
+		b.drop(c)
+	}
+	for i := keep; i < len(b.chunks); i++ {
+		b.chunks[i] = nil
+	}
+	b.chunks = b.chunks[:keep]
+	b.size = size
+
+	if keep > 0 {
+		if l := int(size - int64(keep-1)*cs); l < len(b.chunks[keep-1].b) {
+			c := b.writable(keep - 1)
+			c.b = c.b[:l]
+		}
+	}
+
+	return nil
+}
+
+// grow extends the buffer with zeros up to size.
+func (b *chunkedBuffer) grow(size int64) {
+	cs := b.chunkSize()
+	for b.size < size {
+		last := len(b.chunks) - 1
+		if last < 0 || int64(len(b.chunks[last].b)) == cs {
+			b.chunks = append(b.chunks, &chunk{})
+			last++
+		}
+
+		c := b.writable(last)
+		l := int64(len(c.b))
+		n := size - b.size
+		if l+n > cs {
+			n = cs - l
+		}
+
+		c.b = extend(c.b, int(l+n), int(cs))
+		b.size += n
+	}
+}
+
+// extend returns b grown to n bytes with zeros, allocating at most max
+// bytes.
+func extend(b []byte, n, max int) []byte {
+	l := len(b)
+	if n <= cap(b) {
+		b = b[:n]
+		for i := l; i < n; i++ {
+			b[i] = 0
+		}
+		return b
+	}
+
+	c := 2 * cap(b)
+	if c < n {
+		c = n
+	}
+	if c > max {
+		c = max
+	}
+
+	nb := make([]byte, n, c)
+	copy(nb, b)
+	return nb
+}
+
+// writable returns the chunk i, copied first if it is shared.
+func (b *chunkedBuffer) writable(i int) *chunk {
+	c := b.chunks[i]
+	if !c.shared {
+		return c
+	}
+
+	nc := &chunk{b: append([]byte(nil), c.b...)}
+	b.drop(c)
+	b.chunks[i] = nc
+	return nc
+}
+
+func (b *chunkedBuffer) drop(c *chunk) {
+	if !b.released && b.store != nil {
+		b.store.release(c)
+	}
+}
+
+func (b *chunkedBuffer) Seal() {
+	if b.released || b.store == nil {
+		return
+	}
+
+	for i, c := range b.chunks {
+		if !c.shared {
+			b.chunks[i] = b.store.intern(c)
+		}
+	}
+}
+
+func (b *chunkedBuffer) Release() {
+	if b.released {
+		return
+	}
+
+	for _, c := range b.chunks {
+		b.drop(c)
+	}
+	b.released = true
+}
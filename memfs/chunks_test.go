@@ -0,0 +1,98 @@
+package memfs
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type DedupSuite struct {
+	test.FilesystemSuite
+	store *ChunkStore
+}
+
+var _ = Suite(&DedupSuite{})
+
+func (s *DedupSuite) SetUpTest(c *C) {
+	s.store = NewChunkStore(4)
+	s.FilesystemSuite = test.NewFilesystemSuite(New(WithDedup(s.store)))
+}
+
+func (s *DedupSuite) TestShared(c *C) {
+	content := []byte("abcdabcdefgh12")
+	c.Assert(util.WriteFile(s.FS, "foo", content, 0o644), IsNil)
+
+	other := New(WithDedup(s.store))
+	c.Assert(util.WriteFile(other, "bar", content, 0o644), IsNil)
+
+	// "abcd", "efgh" and "12".
+	c.Assert(s.store.Stats(), Equals, ChunkStats{Chunks: 3, Bytes: 10})
+
+	c.Assert(s.FS.Remove("foo"), IsNil)
+	c.Assert(s.store.Stats(), Equals, ChunkStats{Chunks: 3, Bytes: 10})
+
+	c.Assert(other.Remove("bar"), IsNil)
+	c.Assert(s.store.Stats(), Equals, ChunkStats{})
+}
+
+func (s *DedupSuite) TestCopyOnWrite(c *C) {
+	content := []byte("abcdefghijkl")
+	c.Assert(util.WriteFile(s.FS, "foo", content, 0o644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "bar", content, 0o644), IsNil)
+
+	f, err := s.FS.OpenFile("bar", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Seek(3, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("XY"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(10), IsNil)
+	_, err = f.Seek(13, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("Z"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	b, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(b, DeepEquals, content)
+
+	b, err = util.ReadFile(s.FS, "bar")
+	c.Assert(err, IsNil)
+	c.Assert(b, DeepEquals, []byte("abcXYfghij\x00\x00\x00Z"))
+
+	// "abcd", "efgh", "ijkl", "abcX", "Yfgh", "ij\0\0" and "\0Z".
+	c.Assert(s.store.Stats().Chunks, Equals, 7)
+}
+
+func (s *DedupSuite) TestRemovedOpenFile(c *C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("abcdefgh"), 0o644), IsNil)
+
+	f, err := s.FS.OpenFile("foo", os.O_RDWR, 0)
+	c.Assert(err, IsNil)
+	c.Assert(s.FS.Remove("foo"), IsNil)
+	c.Assert(s.store.Stats(), Equals, ChunkStats{})
+
+	_, err = f.Write([]byte("X"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(s.store.Stats(), Equals, ChunkStats{})
+}
+
+func (s *DedupSuite) TestLarge(c *C) {
+	s.store = NewChunkStore(0)
+	fs := New(WithDedup(s.store))
+
+	content := bytes.Repeat([]byte("0123456789"), DefaultChunkSize/5+1)[:2*DefaultChunkSize]
+	c.Assert(util.WriteFile(fs, "foo", content, 0o644), IsNil)
+
+	b, err := util.ReadFile(fs, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(b, DeepEquals, content)
+	c.Assert(s.store.Stats(), Equals, ChunkStats{Chunks: 2, Bytes: 2 * DefaultChunkSize})
+}
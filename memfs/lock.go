@@ -0,0 +1,82 @@
+package memfs
+
+import "sync"
+
+// flock is the lock table of a content, emulating flock(2) between the files
+// opened on it, as if each was opened by a process of its own.
+type flock struct {
+	m      sync.Mutex
+	c      *sync.Cond
+	excl   *file
+	shared map[*file]bool
+}
+
+// lock acquires an exclusive or shared lock for f, converting the one it may
+// hold. It returns false if it would have to wait and wait is false.
+func (l *flock) lock(f *file, exclusive, wait bool) bool {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.c == nil {
+		l.c = sync.NewCond(&l.m)
+		l.shared = make(map[*file]bool)
+	}
+
+	for l.conflicts(f, exclusive) {
+		if !wait {
+			return false
+		}
+		l.c.Wait()
+	}
+
+	l.release(f)
+	if exclusive {
+		l.excl = f
+	} else {
+		l.shared[f] = true
+	}
+
+	return true
+}
+
+func (l *flock) conflicts(f *file, exclusive bool) bool {
+	if l.excl != nil && l.excl != f {
+		return true
+	}
+
+	if !exclusive {
+		return false
+	}
+
+	for s := range l.shared {
+		if s != f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unlock releases the lock of f, if any.
+func (l *flock) unlock(f *file) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.release(f) {
+		l.c.Broadcast()
+	}
+}
+
+func (l *flock) release(f *file) bool {
+	if l.excl == f {
+		l.excl = nil
+		return true
+	}
+
+	if l.shared[f] {
+		delete(l.shared, f)
+		return true
+	}
+
+	return false
+}
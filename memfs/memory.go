@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	iofs "io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-git/go-billy/v5"
@@ -18,16 +22,26 @@ import (
 
 const separator = filepath.Separator
 
-// Memory a very convenient filesystem based on memory files
+// Memory a very convenient filesystem based on memory files. It implements
+// util.VFS, as the filesystems returned by New do, so that the paths given
+// to util.SecureJoinVFS can be resolved against an in-memory tree.
 type Memory struct {
 	s *storage
 
-	tempCount int
+	tempDir   string
+	tempCount uint64
 }
 
-//New returns a new Memory filesystem.
-func New() billy.Filesystem {
-	fs := &Memory{s: newStorage()}
+var _ util.VFS = (*Memory)(nil)
+
+// New returns a new Memory filesystem.
+func New(opts ...Option) billy.Filesystem {
+	o := options{changeLog: ChangeLogSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	fs := &Memory{s: newStorage(o), tempDir: o.tempDir}
 	return chroot.New(fs, string(separator))
 }
 
@@ -43,17 +57,25 @@ func (fs *Memory) OpenFile(filename string, flag int, perm os.FileMode) (billy.F
 	f, has := fs.s.Get(filename)
 	if !has {
 		if !isCreate(flag) {
-			return nil, os.ErrNotExist
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
 		}
 
 		var err error
 		f, err = fs.s.New(filename, perm, flag)
+		if os.IsExist(err) || err == nil && f == nil {
+			// Created concurrently.
+			if isExclusive(flag) {
+				return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+			}
+			return fs.OpenFile(filename, flag, perm)
+		}
 		if err != nil {
-			return nil, err
+			return nil, &os.PathError{Op: "open", Path: filename, Err: underlying(err)}
 		}
 	} else {
-		if isExclusive(flag) {
-			return nil, os.ErrExist
+		// As with open(2), O_EXCL is only meaningful along with O_CREATE.
+		if isCreate(flag) && isExclusive(flag) {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
 		}
 
 		if target, isLink := fs.resolveLink(filename, f); isLink {
@@ -61,21 +83,22 @@ func (fs *Memory) OpenFile(filename string, flag int, perm os.FileMode) (billy.F
 		}
 	}
 
-	if f.mode.IsDir() {
-		return nil, fmt.Errorf("cannot open directory: %s", filename)
+	mode := f.loadMode()
+	if mode.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EISDIR}
 	}
 
-	return f.Duplicate(filename, perm, flag), nil
+	return f.Duplicate(filename, mode, flag), nil
 }
 
 var errNotLink = errors.New("not a link")
 
 func (fs *Memory) resolveLink(fullpath string, f *file) (target string, isLink bool) {
-	if !isSymlink(f.mode) {
+	if !isSymlink(f.loadMode()) {
 		return fullpath, false
 	}
 
-	target = string(f.content.bytes)
+	target = f.content.String()
 	if !isAbs(target) {
 		target = fs.Join(filepath.Dir(fullpath), target)
 	}
@@ -93,7 +116,7 @@ func isAbs(path string) bool {
 func (fs *Memory) Stat(filename string) (os.FileInfo, error) {
 	f, has := fs.s.Get(filename)
 	if !has {
-		return nil, os.ErrNotExist
+		return nil, &os.PathError{Op: "stat", Path: filename, Err: os.ErrNotExist}
 	}
 
 	fi, _ := f.Stat()
@@ -102,7 +125,7 @@ func (fs *Memory) Stat(filename string) (os.FileInfo, error) {
 	if target, isLink := fs.resolveLink(filename, f); isLink {
 		fi, err = fs.Stat(target)
 		if err != nil {
-			return nil, err
+			return nil, &os.PathError{Op: "stat", Path: filename, Err: underlying(err)}
 		}
 	}
 
@@ -116,10 +139,12 @@ func (fs *Memory) Stat(filename string) (os.FileInfo, error) {
 func (fs *Memory) Lstat(filename string) (os.FileInfo, error) {
 	f, has := fs.s.Get(filename)
 	if !has {
-		return nil, os.ErrNotExist
+		return nil, &os.PathError{Op: "lstat", Path: filename, Err: os.ErrNotExist}
 	}
 
-	return f.Stat()
+	fi, _ := f.Stat()
+	fi.(*fileInfo).name = filepath.Base(clean(filename))
+	return fi, nil
 }
 
 type ByName []os.FileInfo
@@ -136,8 +161,9 @@ func (fs *Memory) ReadDir(path string) ([]os.FileInfo, error) {
 	}
 
 	var entries []os.FileInfo
-	for _, f := range fs.s.Children(path) {
+	for name, f := range fs.s.Children(path) {
 		fi, _ := f.Stat()
+		fi.(*fileInfo).name = name
 		entries = append(entries, fi)
 	}
 
@@ -146,29 +172,228 @@ func (fs *Memory) ReadDir(path string) ([]os.FileInfo, error) {
 	return entries, nil
 }
 
+// OpenDir implements billy.DirIterator. The names of the entries are
+// sorted when the directory is opened, each entry being looked up as it is
+// returned, so that the ones removed since are skipped.
+func (fs *Memory) OpenDir(path string) (billy.DirIter, error) {
+	n, names := fs.s.Dir(path)
+	if n == nil {
+		return nil, &os.PathError{Op: "opendir", Path: path, Err: os.ErrNotExist}
+	}
+
+	// The root has no file until something is written.
+	if f := n.entry(); f != nil {
+		if target, isLink := fs.resolveLink(path, f); isLink {
+			return fs.OpenDir(target)
+		}
+		if !f.loadMode().IsDir() {
+			return nil, &os.PathError{Op: "opendir", Path: path, Err: syscall.ENOTDIR}
+		}
+	}
+
+	return &dirIter{n: n, names: names}, nil
+}
+
+// dirIter is the billy.DirIter of a Memory directory.
+type dirIter struct {
+	n     *node
+	names []string
+}
+
+func (it *dirIter) Next() (iofs.DirEntry, error) {
+	for len(it.names) > 0 {
+		name := it.names[0]
+		it.names = it.names[1:]
+
+		c := it.n.child(name)
+		if c == nil {
+			continue
+		}
+		f := c.entry()
+		if f == nil {
+			continue
+		}
+
+		fi, _ := f.Stat()
+		fi.(*fileInfo).name = name
+		return iofs.FileInfoToDirEntry(fi), nil
+	}
+
+	return nil, io.EOF
+}
+
+func (it *dirIter) Close() error {
+	it.names = nil
+	return nil
+}
+
 func (fs *Memory) MkdirAll(path string, perm os.FileMode) error {
-	_, err := fs.s.New(path, perm|os.ModeDir, 0)
-	return err
+	if _, err := fs.s.New(path, perm|os.ModeDir, 0); err != nil {
+		return &os.PathError{Op: "mkdir", Path: path, Err: underlying(err)}
+	}
+
+	return nil
 }
 
+// TempFile creates a temporary file in dir, or in the directory of
+// WithTempDir if dir is empty or the root.
 func (fs *Memory) TempFile(dir, prefix string) (billy.File, error) {
-	return util.TempFile(fs, dir, prefix)
+	return util.TempFile(fs, fs.tempIn(dir), prefix)
+}
+
+// CreateTemp implements billy.TempCreator. The random part of the names is
+// made of a counter and of the current time. The files are created in the
+// directory of WithTempDir if dir is empty or the root.
+func (fs *Memory) CreateTemp(dir, pattern string) (billy.File, error) {
+	dir = fs.tempIn(dir)
+	for {
+		name, err := fs.tempName("createtemp", dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
+
+// MkdirTemp implements billy.TempCreator, naming the directories as
+// CreateTemp does.
+func (fs *Memory) MkdirTemp(dir, pattern string) (string, error) {
+	dir = fs.tempIn(dir)
+	for {
+		name, err := fs.tempName("mkdirtemp", dir, pattern)
+		if err != nil {
+			return "", err
+		}
+
+		f, err := fs.s.New(name, 0700|os.ModeDir, 0)
+		if err == nil && f != nil {
+			return name, nil
+		}
+		if err != nil && !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// tempIn returns the directory where the temporary entries asked in dir are
+// created. The filesystem returned by New passes the root for an empty dir.
+func (fs *Memory) tempIn(dir string) string {
+	if fs.tempDir == "" {
+		return dir
+	}
+	if dir == "" || clean(dir) == string(separator) {
+		return fs.tempDir
+	}
+
+	return dir
+}
+
+// TempFiles returns the paths of the entries of the directory of
+// WithTempDir, sorted, such as the temporary files left behind. It returns
+// nil if the option isn't used.
+func (fs *Memory) TempFiles() []string {
+	if fs.tempDir == "" {
+		return nil
+	}
+
+	var names []string
+	for name := range fs.s.Children(fs.tempDir) {
+		names = append(names, fs.Join(fs.tempDir, name))
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// PurgeTemp removes the entries of the directory of WithTempDir, along with
+// their content. The files still open remain readable, as after Remove. It
+// does nothing if the option isn't used.
+func (fs *Memory) PurgeTemp() error {
+	for _, name := range fs.TempFiles() {
+		if err := fs.s.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *Memory) tempName(op, dir, pattern string) (string, error) {
+	if strings.ContainsAny(pattern, "/"+string(separator)) {
+		return "", &os.PathError{Op: op, Path: pattern, Err: errors.New("pattern contains path separator")}
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i != -1 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	n := atomic.AddUint64(&fs.tempCount, 1)
+	return fs.Join(dir, fmt.Sprintf("%s%d_%d%s", prefix, n, util.Now().UnixNano(), suffix)), nil
 }
 
 func (fs *Memory) getTempFilename(dir, prefix string) string {
-	fs.tempCount++
-	filename := fmt.Sprintf("%s_%d_%d", prefix, fs.tempCount, time.Now().UnixNano())
+	n := atomic.AddUint64(&fs.tempCount, 1)
+	filename := fmt.Sprintf("%s_%d_%d", prefix, n, util.Now().UnixNano())
 	return fs.Join(dir, filename)
 }
 
+// StatFS implements billy.StatFS. The space and the inodes being bounded by
+// the memory only, the volume is reported as being of math.MaxInt64 bytes and
+// inodes, of which the ones of the files are used.
+func (fs *Memory) StatFS(path string) (*billy.FSInfo, error) {
+	if _, ok := fs.s.Get(path); !ok {
+		return nil, &os.PathError{Op: "statfs", Path: path, Err: os.ErrNotExist}
+	}
+
+	bytes, files := fs.s.Usage()
+	return &billy.FSInfo{
+		Total:      math.MaxInt64,
+		Free:       math.MaxInt64 - bytes,
+		Used:       bytes,
+		Inodes:     math.MaxInt64,
+		FreeInodes: math.MaxInt64 - files,
+	}, nil
+}
+
+// Close implements billy.Closer, releasing the contents of all the files,
+// which removes the temporary files of WithSpill. The filesystem is left
+// empty, the files still open remaining readable.
+func (fs *Memory) Close() error {
+	fs.s.Close()
+	return nil
+}
+
+// SyncDir implements billy.DirSyncer; it is a no-op as the entries are never
+// persisted.
+func (fs *Memory) SyncDir(path string) error {
+	return nil
+}
+
 func (fs *Memory) Rename(from, to string) error {
 	return fs.s.Rename(from, to)
 }
 
+// Link implements billy.Linker. The names of a file share its content, its
+// mode and its Inode.
+func (fs *Memory) Link(oldname, newname string) error {
+	return fs.s.Link(oldname, newname)
+}
+
 func (fs *Memory) Remove(filename string) error {
 	return fs.s.Remove(filename)
 }
 
+// RemoveAll removes path and any children it contains, in a time
+// proportional to their number. It is used by util.RemoveAll.
+func (fs *Memory) RemoveAll(path string) error {
+	return fs.s.RemoveAll(path)
+}
+
 func (fs *Memory) Join(elem ...string) string {
 	return filepath.Join(elem...)
 }
@@ -176,7 +401,7 @@ func (fs *Memory) Join(elem ...string) string {
 func (fs *Memory) Symlink(target, link string) error {
 	_, err := fs.Stat(link)
 	if err == nil {
-		return os.ErrExist
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: os.ErrExist}
 	}
 
 	if !os.IsNotExist(err) {
@@ -189,18 +414,132 @@ func (fs *Memory) Symlink(target, link string) error {
 func (fs *Memory) Readlink(link string) (string, error) {
 	f, has := fs.s.Get(link)
 	if !has {
-		return "", os.ErrNotExist
+		return "", &os.PathError{Op: "readlink", Path: link, Err: os.ErrNotExist}
 	}
 
-	if !isSymlink(f.mode) {
-		return "", &os.PathError{
-			Op:   "readlink",
-			Path: link,
-			Err:  fmt.Errorf("not a symlink"),
-		}
+	if !isSymlink(f.loadMode()) {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: syscall.EINVAL}
+	}
+
+	return f.content.String(), nil
+}
+
+// follow returns the file at name, following the symbolic links.
+func (fs *Memory) follow(name string) (*file, bool) {
+	f, has := fs.s.Get(name)
+	if !has {
+		return nil, false
 	}
 
-	return string(f.content.bytes), nil
+	if target, isLink := fs.resolveLink(name, f); isLink {
+		return fs.follow(target)
+	}
+
+	return f, true
+}
+
+// Chmod implements billy.Change. The file type bits of mode are ignored.
+func (fs *Memory) Chmod(name string, mode os.FileMode) error {
+	f, has := fs.follow(name)
+	if !has {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+
+	atomic.StoreUint32((*uint32)(&f.mode), uint32(f.loadMode()&^os.ModePerm|mode&os.ModePerm))
+	fs.s.notify(billy.EventChmod, name)
+	return nil
+}
+
+// Lchown implements billy.Change. The ownership isn't tracked in memory, so
+// it only checks that name exists.
+func (fs *Memory) Lchown(name string, uid, gid int) error {
+	if _, has := fs.s.Get(name); !has {
+		return &os.PathError{Op: "lchown", Path: name, Err: os.ErrNotExist}
+	}
+
+	return nil
+}
+
+// Chown implements billy.Change. The ownership isn't tracked in memory, so
+// it only checks that name exists.
+func (fs *Memory) Chown(name string, uid, gid int) error {
+	if _, err := fs.Stat(name); err != nil {
+		return &os.PathError{Op: "chown", Path: name, Err: underlying(err)}
+	}
+
+	return nil
+}
+
+// Chtimes implements billy.Change. Only the modification time is kept.
+func (fs *Memory) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	f, has := fs.follow(name)
+	if !has {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrNotExist}
+	}
+
+	f.content.m.Lock()
+	f.content.modTime = mtime
+	f.content.m.Unlock()
+	fs.s.notify(billy.EventChmod, name)
+	return nil
+}
+
+// Getxattr implements billy.Xattrer.
+func (fs *Memory) Getxattr(name, attr string) ([]byte, error) {
+	f, has := fs.follow(name)
+	if !has {
+		return nil, &os.PathError{Op: "getxattr", Path: name, Err: os.ErrNotExist}
+	}
+
+	value, ok := f.content.Getxattr(attr)
+	if !ok {
+		return nil, &os.PathError{Op: "getxattr", Path: name, Err: billy.ErrNoXattr}
+	}
+
+	return value, nil
+}
+
+// Setxattr implements billy.Xattrer.
+func (fs *Memory) Setxattr(name, attr string, value []byte) error {
+	f, has := fs.follow(name)
+	if !has {
+		return &os.PathError{Op: "setxattr", Path: name, Err: os.ErrNotExist}
+	}
+
+	f.content.Setxattr(attr, value)
+	fs.s.notify(billy.EventChmod, name)
+	return nil
+}
+
+// Listxattr implements billy.Xattrer. The names are sorted.
+func (fs *Memory) Listxattr(name string) ([]string, error) {
+	f, has := fs.follow(name)
+	if !has {
+		return nil, &os.PathError{Op: "listxattr", Path: name, Err: os.ErrNotExist}
+	}
+
+	return f.content.Listxattr(), nil
+}
+
+// Removexattr implements billy.Xattrer.
+func (fs *Memory) Removexattr(name, attr string) error {
+	f, has := fs.follow(name)
+	if !has {
+		return &os.PathError{Op: "removexattr", Path: name, Err: os.ErrNotExist}
+	}
+
+	if !f.content.Removexattr(attr) {
+		return &os.PathError{Op: "removexattr", Path: name, Err: billy.ErrNoXattr}
+	}
+
+	fs.s.notify(billy.EventChmod, name)
+	return nil
+}
+
+// Watch implements billy.Watcher. The changes are reported as they are made,
+// the writes to a file being reported once per call to Write or Truncate.
+func (fs *Memory) Watch(path string) (<-chan billy.Event, func()) {
+	return fs.s.hub.Watch(clean(path))
 }
 
 // Capabilities implements the Capable interface.
@@ -209,7 +548,14 @@ func (fs *Memory) Capabilities() billy.Capability {
 		billy.ReadCapability |
 		billy.ReadAndWriteCapability |
 		billy.SeekCapability |
-		billy.TruncateCapability
+		billy.TruncateCapability |
+		billy.LockCapability |
+		billy.XattrCapability |
+		billy.SymlinkCapability |
+		billy.HardlinkCapability |
+		billy.ChangeCapability |
+		billy.SyncCapability |
+		billy.ConcurrentCapability
 }
 
 type file struct {
@@ -218,6 +564,7 @@ type file struct {
 	position int64
 	flag     int
 	mode     os.FileMode
+	s        *storage
 
 	isClosed bool
 }
@@ -226,6 +573,11 @@ func (f *file) Name() string {
 	return f.name
 }
 
+// loadMode returns the mode of the file, which Chmod changes concurrently.
+func (f *file) loadMode() os.FileMode {
+	return os.FileMode(atomic.LoadUint32((*uint32)(&f.mode)))
+}
+
 func (f *file) Read(b []byte) (int, error) {
 	n, err := f.ReadAt(b, f.position)
 	f.position += int64(n)
@@ -239,11 +591,11 @@ func (f *file) Read(b []byte) (int, error) {
 
 func (f *file) ReadAt(b []byte, off int64) (int, error) {
 	if f.isClosed {
-		return 0, os.ErrClosed
+		return 0, f.closed("read")
 	}
 
 	if !isReadAndWrite(f.flag) && !isReadOnly(f.flag) {
-		return 0, errors.New("read not supported")
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
 	}
 
 	n, err := f.content.ReadAt(b, off)
@@ -251,9 +603,21 @@ func (f *file) ReadAt(b []byte, off int64) (int, error) {
 	return n, err
 }
 
+// Mmap implements billy.Mmapper. The content being kept in chunks, the slice
+// returned is a copy of it and releasing it is a no-op.
+func (f *file) Mmap() ([]byte, func() error, error) {
+	b := make([]byte, f.content.Len())
+	n, err := f.ReadAt(b, 0)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	return b[:n], func() error { return nil }, nil
+}
+
 func (f *file) Seek(offset int64, whence int) (int64, error) {
 	if f.isClosed {
-		return 0, os.ErrClosed
+		return 0, f.closed("seek")
 	}
 
 	switch whence {
@@ -262,7 +626,18 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 	case io.SeekStart:
 		f.position = offset
 	case io.SeekEnd:
-		f.position = int64(f.content.Len()) + offset
+		f.position = f.content.Len() + offset
+	case billy.SeekData, billy.SeekHole:
+		// The content has no holes but the one at its end.
+		size := f.content.Len()
+		if offset < 0 || offset >= size {
+			return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.ENXIO}
+		}
+
+		f.position = offset
+		if whence == billy.SeekHole {
+			f.position = size
+		}
 	}
 
 	return f.position, nil
@@ -270,79 +645,200 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 
 func (f *file) Write(p []byte) (int, error) {
 	if f.isClosed {
-		return 0, os.ErrClosed
+		return 0, f.closed("write")
 	}
 
 	if !isReadAndWrite(f.flag) && !isWriteOnly(f.flag) {
-		return 0, errors.New("write not supported")
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
 	}
 
-	n, err := f.content.WriteAt(p, f.position)
-	f.position += int64(n)
+	var n int
+	var err error
+	if isAppend(f.flag) {
+		// Every write goes to the end of the content, even when it was
+		// extended through another descriptor since the last one.
+		n, f.position, err = f.content.Append(p)
+	} else {
+		n, err = f.content.WriteAt(p, f.position)
+		f.position += int64(n)
+	}
+	if n > 0 {
+		f.notify(billy.EventWrite)
+	}
 
 	return n, err
 }
 
 func (f *file) Close() error {
 	if f.isClosed {
-		return os.ErrClosed
+		return f.closed("close")
 	}
 
 	f.isClosed = true
+	if isReadAndWrite(f.flag) || isWriteOnly(f.flag) {
+		f.content.seal()
+	}
+	f.content.locks.unlock(f)
+
 	return nil
 }
 
 func (f *file) Truncate(size int64) error {
-	if size < int64(len(f.content.bytes)) {
-		f.content.bytes = f.content.bytes[:size]
-	} else if more := int(size) - len(f.content.bytes); more > 0 {
-		f.content.bytes = append(f.content.bytes, make([]byte, more)...)
+	if f.isClosed {
+		return f.closed("truncate")
+	}
+
+	if !isReadAndWrite(f.flag) && !isWriteOnly(f.flag) {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EBADF}
+	}
+
+	if err := f.content.Truncate(size); err != nil {
+		return err
+	}
+	f.notify(billy.EventWrite)
+
+	return nil
+}
+
+// Allocate implements billy.Allocator. The chunks being allocated as they are
+// written, the content is only extended to off+length.
+func (f *file) Allocate(off, length int64) error {
+	if f.isClosed {
+		return f.closed("allocate")
+	}
+
+	if off < 0 || length <= 0 {
+		return &os.PathError{Op: "allocate", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	if !isReadAndWrite(f.flag) && !isWriteOnly(f.flag) {
+		return &os.PathError{Op: "allocate", Path: f.name, Err: syscall.EBADF}
 	}
 
+	if err := f.content.Extend(off + length); err != nil {
+		return err
+	}
+	f.notify(billy.EventWrite)
+
 	return nil
 }
 
+// closed returns the error of the operation op on the closed file.
+func (f *file) closed(op string) error {
+	return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+}
+
+func (f *file) notify(op billy.EventOp) {
+	if f.s != nil {
+		f.s.notify(op, f.name)
+	}
+}
+
 func (f *file) Duplicate(filename string, mode os.FileMode, flag int) billy.File {
 	new := &file{
 		name:    filename,
 		content: f.content,
 		mode:    mode,
 		flag:    flag,
-	}
-
-	if isAppend(flag) {
-		new.position = int64(new.content.Len())
+		s:       f.s,
 	}
 
 	if isTruncate(flag) {
-		new.content.Truncate()
+		if new.content.Len() > 0 {
+			new.notify(billy.EventWrite)
+		}
+		_ = new.content.Truncate(0)
 	}
 
 	return new
 }
 
+// Stat implements billy.FileStater.
 func (f *file) Stat() (os.FileInfo, error) {
 	return &fileInfo{
-		name: f.Name(),
-		mode: f.mode,
-		size: f.content.Len(),
+		name:    filepath.Base(f.Name()),
+		mode:    f.loadMode(),
+		size:    int(f.content.Len()),
+		modTime: f.content.ModTime(),
+		inode: Inode{
+			Dev:   f.content.dev,
+			Ino:   f.content.ino,
+			Nlink: atomic.LoadUint64(&f.content.nlink),
+		},
 	}, nil
 }
 
-// Lock is a no-op in memfs.
+// Sync implements billy.Syncer; it is a no-op as the content is never
+// persisted.
+func (f *file) Sync() error {
+	return nil
+}
+
+// Lock locks the file exclusively, waiting for the locks held by the other
+// files opened at the same path to be released, as if each was opened by a
+// process of its own. Closing the file releases its lock.
 func (f *file) Lock() error {
+	if f.isClosed {
+		return f.closed("lock")
+	}
+
+	f.content.locks.lock(f, true, true)
+	return nil
+}
+
+// RLock implements billy.RWLocker, the shared counterpart of Lock.
+func (f *file) RLock() error {
+	if f.isClosed {
+		return f.closed("lock")
+	}
+
+	f.content.locks.lock(f, false, true)
 	return nil
 }
 
-// Unlock is a no-op in memfs.
+// TryLock implements billy.RWLocker.
+func (f *file) TryLock() (bool, error) {
+	if f.isClosed {
+		return false, f.closed("lock")
+	}
+
+	return f.content.locks.lock(f, true, false), nil
+}
+
+// TryRLock implements billy.RWLocker.
+func (f *file) TryRLock() (bool, error) {
+	if f.isClosed {
+		return false, f.closed("lock")
+	}
+
+	return f.content.locks.lock(f, false, false), nil
+}
+
+// Unlock releases the lock held by the file, if any.
 func (f *file) Unlock() error {
+	f.content.locks.unlock(f)
 	return nil
 }
 
+// Inode is the value returned by the Sys method of the os.FileInfo of the
+// files, identifying them as the Dev and Ino fields of syscall.Stat_t do:
+// two names refer to the same file if their Dev and Ino are equal. A file
+// keeps its Ino when renamed, and shares it with its hard links.
+type Inode struct {
+	// Dev identifies the filesystem, being unique to each Memory.
+	Dev uint64
+	// Ino identifies the file within the filesystem.
+	Ino uint64
+	// Nlink is the number of names of the file.
+	Nlink uint64
+}
+
 type fileInfo struct {
-	name string
-	size int
-	mode os.FileMode
+	name    string
+	size    int
+	mode    os.FileMode
+	modTime time.Time
+	inode   Inode
 }
 
 func (fi *fileInfo) Name() string {
@@ -357,24 +853,27 @@ func (fi *fileInfo) Mode() os.FileMode {
 	return fi.mode
 }
 
-func (*fileInfo) ModTime() time.Time {
-	return time.Now()
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
 }
 
 func (fi *fileInfo) IsDir() bool {
 	return fi.mode.IsDir()
 }
 
-func (*fileInfo) Sys() interface{} {
-	return nil
+// Sys returns the *Inode of the file.
+func (fi *fileInfo) Sys() interface{} {
+	return &fi.inode
 }
 
-func (c *content) Truncate() {
-	c.bytes = make([]byte, 0)
-}
+// underlying returns the error wrapped by err, if it is an *os.PathError, so
+// that it can be reported for another path or operation.
+func underlying(err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err
+	}
 
-func (c *content) Len() int {
-	return len(c.bytes)
+	return err
 }
 
 func isCreate(flag int) bool {
@@ -398,7 +897,7 @@ func isReadAndWrite(flag int) bool {
 }
 
 func isReadOnly(flag int) bool {
-	return flag == os.O_RDONLY
+	return flag&(os.O_WRONLY|os.O_RDWR) == 0
 }
 
 func isWriteOnly(flag int) bool {
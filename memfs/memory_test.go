@@ -1,13 +1,19 @@
 package memfs
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
 
 	. "gopkg.in/check.v1"
 )
@@ -30,9 +36,346 @@ func (s *MemorySuite) TestCapabilities(c *C) {
 	c.Assert(ok, Equals, true)
 
 	caps := billy.Capabilities(s.FS)
-	c.Assert(caps, Equals, billy.DefaultCapabilities&^billy.LockCapability)
+	c.Assert(caps, Equals, billy.DefaultCapabilities|billy.XattrCapability|
+		billy.SymlinkCapability|billy.HardlinkCapability|billy.ChangeCapability|billy.SyncCapability|
+		billy.ConcurrentCapability)
+	c.Assert(billy.CapabilityCheck(s.FS, billy.LockCapability), Equals, true)
 }
 
+func (s *MemorySuite) TestSync(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.(billy.Syncer).Sync(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fs := &Memory{s: newStorage(options{})}
+	c.Assert(fs.SyncDir("/"), IsNil)
+}
+
+func (s *MemorySuite) TestFileStat(c *C) {
+	f, err := s.FS.OpenFile("dir/foo", os.O_RDWR|os.O_CREATE, 0640)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(s.FS.Rename("dir/foo", "bar"), IsNil)
+
+	fi, err := f.(billy.FileStater).Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "foo")
+	c.Assert(fi.Size(), Equals, int64(3))
+	c.Assert(fi.Mode(), Equals, os.FileMode(0640))
+	c.Assert(fi.IsDir(), Equals, false)
+
+	r, err := s.FS.Open("bar")
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	fi, err = r.(billy.FileStater).Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.FileMode(0640))
+}
+
+func (s *MemorySuite) TestChange(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/foo", []byte("foo"), 0644), IsNil)
+	c.Assert(s.FS.Symlink("foo", "dir/link"), IsNil)
+
+	ch := s.FS.(billy.Change)
+	c.Assert(ch.Chmod("dir/link", 0600), IsNil)
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Assert(ch.Chtimes("dir/link", mtime, mtime), IsNil)
+
+	fi, err := s.FS.Stat("dir/foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.FileMode(0600))
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+	c.Assert(ch.Chmod("dir", 0700), IsNil)
+	fi, err = s.FS.Stat("dir")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode(), Equals, os.ModeDir|0700)
+
+	c.Assert(ch.Chown("dir/foo", 1, 1), IsNil)
+	c.Assert(ch.Lchown("dir/link", 1, 1), IsNil)
+	c.Assert(os.IsNotExist(ch.Chmod("missing", 0600)), Equals, true)
+	c.Assert(os.IsNotExist(ch.Chown("missing", 1, 1)), Equals, true)
+	c.Assert(os.IsNotExist(ch.Chtimes("missing", mtime, mtime)), Equals, true)
+}
+
+func (s *MemorySuite) TestXattr(c *C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), IsNil)
+	c.Assert(s.FS.Symlink("foo", "link"), IsNil)
+
+	x := s.FS.(billy.Xattrer)
+	c.Assert(x.Setxattr("link", "user.b", []byte("b")), IsNil)
+	c.Assert(x.Setxattr("foo", "user.a", []byte("a")), IsNil)
+
+	v, err := x.Getxattr("foo", "user.b")
+	c.Assert(err, IsNil)
+	c.Assert(string(v), Equals, "b")
+
+	attrs, err := x.Listxattr("link")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, DeepEquals, []string{"user.a", "user.b"})
+
+	c.Assert(s.FS.Rename("foo", "bar"), IsNil)
+	c.Assert(x.Removexattr("bar", "user.a"), IsNil)
+	c.Assert(errors.Is(x.Removexattr("bar", "user.a"), billy.ErrNoXattr), Equals, true)
+
+	_, err = x.Getxattr("bar", "user.a")
+	c.Assert(errors.Is(err, billy.ErrNoXattr), Equals, true)
+
+	_, err = x.Getxattr("missing", "user.a")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MemorySuite) TestRenameKeepsSiblings(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/foo", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "dirty", []byte("dirty"), 0644), IsNil)
+
+	c.Assert(s.FS.Rename("dir", "new"), IsNil)
+
+	b, err := util.ReadFile(s.FS, "dirty")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "dirty")
+	_, err = s.FS.Stat("new/foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *MemorySuite) TestRenameNested(c *C) {
+	for i := 0; i < 20; i++ {
+		from, to := fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+		c.Assert(util.WriteFile(s.FS, from+"/sub/deep/foo", []byte("foo"), 0644), IsNil)
+		c.Assert(s.FS.Rename(from, to), IsNil)
+
+		b, err := util.ReadFile(s.FS, to+"/sub/deep/foo")
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, "foo")
+	}
+}
+
+func (s *MemorySuite) TestRenameIntoItself(c *C) {
+	c.Assert(s.FS.MkdirAll("dir/sub", 0755), IsNil)
+
+	err := s.FS.Rename("dir", "dir/sub/dir")
+	c.Assert(err, NotNil)
+	_, err = s.FS.Stat("dir/sub")
+	c.Assert(err, IsNil)
+}
+
+func (s *MemorySuite) TestRemoveAll(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/sub/foo", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "dir/bar", []byte("bar"), 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "dirty", []byte("dirty"), 0644), IsNil)
+
+	events, stop := s.FS.(billy.Watcher).Watch("dir")
+	defer stop()
+
+	c.Assert(util.RemoveAll(s.FS, "dir"), IsNil)
+	c.Assert(util.RemoveAll(s.FS, "missing"), IsNil)
+
+	_, err := s.FS.Stat("dir")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = s.FS.Stat("dirty")
+	c.Assert(err, IsNil)
+
+	removed := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		e := <-events
+		c.Assert(e.Op, Equals, billy.EventRemove)
+		removed[filepath.ToSlash(e.Name)] = true
+	}
+	c.Assert(removed, DeepEquals, map[string]bool{
+		"dir": true, "dir/sub": true, "dir/sub/foo": true, "dir/bar": true,
+	})
+}
+
+func (s *MemorySuite) TestConcurrent(c *C) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				dir := fmt.Sprintf("dir%d/sub%d", i%2, j%5)
+				name := fmt.Sprintf("%s/file%d-%d", dir, i, j)
+				if err := util.WriteFile(s.FS, name, []byte(name), 0644); err != nil {
+					errs <- err
+					return
+				}
+
+				if _, err := s.FS.ReadDir(dir); err != nil {
+					errs <- err
+					return
+				}
+
+				b, err := util.ReadFile(s.FS, name)
+				if err == nil && string(b) != name {
+					err = fmt.Errorf("unexpected content %q", b)
+				}
+				if err == nil {
+					err = s.FS.Remove(name)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		c.Error(err)
+	}
+}
+
+func (s *MemorySuite) TestLargeFile(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+
+	block := make([]byte, 1000)
+	for i := 0; i < 3*DefaultChunkSize/len(block); i++ {
+		for j := range block {
+			block[j] = byte(i)
+		}
+		_, err = f.Write(block)
+		c.Assert(err, IsNil)
+	}
+
+	size := int64(DefaultChunkSize + 10)
+	c.Assert(f.Truncate(size), IsNil)
+	_, err = f.Seek(size+5, io.SeekStart)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte{0xff})
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	b, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(b, HasLen, int(size)+6)
+	for i, v := range b[:size] {
+		if v != byte(i/len(block)) {
+			c.Fatalf("unexpected byte %d at %d", v, i)
+		}
+	}
+	c.Assert(b[size:], DeepEquals, []byte{0, 0, 0, 0, 0, 0xff})
+}
+
+func (s *MemorySuite) TestMmap(c *C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), IsNil)
+
+	f, err := s.FS.Open("foo")
+	c.Assert(err, IsNil)
+
+	b, release, err := f.(billy.Mmapper).Mmap()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+	c.Assert(release(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = s.FS.OpenFile("foo", os.O_WRONLY, 0)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	_, _, err = f.(billy.Mmapper).Mmap()
+	c.Assert(err, NotNil)
+}
+func (s *MemorySuite) TestAllocate(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	c.Assert(f.(billy.Allocator).Allocate(2, 8), IsNil)
+	c.Assert(f.(billy.Allocator).Allocate(0, 1), IsNil)
+	c.Assert(f.(billy.Allocator).Allocate(0, 0), NotNil)
+
+	c.Assert(f.Close(), IsNil)
+	b, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo\x00\x00\x00\x00\x00\x00\x00")
+}
+
+func (s *MemorySuite) TestSeekHole(c *C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), IsNil)
+
+	f, err := s.FS.Open("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	n, err := f.Seek(1, billy.SeekData)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(1))
+
+	n, err = f.Seek(0, billy.SeekHole)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(3))
+
+	_, err = f.Seek(3, billy.SeekHole)
+	c.Assert(errors.Is(err, syscall.ENXIO), Equals, true)
+}
+func (s *MemorySuite) TestStatFS(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/foo", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "bar", []byte("bar"), 0644), IsNil)
+
+	info, err := s.FS.(billy.StatFS).StatFS("dir")
+	c.Assert(err, IsNil)
+	c.Assert(info.Used, Equals, uint64(6))
+	c.Assert(info.Free, Equals, info.Total-6)
+	// The root, dir and both files.
+	c.Assert(info.FreeInodes, Equals, info.Inodes-4)
+
+	_, err = s.FS.(billy.StatFS).StatFS("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+func (s *MemorySuite) TestRWLock(c *C) {
+	c.Assert(util.WriteFile(s.FS, "foo", nil, 0644), IsNil)
+
+	f1, err := s.FS.Open("foo")
+	c.Assert(err, IsNil)
+	defer f1.Close()
+	f2, err := s.FS.Open("foo")
+	c.Assert(err, IsNil)
+	l1, l2 := f1.(billy.RWLocker), f2.(billy.RWLocker)
+
+	c.Assert(f1.Lock(), IsNil)
+	ok, err := l2.TryRLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+
+	locked := make(chan struct{})
+	go func() {
+		c.Check(l2.RLock(), IsNil)
+		close(locked)
+	}()
+	c.Assert(f1.Unlock(), IsNil)
+	<-locked
+
+	ok, err = l1.TryRLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	ok, err = l1.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+
+	// Closing f2 releases its lock.
+	c.Assert(f2.Close(), IsNil)
+	ok, err = l1.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+}
+
+
+
+
 func (s *MemorySuite) TestNegativeOffsets(c *C) {
 	f, err := s.FS.Create("negative")
 	c.Assert(err, IsNil)
@@ -58,7 +401,83 @@ func (s *MemorySuite) TestExclusive(c *C) {
 	c.Assert(err, IsNil)
 
 	_, err = s.FS.OpenFile("exclusive", os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
-	c.Assert(err, ErrorMatches, os.ErrExist.Error())
+	c.Assert(os.IsExist(err), Equals, true)
+}
+
+func (s *MemorySuite) TestInode(c *C) {
+	inode := func(name string) Inode {
+		fi, err := s.FS.Lstat(name)
+		c.Assert(err, IsNil)
+		return *fi.Sys().(*Inode)
+	}
+
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(s.FS, "bar", []byte("bar"), 0644), IsNil)
+
+	foo := inode("foo")
+	c.Assert(foo.Nlink, Equals, uint64(1))
+	c.Assert(inode("bar").Ino, Not(Equals), foo.Ino)
+	c.Assert(inode("bar").Dev, Equals, foo.Dev)
+
+	c.Assert(s.FS.Rename("foo", "dir/qux"), IsNil)
+	c.Assert(inode("dir/qux"), Equals, foo)
+
+	other := New()
+	c.Assert(util.WriteFile(other, "foo", nil, 0644), IsNil)
+	fi, err := other.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Sys().(*Inode).Dev, Not(Equals), foo.Dev)
+}
+
+func (s *MemorySuite) TestLink(c *C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), IsNil)
+	c.Assert(s.FS.(billy.Linker).Link("foo", "dir/bar"), IsNil)
+
+	foo, err := s.FS.Stat("foo")
+	c.Assert(err, IsNil)
+	bar, err := s.FS.Stat("dir/bar")
+	c.Assert(err, IsNil)
+	c.Assert(bar.Sys(), DeepEquals, foo.Sys())
+	c.Assert(foo.Sys().(*Inode).Nlink, Equals, uint64(2))
+
+	c.Assert(util.WriteFile(s.FS, "dir/bar", []byte("bar"), 0644), IsNil)
+	c.Assert(s.FS.(billy.Change).Chmod("foo", 0600), IsNil)
+	c.Assert(s.FS.Remove("foo"), IsNil)
+
+	content, err := util.ReadFile(s.FS, "dir/bar")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "bar")
+
+	bar, err = s.FS.Stat("dir/bar")
+	c.Assert(err, IsNil)
+	c.Assert(bar.Mode(), Equals, os.FileMode(0600))
+	c.Assert(bar.Sys().(*Inode).Nlink, Equals, uint64(1))
+
+	err = s.FS.(billy.Linker).Link("dir/bar", "dir/bar")
+	c.Assert(os.IsExist(err), Equals, true)
+	err = s.FS.(billy.Linker).Link("dir", "baz")
+	c.Assert(errors.Is(err, syscall.EPERM), Equals, true)
+}
+
+func (s *MemorySuite) TestAppendInterleaved(c *C) {
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0666)
+	c.Assert(err, IsNil)
+
+	f1, err := s.FS.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	f2, err := s.FS.OpenFile("foo", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+
+	for i, f := range []billy.File{f1, f2, f1} {
+		_, err = fmt.Fprint(f, i)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(f1.Close(), IsNil)
+	c.Assert(f2.Close(), IsNil)
+
+	content, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo012")
 }
 
 func (s *MemorySuite) TestOrder(c *C) {
@@ -84,3 +503,124 @@ func (s *MemorySuite) TestOrder(c *C) {
 		}
 	}
 }
+
+func (s *MemorySuite) TestVFS(c *C) {
+	fs := New()
+	c.Assert(fs.MkdirAll(filepath.FromSlash("/a/b"), 0o755), IsNil)
+	c.Assert(fs.Symlink("b", filepath.FromSlash("/a/link")), IsNil)
+
+	vfs := util.NewVFS(fs)
+	c.Assert(vfs, Equals, fs)
+
+	got, err := util.SecureJoinVFS(filepath.FromSlash("/a"), filepath.FromSlash("link/x"), vfs)
+	c.Assert(err, IsNil)
+	c.Assert(got, Equals, filepath.FromSlash("/a/b/x"))
+
+	infos, err := vfs.ReadDir(filepath.FromSlash("/a"))
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].Name(), Equals, "b")
+	c.Assert(infos[1].Name(), Equals, "link")
+}
+
+func (s *MemorySuite) TestTempDir(c *C) {
+	fs := New(WithTempDir("tmp"))
+	m := memory(c, fs)
+
+	f, err := fs.TempFile("", "foo")
+	c.Assert(err, IsNil)
+	c.Assert(filepath.Dir(f.Name()), Equals, "tmp")
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	d, err := fs.(billy.TempCreator).MkdirTemp("", "dir")
+	c.Assert(err, IsNil)
+	c.Assert(filepath.Dir(d), Equals, "tmp")
+
+	// The temporary files asked elsewhere aren't confined.
+	kept, err := fs.TempFile("dir", "kept")
+	c.Assert(err, IsNil)
+	c.Assert(kept.Close(), IsNil)
+
+	c.Assert(m.TempFiles(), DeepEquals, []string{abs(d), abs(f.Name())})
+
+	c.Assert(m.PurgeTemp(), IsNil)
+	c.Assert(m.TempFiles(), HasLen, 0)
+	_, err = fs.Stat(kept.Name())
+	c.Assert(err, IsNil)
+
+	// The files still open remain readable.
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, IsNil)
+	data, err := io.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo")
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(memory(c, New()).TempFiles(), IsNil)
+}
+
+func (s *MemorySuite) TestRenameNoReplace(c *C) {
+	fs := New(WithRenameNoReplace())
+	c.Assert(util.WriteFile(fs, "foo", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "bar", []byte("bar"), 0644), IsNil)
+	c.Assert(fs.MkdirAll("empty", 0755), IsNil)
+
+	err := fs.Rename("foo", "bar")
+	c.Assert(os.IsExist(err), Equals, true, Commentf("error: %v", err))
+	c.Assert(fs.MkdirAll("dir", 0755), IsNil)
+	err = fs.Rename("dir", "empty")
+	c.Assert(os.IsExist(err), Equals, true, Commentf("error: %v", err))
+
+	c.Assert(fs.Rename("foo", "dir/foo"), IsNil)
+	data, err := util.ReadFile(fs, "dir/foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo")
+}
+
+func (s *MemorySuite) TestOpenDir(c *C) {
+	fs := New()
+
+	d, err := billy.OpenDir(fs, "/")
+	c.Assert(err, IsNil)
+	_, err = d.Next()
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(d.Close(), IsNil)
+
+	for _, name := range []string{"dir/c", "dir/a", "dir/b", "dir/sub/d"} {
+		c.Assert(util.WriteFile(fs, name, []byte(name), 0644), IsNil)
+	}
+	c.Assert(fs.Symlink("dir", "link"), IsNil)
+
+	d, err = fs.(billy.DirIterator).OpenDir("link")
+	c.Assert(err, IsNil)
+	defer d.Close()
+
+	e, err := d.Next()
+	c.Assert(err, IsNil)
+	c.Assert(e.Name(), Equals, "a")
+
+	// The entries removed once the directory is open are skipped.
+	c.Assert(fs.Remove("dir/b"), IsNil)
+
+	e, err = d.Next()
+	c.Assert(err, IsNil)
+	c.Assert(e.Name(), Equals, "c")
+	fi, err := e.Info()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(len("dir/c")))
+
+	e, err = d.Next()
+	c.Assert(err, IsNil)
+	c.Assert(e.Name(), Equals, "sub")
+	c.Assert(e.IsDir(), Equals, true)
+
+	_, err = d.Next()
+	c.Assert(err, Equals, io.EOF)
+
+	_, err = fs.(billy.DirIterator).OpenDir("dir/a")
+	c.Assert(errors.Is(err, syscall.ENOTDIR), Equals, true)
+
+	_, err = fs.(billy.DirIterator).OpenDir("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
@@ -0,0 +1,84 @@
+package memfs
+
+import "path/filepath"
+
+// Option configures a Memory filesystem.
+type Option func(*options)
+
+type options struct {
+	chunks *ChunkStore
+
+	spillDir       string
+	spillThreshold int64
+	spill          bool
+
+	changeLog int
+
+	tempDir string
+
+	noReplace bool
+}
+
+// WithDedup stores the content of the files as chunks of store, sharing the
+// identical chunks between the files of all the filesystems using store.
+// The chunks written to a file are shared once it is closed.
+func WithDedup(store *ChunkStore) Option {
+	return func(o *options) {
+		o.chunks = store
+	}
+}
+
+// WithSpill moves the content of the files growing past threshold bytes to
+// temporary files of dir, or of the default directory for temporary files if
+// dir is empty, keeping the smaller files in memory. The temporary files are
+// removed with the files of the filesystem.
+func WithSpill(dir string, threshold int64) Option {
+	return func(o *options) {
+		o.spillDir, o.spillThreshold, o.spill = dir, threshold, true
+	}
+}
+
+// WithChangeLog keeps at least the last size changes for Memory.Changes, instead of
+// ChangeLogSize. Zero or less disables the log, Changes reporting an
+// overflow for any Snapshot but the current one.
+func WithChangeLog(size int) Option {
+	return func(o *options) {
+		o.changeLog = size
+	}
+}
+
+// WithTempDir confines the temporary files and directories created without a
+// directory, or in the root, to dir, created on demand. They can then be
+// listed with Memory.TempFiles and removed with Memory.PurgeTemp, such as
+// the ones left behind by crashed writers in a long-lived filesystem.
+func WithTempDir(dir string) Option {
+	return func(o *options) {
+		o.tempDir = filepath.Join(string(separator), dir)
+	}
+}
+
+// WithRenameNoReplace makes Rename fail with an error satisfying os.IsExist
+// when the target exists, instead of replacing it, as renameat2(2) does with
+// RENAME_NOREPLACE. The check and the rename are atomic.
+func WithRenameNoReplace() Option {
+	return func(o *options) {
+		o.noReplace = true
+	}
+}
+
+func (o *options) newBuffer() buffer {
+	if o.spill {
+		return &spillBuffer{
+			mem:       o.newMemBuffer(),
+			newMem:    o.newMemBuffer,
+			dir:       o.spillDir,
+			threshold: o.spillThreshold,
+		}
+	}
+
+	return o.newMemBuffer()
+}
+
+func (o *options) newMemBuffer() buffer {
+	return &chunkedBuffer{store: o.chunks}
+}
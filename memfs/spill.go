@@ -0,0 +1,145 @@
+package memfs
+
+import (
+	"io"
+	"os"
+	"runtime"
+)
+
+// spillBuffer is a buffer kept in memory until it grows past the threshold,
+// when its content is moved to a temporary file of dir.
+type spillBuffer struct {
+	mem       buffer
+	newMem    func() buffer
+	dir       string
+	threshold int64
+
+	f       *os.File
+	size    int64
+	removed bool
+}
+
+func (b *spillBuffer) ReadAt(p []byte, off int64) (int, error) {
+	if b.f == nil {
+		return b.mem.ReadAt(p, off)
+	}
+
+	return b.f.ReadAt(p, off)
+}
+
+func (b *spillBuffer) WriteAt(p []byte, off int64) error {
+	end := off + int64(len(p))
+	if b.f == nil && end > b.threshold {
+		if err := b.spill(); err != nil {
+			return err
+		}
+	}
+
+	if b.f == nil {
+		return b.mem.WriteAt(p, off)
+	}
+
+	if _, err := b.f.WriteAt(p, off); err != nil {
+		return err
+	}
+	if end > b.size {
+		b.size = end
+	}
+
+	return nil
+}
+
+func (b *spillBuffer) Len() int64 {
+	if b.f == nil {
+		return b.mem.Len()
+	}
+
+	return b.size
+}
+
+// Truncate moves the content back to memory when it is emptied.
+func (b *spillBuffer) Truncate(size int64) error {
+	if b.f != nil && size == 0 {
+		b.remove()
+		b.mem = b.newMem()
+		return nil
+	}
+
+	if b.f == nil && size > b.threshold {
+		if err := b.spill(); err != nil {
+			return err
+		}
+	}
+
+	if b.f == nil {
+		return b.mem.Truncate(size)
+	}
+
+	if err := b.f.Truncate(size); err != nil {
+		return err
+	}
+	b.size = size
+
+	return nil
+}
+
+func (b *spillBuffer) Seal() {
+	if b.f == nil {
+		b.mem.Seal()
+	}
+}
+
+// Release unlinks the temporary file, keeping it open for the files still
+// open, or closes it where open files can't be removed.
+func (b *spillBuffer) Release() {
+	if b.f == nil {
+		b.mem.Release()
+		return
+	}
+
+	if os.Remove(b.f.Name()) == nil {
+		b.removed = true
+		return
+	}
+
+	b.remove()
+	b.mem = b.newMem()
+}
+
+// spill copies the content to a new temporary file.
+func (b *spillBuffer) spill() error {
+	f, err := os.CreateTemp(b.dir, "memfs-")
+	if err != nil {
+		return err
+	}
+
+	size := b.mem.Len()
+	_, err = io.Copy(f, io.NewSectionReader(b.mem, 0, size))
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	b.mem.Release()
+	b.mem = nil
+	b.f, b.size = f, size
+
+	// The files of a Memory dropped without being removed would be left
+	// behind otherwise.
+	runtime.SetFinalizer(b, (*spillBuffer).remove)
+	return nil
+}
+
+func (b *spillBuffer) remove() {
+	if b.f == nil {
+		return
+	}
+
+	runtime.SetFinalizer(b, nil)
+	b.f.Close()
+	if !b.removed {
+		os.Remove(b.f.Name())
+	}
+	b.f, b.size, b.removed = nil, 0, false
+}
@@ -0,0 +1,82 @@
+package memfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+type SpillSuite struct {
+	test.FilesystemSuite
+	dir string
+}
+
+var _ = Suite(&SpillSuite{})
+
+func (s *SpillSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.FilesystemSuite = test.NewFilesystemSuite(New(WithSpill(s.dir, 8)))
+}
+
+func (s *SpillSuite) spilled(c *C) int {
+	entries, err := os.ReadDir(s.dir)
+	c.Assert(err, IsNil)
+	return len(entries)
+}
+
+func (s *SpillSuite) TestSpill(c *C) {
+	c.Assert(util.WriteFile(s.FS, "small", []byte("12345678"), 0o644), IsNil)
+	c.Assert(s.spilled(c), Equals, 0)
+
+	c.Assert(util.WriteFile(s.FS, "large", []byte("123456789"), 0o644), IsNil)
+	c.Assert(s.spilled(c), Equals, 1)
+
+	f, err := s.FS.OpenFile("small", os.O_WRONLY|os.O_APPEND, 0)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("9abc"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(s.spilled(c), Equals, 2)
+
+	b, err := util.ReadFile(s.FS, "small")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "123456789abc")
+
+	fi, err := s.FS.Stat("small")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(12))
+
+	c.Assert(util.WriteFile(s.FS, "small", []byte("1"), 0o644), IsNil)
+	c.Assert(s.spilled(c), Equals, 1)
+
+	c.Assert(s.FS.Remove("large"), IsNil)
+	c.Assert(s.spilled(c), Equals, 0)
+}
+
+func (s *SpillSuite) TestTruncate(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(20), IsNil)
+	c.Assert(s.spilled(c), Equals, 1)
+	c.Assert(f.Truncate(4), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	b, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(b, DeepEquals, make([]byte, 4))
+}
+
+func (s *SpillSuite) TestClose(c *C) {
+	c.Assert(util.WriteFile(s.FS, "dir/large", []byte("123456789"), 0o644), IsNil)
+	c.Assert(s.spilled(c), Equals, 1)
+
+	c.Assert(billy.Close(s.FS), IsNil)
+	c.Assert(s.spilled(c), Equals, 0)
+
+	_, err := s.FS.Stat("dir")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
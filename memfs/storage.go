@@ -6,82 +6,254 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/watch"
+	"github.com/go-git/go-billy/v5/util"
 )
 
+// storage is the tree of the files of a Memory. Its lock is held for
+// writing only by the operations moving or removing whole subtrees, the
+// other ones holding it for reading and locking the directories they walk
+// through or change, so that the operations on different directories don't
+// wait for each other.
 type storage struct {
-	files    map[string]*file
-	children map[string]map[string]*file
+	// inodes is the last inode number given, first for its alignment.
+	inodes uint64
+	dev    uint64
+
+	m       sync.RWMutex
+	root    *node
+	hub     *watch.Hub
+	changes changeLog
+	opts    options
+}
+
+// devices is the last device number given to a storage.
+var devices uint64
+
+// node is an entry of the tree of the storage, the children of the
+// directories being indexed by name. The root node has no file until it is
+// created; the file of the other nodes never changes.
+type node struct {
+	m        sync.RWMutex
+	file     *file
+	children map[string]*node
+	removed  bool
 }
 
-func newStorage() *storage {
+func newStorage(opts options) *storage {
 	return &storage{
-		files:    make(map[string]*file, 0),
-		children: make(map[string]map[string]*file, 0),
+		dev:     atomic.AddUint64(&devices, 1),
+		root:    &node{children: make(map[string]*node)},
+		hub:     &watch.Hub{},
+		changes: changeLog{size: opts.changeLog},
+		opts:    opts,
 	}
 }
 
-func (s *storage) Has(path string) bool {
-	path = clean(path)
+// notify reports op on path to the watchers, and records it for Changes.
+func (s *storage) notify(op billy.EventOp, path string) {
+	s.hub.Notify(op, path)
+	s.changes.record(op, path)
+}
+
+func (n *node) child(name string) *node {
+	n.m.RLock()
+	defer n.m.RUnlock()
 
-	_, ok := s.files[path]
+	return n.children[name]
+}
+
+func (n *node) entry() *file {
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	return n.file
+}
+
+// node returns the node of path, walking the tree from the root.
+func (s *storage) node(path string) *node {
+	n := s.root
+	for _, name := range split(path) {
+		if n = n.child(name); n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+// split returns the names of the elements of path.
+func split(path string) []string {
+	path = strings.Trim(clean(path), string(separator))
+	if path == "" || path == "." {
+		return nil
+	}
+
+	return strings.Split(path, string(separator))
+}
+
+func (s *storage) Has(path string) bool {
+	_, ok := s.Get(path)
 	return ok
 }
 
 func (s *storage) New(path string, mode os.FileMode, flag int) (*file, error) {
-	path = clean(path)
-	if s.Has(path) {
-		if !s.MustGet(path).mode.IsDir() {
-			return nil, fmt.Errorf("file already exists %q", path)
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.create(clean(path), mode, flag)
+}
+
+// create creates the file path, along with its missing parents. It returns
+// a nil file if path is an existing directory.
+func (s *storage) create(path string, mode os.FileMode, flag int) (*file, error) {
+	names := split(path)
+	if len(names) == 0 {
+		return s.createRoot(mode, flag), nil
+	}
+
+	// The paths of the elements, for the notifications and the errors.
+	paths := make([]string, len(names))
+	for i, p := len(names)-1, path; i >= 0; i-- {
+		paths[i], p = p, filepath.Dir(p)
+	}
+
+	dirMode := mode.Perm() | os.ModeDir
+	s.createRoot(dirMode, 0)
+
+	n := s.root
+	last := len(names) - 1
+	for i, name := range names[:last] {
+		c, f, err := s.add(n, name, paths[i], dirMode, 0)
+		if err != nil {
+			return nil, err
 		}
 
-		return nil, nil
+		if f == nil && !c.file.loadMode().IsDir() {
+			return nil, &os.PathError{Op: "open", Path: paths[i], Err: syscall.ENOTDIR}
+		}
+		n = c
 	}
 
-	name := filepath.Base(path)
+	c, f, err := s.add(n, names[last], path, mode, flag)
+	if err != nil || f != nil {
+		return f, err
+	}
 
-	f := &file{
-		name:    name,
-		content: &content{name: name},
-		mode:    mode,
-		flag:    flag,
+	if !c.file.loadMode().IsDir() {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
 	}
 
-	s.files[path] = f
-	s.createParent(path, mode, f)
-	return f, nil
+	return nil, nil
 }
 
-func (s *storage) createParent(path string, mode os.FileMode, f *file) error {
-	base := filepath.Dir(path)
-	base = clean(base)
-	if f.Name() == string(separator) {
+// createRoot creates the root directory if missing, and returns it if so.
+func (s *storage) createRoot(mode os.FileMode, flag int) *file {
+	s.root.m.Lock()
+	defer s.root.m.Unlock()
+
+	if s.root.file != nil {
 		return nil
 	}
 
-	if _, err := s.New(base, mode.Perm()|os.ModeDir, 0); err != nil {
-		return err
+	s.root.file = s.newFile(string(separator), mode, flag)
+	s.notify(billy.EventCreate, string(separator))
+	return s.root.file
+}
+
+// add returns the child name of the directory n, creating it with mode if
+// missing, in which case its file is returned too.
+func (s *storage) add(n *node, name, path string, mode os.FileMode, flag int) (*node, *file, error) {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if n.removed {
+		return nil, nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
 	}
 
-	if _, ok := s.children[base]; !ok {
-		s.children[base] = make(map[string]*file, 0)
+	if c, ok := n.children[name]; ok {
+		return c, nil, nil
 	}
 
-	s.children[base][f.Name()] = f
-	return nil
+	c := &node{file: s.newFile(name, mode, flag)}
+	if mode.IsDir() {
+		c.children = make(map[string]*node)
+	}
+
+	n.children[name] = c
+	s.notify(billy.EventCreate, path)
+	return c, c.file, nil
 }
 
-func (s *storage) Children(path string) []*file {
-	path = clean(path)
+func (s *storage) newFile(name string, mode os.FileMode, flag int) *file {
+	return &file{
+		name: name,
+		content: &content{
+			name:    name,
+			modTime: util.Now(),
+			data:    s.opts.newBuffer(),
+			dev:     s.dev,
+			ino:     atomic.AddUint64(&s.inodes, 1),
+			nlink:   1,
+		},
+		mode: mode,
+		flag: flag,
+		s:    s,
+	}
+}
+
+// Children returns the files of the directory path, by name.
+func (s *storage) Children(path string) map[string]*file {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	l := make(map[string]*file)
+
+	n := s.node(path)
+	if n == nil {
+		return l
+	}
+
+	n.m.RLock()
+	defer n.m.RUnlock()
 
-	l := make([]*file, 0)
-	for _, f := range s.children[path] {
-		l = append(l, f)
+	for name, c := range n.children {
+		l[name] = c.file
 	}
 
 	return l
 }
 
+// Dir returns the node of path with the names of its children, sorted, or a
+// nil node if path doesn't exist.
+func (s *storage) Dir(path string) (*node, []string) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	n := s.node(path)
+	if n == nil {
+		return nil, nil
+	}
+
+	n.m.RLock()
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	n.m.RUnlock()
+
+	sort.Strings(names)
+	return n, names
+}
+
 func (s *storage) MustGet(path string) *file {
 	f, ok := s.Get(path)
 	if !ok {
@@ -92,93 +264,316 @@ func (s *storage) MustGet(path string) *file {
 }
 
 func (s *storage) Get(path string) (*file, bool) {
-	path = clean(path)
-	if !s.Has(path) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	n := s.node(path)
+	if n == nil {
 		return nil, false
 	}
 
-	file, ok := s.files[path]
-	return file, ok
+	f := n.entry()
+	return f, f != nil
 }
 
-func (s *storage) Rename(from, to string) error {
-	from = clean(from)
-	to = clean(to)
+// Rename moves the node of from, with its whole subtree, under the parent of
+// to, replacing the node found there if any.
+func (s *storage) Rename(oldpath, newpath string) error {
+	from := clean(oldpath)
+	to := clean(newpath)
 
-	if !s.Has(from) {
-		return os.ErrNotExist
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	n := s.node(from)
+	if n == nil || n.file == nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
 	}
 
-	move := [][2]string{{from, to}}
+	if from == to {
+		return nil
+	}
 
-	for pathFrom := range s.files {
-		if pathFrom == from || !filepath.HasPrefix(pathFrom, from) {
-			continue
+	if n == s.root || strings.HasPrefix(to, from+string(separator)) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EINVAL}
+	}
+
+	if _, err := s.create(filepath.Dir(to), 0644|os.ModeDir, 0); err != nil {
+		if pe, ok := err.(*os.PathError); ok {
+			err = pe.Err
 		}
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	parent := s.node(filepath.Dir(to))
+
+	name := filepath.Base(to)
+	old, replaced := parent.children[name]
+	if replaced && old.file == n.file {
+		// Both are hard links to the same file, left as they are.
+		return nil
+	}
 
-		rel, _ := filepath.Rel(from, pathFrom)
-		pathTo := filepath.Join(to, rel)
+	if replaced {
+		err := checkReplace(n, old)
+		if err == nil && s.opts.noReplace {
+			err = os.ErrExist
+		}
+		if err != nil {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+		}
+	}
 
-		move = append(move, [2]string{pathFrom, pathTo})
+	delete(s.node(filepath.Dir(from)).children, filepath.Base(from))
+	if replaced {
+		old.release()
 	}
 
-	for _, ops := range move {
-		from := ops[0]
-		to := ops[1]
+	parent.children[name] = n
+
+	s.notify(billy.EventRename, from)
+	s.notify(billy.EventCreate, to)
+	return nil
+}
 
-		if err := s.move(from, to); err != nil {
-			return err
+// Link adds newpath as another name of the file oldpath, creating the missing
+// parents of newpath.
+func (s *storage) Link(oldpath, newpath string) error {
+	from := clean(oldpath)
+	to := clean(newpath)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	n := s.node(from)
+	if n == nil || n.file == nil {
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+
+	if n.file.loadMode().IsDir() {
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: syscall.EPERM}
+	}
+
+	if _, err := s.create(filepath.Dir(to), 0755|os.ModeDir, 0); err != nil {
+		if pe, ok := err.(*os.PathError); ok {
+			err = pe.Err
 		}
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: err}
 	}
+	parent := s.node(filepath.Dir(to))
 
+	name := filepath.Base(to)
+	if _, ok := parent.children[name]; ok {
+		return &os.LinkError{Op: "link", Old: oldpath, New: newpath, Err: os.ErrExist}
+	}
+
+	atomic.AddUint64(&n.file.content.nlink, 1)
+	parent.children[name] = &node{file: n.file}
+
+	s.notify(billy.EventCreate, to)
 	return nil
 }
 
-func (s *storage) move(from, to string) error {
-	s.files[to] = s.files[from]
-	s.files[to].name = filepath.Base(to)
-	s.children[to] = s.children[from]
+// Remove removes the file or empty directory path, locking only its parent
+// unless path is the root.
+func (s *storage) Remove(name string) error {
+	path := clean(name)
+	if len(split(path)) == 0 {
+		return s.removeRoot(name)
+	}
+
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	parent := s.node(filepath.Dir(path))
+	if parent == nil {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+
+	parent.m.Lock()
+	defer parent.m.Unlock()
+
+	base := filepath.Base(path)
+	n, ok := parent.children[base]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+
+	n.m.Lock()
+	defer n.m.Unlock()
 
-	defer func() {
-		delete(s.children, from)
-		delete(s.files, from)
-		delete(s.children[filepath.Dir(from)], filepath.Base(from))
-	}()
+	if n.file.loadMode().IsDir() && len(n.children) != 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
 
-	return s.createParent(to, 0644, s.files[to])
+	n.removed = true
+	delete(parent.children, base)
+	n.file.content.unlink()
+	s.notify(billy.EventRemove, path)
+	return nil
 }
 
-func (s *storage) Remove(path string) error {
-	path = clean(path)
+func (s *storage) removeRoot(path string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
 
-	f, has := s.Get(path)
-	if !has {
-		return os.ErrNotExist
+	if s.root.file == nil {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
 	}
 
-	if f.mode.IsDir() && len(s.children[path]) != 0 {
-		return fmt.Errorf("dir: %s contains files", path)
+	if len(s.root.children) != 0 {
+		return &os.PathError{Op: "remove", Path: path, Err: syscall.ENOTEMPTY}
 	}
 
-	base, file := filepath.Split(path)
-	base = filepath.Clean(base)
+	s.root.file.content.unlink()
+	s.root = &node{children: make(map[string]*node)}
+	s.notify(billy.EventRemove, clean(path))
+	return nil
+}
+
+// Usage returns the size of the contents of the files, and their number,
+// directories included.
+func (s *storage) Usage() (bytes, files uint64) {
+	s.m.RLock()
+	defer s.m.RUnlock()
 
-	delete(s.children[base], file)
-	delete(s.files, path)
+	return s.root.usage()
+}
+
+func (n *node) usage() (bytes, files uint64) {
+	n.m.RLock()
+	defer n.m.RUnlock()
+
+	if n.file != nil {
+		bytes, files = uint64(n.file.content.Len()), 1
+	}
+
+	for _, c := range n.children {
+		b, f := c.usage()
+		bytes, files = bytes+b, files+f
+	}
+
+	return bytes, files
+}
+
+// Close empties the storage, releasing the contents of all its files.
+func (s *storage) Close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.root.release()
+	s.root = &node{children: make(map[string]*node)}
+	s.changes.reset()
+}
+
+// RemoveAll removes path and its subtree at once, reporting the removal of
+// every entry, the children before their parent.
+func (s *storage) RemoveAll(path string) error {
+	path = clean(path)
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	n := s.node(path)
+	if n == nil || n.file == nil {
+		return nil
+	}
+
+	if n != s.root {
+		delete(s.node(filepath.Dir(path)).children, filepath.Base(path))
+	} else {
+		s.root = &node{children: make(map[string]*node)}
+	}
+
+	n.release()
+	s.notifyRemoved(path, n)
 	return nil
 }
 
+func (s *storage) notifyRemoved(path string, n *node) {
+	for name, c := range n.children {
+		s.notifyRemoved(filepath.Join(path, name), c)
+	}
+
+	s.notify(billy.EventRemove, path)
+}
+
+// release releases the contents of the files of the subtree of n, marking
+// its directories as removed.
+func (n *node) release() {
+	for _, c := range n.children {
+		c.release()
+	}
+
+	n.removed = true
+	if n.file != nil {
+		n.file.content.unlink()
+	}
+}
+
 func clean(path string) string {
 	return filepath.Clean(filepath.FromSlash(path))
 }
 
 type content struct {
-	name  string
-	bytes []byte
+	// nlink is the number of names of the file, first for its alignment.
+	nlink uint64
+	dev   uint64
+	ino   uint64
+
+	name    string
+	data    buffer
+	modTime time.Time
+	xattrs  map[string][]byte
+	locks   flock
 
 	m sync.RWMutex
 }
 
+func (c *content) Getxattr(attr string) ([]byte, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	v, ok := c.xattrs[attr]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]byte(nil), v...), true
+}
+
+func (c *content) Setxattr(attr string, value []byte) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.xattrs == nil {
+		c.xattrs = make(map[string][]byte)
+	}
+	c.xattrs[attr] = append([]byte(nil), value...)
+}
+
+func (c *content) Listxattr() []string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	var attrs []string
+	for attr := range c.xattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	return attrs
+}
+
+func (c *content) Removexattr(attr string) bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	_, ok := c.xattrs[attr]
+	delete(c.xattrs, attr)
+
+	return ok
+}
+
 func (c *content) WriteAt(p []byte, off int64) (int, error) {
 	if off < 0 {
 		return 0, &os.PathError{
@@ -189,20 +584,29 @@ func (c *content) WriteAt(p []byte, off int64) (int, error) {
 	}
 
 	c.m.Lock()
-	prev := len(c.bytes)
+	defer c.m.Unlock()
 
-	diff := int(off) - prev
-	if diff > 0 {
-		c.bytes = append(c.bytes, make([]byte, diff)...)
+	if err := c.data.WriteAt(p, off); err != nil {
+		return 0, &os.PathError{Op: "write", Path: c.name, Err: err}
 	}
+	c.modTime = util.Now()
 
-	c.bytes = append(c.bytes[:off], p...)
-	if len(c.bytes) < prev {
-		c.bytes = c.bytes[:prev]
+	return len(p), nil
+}
+
+// Append writes p at the end of the content, as a single operation, and
+// returns the offset following it.
+func (c *content) Append(p []byte) (int, int64, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	off := c.data.Len()
+	if err := c.data.WriteAt(p, off); err != nil {
+		return 0, off, &os.PathError{Op: "write", Path: c.name, Err: err}
 	}
-	c.m.Unlock()
+	c.modTime = util.Now()
 
-	return len(p), nil
+	return len(p), off + int64(len(p)), nil
 }
 
 func (c *content) ReadAt(b []byte, off int64) (n int, err error) {
@@ -215,24 +619,89 @@ func (c *content) ReadAt(b []byte, off int64) (n int, err error) {
 	}
 
 	c.m.RLock()
-	size := int64(len(c.bytes))
-	if off >= size {
-		c.m.RUnlock()
+	defer c.m.RUnlock()
+
+	if off >= c.data.Len() {
 		return 0, io.EOF
 	}
 
-	l := int64(len(b))
-	if off+l > size {
-		l = size - off
+	return c.data.ReadAt(b, off)
+}
+
+// Truncate changes the size of the content, extending it with zeros.
+func (c *content) Truncate(size int64) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if err := c.data.Truncate(size); err != nil {
+		return &os.PathError{Op: "truncate", Path: c.name, Err: err}
 	}
+	c.modTime = util.Now()
+
+	return nil
+}
+
+// Extend grows the content to size with zeros, leaving it as is if it is
+// already larger.
+func (c *content) Extend(size int64) error {
+	c.m.Lock()
+	defer c.m.Unlock()
 
-	btr := c.bytes[off : off+l]
-	n = copy(b, btr)
+	if c.data.Len() >= size {
+		return nil
+	}
 
-	if len(btr) < len(b) {
-		err = io.EOF
+	if err := c.data.Truncate(size); err != nil {
+		return &os.PathError{Op: "allocate", Path: c.name, Err: err}
 	}
-	c.m.RUnlock()
+	c.modTime = util.Now()
 
-	return
+	return nil
+}
+
+func (c *content) ModTime() time.Time {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return c.modTime
+}
+
+func (c *content) Len() int64 {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return c.data.Len()
+}
+
+// String returns the whole content, such as the target of a symlink.
+func (c *content) String() string {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	b := make([]byte, c.data.Len())
+	n, _ := c.data.ReadAt(b, 0)
+	return string(b[:n])
+}
+
+// seal is called once a file opened for writing is closed.
+func (c *content) seal() {
+	c.m.Lock()
+	c.data.Seal()
+	c.m.Unlock()
+}
+
+// unlink drops one of the names of the file, releasing the content once it
+// has none left.
+func (c *content) unlink() {
+	if atomic.AddUint64(&c.nlink, ^uint64(0)) == 0 {
+		c.release()
+	}
+}
+
+// release is called once the content is no longer part of the filesystem.
+// The files still open keep reading it.
+func (c *content) release() {
+	c.m.Lock()
+	c.data.Release()
+	c.m.Unlock()
 }
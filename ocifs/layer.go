@@ -0,0 +1,318 @@
+package ocifs
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// whiteoutPrefix marks the entries removing a file of the lower layers.
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque marks the directories hiding the content they have in
+	// the lower layers.
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// Layer is the blob of a layer of an OCI image or artifact: a tar archive,
+// either uncompressed or compressed with gzip.
+//
+// The files of the uncompressed layers are read in place, with random
+// access. The ones of the compressed layers are decompressed from the start
+// of the blob each time they are read from an offset other than the one
+// reached by the previous read, so seeking back in them is slow.
+type Layer struct {
+	// Blob gives access to the content of the layer, such as a file of a
+	// blob cache.
+	Blob io.ReaderAt
+	// Size is the size of Blob in bytes.
+	Size int64
+}
+
+// node is an entry of the merged tree.
+type node struct {
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+	target  string
+	hdr     *tar.Header
+	data    content
+	// layer is the index of the layer the entry comes from.
+	layer int
+
+	children map[string]*node
+}
+
+func newDir(layer int) *node {
+	return &node{
+		mode:     os.ModeDir | 0o755,
+		layer:    layer,
+		children: make(map[string]*node),
+	}
+}
+
+// content gives access to the data of a regular file.
+type content interface {
+	// reader returns a reader of the data, starting at off.
+	reader(off int64) (io.Reader, error)
+}
+
+// section is the data of a file of an uncompressed layer.
+type section struct {
+	blob       io.ReaderAt
+	start, len int64
+}
+
+func (s *section) reader(off int64) (io.Reader, error) {
+	return io.NewSectionReader(s.blob, s.start+off, s.len-off), nil
+}
+
+// compressed is the data of a file of a gzip compressed layer, at start in
+// the decompressed stream.
+type compressed struct {
+	layer      Layer
+	start, len int64
+}
+
+func (c *compressed) reader(off int64) (io.Reader, error) {
+	zr, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(c.layer.Blob, 0, c.layer.Size)))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.CopyN(io.Discard, zr, c.start+off); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return io.LimitReader(zr, c.len-off), nil
+}
+
+// inline is the data of a file kept in memory, the sparse files which can't
+// be read in place.
+type inline []byte
+
+func (b inline) reader(off int64) (io.Reader, error) {
+	return bytes.NewReader(b[off:]), nil
+}
+
+// counter counts the bytes read from a reader.
+type counter struct {
+	r io.Reader
+	n int64
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// apply merges the entries of the layer at index i of fs.
+func (fs *FS) apply(i int, l Layer) error {
+	gzipped, err := isGzip(l)
+	if err != nil {
+		return err
+	}
+
+	var cr *counter
+	if gzipped {
+		zr, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(l.Blob, 0, l.Size)))
+		if err != nil {
+			return err
+		}
+		cr = &counter{r: zr}
+	} else {
+		cr = &counter{r: io.NewSectionReader(l.Blob, 0, l.Size)}
+	}
+
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		name, err := cleanName(hdr.Name)
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i, err)
+		}
+		if name == "" {
+			// The root itself, whose attributes are kept.
+			continue
+		}
+
+		dir, base := path.Split(name)
+		parent := fs.mkdirAll(strings.TrimSuffix(dir, "/"), i)
+
+		switch {
+		case base == whiteoutOpaque:
+			for name, child := range parent.children {
+				if child.layer < i {
+					delete(parent.children, name)
+				}
+			}
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			target := strings.TrimPrefix(base, whiteoutPrefix)
+			if child, ok := parent.children[target]; ok && child.layer < i {
+				delete(parent.children, target)
+			}
+			continue
+		}
+
+		n, err := fs.entry(i, l, gzipped, hdr, tr, cr.n)
+		if err != nil {
+			return fmt.Errorf("layer %d: %s: %w", i, name, err)
+		}
+
+		// The directories of several layers are merged, the attributes of
+		// the upper one winning.
+		if old, ok := parent.children[base]; ok && old.mode.IsDir() && n.mode.IsDir() {
+			n.children = old.children
+		}
+		parent.children[base] = n
+	}
+}
+
+// entry returns the node of the entry hdr, whose data starts at offset off
+// of the tar stream.
+func (fs *FS) entry(i int, l Layer, gzipped bool, hdr *tar.Header, tr *tar.Reader, off int64) (*node, error) {
+	n := &node{
+		mode:    hdr.FileInfo().Mode(),
+		modTime: hdr.ModTime,
+		hdr:     hdr,
+		layer:   i,
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		n.children = make(map[string]*node)
+	case tar.TypeSymlink:
+		n.target = hdr.Linkname
+	case tar.TypeLink:
+		name, err := cleanName(hdr.Linkname)
+		if err != nil {
+			return nil, err
+		}
+
+		target, err := fs.lookup(name)
+		if err != nil || !target.mode.IsRegular() {
+			return nil, fmt.Errorf("hard link to %s: %w", hdr.Linkname, os.ErrNotExist)
+		}
+
+		n.mode, n.size, n.data = target.mode, target.size, target.data
+	case tar.TypeReg, tar.TypeGNUSparse:
+		n.size = hdr.Size
+		switch {
+		case isSparse(hdr):
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			n.data = inline(data)
+		case gzipped:
+			n.data = &compressed{layer: l, start: off, len: hdr.Size}
+		default:
+			n.data = &section{blob: l.Blob, start: off, len: hdr.Size}
+		}
+	}
+
+	return n, nil
+}
+
+// mkdirAll returns the directory at name, creating it and its parents in
+// layer i if they don't exist or aren't directories.
+func (fs *FS) mkdirAll(name string, i int) *node {
+	dir := fs.root
+	if name == "" {
+		return dir
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		child, ok := dir.children[elem]
+		if !ok || !child.mode.IsDir() {
+			child = newDir(i)
+			dir.children[elem] = child
+		}
+		dir = child
+	}
+
+	return dir
+}
+
+// lookup returns the node at the clean slash separated name, without
+// following symbolic links.
+func (fs *FS) lookup(name string) (*node, error) {
+	n := fs.root
+	if name == "" {
+		return n, nil
+	}
+
+	for _, elem := range strings.Split(name, "/") {
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		n = child
+	}
+
+	return n, nil
+}
+
+// cleanName returns the slash separated name of an entry, relative to the
+// root. The names with ".." elements or NUL bytes are rejected.
+func cleanName(name string) (string, error) {
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." || strings.Contains(elem, "\x00") {
+			return "", fmt.Errorf("invalid entry name %q", name)
+		}
+	}
+
+	return strings.TrimPrefix(path.Clean("/"+name), "/"), nil
+}
+
+// isSparse reports whether the data of hdr can't be read in place.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isGzip reports whether the blob of l starts with the gzip magic number.
+func isGzip(l Layer) (bool, error) {
+	var magic [2]byte
+	_, err := l.Blob.ReadAt(magic[:], 0)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return magic[0] == 0x1f && magic[1] == 0x8b, nil
+}
@@ -0,0 +1,373 @@
+// Package ocifs provides a read-only billy filesystem exposing the merged
+// content of the layers of an OCI image or artifact, as they would be once
+// extracted on top of each other, without extracting them.
+//
+// The whiteouts of the OCI image specification are honored: a ".wh.<name>"
+// entry removes <name> of the lower layers, and a ".wh..wh..opq" entry
+// makes its directory hide the content it has in the lower layers. The
+// whiteouts themselves don't show in the merged tree.
+package ocifs // import "github.com/go-git/go-billy/v5/ocifs"
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// maxLinks is the number of symbolic links followed resolving a path.
+const maxLinks = 255
+
+// FS is a read-only billy.Filesystem made of the entries of a stack of
+// layers. The absolute targets of its symbolic links are resolved from its
+// root, as in a container. Every write operation fails with
+// billy.ErrReadOnly.
+//
+// The merged tree is built by New, reading the headers of every layer, and
+// kept in memory; the content of the files is read from the layers when the
+// files are. FS is safe for concurrent use.
+type FS struct {
+	root *node
+}
+
+// New returns the filesystem merging layers, the lowest one first, as they
+// are listed in the manifest of an image.
+func New(layers ...Layer) (*FS, error) {
+	fs := &FS{root: newDir(-1)}
+	for i, l := range layers {
+		if err := fs.apply(i, l); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs, nil
+}
+
+// resolve returns the node at name, following the symbolic links leading to
+// it, as well as the final one if follow is true.
+func (fs *FS) resolve(op, name string, follow bool) (*node, error) {
+	pending := splitPath(filepath.ToSlash(name))
+	n := fs.root
+	var parents []*node
+
+	links := 0
+	for len(pending) > 0 {
+		elem := pending[0]
+		pending = pending[1:]
+
+		switch elem {
+		case ".":
+			continue
+		case "..":
+			if len(parents) > 0 {
+				n = parents[len(parents)-1]
+				parents = parents[:len(parents)-1]
+			}
+			continue
+		}
+
+		if !n.mode.IsDir() {
+			return nil, &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+
+		if child.mode&os.ModeSymlink != 0 && (follow || len(pending) > 0) {
+			if links++; links > maxLinks {
+				return nil, &os.PathError{Op: op, Path: name, Err: syscall.ELOOP}
+			}
+
+			if path.IsAbs(child.target) {
+				n, parents = fs.root, nil
+			}
+			pending = append(splitPath(child.target), pending...)
+			continue
+		}
+
+		parents = append(parents, n)
+		n = child
+	}
+
+	return n, nil
+}
+
+func splitPath(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool { return r == '/' })
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the regular file or directory at filename for reading. The
+// flags opening it for writing make it fail with billy.ErrReadOnly.
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, billy.ErrReadOnly
+	}
+
+	n, err := fs.resolve("open", filename, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsRegular() && !n.mode.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: billy.ErrNotSupported}
+	}
+
+	return &file{name: filename, n: n}, nil
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	n, err := fs.resolve("stat", filename, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(filename, n), nil
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	n, err := fs.resolve("lstat", filename, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileInfo(filename, n), nil
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	n, err := fs.resolve("readlink", link, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: syscall.EINVAL}
+	}
+
+	return n.target, nil
+}
+
+// ReadDir returns the entries of the directory at path, sorted by name.
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	n, err := fs.resolve("readdir", path, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: syscall.ENOTDIR}
+	}
+
+	return n.entries(), nil
+}
+
+func (fs *FS) Rename(from, to string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+func (fs *FS) Root() string {
+	return "/"
+}
+
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// Capabilities implements the Capable interface.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.ReadCapability | billy.SeekCapability | billy.ConcurrentCapability
+}
+
+func (n *node) entries() []os.FileInfo {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fis := make([]os.FileInfo, len(names))
+	for i, name := range names {
+		fis[i] = &fileInfo{name: name, n: n.children[name]}
+	}
+
+	return fis
+}
+
+type fileInfo struct {
+	name string
+	n    *node
+}
+
+func newFileInfo(name string, n *node) *fileInfo {
+	return &fileInfo{name: filepath.Base(name), n: n}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.n.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.n.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.n.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.n.mode.IsDir() }
+
+// Sys returns the *tar.Header of the entry, or nil for the directories
+// missing from the layers, only implied by the entries they hold.
+func (fi *fileInfo) Sys() interface{} {
+	if fi.n.hdr == nil {
+		return nil
+	}
+
+	return fi.n.hdr
+}
+
+// file is a regular file or a directory opened for reading.
+type file struct {
+	name string
+	n    *node
+
+	off    int64
+	closed bool
+	// r reads the content from roff, reused by the sequential reads.
+	r    io.Reader
+	roff int64
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) check(op string) error {
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	if f.n.mode.IsDir() {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+
+	return nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if f.off >= f.n.size {
+		return 0, io.EOF
+	}
+
+	if f.r == nil || f.roff != f.off {
+		r, err := f.n.data.reader(f.off)
+		if err != nil {
+			return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.r, f.roff = r, f.off
+	}
+
+	n, err := f.r.Read(p)
+	f.off += int64(n)
+	f.roff += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EINVAL}
+	}
+	if off >= f.n.size {
+		return 0, io.EOF
+	}
+
+	r, err := f.n.data.reader(off)
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: err}
+	}
+
+	n, err := io.ReadFull(r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.n.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	f.off = offset
+	return offset, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: billy.ErrReadOnly}
+}
+
+func (f *file) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: f.name, Err: billy.ErrReadOnly}
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+
+	f.closed, f.r = true, nil
+	return nil
+}
+
+// Stat returns the FileInfo of the file, as billy.FileStater.
+func (f *file) Stat() (os.FileInfo, error) {
+	return newFileInfo(f.name, f.n), nil
+}
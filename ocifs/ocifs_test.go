@@ -0,0 +1,262 @@
+package ocifs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+type entry struct {
+	name, content, link string
+	typ                 byte
+}
+
+func layer(t *testing.T, gzipped bool, entries ...entry) Layer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if gzipped {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.typ, Mode: 0o644}
+		switch e.typ {
+		case 0:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(e.content))
+		case tar.TypeDir:
+			hdr.Mode = 0o755
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return Layer{Blob: bytes.NewReader(buf.Bytes()), Size: int64(buf.Len())}
+}
+
+func newTestFS(t *testing.T, gzipped bool) *FS {
+	t.Helper()
+
+	fs, err := New(
+		layer(t, gzipped,
+			entry{name: "etc/", typ: tar.TypeDir},
+			entry{name: "etc/passwd", content: "root"},
+			entry{name: "etc/hosts", content: "localhost"},
+			entry{name: "opt/app/bin", content: "v1"},
+			entry{name: "opt/app/lib", content: "lib"},
+			entry{name: "var/cache/data", content: "cached"},
+		),
+		layer(t, gzipped,
+			entry{name: "./etc/.wh.hosts"},
+			entry{name: "./etc/passwd", content: "root\nuser"},
+			entry{name: "./opt/app/.wh..wh..opq"},
+			entry{name: "./opt/app/bin", content: "v2"},
+			entry{name: "./var/cache", content: "now a file"},
+			entry{name: "./data", typ: tar.TypeSymlink, link: "/etc/passwd"},
+			entry{name: "./rel", typ: tar.TypeSymlink, link: "../../opt/app"},
+			entry{name: "./hard", typ: tar.TypeLink, link: "etc/passwd"},
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestMerge(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		fs := newTestFS(t, gzipped)
+
+		for name, want := range map[string]string{
+			"etc/passwd":  "root\nuser",
+			"opt/app/bin": "v2",
+			"var/cache":   "now a file",
+			"data":        "root\nuser",
+			"rel/bin":     "v2",
+			"/hard":       "root\nuser",
+		} {
+			got, err := util.ReadFile(fs, name)
+			if err != nil {
+				t.Errorf("gzip %v: %s: %v", gzipped, name, err)
+				continue
+			}
+			if string(got) != want {
+				t.Errorf("gzip %v: %s: got %q, want %q", gzipped, name, got, want)
+			}
+		}
+
+		for _, name := range []string{"etc/hosts", "etc/.wh.hosts", "opt/app/lib", "opt/app/.wh..wh..opq", "var/cache/data"} {
+			if _, err := fs.Lstat(name); !os.IsNotExist(err) && !errors.Is(err, syscall.ENOTDIR) {
+				t.Errorf("gzip %v: %s: got %v, want not exist", gzipped, name, err)
+			}
+		}
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	fs := newTestFS(t, false)
+
+	fis, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	if got := strings.Join(names, " "); got != "data etc hard opt rel var" {
+		t.Errorf("got %q", got)
+	}
+
+	fis, err = fs.ReadDir("rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 || fis[0].Name() != "bin" || fis[0].Size() != 2 {
+		t.Errorf("got %v, want only bin", fis)
+	}
+
+	if _, err := fs.ReadDir("etc/passwd"); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("got %v, want ENOTDIR", err)
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	fs := newTestFS(t, false)
+
+	fi, err := fs.Lstat("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("got mode %s, want a symlink", fi.Mode())
+	}
+
+	target, err := fs.Readlink("data")
+	if err != nil || target != "/etc/passwd" {
+		t.Errorf("got %q, %v", target, err)
+	}
+
+	fi, err = fs.Stat("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Mode().IsRegular() || fi.Size() != 9 {
+		t.Errorf("got %s of %d bytes, want the target", fi.Mode(), fi.Size())
+	}
+	if hdr, ok := fi.Sys().(*tar.Header); !ok || hdr.Name != "./etc/passwd" {
+		t.Errorf("got %#v, want the tar header", fi.Sys())
+	}
+
+	if _, err := fs.Readlink("etc/passwd"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("got %v, want EINVAL", err)
+	}
+
+	ch, err := fs.Chroot("opt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := util.ReadFile(ch, "app/bin"); err != nil || string(got) != "v2" {
+		t.Errorf("chroot: got %q, %v", got, err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	for _, gzipped := range []bool{false, true} {
+		fs, err := New(layer(t, gzipped, entry{name: "pad", content: "pad"}, entry{name: "file", content: content}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := fs.Open("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := make([]byte, 5)
+		if n, err := f.ReadAt(p, 9995); n != 5 || err != nil || string(p) != "56789" {
+			t.Errorf("gzip %v: ReadAt: got %d, %v, %q", gzipped, n, err, p)
+		}
+		if n, err := f.ReadAt(p, 9998); n != 2 || err != io.EOF {
+			t.Errorf("gzip %v: ReadAt at the end: got %d, %v", gzipped, n, err)
+		}
+
+		if _, err := f.Seek(-3, io.SeekEnd); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(f); err != nil || string(got) != "789" {
+			t.Errorf("gzip %v: got %q, %v", gzipped, got, err)
+		}
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := io.ReadAll(f); err != nil || string(got) != content {
+			t.Errorf("gzip %v: got %d bytes, %v", gzipped, len(got), err)
+		}
+
+		if _, err := f.Write([]byte("foo")); !errors.Is(err, billy.ErrReadOnly) {
+			t.Errorf("Write: got %v, want read-only", err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Read(p); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Read after Close: got %v", err)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	fs := newTestFS(t, false)
+
+	if _, err := fs.Create("foo"); err != billy.ErrReadOnly {
+		t.Errorf("Create: got %v", err)
+	}
+	if _, err := fs.OpenFile("etc/passwd", os.O_RDWR, 0); err != billy.ErrReadOnly {
+		t.Errorf("OpenFile: got %v", err)
+	}
+	if err := fs.Remove("etc/passwd"); err != billy.ErrReadOnly {
+		t.Errorf("Remove: got %v", err)
+	}
+	if billy.CapabilityCheck(fs, billy.WriteCapability) {
+		t.Error("WriteCapability reported")
+	}
+}
+
+func TestInvalidEntry(t *testing.T) {
+	_, err := New(layer(t, false, entry{name: "../escape", content: "foo"}))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
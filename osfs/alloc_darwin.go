@@ -0,0 +1,43 @@
+//go:build darwin
+// +build darwin
+
+package osfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"golang.org/x/sys/unix"
+)
+
+func allocate(f *os.File, off, length int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// F_PREALLOCATE reserves the blocks past the end of the file only, and
+	// doesn't change its size.
+	if grow := off + length - fi.Size(); grow > 0 {
+		store := &unix.Fstore_t{
+			Flags:   unix.F_ALLOCATEALL,
+			Posmode: unix.F_PEOFPOSMODE,
+			Length:  grow,
+		}
+		if err := unix.FcntlFstore(f.Fd(), unix.F_PREALLOCATE, store); err != nil && err != unix.ENOTSUP {
+			return err
+		}
+	}
+
+	return extend(f, off+length)
+}
+
+// seekHole maps the whence values of billy, those of Linux, to the ones of
+// darwin, which are swapped.
+func seekHole(f *os.File, offset int64, whence int) (int64, error) {
+	if whence == billy.SeekData {
+		return f.Seek(offset, unix.SEEK_DATA)
+	}
+
+	return f.Seek(offset, unix.SEEK_HOLE)
+}
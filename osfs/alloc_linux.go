@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func allocate(f *os.File, off, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), 0, off, length)
+	if err == unix.EOPNOTSUPP {
+		return extend(f, off+length)
+	}
+
+	return err
+}
+
+// seekHole relies on lseek(2), whose whence values are the ones of billy.
+func seekHole(f *os.File, offset int64, whence int) (int64, error) {
+	return f.Seek(offset, whence)
+}
@@ -0,0 +1,35 @@
+//go:build !linux && !darwin && !js
+// +build !linux,!darwin,!js
+
+package osfs
+
+import (
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+func allocate(f *os.File, off, length int64) error {
+	return extend(f, off+length)
+}
+
+// seekHole reports the whole file as data, followed by the implicit hole at
+// its end.
+func seekHole(f *os.File, offset int64, whence int) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if offset < 0 || offset >= fi.Size() {
+		return 0, &os.PathError{Op: "seek", Path: f.Name(), Err: syscall.ENXIO}
+	}
+
+	if whence == billy.SeekHole {
+		offset = fi.Size()
+	}
+
+	return f.Seek(offset, io.SeekStart)
+}
@@ -0,0 +1,27 @@
+//go:build darwin
+// +build darwin
+
+package osfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// cloneFile clones in to dst with clonefile(2), on APFS, returning
+// billy.ErrNotSupported if dst exists, as it would not be replaced, or if the
+// filesystem doesn't support clones. The clone keeps the mode of in.
+func cloneFile(in *os.File, dst string, perm os.FileMode) error {
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		return billy.ErrNotSupported
+	}
+
+	if err := unix.Fclonefileat(int(in.Fd()), unix.AT_FDCWD, dst, 0); err != nil {
+		return billy.ErrNotSupported
+	}
+
+	return nil
+}
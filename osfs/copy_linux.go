@@ -0,0 +1,32 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// cloneFile makes dst share the blocks of in with the FICLONE ioctl, which
+// filesystems like Btrfs and XFS support, returning billy.ErrNotSupported
+// where they don't.
+func cloneFile(in *os.File, dst string, perm os.FileMode) error {
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	err = unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+	if err1 := out.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return billy.ErrNotSupported
+	}
+
+	return nil
+}
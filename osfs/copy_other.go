@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !js
+// +build !linux,!darwin,!js
+
+package osfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+func cloneFile(in *os.File, dst string, perm os.FileMode) error {
+	return billy.ErrNotSupported
+}
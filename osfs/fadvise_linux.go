@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func prefetch(path string, off, length int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := unix.Fadvise(int(f.Fd()), off, length, unix.FADV_WILLNEED); err != nil {
+		return &os.PathError{Op: "fadvise", Path: path, Err: err}
+	}
+
+	return nil
+}
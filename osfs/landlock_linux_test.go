@@ -0,0 +1,75 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const landlockEnv = "BILLY_OSFS_LANDLOCK_ROOT"
+
+// TestLandlock checks WithLandlock in a child process, as the restriction
+// can't be lifted once applied.
+func TestLandlock(t *testing.T) {
+	if root := os.Getenv(landlockEnv); root != "" {
+		if err := landlockChild(root); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLandlock$")
+	cmd.Env = append(os.Environ(), landlockEnv+"="+root)
+	out, err := cmd.CombinedOutput()
+	if strings.Contains(string(out), "landlock unavailable") {
+		t.Skip(strings.TrimSpace(string(out)))
+	}
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+}
+
+func landlockChild(root string) error {
+	fs := New(root, WithLandlock())
+	if billy.Capabilities(fs)&billy.SandboxCapability == 0 {
+		return fmt.Errorf("landlock unavailable: %v", landlock(root))
+	}
+
+	if err := util.WriteFile(fs, "file", []byte("inside"), 0o644); err != nil {
+		return fmt.Errorf("write inside the root: %w", err)
+	}
+	if _, err := util.ReadFile(fs, "file"); err != nil {
+		return fmt.Errorf("read inside the root: %w", err)
+	}
+
+	_, err := util.ReadFile(fs, "link")
+	if !errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("expected a permission error through the link, got %v", err)
+	}
+
+	if billy.Capabilities(Default)&billy.SandboxCapability != 0 {
+		return fmt.Errorf("unexpected sandbox capability for Default")
+	}
+
+	return nil
+}
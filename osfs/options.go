@@ -0,0 +1,172 @@
+package osfs
+
+import "os"
+
+// Option configures the filesystem returned by New.
+type Option func(*options)
+
+type options struct {
+	landlock    bool
+	denySpecial bool
+	anonTemp    bool
+	perms       perms
+	ids         IDMapper
+	noReplace   bool
+}
+
+// isZero reports whether o holds no option, the filesystem being Default.
+func (o *options) isZero() bool {
+	return !o.landlock && !o.denySpecial && !o.anonTemp && o.perms == (perms{}) && o.ids == nil && !o.noReplace
+}
+
+// perms holds the modes given to the entries created, see WithUmask.
+type perms struct {
+	// file and dir are the modes of the files created by Create and of the
+	// directories created, when not zero.
+	file, dir os.FileMode
+	umask     os.FileMode
+	// explicit is set when the modes are applied with chmod(2), instead of
+	// being masked by the umask of the process.
+	explicit bool
+	private  bool
+}
+
+// WithLandlock restricts the filesystem accesses of the whole process to the
+// base directory with a Landlock ruleset, on Linux kernels supporting it,
+// so that the kernel enforces the boundary even if a path escapes it.
+//
+// The restriction can't be lifted and applies to every goroutine, including
+// to the files they open through other means, so it is meant for processes,
+// or helper processes, dedicated to working on the base directory. It
+// requires a binary built without cgo. Whether it is active is reported by
+// billy.SandboxCapability.
+func WithLandlock() Option {
+	return func(o *options) {
+		o.landlock = true
+	}
+}
+
+// WithDenySpecialFiles makes Open, OpenFile and Stat fail with
+// billy.ErrSpecialFileDenied on device nodes, FIFOs and sockets, so that a
+// tree extracted from an untrusted source can't be used to interact with
+// them. Lstat still reports them, for the callers wanting to remove them.
+//
+// As the filesystem can't create special files, and OpenFile refuses the
+// existing ones even with O_CREATE, Create always produces regular files.
+func WithDenySpecialFiles() Option {
+	return func(o *options) {
+		o.denySpecial = true
+	}
+}
+
+// WithAnonymousTempFiles makes TempFile create the files without a name, with
+// O_TMPFILE on Linux, so that they vanish if the process dies before they are
+// complete. They are then given a name with billy.Publisher. Where anonymous
+// files aren't supported, by the platform or by the filesystem of dir, named
+// ones are created instead, Publish renaming them.
+//
+// Until it is published, the name of an anonymous file is the one of its
+// directory followed by the prefix and a "*", which doesn't exist.
+func WithAnonymousTempFiles() Option {
+	return func(o *options) {
+		o.anonTemp = true
+	}
+}
+
+// WithRenameNoReplace makes Rename fail with an error satisfying os.IsExist
+// when the target exists, instead of replacing it. It uses renameat2(2) with
+// RENAME_NOREPLACE on Linux. Elsewhere, and on the filesystems not
+// supporting it, the files are linked to their new name then unlinked from
+// the old one, which fails as atomically on an existing target; the
+// directories, and the files of the filesystems without hard links, are
+// renamed after checking that the target is missing, which races with the
+// other processes creating it.
+func WithRenameNoReplace() Option {
+	return func(o *options) {
+		o.noReplace = true
+	}
+}
+
+// WithDefaultFileMode makes Create, which takes no mode, create the files
+// with mode instead of 0666, masked as the other modes.
+func WithDefaultFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.perms.file = mode.Perm()
+	}
+}
+
+// WithDefaultDirMode gives mode, instead of 0755, to the directories created
+// by MkdirAll, which ignores its perm argument, and to the parents created
+// along with the files, masked as the other modes.
+func WithDefaultDirMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.perms.dir = mode.Perm()
+	}
+}
+
+// WithUmask masks the modes of the entries created with mask, instead of
+// the umask of the process, so that they don't depend on the environment:
+// the files and directories are created with their mode then given exactly
+// their masked mode with chmod(2). WithUmask(0) creates them with the modes
+// asked for.
+//
+// The temporary files and directories, always private to their owner, are
+// left as they are created.
+func WithUmask(mask os.FileMode) Option {
+	return func(o *options) {
+		o.perms.umask, o.perms.explicit = mask.Perm(), true
+	}
+}
+
+// WithPrivate gives 0600 to every file created, and 0700 to every
+// directory, whatever the mode asked for and the umask of the process, for
+// the trees holding secrets.
+func WithPrivate() Option {
+	return func(o *options) {
+		o.perms.private, o.perms.explicit = true, true
+	}
+}
+
+// IDMapper maps the owner given to Chown or Lchown, -1 standing for an id
+// left unchanged, to the one applied. It returns false to leave the owner of
+// the file as it is.
+type IDMapper func(uid, gid int) (int, int, bool)
+
+// WithIDMapper makes Chown and Lchown apply the owner returned by m instead
+// of the one given, so that extracting an archive with arbitrary owners
+// doesn't fail in a process lacking the privilege to give them, such as in
+// a container.
+func WithIDMapper(m IDMapper) Option {
+	return func(o *options) {
+		o.ids = m
+	}
+}
+
+// DropOwnership is an IDMapper leaving every owner unchanged, Chown and
+// Lchown doing nothing but checking that the file exists.
+func DropOwnership(uid, gid int) (int, int, bool) {
+	return uid, gid, false
+}
+
+// CurrentUser returns an IDMapper giving every file to the user and the
+// primary group of the process, which any user can do to its own files.
+func CurrentUser() IDMapper {
+	uid, gid := os.Getuid(), os.Getgid()
+	return func(int, int) (int, int, bool) {
+		return uid, gid, true
+	}
+}
+
+// IDOffset returns an IDMapper shifting the ids by uid and gid, as a user
+// namespace mapping the ids of a container to a range of the host does.
+func IDOffset(uid, gid int) IDMapper {
+	return func(u, g int) (int, int, bool) {
+		if u >= 0 {
+			u += uid
+		}
+		if g >= 0 {
+			g += gid
+		}
+		return u, g, true
+	}
+}
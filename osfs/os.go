@@ -5,10 +5,15 @@
 package osfs // import "github.com/go-git/go-billy/v5/osfs"
 
 import (
+	"io"
+	iofs "io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/helper/chroot"
@@ -23,35 +28,109 @@ const (
 var Default = &OS{}
 
 // OS is a filesystem based on the os filesystem.
-type OS struct{}
+type OS struct {
+	// sandboxed is true when the process is restricted to the filesystem,
+	// see WithLandlock.
+	sandboxed bool
+	// denySpecial is set by WithDenySpecialFiles.
+	denySpecial bool
+	// anonTemp is set by WithAnonymousTempFiles.
+	anonTemp bool
+	perms    perms
+	// ids is set by WithIDMapper.
+	ids IDMapper
+	// noReplace is set by WithRenameNoReplace.
+	noReplace bool
+}
 
 // New returns a new OS filesystem.
-func New(baseDir string) billy.Filesystem {
-	return chroot.New(Default, baseDir)
+func New(baseDir string, opts ...Option) billy.Filesystem {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.isZero() {
+		return chroot.New(Default, baseDir)
+	}
+
+	fs := &OS{denySpecial: o.denySpecial, anonTemp: o.anonTemp, perms: o.perms, ids: o.ids, noReplace: o.noReplace}
+	if o.landlock {
+		fs.sandboxed = landlock(baseDir) == nil
+	}
+
+	return chroot.New(fs, baseDir)
 }
 
 func (fs *OS) Create(filename string) (billy.File, error) {
-	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, defaultCreateMode)
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fs.perms.createMode())
 }
 
 func (fs *OS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	name, err := fixPath("open", filename)
+	if err != nil {
+		return nil, err
+	}
+
 	if flag&os.O_CREATE != 0 {
-		if err := fs.createDir(filename); err != nil {
+		if err := fs.createDir(name); err != nil {
 			return nil, err
 		}
 	}
 
-	f, err := os.OpenFile(filename, flag, perm)
+	if fs.denySpecial {
+		return fs.openRegular(name, filename, flag, perm)
+	}
+
+	f, err := fs.openFile(name, flag, perm)
 	if err != nil {
 		return nil, err
 	}
-	return &file{File: f}, err
+	return newFile(f, filename), err
+}
+
+// specialMode are the mode bits of the files refused by WithDenySpecialFiles.
+const specialMode = os.ModeDevice | os.ModeCharDevice | os.ModeNamedPipe | os.ModeSocket
+
+func denySpecial(op, name string, fi os.FileInfo) error {
+	if fi.Mode()&specialMode != 0 {
+		return &os.PathError{Op: op, Path: name, Err: billy.ErrSpecialFileDenied}
+	}
+
+	return nil
+}
+
+// openRegular opens filename, refusing the special files. They are checked
+// before opening, as opening a FIFO blocks and opening a device may have side
+// effects, and after, in case the file was replaced in between.
+func (fs *OS) openRegular(name, filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if fi, err := os.Stat(name); err == nil {
+		if err := denySpecial("open", filename, fi); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := fs.openFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err == nil {
+		err = denySpecial("open", filename, fi)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return newFile(f, filename), nil
 }
 
 func (fs *OS) createDir(fullpath string) error {
 	dir := filepath.Dir(fullpath)
 	if dir != "." {
-		if err := os.MkdirAll(dir, defaultDirectoryMode); err != nil {
+		if err := fs.mkdirAll(dir); err != nil {
 			return err
 		}
 	}
@@ -60,6 +139,11 @@ func (fs *OS) createDir(fullpath string) error {
 }
 
 func (fs *OS) ReadDir(path string) ([]os.FileInfo, error) {
+	path, err := fixPath("readdir", path)
+	if err != nil {
+		return nil, err
+	}
+
 	l, err := ioutil.ReadDir(path)
 	if err != nil {
 		return nil, err
@@ -73,16 +157,119 @@ func (fs *OS) ReadDir(path string) ([]os.FileInfo, error) {
 	return s, nil
 }
 
+// dirBatch is the number of entries read at once by the iterators of
+// OpenDir.
+const dirBatch = 256
+
+// OpenDir implements billy.DirIterator, reading the entries dirBatch at a
+// time, in the order of the directory, without calling lstat(2) on them.
+func (fs *OS) OpenDir(path string) (billy.DirIter, error) {
+	path, err := fixPath("opendir", path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err == nil && !fi.IsDir() {
+		err = &os.PathError{Op: "opendir", Path: path, Err: syscall.ENOTDIR}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &dirIter{f: f}, nil
+}
+
+// dirIter is the billy.DirIter of an OS directory.
+type dirIter struct {
+	f       *os.File
+	entries []iofs.DirEntry
+}
+
+func (it *dirIter) Next() (iofs.DirEntry, error) {
+	if len(it.entries) == 0 {
+		entries, err := it.f.ReadDir(dirBatch)
+		if len(entries) == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		it.entries = entries
+	}
+
+	e := it.entries[0]
+	it.entries = it.entries[1:]
+	return e, nil
+}
+
+func (it *dirIter) Close() error {
+	it.entries = nil
+	return it.f.Close()
+}
+
 func (fs *OS) Rename(from, to string) error {
+	from, err := fixPath("rename", from)
+	if err != nil {
+		return err
+	}
+	to, err = fixPath("rename", to)
+	if err != nil {
+		return err
+	}
+
 	if err := fs.createDir(to); err != nil {
 		return err
 	}
 
+	if fs.noReplace {
+		return renameNoReplace(from, to)
+	}
+
+	return rename(from, to)
+}
+
+// emulateRenameNoReplace renames from to to unless to exists, see
+// WithRenameNoReplace.
+func emulateRenameNoReplace(from, to string) error {
+	fi, err := os.Lstat(from)
+	if err != nil {
+		return err
+	}
+
+	exists := &os.LinkError{Op: "rename", Old: from, New: to, Err: os.ErrExist}
+	if !fi.IsDir() {
+		err := os.Link(from, to)
+		if err == nil {
+			return os.Remove(from)
+		}
+		if os.IsExist(err) {
+			return exists
+		}
+	}
+
+	if _, err := os.Lstat(to); err == nil {
+		return exists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
 	return rename(from, to)
 }
 
 func (fs *OS) MkdirAll(path string, perm os.FileMode) error {
-	return os.MkdirAll(path, defaultDirectoryMode)
+	path, err := fixPath("mkdir", path)
+	if err != nil {
+		return err
+	}
+
+	return fs.mkdirAll(path)
 }
 
 func (fs *OS) Open(filename string) (billy.File, error) {
@@ -90,23 +277,113 @@ func (fs *OS) Open(filename string) (billy.File, error) {
 }
 
 func (fs *OS) Stat(filename string) (os.FileInfo, error) {
-	return os.Stat(filename)
+	name, err := fixPath("stat", filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(name)
+	if err == nil && fs.denySpecial {
+		err = denySpecial("stat", filename, fi)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return fi, nil
 }
 
 func (fs *OS) Remove(filename string) error {
+	filename, err := fixPath("remove", filename)
+	if err != nil {
+		return err
+	}
+
 	return os.Remove(filename)
 }
 
 func (fs *OS) TempFile(dir, prefix string) (billy.File, error) {
-	if err := fs.createDir(dir + string(os.PathSeparator)); err != nil {
+	name, err := fixPath("tempfile", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.createDir(name + string(os.PathSeparator)); err != nil {
+		return nil, err
+	}
+
+	if fs.anonTemp {
+		f, err := openAnonymous(name)
+		if err == nil {
+			return &file{File: f, name: filepath.Join(dir, prefix+"*"), anonymous: true}, nil
+		}
+		if err != billy.ErrNotSupported {
+			return nil, &os.PathError{Op: "tempfile", Path: dir, Err: err}
+		}
+	}
+
+	f, err := ioutil.TempFile(name, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	nf := newFile(f, filepath.Join(dir, filepath.Base(f.Name())))
+	nf.temp = fs.anonTemp
+	return nf, nil
+}
+
+// CreateTemp implements billy.TempCreator with os.CreateTemp.
+func (fs *OS) CreateTemp(dir, pattern string) (billy.File, error) {
+	name, err := fixPath("createtemp", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fs.createDir(name + string(os.PathSeparator)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(name, pattern)
+	if err != nil {
 		return nil, err
 	}
 
-	f, err := ioutil.TempFile(dir, prefix)
+	return newFile(f, filepath.Join(dir, filepath.Base(f.Name()))), nil
+}
+
+// MkdirTemp implements billy.TempCreator with os.MkdirTemp.
+func (fs *OS) MkdirTemp(dir, pattern string) (string, error) {
+	name, err := fixPath("mkdirtemp", dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := fs.createDir(name + string(os.PathSeparator)); err != nil {
+		return "", err
+	}
+
+	path, err := os.MkdirTemp(name, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.Base(path)), nil
+}
+
+// StatFS implements billy.StatFS with statfs(2), or GetDiskFreeSpaceEx on
+// Windows.
+func (fs *OS) StatFS(path string) (*billy.FSInfo, error) {
+	name, err := fixPath("statfs", path)
 	if err != nil {
 		return nil, err
 	}
-	return &file{File: f}, nil
+
+	info, err := statfs(name)
+	if err != nil && err != billy.ErrNotSupported {
+		return nil, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	return info, err
 }
 
 func (fs *OS) Join(elem ...string) string {
@@ -114,14 +391,29 @@ func (fs *OS) Join(elem ...string) string {
 }
 
 func (fs *OS) RemoveAll(path string) error {
-	return os.RemoveAll(filepath.Clean(path))
+	path, err := fixPath("removeall", filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(path)
 }
 
 func (fs *OS) Lstat(filename string) (os.FileInfo, error) {
-	return os.Lstat(filepath.Clean(filename))
+	filename, err := fixPath("lstat", filepath.Clean(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Lstat(filename)
 }
 
 func (fs *OS) Symlink(target, link string) error {
+	link, err := fixPath("symlink", link)
+	if err != nil {
+		return err
+	}
+
 	if err := fs.createDir(link); err != nil {
 		return err
 	}
@@ -130,16 +422,387 @@ func (fs *OS) Symlink(target, link string) error {
 }
 
 func (fs *OS) Readlink(link string) (string, error) {
+	link, err := fixPath("readlink", link)
+	if err != nil {
+		return "", err
+	}
+
 	return os.Readlink(link)
 }
 
+// Link implements billy.Linker.
+func (fs *OS) Link(oldname, newname string) error {
+	oldname, err := fixPath("link", oldname)
+	if err != nil {
+		return err
+	}
+	newname, err = fixPath("link", newname)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.createDir(newname); err != nil {
+		return err
+	}
+
+	return os.Link(oldname, newname)
+}
+
+// Chmod implements billy.Change.
+func (fs *OS) Chmod(name string, mode os.FileMode) error {
+	name, err := fixPath("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	return os.Chmod(name, mode)
+}
+
+// Lchown implements billy.Change, mapping the owner as set by WithIDMapper.
+func (fs *OS) Lchown(name string, uid, gid int) error {
+	name, err := fixPath("lchown", name)
+	if err != nil {
+		return err
+	}
+
+	uid, gid, ok := fs.mapIDs(uid, gid)
+	if !ok {
+		_, err := os.Lstat(name)
+		if pe, isPath := err.(*os.PathError); isPath {
+			pe.Op = "lchown"
+		}
+		return err
+	}
+
+	return os.Lchown(name, uid, gid)
+}
+
+// Chown implements billy.Change, mapping the owner as set by WithIDMapper.
+func (fs *OS) Chown(name string, uid, gid int) error {
+	name, err := fixPath("chown", name)
+	if err != nil {
+		return err
+	}
+
+	uid, gid, ok := fs.mapIDs(uid, gid)
+	if !ok {
+		_, err := os.Stat(name)
+		if pe, isPath := err.(*os.PathError); isPath {
+			pe.Op = "chown"
+		}
+		return err
+	}
+
+	return os.Chown(name, uid, gid)
+}
+
+func (fs *OS) mapIDs(uid, gid int) (int, int, bool) {
+	if fs.ids == nil {
+		return uid, gid, true
+	}
+
+	return fs.ids(uid, gid)
+}
+
+// Chtimes implements billy.Change.
+func (fs *OS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	name, err := fixPath("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Getxattr implements billy.Xattrer. Extended attributes are only supported
+// on Linux; on other platforms billy.ErrNotSupported is returned.
+func (fs *OS) Getxattr(name, attr string) ([]byte, error) {
+	return getxattr(name, attr)
+}
+
+// Setxattr implements billy.Xattrer.
+func (fs *OS) Setxattr(name, attr string, value []byte) error {
+	return setxattr(name, attr, value)
+}
+
+// Listxattr implements billy.Xattrer.
+func (fs *OS) Listxattr(name string) ([]string, error) {
+	return listxattr(name)
+}
+
+// Removexattr implements billy.Xattrer.
+func (fs *OS) Removexattr(name, attr string) error {
+	return removexattr(name, attr)
+}
+
+// Exchange atomically swaps the entries at a and b, which must both exist.
+// It is only supported on Linux, through renameat2(2) with RENAME_EXCHANGE;
+// on other platforms billy.ErrNotSupported is returned.
+func (fs *OS) Exchange(a, b string) error {
+	return exchange(a, b)
+}
+
+// CopyFile copies the regular file src to dst, creating it with the
+// permission bits of src or truncating it. The copy shares the blocks of src
+// where the filesystem supports it, through the FICLONE ioctl on Linux or
+// clonefile(2) on macOS when dst doesn't exist; otherwise the data is copied
+// by the kernel where possible, with copy_file_range(2) on Linux. It is used
+// by util.CopyFile.
+func (fs *OS) CopyFile(src, dst string) error {
+	src, err := fixPath("copy", src)
+	if err != nil {
+		return err
+	}
+	dst, err = fixPath("copy", dst)
+	if err != nil {
+		return err
+	}
+
+	// Checked before opening, as opening a FIFO blocks.
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return &os.PathError{Op: "copy", Path: src, Err: billy.ErrNotSupported}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := fs.createDir(dst); err != nil {
+		return err
+	}
+
+	if err := cloneFile(in, dst, fi.Mode().Perm()); err != billy.ErrNotSupported {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err1 := out.Close(); err == nil {
+		err = err1
+	}
+
+	return err
+}
+
+// SyncDir implements billy.DirSyncer, calling fsync(2) on the directory. It is
+// a no-op on Windows, where directories can't be synced.
+func (fs *OS) SyncDir(path string) error {
+	path, err := fixPath("syncdir", path)
+	if err != nil {
+		return err
+	}
+
+	return syncDir(path)
+}
+
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Prefetch implements billy.Prefetcher, with posix_fadvise(2) and
+// POSIX_FADV_WILLNEED on Linux, which starts reading the range into the page
+// cache. It is a no-op on the other platforms.
+func (fs *OS) Prefetch(path string, off, length int64) error {
+	path, err := fixPath("prefetch", path)
+	if err != nil {
+		return err
+	}
+
+	return prefetch(path, off, length)
+}
+
+// Watch implements billy.Watcher, with inotify(7) on Linux and by scanning
+// the tree every watch.DefaultInterval on other platforms.
+func (fs *OS) Watch(path string) (<-chan billy.Event, func()) {
+	return watchPath(fs, path)
+}
+
 // Capabilities implements the Capable interface.
 func (fs *OS) Capabilities() billy.Capability {
-	return billy.DefaultCapabilities
+	caps := platformCapabilities(runtime.GOOS)
+	if fs.sandboxed {
+		caps |= billy.SandboxCapability
+	}
+
+	return caps
+}
+
+// platformCapabilities returns the capabilities of the os filesystem on goos.
+// File systems are assumed case-insensitive on the platforms where they are
+// by default, even though it can be configured otherwise.
+func platformCapabilities(goos string) billy.Capability {
+	caps := billy.DefaultCapabilities |
+		billy.ChangeCapability |
+		billy.SyncCapability |
+		billy.ConcurrentCapability |
+		xattrCapability
+
+	switch goos {
+	case "plan9":
+		// Plan 9 has neither links nor advisory locks, see file.Lock.
+		return caps &^ billy.LockCapability
+	case "windows", "darwin", "ios":
+		caps |= billy.CaseInsensitiveCapability
+	}
+
+	return caps | billy.SymlinkCapability | billy.HardlinkCapability
 }
 
 // file is a wrapper for an os.File which adds support for file locking.
 type file struct {
 	*os.File
 	m sync.Mutex
+	// name is the name the file was opened with, before fixPath.
+	name string
+	// anonymous is true for the files created with O_TMPFILE, and temp for
+	// the named ones standing in for them, see WithAnonymousTempFiles.
+	anonymous, temp bool
+}
+
+func newFile(f *os.File, name string) *file {
+	return &file{File: f, name: name}
+}
+
+// Name returns the name of the file as presented to Open.
+func (f *file) Name() string {
+	return f.name
+}
+
+// Mmap implements billy.Mmapper, mapping the file in memory read-only. The
+// mapping is shared, the writes made to the file being visible through it.
+func (f *file) Mmap() ([]byte, func() error, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return []byte{}, func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, &os.PathError{Op: "mmap", Path: f.name, Err: syscall.EFBIG}
+	}
+
+	b, release, err := mmap(f.File, int(size))
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "mmap", Path: f.name, Err: err}
+	}
+
+	return b, release, nil
+}
+
+// ReadFrom implements io.ReaderFrom, letting the kernel move the data where
+// possible, with copy_file_range(2) from another file or splice(2) from a
+// socket on Linux.
+func (f *file) ReadFrom(r io.Reader) (int64, error) {
+	return f.File.ReadFrom(r)
+}
+
+// WriteTo implements io.WriterTo, handing the underlying os.File to w when it
+// is an io.ReaderFrom, so that sockets can use sendfile(2) and files
+// copy_file_range(2).
+func (f *file) WriteTo(w io.Writer) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(f.File)
+	}
+
+	return io.Copy(w, struct{ io.Reader }{f.File})
+}
+
+// Allocate implements billy.Allocator with fallocate(2) on Linux and
+// F_PREALLOCATE on darwin. Where the storage can't be reserved, the file is
+// only extended to off+length.
+func (f *file) Allocate(off, length int64) error {
+	if off < 0 || length <= 0 {
+		return &os.PathError{Op: "allocate", Path: f.name, Err: syscall.EINVAL}
+	}
+
+	if err := allocate(f.File, off, length); err != nil {
+		return &os.PathError{Op: "allocate", Path: f.name, Err: err}
+	}
+
+	return nil
+}
+
+// Seek implements billy.File, supporting billy.SeekData and billy.SeekHole.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if whence == billy.SeekData || whence == billy.SeekHole {
+		return seekHole(f.File, offset, whence)
+	}
+
+	return f.File.Seek(offset, whence)
+}
+
+// extend grows f to size, if it is smaller.
+func extend(f *os.File, size int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() >= size {
+		return nil
+	}
+
+	return f.Truncate(size)
+}
+
+// Publish implements billy.Publisher for the temporary files created with
+// WithAnonymousTempFiles, linking the anonymous ones with linkat(2) and
+// renaming the others.
+func (f *file) Publish(name string) error {
+	if !f.anonymous && !f.temp {
+		return billy.ErrNotSupported
+	}
+
+	path, err := fixPath("publish", name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), defaultDirectoryMode); err != nil {
+		return err
+	}
+
+	if f.anonymous {
+		if err := linkAnonymous(f.File, path); err != nil {
+			return &os.LinkError{Op: "publish", Old: f.name, New: name, Err: err}
+		}
+	} else {
+		// Link, unlike Rename, fails if path exists.
+		if err := os.Link(f.File.Name(), path); err != nil {
+			return err
+		}
+		if err := os.Remove(f.File.Name()); err != nil {
+			return err
+		}
+	}
+
+	f.name, f.anonymous, f.temp = name, false, false
+	return nil
 }
@@ -12,10 +12,11 @@ import (
 var globalMemFs = memfs.New()
 
 // Default Filesystem representing the root of in-memory filesystem for a
-// js/wasm environment.
+// js/wasm environment. Its content is lost when the page is reloaded, see
+// the browserfs package for a filesystem persisted by the browser.
 var Default = memfs.New()
 
-// New returns a new OS filesystem.
-func New(baseDir string) billy.Filesystem {
+// New returns a new OS filesystem. The options don't apply to js/wasm.
+func New(baseDir string, opts ...Option) billy.Filesystem {
 	return chroot.New(Default, Default.Join("/", baseDir))
 }
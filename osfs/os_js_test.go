@@ -43,5 +43,6 @@ func (s *OSSuite) TestCapabilities(c *C) {
 	c.Assert(ok, Equals, true)
 
 	caps := billy.Capabilities(s.FS)
-	c.Assert(caps, Equals, billy.DefaultCapabilities&^billy.LockCapability)
+	c.Assert(caps, Equals, billy.DefaultCapabilities&^billy.LockCapability|billy.XattrCapability|
+		billy.SymlinkCapability|billy.ChangeCapability|billy.SyncCapability)
 }
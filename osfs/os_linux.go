@@ -0,0 +1,106 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+const xattrCapability = billy.XattrCapability
+
+func exchange(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	if err != nil {
+		return &os.LinkError{Op: "exchange", Old: a, New: b, Err: err}
+	}
+
+	return nil
+}
+
+func renameNoReplace(from, to string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, from, unix.AT_FDCWD, to, unix.RENAME_NOREPLACE)
+	switch err {
+	case nil:
+		return nil
+	case unix.EINVAL, unix.ENOSYS:
+		// The kernel, or the filesystem, doesn't support the flag.
+		return emulateRenameNoReplace(from, to)
+	}
+
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: err}
+}
+
+func xattrError(op, name string, err error) error {
+	if err == unix.ENODATA {
+		err = billy.ErrNoXattr
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+func getxattr(name, attr string) ([]byte, error) {
+	for {
+		size, err := unix.Getxattr(name, attr, nil)
+		if err != nil {
+			return nil, xattrError("getxattr", name, err)
+		}
+
+		// The value may grow between both calls, in which case ERANGE is
+		// returned and the size queried again.
+		buf := make([]byte, size)
+		n, err := unix.Getxattr(name, attr, buf)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, xattrError("getxattr", name, err)
+		}
+
+		return buf[:n], nil
+	}
+}
+
+func setxattr(name, attr string, value []byte) error {
+	if err := unix.Setxattr(name, attr, value, 0); err != nil {
+		return xattrError("setxattr", name, err)
+	}
+
+	return nil
+}
+
+func listxattr(name string) ([]string, error) {
+	for {
+		size, err := unix.Listxattr(name, nil)
+		if err != nil {
+			return nil, xattrError("listxattr", name, err)
+		}
+		if size == 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, size)
+		n, err := unix.Listxattr(name, buf)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, xattrError("listxattr", name, err)
+		}
+
+		return strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00"), nil
+	}
+}
+
+func removexattr(name, attr string) error {
+	if err := unix.Removexattr(name, attr); err != nil {
+		return xattrError("removexattr", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *OSSuite) TestXattr(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	x := s.FS.(billy.Xattrer)
+	err = x.Setxattr("foo", "user.billy", []byte("value"))
+	if errors.Is(err, unix.ENOTSUP) {
+		c.Skip("user extended attributes not supported by the temporary directory")
+	}
+	c.Assert(err, IsNil)
+
+	v, err := x.Getxattr("foo", "user.billy")
+	c.Assert(err, IsNil)
+	c.Assert(string(v), Equals, "value")
+
+	attrs, err := x.Listxattr("foo")
+	c.Assert(err, IsNil)
+	c.Assert(attrs, DeepEquals, []string{"user.billy"})
+
+	c.Assert(x.Removexattr("foo", "user.billy"), IsNil)
+	_, err = x.Getxattr("foo", "user.billy")
+	c.Assert(errors.Is(err, billy.ErrNoXattr), Equals, true)
+
+	_, err = x.Getxattr("../foo", "user.billy")
+	c.Assert(err, Equals, billy.ErrCrossedBoundary)
+}
+
+func (s *OSSuite) TestDenySpecialFiles(c *C) {
+	c.Assert(unix.Mkfifo(filepath.Join(s.path, "fifo"), 0o600), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(s.path, "file"), nil, 0o600), IsNil)
+	c.Assert(os.Symlink("fifo", filepath.Join(s.path, "link")), IsNil)
+
+	fs := New(s.path, WithDenySpecialFiles())
+
+	for _, name := range []string{"fifo", "link"} {
+		_, err := fs.Open(name)
+		c.Assert(errors.Is(err, billy.ErrSpecialFileDenied), Equals, true, Commentf("%s: %v", name, err))
+
+		_, err = fs.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0o600)
+		c.Assert(errors.Is(err, billy.ErrSpecialFileDenied), Equals, true, Commentf("%s: %v", name, err))
+
+		_, err = fs.Stat(name)
+		c.Assert(errors.Is(err, billy.ErrSpecialFileDenied), Equals, true, Commentf("%s: %v", name, err))
+	}
+
+	fi, err := fs.Lstat("fifo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeNamedPipe, Not(Equals), os.FileMode(0))
+
+	f, err := fs.Open("file")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = fs.Create("new")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(fs.Remove("fifo"), IsNil)
+}
+
+func (s *OSSuite) TestWatch(c *C) {
+	c.Assert(s.FS.MkdirAll("dir", 0o755), IsNil)
+
+	ch, stop := s.FS.(billy.Watcher).Watch("dir")
+	defer stop()
+
+	expect := func(want ...billy.Event) {
+		timeout := time.After(5 * time.Second)
+		for len(want) > 0 {
+			select {
+			case e := <-ch:
+				c.Assert(e.Err, IsNil)
+				if e == want[0] {
+					want = want[1:]
+				}
+			case <-timeout:
+				c.Fatalf("timeout waiting for %v", want)
+			}
+		}
+	}
+
+	// The new directories are watched once reported.
+	c.Assert(s.FS.MkdirAll("dir/sub", 0o755), IsNil)
+	expect(billy.Event{Name: "dir/sub", Op: billy.EventCreate})
+
+	f, err := s.FS.Create("dir/sub/file")
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(s.FS.Rename("dir/sub/file", "dir/moved"), IsNil)
+	c.Assert(s.FS.Remove("dir/moved"), IsNil)
+
+	expect(
+		billy.Event{Name: "dir/sub/file", Op: billy.EventCreate},
+		billy.Event{Name: "dir/sub/file", Op: billy.EventWrite},
+		billy.Event{Name: "dir/sub/file", Op: billy.EventRename},
+		billy.Event{Name: "dir/moved", Op: billy.EventCreate},
+		billy.Event{Name: "dir/moved", Op: billy.EventRemove},
+	)
+
+	stop()
+	for range ch {
+	}
+}
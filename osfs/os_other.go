@@ -0,0 +1,43 @@
+//go:build !linux && !js
+// +build !linux,!js
+
+package osfs
+
+import (
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/watch"
+)
+
+const xattrCapability billy.Capability = 0
+
+func landlock(root string) error {
+	return billy.ErrNotSupported
+}
+
+func exchange(a, b string) error {
+	return billy.ErrNotSupported
+}
+
+func renameNoReplace(from, to string) error {
+	return emulateRenameNoReplace(from, to)
+}
+
+func getxattr(name, attr string) ([]byte, error) {
+	return nil, billy.ErrNotSupported
+}
+
+func setxattr(name, attr string, value []byte) error {
+	return billy.ErrNotSupported
+}
+
+func listxattr(name string) ([]string, error) {
+	return nil, billy.ErrNotSupported
+}
+
+func removexattr(name, attr string) error {
+	return billy.ErrNotSupported
+}
+
+func watchPath(fs *OS, path string) (<-chan billy.Event, func()) {
+	return watch.Poll(fs, path, watch.DefaultInterval)
+}
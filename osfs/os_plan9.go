@@ -23,10 +23,29 @@ func (f *file) Lock() error {
 	return nil
 }
 
+// RLock is a no-op, as Lock.
+func (f *file) RLock() error {
+	return nil
+}
+
+// TryLock always succeeds, as Lock.
+func (f *file) TryLock() (bool, error) {
+	return true, nil
+}
+
+// TryRLock always succeeds, as Lock.
+func (f *file) TryRLock() (bool, error) {
+	return true, nil
+}
+
 func (f *file) Unlock() error {
 	return nil
 }
 
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, syscall.EPLAN9
+}
+
 func rename(from, to string) error {
 	// If from and to are in different directories, copy the file
 	// since Plan 9 does not support cross-directory rename.
@@ -16,6 +16,36 @@ func (f *file) Lock() error {
 	return unix.Flock(int(f.File.Fd()), unix.LOCK_EX)
 }
 
+// RLock implements billy.RWLocker with flock(2).
+func (f *file) RLock() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	return unix.Flock(int(f.File.Fd()), unix.LOCK_SH)
+}
+
+// TryLock implements billy.RWLocker.
+func (f *file) TryLock() (bool, error) {
+	return f.tryFlock(unix.LOCK_EX)
+}
+
+// TryRLock implements billy.RWLocker.
+func (f *file) TryRLock() (bool, error) {
+	return f.tryFlock(unix.LOCK_SH)
+}
+
+func (f *file) tryFlock(how int) (bool, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	err := unix.Flock(int(f.File.Fd()), how|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
 func (f *file) Unlock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
@@ -26,3 +56,12 @@ func (f *file) Unlock() error {
 func rename(from, to string) error {
 	return os.Rename(from, to)
 }
+
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	b, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, func() error { return unix.Munmap(b) }, nil
+}
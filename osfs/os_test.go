@@ -3,14 +3,21 @@
 package osfs
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
 
 	. "gopkg.in/check.v1"
 )
@@ -50,10 +57,377 @@ func (s *OSSuite) TestOpenDoesNotCreateDir(c *C) {
 	c.Assert(os.IsNotExist(err), Equals, true)
 }
 
+func (s *OSSuite) TestSync(c *C) {
+	f, err := s.FS.Create("dir/foo")
+	c.Assert(err, IsNil)
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.(billy.Syncer).Sync(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(Default.SyncDir(filepath.Join(s.path, "dir")), IsNil)
+	c.Assert(Default.SyncDir(filepath.Join(s.path, "missing")), NotNil)
+}
+
+func (s *OSSuite) TestFileStat(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	fi, err := f.(billy.FileStater).Stat()
+	c.Assert(err, IsNil)
+	c.Assert(fi.Name(), Equals, "foo")
+	c.Assert(fi.Size(), Equals, int64(3))
+}
+
+func (s *OSSuite) TestChange(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	ch := s.FS.(billy.Change)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Assert(ch.Chtimes("foo", mtime, mtime), IsNil)
+
+	fi, err := s.FS.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), Equals, true)
+
+	if runtime.GOOS != "windows" {
+		c.Assert(ch.Chmod("foo", 0600), IsNil)
+		fi, err = s.FS.Stat("foo")
+		c.Assert(err, IsNil)
+		c.Assert(fi.Mode(), Equals, os.FileMode(0600))
+	}
+
+	c.Assert(ch.Chmod("../foo", 0600), Equals, billy.ErrCrossedBoundary)
+}
+
+func (s *OSSuite) TestLink(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.FS.(billy.Linker).Link("foo", "dir/bar"), IsNil)
+
+	foo, err := s.FS.Stat("foo")
+	c.Assert(err, IsNil)
+	bar, err := s.FS.Stat("dir/bar")
+	c.Assert(err, IsNil)
+	c.Assert(os.SameFile(foo, bar), Equals, true)
+
+	c.Assert(s.FS.(billy.Linker).Link("foo", "../bar"), Equals, billy.ErrCrossedBoundary)
+}
+
+func (s *OSSuite) TestCopyFile(c *C) {
+	src := filepath.Join(s.path, "foo")
+	c.Assert(ioutil.WriteFile(src, []byte("foo"), 0600), IsNil)
+
+	dst := filepath.Join(s.path, "dir", "bar")
+	c.Assert(Default.CopyFile(src, dst), IsNil)
+	c.Assert(Default.CopyFile(src, dst), IsNil)
+
+	b, err := ioutil.ReadFile(dst)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+
+	if runtime.GOOS != "windows" {
+		fi, err := os.Stat(dst)
+		c.Assert(err, IsNil)
+		c.Assert(fi.Mode().Perm(), Equals, os.FileMode(0600))
+	}
+
+	err = Default.CopyFile(s.path, dst)
+	c.Assert(err, NotNil)
+}
+
+func (s *OSSuite) TestReadFromWriteTo(c *C) {
+	src, err := s.FS.Create("src")
+	c.Assert(err, IsNil)
+	defer src.Close()
+	_, err = src.Write([]byte("foobar"))
+	c.Assert(err, IsNil)
+	_, err = src.Seek(3, io.SeekStart)
+	c.Assert(err, IsNil)
+
+	dst, err := s.FS.Create("dst")
+	c.Assert(err, IsNil)
+	defer dst.Close()
+
+	_, ok := dst.(io.ReaderFrom)
+	c.Assert(ok, Equals, true)
+	_, ok = src.(io.WriterTo)
+	c.Assert(ok, Equals, true)
+
+	n, err := io.Copy(dst, src)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(3))
+
+	_, err = dst.Seek(0, io.SeekStart)
+	c.Assert(err, IsNil)
+	var buf strings.Builder
+	n, err = io.Copy(&buf, dst)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(3))
+	c.Assert(buf.String(), Equals, "bar")
+}
+
+func (s *OSSuite) TestMmap(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	b, release, err := f.(billy.Mmapper).Mmap()
+	c.Assert(err, IsNil)
+	c.Assert(b, HasLen, 0)
+	c.Assert(release(), IsNil)
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	b, release, err = f.(billy.Mmapper).Mmap()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+	c.Assert(release(), IsNil)
+}
+
+func (s *OSSuite) TestDescriptor(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	d, ok := f.(billy.Descriptor)
+	c.Assert(ok, Equals, true)
+
+	conn, err := d.SyscallConn()
+	c.Assert(err, IsNil)
+	var fd uintptr
+	c.Assert(conn.Control(func(v uintptr) { fd = v }), IsNil)
+
+	c.Assert(fd, Not(Equals), ^uintptr(0))
+	c.Assert(d.Fd(), Equals, fd)
+}
+func (s *OSSuite) TestAllocate(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	c.Assert(f.(billy.Allocator).Allocate(0, 1<<20), IsNil)
+	c.Assert(f.(billy.Allocator).Allocate(0, 10), IsNil)
+
+	fi, err := s.FS.Stat("foo")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(1<<20))
+
+	c.Assert(f.(billy.Allocator).Allocate(-1, 10), NotNil)
+}
+
+func (s *OSSuite) TestSeekHole(c *C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	n, err := f.Seek(1, billy.SeekData)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(1))
+
+	n, err = f.Seek(0, billy.SeekHole)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, int64(3))
+
+	_, err = f.Seek(3, billy.SeekData)
+	c.Assert(errors.Is(err, syscall.ENXIO), Equals, true)
+}
+func (s *OSSuite) TestPublish(c *C) {
+	fs := New(s.path, WithAnonymousTempFiles())
+
+	f, err := fs.TempFile("tmp", "foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "exists", nil, 0644), IsNil)
+	err = f.(billy.Publisher).Publish("exists")
+	c.Assert(errors.Is(err, os.ErrExist), Equals, true)
+
+	c.Assert(f.(billy.Publisher).Publish("dir/bar"), IsNil)
+	c.Assert(f.Name(), Equals, filepath.Join("dir", "bar"))
+
+	b, err := util.ReadFile(fs, "dir/bar")
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "foo")
+
+	// Nothing is left behind in the temporary directory.
+	fis, err := fs.ReadDir("tmp")
+	c.Assert(err, IsNil)
+	c.Assert(fis, HasLen, 0)
+
+	f, err = s.FS.TempFile("tmp", "foo")
+	c.Assert(err, IsNil)
+	defer f.Close()
+	c.Assert(f.(billy.Publisher).Publish("baz"), Equals, billy.ErrNotSupported)
+}
+func (s *OSSuite) TestStatFS(c *C) {
+	info, err := s.FS.(billy.StatFS).StatFS("")
+	if err == billy.ErrNotSupported {
+		c.Skip("statfs not supported")
+	}
+	c.Assert(err, IsNil)
+	c.Assert(info.Total > 0, Equals, true)
+	c.Assert(info.Free <= info.Total, Equals, true)
+	c.Assert(info.Used <= info.Total, Equals, true)
+
+	_, err = s.FS.(billy.StatFS).StatFS("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+func (s *OSSuite) TestRWLock(c *C) {
+	f1, err := s.FS.Create("foo")
+	c.Assert(err, IsNil)
+	defer f1.Close()
+	f2, err := s.FS.Open("foo")
+	c.Assert(err, IsNil)
+	defer f2.Close()
+	l1, l2 := f1.(billy.RWLocker), f2.(billy.RWLocker)
+
+	c.Assert(l1.RLock(), IsNil)
+	ok, err := l2.TryRLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(f2.Unlock(), IsNil)
+
+	ok, err = l2.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, false)
+
+	c.Assert(f1.Unlock(), IsNil)
+	ok, err = l2.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+	c.Assert(f2.Unlock(), IsNil)
+}
+
+
+
+
+
 func (s *OSSuite) TestCapabilities(c *C) {
 	_, ok := s.FS.(billy.Capable)
 	c.Assert(ok, Equals, true)
 
 	caps := billy.Capabilities(s.FS)
-	c.Assert(caps, Equals, billy.AllCapabilities)
+	c.Assert(caps, Equals, platformCapabilities(runtime.GOOS))
+	c.Assert(caps&xattrCapability, Equals, xattrCapability)
+}
+
+func (s *OSSuite) TestPlatformCapabilities(c *C) {
+	common := billy.ChangeCapability | billy.SyncCapability | billy.ConcurrentCapability | xattrCapability
+	links := billy.SymlinkCapability | billy.HardlinkCapability
+
+	c.Assert(platformCapabilities("linux"), Equals, billy.DefaultCapabilities|common|links)
+	c.Assert(platformCapabilities("windows"), Equals, billy.DefaultCapabilities|common|links|billy.CaseInsensitiveCapability)
+	c.Assert(platformCapabilities("darwin"), Equals, billy.DefaultCapabilities|common|links|billy.CaseInsensitiveCapability)
+	c.Assert(platformCapabilities("plan9"), Equals, billy.DefaultCapabilities&^billy.LockCapability|common)
+}
+
+func (s *OSSuite) TestIsReservedName(c *C) {
+	for _, name := range []string{"CON", "nul", "Aux.txt", "com1.tar.gz", "LPT9", "prn ", "conout$"} {
+		c.Assert(isReservedName(name), Equals, true, Commentf("%q", name))
+	}
+
+	for _, name := range []string{"CONSOLE", "com0", "lpt10", "nul_", "xnul", ".nul", ""} {
+		c.Assert(isReservedName(name), Equals, false, Commentf("%q", name))
+	}
+}
+
+func (s *OSSuite) TestTempFileName(c *C) {
+	f, err := Default.TempFile(s.path, "prefix")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	c.Assert(filepath.Dir(f.Name()), Equals, s.path)
+	c.Assert(filepath.Base(f.Name()), Matches, "prefix.*")
+}
+
+func (s *OSSuite) TestRenameNoReplace(c *C) {
+	fs := New(s.path, WithRenameNoReplace())
+	c.Assert(util.WriteFile(fs, "foo", []byte("foo"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, "bar", []byte("bar"), 0644), IsNil)
+	c.Assert(fs.MkdirAll("dir", 0755), IsNil)
+	c.Assert(fs.MkdirAll("empty", 0755), IsNil)
+
+	err := fs.Rename("foo", "bar")
+	c.Assert(os.IsExist(err), Equals, true, Commentf("error: %v", err))
+	err = fs.Rename("dir", "empty")
+	c.Assert(os.IsExist(err), Equals, true, Commentf("error: %v", err))
+
+	data, err := util.ReadFile(fs, "bar")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "bar")
+
+	c.Assert(fs.Rename("foo", "dir/foo"), IsNil)
+	_, err = fs.Stat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+	data, err = util.ReadFile(fs, "dir/foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "foo")
+}
+
+func (s *OSSuite) TestEmulateRenameNoReplace(c *C) {
+	foo, bar := filepath.Join(s.path, "foo"), filepath.Join(s.path, "bar")
+	c.Assert(os.WriteFile(foo, []byte("foo"), 0644), IsNil)
+	c.Assert(os.WriteFile(bar, []byte("bar"), 0644), IsNil)
+
+	err := emulateRenameNoReplace(foo, bar)
+	c.Assert(os.IsExist(err), Equals, true, Commentf("error: %v", err))
+
+	c.Assert(emulateRenameNoReplace(foo, filepath.Join(s.path, "qux")), IsNil)
+	_, err = os.Lstat(foo)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *OSSuite) TestOpenDir(c *C) {
+	fs := New(s.path)
+
+	// More entries than read at once.
+	want := make(map[string]bool)
+	for i := 0; i < 2*dirBatch+1; i++ {
+		name := fmt.Sprintf("%04d", i)
+		c.Assert(util.WriteFile(fs, fs.Join("dir", name), nil, 0644), IsNil)
+		want[name] = true
+	}
+	c.Assert(fs.MkdirAll("dir/sub", 0755), IsNil)
+	want["sub"] = true
+
+	d, err := billy.OpenDir(fs, "dir")
+	c.Assert(err, IsNil)
+	_, ok := d.(*dirIter)
+	c.Assert(ok, Equals, true)
+
+	got := make(map[string]bool)
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		c.Assert(e.IsDir(), Equals, e.Name() == "sub")
+		got[e.Name()] = true
+	}
+	c.Assert(d.Close(), IsNil)
+	c.Assert(got, DeepEquals, want)
+
+	_, err = fs.(billy.DirIterator).OpenDir("dir/0000")
+	c.Assert(errors.Is(err, syscall.ENOTDIR), Equals, true)
+
+	_, err = fs.(billy.DirIterator).OpenDir("missing")
+	c.Assert(os.IsNotExist(err), Equals, true)
 }
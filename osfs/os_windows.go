@@ -5,7 +5,9 @@ package osfs
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -27,16 +29,45 @@ var (
 )
 
 const (
-	lockfileExclusiveLock = 0x2
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
 )
 
 func (f *file) Lock() error {
+	return f.lockFileEx(lockfileExclusiveLock)
+}
+
+// RLock implements billy.RWLocker with LockFileEx.
+func (f *file) RLock() error {
+	return f.lockFileEx(0)
+}
+
+// TryLock implements billy.RWLocker.
+func (f *file) TryLock() (bool, error) {
+	return f.tryLockFileEx(lockfileExclusiveLock)
+}
+
+// TryRLock implements billy.RWLocker.
+func (f *file) TryRLock() (bool, error) {
+	return f.tryLockFileEx(0)
+}
+
+func (f *file) tryLockFileEx(flags uintptr) (bool, error) {
+	err := f.lockFileEx(flags | lockfileFailImmediately)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (f *file) lockFileEx(flags uintptr) error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
 	var overlapped windows.Overlapped
 	// err is always non-nil as per sys/windows semantics.
-	ret, _, err := lockFileExProc.Call(f.File.Fd(), lockfileExclusiveLock, 0, 0xFFFFFFFF, 0,
+	ret, _, err := lockFileExProc.Call(f.File.Fd(), flags, 0, 0xFFFFFFFF, 0,
 		uintptr(unsafe.Pointer(&overlapped)))
 	runtime.KeepAlive(&overlapped)
 	if ret == 0 {
@@ -60,3 +91,66 @@ func (f *file) Unlock() error {
 func rename(from, to string) error {
 	return os.Rename(from, to)
 }
+
+// maxPath is the length above which paths are switched to the extended-length
+// form. It is MAX_PATH minus the 12 characters of an 8.3 file name, the limit
+// for directories.
+const maxPath = 248
+
+// fixPath returns the name to use for the os calls on name. It refuses the
+// paths with a component naming a device, and returns the extended-length
+// form, prefixed with \\?\, of the paths too long for the Win32 API.
+func fixPath(op, name string) (string, error) {
+	vol := filepath.VolumeName(name)
+	if strings.HasPrefix(vol, `\\?\`) || strings.HasPrefix(vol, `\\.\`) {
+		return name, nil
+	}
+
+	for _, elem := range strings.FieldsFunc(name[len(vol):], isSlash) {
+		if isReservedName(elem) {
+			return "", &os.PathError{Op: op, Path: name, Err: ErrReservedName}
+		}
+	}
+
+	if len(name) < maxPath {
+		return name, nil
+	}
+
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name, nil
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		// UNC path, \\server\share\name.
+		return `\\?\UNC\` + abs[2:], nil
+	}
+
+	return `\\?\` + abs, nil
+}
+
+func isSlash(r rune) bool {
+	return r == '\\' || r == '/'
+}
+
+func mmap(f *os.File, size int) ([]byte, func() error, error) {
+	h, err := windows.CreateFileMapping(windows.Handle(f.Fd()), nil, windows.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The view keeps the mapping alive.
+	defer windows.CloseHandle(h)
+
+	addr, err := windows.MapViewOfFile(h, windows.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var b []byte
+	hdr := (*struct {
+		data     uintptr
+		len, cap int
+	})(unsafe.Pointer(&b))
+	hdr.data, hdr.len, hdr.cap = addr, size, size
+
+	return b, func() error { return windows.UnmapViewOfFile(addr) }, nil
+}
@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package osfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *OSSuite) TestReservedName(c *C) {
+	for _, name := range []string{"nul", "dir/CON.txt", "aux/file"} {
+		_, err := s.FS.Create(name)
+		c.Assert(errors.Is(err, ErrReservedName), Equals, true, Commentf("%q: %v", name, err))
+	}
+
+	_, err := s.FS.Stat("com1")
+	c.Assert(errors.Is(err, ErrReservedName), Equals, true)
+}
+
+func (s *OSSuite) TestLongPath(c *C) {
+	dir := strings.Repeat("d", 100)
+	name := filepath.Join(dir, dir, dir, "file")
+
+	f, err := s.FS.Create(name)
+	c.Assert(err, IsNil)
+	c.Assert(f.Name(), Equals, name)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	fi, err := s.FS.Stat(name)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, int64(3))
+
+	c.Assert(s.FS.Rename(name, name+".new"), IsNil)
+	c.Assert(s.FS.Remove(name+".new"), IsNil)
+	_, err = os.Stat(filepath.Join(s.path, dir))
+	c.Assert(err, IsNil)
+}
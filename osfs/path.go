@@ -0,0 +1,29 @@
+package osfs
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrReservedName is returned on Windows for the paths naming a device, such
+// as CON, NUL or COM1, which Windows reserves in every directory and with any
+// extension, so that reading or writing "nul.txt" doesn't silently go to the
+// null device.
+var ErrReservedName = errors.New("reserved device name")
+
+// isReservedName reports whether the path component name is a device name
+// on Windows. Trailing spaces and extensions are ignored, as Windows does.
+func isReservedName(name string) bool {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+
+	switch strings.ToUpper(strings.TrimRight(name, " ")) {
+	case "CON", "PRN", "AUX", "NUL", "CONIN$", "CONOUT$",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+//go:build !js
+// +build !js
+
+package osfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// fileMode returns the mode of a file created with perm.
+func (p *perms) fileMode(perm os.FileMode) os.FileMode {
+	if p.private {
+		return 0o600
+	}
+
+	return perm &^ p.umask
+}
+
+// createMode returns the mode of the files created by Create.
+func (p *perms) createMode() os.FileMode {
+	if p.file != 0 {
+		return p.file
+	}
+
+	return defaultCreateMode
+}
+
+// dirMode returns the mode of the directories created.
+func (p *perms) dirMode() os.FileMode {
+	switch {
+	case p.private:
+		return 0o700
+	case p.dir != 0:
+		return p.dir &^ p.umask
+	}
+
+	return defaultDirectoryMode &^ p.umask
+}
+
+// openFile opens name as os.OpenFile does, giving the file exactly its mode
+// if it is created and the modes are explicit.
+func (fs *OS) openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if flag&os.O_CREATE == 0 || !fs.perms.explicit {
+		return os.OpenFile(name, flag, perm)
+	}
+
+	mode := fs.perms.fileMode(perm)
+	f, err := os.OpenFile(name, flag|os.O_EXCL, mode)
+	if err == nil {
+		if err := f.Chmod(mode); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	if flag&os.O_EXCL != 0 || !os.IsExist(err) {
+		return nil, err
+	}
+
+	// The file exists, unless it was removed since, or is a dangling
+	// symlink, whose target is then created with the mode masked by the
+	// umask of the process.
+	f, err = os.OpenFile(name, flag&^os.O_CREATE, mode)
+	if os.IsNotExist(err) {
+		return os.OpenFile(name, flag, mode)
+	}
+
+	return f, err
+}
+
+// mkdirAll creates the directory path and its missing parents, with the
+// modes of fs.
+func (fs *OS) mkdirAll(path string) error {
+	mode := fs.perms.dirMode()
+	if !fs.perms.explicit {
+		return os.MkdirAll(path, mode)
+	}
+
+	var missing []string
+	for p := path; ; p = filepath.Dir(p) {
+		fi, err := os.Stat(p)
+		if err == nil {
+			if !fi.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		missing = append(missing, p)
+		if parent := filepath.Dir(p); parent == p {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		err := os.Mkdir(missing[i], mode)
+		if os.IsExist(err) {
+			// Created concurrently, with the mode of someone else.
+			continue
+		}
+		if err == nil {
+			err = os.Chmod(missing[i], mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
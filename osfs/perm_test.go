@@ -0,0 +1,129 @@
+//go:build !js && !windows && !plan9
+// +build !js,!windows,!plan9
+
+package osfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *OSSuite) mode(c *C, name string) os.FileMode {
+	fi, err := os.Stat(filepath.Join(s.path, name))
+	c.Assert(err, IsNil)
+	return fi.Mode().Perm()
+}
+
+func (s *OSSuite) TestUmask(c *C) {
+	defer syscall.Umask(syscall.Umask(0o077))
+
+	fs := New(s.path, WithUmask(0o022), WithDefaultFileMode(0o664), WithDefaultDirMode(0o775))
+
+	f, err := fs.Create("dir/sub/created")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(util.WriteFile(fs, "dir/written", nil, 0o666), IsNil)
+	c.Assert(fs.MkdirAll("made/deep", 0o700), IsNil)
+
+	c.Assert(s.mode(c, "dir"), Equals, os.FileMode(0o755))
+	c.Assert(s.mode(c, "dir/sub"), Equals, os.FileMode(0o755))
+	c.Assert(s.mode(c, "dir/sub/created"), Equals, os.FileMode(0o644))
+	c.Assert(s.mode(c, "dir/written"), Equals, os.FileMode(0o644))
+	c.Assert(s.mode(c, "made"), Equals, os.FileMode(0o755))
+	c.Assert(s.mode(c, "made/deep"), Equals, os.FileMode(0o755))
+
+	// The existing files keep their mode.
+	c.Assert(os.Chmod(filepath.Join(s.path, "dir/written"), 0o600), IsNil)
+	c.Assert(util.WriteFile(fs, "dir/written", []byte("x"), 0o666), IsNil)
+	c.Assert(s.mode(c, "dir/written"), Equals, os.FileMode(0o600))
+}
+
+func (s *OSSuite) TestUmaskZero(c *C) {
+	defer syscall.Umask(syscall.Umask(0o077))
+
+	fs := New(s.path, WithUmask(0))
+	c.Assert(util.WriteFile(fs, "dir/file", nil, 0o666), IsNil)
+
+	c.Assert(s.mode(c, "dir"), Equals, os.FileMode(0o755))
+	c.Assert(s.mode(c, "dir/file"), Equals, os.FileMode(0o666))
+}
+
+func (s *OSSuite) TestPrivate(c *C) {
+	defer syscall.Umask(syscall.Umask(0))
+
+	fs := New(s.path, WithPrivate())
+	c.Assert(util.WriteFile(fs, "secrets/token", nil, 0o755), IsNil)
+	c.Assert(fs.MkdirAll("keys", 0o777), IsNil)
+
+	c.Assert(s.mode(c, "secrets"), Equals, os.FileMode(0o700))
+	c.Assert(s.mode(c, "secrets/token"), Equals, os.FileMode(0o600))
+	c.Assert(s.mode(c, "keys"), Equals, os.FileMode(0o700))
+}
+
+func (s *OSSuite) TestDefaultFileMode(c *C) {
+	defer syscall.Umask(syscall.Umask(0))
+
+	fs := New(s.path, WithDefaultFileMode(0o640))
+	f, err := fs.Create("file")
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	c.Assert(s.mode(c, "file"), Equals, os.FileMode(0o640))
+}
+
+// owner returns the owner of the file at name, failing the test where it
+// can't be told.
+func (s *OSSuite) owner(c *C, name string) (int, int) {
+	fi, err := os.Lstat(filepath.Join(s.path, name))
+	c.Assert(err, IsNil)
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	c.Assert(ok, Equals, true)
+	return int(st.Uid), int(st.Gid)
+}
+
+func (s *OSSuite) TestIDMapperCurrentUser(c *C) {
+	fs := New(s.path, WithIDMapper(CurrentUser()))
+	c.Assert(util.WriteFile(fs, "file", nil, 0o644), IsNil)
+	c.Assert(fs.Symlink("file", "link"), IsNil)
+
+	ch := fs.(billy.Change)
+	c.Assert(ch.Chown("file", 12345, 23456), IsNil)
+	c.Assert(ch.Lchown("link", 12345, 23456), IsNil)
+
+	uid, gid := s.owner(c, "file")
+	c.Assert(uid, Equals, os.Getuid())
+	c.Assert(gid, Equals, os.Getgid())
+}
+
+func (s *OSSuite) TestIDMapperDrop(c *C) {
+	fs := New(s.path, WithIDMapper(DropOwnership))
+	c.Assert(util.WriteFile(fs, "file", nil, 0o644), IsNil)
+	uid, gid := s.owner(c, "file")
+
+	ch := fs.(billy.Change)
+	c.Assert(ch.Chown("file", 12345, 23456), IsNil)
+	c.Assert(ch.Lchown("file", 12345, 23456), IsNil)
+
+	newUID, newGID := s.owner(c, "file")
+	c.Assert(newUID, Equals, uid)
+	c.Assert(newGID, Equals, gid)
+
+	err := ch.Chown("missing", 0, 0)
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(err.(*os.PathError).Op, Equals, "chown")
+}
+
+func (s *OSSuite) TestIDOffset(c *C) {
+	m := IDOffset(100000, 200000)
+
+	uid, gid, ok := m(1000, -1)
+	c.Assert(ok, Equals, true)
+	c.Assert(uid, Equals, 101000)
+	c.Assert(gid, Equals, -1)
+}
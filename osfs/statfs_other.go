@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !windows && !js
+// +build !linux,!darwin,!freebsd,!windows,!js
+
+package osfs
+
+import (
+	"github.com/go-git/go-billy/v5"
+)
+
+func statfs(path string) (*billy.FSInfo, error) {
+	return nil, billy.ErrNotSupported
+}
@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package osfs
+
+import (
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+func statfs(path string) (*billy.FSInfo, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+
+	bsize := uint64(st.Bsize)
+	info := &billy.FSInfo{
+		Total:  uint64(st.Blocks) * bsize,
+		Used:   (uint64(st.Blocks) - uint64(st.Bfree)) * bsize,
+		Inodes: uint64(st.Files),
+	}
+
+	// Bavail and Ffree are signed on FreeBSD, where they go negative once
+	// the space reserved to root is being used.
+	if st.Bavail > 0 {
+		info.Free = uint64(st.Bavail) * bsize
+	}
+	if st.Ffree > 0 {
+		info.FreeInodes = uint64(st.Ffree)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package osfs
+
+import (
+	"golang.org/x/sys/windows"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// statfs reports the space of the volume holding path, the inodes not being
+// counted on Windows.
+func statfs(path string) (*billy.FSInfo, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var avail, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &free); err != nil {
+		return nil, err
+	}
+
+	return &billy.FSInfo{Total: total, Free: avail, Used: total - free}, nil
+}
@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// openAnonymous creates an unnamed file in dir with O_TMPFILE, returning
+// billy.ErrNotSupported if the kernel, or the filesystem, lacks it.
+func openAnonymous(dir string) (*os.File, error) {
+	fd, err := unix.Open(dir, unix.O_RDWR|unix.O_TMPFILE|unix.O_CLOEXEC, 0600)
+	switch err {
+	case nil:
+		return os.NewFile(uintptr(fd), dir), nil
+	case unix.EISDIR, unix.EOPNOTSUPP:
+		// EISDIR is returned by the kernels older than 3.11, which take
+		// O_TMPFILE for O_DIRECTORY.
+		return nil, billy.ErrNotSupported
+	default:
+		return nil, err
+	}
+}
+
+// linkAnonymous gives a name to a file created by openAnonymous. The file is
+// linked through /proc, as AT_EMPTY_PATH requires CAP_DAC_READ_SEARCH.
+func linkAnonymous(f *os.File, name string) error {
+	proc := "/proc/self/fd/" + strconv.Itoa(int(f.Fd()))
+	return unix.Linkat(unix.AT_FDCWD, proc, unix.AT_FDCWD, name, unix.AT_SYMLINK_FOLLOW)
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !js
+// +build !linux,!js
+
+package osfs
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+func openAnonymous(dir string) (*os.File, error) {
+	return nil, billy.ErrNotSupported
+}
+
+func linkAnonymous(f *os.File, name string) error {
+	return billy.ErrNotSupported
+}
@@ -0,0 +1,219 @@
+//go:build linux
+// +build linux
+
+package osfs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+const (
+	inotifyDirMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_ATTRIB |
+		unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+		unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+	inotifyFileMask = unix.IN_MODIFY | unix.IN_ATTRIB |
+		unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+)
+
+// inotify watches a tree with inotify(7). As inotify isn't recursive, every
+// directory of the tree is watched, the ones created or moved into the tree
+// being added as they are reported.
+type inotify struct {
+	root string
+	f    *os.File
+	ch   chan billy.Event
+	done chan struct{}
+
+	m      sync.Mutex
+	fd     int
+	closed bool
+	paths  map[int]string
+}
+
+func watchPath(fs *OS, path string) (<-chan billy.Event, func()) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return watchError(os.NewSyscallError("inotify_init1", err))
+	}
+
+	w := &inotify{
+		root:  filepath.Clean(path),
+		f:     os.NewFile(uintptr(fd), "inotify"),
+		ch:    make(chan billy.Event),
+		done:  make(chan struct{}),
+		fd:    fd,
+		paths: make(map[int]string),
+	}
+
+	if err := w.add(w.root, false); err != nil {
+		w.f.Close()
+		return watchError(err)
+	}
+
+	go w.run()
+
+	var once sync.Once
+	return w.ch, func() { once.Do(w.stop) }
+}
+
+func (w *inotify) stop() {
+	close(w.done)
+
+	w.m.Lock()
+	w.closed = true
+	w.f.Close()
+	w.m.Unlock()
+}
+
+// add watches path and, if it is a directory, the directories below it. If
+// created is true, the entries found below path are reported as created, as
+// they may have been created before their directory was watched.
+func (w *inotify) add(path string, created bool) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	mask := uint32(inotifyFileMask)
+	if fi.IsDir() {
+		mask = inotifyDirMask
+	}
+
+	w.m.Lock()
+	if w.closed {
+		w.m.Unlock()
+		return nil
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, path, mask|unix.IN_DONT_FOLLOW)
+	if err == nil {
+		w.paths[wd] = path
+	}
+	w.m.Unlock()
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	if !fi.IsDir() {
+		return nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+
+	for _, e := range entries {
+		name := filepath.Join(path, e.Name())
+		if created && !w.send(billy.Event{Name: name, Op: billy.EventCreate}) {
+			return nil
+		}
+		if e.IsDir() {
+			w.add(name, created)
+		}
+	}
+
+	return nil
+}
+
+func (w *inotify) send(e billy.Event) bool {
+	select {
+	case w.ch <- e:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *inotify) run() {
+	defer close(w.ch)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				w.send(billy.Event{Err: err})
+			}
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(raw.Len)]
+			off += unix.SizeofInotifyEvent + int(raw.Len)
+
+			if !w.handle(int(raw.Wd), raw.Mask, string(bytes.TrimRight(name, "\x00"))) {
+				return
+			}
+		}
+	}
+}
+
+// handle reports the event of the watch descriptor wd, returning false once
+// the watch is stopped.
+func (w *inotify) handle(wd int, mask uint32, name string) bool {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		return w.send(billy.Event{Op: billy.EventOverflow})
+	}
+
+	w.m.Lock()
+	path, ok := w.paths[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.paths, wd)
+	}
+	w.m.Unlock()
+	if !ok {
+		return true
+	}
+
+	if name != "" {
+		path = filepath.Join(path, name)
+	} else if path != w.root {
+		// The changes of the directories themselves are reported by their
+		// parent.
+		return true
+	}
+
+	var op billy.EventOp
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		op = billy.EventCreate
+	case mask&unix.IN_MODIFY != 0:
+		op = billy.EventWrite
+	case mask&unix.IN_ATTRIB != 0:
+		op = billy.EventChmod
+	case mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0:
+		op = billy.EventRemove
+	case mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0:
+		op = billy.EventRename
+	default:
+		return true
+	}
+
+	if !w.send(billy.Event{Name: path, Op: op}) {
+		return false
+	}
+
+	if op == billy.EventCreate && mask&unix.IN_ISDIR != 0 {
+		w.add(path, true)
+	}
+
+	return true
+}
+
+func watchError(err error) (<-chan billy.Event, func()) {
+	ch := make(chan billy.Event, 1)
+	ch <- billy.Event{Err: err}
+	close(ch)
+
+	return ch, func() {}
+}
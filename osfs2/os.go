@@ -25,12 +25,15 @@ limitations under the License.
 package osfs2
 
 import (
+	"errors"
 	"fmt"
 	stdfs "io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
@@ -41,6 +44,11 @@ const (
 	defaultCreateMode    = 0o666
 )
 
+// errNotAnchored is returned by the operations made relative to a descriptor
+// of the working dir when they can't be, the callers falling back to the
+// paths resolved by SecureJoin.
+var errNotAnchored = errors.New("operation not anchored to the working dir")
+
 // OS is a fs implementation based on the OS filesystem which has some
 // changes in behaviour when compared to osfs:
 //
@@ -55,15 +63,44 @@ const (
 //	a) The filename is located within the current dir.
 //	b) The dir in which filename is based, is located within the current dir.
 type OS struct {
-	workingDir string
+	workingDir   string
+	noFollow     bool
+	escapeErrors bool
+}
+
+// Option configures the filesystem returned by New.
+type Option func(*OS)
+
+// WithNoFollow makes the filesystem refuse to follow any symbolic link below
+// the working dir, instead of resolving it within the working dir. Paths
+// going through a link fail with billy.ErrSymlinkDenied, while the operations
+// acting on the link itself, such as Lstat, Readlink or Remove, still work.
+func WithNoFollow() Option {
+	return func(fs *OS) {
+		fs.noFollow = true
+	}
+}
+
+// WithEscapeErrors makes the filesystem fail with an *util.EscapeError, which
+// matches billy.ErrCrossedBoundary, when a path would escape the working dir,
+// through ".." elements or symbolic links, rather than rewriting it back
+// inside the working dir.
+func WithEscapeErrors() Option {
+	return func(fs *OS) {
+		fs.escapeErrors = true
+	}
 }
 
 // New returns a new OS filesystem using the workingDir as prefix for relative paths.
 // It also ensures that operations are kept within that working dir.
-func New(workingDir string) billy.Filesystem {
-	return &OS{
+func New(workingDir string, opts ...Option) billy.Filesystem {
+	fs := &OS{
 		workingDir: workingDir,
 	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+	return fs
 }
 
 func (fs *OS) Create(filename string) (billy.File, error) {
@@ -71,28 +108,55 @@ func (fs *OS) Create(filename string) (billy.File, error) {
 }
 
 func (fs *OS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
-	fn, err := fs.abs(filename)
-	if err != nil {
-		return nil, err
-	}
 	if flag&os.O_CREATE != 0 {
+		fn, err := fs.abs(filename)
+		if err != nil {
+			return nil, fs.restore(err, "open", filename)
+		}
 		if err := fs.createDir(fn); err != nil {
-			return nil, err
+			return nil, fs.restore(err, "open", filename)
 		}
 	}
 
-	f, err := os.OpenFile(fn, flag, perm)
+	f, err := fs.openFile(filename, flag, perm)
 	if err != nil {
-		return nil, err
+		return nil, fs.restore(err, "open", filename)
 	}
 	return &file{File: f}, err
 }
 
+// openFileSecureJoin opens filename once resolved with SecureJoin. It is used
+// where the kernel can't resolve paths beneath a directory itself.
+func (fs *OS) openFileSecureJoin(filename string, flag int, perm os.FileMode) (*os.File, error) {
+	fn, err := fs.abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !fs.noFollow {
+		return os.OpenFile(fn, flag, perm)
+	}
+
+	// The link may have been created since it was checked.
+	f, err := os.OpenFile(fn, flag|oNoFollow, perm)
+	if err != nil && isNoFollowError(err) {
+		return nil, &os.PathError{Op: "open", Path: fn, Err: billy.ErrSymlinkDenied}
+	}
+	return f, err
+}
+
 func (fs *OS) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := fs.readDir(path)
+	return infos, fs.restore(err, "readdir", path)
+}
+
+func (fs *OS) readDir(path string) ([]os.FileInfo, error) {
 	dir, err := fs.abs(path)
 	if err != nil {
 		return nil, err
 	}
+	if err := fs.checkResolved(dir); err != nil {
+		return nil, err
+	}
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -110,11 +174,19 @@ func (fs *OS) ReadDir(path string) ([]os.FileInfo, error) {
 }
 
 func (fs *OS) Rename(from, to string) error {
-	f, err := fs.abs(from)
+	err := fs.renameAt(from, to)
+	if err == errNotAnchored {
+		err = fs.rename(from, to)
+	}
+	return fs.restore(err, "rename", from, to)
+}
+
+func (fs *OS) rename(from, to string) error {
+	f, err := fs.absLink(from)
 	if err != nil {
 		return err
 	}
-	t, err := fs.abs(to)
+	t, err := fs.absLink(to)
 	if err != nil {
 		return err
 	}
@@ -128,11 +200,15 @@ func (fs *OS) Rename(from, to string) error {
 }
 
 func (fs *OS) MkdirAll(path string, perm os.FileMode) error {
-	dir, err := fs.abs(path)
-	if err != nil {
-		return err
+	err := fs.mkdirAllAt(path, perm)
+	if err == errNotAnchored {
+		var dir string
+		dir, err = fs.abs(path)
+		if err == nil {
+			err = os.MkdirAll(dir, perm)
+		}
 	}
-	return os.MkdirAll(dir, perm)
+	return fs.restore(err, "mkdir", path)
 }
 
 func (fs *OS) Open(filename string) (billy.File, error) {
@@ -140,36 +216,48 @@ func (fs *OS) Open(filename string) (billy.File, error) {
 }
 
 func (fs *OS) Stat(filename string) (os.FileInfo, error) {
-	filename, err := fs.abs(filename)
+	fn, err := fs.abs(filename)
 	if err != nil {
-		return nil, err
+		return nil, fs.restore(err, "stat", filename)
+	}
+	if err := fs.checkResolved(fn); err != nil {
+		return nil, fs.restore(err, "stat", filename)
 	}
-	return os.Stat(filename)
+	fi, err := os.Stat(fn)
+	return fi, fs.restore(err, "stat", filename)
 }
 
 func (fs *OS) Remove(filename string) error {
-	fn, err := fs.abs(filename)
-	if err != nil {
-		return err
+	err := fs.removeAt(filename)
+	if err == errNotAnchored {
+		var fn string
+		fn, err = fs.absLink(filename)
+		if err == nil {
+			err = os.Remove(fn)
+		}
 	}
-	return os.Remove(fn)
+	return fs.restore(err, "remove", filename)
 }
 
 // TempFile creates a temporary file. If dir is empty, the file
 // will be created within the OS Temporary dir. If dir is provided
 // it must descend from the current working dir.
 func (fs *OS) TempFile(dir, prefix string) (billy.File, error) {
+	fn := dir
 	if dir != "" {
 		var err error
-		dir, err = fs.abs(dir)
+		fn, err = fs.abs(dir)
 		if err != nil {
-			return nil, err
+			return nil, fs.restore(err, "createtemp", dir)
 		}
 	}
 
-	f, err := os.CreateTemp(dir, prefix)
+	f, err := os.CreateTemp(fn, prefix)
 	if err != nil {
-		return nil, err
+		if dir == "" {
+			return nil, err
+		}
+		return nil, fs.restore(err, "createtemp", dir)
 	}
 	return &file{File: f}, nil
 }
@@ -179,54 +267,169 @@ func (fs *OS) Join(elem ...string) string {
 }
 
 func (fs *OS) RemoveAll(path string) error {
-	dir, err := fs.abs(path)
-	if err != nil {
-		return err
+	dir, err := fs.absLink(path)
+	if err == nil {
+		err = os.RemoveAll(dir)
 	}
-	return os.RemoveAll(dir)
+	return fs.restore(err, "removeall", path)
 }
 
 func (fs *OS) Symlink(target, link string) error {
+	if err := fs.symlinkAt(target, link); err != errNotAnchored {
+		return err
+	}
+
 	ln, err := fs.abs(link)
+	if err == nil {
+		// MkdirAll for containing dir.
+		err = fs.createDir(ln)
+	}
+	if err != nil {
+		return fs.restore(err, "symlink", link)
+	}
+	err = os.Symlink(target, ln)
+	if le, ok := err.(*os.LinkError); ok {
+		return &os.LinkError{Op: le.Op, Old: target, New: link, Err: le.Err}
+	}
+	return err
+}
+
+func (fs *OS) Lstat(filename string) (os.FileInfo, error) {
+	fn := filepath.Clean(filename)
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(fs.workingDir, fn)
+	}
+	if ok, err := fs.insideWorkingDirEval(fn); !ok {
+		return nil, fs.restore(err, "lstat", filename)
+	}
+	fi, err := os.Lstat(fn)
+	return fi, fs.restore(err, "lstat", filename)
+}
+
+func (fs *OS) Readlink(link string) (string, error) {
+	fn := link
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Clean(filepath.Join(fs.workingDir, fn))
+	}
+	if ok, err := fs.insideWorkingDirEval(fn); !ok {
+		return "", fs.restore(err, "readlink", link)
+	}
+	target, err := os.Readlink(fn)
+	return target, fs.restore(err, "readlink", link)
+}
+
+// Link implements billy.Linker.
+func (fs *OS) Link(oldname, newname string) error {
+	return fs.restore(fs.link(oldname, newname), "link", oldname, newname)
+}
+
+func (fs *OS) link(oldname, newname string) error {
+	o, err := fs.abs(oldname)
+	if err != nil {
+		return err
+	}
+	n, err := fs.abs(newname)
 	if err != nil {
 		return err
 	}
 	// MkdirAll for containing dir.
-	if err := fs.createDir(ln); err != nil {
+	if err := fs.createDir(n); err != nil {
 		return err
 	}
-	return os.Symlink(target, ln)
+	return os.Link(o, n)
 }
 
-func (fs *OS) Lstat(filename string) (os.FileInfo, error) {
-	filename = filepath.Clean(filename)
-	if !filepath.IsAbs(filename) {
-		filename = filepath.Join(fs.workingDir, filename)
+// Chmod implements billy.Change.
+func (fs *OS) Chmod(name string, mode os.FileMode) error {
+	fn, err := fs.abs(name)
+	if err == nil {
+		err = os.Chmod(fn, mode)
 	}
-	if ok, err := fs.insideWorkingDirEval(filename); !ok {
-		return nil, err
+	return fs.restore(err, "chmod", name)
+}
+
+// Lchown implements billy.Change. Symbolic links are not followed.
+func (fs *OS) Lchown(name string, uid, gid int) error {
+	fn := filepath.Clean(name)
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(fs.workingDir, fn)
+	}
+	if ok, err := fs.insideWorkingDirEval(fn); !ok {
+		return fs.restore(err, "lchown", name)
 	}
-	return os.Lstat(filename)
+	return fs.restore(os.Lchown(fn, uid, gid), "lchown", name)
 }
 
-func (fs *OS) Readlink(link string) (string, error) {
-	if !filepath.IsAbs(link) {
-		link = filepath.Clean(filepath.Join(fs.workingDir, link))
+// Chown implements billy.Change.
+func (fs *OS) Chown(name string, uid, gid int) error {
+	fn, err := fs.abs(name)
+	if err == nil {
+		err = os.Chown(fn, uid, gid)
 	}
-	if ok, err := fs.insideWorkingDirEval(link); !ok {
-		return "", err
+	return fs.restore(err, "chown", name)
+}
+
+// Chtimes implements billy.Change.
+func (fs *OS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fn, err := fs.abs(name)
+	if err == nil {
+		err = os.Chtimes(fn, atime, mtime)
+	}
+	return fs.restore(err, "chtimes", name)
+}
+
+// SyncDir implements billy.DirSyncer, calling fsync(2) on the directory. It is
+// a no-op on Windows, where directories can't be synced.
+func (fs *OS) SyncDir(path string) error {
+	return fs.restore(fs.syncDir(path), "sync", path)
+}
+
+func (fs *OS) syncDir(path string) error {
+	dir, err := fs.abs(path)
+	if err != nil {
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
 	}
-	return os.Readlink(link)
+	return err
 }
 
 // Chroot returns a new OS filesystem, with the working dir set to the
 // result of joining the provided path with the underlying working dir.
 func (fs *OS) Chroot(path string) (billy.Filesystem, error) {
-	joined, err := util.SecureJoin(fs.workingDir, path)
+	joined, err := fs.abs(path)
 	if err != nil {
 		return nil, err
 	}
-	return New(joined), nil
+	return &OS{workingDir: joined, noFollow: fs.noFollow, escapeErrors: fs.escapeErrors}, nil
+}
+
+// Capabilities implements the Capable interface. File systems are assumed
+// case-insensitive on the platforms where they are by default.
+func (fs *OS) Capabilities() billy.Capability {
+	caps := billy.DefaultCapabilities |
+		billy.ChangeCapability |
+		billy.SyncCapability |
+		billy.ConcurrentCapability
+
+	switch runtime.GOOS {
+	case "plan9":
+		return caps &^ billy.LockCapability
+	case "windows", "darwin", "ios":
+		caps |= billy.CaseInsensitiveCapability
+	}
+
+	return caps | billy.SymlinkCapability | billy.HardlinkCapability
 }
 
 // Root returns the current working dir of the billy.Filesystem.
@@ -260,14 +463,159 @@ func (fs *OS) createDir(fullpath string) error {
 // Note that if filename is a symlink, the returned address will be the target of the
 // symlink.
 func (fs *OS) abs(filename string) (string, error) {
+	if fs.noFollow {
+		if err := fs.lexicalEscape(filename); err != nil {
+			return "", err
+		}
+		name := fs.lexicalAbs(filename)
+		if err := fs.denySymlinks(name, true); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+
 	if filename == fs.workingDir {
 		filename = string(filepath.Separator)
 	} else if cw := fs.workingDir + string(filepath.Separator); strings.HasPrefix(filename, cw) {
 		filename = strings.TrimPrefix(filename, cw)
 	}
+	if fs.escapeErrors {
+		return util.SecureJoinVFSStrict(fs.workingDir, filename, nil)
+	}
 	return util.SecureJoin(fs.workingDir, filename)
 }
 
+// absLink is like abs, except that with the no-follow policy the last
+// element of filename may be a symbolic link, for the operations acting on
+// the link itself.
+func (fs *OS) absLink(filename string) (string, error) {
+	if !fs.noFollow {
+		return fs.abs(filename)
+	}
+
+	if err := fs.lexicalEscape(filename); err != nil {
+		return "", err
+	}
+	name := fs.lexicalAbs(filename)
+	if err := fs.denySymlinks(name, false); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// lexicalAbs joins filename to the working dir without evaluating symlinks,
+// its ".." elements being clamped to the working dir.
+func (fs *OS) lexicalAbs(filename string) string {
+	if filename == fs.workingDir {
+		return fs.workingDir
+	}
+	if cw := fs.workingDir + string(filepath.Separator); strings.HasPrefix(filename, cw) {
+		filename = strings.TrimPrefix(filename, cw)
+	}
+	filename = filename[len(filepath.VolumeName(filename)):]
+
+	return filepath.Join(fs.workingDir, filepath.Clean(string(filepath.Separator)+filename))
+}
+
+// lexicalEscape returns an *util.EscapeError if the ".." elements of
+// filename climb above the working dir and WithEscapeErrors was given.
+func (fs *OS) lexicalEscape(filename string) error {
+	if !fs.escapeErrors || filename == fs.workingDir {
+		return nil
+	}
+	name := filename
+	if cw := fs.workingDir + string(filepath.Separator); strings.HasPrefix(name, cw) {
+		name = strings.TrimPrefix(name, cw)
+	}
+	name = strings.TrimLeft(name[len(filepath.VolumeName(name)):], string(filepath.Separator))
+
+	rel := filepath.Clean(name)
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	return &util.EscapeError{
+		Root:      fs.workingDir,
+		Path:      filename,
+		Component: "..",
+		Target:    filepath.Dir(fs.workingDir),
+	}
+}
+
+// denySymlinks returns billy.ErrSymlinkDenied if one of the elements of name
+// below the working dir is a symbolic link. The last element is only checked
+// if last is true. Checking stops at the first element which doesn't exist.
+func (fs *OS) denySymlinks(name string, last bool) error {
+	rel, err := filepath.Rel(fs.workingDir, name)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	elems := strings.Split(rel, string(filepath.Separator))
+	if !last {
+		elems = elems[:len(elems)-1]
+	}
+
+	p := fs.workingDir
+	for _, e := range elems {
+		p = filepath.Join(p, e)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return &os.PathError{Op: "follow", Path: p, Err: billy.ErrSymlinkDenied}
+		}
+	}
+	return nil
+}
+
+// restore rewrites err, returned for the operation op on the given names, so
+// that it is an *os.PathError or *os.LinkError holding the names rather than
+// the absolute paths built from them. The paths below the working dir which
+// aren't derived from the names, such as the ones of the links followed, are
+// made relative to it.
+func (fs *OS) restore(err error, op string, names ...string) error {
+	switch e := err.(type) {
+	case nil, *util.EscapeError:
+		return err
+	case *os.PathError:
+		return &os.PathError{Op: e.Op, Path: fs.visiblePath(e.Path, names), Err: e.Err}
+	case *os.LinkError:
+		return &os.LinkError{
+			Op:  e.Op,
+			Old: fs.visiblePath(e.Old, names),
+			New: fs.visiblePath(e.New, names),
+			Err: e.Err,
+		}
+	}
+
+	if len(names) == 2 {
+		return &os.LinkError{Op: op, Old: names[0], New: names[1], Err: err}
+	}
+	return &os.PathError{Op: op, Path: names[0], Err: err}
+}
+
+func (fs *OS) visiblePath(path string, names []string) string {
+	for _, name := range names {
+		abs := fs.lexicalAbs(name)
+		if path == abs {
+			return name
+		}
+		if strings.HasPrefix(path, abs+string(filepath.Separator)) {
+			return filepath.Join(name, strings.TrimPrefix(path, abs))
+		}
+	}
+
+	rel, err := filepath.Rel(fs.workingDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
 // insideWorkingDir checks whether filename is located within
 // the fs.workingDir.
 func (fs *OS) insideWorkingDir(filename string) (bool, error) {
@@ -284,6 +632,11 @@ func (fs *OS) insideWorkingDir(filename string) (bool, error) {
 // a dir that is within the fs.workingDir, by evaluating any symlinks
 // that either filename or fs.workingDir may contain.
 func (fs *OS) insideWorkingDirEval(filename string) (bool, error) {
+	if fs.noFollow {
+		if err := fs.denySymlinks(fs.lexicalAbs(filename), false); err != nil {
+			return false, err
+		}
+	}
 	dir, err := filepath.EvalSymlinks(filepath.Dir(filename))
 	if dir == "" || os.IsNotExist(err) {
 		dir = filepath.Dir(filename)
@@ -0,0 +1,26 @@
+//go:build !linux && !js
+// +build !linux,!js
+
+package osfs2
+
+import "os"
+
+// The operations below are only anchored to a descriptor of the working dir
+// on Linux, through openat2(2). Elsewhere the paths resolved by SecureJoin
+// are used.
+
+func (fs *OS) renameAt(from, to string) error {
+	return errNotAnchored
+}
+
+func (fs *OS) removeAt(name string) error {
+	return errNotAnchored
+}
+
+func (fs *OS) mkdirAllAt(path string, perm os.FileMode) error {
+	return errNotAnchored
+}
+
+func (fs *OS) symlinkAt(target, link string) error {
+	return errNotAnchored
+}
@@ -0,0 +1,329 @@
+//go:build linux
+// +build linux
+
+package osfs2
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+var (
+	openat2Once      sync.Once
+	openat2Supported bool
+)
+
+// hasOpenat2 reports whether openat2(2) is available, which requires Linux
+// 5.6 and may be blocked by seccomp filters.
+func hasOpenat2() bool {
+	openat2Once.Do(func() {
+		fd, err := unix.Openat2(unix.AT_FDCWD, ".", &unix.OpenHow{
+			Flags: unix.O_PATH | unix.O_CLOEXEC,
+		})
+		if err == nil {
+			unix.Close(fd)
+			openat2Supported = true
+		}
+	})
+
+	return openat2Supported
+}
+
+// openFile opens filename with openat2(2), relative to the working dir and
+// with RESOLVE_BENEATH, so that the kernel resolves the path and refuses to
+// leave the working dir, without the window between the resolution and the
+// open of SecureJoin.
+//
+// The paths for which the kernel returns EXDEV go through SecureJoin, which
+// re-roots the absolute symlinks and the ones climbing above the working dir
+// instead of rejecting them, unless WithEscapeErrors was given.
+func (fs *OS) openFile(filename string, flag int, perm os.FileMode) (*os.File, error) {
+	if !hasOpenat2() {
+		return fs.openFileSecureJoin(filename, flag, perm)
+	}
+	if err := fs.lexicalEscape(filename); err != nil {
+		return nil, err
+	}
+
+	rel := fs.rel(filename)
+	name := filepath.Join(fs.workingDir, rel)
+
+	how := &unix.OpenHow{
+		Flags:   uint64(flag) | unix.O_CLOEXEC | unix.O_LARGEFILE,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	if fs.noFollow {
+		how.Resolve |= unix.RESOLVE_NO_SYMLINKS
+	}
+	if flag&os.O_CREATE != 0 {
+		how.Mode = uint64(syscallMode(perm))
+	}
+
+	dir, err := unix.Open(fs.workingDir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: fs.workingDir, Err: err}
+	}
+	defer unix.Close(dir)
+
+	for {
+		fd, err := unix.Openat2(dir, rel, how)
+		switch {
+		case err == nil:
+			return os.NewFile(uintptr(fd), name), nil
+		case errors.Is(err, unix.EINTR), errors.Is(err, unix.EAGAIN):
+			// EAGAIN is returned when a rename raced with the resolution.
+			continue
+		case errors.Is(err, unix.ELOOP) && fs.noFollow:
+			return nil, &os.PathError{Op: "open", Path: name, Err: billy.ErrSymlinkDenied}
+		case errors.Is(err, unix.EXDEV), errors.Is(err, unix.ENOSYS):
+			return fs.openFileSecureJoin(filename, flag, perm)
+		default:
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+}
+
+// rel returns filename relative to the working dir, with its ".." elements
+// lexically clamped to the working dir, as SecureJoin does.
+func (fs *OS) rel(filename string) string {
+	if cw := fs.workingDir + string(filepath.Separator); strings.HasPrefix(filename, cw) {
+		filename = strings.TrimPrefix(filename, cw)
+	} else if filename == fs.workingDir {
+		return "."
+	}
+
+	rel := strings.TrimPrefix(filepath.Clean(string(filepath.Separator)+filename), string(filepath.Separator))
+	if rel == "" {
+		return "."
+	}
+
+	return rel
+}
+
+// syscallMode returns the syscall-specific mode bits of perm, as os.OpenFile
+// does.
+func syscallMode(perm os.FileMode) uint32 {
+	mode := uint32(perm.Perm())
+	if perm&os.ModeSetuid != 0 {
+		mode |= unix.S_ISUID
+	}
+	if perm&os.ModeSetgid != 0 {
+		mode |= unix.S_ISGID
+	}
+	if perm&os.ModeSticky != 0 {
+		mode |= unix.S_ISVTX
+	}
+
+	return mode
+}
+
+// beneath opens rel, relative to the working dir, as an O_PATH descriptor
+// with openat2(2) and RESOLVE_BENEATH. The working dir is opened for each
+// call, so that a working dir replaced meanwhile is followed as the paths
+// resolved by SecureJoin are.
+func (fs *OS) beneath(rel string, flag int) (int, error) {
+	root, err := unix.Open(fs.workingDir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	defer unix.Close(root)
+
+	how := &unix.OpenHow{
+		Flags:   uint64(flag) | unix.O_PATH | unix.O_CLOEXEC,
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_MAGICLINKS,
+	}
+	if fs.noFollow {
+		how.Resolve |= unix.RESOLVE_NO_SYMLINKS
+	}
+
+	for {
+		fd, err := unix.Openat2(root, rel, how)
+		if errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN) {
+			continue
+		}
+		return fd, err
+	}
+}
+
+// parentAt returns a descriptor of the parent directory of rel, resolved
+// beneath the working dir, and the last element of rel, so that the
+// operation made relative to it can't be redirected outside the working dir
+// by an element of the path swapped meanwhile for a symbolic link.
+//
+// errNotAnchored is returned when the parent can't be resolved that way,
+// and when the last element is a symbolic link, which SecureJoin follows:
+// the fallback reports the errors, and handles the links, as it did before.
+func (fs *OS) parentAt(rel string) (int, string, error) {
+	if rel == "." {
+		return -1, "", errNotAnchored
+	}
+
+	dir, err := fs.beneath(filepath.Dir(rel), unix.O_DIRECTORY)
+	if err != nil {
+		return -1, "", errNotAnchored
+	}
+
+	base := filepath.Base(rel)
+	var st unix.Stat_t
+	if err := unix.Fstatat(dir, base, &st, unix.AT_SYMLINK_NOFOLLOW); err == nil && st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		unix.Close(dir)
+		return -1, "", errNotAnchored
+	}
+
+	return dir, base, nil
+}
+
+// anchoredRel returns name relative to the working dir if the operations on
+// it can be anchored to a descriptor of the working dir, or errNotAnchored.
+func (fs *OS) anchoredRel(name string) (string, error) {
+	if !hasOpenat2() || fs.lexicalEscape(name) != nil {
+		return "", errNotAnchored
+	}
+
+	return fs.rel(name), nil
+}
+
+// renameAt renames from to to with renameat(2), relative to descriptors of
+// their parents, creating the parents of to first.
+func (fs *OS) renameAt(from, to string) error {
+	f, err := fs.anchoredRel(from)
+	if err != nil {
+		return err
+	}
+	t, err := fs.anchoredRel(to)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.mkdirAllRel(filepath.Dir(t), defaultDirectoryMode); err != nil {
+		return err
+	}
+
+	fromDir, fromBase, err := fs.parentAt(f)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fromDir)
+
+	toDir, toBase, err := fs.parentAt(t)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(toDir)
+
+	if err := unix.Renameat(fromDir, fromBase, toDir, toBase); err != nil {
+		return &os.LinkError{
+			Op:  "rename",
+			Old: filepath.Join(fs.workingDir, f),
+			New: filepath.Join(fs.workingDir, t),
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// removeAt removes name with unlinkat(2), relative to a descriptor of its
+// parent, trying it as a directory when it isn't a file, as os.Remove does.
+func (fs *OS) removeAt(name string) error {
+	rel, err := fs.anchoredRel(name)
+	if err != nil {
+		return err
+	}
+
+	dir, base, err := fs.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dir)
+
+	err = unix.Unlinkat(dir, base, 0)
+	if err == nil {
+		return nil
+	}
+	rmdirErr := unix.Unlinkat(dir, base, unix.AT_REMOVEDIR)
+	if rmdirErr == nil {
+		return nil
+	}
+	if rmdirErr != unix.ENOTDIR {
+		err = rmdirErr
+	}
+	return &os.PathError{Op: "remove", Path: filepath.Join(fs.workingDir, rel), Err: err}
+}
+
+// mkdirAllAt creates the directory path, along with its missing parents,
+// with mkdirat(2) relative to descriptors of their parents.
+func (fs *OS) mkdirAllAt(path string, perm os.FileMode) error {
+	rel, err := fs.anchoredRel(path)
+	if err != nil {
+		return err
+	}
+
+	return fs.mkdirAllRel(rel, perm)
+}
+
+func (fs *OS) mkdirAllRel(rel string, perm os.FileMode) error {
+	if rel == "." {
+		return nil
+	}
+	if fd, err := fs.beneath(rel, unix.O_DIRECTORY); err == nil {
+		unix.Close(fd)
+		return nil
+	}
+
+	if err := fs.mkdirAllRel(filepath.Dir(rel), perm); err != nil {
+		return err
+	}
+
+	dir, base, err := fs.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dir)
+
+	err = unix.Mkdirat(dir, base, syscallMode(perm))
+	if errors.Is(err, unix.EEXIST) {
+		// Created meanwhile, or not a directory.
+		var st unix.Stat_t
+		if err = unix.Fstatat(dir, base, &st, 0); err == nil && st.Mode&unix.S_IFMT != unix.S_IFDIR {
+			err = unix.ENOTDIR
+		}
+	}
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: filepath.Join(fs.workingDir, rel), Err: err}
+	}
+	return nil
+}
+
+// symlinkAt creates link, pointing to target, with symlinkat(2) relative to
+// a descriptor of its parent, created first if missing.
+func (fs *OS) symlinkAt(target, link string) error {
+	rel, err := fs.anchoredRel(link)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.mkdirAllRel(filepath.Dir(rel), defaultDirectoryMode); err != nil {
+		if err == errNotAnchored {
+			return err
+		}
+		return fs.restore(err, "symlink", link)
+	}
+
+	dir, base, err := fs.parentAt(rel)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dir)
+
+	if err := unix.Symlinkat(target, dir, base); err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: link, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+//go:build linux
+// +build linux
+
+package osfs2
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/onsi/gomega"
+)
+
+// withOpenat2 runs f with openat2(2) enabled, when the kernel supports it,
+// and disabled.
+func withOpenat2(t *testing.T, f func(t *testing.T)) {
+	supported := hasOpenat2()
+	t.Cleanup(func() { openat2Supported = supported })
+
+	for _, enabled := range []bool{true, false} {
+		name := "openat2"
+		if !enabled {
+			name = "securejoin"
+		} else if !supported {
+			t.Logf("openat2 not supported, skipping")
+			continue
+		}
+
+		openat2Supported = enabled
+		t.Run(name, f)
+	}
+}
+
+func TestOpenFileResolution(t *testing.T) {
+	withOpenat2(t, func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		dir := t.TempDir()
+		outside := t.TempDir()
+
+		g.Expect(os.WriteFile(filepath.Join(outside, "secret"), []byte("outside"), 0o600)).To(gomega.Succeed())
+		g.Expect(os.MkdirAll(filepath.Join(dir, "sub", "secret"), 0o700)).To(gomega.Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("inside"), 0o600)).To(gomega.Succeed())
+		g.Expect(os.MkdirAll(filepath.Join(dir, outside), 0o700)).To(gomega.Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, outside, "secret"), []byte("re-rooted"), 0o600)).To(gomega.Succeed())
+
+		g.Expect(os.Symlink("file", filepath.Join(dir, "rel"))).To(gomega.Succeed())
+		g.Expect(os.Symlink(filepath.Join(dir, "file"), filepath.Join(dir, "abs-inside"))).To(gomega.Succeed())
+		g.Expect(os.Symlink(outside, filepath.Join(dir, "abs-outside"))).To(gomega.Succeed())
+		g.Expect(os.Symlink("../../../../../../../"+outside, filepath.Join(dir, "sub", "up"))).To(gomega.Succeed())
+
+		fs := New(dir)
+		for name, want := range map[string]string{
+			"file":                     "inside",
+			"../../file":               "inside",
+			filepath.Join(dir, "file"): "inside",
+			"rel":                      "inside",
+			"abs-inside":               "inside",
+			"abs-outside/secret":       "re-rooted",
+			"sub/up/secret":            "re-rooted",
+			"sub/../../../abs-inside":  "inside",
+		} {
+			f, err := fs.Open(name)
+			g.Expect(err).ToNot(gomega.HaveOccurred(), name)
+
+			b, err := io.ReadAll(f)
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(string(b)).To(gomega.Equal(want), name)
+			g.Expect(f.Close()).To(gomega.Succeed())
+		}
+
+		_, err := fs.Open("missing")
+		g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+	})
+}
+
+func TestOpenFileCreate(t *testing.T) {
+	withOpenat2(t, func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		dir := t.TempDir()
+		fs := New(dir)
+
+		f, err := fs.OpenFile("a/b/file", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(f.Name()).To(gomega.Equal(filepath.Join(dir, "a", "b", "file")))
+		g.Expect(f.Close()).To(gomega.Succeed())
+
+		fi, err := os.Stat(filepath.Join(dir, "a", "b", "file"))
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(fi.Mode().Perm() &^ 0o640).To(gomega.BeZero())
+
+		_, err = fs.OpenFile("a/b/file", os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+		g.Expect(os.IsExist(err)).To(gomega.BeTrue())
+	})
+}
+
+func TestOpenFileNoFollow(t *testing.T) {
+	withOpenat2(t, func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		dir := t.TempDir()
+
+		g.Expect(os.WriteFile(filepath.Join(dir, "file"), []byte("anything"), 0o600)).To(gomega.Succeed())
+		g.Expect(os.Symlink("file", filepath.Join(dir, "link"))).To(gomega.Succeed())
+		g.Expect(os.Symlink(".", filepath.Join(dir, "self"))).To(gomega.Succeed())
+
+		fs := New(dir, WithNoFollow())
+		for _, name := range []string{"link", "self/file"} {
+			_, err := fs.Open(name)
+			g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "Open(%q): %v", name, err)
+		}
+
+		f, err := fs.Open("file")
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(f.Close()).To(gomega.Succeed())
+	})
+}
+
+func TestAnchoredOperations(t *testing.T) {
+	withOpenat2(t, func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		dir := t.TempDir()
+		outside := t.TempDir()
+
+		g.Expect(os.Mkdir(filepath.Join(dir, "real"), 0o700)).To(gomega.Succeed())
+		g.Expect(os.Symlink("real", filepath.Join(dir, "rel"))).To(gomega.Succeed())
+		g.Expect(os.Symlink(outside, filepath.Join(dir, "abs-outside"))).To(gomega.Succeed())
+
+		fs := New(dir)
+		g.Expect(fs.MkdirAll("rel/a/b", 0o700)).To(gomega.Succeed())
+		g.Expect(fs.Symlink("target", "rel/a/c/link")).To(gomega.Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, "real", "a", "c", "file"), nil, 0o600)).To(gomega.Succeed())
+		g.Expect(fs.Rename("rel/a/c/file", "rel/d/file")).To(gomega.Succeed())
+
+		target, err := os.Readlink(filepath.Join(dir, "real", "a", "c", "link"))
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(target).To(gomega.Equal("target"))
+		_, err = os.Stat(filepath.Join(dir, "real", "d", "file"))
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+
+		if hasOpenat2() {
+			// Not falling back to SecureJoin.
+			g.Expect(fs.(*OS).mkdirAllAt("rel/e", 0o700)).To(gomega.Succeed())
+			g.Expect(fs.(*OS).removeAt("rel/e")).To(gomega.Succeed())
+		}
+
+		g.Expect(fs.Remove("rel/a/b")).To(gomega.Succeed())
+		g.Expect(os.Remove(filepath.Join(dir, "real", "a", "c", "link"))).To(gomega.Succeed())
+		g.Expect(fs.Remove("rel/a/c")).To(gomega.Succeed())
+
+		entries, err := os.ReadDir(filepath.Join(dir, "real", "a"))
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(entries).To(gomega.BeEmpty())
+
+		// The links leaving the working dir are re-rooted, as before.
+		g.Expect(fs.MkdirAll("abs-outside/x", 0o700)).To(gomega.Succeed())
+		g.Expect(fs.Symlink("target", "abs-outside/y")).To(gomega.Succeed())
+		g.Expect(os.WriteFile(filepath.Join(dir, outside, "file"), nil, 0o600)).To(gomega.Succeed())
+		g.Expect(fs.Rename("abs-outside/file", "abs-outside/z")).To(gomega.Succeed())
+		g.Expect(fs.Remove("abs-outside/x")).To(gomega.Succeed())
+
+		entries, err = os.ReadDir(outside)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(entries).To(gomega.BeEmpty())
+		_, err = os.Lstat(filepath.Join(dir, outside, "z"))
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+
+		err = fs.Remove("missing")
+		g.Expect(err).To(gomega.MatchError(&os.PathError{Op: "remove", Path: "missing", Err: syscall.ENOENT}))
+		err = fs.Rename("missing", "other")
+		g.Expect(err).To(gomega.MatchError(&os.LinkError{Op: "rename", Old: "missing", New: "other", Err: syscall.ENOENT}))
+
+		g.Expect(os.WriteFile(filepath.Join(dir, "file"), nil, 0o600)).To(gomega.Succeed())
+		err = fs.MkdirAll("file/sub", 0o700)
+		g.Expect(errors.Is(err, syscall.ENOTDIR)).To(gomega.BeTrue(), "%v", err)
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !js
+// +build !linux,!windows,!js
+
+package osfs2
+
+import "os"
+
+func (fs *OS) openFile(filename string, flag int, perm os.FileMode) (*os.File, error) {
+	return fs.openFileSecureJoin(filename, flag, perm)
+}
@@ -8,8 +8,18 @@ import (
 	"os"
 	"path/filepath"
 	"syscall"
+
+	"github.com/go-git/go-billy/v5"
 )
 
+// oNoFollow is not supported, the symbolic links are only checked before
+// opening the files.
+const oNoFollow = 0
+
+func isNoFollowError(err error) bool {
+	return false
+}
+
 func (f *file) Lock() error {
 	// Plan 9 uses a mode bit instead of explicit lock/unlock syscalls.
 	//
@@ -20,11 +30,13 @@ func (f *file) Lock() error {
 	// There is no obvious way to implement this function using the exclusive use bit.
 	// See https://golang.org/src/cmd/go/internal/lockedfile/lockedfile_plan9.go
 	// for how file locking is done by the go tool on Plan 9.
-	return nil
+	//
+	// Succeeding would let the callers believe they are mutually excluded.
+	return billy.ErrNotSupported
 }
 
 func (f *file) Unlock() error {
-	return nil
+	return billy.ErrNotSupported
 }
 
 func rename(from, to string) error {
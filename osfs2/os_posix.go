@@ -4,12 +4,21 @@
 package osfs2
 
 import (
+	"errors"
 	"os"
 	"syscall"
 
 	"golang.org/x/sys/unix"
 )
 
+const oNoFollow = syscall.O_NOFOLLOW
+
+// isNoFollowError reports whether err is returned by open(2) for a symbolic
+// link opened with O_NOFOLLOW.
+func isNoFollowError(err error) bool {
+	return errors.Is(err, syscall.ELOOP) || errors.Is(err, syscall.EMLINK)
+}
+
 func (f *file) Lock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
@@ -0,0 +1,10 @@
+//go:build !windows && !js
+// +build !windows,!js
+
+package osfs2
+
+// checkResolved is a no-op, SecureJoin already evaluates every symbolic
+// link of the path.
+func (fs *OS) checkResolved(name string) error {
+	return nil
+}
@@ -20,14 +20,17 @@ limitations under the License.
 package osfs2
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/onsi/gomega"
 )
 
@@ -248,7 +251,8 @@ func TestTempFile(t *testing.T) {
 
 	f, err = fs.TempFile("/above/cwd", "prefix")
 	g.Expect(err).To(gomega.HaveOccurred())
-	g.Expect(err.Error()).To(gomega.ContainSubstring(fmt.Sprint(dir, filepath.FromSlash("/above/cwd/prefix"))))
+	g.Expect(err.Error()).To(gomega.ContainSubstring(filepath.FromSlash("/above/cwd/prefix")))
+	g.Expect(err.Error()).ToNot(gomega.ContainSubstring(dir))
 	g.Expect(f).To(gomega.BeNil())
 
 	tempDir := os.TempDir()
@@ -259,7 +263,8 @@ func TestTempFile(t *testing.T) {
 
 	f, err = fs.TempFile(tempDir, "prefix")
 	g.Expect(err).To(gomega.HaveOccurred())
-	g.Expect(err.Error()).To(gomega.ContainSubstring(filepath.Join(dir, tempDir, "prefix")))
+	g.Expect(err.Error()).To(gomega.ContainSubstring(filepath.Join(tempDir, "prefix")))
+	g.Expect(err.Error()).ToNot(gomega.ContainSubstring(dir))
 	g.Expect(f).To(gomega.BeNil())
 }
 
@@ -274,6 +279,67 @@ func TestChroot(t *testing.T) {
 	g.Expect(f.Root()).To(gomega.Equal(filepath.Join(tmp, "test")))
 }
 
+func TestSyncDir(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	fs := New(dir)
+
+	f, err := fs.Create("dir/file")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(f.(billy.Syncer).Sync()).To(gomega.Succeed())
+	g.Expect(f.Close()).To(gomega.Succeed())
+
+	syncer, ok := fs.(billy.DirSyncer)
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(syncer.SyncDir("dir")).To(gomega.Succeed())
+	g.Expect(syncer.SyncDir("missing")).ToNot(gomega.Succeed())
+}
+
+func TestLock(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fs := New(t.TempDir())
+
+	f1, err := fs.Create("lock")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	defer f1.Close()
+	f2, err := fs.Open("lock")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	defer f2.Close()
+
+	if runtime.GOOS == "plan9" {
+		g.Expect(f1.Lock()).To(gomega.MatchError(billy.ErrNotSupported))
+		return
+	}
+
+	g.Expect(f1.Lock()).To(gomega.Succeed())
+
+	locked := make(chan error, 1)
+	go func() { locked <- f2.Lock() }()
+	g.Consistently(locked, 50*time.Millisecond).ShouldNot(gomega.Receive())
+
+	g.Expect(f1.Unlock()).To(gomega.Succeed())
+	g.Eventually(locked).Should(gomega.Receive(gomega.BeNil()))
+	g.Expect(f2.Unlock()).To(gomega.Succeed())
+}
+
+func TestChtimes(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	fs := New(dir)
+
+	f, err := fs.Create("file")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(f.Close()).To(gomega.Succeed())
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ch := fs.(billy.Change)
+	g.Expect(ch.Chtimes("file", mtime, mtime)).To(gomega.Succeed())
+
+	fi, err := fs.Stat("file")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(fi.ModTime().Equal(mtime)).To(gomega.BeTrue())
+}
+
 func TestRoot(t *testing.T) {
 	g := gomega.NewWithT(t)
 	dir := t.TempDir()
@@ -1162,3 +1228,106 @@ func notFoundError() string {
 		return "no such file or directory"
 	}
 }
+
+func TestNoFollow(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+
+	g.Expect(os.MkdirAll(filepath.Join(dir, "real"), 0o700)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "real", "file"), []byte("anything"), 0o600)).To(gomega.Succeed())
+	g.Expect(os.Symlink("real", filepath.Join(dir, "dirlink"))).To(gomega.Succeed())
+	g.Expect(os.Symlink(filepath.Join("real", "file"), filepath.Join(dir, "filelink"))).To(gomega.Succeed())
+
+	fs := New(dir, WithNoFollow())
+
+	f, err := fs.Open("real/file")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(f.Close()).To(gomega.Succeed())
+
+	for _, name := range []string{"filelink", "dirlink/file", "dirlink/new"} {
+		_, err = fs.Open(name)
+		g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "Open(%q): %v", name, err)
+	}
+
+	_, err = fs.Create("dirlink/new")
+	g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "%v", err)
+	_, err = os.Stat(filepath.Join(dir, "real", "new"))
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+
+	_, err = fs.Stat("filelink")
+	g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "%v", err)
+	_, err = fs.ReadDir("dirlink")
+	g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "%v", err)
+	_, err = fs.Chroot("dirlink")
+	g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "%v", err)
+	_, err = fs.Lstat("dirlink/file")
+	g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "%v", err)
+
+	// The links themselves can still be inspected and removed.
+	fi, err := fs.Lstat("filelink")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(fi.Mode() & os.ModeSymlink).ToNot(gomega.BeZero())
+
+	target, err := fs.Readlink("dirlink")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(target).To(gomega.Equal("real"))
+
+	g.Expect(fs.Remove("filelink")).To(gomega.Succeed())
+	mustExist(filepath.Join(dir, "real", "file"))
+
+	sub, err := fs.Chroot("real")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(os.Symlink("file", filepath.Join(dir, "real", "link"))).To(gomega.Succeed())
+	_, err = sub.Open("link")
+	g.Expect(errors.Is(err, billy.ErrSymlinkDenied)).To(gomega.BeTrue(), "%v", err)
+}
+
+func TestEscapeErrors(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	dir, err := filepath.EvalSymlinks(dir)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+
+	g.Expect(os.MkdirAll(filepath.Join(dir, "wd", "sub"), 0o700)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "secret"), []byte("anything"), 0o600)).To(gomega.Succeed())
+	g.Expect(os.Symlink("../../secret", filepath.Join(dir, "wd", "sub", "up"))).To(gomega.Succeed())
+	g.Expect(os.Symlink("sub", filepath.Join(dir, "wd", "down"))).To(gomega.Succeed())
+
+	wd := filepath.Join(dir, "wd")
+	for _, opts := range [][]Option{{WithEscapeErrors()}, {WithEscapeErrors(), WithNoFollow()}} {
+		fs := New(wd, opts...)
+
+		var escape *util.EscapeError
+		_, err = fs.Open("../secret")
+		g.Expect(errors.As(err, &escape)).To(gomega.BeTrue(), "%v", err)
+		g.Expect(escape.Component).To(gomega.Equal(".."))
+		g.Expect(escape.Target).To(gomega.Equal(dir))
+		g.Expect(errors.Is(err, billy.ErrCrossedBoundary)).To(gomega.BeTrue())
+
+		_, err = fs.Create("sub/../../new")
+		g.Expect(errors.As(err, &escape)).To(gomega.BeTrue(), "%v", err)
+		_, err = os.Stat(filepath.Join(dir, "new"))
+		g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+
+		_, err = fs.Stat("sub/../file")
+		g.Expect(os.IsNotExist(err)).To(gomega.BeTrue(), "%v", err)
+	}
+
+	fs := New(wd, WithEscapeErrors())
+	var escape *util.EscapeError
+	_, err = fs.Open("sub/up")
+	g.Expect(errors.As(err, &escape)).To(gomega.BeTrue(), "%v", err)
+	g.Expect(escape.Component).To(gomega.Equal(filepath.Join("sub", "up")))
+
+	_, err = fs.Stat("down")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+
+	sub, err := fs.Chroot("sub")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	_, err = sub.Open("../down")
+	g.Expect(errors.Is(err, billy.ErrCrossedBoundary)).To(gomega.BeTrue(), "%v", err)
+
+	// Without the option, escapes are rewritten within the working dir.
+	_, err = New(wd).Open("../secret")
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue(), "%v", err)
+}
@@ -5,47 +5,36 @@ package osfs2
 
 import (
 	"os"
-	"runtime"
-	"unsafe"
+	"path/filepath"
+	"strings"
 
+	"github.com/go-git/go-billy/v5"
 	"golang.org/x/sys/windows"
 )
 
-var (
-	kernel32DLL    = windows.NewLazySystemDLL("kernel32.dll")
-	lockFileExProc = kernel32DLL.NewProc("LockFileEx")
-	unlockFileProc = kernel32DLL.NewProc("UnlockFile")
-)
+// oNoFollow is not supported, the symbolic links are only checked before
+// opening the files.
+const oNoFollow = 0
 
-const (
-	lockfileExclusiveLock = 0x2
-)
+func isNoFollowError(err error) bool {
+	return false
+}
 
 func (f *file) Lock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
 	var overlapped windows.Overlapped
-	// err is always non-nil as per sys/windows semantics.
-	ret, _, err := lockFileExProc.Call(f.File.Fd(), lockfileExclusiveLock, 0, 0xFFFFFFFF, 0,
-		uintptr(unsafe.Pointer(&overlapped)))
-	runtime.KeepAlive(&overlapped)
-	if ret == 0 {
-		return err
-	}
-	return nil
+	return windows.LockFileEx(windows.Handle(f.File.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK,
+		0, 0xFFFFFFFF, 0xFFFFFFFF, &overlapped)
 }
 
 func (f *file) Unlock() error {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	// err is always non-nil as per sys/windows semantics.
-	ret, _, err := unlockFileProc.Call(f.File.Fd(), 0, 0, 0xFFFFFFFF, 0)
-	if ret == 0 {
-		return err
-	}
-	return nil
+	var overlapped windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(f.File.Fd()), 0, 0xFFFFFFFF, 0xFFFFFFFF, &overlapped)
 }
 
 func rename(from, to string) error {
@@ -56,3 +45,115 @@ func umask(new int) func() {
 	return func() {
 	}
 }
+
+// openFile opens filename once resolved with SecureJoin, and then checks
+// that the file opened is inside the working dir. SecureJoin only evaluates
+// the reparse points reported as symbolic links, while Windows follows
+// junctions and other name surrogates too, so the final path of the handle
+// is compared with the one of the working dir, and the files leading outside
+// of it are rejected with billy.ErrCrossedBoundary.
+//
+// When creating a file, the parent directory is checked first, so that the
+// file isn't created outside of the working dir.
+func (fs *OS) openFile(filename string, flag int, perm os.FileMode) (*os.File, error) {
+	fn, err := fs.abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := finalPathName(fs.workingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag&os.O_CREATE != 0 {
+		dir, err := finalPathName(filepath.Dir(fn))
+		if err != nil {
+			return nil, err
+		}
+		if !within(dir, root) {
+			return nil, &os.PathError{Op: "open", Path: fn, Err: billy.ErrCrossedBoundary}
+		}
+	}
+
+	f, err := os.OpenFile(fn, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	final, err := handleFinalPathName(windows.Handle(f.Fd()))
+	if err == nil && !within(final, root) {
+		err = &os.PathError{Op: "open", Path: fn, Err: billy.ErrCrossedBoundary}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// checkResolved returns billy.ErrCrossedBoundary if the existing file at
+// name, once its reparse points are followed, is outside of the working dir.
+func (fs *OS) checkResolved(name string) error {
+	root, err := finalPathName(fs.workingDir)
+	if err != nil {
+		return err
+	}
+
+	final, err := finalPathName(name)
+	if err != nil {
+		return err
+	}
+	if !within(final, root) {
+		return &os.PathError{Op: "stat", Path: name, Err: billy.ErrCrossedBoundary}
+	}
+
+	return nil
+}
+
+// finalPathName returns the path of name with every reparse point, drive
+// substitution and short name resolved.
+func finalPathName(name string) (string, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+
+	// FILE_FLAG_BACKUP_SEMANTICS is required to open directories.
+	h, err := windows.CreateFile(p, 0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer windows.CloseHandle(h)
+
+	return handleFinalPathName(h)
+}
+
+func handleFinalPathName(h windows.Handle) (string, error) {
+	buf := make([]uint16, windows.MAX_PATH)
+	for {
+		n, err := windows.GetFinalPathNameByHandle(h, &buf[0], uint32(len(buf)), 0)
+		if err != nil {
+			return "", err
+		}
+		if n < uint32(len(buf)) {
+			return windows.UTF16ToString(buf[:n]), nil
+		}
+		buf = make([]uint16, n)
+	}
+}
+
+// within reports whether path is root or inside of it. Paths are compared
+// case-insensitively, as NTFS does by default; both are final path names
+// sharing the same \\?\ prefix, either for a drive or a UNC share.
+func within(path, root string) bool {
+	root = strings.TrimSuffix(root, `\`)
+	if len(path) < len(root) || !strings.EqualFold(path[:len(root)], root) {
+		return false
+	}
+
+	return len(path) == len(root) || path[len(root)] == '\\'
+}
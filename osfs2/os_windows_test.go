@@ -0,0 +1,60 @@
+//go:build windows
+// +build windows
+
+package osfs2
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/onsi/gomega"
+)
+
+func TestJunctionOutsideWorkingDir(t *testing.T) {
+	g := gomega.NewWithT(t)
+	dir := t.TempDir()
+	outside := t.TempDir()
+
+	g.Expect(os.WriteFile(filepath.Join(outside, "secret"), []byte("outside"), 0o600)).To(gomega.Succeed())
+	g.Expect(os.Mkdir(filepath.Join(dir, "inside"), 0o700)).To(gomega.Succeed())
+	g.Expect(os.WriteFile(filepath.Join(dir, "inside", "file"), []byte("inside"), 0o600)).To(gomega.Succeed())
+
+	for link, target := range map[string]string{"escape": outside, "local": filepath.Join(dir, "inside")} {
+		out, err := exec.Command("cmd", "/c", "mklink", "/J", filepath.Join(dir, link), target).CombinedOutput()
+		if err != nil {
+			t.Skipf("cannot create junction: %v: %s", err, out)
+		}
+	}
+
+	fs := New(dir)
+
+	_, err := fs.Open("escape/secret")
+	g.Expect(errors.Is(err, billy.ErrCrossedBoundary)).To(gomega.BeTrue(), "%v", err)
+	_, err = fs.Stat("escape/secret")
+	g.Expect(errors.Is(err, billy.ErrCrossedBoundary)).To(gomega.BeTrue(), "%v", err)
+	_, err = fs.ReadDir("escape")
+	g.Expect(errors.Is(err, billy.ErrCrossedBoundary)).To(gomega.BeTrue(), "%v", err)
+	_, err = fs.Create("escape/new")
+	g.Expect(errors.Is(err, billy.ErrCrossedBoundary)).To(gomega.BeTrue(), "%v", err)
+	_, err = os.Stat(filepath.Join(outside, "new"))
+	g.Expect(os.IsNotExist(err)).To(gomega.BeTrue())
+
+	f, err := fs.Open("local/file")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(f.Close()).To(gomega.Succeed())
+}
+
+func TestWithin(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(within(`\\?\C:\root`, `\\?\C:\root`)).To(gomega.BeTrue())
+	g.Expect(within(`\\?\C:\Root\file`, `\\?\c:\root`)).To(gomega.BeTrue())
+	g.Expect(within(`\\?\C:\root2\file`, `\\?\C:\root`)).To(gomega.BeFalse())
+	g.Expect(within(`\\?\D:\root\file`, `\\?\C:\root`)).To(gomega.BeFalse())
+	g.Expect(within(`\\?\UNC\server\share\root\file`, `\\?\UNC\server\share\root`)).To(gomega.BeTrue())
+	g.Expect(within(`\\?\C:\file`, `\\?\C:\`)).To(gomega.BeTrue())
+}
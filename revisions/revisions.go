@@ -0,0 +1,380 @@
+// Package revisions manages a directory holding several revisions of a tree,
+// of which one is exposed at a stable "current" path.
+//
+// The layout under the managed directory is:
+//
+//	revisions/<name>/   one directory per revision
+//	current             symlink to revisions/<name>
+//	history             names of the promoted revisions, oldest first
+//
+// Promoting a revision replaces the current symlink atomically, so readers
+// of current always see a complete tree. On backends without symlink support
+// current is a copy of the promoted revision, swapped in with util.SwapDirs.
+package revisions // import "github.com/go-git/go-billy/v5/revisions"
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	revisionsDir  = "revisions"
+	currentLink   = "current"
+	historyFile   = "history"
+	stagingPrefix = ".staging-"
+)
+
+var (
+	// ErrNoRevision is returned when there is no current or previous
+	// revision.
+	ErrNoRevision = errors.New("no revision")
+	// ErrInvalidName is returned for revision names which are not a single,
+	// non hidden, path component.
+	ErrInvalidName = errors.New("invalid revision name")
+)
+
+// Manager promotes revisions and prunes old ones.
+type Manager struct {
+	fs   billy.Filesystem
+	dir  string
+	keep int
+}
+
+// New returns a Manager for the directory dir of fs, retaining keep previous
+// revisions besides the current one.
+func New(fs billy.Filesystem, dir string, keep int) *Manager {
+	if keep < 0 {
+		keep = 0
+	}
+
+	return &Manager{fs: fs, dir: dir, keep: keep}
+}
+
+func (m *Manager) path(elem ...string) string {
+	return m.fs.Join(append([]string{m.dir}, elem...)...)
+}
+
+func validName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") ||
+		strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	return nil
+}
+
+// Stage returns a filesystem rooted at a fresh staging directory for the
+// revision name, discarding any leftover from a previous attempt. The
+// content is only visible as a revision once Promote is called.
+func (m *Manager) Stage(name string) (billy.Filesystem, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+
+	staging := m.path(revisionsDir, stagingPrefix+name)
+	if err := util.RemoveAll(m.fs, staging); err != nil {
+		return nil, err
+	}
+
+	if err := m.fs.MkdirAll(staging, 0o755); err != nil {
+		return nil, err
+	}
+
+	return m.fs.Chroot(staging)
+}
+
+// Promote makes name the current revision. If the revision was staged, the
+// staging directory is first renamed to its final location. The previous
+// revisions exceeding the retention are removed afterwards.
+func (m *Manager) Promote(name string) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+
+	target := m.path(revisionsDir, name)
+	staging := m.path(revisionsDir, stagingPrefix+name)
+	if _, err := m.fs.Stat(staging); err == nil {
+		if err := util.RemoveAll(m.fs, target); err != nil {
+			return err
+		}
+		if err := m.fs.Rename(staging, target); err != nil {
+			return err
+		}
+	}
+
+	fi, err := m.fs.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("revision %q is not a directory", name)
+	}
+
+	if err := m.activate(name); err != nil {
+		return err
+	}
+
+	history, err := m.history()
+	if err != nil {
+		return err
+	}
+
+	history = append(remove(history, name), name)
+	if err := m.writeHistory(history); err != nil {
+		return err
+	}
+
+	return m.prune(history)
+}
+
+func (m *Manager) activate(name string) error {
+	current := m.path(currentLink)
+	tmp := m.path("." + currentLink + ".tmp")
+	_ = m.fs.Remove(tmp)
+
+	err := m.fs.Symlink(filepath.Join(revisionsDir, name), tmp)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return m.activateCopy(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi, err := m.fs.Lstat(current); err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		// current was materialized as a copy; replace it by the symlink.
+		if err := util.RemoveAll(m.fs, current); err != nil {
+			return err
+		}
+	}
+
+	if err := m.fs.Rename(tmp, current); err != nil {
+		_ = m.fs.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+func (m *Manager) activateCopy(name string) error {
+	tmp := m.path("." + currentLink + ".next")
+	if err := util.RemoveAll(m.fs, tmp); err != nil {
+		return err
+	}
+
+	if err := copyTree(m.fs, m.path(revisionsDir, name), tmp); err != nil {
+		return err
+	}
+
+	if err := util.SwapDirs(m.fs, m.path(currentLink), tmp); err != nil {
+		return err
+	}
+
+	return util.RemoveAll(m.fs, tmp)
+}
+
+// Current returns the name of the current revision, or ErrNoRevision.
+func (m *Manager) Current() (string, error) {
+	fi, err := m.fs.Lstat(m.path(currentLink))
+	if os.IsNotExist(err) {
+		return "", ErrNoRevision
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := m.fs.Readlink(m.path(currentLink))
+		if err != nil {
+			return "", err
+		}
+		return filepath.Base(target), nil
+	}
+
+	history, err := m.history()
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 {
+		return "", ErrNoRevision
+	}
+
+	return history[len(history)-1], nil
+}
+
+// Previous returns the name of the revision promoted before the current one,
+// or ErrNoRevision.
+func (m *Manager) Previous() (string, error) {
+	current, err := m.Current()
+	if err != nil {
+		return "", err
+	}
+
+	history, err := m.history()
+	if err != nil {
+		return "", err
+	}
+
+	history = remove(history, current)
+	if len(history) == 0 {
+		return "", ErrNoRevision
+	}
+
+	return history[len(history)-1], nil
+}
+
+// Rollback promotes the previous revision.
+func (m *Manager) Rollback() error {
+	previous, err := m.Previous()
+	if err != nil {
+		return err
+	}
+
+	return m.Promote(previous)
+}
+
+// List returns the names of the retained revisions, oldest first.
+func (m *Manager) List() ([]string, error) {
+	return m.history()
+}
+
+func (m *Manager) prune(history []string) error {
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+
+	keep := map[string]bool{current: true}
+	previous := remove(history, current)
+	for i := len(previous) - 1; i >= 0 && len(keep) <= m.keep; i-- {
+		keep[previous[i]] = true
+	}
+
+	infos, err := m.fs.ReadDir(m.path(revisionsDir))
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range infos {
+		name := fi.Name()
+		if keep[name] || strings.HasPrefix(name, stagingPrefix) {
+			continue
+		}
+
+		if err := util.RemoveAll(m.fs, m.path(revisionsDir, name)); err != nil {
+			return err
+		}
+	}
+
+	var retained []string
+	for _, name := range history {
+		if keep[name] {
+			retained = append(retained, name)
+		}
+	}
+
+	return m.writeHistory(retained)
+}
+
+func (m *Manager) history() ([]string, error) {
+	content, err := util.ReadFile(m.fs, m.path(historyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, scanner.Err()
+}
+
+func (m *Manager) writeHistory(names []string) error {
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteByte('\n')
+	}
+
+	tmp := m.path("." + historyFile + ".tmp")
+	if err := util.WriteFile(m.fs, tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	return m.fs.Rename(tmp, m.path(historyFile))
+}
+
+func remove(names []string, name string) []string {
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		if n != name {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+// copyTree copies the directories and regular files of src to dst.
+func copyTree(fs billy.Filesystem, src, dst string) error {
+	fi, err := fs.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return copyFile(fs, src, dst, fi.Mode())
+	}
+
+	if err := fs.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	infos, err := fs.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range infos {
+		if err := copyTree(fs, fs.Join(src, fi.Name()), fs.Join(dst, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(fs billy.Filesystem, src, dst string, mode os.FileMode) error {
+	s, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	d, err := fs.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(d, s)
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,164 @@
+package revisions
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/test/faultfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func stage(t *testing.T, m *Manager, name, content string) {
+	t.Helper()
+
+	fs, err := m.Stage(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "file", []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Promote(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertCurrent(t *testing.T, fs billy.Filesystem, m *Manager, name, content string) {
+	t.Helper()
+
+	current, err := m.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != name {
+		t.Errorf("expected current revision %q, got %q", name, current)
+	}
+
+	data, err := util.ReadFile(fs, "site/current/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("expected %q, got %q", content, data)
+	}
+}
+
+func testPromote(t *testing.T, fs billy.Filesystem) {
+	m := New(fs, "site", 1)
+	if _, err := m.Current(); !errors.Is(err, ErrNoRevision) {
+		t.Fatalf("expected ErrNoRevision, got %v", err)
+	}
+
+	stage(t, m, "r1", "one")
+	assertCurrent(t, fs, m, "r1", "one")
+
+	stage(t, m, "r2", "two")
+	assertCurrent(t, fs, m, "r2", "two")
+
+	stage(t, m, "r3", "three")
+	assertCurrent(t, fs, m, "r3", "three")
+
+	list, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []string{"r2", "r3"}) {
+		t.Errorf("unexpected revisions %v", list)
+	}
+	if _, err := fs.Stat("site/revisions/r1"); err == nil {
+		t.Error("expected r1 to be pruned")
+	}
+
+	previous, err := m.Previous()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "r2" {
+		t.Errorf("expected previous revision r2, got %q", previous)
+	}
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	assertCurrent(t, fs, m, "r2", "two")
+}
+
+func TestPromoteSymlink(t *testing.T) {
+	fs := osfs.New(t.TempDir())
+	testPromote(t, fs)
+
+	fi, err := fs.Lstat("site/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected current to be a symlink, got %s", fi.Mode())
+	}
+}
+
+func TestPromoteCopy(t *testing.T) {
+	fs := faultfs.New(memfs.New())
+	fs.Inject(faultfs.Rule{Op: faultfs.Symlink, Err: billy.ErrNotSupported})
+
+	testPromote(t, fs)
+
+	fi, err := fs.Lstat("site/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Errorf("expected current to be a directory, got %s", fi.Mode())
+	}
+}
+
+func TestPromoteFailureKeepsCurrent(t *testing.T) {
+	fs := faultfs.New(osfs.New(t.TempDir()))
+	m := New(fs, "site", 1)
+	stage(t, m, "r1", "one")
+
+	fs.Inject(faultfs.Rule{Op: faultfs.Rename, Path: "site/current", Err: errors.New("boom")})
+
+	staged, err := m.Stage("r2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	util.WriteFile(staged, "file", []byte("two"), 0o644)
+	if err := m.Promote("r2"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	fs.Reset()
+	assertCurrent(t, fs, m, "r1", "one")
+}
+
+func TestStagingIsNotPruned(t *testing.T) {
+	fs := osfs.New(t.TempDir())
+	m := New(fs, "site", 0)
+
+	if _, err := m.Stage("pending"); err != nil {
+		t.Fatal(err)
+	}
+	stage(t, m, "r1", "one")
+	stage(t, m, "r2", "two")
+
+	if _, err := fs.Stat("site/revisions/.staging-pending"); err != nil {
+		t.Errorf("expected the staging directory to be kept: %v", err)
+	}
+	if _, err := fs.Stat("site/revisions/r1"); err == nil {
+		t.Error("expected r1 to be pruned")
+	}
+}
+
+func TestInvalidName(t *testing.T) {
+	m := New(memfs.New(), "site", 1)
+	for _, name := range []string{"", ".", "..", ".hidden", "a/b"} {
+		if _, err := m.Stage(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%q: expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
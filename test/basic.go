@@ -2,15 +2,17 @@ package test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
-	. "gopkg.in/check.v1"
 	. "github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
 )
 
 // BasicSuite is a convenient test suite to validate any implementation of
@@ -19,565 +21,715 @@ type BasicSuite struct {
 	FS Basic
 }
 
-func (s *BasicSuite) TestCreate(c *C) {
+func (s *BasicSuite) TestCreate(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestCreateDepth(c *C) {
+func (s *BasicSuite) TestCreateDepth(c *check.C) {
 	f, err := s.FS.Create("bar/foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, s.FS.Join("bar", "foo"))
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, s.FS.Join("bar", "foo"))
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestCreateDepthAbsolute(c *C) {
+func (s *BasicSuite) TestCreateDepthAbsolute(c *check.C) {
 	f, err := s.FS.Create("/bar/foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, s.FS.Join("bar", "foo"))
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, s.FS.Join("bar", "foo"))
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestCreateOverwrite(c *C) {
+func (s *BasicSuite) TestCreateOverwrite(c *check.C) {
 	for i := 0; i < 3; i++ {
 		f, err := s.FS.Create("foo")
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 
 		l, err := f.Write([]byte(fmt.Sprintf("foo%d", i)))
-		c.Assert(err, IsNil)
-		c.Assert(l, Equals, 4)
+		c.Assert(err, check.IsNil)
+		c.Assert(l, check.Equals, 4)
 
 		err = f.Close()
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	wrote, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(wrote), DeepEquals, "foo2")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(wrote), check.DeepEquals, "foo2")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestCreateAndClose(c *C) {
+func (s *BasicSuite) TestCreateAndClose(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	_, err = f.Write([]byte("foo"))
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	f, err = s.FS.Open(f.Name())
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	wrote, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(wrote), DeepEquals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(wrote), check.DeepEquals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestOpen(c *C) {
+func (s *BasicSuite) TestOpen(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 
 	f, err = s.FS.Open("foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestOpenNotExists(c *C) {
+func (s *BasicSuite) TestOpenNotExists(c *check.C) {
 	f, err := s.FS.Open("not-exists")
-	c.Assert(err, NotNil)
-	c.Assert(f, IsNil)
+	c.Assert(err, check.NotNil)
+	c.Assert(f, check.IsNil)
 }
 
-func (s *BasicSuite) TestOpenFile(c *C) {
+func (s *BasicSuite) TestOpenFile(c *check.C) {
 	defaultMode := os.FileMode(0666)
 
 	f, err := s.FS.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultMode)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	s.testWriteClose(c, f, "foo1")
 
 	// Truncate if it exists
 	f, err = s.FS.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testWriteClose(c, f, "foo1overwritten")
 
 	// Read-only if it exists
 	f, err = s.FS.OpenFile("foo1", os.O_RDONLY, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testReadClose(c, f, "foo1overwritten")
 
 	// Create when it does exist
 	f, err = s.FS.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testWriteClose(c, f, "bar")
 
 	f, err = s.FS.OpenFile("foo1", os.O_RDONLY, defaultMode)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	s.testReadClose(c, f, "bar")
 }
 
-func (s *BasicSuite) TestOpenFileNoTruncate(c *C) {
+func (s *BasicSuite) TestOpenFileNoTruncate(c *check.C) {
 	defaultMode := os.FileMode(0666)
 
 	// Create when it does not exist
 	f, err := s.FS.OpenFile("foo1", os.O_CREATE|os.O_WRONLY, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testWriteClose(c, f, "foo1")
 
 	f, err = s.FS.OpenFile("foo1", os.O_RDONLY, defaultMode)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	s.testReadClose(c, f, "foo1")
 
 	// Create when it does exist
 	f, err = s.FS.OpenFile("foo1", os.O_CREATE|os.O_WRONLY, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testWriteClose(c, f, "bar")
 
 	f, err = s.FS.OpenFile("foo1", os.O_RDONLY, defaultMode)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	s.testReadClose(c, f, "bar1")
 }
 
-func (s *BasicSuite) TestOpenFileAppend(c *C) {
+func (s *BasicSuite) TestOpenFileAppend(c *check.C) {
 	defaultMode := os.FileMode(0666)
 
 	f, err := s.FS.OpenFile("foo1", os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testWriteClose(c, f, "foo1")
 
 	f, err = s.FS.OpenFile("foo1", os.O_WRONLY|os.O_APPEND, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 	s.testWriteClose(c, f, "bar1")
 
 	f, err = s.FS.OpenFile("foo1", os.O_RDONLY, defaultMode)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	s.testReadClose(c, f, "foo1bar1")
 }
 
-func (s *BasicSuite) TestOpenFileReadWrite(c *C) {
+func (s *BasicSuite) TestOpenFileReadWrite(c *check.C) {
 	defaultMode := os.FileMode(0666)
 
 	f, err := s.FS.OpenFile("foo1", os.O_CREATE|os.O_TRUNC|os.O_RDWR, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 
 	written, err := f.Write([]byte("foobar"))
-	c.Assert(written, Equals, 6)
-	c.Assert(err, IsNil)
+	c.Assert(written, check.Equals, 6)
+	c.Assert(err, check.IsNil)
 
 	_, err = f.Seek(0, os.SEEK_SET)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	written, err = f.Write([]byte("qux"))
-	c.Assert(written, Equals, 3)
-	c.Assert(err, IsNil)
+	c.Assert(written, check.Equals, 3)
+	c.Assert(err, check.IsNil)
 
 	_, err = f.Seek(0, os.SEEK_SET)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	s.testReadClose(c, f, "quxbar")
 }
 
-func (s *BasicSuite) TestOpenFileWithModes(c *C) {
+func (s *BasicSuite) TestOpenFileWithModes(c *check.C) {
 	f, err := s.FS.OpenFile("foo", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, customMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	fi, err := s.FS.Stat("foo")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Mode(), Equals, os.FileMode(customMode))
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Mode(), check.Equals, os.FileMode(customMode))
 }
 
-func (s *BasicSuite) testWriteClose(c *C, f File, content string) {
+func (s *BasicSuite) TestOpenFileExclusive(c *check.C) {
+	f, err := s.FS.OpenFile("foo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	c.Assert(err, check.IsNil)
+	s.testWriteClose(c, f, "foo")
+
+	_, err = s.FS.OpenFile("foo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	c.Assert(os.IsExist(err), check.Equals, true, check.Commentf("%v", err))
+
+	// Without O_CREATE, O_EXCL is ignored.
+	f, err = s.FS.OpenFile("foo", os.O_EXCL|os.O_RDONLY, 0)
+	c.Assert(err, check.IsNil)
+	s.testReadClose(c, f, "foo")
+}
+
+func (s *BasicSuite) TestOpenFileAppendReadWrite(c *check.C) {
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0666)
+	c.Assert(err, check.IsNil)
+
+	f, err := s.FS.OpenFile("foo", os.O_RDWR|os.O_APPEND, 0)
+	c.Assert(err, check.IsNil)
+
+	// The offset starts at the beginning, only the writes go to the end.
+	buf := make([]byte, 3)
+	_, err = io.ReadFull(f, buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(buf), check.Equals, "foo")
+
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, check.IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, check.IsNil)
+
+	pos, err := f.Seek(0, io.SeekCurrent)
+	c.Assert(err, check.IsNil)
+	c.Assert(pos, check.Equals, int64(6))
+	c.Assert(f.Close(), check.IsNil)
+
+	content, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(content), check.Equals, "foobar")
+}
+
+func (s *BasicSuite) TestOpenFileTruncateAppend(c *check.C) {
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0666)
+	c.Assert(err, check.IsNil)
+
+	f, err := s.FS.OpenFile("foo", os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0)
+	c.Assert(err, check.IsNil)
+	s.testWriteClose(c, f, "bar")
+
+	content, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(content), check.Equals, "bar")
+}
+
+func (s *BasicSuite) TestOpenFileSync(c *check.C) {
+	f, err := s.FS.OpenFile("foo", os.O_CREATE|os.O_WRONLY|os.O_SYNC, 0666)
+	c.Assert(err, check.IsNil)
+	s.testWriteClose(c, f, "foo")
+
+	content, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(content), check.Equals, "foo")
+}
+
+func (s *BasicSuite) TestOpenFileReadOnlyWithFlags(c *check.C) {
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0666)
+	c.Assert(err, check.IsNil)
+
+	f, err := s.FS.OpenFile("foo", os.O_RDONLY|os.O_CREATE, 0666)
+	c.Assert(err, check.IsNil)
+	s.testReadClose(c, f, "foo")
+
+	f, err = s.FS.OpenFile("bar", os.O_RDONLY|os.O_CREATE, 0666)
+	c.Assert(err, check.IsNil)
+	s.testReadClose(c, f, "")
+
+	f, err = s.FS.OpenFile("foo", os.O_RDONLY, 0)
+	c.Assert(err, check.IsNil)
+	_, err = f.Write([]byte("bar"))
+	c.Assert(err, check.NotNil)
+	c.Assert(f.Close(), check.IsNil)
+}
+
+func (s *BasicSuite) TestPathErrors(c *check.C) {
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0666)
+	c.Assert(err, check.IsNil)
+
+	_, err = s.FS.Open("missing")
+	s.assertPathError(c, err, "open", "missing", fs.ErrNotExist)
+
+	_, err = s.FS.Stat("missing")
+	s.assertPathError(c, err, "stat", "missing", fs.ErrNotExist)
+
+	err = s.FS.Remove("missing")
+	s.assertPathError(c, err, "remove", "missing", fs.ErrNotExist)
+
+	_, err = s.FS.OpenFile("foo", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+	s.assertPathError(c, err, "open", "foo", fs.ErrExist)
+
+	err = s.FS.Rename("missing", "bar")
+	var le *os.LinkError
+	c.Assert(errors.As(err, &le), check.Equals, true, check.Commentf("%#v", err))
+	c.Assert(le.Op, check.Equals, "rename")
+	c.Assert(le.Old, check.Equals, "missing")
+	c.Assert(le.New, check.Equals, "bar")
+	c.Assert(errors.Is(err, fs.ErrNotExist), check.Equals, true)
+}
+
+// assertPathError checks that err is an *os.PathError for the operation op on
+// path, matching target.
+func (s *BasicSuite) assertPathError(c *check.C, err error, op, path string, target error) {
+	var pe *os.PathError
+	c.Assert(errors.As(err, &pe), check.Equals, true, check.Commentf("%#v", err))
+	c.Assert(pe.Op, check.Equals, op)
+	c.Assert(pe.Path, check.Equals, path)
+	c.Assert(errors.Is(err, target), check.Equals, true, check.Commentf("%v", err))
+}
+
+func (s *BasicSuite) testWriteClose(c *check.C, f File, content string) {
 	written, err := f.Write([]byte(content))
-	c.Assert(written, Equals, len(content))
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(written, check.Equals, len(content))
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) testReadClose(c *C, f File, content string) {
+func (s *BasicSuite) testReadClose(c *check.C, f File, content string) {
 	read, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(read), Equals, content)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(read), check.Equals, content)
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestFileWrite(c *C) {
+func (s *BasicSuite) TestFileWrite(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	n, err := f.Write([]byte("foo"))
-	c.Assert(err, IsNil)
-	c.Assert(n, Equals, 3)
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 3)
 
 	f.Seek(0, io.SeekStart)
 	all, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(all), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(all), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestFileWriteClose(c *C) {
+func (s *BasicSuite) TestFileWriteClose(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
-	c.Assert(f.Close(), IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	_, err = f.Write([]byte("foo"))
-	c.Assert(err, NotNil)
+	c.Assert(err, check.NotNil)
 }
 
-func (s *BasicSuite) TestFileRead(c *C) {
+func (s *BasicSuite) TestFileRead(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	all, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(all), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(all), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestFileClosed(c *C) {
+func (s *BasicSuite) TestFileClosed(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	_, err = ioutil.ReadAll(f)
-	c.Assert(err, NotNil)
+	c.Assert(err, check.NotNil)
 }
 
-func (s *BasicSuite) TestFileNonRead(c *C) {
+func (s *BasicSuite) TestFileNonRead(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.OpenFile("foo", os.O_WRONLY, 0)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	_, err = ioutil.ReadAll(f)
-	c.Assert(err, NotNil)
+	c.Assert(err, check.NotNil)
 
-	c.Assert(f.Close(), IsNil)
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestFileSeekstart(c *C) {
+func (s *BasicSuite) TestFileSeekstart(c *check.C) {
 	s.testFileSeek(c, 10, io.SeekStart)
 }
 
-func (s *BasicSuite) TestFileSeekCurrent(c *C) {
+func (s *BasicSuite) TestFileSeekCurrent(c *check.C) {
 	s.testFileSeek(c, 5, io.SeekCurrent)
 }
 
-func (s *BasicSuite) TestFileSeekEnd(c *C) {
+func (s *BasicSuite) TestFileSeekEnd(c *check.C) {
 	s.testFileSeek(c, -26, io.SeekEnd)
 }
 
-func (s *BasicSuite) testFileSeek(c *C, offset int64, whence int) {
+func (s *BasicSuite) testFileSeek(c *check.C, offset int64, whence int) {
 	err := util.WriteFile(s.FS, "foo", []byte("0123456789abcdefghijklmnopqrstuvwxyz"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	some := make([]byte, 5)
 	_, err = f.Read(some)
-	c.Assert(err, IsNil)
-	c.Assert(string(some), Equals, "01234")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(some), check.Equals, "01234")
 
 	p, err := f.Seek(offset, whence)
-	c.Assert(err, IsNil)
-	c.Assert(int(p), Equals, 10)
+	c.Assert(err, check.IsNil)
+	c.Assert(int(p), check.Equals, 10)
 
 	all, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(all, HasLen, 26)
-	c.Assert(string(all), Equals, "abcdefghijklmnopqrstuvwxyz")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.HasLen, 26)
+	c.Assert(string(all), check.Equals, "abcdefghijklmnopqrstuvwxyz")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestSeekToEndAndWrite(c *C) {
+func (s *BasicSuite) TestSeekToEndAndWrite(c *check.C) {
 	defaultMode := os.FileMode(0666)
 
 	f, err := s.FS.OpenFile("foo1", os.O_CREATE|os.O_TRUNC|os.O_RDWR, defaultMode)
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "foo1")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "foo1")
 
 	_, err = f.Seek(10, io.SeekEnd)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	n, err := f.Write([]byte(`TEST`))
-	c.Assert(err, IsNil)
-	c.Assert(n, Equals, 4)
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 4)
 
 	_, err = f.Seek(0, io.SeekStart)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	s.testReadClose(c, f, "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00TEST")
 }
 
-func (s *BasicSuite) TestFileSeekClosed(c *C) {
+func (s *BasicSuite) TestFileSeekClosed(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	_, err = f.Seek(0, 0)
-	c.Assert(err, NotNil)
+	c.Assert(err, check.NotNil)
 }
 
-func (s *BasicSuite) TestFileCloseTwice(c *C) {
+func (s *BasicSuite) TestFileCloseTwice(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
-	c.Assert(f.Close(), IsNil)
-	c.Assert(f.Close(), NotNil)
+	c.Assert(f.Close(), check.IsNil)
+	c.Assert(f.Close(), check.NotNil)
 }
 
-func (s *BasicSuite) TestStat(c *C) {
+func (s *BasicSuite) TestStat(c *check.C) {
 	util.WriteFile(s.FS, "foo/bar", []byte("foo"), customMode)
 
 	fi, err := s.FS.Stat("foo/bar")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "bar")
-	c.Assert(fi.Size(), Equals, int64(3))
-	c.Assert(fi.Mode(), Equals, customMode)
-	c.Assert(fi.ModTime().IsZero(), Equals, false)
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "bar")
+	c.Assert(fi.Size(), check.Equals, int64(3))
+	c.Assert(fi.Mode(), check.Equals, customMode)
+	c.Assert(fi.ModTime().IsZero(), check.Equals, false)
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *BasicSuite) TestStatNonExistent(c *C) {
+func (s *BasicSuite) TestStatNonExistent(c *check.C) {
 	fi, err := s.FS.Stat("non-existent")
-	comment := Commentf("error: %s", err)
-	c.Assert(os.IsNotExist(err), Equals, true, comment)
-	c.Assert(fi, IsNil)
+	comment := check.Commentf("error: %s", err)
+	c.Assert(os.IsNotExist(err), check.Equals, true, comment)
+	c.Assert(fi, check.IsNil)
 }
 
-func (s *BasicSuite) TestRename(c *C) {
+func (s *BasicSuite) TestRename(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Rename("foo", "bar")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	foo, err := s.FS.Stat("foo")
-	c.Assert(foo, IsNil)
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(foo, check.IsNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 
 	bar, err := s.FS.Stat("bar")
-	c.Assert(err, IsNil)
-	c.Assert(bar, NotNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(bar, check.NotNil)
 }
 
-func (s *BasicSuite) TestOpenAndWrite(c *C) {
+func (s *BasicSuite) TestOpenAndWrite(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	foo, err := s.FS.Open("foo")
-	c.Assert(foo, NotNil)
-	c.Assert(err, IsNil)
+	c.Assert(foo, check.NotNil)
+	c.Assert(err, check.IsNil)
 
 	n, err := foo.Write([]byte("foo"))
-	c.Assert(err, NotNil)
-	c.Assert(n, Equals, 0)
+	c.Assert(err, check.NotNil)
+	c.Assert(n, check.Equals, 0)
 
-	c.Assert(foo.Close(), IsNil)
+	c.Assert(foo.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestOpenAndStat(c *C) {
+func (s *BasicSuite) TestOpenAndStat(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	foo, err := s.FS.Open("foo")
-	c.Assert(foo, NotNil)
-	c.Assert(foo.Name(), Equals, "foo")
-	c.Assert(err, IsNil)
-	c.Assert(foo.Close(), IsNil)
+	c.Assert(foo, check.NotNil)
+	c.Assert(foo.Name(), check.Equals, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(foo.Close(), check.IsNil)
 
 	stat, err := s.FS.Stat("foo")
-	c.Assert(stat, NotNil)
-	c.Assert(err, IsNil)
-	c.Assert(stat.Name(), Equals, "foo")
-	c.Assert(stat.Size(), Equals, int64(3))
+	c.Assert(stat, check.NotNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(stat.Name(), check.Equals, "foo")
+	c.Assert(stat.Size(), check.Equals, int64(3))
 }
 
-func (s *BasicSuite) TestRemove(c *C) {
+func (s *BasicSuite) TestRemove(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	err = s.FS.Remove("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 }
 
-func (s *BasicSuite) TestRemoveNonExisting(c *C) {
+func (s *BasicSuite) TestRemoveNonExisting(c *check.C) {
 	err := s.FS.Remove("NON-EXISTING")
-	c.Assert(err, NotNil)
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(err, check.NotNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 }
 
-func (s *BasicSuite) TestRemoveNotEmptyDir(c *C) {
+func (s *BasicSuite) TestRemoveNotEmptyDir(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Remove("no-exists")
-	c.Assert(err, NotNil)
+	c.Assert(err, check.NotNil)
 }
 
-func (s *BasicSuite) TestJoin(c *C) {
-	c.Assert(s.FS.Join("foo", "bar"), Equals, fmt.Sprintf("foo%cbar", filepath.Separator))
+func (s *BasicSuite) TestJoin(c *check.C) {
+	c.Assert(s.FS.Join("foo", "bar"), check.Equals, fmt.Sprintf("foo%cbar", filepath.Separator))
 }
 
-func (s *BasicSuite) TestReadAtOnReadWrite(c *C) {
+func (s *BasicSuite) TestReadAtOnReadWrite(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	_, err = f.Write([]byte("abcdefg"))
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	rf, ok := f.(io.ReaderAt)
-	c.Assert(ok, Equals, true)
+	c.Assert(ok, check.Equals, true)
 
 	b := make([]byte, 3)
 	n, err := rf.ReadAt(b, 2)
-	c.Assert(err, IsNil)
-	c.Assert(n, Equals, 3)
-	c.Assert(string(b), Equals, "cde")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 3)
+	c.Assert(string(b), check.Equals, "cde")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestReadAtOnReadOnly(c *C) {
+func (s *BasicSuite) TestReadAtOnReadOnly(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("abcdefg"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	rf, ok := f.(io.ReaderAt)
-	c.Assert(ok, Equals, true)
+	c.Assert(ok, check.Equals, true)
 
 	b := make([]byte, 3)
 	n, err := rf.ReadAt(b, 2)
-	c.Assert(err, IsNil)
-	c.Assert(n, Equals, 3)
-	c.Assert(string(b), Equals, "cde")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 3)
+	c.Assert(string(b), check.Equals, "cde")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestReadAtEOF(c *C) {
+func (s *BasicSuite) TestReadAtEOF(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("TEST"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	b := make([]byte, 5)
 	n, err := f.ReadAt(b, 0)
-	c.Assert(err, Equals, io.EOF)
-	c.Assert(n, Equals, 4)
-	c.Assert(string(b), Equals, "TEST\x00")
+	c.Assert(err, check.Equals, io.EOF)
+	c.Assert(n, check.Equals, 4)
+	c.Assert(string(b), check.Equals, "TEST\x00")
 
 	err = f.Close()
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 }
 
-func (s *BasicSuite) TestReadAtOffset(c *C) {
+func (s *BasicSuite) TestReadAtOffset(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("TEST"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	rf, ok := f.(io.ReaderAt)
-	c.Assert(ok, Equals, true)
+	c.Assert(ok, check.Equals, true)
 
 	o, err := f.Seek(0, io.SeekCurrent)
-	c.Assert(err, IsNil)
-	c.Assert(o, Equals, int64(0))
+	c.Assert(err, check.IsNil)
+	c.Assert(o, check.Equals, int64(0))
 
 	b := make([]byte, 4)
 	n, err := rf.ReadAt(b, 0)
-	c.Assert(err, IsNil)
-	c.Assert(n, Equals, 4)
-	c.Assert(string(b), Equals, "TEST")
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 4)
+	c.Assert(string(b), check.Equals, "TEST")
 
 	o, err = f.Seek(0, io.SeekCurrent)
-	c.Assert(err, IsNil)
-	c.Assert(o, Equals, int64(0))
+	c.Assert(err, check.IsNil)
+	c.Assert(o, check.Equals, int64(0))
 
 	err = f.Close()
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 }
 
-func (s *BasicSuite) TestReadWriteLargeFile(c *C) {
+func (s *BasicSuite) TestReadWriteLargeFile(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	size := 1 << 20
 
 	n, err := f.Write(bytes.Repeat([]byte("F"), size))
-	c.Assert(err, IsNil)
-	c.Assert(n, Equals, size)
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, size)
 
-	c.Assert(f.Close(), IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	f, err = s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	b, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(len(b), Equals, size)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(len(b), check.Equals, size)
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestWriteFile(c *C) {
+func (s *BasicSuite) TestWriteFile(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte("bar"), 0777)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	wrote, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(wrote), DeepEquals, "bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(wrote), check.DeepEquals, "bar")
 
-	c.Assert(f.Close(), IsNil)
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *BasicSuite) TestTruncate(c *C) {
+func (s *BasicSuite) TestTruncate(c *check.C) {
 	f, err := s.FS.Create("foo")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	for _, sz := range []int64{4, 7, 2, 30, 0, 1} {
 		err = f.Truncate(sz)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 
 		bs, err := ioutil.ReadAll(f)
-		c.Assert(err, IsNil)
-		c.Assert(len(bs), Equals, int(sz))
+		c.Assert(err, check.IsNil)
+		c.Assert(len(bs), check.Equals, int(sz))
 
 		_, err = f.Seek(0, io.SeekStart)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
-	c.Assert(f.Close(), IsNil)
+	c.Assert(f.Close(), check.IsNil)
+}
+
+func (s *BasicSuite) TestTruncateReadOnly(c *check.C) {
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
+	c.Assert(err, check.IsNil)
+
+	f, err := s.FS.Open("foo")
+	c.Assert(err, check.IsNil)
+
+	err = f.Truncate(1)
+	c.Assert(err, check.NotNil)
+	c.Assert(f.Close(), check.IsNil)
+
+	b, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(b), check.Equals, "foo")
+}
+
+func (s *BasicSuite) TestTruncateClosed(c *check.C) {
+	f, err := s.FS.Create("foo")
+	c.Assert(err, check.IsNil)
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+
+	err = f.Truncate(0)
+	c.Assert(err, check.NotNil)
+
+	b, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(b), check.Equals, "foo")
 }
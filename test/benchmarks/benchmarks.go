@@ -0,0 +1,236 @@
+// Package benchmarks provides standardized workloads to benchmark any
+// billy.Filesystem, so that backends, and the changes made to them for their
+// performance, are compared on the same numbers.
+package benchmarks // import "github.com/go-git/go-billy/v5/test/benchmarks"
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Workload is an operation measured by Run, on a filesystem prepared for it.
+type Workload struct {
+	Name string
+	// Bytes is the number of bytes processed by an operation, reported as
+	// MB/s, if any.
+	Bytes int64
+	// Prepare fills fs, outside of the measured time, and returns the
+	// operation, called with i going from 0 to b.N-1.
+	Prepare func(tb testing.TB, fs billy.Filesystem) func(i int) error
+}
+
+const (
+	// deepTreeDepth is the number of directories created by an operation of
+	// the deep-tree workload.
+	deepTreeDepth = 32
+	// wideDirEntries is the number of files of the wide-readdir directory.
+	wideDirEntries = 10000
+	// seqFileSize is the size of the file written by sequential-write.
+	seqFileSize = 16 << 20
+	// seqChunkSize is the size of the writes of sequential-write.
+	seqChunkSize = 64 << 10
+	// randomFileSize is the size of the file read by random-readat.
+	randomFileSize = 16 << 20
+	// randomReadSize is the size of the reads of random-readat.
+	randomReadSize = 4 << 10
+	// hashTreeDirs and hashTreeFiles are the numbers of directories of the
+	// tree-hash tree, and of files in each of them.
+	hashTreeDirs, hashTreeFiles = 10, 100
+	// hashFileSize is the size of the files of the tree-hash tree.
+	hashFileSize = 4 << 10
+)
+
+// Workloads are the workloads run by Run:
+//
+//   - deep-tree creates a file below deepTreeDepth new directories.
+//   - wide-readdir reads a directory of wideDirEntries files.
+//   - sequential-write writes a 16 MiB file in 64 KiB chunks.
+//   - random-readat reads 4 KiB at a random offset of a 16 MiB file.
+//   - tree-hash hashes, with util.HashTree, a tree of 1000 files of 4 KiB.
+var Workloads = []Workload{
+	{Name: "deep-tree", Prepare: prepareDeepTree},
+	{Name: "wide-readdir", Prepare: prepareWideReadDir},
+	{Name: "sequential-write", Bytes: seqFileSize, Prepare: prepareSequentialWrite},
+	{Name: "random-readat", Bytes: randomReadSize, Prepare: prepareRandomReadAt},
+	{Name: "tree-hash", Bytes: hashTreeDirs * hashTreeFiles * hashFileSize, Prepare: prepareTreeHash},
+}
+
+// Run runs every workload of Workloads as a sub-benchmark, against the
+// filesystems returned by newFS, which is called once per run of a workload
+// and must return an empty filesystem. Along with the time per operation,
+// the operations per second and the allocations are reported:
+//
+//	func BenchmarkBackend(b *testing.B) {
+//		benchmarks.Run(b, func() billy.Filesystem {
+//			return mybackend.New(b.TempDir())
+//		})
+//	}
+//
+// The usual flags of go test select and profile the workloads, for instance
+// to profile the CPU of random-readat:
+//
+//	go test -run '^$' -bench 'Backend/random-readat' -cpuprofile cpu.out
+//
+// The filesystems implementing billy.Closer are closed once measured.
+func Run(b *testing.B, newFS func() billy.Filesystem) {
+	for _, w := range Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			RunWorkload(b, w, newFS())
+		})
+	}
+}
+
+// RunWorkload runs the workload w against fs, which must be empty, reporting
+// the same metrics as Run.
+func RunWorkload(b *testing.B, w Workload, fs billy.Filesystem) {
+	defer func() {
+		if err := billy.Close(fs); err != nil {
+			b.Error(err)
+		}
+	}()
+
+	op := w.Prepare(b, fs)
+	b.SetBytes(w.Bytes)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if err := op(i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	if elapsed > 0 {
+		b.ReportMetric(float64(b.N)/elapsed.Seconds(), "ops/s")
+	}
+}
+
+func prepareDeepTree(tb testing.TB, fs billy.Filesystem) func(i int) error {
+	dirs := make([]string, deepTreeDepth)
+	for i := range dirs {
+		dirs[i] = fmt.Sprintf("d%d", i)
+	}
+	deep := path.Join(dirs...)
+
+	return func(i int) error {
+		dir := fs.Join(fmt.Sprintf("tree%d", i), deep)
+		if err := fs.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+
+		return util.WriteFile(fs, fs.Join(dir, "file"), nil, 0o644)
+	}
+}
+
+func prepareWideReadDir(tb testing.TB, fs billy.Filesystem) func(i int) error {
+	for i := 0; i < wideDirEntries; i++ {
+		if err := util.WriteFile(fs, fs.Join("wide", fmt.Sprintf("file%d", i)), nil, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return func(int) error {
+		infos, err := fs.ReadDir("wide")
+		if err == nil && len(infos) != wideDirEntries {
+			err = fmt.Errorf("read %d entries, expected %d", len(infos), wideDirEntries)
+		}
+
+		return err
+	}
+}
+
+func prepareSequentialWrite(tb testing.TB, fs billy.Filesystem) func(i int) error {
+	chunk := make([]byte, seqChunkSize)
+	rand.New(rand.NewSource(1)).Read(chunk)
+
+	return func(int) error {
+		f, err := fs.Create("sequential")
+		if err != nil {
+			return err
+		}
+
+		for n := 0; n < seqFileSize && err == nil; n += len(chunk) {
+			_, err = f.Write(chunk)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	}
+}
+
+func prepareRandomReadAt(tb testing.TB, fs billy.Filesystem) func(i int) error {
+	writeFile(tb, fs, "random", randomFileSize)
+
+	f, err := fs.Open("random")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { f.Close() })
+
+	// The offsets are drawn upfront, the same ones for every backend.
+	rnd := rand.New(rand.NewSource(1))
+	offsets := make([]int64, 1024)
+	for i := range offsets {
+		offsets[i] = rnd.Int63n(randomFileSize - randomReadSize)
+	}
+
+	buf := make([]byte, randomReadSize)
+	return func(i int) error {
+		_, err := f.ReadAt(buf, offsets[i%len(offsets)])
+		return err
+	}
+}
+
+func prepareTreeHash(tb testing.TB, fs billy.Filesystem) func(i int) error {
+	for d := 0; d < hashTreeDirs; d++ {
+		for f := 0; f < hashTreeFiles; f++ {
+			writeFile(tb, fs, fs.Join("tree", fmt.Sprintf("dir%d", d), fmt.Sprintf("file%d", f)), hashFileSize)
+		}
+	}
+
+	return func(int) error {
+		_, err := util.HashTree(fs, "tree", sha256.New)
+		return err
+	}
+}
+
+// writeFile writes size pseudo-random bytes to the file name.
+func writeFile(tb testing.TB, fs billy.Filesystem, name string, size int64) {
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(size))
+	buf := make([]byte, 64<<10)
+	for written := int64(0); written < size; {
+		n := int64(len(buf))
+		if size-written < n {
+			n = size - written
+		}
+		rnd.Read(buf[:n])
+		if _, err := f.Write(buf[:n]); err != nil {
+			f.Close()
+			tb.Fatal(err)
+		}
+		written += n
+	}
+
+	if err := f.Close(); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package benchmarks_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/test/benchmarks"
+)
+
+func TestWorkloads(t *testing.T) {
+	for _, w := range benchmarks.Workloads {
+		w := w
+		t.Run(w.Name, func(t *testing.T) {
+			op := w.Prepare(t, memfs.New())
+			for i := 0; i < 3; i++ {
+				if err := op(i); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMemfs(b *testing.B) {
+	benchmarks.Run(b, func() billy.Filesystem {
+		return memfs.New()
+	})
+}
+
+func BenchmarkOsfs(b *testing.B) {
+	benchmarks.Run(b, func() billy.Filesystem {
+		return osfs.New(b.TempDir())
+	})
+}
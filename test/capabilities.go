@@ -0,0 +1,89 @@
+package test
+
+import (
+	"io"
+	"os"
+
+	. "github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
+)
+
+// CapabilitiesSuite is a convenient test suite to validate that the
+// capabilities reported by an implementation of billy.Basic are accurate.
+type CapabilitiesSuite struct {
+	FS Basic
+}
+
+func (s *CapabilitiesSuite) TestCapabilitiesReadWrite(c *check.C) {
+	if !CapabilityCheck(s.FS, WriteCapability|ReadCapability) {
+		c.Skip("filesystem is not readable and writable")
+	}
+
+	err := util.WriteFile(s.FS, "foo", []byte("foo"), 0644)
+	c.Assert(err, check.IsNil)
+
+	content, err := util.ReadFile(s.FS, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(content), check.Equals, "foo")
+}
+
+func (s *CapabilitiesSuite) TestCapabilitiesReadAndWrite(c *check.C) {
+	if !CapabilityCheck(s.FS, ReadAndWriteCapability|SeekCapability) {
+		c.Skip("filesystem does not support read and write mode")
+	}
+
+	f, err := s.FS.OpenFile("foo", os.O_CREATE|os.O_RDWR, 0644)
+	c.Assert(err, check.IsNil)
+	defer func() { c.Assert(f.Close(), check.IsNil) }()
+
+	_, err = f.Write([]byte("foo"))
+	c.Assert(err, check.IsNil)
+
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, check.IsNil)
+
+	content, err := io.ReadAll(f)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(content), check.Equals, "foo")
+}
+
+func (s *CapabilitiesSuite) TestCapabilitiesTruncate(c *check.C) {
+	if !CapabilityCheck(s.FS, WriteCapability|TruncateCapability) {
+		c.Skip("filesystem does not support truncate")
+	}
+
+	f, err := s.FS.Create("foo")
+	c.Assert(err, check.IsNil)
+
+	_, err = f.Write([]byte("foobar"))
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Truncate(3), check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+
+	fi, err := s.FS.Stat("foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Size(), check.Equals, int64(3))
+}
+
+func (s *CapabilitiesSuite) TestCapabilitiesLock(c *check.C) {
+	if !CapabilityCheck(s.FS, WriteCapability|LockCapability) {
+		c.Skip("filesystem does not support locking")
+	}
+
+	f, err := s.FS.Create("foo")
+	c.Assert(err, check.IsNil)
+
+	c.Assert(f.Lock(), check.IsNil)
+	c.Assert(f.Unlock(), check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+}
+
+func (s *CapabilitiesSuite) TestCapabilitiesReadOnly(c *check.C) {
+	if CapabilityCheck(s.FS, WriteCapability) {
+		c.Skip("filesystem is writable")
+	}
+
+	_, err := s.FS.Create("foo")
+	c.Assert(err, check.NotNil)
+}
@@ -1,11 +1,12 @@
 package test
 
 import (
+	"errors"
 	"os"
 
-	. "gopkg.in/check.v1"
 	. "github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
 )
 
 // ChrootSuite is a convenient test suite to validate any implementation of
@@ -17,107 +18,126 @@ type ChrootSuite struct {
 	}
 }
 
-func (s *ChrootSuite) TestCreateWithChroot(c *C) {
+func (s *ChrootSuite) TestCreateWithChroot(c *check.C) {
 	fs, _ := s.FS.Chroot("foo")
 	f, err := fs.Create("bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
-	c.Assert(f.Name(), Equals, "bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+	c.Assert(f.Name(), check.Equals, "bar")
 
 	f, err = s.FS.Open("foo/bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, s.FS.Join("foo", "bar"))
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, s.FS.Join("foo", "bar"))
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *ChrootSuite) TestOpenWithChroot(c *C) {
+func (s *ChrootSuite) TestOpenWithChroot(c *check.C) {
 	fs, _ := s.FS.Chroot("foo")
 	f, err := fs.Create("bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
-	c.Assert(f.Name(), Equals, "bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+	c.Assert(f.Name(), check.Equals, "bar")
 
 	f, err = fs.Open("bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Name(), Equals, "bar")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Name(), check.Equals, "bar")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *ChrootSuite) TestOpenOutOffBoundary(c *C) {
+func (s *ChrootSuite) TestPathErrorsWithChroot(c *check.C) {
+	err := util.WriteFile(s.FS, "foo/baz", nil, 0644)
+	c.Assert(err, check.IsNil)
+
+	fs, _ := s.FS.Chroot("foo")
+	_, err = fs.Open("missing")
+
+	var pe *os.PathError
+	c.Assert(errors.As(err, &pe), check.Equals, true, check.Commentf("%#v", err))
+	c.Assert(pe.Path, check.Equals, "missing")
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+
+	err = fs.Rename("missing", "bar")
+	var le *os.LinkError
+	c.Assert(errors.As(err, &le), check.Equals, true, check.Commentf("%#v", err))
+	c.Assert(le.Old, check.Equals, "missing")
+	c.Assert(le.New, check.Equals, "bar")
+}
+
+func (s *ChrootSuite) TestOpenOutOffBoundary(c *check.C) {
 	err := util.WriteFile(s.FS, "bar", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fs, _ := s.FS.Chroot("foo")
 	f, err := fs.Open("../bar")
-	c.Assert(err, Equals, ErrCrossedBoundary)
-	c.Assert(f, IsNil)
+	c.Assert(err, check.Equals, ErrCrossedBoundary)
+	c.Assert(f, check.IsNil)
 }
 
-func (s *ChrootSuite) TestStatOutOffBoundary(c *C) {
+func (s *ChrootSuite) TestStatOutOffBoundary(c *check.C) {
 	err := util.WriteFile(s.FS, "bar", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fs, _ := s.FS.Chroot("foo")
 	f, err := fs.Stat("../bar")
-	c.Assert(err, Equals, ErrCrossedBoundary)
-	c.Assert(f, IsNil)
+	c.Assert(err, check.Equals, ErrCrossedBoundary)
+	c.Assert(f, check.IsNil)
 }
 
-func (s *ChrootSuite) TestStatWithChroot(c *C) {
+func (s *ChrootSuite) TestStatWithChroot(c *check.C) {
 	files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	// Some implementations detect directories based on a prefix
 	// for all files; it's easy to miss path separator handling there.
 	fi, err := s.FS.Stat("qu")
-	c.Assert(os.IsNotExist(err), Equals, true, Commentf("error: %s", err))
-	c.Assert(fi, IsNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true, check.Commentf("error: %s", err))
+	c.Assert(fi, check.IsNil)
 
 	fi, err = s.FS.Stat("qux")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "qux")
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "qux")
+	c.Assert(fi.IsDir(), check.Equals, true)
 
 	qux, _ := s.FS.Chroot("qux")
 
 	fi, err = qux.Stat("baz")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "baz")
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "baz")
+	c.Assert(fi.IsDir(), check.Equals, false)
 
 	fi, err = qux.Stat("/baz")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "baz")
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "baz")
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *ChrootSuite) TestRenameOutOffBoundary(c *C) {
+func (s *ChrootSuite) TestRenameOutOffBoundary(c *check.C) {
 	err := util.WriteFile(s.FS, "foo/foo", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = util.WriteFile(s.FS, "bar", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fs, _ := s.FS.Chroot("foo")
 	err = fs.Rename("../bar", "foo")
-	c.Assert(err, Equals, ErrCrossedBoundary)
+	c.Assert(err, check.Equals, ErrCrossedBoundary)
 
 	err = fs.Rename("foo", "../bar")
-	c.Assert(err, Equals, ErrCrossedBoundary)
+	c.Assert(err, check.Equals, ErrCrossedBoundary)
 }
 
-func (s *ChrootSuite) TestRemoveOutOffBoundary(c *C) {
+func (s *ChrootSuite) TestRemoveOutOffBoundary(c *check.C) {
 	err := util.WriteFile(s.FS, "bar", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fs, _ := s.FS.Chroot("foo")
 	err = fs.Remove("../bar")
-	c.Assert(err, Equals, ErrCrossedBoundary)
+	c.Assert(err, check.Equals, ErrCrossedBoundary)
 }
 
-func (s *FilesystemSuite) TestRoot(c *C) {
-	c.Assert(s.FS.Root(), Not(Equals), "")
+func (s *FilesystemSuite) TestRoot(c *check.C) {
+	c.Assert(s.FS.Root(), check.Not(check.Equals), "")
 }
@@ -1,12 +1,13 @@
 package test
 
 import (
+	"io"
 	"os"
 	"strconv"
 
-	. "gopkg.in/check.v1"
 	. "github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
 )
 
 // DirSuite is a convenient test suite to validate any implementation of
@@ -18,125 +19,155 @@ type DirSuite struct {
 	}
 }
 
-func (s *DirSuite) TestMkdirAll(c *C) {
+func (s *DirSuite) TestMkdirAll(c *check.C) {
 	err := s.FS.MkdirAll("empty", os.FileMode(0755))
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := s.FS.Stat("empty")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
 }
 
-func (s *DirSuite) TestMkdirAllNested(c *C) {
+func (s *DirSuite) TestMkdirAllNested(c *check.C) {
 	err := s.FS.MkdirAll("foo/bar/baz", os.FileMode(0755))
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := s.FS.Stat("foo/bar/baz")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
 
 	fi, err = s.FS.Stat("foo/bar")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
 
 	fi, err = s.FS.Stat("foo")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
 }
 
-func (s *DirSuite) TestMkdirAllIdempotent(c *C) {
+func (s *DirSuite) TestMkdirAllIdempotent(c *check.C) {
 	err := s.FS.MkdirAll("empty", 0755)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	fi, err := s.FS.Stat("empty")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
 
 	// idempotent
 	err = s.FS.MkdirAll("empty", 0755)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 	fi, err = s.FS.Stat("empty")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
 }
 
-func (s *DirSuite) TestMkdirAllAndCreate(c *C) {
+func (s *DirSuite) TestMkdirAllAndCreate(c *check.C) {
 	err := s.FS.MkdirAll("dir", os.FileMode(0755))
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Create("dir/bar/foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	fi, err := s.FS.Stat("dir/bar/foo")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *DirSuite) TestMkdirAllWithExistingFile(c *C) {
+func (s *DirSuite) TestMkdirAllWithExistingFile(c *check.C) {
 	f, err := s.FS.Create("dir/foo")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
 	err = s.FS.MkdirAll("dir/foo", os.FileMode(0755))
-	c.Assert(err, NotNil)
+	c.Assert(err, check.NotNil)
 
 	fi, err := s.FS.Stat("dir/foo")
-	c.Assert(err, IsNil)
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *DirSuite) TestStatDir(c *C) {
+func (s *DirSuite) TestStatDir(c *check.C) {
 	s.FS.MkdirAll("foo/bar", 0755)
 
 	fi, err := s.FS.Stat("foo/bar")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "bar")
-	c.Assert(fi.Mode().IsDir(), Equals, true)
-	c.Assert(fi.ModTime().IsZero(), Equals, false)
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "bar")
+	c.Assert(fi.Mode().IsDir(), check.Equals, true)
+	c.Assert(fi.ModTime().IsZero(), check.Equals, false)
+	c.Assert(fi.IsDir(), check.Equals, true)
 }
 
-func (s *BasicSuite) TestStatDeep(c *C) {
+func (s *BasicSuite) TestStatDeep(c *check.C) {
 	files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	// Some implementations detect directories based on a prefix
 	// for all files; it's easy to miss path separator handling there.
 	fi, err := s.FS.Stat("qu")
-	c.Assert(os.IsNotExist(err), Equals, true, Commentf("error: %s", err))
-	c.Assert(fi, IsNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true, check.Commentf("error: %s", err))
+	c.Assert(fi, check.IsNil)
 
 	fi, err = s.FS.Stat("qux")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "qux")
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "qux")
+	c.Assert(fi.IsDir(), check.Equals, true)
 
 	fi, err = s.FS.Stat("qux/baz")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "baz")
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "baz")
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *DirSuite) TestReadDir(c *C) {
+func (s *DirSuite) TestReadDir(c *check.C) {
 	files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	info, err := s.FS.ReadDir("/")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 3)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 3)
 
 	info, err = s.FS.ReadDir("/qux")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 2)
 }
 
-func (s *DirSuite) TestReadDirNested(c *C) {
+func (s *DirSuite) TestOpenDir(c *check.C) {
+	files := []string{"foo", "bar", "qux/baz", "qux/qux"}
+	for _, name := range files {
+		err := util.WriteFile(s.FS, name, []byte(name), 0644)
+		c.Assert(err, check.IsNil)
+	}
+
+	infos, err := s.FS.ReadDir("/")
+	c.Assert(err, check.IsNil)
+	want := make(map[string]bool)
+	for _, fi := range infos {
+		want[fi.Name()] = fi.IsDir()
+	}
+
+	d, err := OpenDir(s.FS, "/")
+	c.Assert(err, check.IsNil)
+	defer d.Close()
+
+	got := make(map[string]bool)
+	for {
+		e, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		got[e.Name()] = e.IsDir()
+	}
+	c.Assert(got, check.DeepEquals, want)
+}
+
+func (s *DirSuite) TestReadDirNested(c *check.C) {
 	max := 100
 	path := "/"
 	for i := 0; i <= max; i++ {
@@ -146,121 +177,177 @@ func (s *DirSuite) TestReadDirNested(c *C) {
 	files := []string{s.FS.Join(path, "f1"), s.FS.Join(path, "f2")}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	path = "/"
 	for i := 0; i < max; i++ {
 		path = s.FS.Join(path, strconv.Itoa(i))
 		info, err := s.FS.ReadDir(path)
-		c.Assert(err, IsNil)
-		c.Assert(info, HasLen, 1)
+		c.Assert(err, check.IsNil)
+		c.Assert(info, check.HasLen, 1)
 	}
 
 	path = s.FS.Join(path, strconv.Itoa(max))
 	info, err := s.FS.ReadDir(path)
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 2)
 }
 
-func (s *DirSuite) TestReadDirWithMkDirAll(c *C) {
+func (s *DirSuite) TestReadDirWithMkDirAll(c *check.C) {
 	err := s.FS.MkdirAll("qux", 0755)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	files := []string{"qux/baz", "qux/qux"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	info, err := s.FS.ReadDir("/")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 1)
-	c.Assert(info[0].IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 1)
+	c.Assert(info[0].IsDir(), check.Equals, true)
 
 	info, err = s.FS.ReadDir("/qux")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 2)
 }
 
-func (s *DirSuite) TestReadDirFileInfo(c *C) {
+func (s *DirSuite) TestReadDirFileInfo(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", []byte{'F', 'O', 'O'}, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	info, err := s.FS.ReadDir("/")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 1)
 
-	c.Assert(info[0].Size(), Equals, int64(3))
-	c.Assert(info[0].IsDir(), Equals, false)
-	c.Assert(info[0].Name(), Equals, "foo")
+	c.Assert(info[0].Size(), check.Equals, int64(3))
+	c.Assert(info[0].IsDir(), check.Equals, false)
+	c.Assert(info[0].Name(), check.Equals, "foo")
 }
 
-func (s *DirSuite) TestReadDirFileInfoDirs(c *C) {
+func (s *DirSuite) TestReadDirFileInfoDirs(c *check.C) {
 	files := []string{"qux/baz/foo"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, []byte{'F', 'O', 'O'}, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	info, err := s.FS.ReadDir("qux")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 1)
-	c.Assert(info[0].IsDir(), Equals, true)
-	c.Assert(info[0].Name(), Equals, "baz")
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 1)
+	c.Assert(info[0].IsDir(), check.Equals, true)
+	c.Assert(info[0].Name(), check.Equals, "baz")
 
 	info, err = s.FS.ReadDir("qux/baz")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 1)
-	c.Assert(info[0].Size(), Equals, int64(3))
-	c.Assert(info[0].IsDir(), Equals, false)
-	c.Assert(info[0].Name(), Equals, "foo")
-	c.Assert(info[0].Mode(), Not(Equals), 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 1)
+	c.Assert(info[0].Size(), check.Equals, int64(3))
+	c.Assert(info[0].IsDir(), check.Equals, false)
+	c.Assert(info[0].Name(), check.Equals, "foo")
+	c.Assert(info[0].Mode(), check.Not(check.Equals), 0)
 }
 
-func (s *DirSuite) TestRenameToDir(c *C) {
+func (s *DirSuite) TestRenameToDir(c *check.C) {
 	err := util.WriteFile(s.FS, "foo", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Rename("foo", "bar/qux")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	old, err := s.FS.Stat("foo")
-	c.Assert(old, IsNil)
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(old, check.IsNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 
 	dir, err := s.FS.Stat("bar")
-	c.Assert(dir, NotNil)
-	c.Assert(err, IsNil)
+	c.Assert(dir, check.NotNil)
+	c.Assert(err, check.IsNil)
 
 	file, err := s.FS.Stat("bar/qux")
-	c.Assert(file.Name(), Equals, "qux")
-	c.Assert(err, IsNil)
+	c.Assert(file.Name(), check.Equals, "qux")
+	c.Assert(err, check.IsNil)
 }
 
-func (s *DirSuite) TestRenameDir(c *C) {
+func (s *DirSuite) TestRenameDir(c *check.C) {
 	err := s.FS.MkdirAll("foo", 0755)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = util.WriteFile(s.FS, "foo/bar", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Rename("foo", "bar")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	dirfoo, err := s.FS.Stat("foo")
-	c.Assert(dirfoo, IsNil)
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(dirfoo, check.IsNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 
 	dirbar, err := s.FS.Stat("bar")
-	c.Assert(err, IsNil)
-	c.Assert(dirbar, NotNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(dirbar, check.NotNil)
 
 	foo, err := s.FS.Stat("foo/bar")
-	c.Assert(os.IsNotExist(err), Equals, true)
-	c.Assert(foo, IsNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+	c.Assert(foo, check.IsNil)
 
 	bar, err := s.FS.Stat("bar/bar")
-	c.Assert(err, IsNil)
-	c.Assert(bar, NotNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(bar, check.NotNil)
+}
+
+func (s *DirSuite) TestRenameOverFile(c *check.C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), check.IsNil)
+	c.Assert(util.WriteFile(s.FS, "bar", []byte("bar"), 0644), check.IsNil)
+
+	c.Assert(s.FS.Rename("foo", "bar"), check.IsNil)
+
+	data, err := util.ReadFile(s.FS, "bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "foo")
+
+	_, err = s.FS.Stat("foo")
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *DirSuite) TestRenameFileOverDir(c *check.C) {
+	c.Assert(util.WriteFile(s.FS, "foo", []byte("foo"), 0644), check.IsNil)
+	c.Assert(s.FS.MkdirAll("bar", 0755), check.IsNil)
+
+	c.Assert(s.FS.Rename("foo", "bar"), check.NotNil)
+
+	fi, err := s.FS.Stat("foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, false)
+	fi, err = s.FS.Stat("bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
+}
+
+func (s *DirSuite) TestRenameDirOverFile(c *check.C) {
+	c.Assert(s.FS.MkdirAll("foo", 0755), check.IsNil)
+	c.Assert(util.WriteFile(s.FS, "bar", []byte("bar"), 0644), check.IsNil)
+
+	c.Assert(s.FS.Rename("foo", "bar"), check.NotNil)
+
+	fi, err := s.FS.Stat("foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
+	data, err := util.ReadFile(s.FS, "bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "bar")
+}
+
+func (s *DirSuite) TestRenameDirOverNonEmptyDir(c *check.C) {
+	c.Assert(util.WriteFile(s.FS, "foo/qux", []byte("foo"), 0644), check.IsNil)
+	c.Assert(util.WriteFile(s.FS, "bar/qux", []byte("bar"), 0644), check.IsNil)
+
+	c.Assert(s.FS.Rename("foo", "bar"), check.NotNil)
+
+	data, err := util.ReadFile(s.FS, "foo/qux")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "foo")
+	data, err = util.ReadFile(s.FS, "bar/qux")
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "bar")
 }
@@ -0,0 +1,349 @@
+// Package faultfs provides a billy filesystem wrapper which injects scripted
+// failures, to exercise the error paths of code using billy.
+package faultfs // import "github.com/go-git/go-billy/v5/test/faultfs"
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// Op identifies an operation which can fail.
+type Op string
+
+// Filesystem operations.
+const (
+	Create   Op = "create"
+	Open     Op = "open"
+	Stat     Op = "stat"
+	Rename   Op = "rename"
+	Remove   Op = "remove"
+	TempFile Op = "tempfile"
+	ReadDir  Op = "readdir"
+	MkdirAll Op = "mkdirall"
+	Lstat    Op = "lstat"
+	Symlink  Op = "symlink"
+	Readlink Op = "readlink"
+)
+
+// File operations, matched against the name of the file.
+const (
+	Read     Op = "read"
+	ReadAt   Op = "readat"
+	Write    Op = "write"
+	Seek     Op = "seek"
+	Close    Op = "close"
+	Lock     Op = "lock"
+	Unlock   Op = "unlock"
+	Truncate Op = "truncate"
+)
+
+// Rule describes a failure to inject.
+type Rule struct {
+	// Op is the operation to fail. Open matches Open and OpenFile, Create
+	// matches Create and OpenFile with os.O_CREATE.
+	Op Op
+	// Path selects the calls to fail. It is matched against the cleaned,
+	// slash separated path with path.Match, so it may contain wildcards. A
+	// leading slash is optional. An empty Path matches every call. For
+	// Rename, both the old and the new path are matched.
+	Path string
+	// Nth, if greater than zero, only fails the Nth matching call. Otherwise
+	// every matching call fails.
+	Nth int
+	// Err is the error returned, wrapped in an *os.PathError or, for Rename,
+	// an *os.LinkError.
+	Err error
+
+	calls int
+}
+
+func (r *Rule) match(op Op, paths ...string) bool {
+	if r.Op != op {
+		return false
+	}
+
+	if r.Path != "" {
+		matched := false
+		pattern := normalize(r.Path)
+		for _, p := range paths {
+			if ok, _ := path.Match(pattern, normalize(p)); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	r.calls++
+	return r.Nth <= 0 || r.calls == r.Nth
+}
+
+func normalize(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// FS is a billy.Filesystem which forwards every call to the wrapped
+// filesystem, unless a rule makes it fail.
+type FS struct {
+	underlying billy.Filesystem
+
+	m     sync.Mutex
+	rules []*Rule
+}
+
+// New creates a new filesystem wrapping up the given 'fs'. No failure is
+// injected until Inject is called.
+func New(fs billy.Filesystem) *FS {
+	return &FS{underlying: fs}
+}
+
+// Inject adds a rule. Rules are evaluated in the order they were added, the
+// first one matching a call determines the returned error.
+func (fs *FS) Inject(r Rule) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	fs.rules = append(fs.rules, &r)
+}
+
+// Reset removes all the rules.
+func (fs *FS) Reset() {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	fs.rules = nil
+}
+
+func (fs *FS) fault(op Op, paths ...string) error {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	for _, r := range fs.rules {
+		if r.match(op, paths...) {
+			return r.Err
+		}
+	}
+
+	return nil
+}
+
+func (fs *FS) pathFault(op Op, name string) error {
+	if err := fs.fault(op, name); err != nil {
+		return &os.PathError{Op: string(op), Path: name, Err: err}
+	}
+
+	return nil
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	if err := fs.pathFault(Create, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.wrap(fs.underlying.Create(filename))
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	if err := fs.pathFault(Open, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.wrap(fs.underlying.Open(filename))
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	op := Open
+	if flag&os.O_CREATE != 0 {
+		op = Create
+	}
+
+	if err := fs.pathFault(op, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.wrap(fs.underlying.OpenFile(filename, flag, perm))
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	if err := fs.pathFault(Stat, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.Stat(filename)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	if err := fs.fault(Rename, from, to); err != nil {
+		return &os.LinkError{Op: string(Rename), Old: from, New: to, Err: err}
+	}
+
+	return fs.underlying.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	if err := fs.pathFault(Remove, filename); err != nil {
+		return err
+	}
+
+	return fs.underlying.Remove(filename)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	if err := fs.pathFault(TempFile, dir); err != nil {
+		return nil, err
+	}
+
+	return fs.wrap(fs.underlying.TempFile(dir, prefix))
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	if err := fs.pathFault(ReadDir, path); err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.ReadDir(path)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	if err := fs.pathFault(MkdirAll, filename); err != nil {
+		return err
+	}
+
+	return fs.underlying.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	if err := fs.pathFault(Lstat, filename); err != nil {
+		return nil, err
+	}
+
+	return fs.underlying.Lstat(filename)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	if err := fs.pathFault(Symlink, link); err != nil {
+		return err
+	}
+
+	return fs.underlying.Symlink(target, link)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	if err := fs.pathFault(Readlink, link); err != nil {
+		return "", err
+	}
+
+	return fs.underlying.Readlink(link)
+}
+
+// Chroot returns a chrooted view of fs; the rules keep applying to the paths
+// as seen from the root of fs.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface. Faults can't be injected
+// into the optional interfaces, which FS doesn't implement.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) wrap(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+type file struct {
+	billy.File
+	fs *FS
+}
+
+func (f *file) fault(op Op) error {
+	return f.fs.pathFault(op, f.Name())
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if err := f.fault(Read); err != nil {
+		return 0, err
+	}
+
+	return f.File.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.fault(ReadAt); err != nil {
+		return 0, err
+	}
+
+	return f.File.ReadAt(p, off)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if err := f.fault(Write); err != nil {
+		return 0, err
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if err := f.fault(Seek); err != nil {
+		return 0, err
+	}
+
+	return f.File.Seek(offset, whence)
+}
+
+// Close always closes the underlying file, even if a failure is injected,
+// so that injected failures don't leak resources.
+func (f *file) Close() error {
+	err := f.File.Close()
+	if ferr := f.fault(Close); ferr != nil {
+		return ferr
+	}
+
+	return err
+}
+
+func (f *file) Lock() error {
+	if err := f.fault(Lock); err != nil {
+		return err
+	}
+
+	return f.File.Lock()
+}
+
+func (f *file) Unlock() error {
+	if err := f.fault(Unlock); err != nil {
+		return err
+	}
+
+	return f.File.Unlock()
+}
+
+func (f *file) Truncate(size int64) error {
+	if err := f.fault(Truncate); err != nil {
+		return err
+	}
+
+	return f.File.Truncate(size)
+}
@@ -0,0 +1,75 @@
+package faultfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New())
+	})
+}
+
+func TestNthWrite(t *testing.T) {
+	fs := New(memfs.New())
+	fs.Inject(Rule{Op: Write, Path: "/x", Nth: 3, Err: syscall.ENOSPC})
+
+	f, err := fs.Create("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for i := 1; i <= 4; i++ {
+		_, err := f.Write([]byte("foo"))
+		if i == 3 {
+			if !errors.Is(err, syscall.ENOSPC) {
+				t.Errorf("write %d: expected ENOSPC, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("write %d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestRenameEXDEV(t *testing.T) {
+	fs := New(memfs.New())
+	fs.Inject(Rule{Op: Rename, Err: syscall.EXDEV})
+	util.WriteFile(fs, "foo", nil, 0644)
+
+	err := fs.Rename("foo", "bar")
+	var lerr *os.LinkError
+	if !errors.As(err, &lerr) || !errors.Is(err, syscall.EXDEV) {
+		t.Fatalf("expected EXDEV link error, got %v", err)
+	}
+
+	fs.Reset()
+	if err := fs.Rename("foo", "bar"); err != nil {
+		t.Errorf("unexpected error after Reset: %v", err)
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	fs := New(memfs.New())
+	fs.Inject(Rule{Op: Create, Path: "dir/*.yaml", Err: syscall.EACCES})
+
+	if err := util.WriteFile(fs, "dir/foo.yaml", nil, 0644); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("expected EACCES, got %v", err)
+	}
+	if err := util.WriteFile(fs, "dir/foo.json", nil, 0644); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := fs.Stat("dir/foo.yaml"); !os.IsNotExist(err) {
+		t.Errorf("file must not be created, got %v", err)
+	}
+}
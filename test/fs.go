@@ -4,9 +4,9 @@ import (
 	"os"
 	"runtime"
 
-	. "gopkg.in/check.v1"
 	. "github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
 )
 
 // FilesystemSuite is a convenient test suite to validate any implementation of
@@ -19,6 +19,7 @@ type FilesystemSuite struct {
 	SymlinkSuite
 	TempFileSuite
 	ChrootSuite
+	CapabilitiesSuite
 }
 
 // NewFilesystemSuite returns a new FilesystemSuite based on the given fs.
@@ -29,90 +30,91 @@ func NewFilesystemSuite(fs Filesystem) FilesystemSuite {
 	s.SymlinkSuite.FS = s.FS
 	s.TempFileSuite.FS = s.FS
 	s.ChrootSuite.FS = s.FS
+	s.CapabilitiesSuite.FS = s.FS
 
 	return s
 }
 
-func (s *FilesystemSuite) TestSymlinkToDir(c *C) {
+func (s *FilesystemSuite) TestSymlinkToDir(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := s.FS.MkdirAll("dir", 0755)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("dir", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := s.FS.Stat("link")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "link")
-	c.Assert(fi.IsDir(), Equals, true)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "link")
+	c.Assert(fi.IsDir(), check.Equals, true)
 }
 
-func (s *FilesystemSuite) TestSymlinkReadDir(c *C) {
+func (s *FilesystemSuite) TestSymlinkReadDir(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("dir", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	info, err := s.FS.ReadDir("link")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 1)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 1)
 
-	c.Assert(info[0].Size(), Equals, int64(3))
-	c.Assert(info[0].IsDir(), Equals, false)
-	c.Assert(info[0].Name(), Equals, "file")
+	c.Assert(info[0].Size(), check.Equals, int64(3))
+	c.Assert(info[0].IsDir(), check.Equals, false)
+	c.Assert(info[0].Name(), check.Equals, "file")
 }
 
-func (s *FilesystemSuite) TestCreateWithExistantDir(c *C) {
+func (s *FilesystemSuite) TestCreateWithExistantDir(c *check.C) {
 	err := s.FS.MkdirAll("foo", 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Create("foo")
-	c.Assert(err, NotNil)
-	c.Assert(f, IsNil)
+	c.Assert(err, check.NotNil)
+	c.Assert(f, check.IsNil)
 }
 
-func (s *ChrootSuite) TestReadDirWithChroot(c *C) {
+func (s *ChrootSuite) TestReadDirWithChroot(c *check.C) {
 	files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	qux, _ := s.FS.Chroot("/qux")
 
 	info, err := qux.(Filesystem).ReadDir("/")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 2)
 }
 
-func (s *FilesystemSuite) TestSymlinkWithChrootBasic(c *C) {
+func (s *FilesystemSuite) TestSymlinkWithChrootBasic(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	qux, _ := s.FS.Chroot("/qux")
 
 	err := util.WriteFile(qux, "file", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = qux.(Filesystem).Symlink("file", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := qux.Stat("link")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "link")
 
 	fi, err = s.FS.Stat("qux/link")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "link")
 }
 
-func (s *FilesystemSuite) TestSymlinkWithChrootCrossBounders(c *C) {
+func (s *FilesystemSuite) TestSymlinkWithChrootCrossBounders(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
@@ -120,14 +122,14 @@ func (s *FilesystemSuite) TestSymlinkWithChrootCrossBounders(c *C) {
 	util.WriteFile(s.FS, "file", []byte("foo"), customMode)
 
 	err := qux.Symlink("../../file", "qux/link")
-	c.Assert(err, Equals, nil)
+	c.Assert(err, check.Equals, nil)
 
 	fi, err := qux.Stat("qux/link")
-	c.Assert(fi, NotNil)
-	c.Assert(err, Equals, nil)
+	c.Assert(fi, check.NotNil)
+	c.Assert(err, check.Equals, nil)
 }
 
-func (s *FilesystemSuite) TestReadDirWithLink(c *C) {
+func (s *FilesystemSuite) TestReadDirWithLink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
@@ -135,23 +137,23 @@ func (s *FilesystemSuite) TestReadDirWithLink(c *C) {
 	s.FS.Symlink("bar", "foo/qux")
 
 	info, err := s.FS.ReadDir("/foo")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 2)
 }
 
-func (s *FilesystemSuite) TestRemoveAllNonExistent(c *C) {
-	c.Assert(util.RemoveAll(s.FS, "non-existent"), IsNil)
+func (s *FilesystemSuite) TestRemoveAllNonExistent(c *check.C) {
+	c.Assert(util.RemoveAll(s.FS, "non-existent"), check.IsNil)
 }
 
-func (s *FilesystemSuite) TestRemoveAllEmptyDir(c *C) {
-	c.Assert(s.FS.MkdirAll("empty", os.FileMode(0755)), IsNil)
-	c.Assert(util.RemoveAll(s.FS, "empty"), IsNil)
+func (s *FilesystemSuite) TestRemoveAllEmptyDir(c *check.C) {
+	c.Assert(s.FS.MkdirAll("empty", os.FileMode(0755)), check.IsNil)
+	c.Assert(util.RemoveAll(s.FS, "empty"), check.IsNil)
 	_, err := s.FS.Stat("empty")
-	c.Assert(err, NotNil)
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(err, check.NotNil)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 }
 
-func (s *FilesystemSuite) TestRemoveAll(c *C) {
+func (s *FilesystemSuite) TestRemoveAll(c *check.C) {
 	fnames := []string{
 		"foo/1",
 		"foo/2",
@@ -165,19 +167,19 @@ func (s *FilesystemSuite) TestRemoveAll(c *C) {
 
 	for _, fname := range fnames {
 		err := util.WriteFile(s.FS, fname, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
-	c.Assert(util.RemoveAll(s.FS, "foo"), IsNil)
+	c.Assert(util.RemoveAll(s.FS, "foo"), check.IsNil)
 
 	for _, fname := range fnames {
 		_, err := s.FS.Stat(fname)
-		comment := Commentf("not removed: %s %s", fname, err)
-		c.Assert(os.IsNotExist(err), Equals, true, comment)
+		comment := check.Commentf("not removed: %s %s", fname, err)
+		c.Assert(os.IsNotExist(err), check.Equals, true, comment)
 	}
 }
 
-func (s *FilesystemSuite) TestRemoveAllRelative(c *C) {
+func (s *FilesystemSuite) TestRemoveAllRelative(c *check.C) {
 	fnames := []string{
 		"foo/1",
 		"foo/2",
@@ -191,33 +193,33 @@ func (s *FilesystemSuite) TestRemoveAllRelative(c *C) {
 
 	for _, fname := range fnames {
 		err := util.WriteFile(s.FS, fname, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
-	c.Assert(util.RemoveAll(s.FS, "foo/bar/.."), IsNil)
+	c.Assert(util.RemoveAll(s.FS, "foo/bar/.."), check.IsNil)
 
 	for _, fname := range fnames {
 		_, err := s.FS.Stat(fname)
-		comment := Commentf("not removed: %s %s", fname, err)
-		c.Assert(os.IsNotExist(err), Equals, true, comment)
+		comment := check.Commentf("not removed: %s %s", fname, err)
+		c.Assert(os.IsNotExist(err), check.Equals, true, comment)
 	}
 }
 
-func (s *FilesystemSuite) TestReadDir(c *C) {
+func (s *FilesystemSuite) TestReadDir(c *check.C) {
 	err := s.FS.MkdirAll("qux", 0755)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	files := []string{"foo", "bar", "qux/baz", "qux/qux"}
 	for _, name := range files {
 		err := util.WriteFile(s.FS, name, nil, 0644)
-		c.Assert(err, IsNil)
+		c.Assert(err, check.IsNil)
 	}
 
 	info, err := s.FS.ReadDir("/")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 3)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 3)
 
 	info, err = s.FS.ReadDir("/qux")
-	c.Assert(err, IsNil)
-	c.Assert(info, HasLen, 2)
+	c.Assert(err, check.IsNil)
+	c.Assert(info, check.HasLen, 2)
 }
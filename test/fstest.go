@@ -0,0 +1,22 @@
+package test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/iofs"
+)
+
+// FSTest runs testing/fstest.TestFS against fs, through the io/fs adapter
+// provided by helper/iofs. expectedFiles lists files, as slash separated
+// paths relative to the root of fs, which must be found; the check walks the
+// whole tree and validates the consistency of Open, Stat, ReadDir and file
+// contents as seen by the standard library.
+func FSTest(t *testing.T, fs billy.Filesystem, expectedFiles ...string) {
+	t.Helper()
+
+	if err := fstest.TestFS(iofs.New(fs), expectedFiles...); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,32 @@
+package test_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func populate(t *testing.T, fs billy.Filesystem, files ...string) {
+	for _, name := range files {
+		if err := util.WriteFile(fs, name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFSTest(t *testing.T) {
+	files := []string{"foo", "bar/baz", "bar/qux/quux", "empty/file"}
+	for name, fs := range map[string]billy.Filesystem{
+		"memfs": memfs.New(),
+		"osfs":  osfs.New(t.TempDir()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			populate(t, fs, files...)
+			test.FSTest(t, fs, files...)
+		})
+	}
+}
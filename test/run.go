@@ -0,0 +1,60 @@
+package test
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"gopkg.in/check.v1"
+)
+
+// Run runs the conformance suites (Basic, Dir, Symlink, TempFile, Chroot and
+// Capabilities) against the filesystems returned by newFS, which is called
+// once per test case and must return an empty filesystem. It allows
+// third-party implementations of billy.Filesystem to validate that they
+// behave like the ones provided by this module:
+//
+//	func TestConformance(t *testing.T) {
+//		test.Run(t, func() billy.Filesystem {
+//			return mybackend.New(t.TempDir())
+//		})
+//	}
+//
+// The -check.f and -check.v flags are honoured.
+func Run(t *testing.T, newFS func() billy.Filesystem) {
+	t.Helper()
+
+	var out bytes.Buffer
+	conf := &check.RunConf{
+		Output:  &out,
+		Filter:  flagValue("check.f"),
+		Verbose: flagValue("check.v") == "true",
+	}
+
+	result := check.Run(&conformanceSuite{newFS: newFS}, conf)
+	if out.Len() > 0 {
+		t.Log(out.String())
+	}
+	if !result.Passed() {
+		t.Error(result.String())
+	}
+}
+
+func flagValue(name string) string {
+	f := flag.Lookup(name)
+	if f == nil {
+		return ""
+	}
+
+	return f.Value.String()
+}
+
+type conformanceSuite struct {
+	FilesystemSuite
+	newFS func() billy.Filesystem
+}
+
+func (s *conformanceSuite) SetUpTest(c *check.C) {
+	s.FilesystemSuite = NewFilesystemSuite(s.newFS())
+}
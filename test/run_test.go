@@ -0,0 +1,21 @@
+package test_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+)
+
+func TestRun(t *testing.T) {
+	var calls int
+	test.Run(t, func() billy.Filesystem {
+		calls++
+		return memfs.New()
+	})
+
+	if calls < 2 {
+		t.Errorf("expected a new filesystem per test case, got %d", calls)
+	}
+}
@@ -0,0 +1,256 @@
+// Package slowfs provides a billy filesystem wrapper which simulates a slow
+// disk or network filesystem, adding latency to every operation and capping
+// the read and write bandwidth.
+package slowfs // import "github.com/go-git/go-billy/v5/test/slowfs"
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// Op identifies an operation which can be delayed.
+type Op string
+
+// Filesystem operations.
+const (
+	Create   Op = "create"
+	Open     Op = "open"
+	Stat     Op = "stat"
+	Rename   Op = "rename"
+	Remove   Op = "remove"
+	TempFile Op = "tempfile"
+	ReadDir  Op = "readdir"
+	MkdirAll Op = "mkdirall"
+	Lstat    Op = "lstat"
+	Symlink  Op = "symlink"
+	Readlink Op = "readlink"
+)
+
+// File operations.
+const (
+	Read     Op = "read"
+	ReadAt   Op = "readat"
+	Write    Op = "write"
+	Seek     Op = "seek"
+	Close    Op = "close"
+	Lock     Op = "lock"
+	Unlock   Op = "unlock"
+	Truncate Op = "truncate"
+)
+
+// Options configures the simulated slowness. The zero value adds no delay.
+type Options struct {
+	// Latency is added to every operation without an entry in OpLatency.
+	Latency time.Duration
+	// OpLatency overrides Latency for specific operations.
+	OpLatency map[Op]time.Duration
+	// ReadBytesPerSecond caps the bandwidth of Read and ReadAt, shared by
+	// all the files of the filesystem. Zero means unlimited.
+	ReadBytesPerSecond int64
+	// WriteBytesPerSecond caps the bandwidth of Write, shared by all the
+	// files of the filesystem. Zero means unlimited.
+	WriteBytesPerSecond int64
+	// Sleep is called to wait, time.Sleep if nil.
+	Sleep func(time.Duration)
+}
+
+// FS is a billy.Filesystem which forwards every call to the wrapped
+// filesystem after the configured delay.
+type FS struct {
+	underlying billy.Filesystem
+	opts       Options
+
+	read  limiter
+	write limiter
+}
+
+// New creates a new filesystem wrapping up the given 'fs'.
+func New(fs billy.Filesystem, opts Options) *FS {
+	if opts.Sleep == nil {
+		opts.Sleep = time.Sleep
+	}
+
+	return &FS{
+		underlying: fs,
+		opts:       opts,
+		read:       limiter{rate: opts.ReadBytesPerSecond, sleep: opts.Sleep},
+		write:      limiter{rate: opts.WriteBytesPerSecond, sleep: opts.Sleep},
+	}
+}
+
+func (fs *FS) delay(op Op) {
+	d, ok := fs.opts.OpLatency[op]
+	if !ok {
+		d = fs.opts.Latency
+	}
+
+	if d > 0 {
+		fs.opts.Sleep(d)
+	}
+}
+
+// limiter serializes the transfers of a filesystem, making each of them last
+// as long as it would at the configured rate.
+type limiter struct {
+	rate  int64
+	sleep func(time.Duration)
+
+	m sync.Mutex
+}
+
+func (l *limiter) wait(n int) {
+	if l.rate <= 0 || n <= 0 {
+		return
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.sleep(time.Duration(int64(n) * int64(time.Second) / l.rate))
+}
+
+func (fs *FS) Create(filename string) (billy.File, error) {
+	fs.delay(Create)
+	return fs.wrap(fs.underlying.Create(filename))
+}
+
+func (fs *FS) Open(filename string) (billy.File, error) {
+	fs.delay(Open)
+	return fs.wrap(fs.underlying.Open(filename))
+}
+
+func (fs *FS) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&os.O_CREATE != 0 {
+		fs.delay(Create)
+	} else {
+		fs.delay(Open)
+	}
+
+	return fs.wrap(fs.underlying.OpenFile(filename, flag, perm))
+}
+
+func (fs *FS) Stat(filename string) (os.FileInfo, error) {
+	fs.delay(Stat)
+	return fs.underlying.Stat(filename)
+}
+
+func (fs *FS) Rename(from, to string) error {
+	fs.delay(Rename)
+	return fs.underlying.Rename(from, to)
+}
+
+func (fs *FS) Remove(filename string) error {
+	fs.delay(Remove)
+	return fs.underlying.Remove(filename)
+}
+
+func (fs *FS) Join(elem ...string) string {
+	return fs.underlying.Join(elem...)
+}
+
+func (fs *FS) TempFile(dir, prefix string) (billy.File, error) {
+	fs.delay(TempFile)
+	return fs.wrap(fs.underlying.TempFile(dir, prefix))
+}
+
+func (fs *FS) ReadDir(path string) ([]os.FileInfo, error) {
+	fs.delay(ReadDir)
+	return fs.underlying.ReadDir(path)
+}
+
+func (fs *FS) MkdirAll(filename string, perm os.FileMode) error {
+	fs.delay(MkdirAll)
+	return fs.underlying.MkdirAll(filename, perm)
+}
+
+func (fs *FS) Lstat(filename string) (os.FileInfo, error) {
+	fs.delay(Lstat)
+	return fs.underlying.Lstat(filename)
+}
+
+func (fs *FS) Symlink(target, link string) error {
+	fs.delay(Symlink)
+	return fs.underlying.Symlink(target, link)
+}
+
+func (fs *FS) Readlink(link string) (string, error) {
+	fs.delay(Readlink)
+	return fs.underlying.Readlink(link)
+}
+
+// Chroot returns a chrooted view of fs, sharing its delays and bandwidth.
+func (fs *FS) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+func (fs *FS) Root() string {
+	return fs.underlying.Root()
+}
+
+// Capabilities implements the Capable interface, without the optional
+// interfaces FS doesn't implement.
+func (fs *FS) Capabilities() billy.Capability {
+	return billy.Capabilities(fs.underlying) &^ billy.InterfaceCapabilities
+}
+
+func (fs *FS) wrap(f billy.File, err error) (billy.File, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs}, nil
+}
+
+type file struct {
+	billy.File
+	fs *FS
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	f.fs.delay(Read)
+	n, err := f.File.Read(p)
+	f.fs.read.wait(n)
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.delay(ReadAt)
+	n, err := f.File.ReadAt(p, off)
+	f.fs.read.wait(n)
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	f.fs.delay(Write)
+	f.fs.write.wait(len(p))
+	return f.File.Write(p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.fs.delay(Seek)
+	return f.File.Seek(offset, whence)
+}
+
+func (f *file) Close() error {
+	f.fs.delay(Close)
+	return f.File.Close()
+}
+
+func (f *file) Lock() error {
+	f.fs.delay(Lock)
+	return f.File.Lock()
+}
+
+func (f *file) Unlock() error {
+	f.fs.delay(Unlock)
+	return f.File.Unlock()
+}
+
+func (f *file) Truncate(size int64) error {
+	f.fs.delay(Truncate)
+	return f.File.Truncate(size)
+}
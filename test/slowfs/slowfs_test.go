@@ -0,0 +1,76 @@
+package slowfs
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+type recorder struct {
+	m     sync.Mutex
+	total time.Duration
+	calls int
+}
+
+func (r *recorder) sleep(d time.Duration) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.total += d
+	r.calls++
+}
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), Options{Latency: time.Second, Sleep: func(time.Duration) {}})
+	})
+}
+
+func TestLatency(t *testing.T) {
+	r := &recorder{}
+	fs := New(memfs.New(), Options{
+		Latency:   time.Millisecond,
+		OpLatency: map[Op]time.Duration{Stat: 10 * time.Millisecond, Close: 0},
+		Sleep:     r.sleep,
+	})
+
+	f, err := fs.Create("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fs.Stat("foo")
+
+	if r.total != 11*time.Millisecond || r.calls != 2 {
+		t.Errorf("expected 11ms in 2 calls, got %s in %d", r.total, r.calls)
+	}
+}
+
+func TestBandwidth(t *testing.T) {
+	r := &recorder{}
+	fs := New(memfs.New(), Options{
+		ReadBytesPerSecond:  1000,
+		WriteBytesPerSecond: 500,
+		Sleep:               r.sleep,
+	})
+
+	if err := util.WriteFile(fs, "foo", make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r.total != 2*time.Second {
+		t.Errorf("expected writing to last 2s, got %s", r.total)
+	}
+
+	r.total = 0
+	if _, err := util.ReadFile(fs, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if r.total != time.Second {
+		t.Errorf("expected reading to last 1s, got %s", r.total)
+	}
+}
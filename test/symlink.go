@@ -5,9 +5,9 @@ import (
 	"os"
 	"runtime"
 
-	. "gopkg.in/check.v1"
 	. "github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
 )
 
 // SymlinkSuite is a convenient test suite to validate any implementation of
@@ -19,177 +19,177 @@ type SymlinkSuite struct {
 	}
 }
 
-func (s *SymlinkSuite) TestSymlink(c *C) {
+func (s *SymlinkSuite) TestSymlink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "file", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("file", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := s.FS.Stat("link")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "link")
 }
 
-func (s *SymlinkSuite) TestSymlinkCrossDirs(c *C) {
+func (s *SymlinkSuite) TestSymlinkCrossDirs(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "foo/file", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("../foo/file", "bar/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := s.FS.Stat("bar/link")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "link")
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "link")
 }
 
-func (s *SymlinkSuite) TestSymlinkNested(c *C) {
+func (s *SymlinkSuite) TestSymlinkNested(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "file", []byte("hello world!"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("file", "linkA")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("linkA", "linkB")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fi, err := s.FS.Stat("linkB")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "linkB")
-	c.Assert(fi.Size(), Equals, int64(12))
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "linkB")
+	c.Assert(fi.Size(), check.Equals, int64(12))
 }
 
-func (s *SymlinkSuite) TestSymlinkWithNonExistentdTarget(c *C) {
+func (s *SymlinkSuite) TestSymlinkWithNonExistentdTarget(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := s.FS.Symlink("file", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	_, err = s.FS.Stat("link")
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 }
 
-func (s *SymlinkSuite) TestSymlinkWithExistingLink(c *C) {
+func (s *SymlinkSuite) TestSymlinkWithExistingLink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "link", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("file", "link")
-	c.Assert(err, Not(IsNil))
+	c.Assert(err, check.Not(check.IsNil))
 }
 
-func (s *SymlinkSuite) TestOpenWithSymlinkToRelativePath(c *C) {
+func (s *SymlinkSuite) TestOpenWithSymlinkToRelativePath(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("file", "dir/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("dir/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	all, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(all), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(all), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *SymlinkSuite) TestOpenWithSymlinkToAbsolutePath(c *C) {
+func (s *SymlinkSuite) TestOpenWithSymlinkToAbsolutePath(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "dir/file", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("/dir/file", "dir/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	f, err := s.FS.Open("dir/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	all, err := ioutil.ReadAll(f)
-	c.Assert(err, IsNil)
-	c.Assert(string(all), Equals, "foo")
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(all), check.Equals, "foo")
+	c.Assert(f.Close(), check.IsNil)
 }
 
-func (s *SymlinkSuite) TestReadlink(c *C) {
+func (s *SymlinkSuite) TestReadlink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "file", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	_, err = s.FS.Readlink("file")
-	c.Assert(err, Not(IsNil))
+	c.Assert(err, check.Not(check.IsNil))
 }
 
-func (s *SymlinkSuite) TestReadlinkWithRelativePath(c *C) {
+func (s *SymlinkSuite) TestReadlinkWithRelativePath(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "dir/file", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("file", "dir/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	oldname, err := s.FS.Readlink("dir/link")
-	c.Assert(err, IsNil)
-	c.Assert(oldname, Equals, "file")
+	c.Assert(err, check.IsNil)
+	c.Assert(oldname, check.Equals, "file")
 }
 
-func (s *SymlinkSuite) TestReadlinkWithAbsolutePath(c *C) {
+func (s *SymlinkSuite) TestReadlinkWithAbsolutePath(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "dir/file", nil, 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("/dir/file", "dir/link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	oldname, err := s.FS.Readlink("dir/link")
-	c.Assert(err, IsNil)
-	c.Assert(oldname, Equals, expectedSymlinkTarget)
+	c.Assert(err, check.IsNil)
+	c.Assert(oldname, check.Equals, expectedSymlinkTarget)
 }
 
-func (s *SymlinkSuite) TestReadlinkWithNonExistentTarget(c *C) {
+func (s *SymlinkSuite) TestReadlinkWithNonExistentTarget(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := s.FS.Symlink("file", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	oldname, err := s.FS.Readlink("link")
-	c.Assert(err, IsNil)
-	c.Assert(oldname, Equals, "file")
+	c.Assert(err, check.IsNil)
+	c.Assert(oldname, check.Equals, "file")
 }
 
-func (s *SymlinkSuite) TestReadlinkWithNonExistentLink(c *C) {
+func (s *SymlinkSuite) TestReadlinkWithNonExistentLink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	_, err := s.FS.Readlink("link")
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 }
 
-func (s *SymlinkSuite) TestStatLink(c *C) {
+func (s *SymlinkSuite) TestStatLink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
@@ -197,27 +197,27 @@ func (s *SymlinkSuite) TestStatLink(c *C) {
 	s.FS.Symlink("bar", "foo/qux")
 
 	fi, err := s.FS.Stat("foo/qux")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "qux")
-	c.Assert(fi.Size(), Equals, int64(3))
-	c.Assert(fi.Mode(), Equals, customMode)
-	c.Assert(fi.ModTime().IsZero(), Equals, false)
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "qux")
+	c.Assert(fi.Size(), check.Equals, int64(3))
+	c.Assert(fi.Mode(), check.Equals, customMode)
+	c.Assert(fi.ModTime().IsZero(), check.Equals, false)
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *SymlinkSuite) TestLstat(c *C) {
+func (s *SymlinkSuite) TestLstat(c *check.C) {
 	util.WriteFile(s.FS, "foo/bar", []byte("foo"), customMode)
 
 	fi, err := s.FS.Lstat("foo/bar")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "bar")
-	c.Assert(fi.Size(), Equals, int64(3))
-	c.Assert(fi.Mode()&os.ModeSymlink != 0, Equals, false)
-	c.Assert(fi.ModTime().IsZero(), Equals, false)
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "bar")
+	c.Assert(fi.Size(), check.Equals, int64(3))
+	c.Assert(fi.Mode()&os.ModeSymlink != 0, check.Equals, false)
+	c.Assert(fi.ModTime().IsZero(), check.Equals, false)
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *SymlinkSuite) TestLstatLink(c *C) {
+func (s *SymlinkSuite) TestLstatLink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
@@ -225,46 +225,46 @@ func (s *SymlinkSuite) TestLstatLink(c *C) {
 	s.FS.Symlink("bar", "foo/qux")
 
 	fi, err := s.FS.Lstat("foo/qux")
-	c.Assert(err, IsNil)
-	c.Assert(fi.Name(), Equals, "qux")
-	c.Assert(fi.Mode()&os.ModeSymlink != 0, Equals, true)
-	c.Assert(fi.ModTime().IsZero(), Equals, false)
-	c.Assert(fi.IsDir(), Equals, false)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Name(), check.Equals, "qux")
+	c.Assert(fi.Mode()&os.ModeSymlink != 0, check.Equals, true)
+	c.Assert(fi.ModTime().IsZero(), check.Equals, false)
+	c.Assert(fi.IsDir(), check.Equals, false)
 }
 
-func (s *SymlinkSuite) TestRenameWithSymlink(c *C) {
+func (s *SymlinkSuite) TestRenameWithSymlink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := s.FS.Symlink("file", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Rename("link", "newlink")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	_, err = s.FS.Readlink("newlink")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 }
 
-func (s *SymlinkSuite) TestRemoveWithSymlink(c *C) {
+func (s *SymlinkSuite) TestRemoveWithSymlink(c *check.C) {
 	if runtime.GOOS == "plan9" {
 		c.Skip("skipping on Plan 9; symlinks are not supported")
 	}
 	err := util.WriteFile(s.FS, "file", []byte("foo"), 0644)
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Symlink("file", "link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	err = s.FS.Remove("link")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	_, err = s.FS.Readlink("link")
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 
 	_, err = s.FS.Stat("link")
-	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
 
 	_, err = s.FS.Stat("file")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 }
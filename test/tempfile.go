@@ -1,11 +1,12 @@
 package test
 
 import (
+	"path/filepath"
 	"strings"
 
-	. "gopkg.in/check.v1"
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/check.v1"
 )
 
 // TempFileSuite is a convenient test suite to validate any implementation of
@@ -17,78 +18,118 @@ type TempFileSuite struct {
 	}
 }
 
-func (s *TempFileSuite) TestTempFile(c *C) {
+func (s *TempFileSuite) TestTempFile(c *check.C) {
 	f, err := s.FS.TempFile("", "bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
-	c.Assert(strings.Index(f.Name(), "bar"), Not(Equals), -1)
+	c.Assert(strings.Index(f.Name(), "bar"), check.Not(check.Equals), -1)
 }
 
-func (s *TempFileSuite) TestTempFileWithPath(c *C) {
+func (s *TempFileSuite) TestTempFileWithPath(c *check.C) {
 	f, err := s.FS.TempFile("foo", "bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
-	c.Assert(strings.HasPrefix(f.Name(), s.FS.Join("foo", "bar")), Equals, true)
+	c.Assert(strings.HasPrefix(f.Name(), s.FS.Join("foo", "bar")), check.Equals, true)
 }
 
-func (s *TempFileSuite) TestTempFileFullWithPath(c *C) {
+func (s *TempFileSuite) TestTempFileFullWithPath(c *check.C) {
 	f, err := s.FS.TempFile("/foo", "bar")
-	c.Assert(err, IsNil)
-	c.Assert(f.Close(), IsNil)
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
 
-	c.Assert(strings.Index(f.Name(), s.FS.Join("foo", "bar")), Not(Equals), -1)
+	c.Assert(strings.Index(f.Name(), s.FS.Join("foo", "bar")), check.Not(check.Equals), -1)
 }
 
-func (s *TempFileSuite) TestRemoveTempFile(c *C) {
+func (s *TempFileSuite) TestRemoveTempFile(c *check.C) {
 	f, err := s.FS.TempFile("test-dir", "test-prefix")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fn := f.Name()
-	c.Assert(f.Close(), IsNil)
-	c.Assert(s.FS.Remove(fn), IsNil)
+	c.Assert(f.Close(), check.IsNil)
+	c.Assert(s.FS.Remove(fn), check.IsNil)
 }
 
-func (s *TempFileSuite) TestRenameTempFile(c *C) {
+func (s *TempFileSuite) TestRenameTempFile(c *check.C) {
 	f, err := s.FS.TempFile("test-dir", "test-prefix")
-	c.Assert(err, IsNil)
+	c.Assert(err, check.IsNil)
 
 	fn := f.Name()
-	c.Assert(f.Close(), IsNil)
-	c.Assert(s.FS.Rename(fn, "other-path"), IsNil)
+	c.Assert(f.Close(), check.IsNil)
+	c.Assert(s.FS.Rename(fn, "other-path"), check.IsNil)
 }
 
-func (s *TempFileSuite) TestTempFileMany(c *C) {
+func (s *TempFileSuite) TestTempFileMany(c *check.C) {
 	for i := 0; i < 1024; i++ {
 		var fs []billy.File
 
 		for j := 0; j < 100; j++ {
 			f, err := s.FS.TempFile("test-dir", "test-prefix")
-			c.Assert(err, IsNil)
+			c.Assert(err, check.IsNil)
 			fs = append(fs, f)
 		}
 
 		for _, f := range fs {
-			c.Assert(f.Close(), IsNil)
-			c.Assert(s.FS.Remove(f.Name()), IsNil)
+			c.Assert(f.Close(), check.IsNil)
+			c.Assert(s.FS.Remove(f.Name()), check.IsNil)
 		}
 	}
 }
 
-func (s *TempFileSuite) TestTempFileManyWithUtil(c *C) {
+func (s *TempFileSuite) TestTempFileManyWithUtil(c *check.C) {
 	for i := 0; i < 1024; i++ {
 		var fs []billy.File
 
 		for j := 0; j < 100; j++ {
 			f, err := util.TempFile(s.FS, "test-dir", "test-prefix")
-			c.Assert(err, IsNil)
+			c.Assert(err, check.IsNil)
 			fs = append(fs, f)
 		}
 
 		for _, f := range fs {
-			c.Assert(f.Close(), IsNil)
-			c.Assert(s.FS.Remove(f.Name()), IsNil)
+			c.Assert(f.Close(), check.IsNil)
+			c.Assert(s.FS.Remove(f.Name()), check.IsNil)
 		}
 	}
 }
+
+func (s *TempFileSuite) TestCreateTemp(c *check.C) {
+	f, err := util.CreateTemp(s.FS, "foo", "bar-*.txt")
+	c.Assert(err, check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+
+	dir, name := filepath.Split(f.Name())
+	c.Assert(filepath.Clean(dir), check.Equals, "foo")
+	c.Assert(strings.HasPrefix(name, "bar-"), check.Equals, true)
+	c.Assert(strings.HasSuffix(name, ".txt"), check.Equals, true)
+	c.Assert(name, check.Not(check.Equals), "bar-.txt")
+
+	fi, err := s.FS.Stat(f.Name())
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.Mode().IsRegular(), check.Equals, true)
+
+	_, err = util.CreateTemp(s.FS, "foo", "../bar")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *TempFileSuite) TestMkdirTemp(c *check.C) {
+	dfs, ok := s.FS.(billy.Dir)
+	if !ok {
+		c.Skip("billy.Dir not supported")
+	}
+
+	a, err := util.MkdirTemp(dfs, "foo", "bar")
+	c.Assert(err, check.IsNil)
+	b, err := util.MkdirTemp(dfs, "foo", "bar")
+	c.Assert(err, check.IsNil)
+	c.Assert(a, check.Not(check.Equals), b)
+
+	c.Assert(strings.HasPrefix(a, s.FS.Join("foo", "bar")), check.Equals, true)
+	fi, err := s.FS.Stat(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(fi.IsDir(), check.Equals, true)
+
+	_, err = util.MkdirTemp(dfs, "foo", "../bar")
+	c.Assert(err, check.NotNil)
+}
@@ -0,0 +1,183 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// DivergenceError is returned by Replay when an operation didn't behave as
+// recorded: it failed while the recorded one succeeded or the other way
+// around, or it read or wrote a different number of bytes.
+type DivergenceError struct {
+	Event Event
+	// Err is the error returned by the replayed operation, if any.
+	Err error
+	// N is the number of bytes read or written by the replayed operation.
+	N int
+}
+
+func (e *DivergenceError) Error() string {
+	expected := "success"
+	if e.Event.Err != "" {
+		expected = fmt.Sprintf("error %q", e.Event.Err)
+	}
+
+	actual := "success"
+	if e.Err != nil {
+		actual = fmt.Sprintf("error %q", e.Err)
+	}
+
+	if (e.Event.Err == "") == (e.Err == nil) {
+		return fmt.Sprintf("event %d (%s %s): expected %d bytes, got %d",
+			e.Event.Seq, e.Event.Op, e.Event.Path, e.Event.N, e.N)
+	}
+
+	return fmt.Sprintf("event %d (%s %s): expected %s, got %s",
+		e.Event.Seq, e.Event.Op, e.Event.Path, expected, actual)
+}
+
+func (e *DivergenceError) Unwrap() error {
+	return e.Err
+}
+
+// Replay performs the operations of the trace read from r on fs. It stops
+// at the first operation diverging from the trace, returning a
+// *DivergenceError. Files left open by the trace are closed on return.
+func Replay(fs billy.Filesystem, r io.Reader) error {
+	p := &player{
+		fs:    fs,
+		files: make(map[int]billy.File),
+		names: make(map[string]string),
+	}
+	defer p.closeAll()
+
+	dec := json.NewDecoder(r)
+	for {
+		var e Event
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := p.play(e); err != nil {
+			return err
+		}
+	}
+}
+
+type player struct {
+	fs    billy.Filesystem
+	files map[int]billy.File
+	// names maps the temporary file names of the trace to the ones created
+	// while replaying.
+	names map[string]string
+}
+
+func (p *player) closeAll() {
+	for _, f := range p.files {
+		_ = f.Close()
+	}
+}
+
+func (p *player) path(name string) string {
+	if n, ok := p.names[name]; ok {
+		return n
+	}
+
+	return name
+}
+
+func (p *player) play(e Event) error {
+	var (
+		f   billy.File
+		err error
+	)
+	n := e.N
+
+	switch e.Op {
+	case OpCreate:
+		f, err = p.fs.Create(p.path(e.Path))
+	case OpOpen:
+		f, err = p.fs.Open(p.path(e.Path))
+	case OpOpenFile:
+		f, err = p.fs.OpenFile(p.path(e.Path), e.Flag, e.Perm)
+	case OpTempFile:
+		f, err = p.fs.TempFile(p.path(e.Path), e.To)
+		if err == nil {
+			p.names[e.Result] = f.Name()
+		}
+	case OpStat:
+		_, err = p.fs.Stat(p.path(e.Path))
+	case OpLstat:
+		_, err = p.fs.Lstat(p.path(e.Path))
+	case OpRename:
+		err = p.fs.Rename(p.path(e.Path), p.path(e.To))
+	case OpRemove:
+		err = p.fs.Remove(p.path(e.Path))
+	case OpReadDir:
+		_, err = p.fs.ReadDir(p.path(e.Path))
+	case OpMkdirAll:
+		err = p.fs.MkdirAll(p.path(e.Path), e.Perm)
+	case OpSymlink:
+		err = p.fs.Symlink(e.To, p.path(e.Path))
+	case OpReadlink:
+		_, err = p.fs.Readlink(p.path(e.Path))
+	default:
+		opened, ok := p.files[e.File]
+		if !ok {
+			return fmt.Errorf("event %d: unknown file %d", e.Seq, e.File)
+		}
+
+		n, err = p.playFile(opened, e)
+		if e.Op == OpClose {
+			delete(p.files, e.File)
+		}
+	}
+
+	if f != nil {
+		p.files[e.File] = f
+	}
+
+	if (err == nil) != (e.Err == "") || n != e.N {
+		return &DivergenceError{Event: e, Err: err, N: n}
+	}
+
+	return nil
+}
+
+func (p *player) playFile(f billy.File, e Event) (int, error) {
+	switch e.Op {
+	case OpRead:
+		n, err := f.Read(make([]byte, e.Size))
+		return n, ignoreEOF(err)
+	case OpReadAt:
+		n, err := f.ReadAt(make([]byte, e.Size), e.Offset)
+		return n, ignoreEOF(err)
+	case OpWrite:
+		data := e.Data
+		if int64(len(data)) != e.Size {
+			data = bytes.Repeat([]byte{'x'}, int(e.Size))
+		}
+		return f.Write(data)
+	case OpSeek:
+		_, err := f.Seek(e.Offset, e.Whence)
+		return e.N, err
+	case OpClose:
+		return e.N, f.Close()
+	case OpLock:
+		return e.N, f.Lock()
+	case OpUnlock:
+		return e.N, f.Unlock()
+	case OpTruncate:
+		return e.N, f.Truncate(e.Size)
+	}
+
+	return 0, fmt.Errorf("event %d: unknown operation %q", e.Seq, e.Op)
+}
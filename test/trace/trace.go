@@ -0,0 +1,299 @@
+// Package trace records the sequence of operations performed on a billy
+// filesystem into a machine readable log, which can be replayed against any
+// other backend. It helps turning a bug report from a user environment into
+// a reproducer.
+//
+// The log is a stream of JSON objects, one Event per line.
+package trace // import "github.com/go-git/go-billy/v5/test/trace"
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+)
+
+// Operation names, as found in Event.Op.
+const (
+	OpCreate   = "create"
+	OpOpen     = "open"
+	OpOpenFile = "openfile"
+	OpStat     = "stat"
+	OpRename   = "rename"
+	OpRemove   = "remove"
+	OpTempFile = "tempfile"
+	OpReadDir  = "readdir"
+	OpMkdirAll = "mkdirall"
+	OpLstat    = "lstat"
+	OpSymlink  = "symlink"
+	OpReadlink = "readlink"
+	OpRead     = "read"
+	OpReadAt   = "readat"
+	OpWrite    = "write"
+	OpSeek     = "seek"
+	OpClose    = "close"
+	OpLock     = "lock"
+	OpUnlock   = "unlock"
+	OpTruncate = "truncate"
+)
+
+// Event is a recorded operation.
+type Event struct {
+	// Seq is the position of the event in the trace, starting at 1.
+	Seq int    `json:"seq"`
+	Op  string `json:"op"`
+	// Path is the path the operation acts on; the link for Symlink and the
+	// source for Rename.
+	Path string `json:"path,omitempty"`
+	// To is the destination for Rename and the target for Symlink.
+	To   string      `json:"to,omitempty"`
+	Flag int         `json:"flag,omitempty"`
+	Perm os.FileMode `json:"perm,omitempty"`
+	// File identifies the file an operation acts on. For the operations
+	// opening a file, it is the identifier assigned to the opened file.
+	File int `json:"file,omitempty"`
+	// Data holds the bytes written by Write, unless the contents are
+	// anonymized.
+	Data []byte `json:"data,omitempty"`
+	// Size is the length of the buffer given to Read, ReadAt and Write, and
+	// the size given to Truncate.
+	Size   int64 `json:"size,omitempty"`
+	Offset int64 `json:"offset,omitempty"`
+	Whence int   `json:"whence,omitempty"`
+	// N is the number of bytes read or written.
+	N int `json:"n,omitempty"`
+	// Result is the name of the file created by TempFile.
+	Result string `json:"result,omitempty"`
+	// Err is the message of the error returned by the operation, if any.
+	Err string `json:"err,omitempty"`
+}
+
+// Options configures a Recorder.
+type Options struct {
+	// Anonymize omits the written data from the trace, only its length is
+	// recorded. Replaying writes filler bytes instead.
+	Anonymize bool
+}
+
+// Recorder is a billy.Filesystem which forwards every call to the wrapped
+// filesystem and records it.
+type Recorder struct {
+	underlying billy.Filesystem
+	opts       Options
+
+	m    sync.Mutex
+	enc  *json.Encoder
+	seq  int
+	fd   int
+	werr error
+}
+
+// New creates a new filesystem wrapping up the given 'fs', writing the trace
+// of its operations to w.
+func New(fs billy.Filesystem, w io.Writer, opts Options) *Recorder {
+	return &Recorder{underlying: fs, opts: opts, enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error encountered writing the trace.
+func (r *Recorder) Err() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.werr
+}
+
+func (r *Recorder) record(e Event, err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.seq++
+	e.Seq = r.seq
+	if err != nil {
+		e.Err = err.Error()
+	}
+
+	if werr := r.enc.Encode(e); werr != nil && r.werr == nil {
+		r.werr = werr
+	}
+}
+
+func (r *Recorder) open(e Event, f billy.File, err error) (billy.File, error) {
+	if err == nil {
+		r.m.Lock()
+		r.fd++
+		e.File = r.fd
+		r.m.Unlock()
+	}
+
+	if e.Op == OpTempFile && err == nil {
+		e.Result = f.Name()
+	}
+
+	r.record(e, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, r: r, fd: e.File}, nil
+}
+
+func (r *Recorder) Create(filename string) (billy.File, error) {
+	f, err := r.underlying.Create(filename)
+	return r.open(Event{Op: OpCreate, Path: filename}, f, err)
+}
+
+func (r *Recorder) Open(filename string) (billy.File, error) {
+	f, err := r.underlying.Open(filename)
+	return r.open(Event{Op: OpOpen, Path: filename}, f, err)
+}
+
+func (r *Recorder) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := r.underlying.OpenFile(filename, flag, perm)
+	return r.open(Event{Op: OpOpenFile, Path: filename, Flag: flag, Perm: perm}, f, err)
+}
+
+func (r *Recorder) Stat(filename string) (os.FileInfo, error) {
+	fi, err := r.underlying.Stat(filename)
+	r.record(Event{Op: OpStat, Path: filename}, err)
+	return fi, err
+}
+
+func (r *Recorder) Rename(from, to string) error {
+	err := r.underlying.Rename(from, to)
+	r.record(Event{Op: OpRename, Path: from, To: to}, err)
+	return err
+}
+
+func (r *Recorder) Remove(filename string) error {
+	err := r.underlying.Remove(filename)
+	r.record(Event{Op: OpRemove, Path: filename}, err)
+	return err
+}
+
+func (r *Recorder) Join(elem ...string) string {
+	return r.underlying.Join(elem...)
+}
+
+func (r *Recorder) TempFile(dir, prefix string) (billy.File, error) {
+	f, err := r.underlying.TempFile(dir, prefix)
+	return r.open(Event{Op: OpTempFile, Path: dir, To: prefix}, f, err)
+}
+
+func (r *Recorder) ReadDir(path string) ([]os.FileInfo, error) {
+	infos, err := r.underlying.ReadDir(path)
+	r.record(Event{Op: OpReadDir, Path: path}, err)
+	return infos, err
+}
+
+func (r *Recorder) MkdirAll(filename string, perm os.FileMode) error {
+	err := r.underlying.MkdirAll(filename, perm)
+	r.record(Event{Op: OpMkdirAll, Path: filename, Perm: perm}, err)
+	return err
+}
+
+func (r *Recorder) Lstat(filename string) (os.FileInfo, error) {
+	fi, err := r.underlying.Lstat(filename)
+	r.record(Event{Op: OpLstat, Path: filename}, err)
+	return fi, err
+}
+
+func (r *Recorder) Symlink(target, link string) error {
+	err := r.underlying.Symlink(target, link)
+	r.record(Event{Op: OpSymlink, Path: link, To: target}, err)
+	return err
+}
+
+func (r *Recorder) Readlink(link string) (string, error) {
+	target, err := r.underlying.Readlink(link)
+	r.record(Event{Op: OpReadlink, Path: link}, err)
+	return target, err
+}
+
+// Chroot returns a chrooted view of r; the operations are recorded with the
+// paths as seen from the root of r.
+func (r *Recorder) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(r, path), nil
+}
+
+func (r *Recorder) Root() string {
+	return r.underlying.Root()
+}
+
+// Capabilities implements the Capable interface. Only the operations of
+// billy.Filesystem are recorded, so the optional ones aren't advertised.
+func (r *Recorder) Capabilities() billy.Capability {
+	return billy.Capabilities(r.underlying) &^ billy.InterfaceCapabilities
+}
+
+type file struct {
+	billy.File
+	r  *Recorder
+	fd int
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.r.record(Event{Op: OpRead, File: f.fd, Size: int64(len(p)), N: n}, ignoreEOF(err))
+	return n, err
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.r.record(Event{Op: OpReadAt, File: f.fd, Size: int64(len(p)), Offset: off, N: n}, ignoreEOF(err))
+	return n, err
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+
+	e := Event{Op: OpWrite, File: f.fd, Size: int64(len(p)), N: n}
+	if !f.r.opts.Anonymize {
+		e.Data = append([]byte(nil), p...)
+	}
+
+	f.r.record(e, err)
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.File.Seek(offset, whence)
+	f.r.record(Event{Op: OpSeek, File: f.fd, Offset: offset, Whence: whence}, err)
+	return pos, err
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.r.record(Event{Op: OpClose, File: f.fd}, err)
+	return err
+}
+
+func (f *file) Lock() error {
+	err := f.File.Lock()
+	f.r.record(Event{Op: OpLock, File: f.fd}, err)
+	return err
+}
+
+func (f *file) Unlock() error {
+	err := f.File.Unlock()
+	f.r.record(Event{Op: OpUnlock, File: f.fd}, err)
+	return err
+}
+
+func (f *file) Truncate(size int64) error {
+	err := f.File.Truncate(size)
+	f.r.record(Event{Op: OpTruncate, File: f.fd, Size: size}, err)
+	return err
+}
+
+// ignoreEOF doesn't record io.EOF, as reaching the end of a file is reflected
+// by the number of bytes read.
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+
+	return err
+}
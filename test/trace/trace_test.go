@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestConformance(t *testing.T) {
+	test.Run(t, func() billy.Filesystem {
+		return New(memfs.New(), io.Discard, Options{})
+	})
+}
+
+func workload(t *testing.T, fs billy.Filesystem) {
+	t.Helper()
+
+	if err := util.WriteFile(fs, "dir/foo", []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Open("missing"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	tmp, err := fs.TempFile("dir", "tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp.Write([]byte("temporary"))
+	tmp.Close()
+	if err := fs.Rename(tmp.Name(), "dir/bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := util.ReadFile(fs, "dir/bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("dir/foo"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	workload(t, New(memfs.New(), &buf, Options{}))
+
+	if !strings.Contains(buf.String(), `"op":"rename"`) {
+		t.Errorf("unexpected trace:\n%s", buf.String())
+	}
+
+	fs := memfs.New()
+	if err := Replay(fs, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := util.ReadFile(fs, "dir/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "temporary" {
+		t.Errorf("unexpected content %q", content)
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	var buf bytes.Buffer
+	workload(t, New(memfs.New(), &buf, Options{}))
+
+	fs := memfs.New()
+	util.WriteFile(fs, "missing", nil, 0644)
+
+	err := Replay(fs, &buf)
+	var derr *DivergenceError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a divergence, got %v", err)
+	}
+	if derr.Event.Op != OpOpen || derr.Event.Path != "missing" {
+		t.Errorf("unexpected diverging event %+v", derr.Event)
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	var buf bytes.Buffer
+	workload(t, New(memfs.New(), &buf, Options{Anonymize: true}))
+
+	if strings.Contains(buf.String(), "c2VjcmV0") {
+		t.Errorf("trace leaks written data:\n%s", buf.String())
+	}
+
+	fs := memfs.New()
+	if err := Replay(fs, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := util.ReadFile(fs, "dir/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != len("temporary") {
+		t.Errorf("unexpected content %q", content)
+	}
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// WriteFileAtomic writes data to the file named by filename, replacing it
+// atomically: readers see either the previous content or data, never a
+// partially written file. The data is written to a temporary file in the
+// same directory, created with permissions perm, which is synced and then
+// renamed over filename; the directory is synced last, so the new content
+// survives a crash once WriteFileAtomic returns. The temporary file is
+// removed on error.
+//
+// The durability guarantees depend on fs implementing billy.Syncer for its
+// files and billy.DirSyncer; without them WriteFileAtomic is only atomic.
+func WriteFileAtomic(fs billy.Filesystem, filename string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(filename)
+	f, err := createTemp(fs, dir, "."+filepath.Base(filename)+".tmp-", perm)
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			_ = fs.Remove(tmp)
+		}
+	}()
+
+	n, err := f.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err == nil {
+		err = SyncFile(f)
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = fs.Rename(tmp, filename); err != nil {
+		return err
+	}
+
+	return SyncDir(fs, dir)
+}
+
+// createTemp is like TempFile, but creates the file with permissions perm.
+func createTemp(fs billy.Basic, dir, prefix string, perm os.FileMode) (f billy.File, err error) {
+	nconflict := 0
+	for i := 0; i < 10000; i++ {
+		name := fs.Join(dir, prefix+nextSuffix())
+		f, err = fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			if nconflict++; nconflict > 10 {
+				reseed()
+			}
+			continue
+		}
+		break
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package util_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/test/faultfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	fs := osfs.New(t.TempDir())
+
+	if err := util.WriteFileAtomic(fs, "dir/foo", []byte("foo"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fs, "dir/foo", "foo")
+
+	fi, err := fs.Stat("dir/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("unexpected mode %s", fi.Mode())
+	}
+
+	if err := util.WriteFileAtomic(fs, "dir/foo", []byte("bar"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	assertContent(t, fs, "dir/foo", "bar")
+
+	infos, err := fs.ReadDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Errorf("expected the temporary file to be gone, got %d entries", len(infos))
+	}
+}
+
+func TestWriteFileAtomicError(t *testing.T) {
+	errBoom := errors.New("boom")
+	fs := faultfs.New(memfs.New())
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+
+	for _, op := range []faultfs.Op{faultfs.Write, faultfs.Close, faultfs.Rename} {
+		fs.Reset()
+		fs.Inject(faultfs.Rule{Op: op, Err: errBoom})
+
+		err := util.WriteFileAtomic(fs, "foo", []byte("bar"), 0644)
+		if !errors.Is(err, errBoom) {
+			t.Errorf("%s: expected the injected error, got %v", op, err)
+		}
+
+		fs.Reset()
+		assertContent(t, fs, "foo", "foo")
+
+		infos, err := fs.ReadDir("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 1 {
+			t.Errorf("%s: expected the temporary file to be removed, got %d entries", op, len(infos))
+		}
+	}
+}
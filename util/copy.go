@@ -0,0 +1,51 @@
+package util
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+type fileCopier interface {
+	CopyFile(src, dst string) error
+}
+
+// CopyFile copies the regular file srcPath of src to dstPath of dst, creating
+// it with the permission bits of the source or truncating it. When both paths
+// lead to the same filesystem able to copy files itself, such as osfs, the
+// copy is left to it, so that the data can be cloned or copied by the kernel
+// without going through the process. Otherwise the content is copied with
+// io.Copy.
+func CopyFile(dst billy.Basic, dstPath string, src billy.Basic, srcPath string) error {
+	fi, err := src.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return &os.PathError{Op: "copy", Path: srcPath, Err: billy.ErrNotSupported}
+	}
+
+	// Stat checks that dstPath doesn't escape dst before it is unwrapped.
+	if _, err := dst.Stat(dstPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	ud, pd := nativePath(dst, dstPath)
+	us, ps := nativePath(src, srcPath)
+	if c, ok := ud.(fileCopier); ok && ud == us {
+		return c.CopyFile(ps, pd)
+	}
+
+	return copyFile(dst, dstPath, src, srcPath, fi.Mode().Perm(), nil, "")
+}
+
+// nativePath returns the innermost filesystem of fs, and the path leading to
+// path there.
+func nativePath(fs billy.Basic, path string) (billy.Basic, string) {
+	for {
+		if _, ok := fs.(underlying); !ok {
+			return fs, path
+		}
+		fs, path = getUnderlyingAndPath(fs, path)
+	}
+}
@@ -0,0 +1,67 @@
+package util_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func testCopyFile(t *testing.T, dst, src billy.Filesystem) {
+	if err := util.WriteFile(src, "foo", []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(dst, "dir/bar", []byte("previous content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dir/bar", "new"} {
+		if err := util.CopyFile(dst, name, src, "foo"); err != nil {
+			t.Fatal(err)
+		}
+		assertContent(t, dst, name, "foo")
+	}
+
+	fi, err := dst.Stat("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %s", fi.Mode())
+	}
+
+	if err := src.MkdirAll("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.CopyFile(dst, "copy", src, "dir"); err == nil {
+		t.Error("expected an error copying a directory")
+	}
+	if err := util.CopyFile(dst, "copy", src, "missing"); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	testCopyFile(t, memfs.New(), memfs.New())
+}
+
+func TestCopyFileOS(t *testing.T) {
+	testCopyFile(t, osfs.New(t.TempDir()), osfs.New(t.TempDir()))
+}
+
+func TestCopyFileOutside(t *testing.T) {
+	dir := t.TempDir()
+	src := osfs.New(dir)
+	if err := util.WriteFile(src, "foo", []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := osfs.New(dir + "/sub")
+	if err := util.CopyFile(dst, "../escaped", src, "foo"); err != billy.ErrCrossedBoundary {
+		t.Errorf("expected %v, got %v", billy.ErrCrossedBoundary, err)
+	}
+}
@@ -0,0 +1,195 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/fs"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// DuplicateOptions configures FindDuplicatesWithOptions.
+type DuplicateOptions struct {
+	// MinSize is the size below which the files are ignored, in bytes.
+	// The empty files are always ignored.
+	MinSize int64
+	// Concurrency is the number of directories read, and of files hashed,
+	// at the same time, see WalkParallel. runtime.NumCPU is used if it is
+	// zero or negative.
+	Concurrency int
+}
+
+// Duplicates is a set of regular files with the same content.
+type Duplicates struct {
+	// Size is the size of each file, in bytes.
+	Size int64
+	// Digest is the SHA-256 of the content of the files.
+	Digest []byte
+	// Paths are the paths of the files, sorted.
+	Paths []string
+}
+
+// Wasted returns the space taken by the copies beyond the first one.
+func (d Duplicates) Wasted() int64 {
+	return d.Size * int64(len(d.Paths)-1)
+}
+
+// FindDuplicates returns the sets of regular files of the tree at root with
+// the same content, see FindDuplicatesWithOptions.
+func FindDuplicates(fs billy.Filesystem, root string) ([]Duplicates, error) {
+	return FindDuplicatesWithOptions(fs, root, DuplicateOptions{})
+}
+
+// FindDuplicatesWithOptions returns the sets of regular files of the tree at
+// root with the same content, the ones wasting the most space first. The
+// tree is walked with WalkParallel, the files being grouped by size, and
+// then only the files sharing their size are hashed. The symbolic links
+// aren't followed, and the hard links of a file are reported as duplicates.
+//
+// The first error met, reading the tree or a file, stops the search.
+func FindDuplicatesWithOptions(fsys billy.Filesystem, root string, opts DuplicateOptions) ([]Duplicates, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	minSize := opts.MinSize
+	if minSize < 1 {
+		minSize = 1
+	}
+
+	var m sync.Mutex
+	bySize := make(map[int64][]string)
+	err := WalkParallel(fsys, root, concurrency, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() < minSize {
+			return nil
+		}
+
+		m.Lock()
+		bySize[info.Size()] = append(bySize[info.Size()], path)
+		m.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []dupCandidate
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, path := range paths {
+			candidates = append(candidates, dupCandidate{path: path, size: size})
+		}
+	}
+
+	if err := hashCandidates(fsys, candidates, concurrency); err != nil {
+		return nil, err
+	}
+
+	return groupDuplicates(candidates), nil
+}
+
+// dupCandidate is a file sharing its size with others.
+type dupCandidate struct {
+	path   string
+	size   int64
+	digest []byte
+}
+
+// hashCandidates computes the digests of the candidates, hashing up to
+// concurrency files at the same time.
+func hashCandidates(fs billy.Filesystem, candidates []dupCandidate, concurrency int) error {
+	jobs := make(chan *dupCandidate)
+	errs := make(chan error, concurrency)
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				digest, err := hashFile(fs, c.path, sha256.New(), nil, "")
+				if err != nil {
+					errs <- err
+					return
+				}
+				c.digest = digest
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	var err error
+feed:
+	for i := range candidates {
+		select {
+		case jobs <- &candidates[i]:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(jobs)
+	<-done
+
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+
+	return err
+}
+
+// groupDuplicates groups the candidates by size and digest, keeping the
+// groups of several files.
+func groupDuplicates(candidates []dupCandidate) []Duplicates {
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.size != b.size {
+			return a.size > b.size
+		}
+		if c := bytes.Compare(a.digest, b.digest); c != 0 {
+			return c < 0
+		}
+		return a.path < b.path
+	})
+
+	var result []Duplicates
+	for i := 0; i < len(candidates); {
+		j := i + 1
+		for j < len(candidates) && candidates[j].size == candidates[i].size &&
+			bytes.Equal(candidates[j].digest, candidates[i].digest) {
+			j++
+		}
+
+		if j-i > 1 {
+			d := Duplicates{Size: candidates[i].size, Digest: candidates[i].digest}
+			for _, c := range candidates[i:j] {
+				d.Paths = append(d.Paths, c.path)
+			}
+			result = append(result, d)
+		}
+		i = j
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Wasted() > result[j].Wasted()
+	})
+
+	return result
+}
@@ -0,0 +1,59 @@
+package util_test
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	fs := memfs.New()
+	for name, content := range map[string]string{
+		"a/big":      "0123456789",
+		"b/big":      "0123456789",
+		"c/d/big":    "0123456789",
+		"a/same":     "abcdefghij",
+		"a/small":    "xy",
+		"b/small":    "xy",
+		"b/unique":   "zz",
+		"a/empty":    "",
+		"b/empty":    "",
+		"lone/file1": "1",
+	} {
+		if err := util.WriteFile(fs, name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Symlink("big", "a/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	dups, err := util.FindDuplicates(fs, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := sha256.Sum256([]byte("0123456789"))
+	small := sha256.Sum256([]byte("xy"))
+	want := []util.Duplicates{
+		{Size: 10, Digest: big[:], Paths: []string{"/a/big", "/b/big", "/c/d/big"}},
+		{Size: 2, Digest: small[:], Paths: []string{"/a/small", "/b/small"}},
+	}
+	if !reflect.DeepEqual(dups, want) {
+		t.Errorf("FindDuplicates = %+v, want %+v", dups, want)
+	}
+	if dups[0].Wasted() != 20 {
+		t.Errorf("Wasted = %d", dups[0].Wasted())
+	}
+
+	dups, err = util.FindDuplicatesWithOptions(fs, "/", util.DuplicateOptions{MinSize: 5, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 1 || dups[0].Size != 10 {
+		t.Errorf("FindDuplicatesWithOptions = %+v", dups)
+	}
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	// The algorithms of the digests, which are only available once linked.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"hash"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/opencontainers/go-digest"
+)
+
+// DigestError is returned when the content of a file doesn't match the
+// digest expected, such as by the Close of the files created by
+// CreateWithDigest.
+type DigestError struct {
+	// Path is the name of the file, as given to CreateWithDigest or Open.
+	Path string
+	// Expected is the digest given to CreateWithDigest, and Actual the one
+	// of the content written.
+	Expected, Actual digest.Digest
+}
+
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("%s: digest mismatch: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// CreateWithDigest creates or truncates the named file, as Create does, for
+// its content to be streamed to it and checked against expected, such as
+// the blob of an OCI artifact being pulled.
+//
+// The content must be written sequentially: the Seek and Truncate of the
+// file returned fail with billy.ErrNotSupported. Its Close fails with a
+// *DigestError if the content written doesn't match expected, in which case
+// the file is removed, as it is if the Close of the underlying file fails. The file isn't verified nor removed if Close is never called.
+func CreateWithDigest(fs billy.Basic, name string, expected digest.Digest) (billy.File, error) {
+	if err := expected.Validate(); err != nil {
+		return nil, &os.PathError{Op: "create", Path: name, Err: err}
+	}
+
+	f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	return &digestFile{
+		File:     f,
+		fs:       fs,
+		name:     name,
+		expected: expected,
+		hash:     expected.Algorithm().Hash(),
+	}, nil
+}
+
+// digestFile is the billy.File returned by CreateWithDigest.
+type digestFile struct {
+	billy.File
+	fs       billy.Basic
+	name     string
+	expected digest.Digest
+	hash     hash.Hash
+
+	closed bool
+	err    error
+}
+
+func (f *digestFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.hash.Write(p[:n])
+	return n, err
+}
+
+func (f *digestFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, f.notSupported("seek")
+}
+
+func (f *digestFile) Truncate(size int64) error {
+	return f.notSupported("truncate")
+}
+
+func (f *digestFile) notSupported(op string) error {
+	return &os.PathError{Op: op, Path: f.name, Err: billy.ErrNotSupported}
+}
+
+// Close closes the file and verifies its content, removing it on failure.
+// Calling Close more than once returns the result of the first call.
+func (f *digestFile) Close() error {
+	if f.closed {
+		return f.err
+	}
+	f.closed = true
+
+	f.err = f.File.Close()
+	if f.err == nil {
+		actual := digest.NewDigest(f.expected.Algorithm(), f.hash)
+		if actual != f.expected {
+			f.err = &DigestError{Path: f.name, Expected: f.expected, Actual: actual}
+		}
+	}
+
+	if f.err != nil {
+		_ = f.fs.Remove(f.name)
+	}
+
+	return f.err
+}
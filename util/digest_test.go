@@ -0,0 +1,102 @@
+package util_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestCreateWithDigest(t *testing.T) {
+	fs := memfs.New()
+	content := []byte("hello world")
+
+	for _, expected := range []digest.Digest{
+		digest.FromBytes(content),
+		digest.SHA512.FromBytes(content),
+	} {
+		f, err := util.CreateWithDigest(fs, "blob", expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(content[:5]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(content[5:]); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("%s: %v", expected.Algorithm(), err)
+		}
+
+		got, err := util.ReadFile(fs, "blob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(content) {
+			t.Errorf("got %q, want %q", got, content)
+		}
+	}
+}
+
+func TestCreateWithDigest_Mismatch(t *testing.T) {
+	fs := memfs.New()
+	expected := digest.FromString("expected")
+
+	f, err := util.CreateWithDigest(fs, "blob", expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(f, "tampered"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.Close()
+	var derr *util.DigestError
+	if !errors.As(err, &derr) {
+		t.Fatalf("got %v, want a DigestError", err)
+	}
+	if derr.Path != "blob" || derr.Expected != expected || derr.Actual != digest.FromString("tampered") {
+		t.Errorf("unexpected %#v", derr)
+	}
+	if err2 := f.Close(); err2 != err {
+		t.Errorf("second Close: got %v, want %v", err2, err)
+	}
+
+	if _, err := fs.Stat("blob"); !os.IsNotExist(err) {
+		t.Errorf("partial file not removed: %v", err)
+	}
+}
+
+func TestCreateWithDigest_Invalid(t *testing.T) {
+	fs := memfs.New()
+	for _, d := range []digest.Digest{"", "sha256:foo", "md5:d41d8cd98f00b204e9800998ecf8427e"} {
+		if _, err := util.CreateWithDigest(fs, "blob", d); err == nil {
+			t.Errorf("%q: expected an error", d)
+		}
+	}
+	if _, err := fs.Stat("blob"); !os.IsNotExist(err) {
+		t.Errorf("file created for an invalid digest: %v", err)
+	}
+}
+
+func TestCreateWithDigest_Sequential(t *testing.T) {
+	fs := memfs.New()
+	f, err := util.CreateWithDigest(fs, "blob", digest.FromString(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(0, io.SeekStart); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("Seek: got %v, want not supported", err)
+	}
+	if err := f.Truncate(0); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("Truncate: got %v, want not supported", err)
+	}
+}
@@ -0,0 +1,238 @@
+package util
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// DiskUsageOptions configures DiskUsage.
+type DiskUsageOptions struct {
+	// FollowSymlinks makes the symbolic links count as their destination:
+	// the size of the files they point to is added, and the directories they
+	// point to are walked. Each directory is walked once, the ones inside
+	// the walked tree being only counted there. Otherwise, symbolic links
+	// are skipped.
+	FollowSymlinks bool
+	// Concurrency is the number of directories read at the same time, see
+	// WalkParallel. runtime.NumCPU is used if it is zero or negative.
+	Concurrency int
+}
+
+// Usage is the space used by a set of files.
+type Usage struct {
+	// Size is the total size of the regular files, in bytes.
+	Size int64
+	// Files is the number of regular files.
+	Files int
+}
+
+// DiskUsageResult is the usage of a tree, as returned by DiskUsage.
+type DiskUsageResult struct {
+	Usage
+	// Dirs holds the usage of each directory at the top of the tree, keyed
+	// by name. The files at the top only count in the total.
+	Dirs map[string]Usage
+}
+
+// DiskUsage returns the size and number of the regular files of the tree at
+// path, as du(1) does, with the usage of each of its top level directories.
+// The size of the directories themselves is not counted. The tree is walked
+// with WalkParallel, the first error met stopping the walk.
+func DiskUsage(fsys billy.Filesystem, path string, opts *DiskUsageOptions) (DiskUsageResult, error) {
+	if opts == nil {
+		opts = &DiskUsageOptions{}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	du := &diskUsage{
+		fs:      fsys,
+		root:    filepath.Clean(path),
+		follow:  opts.FollowSymlinks,
+		result:  DiskUsageResult{Dirs: make(map[string]Usage)},
+		visited: map[string]bool{absPath(path): true},
+		queue:   []duTask{{path: filepath.Clean(path)}},
+	}
+
+	for {
+		du.m.Lock()
+		if len(du.queue) == 0 {
+			du.m.Unlock()
+			return du.result, nil
+		}
+		t := du.queue[0]
+		du.queue = du.queue[1:]
+		du.m.Unlock()
+
+		err := WalkParallel(fsys, t.path, concurrency, func(p string, d fs.DirEntry, err error) error {
+			return du.visit(t, p, d, err)
+		})
+		if err != nil {
+			return du.result, err
+		}
+	}
+}
+
+// duTask is a tree walked by DiskUsage: the one at its root, and then the
+// ones symbolic links lead to, counted in the top level directory holding
+// the link.
+type duTask struct {
+	path string
+	top  string
+}
+
+type diskUsage struct {
+	fs     billy.Filesystem
+	root   string
+	follow bool
+
+	m       sync.Mutex
+	result  DiskUsageResult
+	visited map[string]bool
+	queue   []duTask
+}
+
+func (du *diskUsage) visit(t duTask, path string, d fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+
+	top := t.top
+	atTop := false
+	if t.path == du.root {
+		top = du.topOf(path)
+		atTop = top != "" && filepath.Dir(path) == du.root
+	}
+
+	switch {
+	case d.IsDir():
+		// A tree a link led to may hold one walked already.
+		if path != t.path && du.isVisited(path) {
+			return filepath.SkipDir
+		}
+		if atTop {
+			du.add(top, 0, 0)
+		}
+		return nil
+	case d.Type()&os.ModeSymlink != 0:
+		if !du.follow {
+			return nil
+		}
+		return du.followLink(path, top, atTop)
+	case d.Type().IsRegular():
+		if atTop {
+			top = ""
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		du.add(top, info.Size(), 1)
+	}
+
+	return nil
+}
+
+// followLink counts the destination of the symbolic link at path, atTop
+// telling if it is a top level entry. The broken links are ignored.
+func (du *diskUsage) followLink(path, top string, atTop bool) error {
+	info, err := du.fs.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().IsRegular() {
+		if atTop {
+			top = ""
+		}
+		du.add(top, info.Size(), 1)
+		return nil
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	target, err := du.fs.Readlink(path)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+
+	if atTop {
+		du.add(top, 0, 0)
+	}
+
+	du.m.Lock()
+	defer du.m.Unlock()
+
+	abs := absPath(target)
+	for dir := range du.visited {
+		if abs == dir || strings.HasPrefix(abs, dir+string(filepath.Separator)) ||
+			dir == string(filepath.Separator) {
+			return nil
+		}
+	}
+
+	du.visited[abs] = true
+	du.queue = append(du.queue, duTask{path: filepath.Clean(target), top: top})
+	return nil
+}
+
+func (du *diskUsage) isVisited(path string) bool {
+	du.m.Lock()
+	defer du.m.Unlock()
+
+	return du.visited[absPath(path)]
+}
+
+// topOf returns the name of the top level entry of the tree holding path, or
+// an empty string for the root itself.
+func (du *diskUsage) topOf(path string) string {
+	rel, err := filepath.Rel(du.root, path)
+	if err != nil || rel == "." {
+		return ""
+	}
+
+	if i := strings.IndexByte(rel, filepath.Separator); i >= 0 {
+		return rel[:i]
+	}
+
+	return rel
+}
+
+func (du *diskUsage) add(top string, size int64, files int) {
+	du.m.Lock()
+	defer du.m.Unlock()
+
+	du.result.Size += size
+	du.result.Files += files
+
+	if top == "" {
+		return
+	}
+
+	u := du.result.Dirs[top]
+	u.Size += size
+	u.Files += files
+	du.result.Dirs[top] = u
+}
+
+// absPath returns path as an absolute, clean, path, for comparisons.
+func absPath(path string) string {
+	return filepath.Join(string(filepath.Separator), path)
+}
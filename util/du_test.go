@@ -0,0 +1,86 @@
+package util_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func newDiskUsageTree(t *testing.T) billy.Filesystem {
+	fs := memfs.New()
+
+	files := map[string]int{
+		"root/top.txt": 3,
+		"root/a/x":     5,
+		"root/a/b/y":   7,
+		"root/c/z":     11,
+		"ext/big":      100,
+	}
+	for name, size := range files {
+		if err := util.WriteFile(fs, name, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"root/lfile":  "a/x",
+		"root/c/ldir": "../../ext",
+		"root/c/lin":  "../a",
+		"root/loop":   ".",
+		"ext/back":    "../root",
+		"root/broken": "missing",
+	}
+	for link, target := range links {
+		if err := fs.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fs
+}
+
+func TestDiskUsage(t *testing.T) {
+	fs := newDiskUsageTree(t)
+
+	du, err := util.DiskUsage(fs, "root", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := util.DiskUsageResult{
+		Usage: util.Usage{Size: 26, Files: 4},
+		Dirs: map[string]util.Usage{
+			"a": {Size: 12, Files: 2},
+			"c": {Size: 11, Files: 1},
+		},
+	}
+	if !reflect.DeepEqual(du, expected) {
+		t.Errorf("DiskUsage(fs, `root`, nil) = %+v, want %+v", du, expected)
+	}
+}
+
+func TestDiskUsage_FollowSymlinks(t *testing.T) {
+	fs := newDiskUsageTree(t)
+
+	du, err := util.DiskUsage(fs, "root", &util.DiskUsageOptions{FollowSymlinks: true, Concurrency: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lfile counts at the top, ext is walked once, from c, and the links
+	// back into root aren't walked again.
+	expected := util.DiskUsageResult{
+		Usage: util.Usage{Size: 131, Files: 6},
+		Dirs: map[string]util.Usage{
+			"a":    {Size: 12, Files: 2},
+			"c":    {Size: 111, Files: 2},
+			"loop": {},
+		},
+	}
+	if !reflect.DeepEqual(du, expected) {
+		t.Errorf("DiskUsage(fs, `root`, follow) = %+v, want %+v", du, expected)
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"errors"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// SyncFile commits the content of f to stable storage, if f implements
+// billy.Syncer. Files unable to sync, such as the ones of in-memory
+// filesystems, are ignored.
+func SyncFile(f billy.File) error {
+	s, ok := f.(billy.Syncer)
+	if !ok {
+		return nil
+	}
+
+	return ignoreNotSupported(s.Sync())
+}
+
+// SyncDir commits the entries of the directory at path to stable storage, if
+// fs, or any filesystem it wraps, implements billy.DirSyncer. It is a no-op
+// otherwise. Syncing the parent directory is required for a newly created or
+// renamed file to survive a crash.
+func SyncDir(fs billy.Basic, path string) error {
+	for {
+		if s, ok := fs.(billy.DirSyncer); ok {
+			return ignoreNotSupported(s.SyncDir(path))
+		}
+
+		next, npath := getUnderlyingAndPath(fs, path)
+		if next == fs {
+			return nil
+		}
+		fs, path = next, npath
+	}
+}
+
+func ignoreNotSupported(err error) error {
+	if errors.Is(err, billy.ErrNotSupported) {
+		return nil
+	}
+
+	return err
+}
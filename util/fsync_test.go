@@ -0,0 +1,53 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestSyncFileAndDir(t *testing.T) {
+	dir := t.TempDir()
+	fs := osfs.New(dir)
+
+	f, err := fs.Create("dir/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.SyncFile(f); err != nil {
+		t.Errorf("unexpected SyncFile error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.SyncDir(fs, "dir"); err != nil {
+		t.Errorf("unexpected SyncDir error: %v", err)
+	}
+	if err := util.SyncDir(fs, "missing"); err == nil {
+		t.Errorf("expected an error syncing a missing directory")
+	}
+}
+
+func TestSyncFileAndDirMemory(t *testing.T) {
+	fs := chroot.New(memfs.New(), "base")
+
+	f, err := fs.Create("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := util.SyncFile(f); err != nil {
+		t.Errorf("unexpected SyncFile error: %v", err)
+	}
+	if err := util.SyncDir(fs, "/"); err != nil {
+		t.Errorf("unexpected SyncDir error: %v", err)
+	}
+}
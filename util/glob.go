@@ -1,6 +1,7 @@
 package util
 
 import (
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,12 +14,118 @@ import (
 // as in Match. The pattern may describe hierarchical names such as
 // /usr/*/bin/ed (assuming the Separator is '/').
 //
+// Two extensions to the syntax of Match are supported:
+//
+//   - a path element "**" matches zero or more directories, so that
+//     clusters/**/kustomization.yaml matches clusters/kustomization.yaml
+//     as well as clusters/a/b/kustomization.yaml. A trailing "**" matches
+//     every file and directory below the preceding ones. Symbolic links
+//     to directories are not followed by "**";
+//   - braces are expanded, {a,b} matching either a or b. They can be
+//     nested and combined with the other wildcards, as in *.{yaml,yml}.
+//
+// The matches of patterns using these extensions are sorted.
+//
 // Glob ignores file system errors such as I/O errors reading directories.
 // The only possible returned error is ErrBadPattern, when pattern
 // is malformed.
 //
 // Function originally from https://golang.org/src/path/filepath/match_test.go
 func Glob(fs billy.Filesystem, pattern string) (matches []string, err error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 1 && !hasDoublestar(pattern) {
+		return globPattern(fs, pattern)
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		var m []string
+		if hasDoublestar(p) {
+			m, err = globDoublestar(fs, p)
+		} else {
+			m, err = globPattern(fs, p)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range m {
+			if !seen[name] {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// MatchPath reports whether name matches pattern, with the syntax of Glob,
+// "**" and braces included, without accessing any filesystem. Both are split
+// in path elements at either separator, and the "." elements of name are
+// ignored. The only possible returned error is ErrBadPattern.
+func MatchPath(pattern, name string) (bool, error) {
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	var elems []string
+	for _, elem := range splitPattern(name) {
+		if elem != "." {
+			elems = append(elems, elem)
+		}
+	}
+
+	matched := false
+	for _, p := range patterns {
+		pelems := splitPattern(p)
+		for _, elem := range pelems {
+			if _, err := filepath.Match(elem, ""); err != nil {
+				return false, err
+			}
+		}
+
+		if !matched {
+			matched = matchElems(pelems, elems)
+		}
+	}
+
+	return matched, nil
+}
+
+// matchElems reports whether the path elements of name match the ones of
+// pattern, "**" matching any number of them.
+func matchElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// globPattern implements Glob for patterns without extensions.
+func globPattern(fs billy.Filesystem, pattern string) (matches []string, err error) {
 	if !hasMeta(pattern) {
 		if _, err = fs.Lstat(pattern); err != nil {
 			return nil, nil
@@ -33,7 +140,7 @@ func Glob(fs billy.Filesystem, pattern string) (matches []string, err error) {
 	}
 
 	var m []string
-	m, err = Glob(fs, cleanGlobPath(dir))
+	m, err = globPattern(fs, cleanGlobPath(dir))
 	if err != nil {
 		return
 	}
@@ -89,6 +196,173 @@ func glob(fs billy.Filesystem, dir, pattern string, matches []string) (m []strin
 	return
 }
 
+// hasDoublestar reports whether pattern has a "**" path element.
+func hasDoublestar(pattern string) bool {
+	for _, elem := range splitPattern(pattern) {
+		if elem == "**" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitPattern splits pattern in path elements. The separators are
+// always accepted, even on Windows, where / is not used to escape.
+func splitPattern(pattern string) []string {
+	return strings.FieldsFunc(pattern, func(r rune) bool {
+		return r == '/' || r == filepath.Separator
+	})
+}
+
+// globDoublestar implements Glob for patterns with a "**" element,
+// matching the path elements one by one from the root of the pattern.
+func globDoublestar(fs billy.Filesystem, pattern string) ([]string, error) {
+	elems := splitPattern(pattern)
+	for _, elem := range elems {
+		if elem == "**" || !hasMeta(elem) {
+			continue
+		}
+		if _, err := filepath.Match(elem, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	root := "."
+	if strings.HasPrefix(pattern, "/") || strings.HasPrefix(pattern, string(filepath.Separator)) {
+		root = string(filepath.Separator)
+	}
+
+	var matches []string
+	globElems(fs, root, elems, &matches)
+	return matches, nil
+}
+
+// globElems appends to matches the paths below dir matching elems.
+func globElems(fs billy.Filesystem, dir string, elems []string, matches *[]string) {
+	if len(elems) == 0 {
+		*matches = append(*matches, dir)
+		return
+	}
+
+	elem, rest := elems[0], elems[1:]
+	if elem == "**" {
+		globDoublestarElem(fs, dir, rest, matches)
+		return
+	}
+
+	if !hasMeta(elem) {
+		name := filepath.Join(dir, elem)
+		if _, err := fs.Lstat(name); err == nil {
+			globNext(fs, name, rest, matches)
+		}
+		return
+	}
+
+	names, err := readdirnames(fs, dir)
+	if err != nil {
+		return
+	}
+
+	for _, n := range names {
+		if matched, _ := filepath.Match(elem, n); matched {
+			globNext(fs, filepath.Join(dir, n), rest, matches)
+		}
+	}
+}
+
+// globNext continues matching rest below name, a match of the previous
+// element, which has to be a directory unless rest is empty.
+func globNext(fs billy.Filesystem, name string, rest []string, matches *[]string) {
+	if len(rest) == 0 {
+		*matches = append(*matches, name)
+		return
+	}
+
+	if fi, err := fs.Stat(name); err == nil && fi.IsDir() {
+		globElems(fs, name, rest, matches)
+	}
+}
+
+// globDoublestarElem matches rest against dir and every directory below it.
+// A trailing "**" matches every file and directory below dir.
+func globDoublestarElem(fs billy.Filesystem, dir string, rest []string, matches *[]string) {
+	_ = Walk(fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if len(rest) == 0 {
+			if path != "." {
+				*matches = append(*matches, path)
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			globElems(fs, path, rest, matches)
+		}
+		return nil
+	})
+}
+
+// expandBraces returns the patterns obtained by expanding the braces of
+// pattern, or the pattern itself if it has none.
+func expandBraces(pattern string) ([]string, error) {
+	open := -1
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && filepath.Separator != '\\' {
+			i++
+			continue
+		}
+		if pattern[i] == '{' {
+			open = i
+			break
+		}
+	}
+	if open < 0 {
+		return []string{pattern}, nil
+	}
+
+	var alternatives []string
+	depth, start := 0, open+1
+	for i := open + 1; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if filepath.Separator != '\\' {
+				i++
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+				continue
+			}
+
+			alternatives = append(alternatives, pattern[start:i])
+			prefix, suffix := pattern[:open], pattern[i+1:]
+
+			var patterns []string
+			for _, alt := range alternatives {
+				expanded, err := expandBraces(prefix + alt + suffix)
+				if err != nil {
+					return nil, err
+				}
+				patterns = append(patterns, expanded...)
+			}
+			return patterns, nil
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, pattern[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return nil, filepath.ErrBadPattern
+}
+
 // hasMeta reports whether path contains any of the magic characters
 // recognized by Match.
 func hasMeta(path string) bool {
@@ -106,6 +380,7 @@ func readdirnames(fs billy.Filesystem, dir string) ([]string, error) {
 	for _, file := range files {
 		names = append(names, file.Name())
 	}
+	sort.Strings(names)
 
 	return names, nil
 }
@@ -32,3 +32,113 @@ func (s *UtilSuite) TestCreate(c *C) {
 	})
 
 }
+
+func (s *UtilSuite) TestGlobDoublestar(c *C) {
+	fs := memfs.New()
+	util.WriteFile(fs, "clusters/kustomization.yaml", nil, 0644)
+	util.WriteFile(fs, "clusters/prod/kustomization.yaml", nil, 0644)
+	util.WriteFile(fs, "clusters/prod/eu/kustomization.yaml", nil, 0644)
+	util.WriteFile(fs, "clusters/prod/eu/values.yaml", nil, 0644)
+	util.WriteFile(fs, "apps/kustomization.yaml", nil, 0644)
+
+	names, err := util.Glob(fs, "clusters/**/kustomization.yaml")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.Join("clusters", "kustomization.yaml"),
+		filepath.Join("clusters", "prod", "eu", "kustomization.yaml"),
+		filepath.Join("clusters", "prod", "kustomization.yaml"),
+	})
+
+	names, err = util.Glob(fs, "**/eu/*.yaml")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.Join("clusters", "prod", "eu", "kustomization.yaml"),
+		filepath.Join("clusters", "prod", "eu", "values.yaml"),
+	})
+
+	names, err = util.Glob(fs, "clusters/prod/**")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.Join("clusters", "prod"),
+		filepath.Join("clusters", "prod", "eu"),
+		filepath.Join("clusters", "prod", "eu", "kustomization.yaml"),
+		filepath.Join("clusters", "prod", "eu", "values.yaml"),
+		filepath.Join("clusters", "prod", "kustomization.yaml"),
+	})
+
+	names, err = util.Glob(fs, "/**/**/values.yaml")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.FromSlash("/clusters/prod/eu/values.yaml"),
+	})
+}
+
+func (s *UtilSuite) TestGlobBraces(c *C) {
+	fs := memfs.New()
+	util.WriteFile(fs, "a/values.yaml", nil, 0644)
+	util.WriteFile(fs, "a/values.yml", nil, 0644)
+	util.WriteFile(fs, "a/values.json", nil, 0644)
+	util.WriteFile(fs, "b/values.yaml", nil, 0644)
+	util.WriteFile(fs, "c1/values.yaml", nil, 0644)
+
+	names, err := util.Glob(fs, "a/*.{yaml,yml}")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.Join("a", "values.yaml"),
+		filepath.Join("a", "values.yml"),
+	})
+
+	names, err = util.Glob(fs, "{a,c[0-9]}/values.{y{a,}ml,json}")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.Join("a", "values.json"),
+		filepath.Join("a", "values.yaml"),
+		filepath.Join("a", "values.yml"),
+		filepath.Join("c1", "values.yaml"),
+	})
+
+	names, err = util.Glob(fs, "{a,b,a}/**/values.yaml")
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{
+		filepath.Join("a", "values.yaml"),
+		filepath.Join("b", "values.yaml"),
+	})
+}
+
+func (s *UtilSuite) TestGlobBadPattern(c *C) {
+	fs := memfs.New()
+
+	_, err := util.Glob(fs, "{a,b")
+	c.Assert(err, Equals, filepath.ErrBadPattern)
+
+	_, err = util.Glob(fs, "**/[a")
+	c.Assert(err, Equals, filepath.ErrBadPattern)
+}
+
+func (s *UtilSuite) TestMatchPath(c *C) {
+	cases := []struct {
+		pattern, name string
+		matched       bool
+	}{
+		{"clusters/**", "clusters", true},
+		{"clusters/**", "clusters/a/b.yaml", true},
+		{"clusters/**", "apps/a", false},
+		{"**/*.yaml", "a.yaml", true},
+		{"**/*.yaml", "a/b/c.yaml", true},
+		{"**/*.yaml", "a/b/c.yml", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/*", "a/b/c", false},
+		{"*.{yaml,yml}", "./values.yml", true},
+		{"**", "", true},
+	}
+
+	for _, tc := range cases {
+		matched, err := util.MatchPath(tc.pattern, filepath.FromSlash(tc.name))
+		c.Assert(err, IsNil)
+		c.Assert(matched, Equals, tc.matched, Commentf("%s %s", tc.pattern, tc.name))
+	}
+
+	_, err := util.MatchPath("a/[b", "a/c")
+	c.Assert(err, Equals, filepath.ErrBadPattern)
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// HashTree returns a digest of the tree rooted at path, computed with the
+// hash returned by h. The digest covers the relative name, type and
+// permission bits of every entry, the target of every symlink and the
+// contents of every regular file. Entries are visited in lexical order and
+// names are always encoded with forward slashes, so the result is stable
+// across backends and platforms for equivalent trees.
+//
+// Modification times, ownership and the name of path itself are not part of
+// the digest. Symlinks are never followed.
+func HashTree(fs billy.Filesystem, path string, h func() hash.Hash) ([]byte, error) {
+	return HashTreeWithOptions(fs, path, h, HashOptions{})
+}
+
+// HashOptions configures HashTreeWithOptions.
+type HashOptions struct {
+	// Progress, if not nil, is called as the entries are hashed, the bytes
+	// counted being the ones of the regular files read.
+	Progress ProgressFunc
+}
+
+// HashTreeWithOptions is like HashTree, with the options given by opts.
+func HashTreeWithOptions(fs billy.Filesystem, path string, h func() hash.Hash, opts HashOptions) ([]byte, error) {
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &treeHasher{fs: fs, h: h, sum: h(), progress: newProgress(opts.Progress)}
+	if err := t.hashEntry(path, ".", fi); err != nil {
+		return nil, err
+	}
+
+	return t.sum.Sum(nil), nil
+}
+
+type treeHasher struct {
+	fs       billy.Filesystem
+	h        func() hash.Hash
+	sum      hash.Hash
+	progress *progress
+}
+
+func (t *treeHasher) hashEntry(path, rel string, fi os.FileInfo) error {
+	fs, sum := t.fs, t.sum
+
+	mode := fi.Mode()
+	switch {
+	case mode&os.ModeSymlink != 0:
+		target, err := fs.Readlink(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sum, "l %o %s\x00%s\n", mode.Perm(), rel, filepath.ToSlash(target))
+	case mode.IsDir():
+		fmt.Fprintf(sum, "d %o %s\n", mode.Perm(), rel)
+		if err := t.progress.done(rel); err != nil {
+			return err
+		}
+
+		names, err := readdirnames(fs, path)
+		if err != nil {
+			return err
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			child := fs.Join(path, name)
+			cfi, err := fs.Lstat(child)
+			if err != nil {
+				return err
+			}
+
+			crel := name
+			if rel != "." {
+				crel = rel + "/" + name
+			}
+
+			if err := t.hashEntry(child, crel, cfi); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case mode.IsRegular():
+		digest, err := hashFile(fs, path, t.h(), t.progress, rel)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sum, "f %o %s\x00%x\n", mode.Perm(), rel, digest)
+	default:
+		fmt.Fprintf(sum, "s %o %s\x00%s\n", mode.Perm(), rel, mode.Type())
+	}
+
+	return t.progress.done(rel)
+}
+
+func hashFile(fs billy.Basic, path string, h hash.Hash, p *progress, rel string) ([]byte, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(p.writer(h, rel), f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,116 @@
+package util_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func buildHashTree(t *testing.T) billy.Filesystem {
+	fs := memfs.New()
+	for name, content := range map[string]string{
+		"b/file":   "bar",
+		"a/file":   "foo",
+		"a/c/file": "qux",
+		"root":     "root",
+	} {
+		if err := util.WriteFile(fs, name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Symlink("a/file", "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestHashTree(t *testing.T) {
+	fs := buildHashTree(t)
+
+	sum, err := util.HashTree(fs, "/", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := util.HashTree(buildHashTree(t), "/", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sum, again) {
+		t.Errorf("HashTree is not deterministic: %x != %x", sum, again)
+	}
+
+	for name, mutate := range map[string]func(billy.Filesystem) error{
+		"content": func(fs billy.Filesystem) error {
+			return util.WriteFile(fs, "a/file", []byte("changed"), 0644)
+		},
+		"mode": func(fs billy.Filesystem) error {
+			if err := fs.Remove("a/c/file"); err != nil {
+				return err
+			}
+			return util.WriteFile(fs, "a/c/file", []byte("qux"), 0755)
+		},
+		"symlink": func(fs billy.Filesystem) error {
+			if err := fs.Remove("link"); err != nil {
+				return err
+			}
+			return fs.Symlink("b/file", "link")
+		},
+		"new file": func(fs billy.Filesystem) error {
+			return util.WriteFile(fs, "b/other", nil, 0644)
+		},
+		"removed file": func(fs billy.Filesystem) error {
+			return fs.Remove("root")
+		},
+	} {
+		fs := buildHashTree(t)
+		if err := mutate(fs); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		got, err := util.HashTree(fs, "/", sha256.New)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if bytes.Equal(sum, got) {
+			t.Errorf("%s: expected digest to change", name)
+		}
+	}
+}
+
+func TestHashTreeSubdir(t *testing.T) {
+	fs := buildHashTree(t)
+
+	sum, err := util.HashTree(fs, "a", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := memfs.New()
+	util.WriteFile(other, "elsewhere/file", []byte("foo"), 0644)
+	util.WriteFile(other, "elsewhere/c/file", []byte("qux"), 0644)
+
+	got, err := util.HashTree(other, "elsewhere", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(sum, got) {
+		t.Errorf("expected equal digests for equal trees, got %x and %x", sum, got)
+	}
+}
+
+func TestHashTreeNotExist(t *testing.T) {
+	_, err := util.HashTree(memfs.New(), "missing", sha256.New)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
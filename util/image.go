@@ -0,0 +1,413 @@
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// ErrImageChecksum is wrapped by the errors of DecodeImage when the content
+// of a file doesn't match its checksum.
+var ErrImageChecksum = errors.New("image entry checksum mismatch")
+
+const (
+	// imageChecksum is the PAX record holding the SHA-256 digest of the
+	// content of a regular file, in hex.
+	imageChecksum = "BILLY.sha256"
+	// imageXattr prefixes the PAX records holding the extended attributes,
+	// as GNU tar and bsdtar write them.
+	imageXattr = "SCHILY.xattr."
+)
+
+// EncodeImage writes the tree rooted at the directory root to w as an
+// image, a single stream from which DecodeImage restores it, for instance
+// to snapshot a storage directory into a support bundle.
+//
+// The image is a PAX tar stream, readable by the usual tools, with the
+// entries of WriteTar preceded by one for root itself. Along with the
+// content, the permission bits, the setuid, setgid and sticky bits, the
+// modification times and the symbolic links, it holds the extended
+// attributes, where fs implements billy.Xattrer, and the SHA-256 digest of
+// every regular file, checked by DecodeImage. A file changed while being
+// encoded makes EncodeImage fail.
+//
+// The stream is written as it is produced, w not being closed afterwards.
+func EncodeImage(w io.Writer, fs billy.Filesystem, root string) error {
+	fi, err := fs.Lstat(root)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return &os.PathError{Op: "encodeimage", Path: root, Err: errNotDir}
+	}
+
+	e := &imageEncoder{tw: tar.NewWriter(w), fs: fs, root: root}
+	if err := e.entry(".", fi); err != nil {
+		return err
+	}
+
+	return e.tw.Close()
+}
+
+var errNotDir = errors.New("not a directory")
+
+type imageEncoder struct {
+	tw   *tar.Writer
+	fs   billy.Filesystem
+	root string
+}
+
+func (e *imageEncoder) entry(name string, fi os.FileInfo) error {
+	fullpath := e.fs.Join(e.root, filepath.FromSlash(name))
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    tarMode(fi.Mode()),
+		ModTime: fi.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+
+	switch {
+	case fi.IsDir():
+		hdr.Typeflag, hdr.Name = tar.TypeDir, name+"/"
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := e.fs.Readlink(fullpath)
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, filepath.ToSlash(target)
+	case fi.Mode().IsRegular():
+		hdr.Typeflag, hdr.Size = tar.TypeReg, fi.Size()
+	default:
+		return nil
+	}
+
+	records, err := e.xattrs(fullpath, hdr.Typeflag)
+	if err != nil {
+		return err
+	}
+	hdr.PAXRecords = records
+
+	var sum []byte
+	if hdr.Typeflag == tar.TypeReg {
+		sum, err = hashFile(e.fs, fullpath, sha256.New(), nil, "")
+		if err != nil {
+			return err
+		}
+		hdr.PAXRecords[imageChecksum] = hex.EncodeToString(sum)
+	}
+
+	if err := e.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return e.dir(name)
+	case tar.TypeReg:
+		h := sha256.New()
+		if err := writeTarFile(io.MultiWriter(e.tw, h), e.fs, fullpath, hdr.Size); err != nil {
+			return err
+		}
+		if !bytes.Equal(h.Sum(nil), sum) {
+			return fmt.Errorf("%s: changed while encoding the image", fullpath)
+		}
+	}
+
+	return nil
+}
+
+func (e *imageEncoder) dir(rel string) error {
+	infos, err := e.fs.ReadDir(e.fs.Join(e.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	sort.Sort(byName(infos))
+
+	for _, fi := range infos {
+		if err := e.entry(path.Join(rel, fi.Name()), fi); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xattrs returns the PAX records of the extended attributes of the entry,
+// none for the symbolic links, whose attributes billy can't read.
+func (e *imageEncoder) xattrs(fullpath string, typeflag byte) (map[string]string, error) {
+	records := make(map[string]string)
+	x, ok := e.fs.(billy.Xattrer)
+	if !ok || typeflag == tar.TypeSymlink {
+		return records, nil
+	}
+
+	attrs, err := x.Listxattr(fullpath)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attr := range attrs {
+		value, err := x.Getxattr(fullpath, attr)
+		if errors.Is(err, billy.ErrNoXattr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records[imageXattr+attr] = string(value)
+	}
+
+	return records, nil
+}
+
+// DecodeImage restores the tree of the image read from r, written by
+// EncodeImage, into the directory root, created if needed. It is meant to
+// restore onto an empty root: the entries of the image replace the files
+// in the way, but the other entries are left as they are.
+//
+// The modes, the modification times and the extended attributes are
+// restored where fs implements billy.Change and billy.Xattrer, and ignored
+// otherwise. The content of every file is checked against its checksum, a
+// mismatch failing with an error wrapping ErrImageChecksum once the file is
+// written. The entries which would be written through a symbolic link of
+// the image are refused, so that a crafted image can't write outside root.
+func DecodeImage(r io.Reader, fs billy.Filesystem, root string) error {
+	d := &imageDecoder{fs: fs, root: root, links: make(map[string]bool)}
+	if err := fs.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := d.entry(hdr, tr); err != nil {
+			return err
+		}
+	}
+
+	// The modes and the times of the directories are set once their
+	// entries are written, the deepest first, so that a read-only directory
+	// is filled before.
+	for i := len(d.dirs) - 1; i >= 0; i-- {
+		dir := d.dirs[i]
+		if err := d.chmod(dir.path, dir.mode); err != nil {
+			return err
+		}
+		if err := d.chtimes(dir.path, dir.modTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type imageDecoder struct {
+	fs   billy.Filesystem
+	root string
+	// links are the names of the symbolic links restored.
+	links map[string]bool
+	dirs  []imageDir
+}
+
+type imageDir struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (d *imageDecoder) entry(hdr *tar.Header, tr io.Reader) error {
+	name := cleanTarName(hdr.Name)
+	for dir := path.Dir(name); name != "" && dir != "."; dir = path.Dir(dir) {
+		if d.links[dir] {
+			return &os.PathError{Op: "decodeimage", Path: hdr.Name, Err: ErrSymlinkEscapes}
+		}
+	}
+
+	fullpath := d.root
+	if name != "" {
+		fullpath = d.fs.Join(d.root, filepath.FromSlash(name))
+	}
+	mode := fileMode(hdr.Mode)
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := d.fs.MkdirAll(fullpath, 0o700); err != nil {
+			return err
+		}
+		d.dirs = append(d.dirs, imageDir{path: fullpath, mode: mode, modTime: hdr.ModTime})
+		return d.xattrs(fullpath, hdr.PAXRecords)
+	case tar.TypeSymlink:
+		if name == "" {
+			return &os.PathError{Op: "decodeimage", Path: hdr.Name, Err: errNotDir}
+		}
+		if err := d.remove(fullpath); err != nil {
+			return err
+		}
+		if err := d.fs.Symlink(filepath.FromSlash(hdr.Linkname), fullpath); err != nil {
+			return err
+		}
+		d.links[name] = true
+		return nil
+	case tar.TypeReg, tar.TypeRegA:
+		if name == "" {
+			return &os.PathError{Op: "decodeimage", Path: hdr.Name, Err: errNotDir}
+		}
+		if err := d.file(fullpath, hdr, tr); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	if err := d.xattrs(fullpath, hdr.PAXRecords); err != nil {
+		return err
+	}
+	if err := d.chmod(fullpath, mode); err != nil {
+		return err
+	}
+
+	return d.chtimes(fullpath, hdr.ModTime)
+}
+
+// remove removes the file at fullpath, if any, so that an entry of another
+// type takes its place.
+func (d *imageDecoder) remove(fullpath string) error {
+	fi, err := d.fs.Lstat(fullpath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return &os.PathError{Op: "decodeimage", Path: fullpath, Err: os.ErrExist}
+	}
+
+	return d.fs.Remove(fullpath)
+}
+
+func (d *imageDecoder) file(fullpath string, hdr *tar.Header, tr io.Reader) error {
+	want, err := hex.DecodeString(hdr.PAXRecords[imageChecksum])
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("%s: missing or invalid checksum", hdr.Name)
+	}
+
+	if fi, err := d.fs.Lstat(fullpath); err == nil && !fi.Mode().IsRegular() {
+		if err := d.remove(fullpath); err != nil {
+			return err
+		}
+	}
+
+	f, err := d.fs.OpenFile(fullpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode(hdr.Mode).Perm())
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), tr)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("%s: %w: expected sha256:%x, got sha256:%x", hdr.Name, ErrImageChecksum, want, got)
+	}
+
+	return nil
+}
+
+// xattrs restores the extended attributes of the entry at fullpath held by
+// records, where fs supports them.
+func (d *imageDecoder) xattrs(fullpath string, records map[string]string) error {
+	x, ok := d.fs.(billy.Xattrer)
+	if !ok {
+		return nil
+	}
+
+	for key, value := range records {
+		attr := strings.TrimPrefix(key, imageXattr)
+		if attr == key {
+			continue
+		}
+
+		err := x.Setxattr(fullpath, attr, []byte(value))
+		if errors.Is(err, billy.ErrNotSupported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *imageDecoder) chmod(fullpath string, mode os.FileMode) error {
+	c, ok := d.fs.(billy.Change)
+	if !ok {
+		return nil
+	}
+
+	err := c.Chmod(fullpath, mode)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return nil
+	}
+
+	return err
+}
+
+func (d *imageDecoder) chtimes(fullpath string, modTime time.Time) error {
+	c, ok := d.fs.(billy.Change)
+	if !ok {
+		return nil
+	}
+
+	err := c.Chtimes(fullpath, modTime, modTime)
+	if errors.Is(err, billy.ErrNotSupported) {
+		return nil
+	}
+
+	return err
+}
+
+// fileMode returns the os.FileMode of the mode of a tar header, the reverse
+// of tarMode.
+func fileMode(mode int64) os.FileMode {
+	m := os.FileMode(mode).Perm()
+	if mode&0o4000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		m |= os.ModeSticky
+	}
+
+	return m
+}
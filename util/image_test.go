@@ -0,0 +1,116 @@
+package util_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestImage(t *testing.T) {
+	src := memfs.New()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, err := range []error{
+		util.WriteFile(src, "state/dir/file", []byte("file"), 0o640),
+		util.WriteFile(src, "state/exec", []byte("exec"), 0o755),
+		src.Symlink("dir/file", "state/link"),
+		src.(billy.Xattrer).Setxattr("state/exec", "user.origin", []byte("test")),
+		src.(billy.Change).Chmod("state/dir", 0o500),
+		src.(billy.Change).Chtimes("state/dir/file", mtime, mtime),
+		src.(billy.Change).Chtimes("state/dir", mtime, mtime),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := util.EncodeImage(&buf, src, "state"); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := memfs.New()
+	if err := util.DecodeImage(&buf, dst, "restored"); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := util.HashTree(src, "state", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := util.HashTree(dst, "restored", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("restored tree differs")
+	}
+
+	for _, name := range []string{"restored/dir", "restored/dir/file"} {
+		fi, err := dst.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: mtime %v, want %v", name, fi.ModTime(), mtime)
+		}
+	}
+
+	value, err := dst.(billy.Xattrer).Getxattr("restored/exec", "user.origin")
+	if err != nil || string(value) != "test" {
+		t.Errorf("Getxattr = %q, %v", value, err)
+	}
+}
+
+func TestDecodeImage_Corrupted(t *testing.T) {
+	src := memfs.New()
+	if err := util.WriteFile(src, "file", []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := util.EncodeImage(&buf, src, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	image := bytes.Replace(buf.Bytes(), []byte("content"), []byte("CONTENT"), 1)
+	err := util.DecodeImage(bytes.NewReader(image), memfs.New(), "")
+	if !errors.Is(err, util.ErrImageChecksum) {
+		t.Fatalf("DecodeImage = %v, want %v", err, util.ErrImageChecksum)
+	}
+}
+
+func TestDecodeImage_ThroughSymlink(t *testing.T) {
+	image := buildTar(t, []tarEntry{
+		{hdr: tar.Header{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		{hdr: tar.Header{Name: "escape/passwd", Typeflag: tar.TypeReg, Mode: 0o644}, content: "x"},
+	})
+
+	fs := memfs.New()
+	err := util.DecodeImage(image, fs, "root")
+	if !errors.Is(err, util.ErrSymlinkEscapes) {
+		t.Fatalf("DecodeImage = %v, want %v", err, util.ErrSymlinkEscapes)
+	}
+	if _, err := fs.Stat("/etc/passwd"); !os.IsNotExist(err) {
+		t.Errorf("file written through the link: %v", err)
+	}
+}
+
+func TestDecodeImage_NoChecksum(t *testing.T) {
+	image := buildTar(t, []tarEntry{
+		{hdr: tar.Header{Name: "file", Typeflag: tar.TypeReg, Mode: 0o644}, content: "x"},
+	})
+
+	err := util.DecodeImage(image, memfs.New(), "")
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("DecodeImage = %v, want a checksum error", err)
+	}
+}
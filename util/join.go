@@ -1,6 +1,3 @@
-//go:build !js
-// +build !js
-
 // Copyright (C) 2014-2015 Docker Inc & Go Authors. All rights reserved.
 // Copyright (C) 2017 SUSE LLC. All rights reserved.
 // Use of this source code is governed by a BSD-style
@@ -9,12 +6,13 @@
 package util
 
 import (
-	"bytes"
 	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"syscall"
+
+	"github.com/go-git/go-billy/v5"
 )
 
 // IsNotExist tells you if err is an error that implies that either the path
@@ -38,15 +36,96 @@ func IsNotExist(err error) bool {
 // replaced with symlinks on the filesystem) after this function has returned.
 // Such a symlink race is necessarily out-of-scope of SecureJoin.
 func SecureJoinVFS(root, unsafePath string, vfs VFS) (string, error) {
+	return SecureJoinVFSWithOptions(root, unsafePath, vfs, Options{})
+}
+
+// DefaultMaxLinks is the number of symbolic links SecureJoinVFS dereferences
+// before failing with ELOOP.
+const DefaultMaxLinks = 255
+
+// Options tunes the resolution of SecureJoinVFSWithOptions.
+type Options struct {
+	// MaxLinks is the number of symbolic links dereferenced before failing
+	// with ELOOP. DefaultMaxLinks is used if it is zero or negative.
+	MaxLinks int
+	// OnSymlink, if not nil, is called with every symbolic link met, relative
+	// to root, and its destination. It returns the destination to follow,
+	// which may be rewritten, or an error to refuse the link, which
+	// SecureJoinVFSWithOptions returns wrapped in an *os.PathError.
+	OnSymlink func(link, dest string) (string, error)
+	// Strict makes the paths escaping root fail with an *EscapeError, see
+	// SecureJoinVFSStrict.
+	Strict bool
+}
+
+// SecureJoinVFSWithOptions is like SecureJoinVFS, with the traversal rules
+// given by opts.
+func SecureJoinVFSWithOptions(root, unsafePath string, vfs VFS, opts Options) (string, error) {
+	return secureJoin(root, unsafePath, vfs, opts)
+}
+
+// SecureJoinVFSStrict is like SecureJoinVFS, except that the paths which
+// would have escaped root, through ".." elements or symbolic links, are not
+// rewritten back inside root: an *EscapeError is returned instead.
+//
+// Absolute symbolic links pointing inside root are still followed.
+func SecureJoinVFSStrict(root, unsafePath string, vfs VFS) (string, error) {
+	return secureJoin(root, unsafePath, vfs, Options{Strict: true})
+}
+
+// EscapeError records a path resolution which would have escaped its root.
+type EscapeError struct {
+	// Root is the root the path was joined to.
+	Root string
+	// Path is the unsafe path being joined.
+	Path string
+	// Component is the element of the path that escapes, either ".." or
+	// the symbolic link, relative to Root, whose destination escapes.
+	Component string
+	// Target is where Component leads to, outside of Root.
+	Target string
+}
+
+func (e *EscapeError) Error() string {
+	return "securejoin " + e.Path + ": " + e.Component + " escapes " + e.Root + " to " + e.Target
+}
+
+// Unwrap returns billy.ErrCrossedBoundary.
+func (e *EscapeError) Unwrap() error { return billy.ErrCrossedBoundary }
+
+// separators holds the characters separating the elements of a path, the
+// slash being one on Windows too.
+const separators = string(filepath.Separator) + "/"
+
+// expansion is a symbolic link being expanded by secureJoin, whose
+// destination runs until only tail bytes of the unsafe path are left.
+type expansion struct {
+	link string
+	tail int
+}
+
+func secureJoin(root, unsafePath string, vfs VFS, opts Options) (string, error) {
 	// Use the os.* VFS implementation if none was specified.
 	if vfs == nil {
 		vfs = osVFS{}
 	}
+	maxLinks := opts.MaxLinks
+	if maxLinks <= 0 {
+		maxLinks = DefaultMaxLinks
+	}
+	strict := opts.Strict
+
+	orig := unsafePath
+	var links []expansion
 
-	var path bytes.Buffer
+	// cur is the path resolved so far, relative to root, which holds no
+	// symbolic link. It is kept clean, and starts with a separator unless
+	// it is root itself, in which case it is empty, so that each component
+	// only costs a concatenation.
+	cur := ""
 	n := 0
 	for unsafePath != "" {
-		if n > 255 {
+		if n > maxLinks {
 			return "", &os.PathError{Op: "SecureJoin", Path: root + string(filepath.Separator) + unsafePath, Err: syscall.ELOOP}
 		}
 
@@ -56,8 +135,13 @@ func SecureJoinVFS(root, unsafePath string, vfs VFS) (string, error) {
 			unsafePath = unsafePath[len(v):]
 		}
 
+		// Forget about the links whose destination was fully consumed.
+		for len(links) > 0 && len(unsafePath) <= links[len(links)-1].tail {
+			links = links[:len(links)-1]
+		}
+
 		// Next path component, p.
-		i := strings.IndexRune(unsafePath, filepath.Separator)
+		i := strings.IndexAny(unsafePath, separators)
 		var p string
 		if i == -1 {
 			p, unsafePath = unsafePath, ""
@@ -65,16 +149,29 @@ func SecureJoinVFS(root, unsafePath string, vfs VFS) (string, error) {
 			p, unsafePath = unsafePath[:i], unsafePath[i+1:]
 		}
 
-		// Create a cleaned path, using the lexical semantics of /../a, to
-		// create a "scoped" path component which can safely be joined to fullP
-		// for evaluation. At this point, path.String() doesn't contain any
-		// symlink components.
-		cleanP := filepath.Clean(string(filepath.Separator) + path.String() + p)
-		if cleanP == string(filepath.Separator) {
-			path.Reset()
+		switch p {
+		case "", ".":
+			continue
+		case "..":
+			if strict && cur == "" {
+				component := p
+				if len(links) > 0 {
+					component = links[len(links)-1].link
+				}
+				if target := filepath.Join(root, p); !inside(root, target) {
+					return "", &EscapeError{Root: root, Path: orig, Component: component, Target: target}
+				}
+			}
+			// Use the lexical semantics of /../a: cur doesn't contain any
+			// symlink component, and can't climb above root.
+			if cur != "" {
+				cur = cur[:strings.LastIndexByte(cur, filepath.Separator)]
+			}
 			continue
 		}
-		fullP := filepath.Clean(root + cleanP)
+
+		next := cur + string(filepath.Separator) + p
+		fullP := filepath.Clean(root + next)
 
 		// Figure out whether the path is a symlink.
 		fi, err := vfs.Lstat(fullP)
@@ -84,8 +181,7 @@ func SecureJoinVFS(root, unsafePath string, vfs VFS) (string, error) {
 		// Treat non-existent path components the same as non-symlinks (we
 		// can't do any better here).
 		if IsNotExist(err) || fi.Mode()&os.ModeSymlink == 0 {
-			path.WriteString(p)
-			path.WriteRune(filepath.Separator)
+			cur = next
 			continue
 		}
 
@@ -97,21 +193,41 @@ func SecureJoinVFS(root, unsafePath string, vfs VFS) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		link := next[1:]
+		if opts.OnSymlink != nil {
+			if dest, err = opts.OnSymlink(link, dest); err != nil {
+				return "", &os.PathError{Op: "SecureJoin", Path: fullP, Err: err}
+			}
+		}
 		// Absolute symlinks reset any work we've already done.
 		if filepath.IsAbs(dest) {
+			if strict && !inside(root, dest) {
+				return "", &EscapeError{Root: root, Path: orig, Component: link, Target: dest}
+			}
 			// Avoid duplicating root dir due to abs symlinks.
 			dest = strings.Replace(dest, root+string(filepath.Separator), string(filepath.Separator), 1)
-			path.Reset()
+			cur = ""
 		}
+		links = append(links, expansion{link: link, tail: len(unsafePath)})
 		unsafePath = dest + string(filepath.Separator) + unsafePath
 	}
 
-	// We have to clean path.String() here because it may contain '..'
-	// components that are entirely lexical, but would be misleading otherwise.
-	// And finally do a final clean to ensure that root is also lexically
-	// clean.
-	fullP := filepath.Clean(string(filepath.Separator) + path.String())
-	return filepath.Clean(root + fullP), nil
+	// Do a final clean to ensure that root is also lexically clean.
+	if cur == "" {
+		cur = string(filepath.Separator)
+	}
+	return filepath.Clean(root + cur), nil
+}
+
+// within reports whether the clean relative path rel stays below its base.
+func within(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// inside reports whether the absolute path name is root or below it.
+func inside(root, name string) bool {
+	rel, err := filepath.Rel(root, filepath.Clean(name))
+	return err == nil && within(rel)
 }
 
 // SecureJoin is a wrapper around SecureJoinVFS that just uses the os.* library
@@ -120,15 +236,40 @@ func SecureJoin(root, unsafePath string) (string, error) {
 	return SecureJoinVFS(root, unsafePath, nil)
 }
 
+// SecureJoinAll is like SecureJoin for each of paths, returning their
+// resolutions in the same order. The elements they share, such as common
+// prefixes, are queried once for all of them, through a StatCache dropped
+// on return. It fails with the first error met, the result being nil then.
+func SecureJoinAll(root string, paths []string) ([]string, error) {
+	vfs := NewStatCache(nil)
+
+	joined := make([]string, len(paths))
+	for i, p := range paths {
+		j, err := secureJoin(root, p, vfs, Options{})
+		if err != nil {
+			return nil, err
+		}
+		joined[i] = j
+	}
+
+	return joined, nil
+}
+
 // In future this should be moved into a separate package, because now there
 // are several projects (umoci and go-mtree) that are using this sort of
 // interface.
 
-// VFS is the minimal interface necessary to use SecureJoinVFS. A nil VFS is
-// equivalent to using the standard os.* family of functions. This is mainly
-// used for the purposes of mock testing, but also can be used to otherwise use
-// SecureJoin with VFS-like system.
+// VFS is the interface through which SecureJoinVFS, and the utilities
+// resolving paths like it, query the filesystem. A nil VFS is equivalent to
+// using the standard os.* family of functions. It can be implemented by any
+// billy.Filesystem, a memfs.Memory serving for instance to test the
+// resolutions without touching the disk, while NewVFS adapts the filesystems
+// implementing a part of it only.
 type VFS interface {
+	// Stat returns a FileInfo describing the named file, following the
+	// symbolic links. These semantics are identical to os.Stat.
+	Stat(name string) (os.FileInfo, error)
+
 	// Lstat returns a FileInfo describing the named file. If the file is a
 	// symbolic link, the returned FileInfo describes the symbolic link. Lstat
 	// makes no attempt to follow the link. These semantics are identical to
@@ -138,12 +279,20 @@ type VFS interface {
 	// Readlink returns the destination of the named symbolic link. These
 	// semantics are identical to os.Readlink.
 	Readlink(name string) (string, error)
+
+	// ReadDir returns the entries of the named directory, sorted by name,
+	// as ioutil.ReadDir does.
+	ReadDir(name string) ([]os.FileInfo, error)
 }
 
 // osVFS is the "nil" VFS, in that it just passes everything through to the os
 // module.
 type osVFS struct{}
 
+// Stat returns a FileInfo describing the named file. These semantics are
+// identical to os.Stat.
+func (o osVFS) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
 // Lstat returns a FileInfo describing the named file. If the file is a
 // symbolic link, the returned FileInfo describes the symbolic link. Lstat
 // makes no attempt to follow the link. These semantics are identical to
@@ -153,3 +302,21 @@ func (o osVFS) Lstat(name string) (os.FileInfo, error) { return os.Lstat(name) }
 // Readlink returns the destination of the named symbolic link. These
 // semantics are identical to os.Readlink.
 func (o osVFS) Readlink(name string) (string, error) { return os.Readlink(name) }
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (o osVFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, fi)
+	}
+	return infos, nil
+}
@@ -294,6 +294,7 @@ func TestIsNotExist(t *testing.T) {
 }
 
 type mockVFS struct {
+	osVFS
 	lstat    func(path string) (os.FileInfo, error)
 	readlink func(path string) (string, error)
 }
@@ -427,3 +428,144 @@ func TestSecureJoinVFSErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestSecureJoinVFSStrict(t *testing.T) {
+	dir := t.TempDir()
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "subdir"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "cousinparent", "cousin"), 0o755)
+	symlink(t, filepath.FromSlash("../cousinparent/cousin"), filepath.Join(dir, "subdir", "link"))
+	symlink(t, filepath.FromSlash("../../outside"), filepath.Join(dir, "subdir", "up"))
+	symlink(t, filepath.Join(dir, "cousinparent"), filepath.Join(dir, "inside"))
+	symlink(t, filepath.FromSlash("/etc"), filepath.Join(dir, "abs"))
+
+	for _, test := range []struct {
+		unsafe    string
+		expected  string
+		component string
+		target    string
+	}{
+		{unsafe: "subdir/../test", expected: filepath.Join(dir, "test")},
+		{unsafe: "subdir/link/../test", expected: filepath.Join(dir, "cousinparent", "test")},
+		{unsafe: "inside/cousin", expected: filepath.Join(dir, "cousinparent", "cousin")},
+		{unsafe: "../test", component: "..", target: filepath.Dir(dir)},
+		{unsafe: "subdir/../../test", component: "..", target: filepath.Dir(dir)},
+		{unsafe: "subdir/up/test", component: filepath.FromSlash("subdir/up"), target: filepath.Dir(dir)},
+		{unsafe: "abs/passwd", component: "abs", target: filepath.FromSlash("/etc")},
+	} {
+		unsafe := filepath.FromSlash(test.unsafe)
+		got, err := SecureJoinVFSStrict(dir, unsafe, nil)
+		if test.component == "" {
+			if err != nil {
+				t.Errorf("securejoin(%q): unexpected error: %v", unsafe, err)
+			} else if got != test.expected {
+				t.Errorf("securejoin(%q): expected %q, got %q", unsafe, test.expected, got)
+			}
+			continue
+		}
+
+		var escape *EscapeError
+		if !errors.As(err, &escape) {
+			t.Errorf("securejoin(%q): expected an escape error, got %q, %v", unsafe, got, err)
+			continue
+		}
+		if escape.Root != dir || escape.Path != unsafe || escape.Component != test.component || escape.Target != test.target {
+			t.Errorf("securejoin(%q): unexpected escape error %#v", unsafe, escape)
+		}
+
+		// The default behaviour is left unchanged.
+		if _, err := SecureJoinVFS(dir, unsafe, nil); err != nil {
+			t.Errorf("securejoin(%q): unexpected error: %v", unsafe, err)
+		}
+	}
+}
+
+func TestSecureJoinVFSWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "target"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "other"), 0o755)
+	symlink(t, "b", filepath.Join(dir, "a"))
+	symlink(t, "c", filepath.Join(dir, "b"))
+	symlink(t, "target", filepath.Join(dir, "c"))
+
+	got, err := SecureJoinVFSWithOptions(dir, "a", nil, Options{MaxLinks: 3})
+	if err != nil || got != filepath.Join(dir, "target") {
+		t.Errorf("securejoin with 3 links: got %q, %v", got, err)
+	}
+	if _, err := SecureJoinVFSWithOptions(dir, "a", nil, Options{MaxLinks: 2}); !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("securejoin with 2 links: expected ELOOP, got %v", err)
+	}
+
+	var seen []string
+	errDenied := errors.New("denied")
+	opts := Options{OnSymlink: func(link, dest string) (string, error) {
+		seen = append(seen, link+"->"+dest)
+		switch link {
+		case "b":
+			return "other", nil
+		case "c":
+			return "", errDenied
+		}
+		return dest, nil
+	}}
+
+	got, err = SecureJoinVFSWithOptions(dir, filepath.Join("a", "file"), nil, opts)
+	if err != nil || got != filepath.Join(dir, "other", "file") {
+		t.Errorf("securejoin with rewrite: got %q, %v", got, err)
+	}
+	if expected := []string{"a->b", "b->c"}; len(seen) != 2 || seen[0] != expected[0] || seen[1] != expected[1] {
+		t.Errorf("securejoin with rewrite: expected links %q, got %q", expected, seen)
+	}
+
+	_, err = SecureJoinVFSWithOptions(dir, "c", nil, opts)
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr.Err != errDenied || pathErr.Path != filepath.Join(dir, "c") {
+		t.Errorf("securejoin with deny: unexpected error %v", err)
+	}
+}
+
+func TestSecureJoinAll(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755)
+	symlink(t, filepath.Join("a", "b"), filepath.Join(dir, "link"))
+	symlink(t, "/", filepath.Join(dir, "a", "up"))
+
+	got, err := SecureJoinAll(dir, []string{
+		filepath.Join("link", "x"),
+		filepath.Join("link", "y"),
+		filepath.Join("a", "up", "a", "z"),
+		filepath.Join("..", "..", "w"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a", "b", "x"),
+		filepath.Join(dir, "a", "b", "y"),
+		filepath.Join(dir, "a", "z"),
+		filepath.Join(dir, "w"),
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("path %d: expected %q, got %q", i, expected[i], got[i])
+		}
+	}
+
+	symlink(t, "loop", filepath.Join(dir, "loop"))
+	if _, err := SecureJoinAll(dir, []string{"a", "loop"}); !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("expected ELOOP, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+var (
+	// ErrLeaseHeld is returned by AcquireLease when the lease is held by
+	// another owner and hasn't expired.
+	ErrLeaseHeld = errors.New("lease held by another owner")
+	// ErrLeaseLost is returned by Lease.Renew and Lease.Release when the
+	// lease expired, and may have been taken over by another owner.
+	ErrLeaseLost = errors.New("lease lost")
+)
+
+// CreateExclusive creates the file named by path with data as content,
+// failing with an error satisfying os.IsExist if it already exists. It only
+// relies on O_EXCL, so it can be used on any backend honoring it, including
+// the object stores without native locks. The file is removed if the data
+// can't be written.
+//
+// The file is visible, empty or partially written, before CreateExclusive
+// returns: the readers must tolerate it, as AcquireLease does.
+func CreateExclusive(fs billy.Basic, path string, data []byte) error {
+	f, err := fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	n, err := f.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = fs.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+// LeaseInfo describes the holder of a lease, as recorded in its file.
+type LeaseInfo struct {
+	// Owner is the name given by the holder to AcquireLease.
+	Owner string `json:"owner"`
+	// Expires is the time after which the lease can be taken over.
+	Expires time.Time `json:"expires"`
+	// Token identifies the acquisition, telling apart the successive
+	// leases of the same owner.
+	Token string `json:"token"`
+}
+
+// Lease is a lease on a file, held by a single owner until it expires,
+// usable for leader election between processes sharing a filesystem. The
+// lease file is created exclusively and records its expiry, so that the
+// other candidates can take it over once the holder stops renewing it, for
+// instance because it crashed.
+//
+// The expiry is compared with the clocks of the candidates, which must be
+// reasonably synchronized: a lease should be renewed well before it expires,
+// the margin covering the clock skew and the time taken by Renew.
+type Lease struct {
+	fs   billy.Filesystem
+	path string
+	ttl  time.Duration
+
+	m    sync.Mutex
+	info LeaseInfo
+}
+
+// AcquireLease acquires the lease whose file is path, for owner and for a
+// duration of ttl, failing with ErrLeaseHeld if another owner holds it. An
+// expired lease is taken over, the candidates doing so concurrently being
+// serialized so that only one of them gets it.
+//
+// A lease file which can't be parsed, being written or corrupted, is
+// considered expired once its modification time is older than ttl.
+func AcquireLease(fs billy.Filesystem, path, owner string, ttl time.Duration) (*Lease, error) {
+	for i := 0; i < 2; i++ {
+		l, err := createLease(fs, path, owner, ttl)
+		if !os.IsExist(err) {
+			return l, err
+		}
+
+		info, expired, err := leaseExpired(fs, path, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if !expired {
+			return nil, ErrLeaseHeld
+		}
+
+		// The lease may be taken over by another candidate meanwhile, the
+		// creation being attempted once more in any case.
+		err = breakFile(fs, path, ttl, func(data []byte) bool {
+			var cur LeaseInfo
+			return json.Unmarshal(data, &cur) != nil ||
+				cur.Token == info.Token && Now().After(cur.Expires)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrLeaseHeld
+}
+
+func createLease(fs billy.Filesystem, path, owner string, ttl time.Duration) (*Lease, error) {
+	src := currentSource()
+	info := LeaseInfo{
+		Owner:   owner,
+		Expires: Now().Add(ttl),
+		Token:   fmt.Sprintf("%08x%08x", src.Uint32(), src.Uint32()),
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := CreateExclusive(fs, path, data); err != nil {
+		return nil, err
+	}
+
+	return &Lease{fs: fs, path: path, ttl: ttl, info: info}, nil
+}
+
+// leaseExpired reads the lease file at path, and reports whether it can be
+// taken over.
+func leaseExpired(fs billy.Filesystem, path string, ttl time.Duration) (LeaseInfo, bool, error) {
+	var info LeaseInfo
+	data, err := ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return info, true, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+	if json.Unmarshal(data, &info) == nil {
+		return info, Now().After(info.Expires), nil
+	}
+
+	fi, err := fs.Stat(path)
+	if os.IsNotExist(err) {
+		return info, true, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+
+	return info, Now().Sub(fi.ModTime()) > ttl, nil
+}
+
+// ReadLease returns the holder of the lease whose file is path, which may
+// have expired. It fails with an error satisfying os.IsNotExist if the
+// lease isn't held.
+func ReadLease(fs billy.Basic, path string) (LeaseInfo, error) {
+	var info LeaseInfo
+	data, err := ReadFile(fs, path)
+	if err != nil {
+		return info, err
+	}
+
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+// Owner returns the owner of the lease.
+func (l *Lease) Owner() string {
+	return l.info.Owner
+}
+
+// Expires returns the time at which the lease expires, unless renewed.
+func (l *Lease) Expires() time.Time {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.info.Expires
+}
+
+// Renew extends the lease by its ttl from now. It fails with ErrLeaseLost
+// once the lease expired, even if it wasn't taken over yet, since another
+// candidate may be doing so. The lease file is replaced atomically, see
+// WriteFileAtomic, so that the candidates never read it partially written.
+func (l *Lease) Renew() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if err := l.check(); err != nil {
+		return err
+	}
+
+	info := l.info
+	info.Expires = Now().Add(l.ttl)
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(l.fs, l.path, data, 0644); err != nil {
+		return err
+	}
+
+	l.info = info
+	return nil
+}
+
+// Release gives up the lease, removing its file. It fails with ErrLeaseLost
+// if the lease expired, in which case the file is left to the candidates
+// taking it over.
+func (l *Lease) Release() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if err := l.check(); err != nil {
+		return err
+	}
+
+	if err := l.fs.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Prevent any later Renew from recreating the file.
+	l.info.Expires = time.Time{}
+	return nil
+}
+
+// check returns ErrLeaseLost unless the lease file still holds the token of
+// l, unexpired.
+func (l *Lease) check() error {
+	if !Now().Before(l.info.Expires) {
+		return ErrLeaseLost
+	}
+
+	info, err := ReadLease(l.fs, l.path)
+	if os.IsNotExist(err) {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return err
+	}
+	if info.Token != l.info.Token {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package util_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestCreateExclusive(t *testing.T) {
+	fs := memfs.New()
+
+	if err := util.CreateExclusive(fs, "leader", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.CreateExclusive(fs, "leader", []byte("b")); !os.IsExist(err) {
+		t.Fatalf("CreateExclusive = %v, want an existence error", err)
+	}
+
+	assertContent(t, fs, "leader", "a")
+}
+
+func TestLease(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	util.SetSource(util.NewFixedSource(1, now))
+	defer util.SetSource(nil)
+
+	fs := memfs.New()
+	a, err := util.AcquireLease(fs, "leader", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.AcquireLease(fs, "leader", "b", time.Minute); err != util.ErrLeaseHeld {
+		t.Fatalf("AcquireLease(b) = %v, want %v", err, util.ErrLeaseHeld)
+	}
+
+	info, err := util.ReadLease(fs, "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Owner != "a" || !info.Expires.Equal(now.Add(time.Minute)) {
+		t.Fatalf("ReadLease = %+v", info)
+	}
+
+	util.SetSource(util.NewFixedSource(2, now.Add(30*time.Second)))
+	if err := a.Renew(); err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(90 * time.Second); !a.Expires().Equal(want) {
+		t.Fatalf("Expires = %v, want %v", a.Expires(), want)
+	}
+
+	// Once expired, the lease is taken over, and lost by its holder.
+	util.SetSource(util.NewFixedSource(3, now.Add(2*time.Minute)))
+	b, err := util.AcquireLease(fs, "leader", "b", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Renew(); err != util.ErrLeaseLost {
+		t.Fatalf("Renew(a) = %v, want %v", err, util.ErrLeaseLost)
+	}
+	if err := a.Release(); err != util.ErrLeaseLost {
+		t.Fatalf("Release(a) = %v, want %v", err, util.ErrLeaseLost)
+	}
+
+	if err := b.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.ReadLease(fs, "leader"); !os.IsNotExist(err) {
+		t.Fatalf("ReadLease = %v, want a not exist error", err)
+	}
+	if _, err := fs.Stat("leader.break"); !os.IsNotExist(err) {
+		t.Fatalf("takeover marker left: %v", err)
+	}
+}
+
+func TestLease_Unparsable(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	util.SetSource(util.NewFixedSource(1, now))
+	defer util.SetSource(nil)
+
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "leader", []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file may be being written by the new holder.
+	if _, err := util.AcquireLease(fs, "leader", "a", time.Minute); err != util.ErrLeaseHeld {
+		t.Fatalf("AcquireLease = %v, want %v", err, util.ErrLeaseHeld)
+	}
+
+	util.SetSource(util.NewFixedSource(2, now.Add(2*time.Minute)))
+	l, err := util.AcquireLease(fs, "leader", "a", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Owner() != "a" {
+		t.Fatalf("Owner = %q", l.Owner())
+	}
+}
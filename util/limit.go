@@ -0,0 +1,98 @@
+package util
+
+import (
+	"io"
+	"strconv"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// FileSizeError is returned by the writes rejected by LimitWriter, or by the
+// filesystems of limitfs.
+type FileSizeError struct {
+	// Path is the name of the file.
+	Path string
+	// Max is the limit which would have been exceeded, in bytes.
+	Max int64
+}
+
+func (e *FileSizeError) Error() string {
+	return "write " + e.Path + ": file too large, limit is " + strconv.FormatInt(e.Max, 10) + " bytes"
+}
+
+// Unwrap returns syscall.EFBIG.
+func (e *FileSizeError) Unwrap() error { return syscall.EFBIG }
+
+// LimitWriter returns a File writing to f at most max bytes. The write which
+// would exceed max fails with a *FileSizeError, without writing anything,
+// and f is truncated to the size it had before the first write through the
+// File returned, discarding the partial content. The later writes fail with
+// the same error. Truncating f to more than max bytes fails as well.
+//
+// The bytes written are counted, wherever they are written, so LimitWriter
+// is meant for the files being created or appended to, such as the ones of
+// an archive being extracted.
+func LimitWriter(f billy.File, max int64) billy.File {
+	return &limitFile{File: f, left: max, max: max, base: -1}
+}
+
+// limitFile is the billy.File returned by LimitWriter.
+type limitFile struct {
+	billy.File
+	left, max int64
+	// base is the size of the file before it was written to, -1 until
+	// then.
+	base int64
+	err  error
+}
+
+func (f *limitFile) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	if f.base < 0 {
+		base, err := fileSize(f.File)
+		if err != nil {
+			return 0, err
+		}
+		f.base = base
+	}
+
+	if int64(len(p)) > f.left {
+		f.err = &FileSizeError{Path: f.Name(), Max: f.max}
+		if err := f.File.Truncate(f.base); err != nil {
+			return 0, err
+		}
+		return 0, f.err
+	}
+
+	n, err := f.File.Write(p)
+	f.left -= int64(n)
+	return n, err
+}
+
+func (f *limitFile) Truncate(size int64) error {
+	if size > f.max {
+		return &FileSizeError{Path: f.Name(), Max: f.max}
+	}
+
+	return f.File.Truncate(size)
+}
+
+// fileSize returns the size of f, keeping its offset.
+func fileSize(f billy.File) (int64, error) {
+	off, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = f.Seek(off, io.SeekStart)
+	return size, err
+}
@@ -0,0 +1,71 @@
+package util_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestLimitWriter(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "file", []byte("head"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fs.OpenFile("file", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lf := util.LimitWriter(f, 6)
+
+	if _, err := lf.Write([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lf.Write([]byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := lf.Write([]byte("!"))
+	var serr *util.FileSizeError
+	if n != 0 || !errors.As(err, &serr) || serr.Max != 6 || serr.Path != "file" {
+		t.Fatalf("got %d, %v, want a FileSizeError", n, err)
+	}
+	if !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("%v doesn't match EFBIG", err)
+	}
+	if _, err := lf.Write(nil); !errors.As(err, &serr) {
+		t.Errorf("write after the limit: got %v", err)
+	}
+	if err := lf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := util.ReadFile(fs, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "head" {
+		t.Errorf("got %q, want the content before the writes", got)
+	}
+}
+
+func TestLimitWriter_Truncate(t *testing.T) {
+	fs := memfs.New()
+	f, err := fs.Create("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lf := util.LimitWriter(f, 10)
+	if err := lf.Truncate(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Truncate(1 << 40); !errors.Is(err, syscall.EFBIG) {
+		t.Errorf("got %v, want EFBIG", err)
+	}
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// lockPollMax is the longest wait between two attempts of LockContext.
+const lockPollMax = 100 * time.Millisecond
+
+// LockContext locks f exclusively, as File.Lock does, giving up with the error
+// of ctx once it is done, so that a timeout can be set with
+// context.WithTimeout. The lock is attempted with billy.RWLocker.TryLock at
+// growing intervals; billy.ErrNotSupported is returned if f doesn't
+// implement it.
+func LockContext(ctx context.Context, f billy.File) error {
+	l, ok := f.(billy.RWLocker)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return pollLock(ctx, l.TryLock)
+}
+
+// RLockContext is the shared counterpart of LockContext, attempting the lock
+// with billy.RWLocker.TryRLock.
+func RLockContext(ctx context.Context, f billy.File) error {
+	l, ok := f.(billy.RWLocker)
+	if !ok {
+		return billy.ErrNotSupported
+	}
+
+	return pollLock(ctx, l.TryRLock)
+}
+
+func pollLock(ctx context.Context, try func() (bool, error)) error {
+	wait := time.Millisecond
+	for {
+		ok, err := try()
+		if ok || err != nil {
+			return err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		if wait *= 2; wait > lockPollMax {
+			wait = lockPollMax
+		}
+	}
+}
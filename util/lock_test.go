@@ -0,0 +1,43 @@
+package util_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestLockContext(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	holder, err := fs.Open("lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Close()
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fs.Open("lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := util.RLockContext(ctx, f); err != context.DeadlineExceeded {
+		t.Fatalf("RLockContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { holder.Unlock() })
+	if err := util.LockContext(context.Background(), f); err != nil {
+		t.Fatalf("LockContext() = %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+var (
+	// ErrLocked is returned by Locker.TryLock when the lock is held.
+	ErrLocked = errors.New("lock held by another owner")
+	// ErrLockLost is returned by Lock.Unlock when the lock was taken over,
+	// its heartbeat having been missed for longer than Locker.StaleAfter.
+	ErrLockLost = errors.New("lock lost")
+)
+
+// DefaultLockStaleAfter is the default of Locker.StaleAfter.
+const DefaultLockStaleAfter = time.Minute
+
+// Locker hands out named advisory locks shared between processes, possibly
+// on several hosts, through lock files created exclusively in a directory.
+// It works on any filesystem supporting O_EXCL, such as a volume shared by
+// the replicas of a controller, even without file locks.
+//
+// A lock is held for as long as its file exists. While held, a heartbeat is
+// written to it, so that the locks of crashed owners can be taken over once
+// it gets older than StaleAfter. The locks of the processes of the same host
+// which are no longer running, or which ran before the host was rebooted,
+// are taken over right away.
+type Locker struct {
+	// StaleAfter is the age of the heartbeat after which a lock is
+	// considered abandoned. DefaultLockStaleAfter is used if it is zero.
+	StaleAfter time.Duration
+	// Heartbeat is the interval between the heartbeats written to the held
+	// locks. A third of StaleAfter is used if it is zero.
+	Heartbeat time.Duration
+
+	fs  billy.Filesystem
+	dir string
+}
+
+// NewLocker returns a Locker keeping its lock files in the directory path of
+// fs, created when the first lock is taken.
+func NewLocker(fs billy.Filesystem, path string) *Locker {
+	return &Locker{fs: fs, dir: path}
+}
+
+// lockInfo is the content of a lock file.
+type lockInfo struct {
+	Token     string    `json:"token"`
+	Host      string    `json:"host"`
+	BootID    string    `json:"bootID,omitempty"`
+	PID       int       `json:"pid"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// Lock takes the lock name, waiting for it to be released or to become
+// stale, up to ctx being done.
+func (l *Locker) Lock(ctx context.Context, name string) (*Lock, error) {
+	var lk *Lock
+	err := pollLock(ctx, func() (bool, error) {
+		var err error
+		lk, err = l.TryLock(name)
+		if err == ErrLocked {
+			return false, nil
+		}
+		return err == nil, err
+	})
+
+	return lk, err
+}
+
+// TryLock takes the lock name, failing with ErrLocked if it is held. A stale
+// lock is taken over.
+func (l *Locker) TryLock(name string) (*Lock, error) {
+	if err := l.fs.MkdirAll(l.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := l.fs.Join(l.dir, name+".lock")
+	for i := 0; i < 2; i++ {
+		lk, err := l.create(name, path)
+		if !os.IsExist(err) {
+			return lk, err
+		}
+
+		info, stale, err := l.stale(path)
+		if err != nil {
+			return nil, err
+		}
+		if !stale {
+			return nil, ErrLocked
+		}
+
+		// The lock may be broken by another process meanwhile, the
+		// creation being attempted once more in any case.
+		if err := l.breakLock(path, info.Token); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrLocked
+}
+
+func (l *Locker) create(name, path string) (*Lock, error) {
+	host, _ := os.Hostname()
+	src := currentSource()
+	info := lockInfo{
+		Token:     fmt.Sprintf("%s-%d-%08x%08x", host, os.Getpid(), src.Uint32(), src.Uint32()),
+		Host:      host,
+		BootID:    bootID(),
+		PID:       os.Getpid(),
+		Heartbeat: Now(),
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := CreateExclusive(l.fs, path, data); err != nil {
+		return nil, err
+	}
+
+	lk := &Lock{
+		l:    l,
+		name: name,
+		path: path,
+		info: info,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go lk.heartbeat()
+
+	return lk, nil
+}
+
+// stale reads the lock file at path, and reports whether it was abandoned.
+// The files which can't be parsed, being written or corrupted, are stale
+// once their modification time is older than StaleAfter.
+func (l *Locker) stale(path string) (lockInfo, bool, error) {
+	var info lockInfo
+	data, err := ReadFile(l.fs, path)
+	if os.IsNotExist(err) {
+		return info, true, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+
+	if err := json.Unmarshal(data, &info); err != nil {
+		fi, err := l.fs.Stat(path)
+		if os.IsNotExist(err) {
+			return info, true, nil
+		}
+		if err != nil {
+			return info, false, err
+		}
+
+		return info, Now().Sub(fi.ModTime()) > l.staleAfter(), nil
+	}
+
+	// The owners running on this host can be checked without waiting for
+	// their heartbeat to expire.
+	if host, _ := os.Hostname(); info.Host == host && info.BootID != "" {
+		switch boot := bootID(); {
+		case boot == "":
+		case boot != info.BootID:
+			return info, true, nil
+		case !processAlive(info.PID):
+			return info, true, nil
+		}
+	}
+
+	return info, Now().Sub(info.Heartbeat) > l.staleAfter(), nil
+}
+
+// breakLock removes the stale lock at path if it still holds token.
+func (l *Locker) breakLock(path, token string) error {
+	return breakFile(l.fs, path, l.staleAfter(), func(data []byte) bool {
+		var info lockInfo
+		return json.Unmarshal(data, &info) != nil || info.Token == token
+	})
+}
+
+// breakFile removes the file at path, found abandoned, if stale still
+// reports so from its content once read again. The processes breaking a
+// file are serialized by a marker file, so that a file created meanwhile
+// isn't removed; the marker left by a process which crashed is removed once
+// older than markerTTL.
+func breakFile(fs billy.Filesystem, path string, markerTTL time.Duration, stale func(data []byte) bool) error {
+	marker := path + ".break"
+	f, err := fs.OpenFile(marker, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		if fi, err := fs.Stat(marker); err == nil && Now().Sub(fi.ModTime()) > markerTTL {
+			_ = fs.Remove(marker)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer fs.Remove(marker)
+
+	data, err := ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !stale(data) {
+		return nil
+	}
+
+	if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Locker) staleAfter() time.Duration {
+	if l.StaleAfter > 0 {
+		return l.StaleAfter
+	}
+
+	return DefaultLockStaleAfter
+}
+
+func (l *Locker) heartbeatInterval() time.Duration {
+	if l.Heartbeat > 0 {
+		return l.Heartbeat
+	}
+
+	return l.staleAfter() / 3
+}
+
+// Lock is a lock held from a Locker.
+type Lock struct {
+	l    *Locker
+	name string
+	path string
+	info lockInfo
+
+	m    sync.Mutex
+	lost bool
+	once sync.Once
+	err  error
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Name returns the name of the lock.
+func (lk *Lock) Name() string {
+	return lk.name
+}
+
+func (lk *Lock) heartbeat() {
+	defer close(lk.done)
+
+	t := time.NewTicker(lk.l.heartbeatInterval())
+	defer t.Stop()
+
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-t.C:
+		}
+
+		if !lk.owned() {
+			lk.m.Lock()
+			lk.lost = true
+			lk.m.Unlock()
+			return
+		}
+
+		info := lk.info
+		info.Heartbeat = Now()
+		if data, err := json.Marshal(info); err == nil {
+			_ = WriteFileAtomic(lk.l.fs, lk.path, data, 0644)
+		}
+	}
+}
+
+// owned reports whether the lock file still holds the token of lk.
+func (lk *Lock) owned() bool {
+	data, err := ReadFile(lk.l.fs, lk.path)
+	if err != nil {
+		return false
+	}
+
+	var info lockInfo
+	return json.Unmarshal(data, &info) == nil && info.Token == lk.info.Token
+}
+
+// Unlock releases the lock, removing its file. ErrLockLost is returned if it
+// was taken over by another owner, in which case the file is left alone.
+// Calling Unlock more than once returns the result of the first call.
+func (lk *Lock) Unlock() error {
+	lk.once.Do(func() {
+		close(lk.stop)
+		<-lk.done
+
+		lk.m.Lock()
+		lost := lk.lost
+		lk.m.Unlock()
+
+		if lost || !lk.owned() {
+			lk.err = ErrLockLost
+			return
+		}
+
+		if err := lk.l.fs.Remove(lk.path); err != nil && !os.IsNotExist(err) {
+			lk.err = err
+		}
+	})
+
+	return lk.err
+}
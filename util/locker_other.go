@@ -0,0 +1,14 @@
+//go:build windows || plan9 || js
+// +build windows plan9 js
+
+package util
+
+// bootID returns an empty string, the heartbeats being relied on alone to
+// detect the stale locks.
+func bootID() string {
+	return ""
+}
+
+func processAlive(pid int) bool {
+	return true
+}
@@ -0,0 +1,170 @@
+package util_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestLocker(t *testing.T) {
+	fs := memfs.New()
+	l := util.NewLocker(fs, "locks")
+
+	a, err := l.TryLock("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TryLock("a"); err != util.ErrLocked {
+		t.Fatalf("TryLock(a) = %v, want %v", err, util.ErrLocked)
+	}
+
+	b, err := l.TryLock("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Unlock()
+
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat("locks/a.lock"); !os.IsNotExist(err) {
+		t.Fatalf("lock file not removed: %v", err)
+	}
+
+	a, err = l.TryLock("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Unlock()
+}
+
+func TestLocker_Wait(t *testing.T) {
+	l := util.NewLocker(memfs.New(), "locks")
+
+	held, err := l.TryLock("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Lock(ctx, "a"); err != context.DeadlineExceeded {
+		t.Fatalf("Lock(a) = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { held.Unlock() })
+	lk, err := l.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lk.Unlock()
+}
+
+
+func TestLocker_Stale(t *testing.T) {
+	fs := memfs.New()
+	l := util.NewLocker(fs, "locks")
+	l.StaleAfter = time.Minute
+
+	stale, _ := json.Marshal(map[string]interface{}{
+		"token":     "other",
+		"host":      "elsewhere",
+		"pid":       1,
+		"heartbeat": time.Now().Add(-2 * time.Minute),
+	})
+	if err := util.WriteFile(fs, "locks/a.lock", stale, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lk, err := l.TryLock("a")
+	if err != nil {
+		t.Fatalf("TryLock(a) = %v, the stale lock should be taken over", err)
+	}
+	defer lk.Unlock()
+
+	fresh, _ := json.Marshal(map[string]interface{}{
+		"token":     "other",
+		"host":      "elsewhere",
+		"pid":       1,
+		"heartbeat": time.Now(),
+	})
+	if err := util.WriteFile(fs, "locks/b.lock", fresh, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.TryLock("b"); err != util.ErrLocked {
+		t.Fatalf("TryLock(b) = %v, want %v", err, util.ErrLocked)
+	}
+}
+
+func TestLocker_DeadProcess(t *testing.T) {
+	b, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if runtime.GOOS != "linux" || err != nil {
+		t.Skip("boot id not available")
+	}
+	host, _ := os.Hostname()
+
+	fs := memfs.New()
+	l := util.NewLocker(fs, "locks")
+
+	// The pid is above the maximum of Linux.
+	dead, _ := json.Marshal(map[string]interface{}{
+		"token":     "other",
+		"host":      host,
+		"bootID":    strings.TrimSpace(string(b)),
+		"pid":       1 << 23,
+		"heartbeat": time.Now(),
+	})
+	if err := util.WriteFile(fs, "locks/a.lock", dead, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lk, err := l.TryLock("a")
+	if err != nil {
+		t.Fatalf("TryLock(a) = %v, the lock of a dead process should be taken over", err)
+	}
+	lk.Unlock()
+}
+
+func TestLocker_Heartbeat(t *testing.T) {
+	fs := memfs.New()
+	l := util.NewLocker(fs, "locks")
+	l.StaleAfter = 50 * time.Millisecond
+	l.Heartbeat = 5 * time.Millisecond
+
+	lk, err := l.TryLock("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := l.TryLock("a"); err != util.ErrLocked {
+		t.Fatalf("TryLock(a) = %v, want %v", err, util.ErrLocked)
+	}
+
+	// Another owner taking the lock over is noticed.
+	if err := fs.Remove("locks/a.lock"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := l.TryLock("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Unlock()
+
+	if err := lk.Unlock(); err != util.ErrLockLost {
+		t.Fatalf("Unlock() = %v, want %v", err, util.ErrLockLost)
+	}
+	if _, err := fs.Stat("locks/a.lock"); err != nil {
+		t.Fatalf("lock file of the new owner removed: %v", err)
+	}
+}
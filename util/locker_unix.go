@@ -0,0 +1,26 @@
+//go:build !windows && !plan9 && !js
+// +build !windows,!plan9,!js
+
+package util
+
+import (
+	"os"
+	"strings"
+	"syscall"
+)
+
+// bootID returns the identifier of the current boot of the host, which only
+// Linux provides.
+func bootID() string {
+	b, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// WhiteoutPrefix marks the entries of a layer removing the file of the
+	// same name, without the prefix, from the layers below, as in the OCI
+	// image specification and overlayfs.
+	WhiteoutPrefix = ".wh."
+	// WhiteoutOpaque is the entry of a layer hiding all the entries the
+	// layers below have in the same directory.
+	WhiteoutOpaque = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+)
+
+// MergeDirEntries merges the entries of the same directory in several
+// layers, as read by ReadDir, into the listing of the directory of their
+// union. The layers are given from the lowest to the uppermost one:
+//
+//   - an entry of a layer replaces the entry of the same name of the layers
+//     below it, even if one of them is a directory and the other isn't;
+//   - a WhiteoutPrefix entry removes the entry of the same name from the
+//     layers below, and a WhiteoutOpaque one removes all of them; the
+//     entries of its own layer are left untouched, and the whiteouts don't
+//     show in the result.
+//
+// The result is sorted by name, as ReadDir does, whatever the order of the
+// entries of each layer. When a layer has several entries of the same name,
+// the last one is kept.
+func MergeDirEntries(layers ...[]os.FileInfo) []os.FileInfo {
+	entries := make(map[string]os.FileInfo)
+	for _, layer := range layers {
+		for _, fi := range layer {
+			name := fi.Name()
+			switch {
+			case name == WhiteoutOpaque:
+				entries = make(map[string]os.FileInfo)
+			case strings.HasPrefix(name, WhiteoutPrefix):
+				delete(entries, strings.TrimPrefix(name, WhiteoutPrefix))
+			}
+		}
+
+		for _, fi := range layer {
+			if !strings.HasPrefix(fi.Name(), WhiteoutPrefix) {
+				entries[fi.Name()] = fi
+			}
+		}
+	}
+
+	merged := make([]os.FileInfo, 0, len(entries))
+	for _, fi := range entries {
+		merged = append(merged, fi)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+
+	return merged
+}
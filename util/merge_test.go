@@ -0,0 +1,64 @@
+package util_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+type entry struct {
+	name string
+	dir  bool
+}
+
+func (e entry) Name() string       { return e.name }
+func (e entry) Size() int64        { return 0 }
+func (e entry) ModTime() time.Time { return time.Time{} }
+func (e entry) IsDir() bool        { return e.dir }
+func (e entry) Sys() interface{}   { return nil }
+
+func (e entry) Mode() os.FileMode {
+	if e.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func entries(names ...string) []os.FileInfo {
+	var fis []os.FileInfo
+	for _, name := range names {
+		fis = append(fis, entry{name: name})
+	}
+	return fis
+}
+
+func TestMergeDirEntries(t *testing.T) {
+	lower := []os.FileInfo{entry{name: "c"}, entry{name: "a"}, entry{name: "b", dir: true}, entry{name: "d"}}
+	upper := []os.FileInfo{entry{name: "b"}, entry{name: ".wh.c"}, entry{name: "e"}}
+
+	got := util.MergeDirEntries(lower, upper)
+	want := []os.FileInfo{entry{name: "a"}, entry{name: "b"}, entry{name: "d"}, entry{name: "e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeDirEntries_Opaque(t *testing.T) {
+	got := util.MergeDirEntries(
+		entries("a", "b"),
+		entries("c", util.WhiteoutOpaque, ".wh.c"),
+		entries("a"),
+	)
+	if want := entries("a", "c"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeDirEntries_Empty(t *testing.T) {
+	if got := util.MergeDirEntries(); len(got) != 0 {
+		t.Errorf("got %v, want no entries", got)
+	}
+}
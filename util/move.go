@@ -0,0 +1,154 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// Move renames oldpath to newpath, as Rename does, falling back to MoveAcross
+// when the rename fails because both paths are on different devices, such
+// as the bind mounts of a container, or isn't supported by fs.
+func Move(fs billy.Filesystem, oldpath, newpath string) error {
+	err := fs.Rename(oldpath, newpath)
+	if err == nil || !(errors.Is(err, syscall.EXDEV) || errors.Is(err, billy.ErrNotSupported)) {
+		return err
+	}
+
+	return MoveAcross(fs, newpath, fs, oldpath)
+}
+
+// MoveAcross moves the file, symbolic link or directory tree at srcPath of
+// src to dstPath of dst, by copying it and then removing it from src.
+//
+// The copy keeps the permissions, the modification times and the symbolic
+// links, where dst implements billy.Change and billy.Symlink. It is written
+// next to dstPath and synced to stable storage before being renamed into
+// place, so that dstPath never holds a partial copy. As with Rename, a file
+// at dstPath is replaced, while an existing directory makes MoveAcross fail
+// with an error wrapping os.ErrExist. A directory can't be renamed onto a
+// file, which is then removed first, dstPath missing meanwhile.
+func MoveAcross(dst billy.Filesystem, dstPath string, src billy.Filesystem, srcPath string) error {
+	fi, err := src.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	dfi, err := dst.Lstat(dstPath)
+	if err == nil && dfi.IsDir() {
+		return &os.LinkError{Op: "move", Old: srcPath, New: dstPath, Err: os.ErrExist}
+	}
+	replaceFile := err == nil && fi.IsDir()
+
+	dir := filepath.Dir(dstPath)
+	if err := dst.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp := dst.Join(dir, "."+filepath.Base(dstPath)+".move-"+nextSuffix())
+	if err := moveCopy(dst, tmp, src, srcPath, fi); err != nil {
+		_ = RemoveAll(dst, tmp)
+		return err
+	}
+
+	if replaceFile {
+		if err := dst.Remove(dstPath); err != nil && !os.IsNotExist(err) {
+			_ = RemoveAll(dst, tmp)
+			return err
+		}
+	}
+
+	if err := dst.Rename(tmp, dstPath); err != nil {
+		_ = RemoveAll(dst, tmp)
+		return err
+	}
+
+	if err := SyncDir(dst, dir); err != nil {
+		return err
+	}
+
+	return RemoveAll(src, srcPath)
+}
+
+// moveCopy copies the entry at srcPath, described by fi, to dstPath.
+func moveCopy(dst billy.Filesystem, dstPath string, src billy.Filesystem, srcPath string, fi os.FileInfo) error {
+	switch mode := fi.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		target, err := src.Readlink(srcPath)
+		if err != nil {
+			return err
+		}
+		return dst.Symlink(target, dstPath)
+	case mode.IsDir():
+		if err := dst.MkdirAll(dstPath, mode.Perm()); err != nil {
+			return err
+		}
+
+		fis, err := src.ReadDir(srcPath)
+		if err != nil {
+			return err
+		}
+
+		for _, cfi := range fis {
+			name := cfi.Name()
+			if err := moveCopy(dst, dst.Join(dstPath, name), src, src.Join(srcPath, name), cfi); err != nil {
+				return err
+			}
+		}
+
+		if err := SyncDir(dst, dstPath); err != nil {
+			return err
+		}
+	case mode.IsRegular():
+		if err := moveFile(dst, dstPath, src, srcPath, mode.Perm()); err != nil {
+			return err
+		}
+	default:
+		return &os.PathError{Op: "move", Path: srcPath, Err: billy.ErrNotSupported}
+	}
+
+	return copyAttrs(dst, dstPath, fi)
+}
+
+func moveFile(dst billy.Basic, dstPath string, src billy.Basic, srcPath string, perm os.FileMode) error {
+	sf, err := src.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	df, err := dst.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(df, sf)
+	if err == nil {
+		err = SyncFile(df)
+	}
+	if cerr := df.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// copyAttrs sets the permissions, not masked by the umask there, and the
+// modification time of fi to the file or directory at path, if fs supports
+// it. The access time is set to the modification time.
+func copyAttrs(fs billy.Basic, path string, fi os.FileInfo) error {
+	ch, ok := fs.(billy.Change)
+	if !ok {
+		return nil
+	}
+
+	if err := ch.Chmod(path, fi.Mode().Perm()); ignoreNotSupported(err) != nil {
+		return err
+	}
+
+	return ignoreNotSupported(ch.Chtimes(path, fi.ModTime(), fi.ModTime()))
+}
@@ -0,0 +1,98 @@
+package util_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// exdevFS fails the renames as if both paths were on different devices.
+type exdevFS struct {
+	billy.Filesystem
+	renames int
+}
+
+func (fs *exdevFS) Rename(from, to string) error {
+	fs.renames++
+	if fs.renames == 1 {
+		return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EXDEV}
+	}
+
+	return fs.Filesystem.Rename(from, to)
+}
+
+func TestMove(t *testing.T) {
+	fs := &exdevFS{Filesystem: memfs.New()}
+	if err := util.WriteFile(fs, "src/dir/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.Move(fs, "src", "dst/moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, fs, "dst/moved/dir/foo", "foo")
+	if _, err := fs.Stat("src"); !os.IsNotExist(err) {
+		t.Errorf("src not removed: %v", err)
+	}
+
+	fis, err := fs.ReadDir("dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 {
+		t.Errorf("temporary copy left behind: %d entries", len(fis))
+	}
+}
+
+func TestMoveAcross(t *testing.T) {
+	src, dst := memfs.New(), memfs.New()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := util.WriteFile(src, "tree/file", []byte("file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Symlink("file", "tree/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.(billy.Change).Chtimes("tree/file", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(dst, "moved", []byte("replaced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.MoveAcross(dst, "moved", src, "tree"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, dst, "moved/file", "file")
+	fi, err := dst.Stat("moved/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 || !fi.ModTime().Equal(mtime) {
+		t.Errorf("moved/file has mode %v and mtime %v", fi.Mode(), fi.ModTime())
+	}
+
+	if target, err := dst.Readlink("moved/link"); err != nil || target != "file" {
+		t.Errorf("Readlink(moved/link) = %q, %v", target, err)
+	}
+
+	if _, err := src.Stat("tree"); !os.IsNotExist(err) {
+		t.Errorf("tree not removed: %v", err)
+	}
+
+	if err := util.WriteFile(src, "other/file", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.MoveAcross(dst, "moved", src, "other"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("MoveAcross() over a directory = %v, want %v", err, os.ErrExist)
+	}
+}
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// Action is the kind of a PlannedOp.
+type Action int
+
+const (
+	// ActionMkdir creates the directory at Path.
+	ActionMkdir Action = iota
+	// ActionWrite creates the regular file at Path, or replaces its
+	// content.
+	ActionWrite
+	// ActionSymlink creates a symbolic link at Path, pointing to Target.
+	ActionSymlink
+	// ActionRemove removes the entry at Path, along with its content if it
+	// is a directory.
+	ActionRemove
+)
+
+var actionNames = [...]string{"mkdir", "write", "symlink", "remove"}
+
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+
+	return actionNames[a]
+}
+
+// PlannedOp is a change a mutating utility would make to a filesystem,
+// returned by its dry runs instead of being made.
+type PlannedOp struct {
+	Action Action
+	// Path is the path of the entry changed, in the filesystem changed.
+	Path string
+	// Target is the target of the link created by ActionSymlink.
+	Target string
+}
+
+func (op PlannedOp) String() string {
+	if op.Action == ActionSymlink {
+		return op.Action.String() + " " + op.Path + " -> " + op.Target
+	}
+
+	return op.Action.String() + " " + op.Path
+}
+
+// RemoveAllOptions configures RemoveAllWithOptions.
+type RemoveAllOptions struct {
+	// DryRun lists the entries which would be removed, leaving them in
+	// place.
+	DryRun bool
+}
+
+// RemoveAllWithOptions is like RemoveAll, with the options given by opts.
+// With DryRun, the entries which would be removed are returned, each
+// directory after its entries, and nothing is removed; the result is nil
+// otherwise.
+func RemoveAllWithOptions(fs billy.Basic, path string, opts RemoveAllOptions) ([]PlannedOp, error) {
+	if !opts.DryRun {
+		return nil, RemoveAll(fs, path)
+	}
+
+	var ops []PlannedOp
+	err := planRemoveAll(fs, path, &ops)
+	return ops, err
+}
+
+func planRemoveAll(fs billy.Basic, path string, ops *[]PlannedOp) error {
+	fi, err := lstat(fs, path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		dirfs, ok := fs.(billy.Dir)
+		if !ok {
+			return billy.ErrNotSupported
+		}
+
+		infos, err := dirfs.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		sort.Sort(byName(infos))
+		for _, fi := range infos {
+			if err := planRemoveAll(fs, fs.Join(path, fi.Name()), ops); err != nil {
+				return err
+			}
+		}
+	}
+
+	*ops = append(*ops, PlannedOp{Action: ActionRemove, Path: path})
+	return nil
+}
+
+// lstat is Lstat if fs supports symbolic links, and Stat otherwise.
+func lstat(fs billy.Basic, path string) (os.FileInfo, error) {
+	if s, ok := fs.(billy.Symlink); ok {
+		return s.Lstat(path)
+	}
+
+	return fs.Stat(path)
+}
@@ -0,0 +1,51 @@
+package util_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestRemoveAllDryRun(t *testing.T) {
+	fs := memfs.New()
+	util.WriteFile(fs, "dir/a", []byte("a"), 0o644)
+	util.WriteFile(fs, "dir/sub/b", []byte("b"), 0o644)
+	fs.Symlink("sub", "dir/link")
+
+	ops, err := util.RemoveAllWithOptions(fs, "dir", util.RemoveAllOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var planned []string
+	for _, op := range ops {
+		planned = append(planned, filepath.ToSlash(op.String()))
+	}
+	expected := []string{
+		"remove dir/a",
+		"remove dir/link",
+		"remove dir/sub/b",
+		"remove dir/sub",
+		"remove dir",
+	}
+	if !reflect.DeepEqual(planned, expected) {
+		t.Errorf("expected the changes %q, got %q", expected, planned)
+	}
+	assertContent(t, fs, "dir/sub/b", "b")
+
+	ops, err = util.RemoveAllWithOptions(fs, "missing", util.RemoveAllOptions{DryRun: true})
+	if err != nil || len(ops) != 0 {
+		t.Errorf("expected nothing to remove, got %v, %v", ops, err)
+	}
+
+	ops, err = util.RemoveAllWithOptions(fs, "dir", util.RemoveAllOptions{})
+	if err != nil || ops != nil {
+		t.Fatalf("unexpected result %v, %v", ops, err)
+	}
+	if _, err := fs.Stat("dir"); err == nil {
+		t.Error("expected dir to be removed")
+	}
+}
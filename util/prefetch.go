@@ -0,0 +1,22 @@
+package util
+
+import "github.com/go-git/go-billy/v5"
+
+// Prefetch hints fs, or the filesystem it wraps, that the length bytes of the
+// file at path starting at off are about to be read, if it implements
+// billy.Prefetcher. A length of zero means up to the end of the file. The
+// hint is dropped otherwise, as it is by the filesystems unable to act on
+// it.
+func Prefetch(fs billy.Basic, path string, off, length int64) error {
+	for {
+		if p, ok := fs.(billy.Prefetcher); ok {
+			return ignoreNotSupported(p.Prefetch(path, off, length))
+		}
+
+		next, npath := getUnderlyingAndPath(fs, path)
+		if next == fs {
+			return nil
+		}
+		fs, path = next, npath
+	}
+}
@@ -0,0 +1,38 @@
+package util_test
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestPrefetch(t *testing.T) {
+	// Backends ignoring the hint are fine.
+	mem := memfs.New()
+	if err := util.WriteFile(mem, "file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.Prefetch(mem, "file", 0, 0); err != nil {
+		t.Errorf("memfs: %v", err)
+	}
+
+	fs := chroot.New(osfs.New(t.TempDir()), "/dir")
+	if err := util.WriteFile(fs, "file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.Prefetch(fs, "file", 0, 0); err != nil {
+		t.Errorf("osfs: %v", err)
+	}
+	// Only Linux acts on the hint, reporting the missing files.
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if err := util.Prefetch(fs, "missing", 0, 0); !os.IsNotExist(err) {
+		t.Errorf("osfs: got %v, want not exist", err)
+	}
+}
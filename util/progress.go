@@ -0,0 +1,75 @@
+package util
+
+import "io"
+
+// Progress describes how far a long-running operation over a tree, such as
+// Sync, HashTreeWithOptions or WriteTar, has gone.
+type Progress struct {
+	// Bytes is the number of bytes of file content processed so far.
+	Bytes int64
+	// Files is the number of entries done so far, directories and symbolic
+	// links included.
+	Files int
+	// Path is the slash separated path, relative to the root of the tree,
+	// of the entry being processed or last done.
+	Path string
+}
+
+// ProgressFunc is called as an operation progresses: once every entry is
+// done, a directory before its entries, and as the content of the files is
+// processed, a chunk at a time. An error returned aborts the operation,
+// which returns it, so that a deadline or a cancellation can be enforced.
+type ProgressFunc func(Progress) error
+
+// progress tracks the Progress of an operation, a nil progress tracking
+// nothing.
+type progress struct {
+	fn ProgressFunc
+	p  Progress
+}
+
+func newProgress(fn ProgressFunc) *progress {
+	if fn == nil {
+		return nil
+	}
+
+	return &progress{fn: fn}
+}
+
+// done records the entry at path as done.
+func (p *progress) done(path string) error {
+	if p == nil {
+		return nil
+	}
+
+	p.p.Files++
+	p.p.Path = path
+	return p.fn(p.p)
+}
+
+// writer returns w, the bytes written to it counting as the content of the
+// entry at path being processed.
+func (p *progress) writer(w io.Writer, path string) io.Writer {
+	if p == nil {
+		return w
+	}
+
+	return &progressWriter{w: w, p: p, path: path}
+}
+
+type progressWriter struct {
+	w    io.Writer
+	p    *progress
+	path string
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.p.p.Bytes += int64(n)
+	w.p.p.Path = w.path
+	if err == nil {
+		err = w.p.fn(w.p.p)
+	}
+
+	return n, err
+}
@@ -0,0 +1,134 @@
+package util_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func newProgressTree(t *testing.T) billy.Filesystem {
+	fs := memfs.New()
+	for name, content := range map[string]string{
+		"src/a":   "foo",
+		"src/b/c": "barbaz",
+	} {
+		if err := util.WriteFile(fs, name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Symlink("a", "src/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+// recordProgress returns a ProgressFunc recording the entries done, and the
+// last Progress reported.
+func recordProgress(done *[]string, last *util.Progress) util.ProgressFunc {
+	return func(p util.Progress) error {
+		if p.Files != last.Files {
+			*done = append(*done, p.Path)
+		}
+		*last = p
+		return nil
+	}
+}
+
+func TestSyncProgress(t *testing.T) {
+	fs := newProgressTree(t)
+
+	var done []string
+	var last util.Progress
+	_, err := util.Sync(fs, "dst", fs, "src", &util.SyncOptions{Progress: recordProgress(&done, &last)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{".", "a", "b", "b/c", "link"}; !reflect.DeepEqual(done, expected) {
+		t.Errorf("expected the entries %q, got %q", expected, done)
+	}
+	if last.Bytes != 9 || last.Files != 5 {
+		t.Errorf("expected 9 bytes and 5 files, got %+v", last)
+	}
+}
+
+func TestHashTreeProgress(t *testing.T) {
+	fs := newProgressTree(t)
+
+	var done []string
+	var last util.Progress
+	opts := util.HashOptions{Progress: recordProgress(&done, &last)}
+	sum, err := util.HashTreeWithOptions(fs, "src", sha256.New, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{".", "a", "b", "b/c", "link"}; !reflect.DeepEqual(done, expected) {
+		t.Errorf("expected the entries %q, got %q", expected, done)
+	}
+	if last.Bytes != 9 {
+		t.Errorf("expected 9 bytes, got %+v", last)
+	}
+
+	plain, err := util.HashTree(fs, "src", sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sum, plain) {
+		t.Error("the progress changed the digest")
+	}
+}
+
+func TestWriteTarProgress(t *testing.T) {
+	fs := newProgressTree(t)
+
+	var done []string
+	var last util.Progress
+	if err := util.WriteTar(io.Discard, fs, "src", util.WithTarProgress(recordProgress(&done, &last))); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"a", "b", "b/c", "link"}; !reflect.DeepEqual(done, expected) {
+		t.Errorf("expected the entries %q, got %q", expected, done)
+	}
+	if last.Bytes != 9 || last.Files != 4 {
+		t.Errorf("expected 9 bytes and 4 files, got %+v", last)
+	}
+}
+
+func TestProgressAbort(t *testing.T) {
+	fs := newProgressTree(t)
+
+	errBudget := errors.New("budget exceeded")
+	abort := func(p util.Progress) error {
+		if p.Bytes > 0 {
+			return errBudget
+		}
+		return nil
+	}
+
+	_, err := util.Sync(fs, "dst", fs, "src", &util.SyncOptions{Progress: abort})
+	if err != errBudget {
+		t.Errorf("Sync: expected the error of the callback, got %v", err)
+	}
+	if _, err := fs.Stat("dst/b"); err == nil {
+		t.Error("Sync went on after the callback failed")
+	}
+
+	_, err = util.HashTreeWithOptions(fs, "src", sha256.New, util.HashOptions{Progress: abort})
+	if err != errBudget {
+		t.Errorf("HashTree: expected the error of the callback, got %v", err)
+	}
+
+	err = util.WriteTar(io.Discard, fs, "src", util.WithTarProgress(abort))
+	if err != errBudget {
+		t.Errorf("WriteTar: expected the error of the callback, got %v", err)
+	}
+}
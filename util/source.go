@@ -0,0 +1,115 @@
+package util
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Source provides the time and the randomness used when creating files: the
+// names of temporary files and directories, and the modification times set
+// by the in-memory filesystem. Replacing it with a deterministic Source, see
+// NewFixedSource, makes these operations reproducible, as required by
+// hermetic build systems.
+type Source interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Uint32 returns a pseudo-random number.
+	Uint32() uint32
+}
+
+var (
+	sourceMu sync.RWMutex
+	source   Source = &defaultSource{}
+)
+
+// SetSource replaces the Source used by billy, process wide. A nil Source
+// restores the default one, based on the system clock.
+func SetSource(s Source) {
+	if s == nil {
+		s = &defaultSource{}
+	}
+
+	sourceMu.Lock()
+	source = s
+	sourceMu.Unlock()
+}
+
+func currentSource() Source {
+	sourceMu.RLock()
+	defer sourceMu.RUnlock()
+
+	return source
+}
+
+// Now returns the current time according to the Source set with SetSource.
+func Now() time.Time {
+	return currentSource().Now()
+}
+
+// defaultSource generates random numbers from a linear congruential
+// generator seeded with the clock and the pid.
+type defaultSource struct {
+	m    sync.Mutex
+	rand uint32
+}
+
+func (s *defaultSource) Now() time.Time {
+	return time.Now()
+}
+
+func (s *defaultSource) Uint32() uint32 {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	r := s.rand
+	if r == 0 {
+		r = seed()
+	}
+	r = r*1664525 + 1013904223 // constants from Numerical Recipes
+	s.rand = r
+	return r
+}
+
+func (s *defaultSource) reseed() {
+	s.m.Lock()
+	s.rand = seed()
+	s.m.Unlock()
+}
+
+func seed() uint32 {
+	return uint32(time.Now().UnixNano() + int64(os.Getpid()))
+}
+
+// reseed reseeds the default Source after too many name conflicts, which
+// likely means another process is using the same sequence. Custom Sources
+// are left alone, to keep them deterministic.
+func reseed() {
+	if s, ok := currentSource().(*defaultSource); ok {
+		s.reseed()
+	}
+}
+
+// NewFixedSource returns a deterministic Source: Now always returns now and
+// Uint32 returns a sequence only depending on seed.
+func NewFixedSource(seed uint32, now time.Time) Source {
+	return &fixedSource{rand: seed, now: now}
+}
+
+type fixedSource struct {
+	m    sync.Mutex
+	rand uint32
+	now  time.Time
+}
+
+func (s *fixedSource) Now() time.Time {
+	return s.now
+}
+
+func (s *fixedSource) Uint32() uint32 {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.rand = s.rand*1664525 + 1013904223
+	return s.rand
+}
@@ -0,0 +1,50 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestFixedSource(t *testing.T) {
+	defer util.SetSource(nil)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	names := func() []string {
+		util.SetSource(util.NewFixedSource(42, now))
+
+		fs := memfs.New()
+		var names []string
+		for i := 0; i < 3; i++ {
+			f, err := util.TempFile(fs, "tmp", "foo")
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+			names = append(names, f.Name())
+
+			fi, err := fs.Stat(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.ModTime().Equal(now) {
+				t.Errorf("expected mtime %s, got %s", now, fi.ModTime())
+			}
+		}
+
+		dir, err := util.TempDir(fs, "tmp", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(names, dir)
+	}
+
+	first, second := names(), names()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("expected reproducible names, got %q and %q", first[i], second[i])
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StatCache is a VFS memoizing the results of the Lstat and Readlink calls
+// made to another one, failures included, so that SecureJoinVFS resolving
+// many paths below the same directories queries each of them once. Stat and
+// ReadDir are forwarded uncached, their results depending on more than the
+// entry of their path. It is safe for concurrent use.
+//
+// The entries never expire: the paths changed on the filesystem must be
+// dropped with Invalidate, or all of them with Reset, before resolving the
+// paths going through them again.
+type StatCache struct {
+	vfs VFS
+
+	m        sync.RWMutex
+	lstat    map[string]lstatResult
+	readlink map[string]readlinkResult
+}
+
+type lstatResult struct {
+	fi  os.FileInfo
+	err error
+}
+
+type readlinkResult struct {
+	dest string
+	err  error
+}
+
+// NewStatCache returns a StatCache querying vfs, or the os.* family of
+// functions if it is nil.
+func NewStatCache(vfs VFS) *StatCache {
+	if vfs == nil {
+		vfs = osVFS{}
+	}
+
+	c := &StatCache{vfs: vfs}
+	c.Reset()
+	return c
+}
+
+// Stat forwards the call to the underlying VFS.
+func (c *StatCache) Stat(name string) (os.FileInfo, error) {
+	return c.vfs.Stat(name)
+}
+
+// ReadDir forwards the call to the underlying VFS.
+func (c *StatCache) ReadDir(name string) ([]os.FileInfo, error) {
+	return c.vfs.ReadDir(name)
+}
+
+// Lstat returns the FileInfo of name from the cache, querying the
+// underlying VFS on a miss.
+func (c *StatCache) Lstat(name string) (os.FileInfo, error) {
+	c.m.RLock()
+	r, ok := c.lstat[name]
+	c.m.RUnlock()
+	if ok {
+		return r.fi, r.err
+	}
+
+	fi, err := c.vfs.Lstat(name)
+	c.m.Lock()
+	c.lstat[name] = lstatResult{fi: fi, err: err}
+	c.m.Unlock()
+
+	return fi, err
+}
+
+// Readlink returns the destination of the link name from the cache,
+// querying the underlying VFS on a miss.
+func (c *StatCache) Readlink(name string) (string, error) {
+	c.m.RLock()
+	r, ok := c.readlink[name]
+	c.m.RUnlock()
+	if ok {
+		return r.dest, r.err
+	}
+
+	dest, err := c.vfs.Readlink(name)
+	c.m.Lock()
+	c.readlink[name] = readlinkResult{dest: dest, err: err}
+	c.m.Unlock()
+
+	return dest, err
+}
+
+// Invalidate drops the entries of name and of the paths below it.
+func (c *StatCache) Invalidate(name string) {
+	name = filepath.Clean(name)
+	prefix := name + string(filepath.Separator)
+	if strings.HasSuffix(name, string(filepath.Separator)) {
+		// The root of the filesystem, or of a volume.
+		prefix = name
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for p := range c.lstat {
+		if p == name || strings.HasPrefix(p, prefix) {
+			delete(c.lstat, p)
+		}
+	}
+	for p := range c.readlink {
+		if p == name || strings.HasPrefix(p, prefix) {
+			delete(c.readlink, p)
+		}
+	}
+}
+
+// Reset drops all the entries.
+func (c *StatCache) Reset() {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.lstat = make(map[string]lstatResult)
+	c.readlink = make(map[string]readlinkResult)
+}
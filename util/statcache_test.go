@@ -0,0 +1,94 @@
+package util_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// countingVFS is a VFS over a billy filesystem counting the calls made.
+type countingVFS struct {
+	fs    billy.Filesystem
+	calls int
+}
+
+func (v *countingVFS) Stat(name string) (os.FileInfo, error) {
+	v.calls++
+	return v.fs.Stat(name)
+}
+
+func (v *countingVFS) ReadDir(name string) ([]os.FileInfo, error) {
+	v.calls++
+	return v.fs.ReadDir(name)
+}
+
+func (v *countingVFS) Lstat(name string) (os.FileInfo, error) {
+	v.calls++
+	return v.fs.Lstat(name)
+}
+
+func (v *countingVFS) Readlink(name string) (string, error) {
+	v.calls++
+	return v.fs.Readlink(name)
+}
+
+func TestStatCache(t *testing.T) {
+	fs := memfs.New()
+	if err := fs.MkdirAll("/root/a", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.MkdirAll("/root/b", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("a", "/root/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	vfs := &countingVFS{fs: fs}
+	cache := util.NewStatCache(vfs)
+	root := filepath.FromSlash("/root")
+
+	join := func(want string) {
+		t.Helper()
+		got, err := util.SecureJoinVFS(root, filepath.Join("link", "missing", "file"), cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want = filepath.Join(root, want, "missing", "file"); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+
+	join("a")
+	calls := vfs.calls
+	if calls == 0 {
+		t.Fatal("the underlying VFS wasn't queried")
+	}
+
+	join("a")
+	if vfs.calls != calls {
+		t.Errorf("expected %d calls, the second resolution being cached, got %d", calls, vfs.calls)
+	}
+
+	if err := fs.Remove("/root/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("b", "/root/link"); err != nil {
+		t.Fatal(err)
+	}
+	join("a")
+
+	cache.Invalidate(filepath.Join(root, "link"))
+	join("b")
+
+	calls = vfs.calls
+	cache.Reset()
+	join("b")
+	if vfs.calls == calls {
+		t.Error("expected the underlying VFS to be queried after Reset")
+	}
+}
@@ -0,0 +1,135 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+type exchanger interface {
+	Exchange(a, b string) error
+}
+
+// SwapDirs promotes the tree at next to the path current, so that readers of
+// current either see the old or the new tree but never a missing or partial
+// one. The following strategies are tried in order:
+//
+//   - If the underlying filesystem supports exchanging two entries
+//     atomically (osfs on Linux, through renameat2(2) with
+//     RENAME_EXCHANGE), both paths are swapped: current holds the new tree
+//     and next the previous one.
+//   - If current is a symlink, a new symlink pointing to next is created
+//     beside it and renamed over current. The previous target is left in
+//     place and next is not modified.
+//   - Otherwise current is renamed aside, next renamed to current and the
+//     previous tree renamed to next. This is not atomic, but if promoting
+//     next fails the previous tree is restored.
+//
+// If current does not exist, next is simply renamed to current.
+func SwapDirs(fs billy.Filesystem, current, next string) error {
+	if _, err := fs.Stat(next); err != nil {
+		return err
+	}
+
+	fi, err := fs.Lstat(current)
+	if os.IsNotExist(err) {
+		return fs.Rename(next, current)
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return flipSymlink(fs, current, next)
+	}
+
+	err = exchange(fs, current, next)
+	if err == nil || !isExchangeUnsupported(err) {
+		return err
+	}
+
+	return swapByRename(fs, current, next)
+}
+
+func exchange(fs billy.Basic, a, b string) error {
+	ua, pa := getUnderlyingAndPath(fs, a)
+	_, pb := getUnderlyingAndPath(fs, b)
+	for {
+		if e, ok := ua.(exchanger); ok {
+			return e.Exchange(pa, pb)
+		}
+
+		next, na := getUnderlyingAndPath(ua, pa)
+		if next == ua {
+			return billy.ErrNotSupported
+		}
+		_, pb = getUnderlyingAndPath(ua, pb)
+		ua, pa = next, na
+	}
+}
+
+func isExchangeUnsupported(err error) bool {
+	return errors.Is(err, billy.ErrNotSupported) ||
+		errors.Is(err, syscall.EINVAL) ||
+		errors.Is(err, syscall.ENOSYS)
+}
+
+func flipSymlink(fs billy.Filesystem, current, next string) error {
+	target := next
+	if !filepath.IsAbs(next) {
+		rel, err := filepath.Rel(filepath.Dir(current), next)
+		if err != nil {
+			return err
+		}
+		target = rel
+	}
+
+	tmp := fs.Join(filepath.Dir(current), "."+filepath.Base(current)+".swap-"+nextSuffix())
+	if err := fs.Symlink(target, tmp); err != nil {
+		return err
+	}
+
+	if err := fs.Rename(tmp, current); err != nil {
+		_ = fs.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+func swapByRename(fs billy.Filesystem, current, next string) error {
+	old := fs.Join(filepath.Dir(current), "."+filepath.Base(current)+".old-"+nextSuffix())
+	if err := fs.Rename(current, old); err != nil {
+		return err
+	}
+
+	if err := fs.Rename(next, current); err != nil {
+		if rerr := fs.Rename(old, current); rerr != nil {
+			return &SwapError{Err: err, RollbackErr: rerr, Previous: old}
+		}
+		return err
+	}
+
+	return fs.Rename(old, next)
+}
+
+// SwapError is returned by SwapDirs when promoting the new tree failed and
+// the previous tree could not be restored either. Previous holds the path
+// where the previous tree was left.
+type SwapError struct {
+	Err         error
+	RollbackErr error
+	Previous    string
+}
+
+func (e *SwapError) Error() string {
+	return "swap failed: " + e.Err.Error() + ", rollback failed: " + e.RollbackErr.Error() +
+		", previous tree left at " + e.Previous
+}
+
+func (e *SwapError) Unwrap() error {
+	return e.Err
+}
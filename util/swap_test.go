@@ -0,0 +1,100 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func assertContent(t *testing.T, fs billy.Basic, name, expected string) {
+	t.Helper()
+
+	content, err := util.ReadFile(fs, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Errorf("%s: expected %q, got %q", name, expected, content)
+	}
+}
+
+func testSwapDirs(t *testing.T, fs billy.Filesystem) {
+	if err := util.WriteFile(fs, "live/file", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "staging/file", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.SwapDirs(fs, "live", "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, fs, "live/file", "new")
+	assertContent(t, fs, "staging/file", "old")
+
+	infos, err := fs.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Errorf("expected no leftovers, got %d entries", len(infos))
+	}
+}
+
+func TestSwapDirsMemory(t *testing.T) {
+	testSwapDirs(t, memfs.New())
+}
+
+func TestSwapDirsOS(t *testing.T) {
+	testSwapDirs(t, osfs.New(t.TempDir()))
+}
+
+func TestSwapDirsMissingCurrent(t *testing.T) {
+	fs := memfs.New()
+	util.WriteFile(fs, "staging/file", []byte("new"), 0644)
+
+	if err := util.SwapDirs(fs, "live", "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContent(t, fs, "live/file", "new")
+}
+
+func TestSwapDirsSymlink(t *testing.T) {
+	fs := osfs.New(t.TempDir())
+	util.WriteFile(fs, "rev1/file", []byte("old"), 0644)
+	util.WriteFile(fs, "rev2/file", []byte("new"), 0644)
+	if err := fs.Symlink("rev1", "live"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := util.SwapDirs(fs, "live", "rev2"); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := fs.Readlink("live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "rev2" {
+		t.Errorf("expected live to point to rev2, got %q", target)
+	}
+
+	assertContent(t, fs, "live/file", "new")
+	assertContent(t, fs, "rev1/file", "old")
+}
+
+func TestSwapDirsMissingNext(t *testing.T) {
+	fs := memfs.New()
+	util.WriteFile(fs, "live/file", []byte("old"), 0644)
+
+	if err := util.SwapDirs(fs, "live", "staging"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	assertContent(t, fs, "live/file", "old")
+}
@@ -0,0 +1,281 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// EvalSymlinks returns the path name after the evaluation of any symbolic
+// links, as filepath.EvalSymlinks does, using the Lstat and Readlink of fs.
+// Absolute paths and link targets are relative to the root of fs, so that
+// the links of a chroot are resolved within it. The result is cleaned, and
+// is relative if path and the links followed are.
+//
+// An error is returned if an element of path doesn't exist, if a file is
+// traversed as a directory, or if too many links are followed.
+func EvalSymlinks(fs billy.Symlink, path string) (string, error) {
+	original := path
+
+	var dest string
+	if len(path) > 0 && os.IsPathSeparator(path[0]) {
+		dest = path[:1]
+	}
+
+	links := 0
+	for start, end := len(dest), len(dest); start < len(path); start = end {
+		for start < len(path) && os.IsPathSeparator(path[start]) {
+			start++
+		}
+		end = start
+		for end < len(path) && !os.IsPathSeparator(path[end]) {
+			end++
+		}
+
+		switch path[start:end] {
+		case "", ".":
+			continue
+		case "..":
+			dest = evalParent(dest)
+			continue
+		}
+
+		if dest != "" && !os.IsPathSeparator(dest[len(dest)-1]) {
+			dest += string(filepath.Separator)
+		}
+		dest += path[start:end]
+
+		fi, err := fs.Lstat(dest)
+		if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			if !fi.IsDir() && end < len(path) {
+				return "", &os.PathError{Op: "lstat", Path: dest, Err: syscall.ENOTDIR}
+			}
+			continue
+		}
+
+		links++
+		if links > DefaultMaxLinks {
+			return "", &os.PathError{Op: "evalsymlinks", Path: original, Err: syscall.ELOOP}
+		}
+
+		link, err := fs.Readlink(dest)
+		if err != nil {
+			return "", err
+		}
+
+		// The rest of path is now resolved from the target of the link.
+		path = link + path[end:]
+		if len(link) > 0 && os.IsPathSeparator(link[0]) {
+			dest = link[:1]
+			end = 1
+		} else {
+			dest = evalParent(dest)
+			end = 0
+		}
+	}
+
+	return filepath.Clean(dest), nil
+}
+
+// evalParent returns the parent of the partially resolved path dest, which
+// keeps the ".." elements that can't be removed from a relative path.
+func evalParent(dest string) string {
+	i := len(dest) - 1
+	for i >= 0 && !os.IsPathSeparator(dest[i]) {
+		i--
+	}
+
+	switch {
+	case dest == "" || dest[i+1:] == "..":
+		if dest != "" {
+			dest += string(filepath.Separator)
+		}
+		return dest + ".."
+	case i < 0:
+		return ""
+	case i == 0:
+		// The root has no parent.
+		return dest[:1]
+	}
+
+	return dest[:i]
+}
+
+var (
+	// ErrSymlinkEscapes is reported by AuditSymlinks for the links resolving
+	// outside of the audited tree.
+	ErrSymlinkEscapes = errors.New("target escapes the root")
+	// ErrSymlinkAbsolute is reported by AuditSymlinks for the links whose
+	// target, or the target of a link they lead to, is absolute.
+	ErrSymlinkAbsolute = errors.New("target is absolute")
+	// ErrSymlinkTooDeep is reported by AuditSymlinks for the links leading
+	// to more links than AuditOptions.MaxLinks.
+	ErrSymlinkTooDeep = errors.New("too many levels of symbolic links")
+)
+
+// DefaultAuditMaxLinks is the default of AuditOptions.MaxLinks, the limit
+// of Linux.
+const DefaultAuditMaxLinks = 40
+
+// AuditOptions configures AuditSymlinksWithOptions.
+type AuditOptions struct {
+	// MaxLinks is the number of links a link may lead to, itself included,
+	// before being reported with ErrSymlinkTooDeep. DefaultAuditMaxLinks is
+	// used if it is zero or negative.
+	MaxLinks int
+}
+
+// SymlinkError describes a symbolic link rejected by AuditSymlinks.
+type SymlinkError struct {
+	// Path is the path of the link.
+	Path string
+	// Target is the target of the link, as read.
+	Target string
+	// Err is ErrSymlinkEscapes, ErrSymlinkAbsolute or ErrSymlinkTooDeep.
+	Err error
+}
+
+func (e *SymlinkError) Error() string {
+	return fmt.Sprintf("symlink %s -> %s: %v", e.Path, e.Target, e.Err)
+}
+
+func (e *SymlinkError) Unwrap() error { return e.Err }
+
+// AuditSymlinks is AuditSymlinksWithOptions with the default options.
+func AuditSymlinks(fs billy.Filesystem, root string) ([]*SymlinkError, error) {
+	return AuditSymlinksWithOptions(fs, root, AuditOptions{})
+}
+
+// AuditSymlinksWithOptions walks the tree at root, such as an extracted
+// archive, and returns the symbolic links which would lead outside of it
+// once resolved: the ones escaping it through ".." elements, the ones going
+// through an absolute target, and the ones leading to too many links. The
+// links are resolved as the kernel would, the other links of the tree
+// included; the links dangling within the tree are accepted.
+//
+// The error returned is the first one met walking the tree, or reading the
+// links; the problems found are only reported through the SymlinkErrors.
+func AuditSymlinksWithOptions(fs billy.Filesystem, root string, opts AuditOptions) ([]*SymlinkError, error) {
+	if opts.MaxLinks <= 0 {
+		opts.MaxLinks = DefaultAuditMaxLinks
+	}
+
+	root = filepath.Clean(root)
+	var found []*SymlinkError
+	err := WalkDir(fs, root, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil || d.Type()&os.ModeSymlink == 0 {
+			return err
+		}
+
+		target, err := fs.Readlink(path)
+		if err != nil {
+			return err
+		}
+
+		reason, err := auditLink(fs, root, path, opts.MaxLinks)
+		if err != nil {
+			return err
+		}
+		if reason != nil {
+			found = append(found, &SymlinkError{Path: path, Target: target, Err: reason})
+		}
+		return nil
+	})
+
+	return found, err
+}
+
+// auditLink resolves the link at path, within the tree at root, and returns
+// the reason to reject it, if any.
+func auditLink(fs billy.Filesystem, root, path string, maxLinks int) (error, error) {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	// resolved holds the elements of the directory reached, below root, and
+	// pending the ones left to walk, in order.
+	var resolved []string
+	if rel != "." {
+		resolved = splitPath(rel)
+	}
+	pending := []string{filepath.Base(path)}
+
+	links := 0
+	missing := false
+	for len(pending) > 0 {
+		elem := pending[0]
+		pending = pending[1:]
+
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return ErrSymlinkEscapes, nil
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+
+		resolved = append(resolved, elem)
+		if missing {
+			// Below a missing element, the path can only be taken
+			// lexically.
+			continue
+		}
+
+		name := filepath.Join(append([]string{root}, resolved...)...)
+		fi, err := fs.Lstat(name)
+		if os.IsNotExist(err) {
+			missing = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			if !fi.IsDir() && len(pending) > 0 {
+				// A file walked through as a directory, which fails to
+				// resolve.
+				return nil, nil
+			}
+			continue
+		}
+
+		links++
+		if links > maxLinks {
+			return ErrSymlinkTooDeep, nil
+		}
+
+		target, err := fs.Readlink(name)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.IsAbs(target) || len(target) > 0 && os.IsPathSeparator(target[0]) {
+			return ErrSymlinkAbsolute, nil
+		}
+
+		resolved = resolved[:len(resolved)-1]
+		pending = append(splitPath(target), pending...)
+	}
+
+	return nil, nil
+}
+
+// splitPath returns the non-empty elements of path.
+func splitPath(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || os.IsPathSeparator(uint8(r))
+	})
+}
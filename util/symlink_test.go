@@ -0,0 +1,204 @@
+package util_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestEvalSymlinks(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "real/file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links := [][2]string{
+		{"real", "rel"},
+		{"/real", "abs"},
+		{"../real", "dir/up"},
+		{"real/file", "file"},
+		{"rel/file", "chain"},
+		{"loop2", "loop1"},
+		{"loop1", "loop2"},
+	}
+	for _, l := range links {
+		if err := fs.MkdirAll(filepath.Dir(l[1]), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Symlink(l[0], l[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"real/file", "real/file"},
+		{"rel/file", "real/file"},
+		{"abs/file", "/real/file"},
+		{"/abs/file", "/real/file"},
+		{"dir/up/file", "real/file"},
+		{"file", "real/file"},
+		{"chain", "real/file"},
+		{"real/../rel", "real"},
+		{"./rel/.", "real"},
+		{"/..", "/"},
+		{"", "."},
+	} {
+		got, err := util.EvalSymlinks(fs, filepath.FromSlash(tc.path))
+		if err != nil {
+			t.Errorf("%q: %v", tc.path, err)
+			continue
+		}
+		if want := filepath.FromSlash(tc.want); got != want {
+			t.Errorf("%q: got %q, want %q", tc.path, got, want)
+		}
+	}
+
+	if _, err := util.EvalSymlinks(fs, "rel/missing"); !os.IsNotExist(err) {
+		t.Errorf("missing: got %v, want not exist", err)
+	}
+	if _, err := util.EvalSymlinks(fs, filepath.FromSlash("file/foo")); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("file/foo: got %v, want ENOTDIR", err)
+	}
+	if _, err := util.EvalSymlinks(fs, "loop1"); !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("loop1: got %v, want ELOOP", err)
+	}
+}
+
+func TestEvalSymlinks_Chroot(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "sub/target", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := fs.Chroot("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.Symlink("/target", "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := util.EvalSymlinks(ch, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(filepath.Separator) + "target"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAuditSymlinks(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "root/dir/file", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "outside", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links := [][2]string{
+		{"dir/file", "safe"},
+		{"../safe", "dir/up"},
+		{"missing/foo", "dangling"},
+		{"../outside", "escape"},
+		{"../../root/dir", "dir/roundtrip"},
+		{"/etc/passwd", "abs"},
+		{"abs", "via-abs"},
+		{"..", "parent"},
+		{"parent/outside", "via-parent"},
+		{"loop2", "loop1"},
+		{"loop1", "loop2"},
+		{"dir/file/foo", "through-file"},
+	}
+	for _, l := range links {
+		if err := fs.Symlink(l[0], filepath.Join("root", l[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := util.AuditSymlinksWithOptions(fs, "root", util.AuditOptions{MaxLinks: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]error{
+		"escape":        util.ErrSymlinkEscapes,
+		"dir/roundtrip": util.ErrSymlinkEscapes,
+		"abs":           util.ErrSymlinkAbsolute,
+		"via-abs":       util.ErrSymlinkAbsolute,
+		"parent":        util.ErrSymlinkEscapes,
+		"via-parent":    util.ErrSymlinkEscapes,
+		"loop1":         util.ErrSymlinkTooDeep,
+		"loop2":         util.ErrSymlinkTooDeep,
+	}
+	got := make(map[string]error)
+	for _, e := range found {
+		rel, err := filepath.Rel("root", e.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[filepath.ToSlash(rel)] = e.Err
+
+		if target, _ := fs.Readlink(e.Path); e.Target != target {
+			t.Errorf("%s: got target %q, want %q", e.Path, e.Target, target)
+		}
+		if !errors.Is(e, e.Err) {
+			t.Errorf("%s: %v doesn't wrap %v", e.Path, e, e.Err)
+		}
+	}
+
+	for path, err := range want {
+		if got[path] != err {
+			t.Errorf("%s: got %v, want %v", path, got[path], err)
+		}
+	}
+	for path, err := range got {
+		if _, ok := want[path]; !ok {
+			t.Errorf("%s: unexpected %v", path, err)
+		}
+	}
+}
+
+func TestAuditSymlinks_MaxLinks(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "file", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// link0 -> link1 -> ... -> link4 -> file
+	for i := 0; i < 5; i++ {
+		target := "file"
+		if i < 4 {
+			target = "link" + strconv.Itoa(i+1)
+		}
+		if err := fs.Symlink(target, "link"+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := util.AuditSymlinks(fs, "/")
+	if err != nil || len(found) != 0 {
+		t.Fatalf("got %v, %v, want none", found, err)
+	}
+
+	found, err = util.AuditSymlinksWithOptions(fs, "/", util.AuditOptions{MaxLinks: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("got %v, want link0 and link1", found)
+	}
+	for _, e := range found {
+		if !errors.Is(e, util.ErrSymlinkTooDeep) {
+			t.Errorf("%s: got %v, want too deep", e.Path, e.Err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// SyncOptions configures Sync.
+type SyncOptions struct {
+	// SrcDigests and DstDigests optionally hold known digests of the regular
+	// files of the source and destination trees, keyed by their slash
+	// separated path relative to the synced roots, such as the ones of a
+	// manifest. When both trees have a digest for a file, they are compared
+	// instead of the contents.
+	SrcDigests map[string]string
+	DstDigests map[string]string
+	// Progress, if not nil, is called as the entries of the source tree are
+	// synced, the bytes counted being the ones copied.
+	Progress ProgressFunc
+	// DryRun leaves the destination untouched, the changes which would be
+	// made being returned in SyncResult.Planned instead.
+	DryRun bool
+}
+
+// SyncResult summarizes the changes made by Sync.
+type SyncResult struct {
+	// Copied is the number of files and symlinks written.
+	Copied int
+	// Skipped is the number of files and symlinks already up to date.
+	Skipped int
+	// Removed is the number of entries removed from the destination,
+	// directories counting as one.
+	Removed int
+	// Planned holds the changes which would be made to the destination, in
+	// order, on a dry run.
+	Planned []PlannedOp
+}
+
+// Sync makes the tree at dstPath in dst identical to the tree at srcPath in
+// src: missing directories, files and symlinks are created, changed ones are
+// replaced and extra ones are removed. Entries other than directories,
+// regular files and symlinks are ignored.
+//
+// Files already up to date are not copied. A file is up to date when both
+// paths refer to the same file on disk (os.SameFile), when the digests given
+// in opts are equal, or otherwise when it has the same mode, size and
+// content. The contents are only read when the sizes match, making a Sync
+// without changes much cheaper than a copy.
+func Sync(dst billy.Filesystem, dstPath string, src billy.Filesystem, srcPath string, opts *SyncOptions) (SyncResult, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+
+	s := &syncer{dst: dst, src: src, opts: opts, progress: newProgress(opts.Progress)}
+	err := s.sync(dstPath, srcPath, ".", false)
+	return s.result, err
+}
+
+type syncer struct {
+	dst, src billy.Filesystem
+	opts     *SyncOptions
+	result   SyncResult
+	progress *progress
+}
+
+// sync syncs dstPath with srcPath, dstPath being known to be missing if
+// missing is set, such as below a directory just created.
+func (s *syncer) sync(dstPath, srcPath, rel string, missing bool) error {
+	sfi, err := s.src.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	var dfi os.FileInfo
+	if !missing {
+		dfi, err = s.dst.Lstat(dstPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	switch {
+	case sfi.IsDir():
+		return s.syncDir(dstPath, srcPath, rel, sfi, dfi)
+	case sfi.Mode()&os.ModeSymlink != 0:
+		err = s.syncSymlink(dstPath, srcPath, dfi)
+	case sfi.Mode().IsRegular():
+		err = s.syncFile(dstPath, srcPath, rel, sfi, dfi)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.progress.done(rel)
+}
+
+// plan records op as planned, reporting whether it must be skipped, the
+// sync being a dry run.
+func (s *syncer) plan(op PlannedOp) bool {
+	if !s.opts.DryRun {
+		return false
+	}
+
+	s.result.Planned = append(s.result.Planned, op)
+	return true
+}
+
+func (s *syncer) remove(path string) error {
+	s.result.Removed++
+	return s.removeAll(path)
+}
+
+func (s *syncer) removeAll(path string) error {
+	if s.plan(PlannedOp{Action: ActionRemove, Path: path}) {
+		return nil
+	}
+
+	return RemoveAll(s.dst, path)
+}
+
+func (s *syncer) syncDir(dstPath, srcPath, rel string, sfi, dfi os.FileInfo) error {
+	if dfi != nil && !dfi.IsDir() {
+		if err := s.remove(dstPath); err != nil {
+			return err
+		}
+	}
+
+	missing := dfi == nil || !dfi.IsDir()
+	if !missing || !s.plan(PlannedOp{Action: ActionMkdir, Path: dstPath}) {
+		if err := s.dst.MkdirAll(dstPath, sfi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err := s.progress.done(rel); err != nil {
+		return err
+	}
+
+	srcInfos, err := s.src.ReadDir(srcPath)
+	if err != nil {
+		return err
+	}
+
+	var dstInfos []os.FileInfo
+	if !missing {
+		dstInfos, err = s.dst.ReadDir(dstPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]bool, len(srcInfos))
+	for _, fi := range srcInfos {
+		names[fi.Name()] = true
+	}
+
+	for _, fi := range dstInfos {
+		if !names[fi.Name()] {
+			if err := s.remove(s.dst.Join(dstPath, fi.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	sort.Slice(srcInfos, func(i, j int) bool { return srcInfos[i].Name() < srcInfos[j].Name() })
+	for _, fi := range srcInfos {
+		name := fi.Name()
+		if err := s.sync(s.dst.Join(dstPath, name), s.src.Join(srcPath, name), path.Join(rel, name), missing); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *syncer) syncSymlink(dstPath, srcPath string, dfi os.FileInfo) error {
+	target, err := s.src.Readlink(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if dfi != nil {
+		if dfi.Mode()&os.ModeSymlink != 0 {
+			if current, err := s.dst.Readlink(dstPath); err == nil && current == target {
+				s.result.Skipped++
+				return nil
+			}
+		}
+
+		if err := s.removeAll(dstPath); err != nil {
+			return err
+		}
+	}
+
+	s.result.Copied++
+	if s.plan(PlannedOp{Action: ActionSymlink, Path: dstPath, Target: target}) {
+		return nil
+	}
+	return s.dst.Symlink(target, dstPath)
+}
+
+func (s *syncer) syncFile(dstPath, srcPath, rel string, sfi, dfi os.FileInfo) error {
+	if dfi != nil {
+		same, err := s.same(dstPath, srcPath, rel, sfi, dfi)
+		if err != nil {
+			return err
+		}
+		if same {
+			s.result.Skipped++
+			return nil
+		}
+
+		// Remove first, so the new file gets the mode of the source.
+		if err := s.removeAll(dstPath); err != nil {
+			return err
+		}
+	}
+
+	s.result.Copied++
+	if s.plan(PlannedOp{Action: ActionWrite, Path: dstPath}) {
+		return nil
+	}
+	return copyFile(s.dst, dstPath, s.src, srcPath, sfi.Mode().Perm(), s.progress, rel)
+}
+
+func (s *syncer) same(dstPath, srcPath, rel string, sfi, dfi os.FileInfo) (bool, error) {
+	if !dfi.Mode().IsRegular() || sfi.Mode().Perm() != dfi.Mode().Perm() {
+		return false, nil
+	}
+
+	if os.SameFile(sfi, dfi) {
+		return true, nil
+	}
+
+	if sfi.Size() != dfi.Size() {
+		return false, nil
+	}
+
+	srcDigest, ok := s.opts.SrcDigests[rel]
+	if dstDigest, dok := s.opts.DstDigests[rel]; ok && dok {
+		return srcDigest == dstDigest, nil
+	}
+
+	return sameContent(s.dst, dstPath, s.src, srcPath)
+}
+
+func sameContent(dst billy.Basic, dstPath string, src billy.Basic, srcPath string) (bool, error) {
+	sf, err := src.Open(srcPath)
+	if err != nil {
+		return false, err
+	}
+	defer sf.Close()
+
+	df, err := dst.Open(dstPath)
+	if err != nil {
+		return false, err
+	}
+	defer df.Close()
+
+	sbuf := make([]byte, 32*1024)
+	dbuf := make([]byte, 32*1024)
+	for {
+		sn, serr := io.ReadFull(sf, sbuf)
+		dn, derr := io.ReadFull(df, dbuf)
+		if !bytes.Equal(sbuf[:sn], dbuf[:dn]) {
+			return false, nil
+		}
+
+		if serr == io.EOF || serr == io.ErrUnexpectedEOF {
+			return derr == io.EOF || derr == io.ErrUnexpectedEOF, nil
+		}
+		if serr != nil {
+			return false, serr
+		}
+		if derr != nil {
+			return false, derr
+		}
+	}
+}
+
+// copyFile copies srcPath of src to dstPath of dst, reporting the bytes
+// copied to p, if not nil, as the content of rel.
+func copyFile(dst billy.Basic, dstPath string, src billy.Basic, srcPath string, perm os.FileMode, p *progress, rel string) error {
+	sf, err := src.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	df, err := dst.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(p.writer(df, rel), sf)
+	if cerr := df.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,165 @@
+package util_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestSync(t *testing.T) {
+	src := memfs.New()
+	util.WriteFile(src, "src/a", []byte("a"), 0644)
+	util.WriteFile(src, "src/dir/b", []byte("b"), 0644)
+	util.WriteFile(src, "src/dir/c", []byte("c"), 0600)
+
+	dst := memfs.New()
+	util.WriteFile(dst, "dst/a", []byte("A"), 0644)
+	util.WriteFile(dst, "dst/dir/b", []byte("b"), 0644)
+	util.WriteFile(dst, "dst/extra/d", []byte("d"), 0644)
+
+	res, err := util.Sync(dst, "dst", src, "src", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 2 || res.Skipped != 1 || res.Removed != 1 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	assertContent(t, dst, "dst/a", "a")
+	assertContent(t, dst, "dst/dir/c", "c")
+	if _, err := dst.Stat("dst/extra"); !os.IsNotExist(err) {
+		t.Errorf("expected extra to be removed, got %v", err)
+	}
+	if fi, _ := dst.Stat("dst/dir/c"); fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected mode %s", fi.Mode())
+	}
+
+	res, err = util.Sync(dst, "dst", src, "src", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 0 || res.Skipped != 3 || res.Removed != 0 {
+		t.Errorf("expected nothing to change, got %+v", res)
+	}
+}
+
+func TestSyncDigests(t *testing.T) {
+	src := memfs.New()
+	util.WriteFile(src, "a", []byte("new"), 0644)
+	dst := memfs.New()
+	util.WriteFile(dst, "a", []byte("old"), 0644)
+
+	// Equal digests are trusted, the contents are not compared.
+	res, err := util.Sync(dst, "/", src, "/", &util.SyncOptions{
+		SrcDigests: map[string]string{"a": "1234"},
+		DstDigests: map[string]string{"a": "1234"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Skipped != 1 {
+		t.Errorf("expected the file to be skipped, got %+v", res)
+	}
+	assertContent(t, dst, "a", "old")
+
+	res, err = util.Sync(dst, "/", src, "/", &util.SyncOptions{
+		SrcDigests: map[string]string{"a": "1234"},
+		DstDigests: map[string]string{"a": "5678"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 1 {
+		t.Errorf("expected the file to be copied, got %+v", res)
+	}
+	assertContent(t, dst, "a", "new")
+}
+
+func TestSyncSameFile(t *testing.T) {
+	dir := t.TempDir()
+	fs := osfs.New(dir)
+	util.WriteFile(fs, "src/a", []byte("a"), 0644)
+	fs.MkdirAll("dst", 0755)
+	if err := os.Link(filepath.Join(dir, "src", "a"), filepath.Join(dir, "dst", "a")); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+
+	res, err := util.Sync(fs, "dst", fs, "src", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Skipped != 1 || res.Copied != 0 {
+		t.Errorf("expected the hard link to be skipped, got %+v", res)
+	}
+}
+
+func TestSyncSymlink(t *testing.T) {
+	src := memfs.New()
+	util.WriteFile(src, "a", []byte("a"), 0644)
+	src.Symlink("a", "link")
+	dst := memfs.New()
+	dst.Symlink("b", "link")
+
+	if _, err := util.Sync(dst, "/", src, "/", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := dst.Readlink("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "a" {
+		t.Errorf("expected link to point to a, got %q", target)
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	src := memfs.New()
+	util.WriteFile(src, "src/a", []byte("a"), 0644)
+	util.WriteFile(src, "src/dir/b", []byte("b"), 0644)
+	util.WriteFile(src, "src/file/c", []byte("c"), 0644)
+	src.Symlink("a", "src/link")
+
+	dst := memfs.New()
+	util.WriteFile(dst, "dst/a", []byte("A"), 0644)
+	util.WriteFile(dst, "dst/dir/b", []byte("b"), 0644)
+	util.WriteFile(dst, "dst/extra/d", []byte("d"), 0644)
+	util.WriteFile(dst, "dst/file", []byte("file"), 0644)
+
+	res, err := util.Sync(dst, "dst", src, "src", &util.SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != 3 || res.Skipped != 1 || res.Removed != 2 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	var planned []string
+	for _, op := range res.Planned {
+		planned = append(planned, filepath.ToSlash(op.String()))
+	}
+	expected := []string{
+		"remove dst/extra",
+		"remove dst/a",
+		"write dst/a",
+		"remove dst/file",
+		"mkdir dst/file",
+		"write dst/file/c",
+		"symlink dst/link -> a",
+	}
+	if !reflect.DeepEqual(planned, expected) {
+		t.Errorf("expected the changes %q, got %q", expected, planned)
+	}
+
+	assertContent(t, dst, "dst/a", "A")
+	assertContent(t, dst, "dst/extra/d", "d")
+	assertContent(t, dst, "dst/file", "file")
+	if _, err := dst.Lstat("dst/link"); !os.IsNotExist(err) {
+		t.Errorf("expected link not to be created, got %v", err)
+	}
+}
@@ -0,0 +1,377 @@
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// Report is the result of comparing a filesystem tree against a reference,
+// such as a tar stream. An empty Report means that both are equivalent.
+type Report struct {
+	// Missing lists the paths present in the reference but not in the tree.
+	Missing []string
+	// Extra lists the paths present in the tree but not in the reference.
+	Extra []string
+	// Mismatches lists the entries present in both whose attributes differ.
+	Mismatches []Mismatch
+}
+
+// Mismatch describes an attribute of an entry that differs between the tree
+// and the reference.
+type Mismatch struct {
+	// Path is the slash separated path of the entry, relative to the root.
+	Path string
+	// Field is the name of the attribute that differs, one of "type",
+	// "mode", "size", "digest" or "target".
+	Field string
+	// Expected is the value found in the reference.
+	Expected string
+	// Actual is the value found in the tree.
+	Actual string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: %s mismatch, expected %q got %q", m.Path, m.Field, m.Expected, m.Actual)
+}
+
+// OK returns true if no differences were found.
+func (r *Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatches) == 0
+}
+
+func (r *Report) mismatch(path, field string, expected, actual interface{}) {
+	r.Mismatches = append(r.Mismatches, Mismatch{
+		Path:     path,
+		Field:    field,
+		Expected: fmt.Sprint(expected),
+		Actual:   fmt.Sprint(actual),
+	})
+}
+
+// VerifyTar checks that the tree rooted at root matches the contents of the
+// tar stream read from r, without extracting it. Paths, entry types,
+// permission bits, sizes, SHA-256 digests of regular files and symlink
+// targets are compared. Directories implied by the tar entries but not
+// present in the stream are not reported as extra.
+//
+// The returned error is only non-nil if the stream or the filesystem could
+// not be read; differences are reported through the Report.
+func VerifyTar(fs billy.Filesystem, root string, r io.Reader) (Report, error) {
+	var report Report
+
+	expected := map[string]bool{".": true}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+
+		name := cleanTarName(hdr.Name)
+		if name == "" {
+			continue
+		}
+
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			expected[dir] = true
+		}
+		expected[name] = true
+
+		if err := verifyTarEntry(fs, root, name, hdr, tr, &report); err != nil {
+			return report, err
+		}
+	}
+
+	extra, err := findExtra(fs, root, ".", expected)
+	if err != nil {
+		return report, err
+	}
+	report.Extra = extra
+
+	return report, nil
+}
+
+func verifyTarEntry(fs billy.Filesystem, root, name string, hdr *tar.Header, tr io.Reader, report *Report) error {
+	fullpath := fs.Join(root, filepath.FromSlash(name))
+	fi, err := fs.Lstat(fullpath)
+	if os.IsNotExist(err) {
+		report.Missing = append(report.Missing, name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var want os.FileMode
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		want = os.ModeDir
+	case tar.TypeSymlink:
+		want = os.ModeSymlink
+	case tar.TypeReg, tar.TypeRegA, tar.TypeLink:
+		want = 0
+	default:
+		// Devices, FIFOs and other special entries are only checked for
+		// existence.
+		return nil
+	}
+
+	if got := fi.Mode().Type(); got != want {
+		report.mismatch(name, "type", typeName(want), typeName(got))
+		return nil
+	}
+
+	if want != os.ModeSymlink {
+		if perm := os.FileMode(hdr.Mode).Perm(); perm != fi.Mode().Perm() {
+			report.mismatch(name, "mode", perm, fi.Mode().Perm())
+		}
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeSymlink:
+		target, err := fs.Readlink(fullpath)
+		if err != nil {
+			return err
+		}
+		if filepath.ToSlash(target) != hdr.Linkname {
+			report.mismatch(name, "target", hdr.Linkname, filepath.ToSlash(target))
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		if hdr.Size != fi.Size() {
+			report.mismatch(name, "size", hdr.Size, fi.Size())
+			return nil
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return err
+		}
+		expected := h.Sum(nil)
+
+		actual, err := hashFile(fs, fullpath, sha256.New(), nil, "")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(expected, actual) {
+			report.mismatch(name, "digest", fmt.Sprintf("sha256:%x", expected), fmt.Sprintf("sha256:%x", actual))
+		}
+	}
+
+	return nil
+}
+
+func findExtra(fs billy.Filesystem, root, rel string, expected map[string]bool) ([]string, error) {
+	infos, err := fs.ReadDir(fs.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byName(infos))
+
+	var extra []string
+	for _, fi := range infos {
+		name := path.Join(rel, fi.Name())
+		if !expected[name] {
+			extra = append(extra, name)
+			continue
+		}
+
+		if fi.IsDir() {
+			sub, err := findExtra(fs, root, name, expected)
+			if err != nil {
+				return nil, err
+			}
+			extra = append(extra, sub...)
+		}
+	}
+
+	return extra, nil
+}
+
+func cleanTarName(name string) string {
+	name = path.Clean("/" + strings.TrimPrefix(name, "./"))
+	return strings.TrimPrefix(name, "/")
+}
+
+func typeName(m os.FileMode) string {
+	switch {
+	case m&os.ModeDir != 0:
+		return "directory"
+	case m&os.ModeSymlink != 0:
+		return "symlink"
+	case m.IsRegular():
+		return "file"
+	default:
+		return m.Type().String()
+	}
+}
+
+type byName []os.FileInfo
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Less(i, j int) bool { return a[i].Name() < a[j].Name() }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+// TarOption configures WriteTar.
+type TarOption func(*tarOptions)
+
+type tarOptions struct {
+	modTime    time.Time
+	fixModTime bool
+	progress   *progress
+}
+
+// WithTarModTime gives every entry the modification time t, instead of the
+// one of the file, so that the tarball only depends on the content of the
+// tree. The zero time stands for the Unix epoch.
+func WithTarModTime(t time.Time) TarOption {
+	return func(o *tarOptions) {
+		if t.IsZero() {
+			t = time.Unix(0, 0)
+		}
+		o.modTime, o.fixModTime = t, true
+	}
+}
+
+// WithTarProgress makes WriteTar call fn as the entries are written, the
+// bytes counted being the ones of the file contents.
+func WithTarProgress(fn ProgressFunc) TarOption {
+	return func(o *tarOptions) {
+		o.progress = newProgress(fn)
+	}
+}
+
+// WriteTar writes the tree rooted at the directory root to w, as a tar
+// stream. The entries are named by their slash separated path relative to
+// root, which isn't part of the stream, and are written in lexical order,
+// each directory before its entries. The permission bits of the modes and
+// the targets of the symlinks are kept; the owners, the access times and the
+// other entries than directories, regular files and symlinks are left out,
+// so that the same tree always gives the same stream.
+//
+// The stream is written as it is produced, w not being closed afterwards.
+func WriteTar(w io.Writer, fs billy.Filesystem, root string, opts ...TarOption) error {
+	var o tarOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeTarDir(tw, fs, root, ".", &o); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func writeTarDir(tw *tar.Writer, fs billy.Filesystem, root, rel string, o *tarOptions) error {
+	infos, err := fs.ReadDir(fs.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	sort.Sort(byName(infos))
+
+	for _, fi := range infos {
+		name := path.Join(rel, fi.Name())
+		if err := writeTarEntry(tw, fs, root, name, fi, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeTarEntry(tw *tar.Writer, fs billy.Filesystem, root, name string, fi os.FileInfo, o *tarOptions) error {
+	fullpath := fs.Join(root, filepath.FromSlash(name))
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    tarMode(fi.Mode()),
+		ModTime: fi.ModTime(),
+	}
+	if o.fixModTime {
+		hdr.ModTime = o.modTime
+	}
+
+	switch {
+	case fi.IsDir():
+		hdr.Typeflag, hdr.Name = tar.TypeDir, name+"/"
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := fs.Readlink(fullpath)
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, filepath.ToSlash(target)
+	case fi.Mode().IsRegular():
+		hdr.Typeflag, hdr.Size = tar.TypeReg, fi.Size()
+	default:
+		return nil
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := o.progress.done(name); err != nil {
+			return err
+		}
+		return writeTarDir(tw, fs, root, name, o)
+	case tar.TypeReg:
+		if err := writeTarFile(o.progress.writer(tw, name), fs, fullpath, hdr.Size); err != nil {
+			return err
+		}
+	}
+
+	return o.progress.done(name)
+}
+
+// writeTarFile copies the content of the file to w, failing if its size
+// changed since it was stated.
+func writeTarFile(w io.Writer, fs billy.Filesystem, name string, size int64) error {
+	f, err := fs.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(w, io.LimitReader(f, size))
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("%s: %w", name, io.ErrUnexpectedEOF)
+	}
+
+	return nil
+}
+
+// tarMode returns the permission bits of m, along with the setuid, setgid
+// and sticky bits, as stored in a tar header.
+func tarMode(m os.FileMode) int64 {
+	mode := int64(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+
+	return mode
+}
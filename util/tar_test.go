@@ -0,0 +1,183 @@
+package util_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+type tarEntry struct {
+	hdr     tar.Header
+	content string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := e.hdr
+		hdr.Size = int64(len(e.content))
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+var verifyTarEntries = []tarEntry{
+	{hdr: tar.Header{Name: "./dir/", Typeflag: tar.TypeDir, Mode: 0755}},
+	{hdr: tar.Header{Name: "./dir/foo", Typeflag: tar.TypeReg, Mode: 0644}, content: "foo"},
+	{hdr: tar.Header{Name: "bar/baz", Typeflag: tar.TypeReg, Mode: 0600}, content: "baz"},
+	{hdr: tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "dir/foo", Mode: 0777}},
+}
+
+func buildVerifyTree(t *testing.T) billy.Filesystem {
+	fs := memfs.New()
+	if err := fs.MkdirAll("root/dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "root/dir/foo", []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "root/bar/baz", []byte("baz"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Symlink("dir/foo", "root/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestVerifyTar(t *testing.T) {
+	fs := buildVerifyTree(t)
+
+	report, err := util.VerifyTar(fs, "root", buildTar(t, verifyTarEntries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() {
+		t.Errorf("expected matching tree, got %+v", report)
+	}
+}
+
+func TestVerifyTarDifferences(t *testing.T) {
+	fs := buildVerifyTree(t)
+	util.WriteFile(fs, "root/dir/foo", []byte("oof"), 0644)
+	util.WriteFile(fs, "root/extra", nil, 0644)
+	fs.Remove("root/bar/baz")
+
+	report, err := util.VerifyTar(fs, "root", buildTar(t, verifyTarEntries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(report.Missing, []string{"bar/baz"}) {
+		t.Errorf("unexpected missing entries: %v", report.Missing)
+	}
+	if !reflect.DeepEqual(report.Extra, []string{"extra"}) {
+		t.Errorf("unexpected extra entries: %v", report.Extra)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].Path != "dir/foo" || report.Mismatches[0].Field != "digest" {
+		t.Errorf("unexpected mismatches: %v", report.Mismatches)
+	}
+}
+
+func TestVerifyTarTypeMismatch(t *testing.T) {
+	fs := buildVerifyTree(t)
+	fs.Remove("root/link")
+	util.WriteFile(fs, "root/link", []byte("dir/foo"), 0777)
+
+	report, err := util.VerifyTar(fs, "root", buildTar(t, verifyTarEntries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []util.Mismatch{{Path: "link", Field: "type", Expected: "symlink", Actual: "file"}}
+	if !reflect.DeepEqual(report.Mismatches, expected) {
+		t.Errorf("unexpected mismatches: %v", report.Mismatches)
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	fs := buildVerifyTree(t)
+
+	var buf bytes.Buffer
+	if err := util.WriteTar(&buf, fs, "root"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || !hdr.AccessTime.IsZero() {
+			t.Errorf("%s: unexpected owner or access time: %+v", hdr.Name, hdr)
+		}
+		names = append(names, hdr.Name)
+	}
+
+	expected := []string{"bar/", "bar/baz", "dir/", "dir/foo", "link"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected entries: %v", names)
+	}
+
+	report, err := util.VerifyTar(fs, "root", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("expected matching tree, got %+v", report)
+	}
+}
+
+func TestWriteTarModTime(t *testing.T) {
+	epoch := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var tarballs [2][]byte
+	for i := range tarballs {
+		fs := buildVerifyTree(t)
+		if err := fs.(billy.Change).Chtimes("root/dir/foo", time.Now(), time.Now().Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := util.WriteTar(&buf, fs, "root", util.WithTarModTime(epoch)); err != nil {
+			t.Fatal(err)
+		}
+		tarballs[i] = buf.Bytes()
+	}
+
+	if !bytes.Equal(tarballs[0], tarballs[1]) {
+		t.Fatal("tarballs of identical trees differ")
+	}
+
+	hdr, err := tar.NewReader(bytes.NewReader(tarballs[0])).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hdr.ModTime.Equal(epoch) {
+		t.Errorf("got modification time %v, want %v", hdr.ModTime, epoch)
+	}
+}
@@ -1,12 +1,12 @@
 package util
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
 
 	"github.com/go-git/go-billy/v5"
 )
@@ -114,26 +114,11 @@ func WriteFile(fs billy.Basic, filename string, data []byte, perm os.FileMode) e
 	return err
 }
 
-// Random number state.
 // We generate random temporary file names so that there's a good
 // chance the file doesn't exist yet - keeps the number of tries in
-// TempFile to a minimum.
-var rand uint32
-var randmu sync.Mutex
-
-func reseed() uint32 {
-	return uint32(time.Now().UnixNano() + int64(os.Getpid()))
-}
-
+// TempFile to a minimum. The randomness comes from the current Source.
 func nextSuffix() string {
-	randmu.Lock()
-	r := rand
-	if r == 0 {
-		r = reseed()
-	}
-	r = r*1664525 + 1013904223 // constants from Numerical Recipes
-	rand = r
-	randmu.Unlock()
+	r := currentSource().Uint32()
 	return strconv.Itoa(int(1e9 + r%1e9))[1:]
 }
 
@@ -156,9 +141,7 @@ func TempFile(fs billy.Basic, dir, prefix string) (f billy.File, err error) {
 		f, err = fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
 		if os.IsExist(err) {
 			if nconflict++; nconflict > 10 {
-				randmu.Lock()
-				rand = reseed()
-				randmu.Unlock()
+				reseed()
 			}
 			continue
 		}
@@ -187,9 +170,7 @@ func TempDir(fs billy.Dir, dir, prefix string) (name string, err error) {
 		err = fs.MkdirAll(try, 0700)
 		if os.IsExist(err) {
 			if nconflict++; nconflict > 10 {
-				randmu.Lock()
-				rand = reseed()
-				randmu.Unlock()
+				reseed()
 			}
 			continue
 		}
@@ -206,6 +187,103 @@ func TempDir(fs billy.Dir, dir, prefix string) (name string, err error) {
 	return
 }
 
+// CreateTemp creates a new temporary file in the directory dir, opened for
+// reading and writing, as os.CreateTemp does: the name is pattern with its
+// last "*" replaced by a random string, or followed by one. The filesystems
+// implementing billy.TempCreator create it themselves.
+func CreateTemp(fs billy.Basic, dir, pattern string) (billy.File, error) {
+	if tc, ok := fs.(billy.TempCreator); ok {
+		f, err := tc.CreateTemp(dir, pattern)
+		if err != billy.ErrNotSupported {
+			return f, err
+		}
+	}
+
+	prefix, suffix, err := splitTempPattern("createtemp", pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		dir = getTempDir(fs)
+	}
+
+	nconflict := 0
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+nextSuffix()+suffix)
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			if nconflict++; nconflict > 10 {
+				reseed()
+			}
+			continue
+		}
+		return f, err
+	}
+
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, pattern), Err: os.ErrExist}
+}
+
+// MkdirTemp creates a new temporary directory in the directory dir and
+// returns its path, following the pattern as CreateTemp does. The directory
+// is created with the mode 0700.
+func MkdirTemp(fs billy.Dir, dir, pattern string) (string, error) {
+	if tc, ok := fs.(billy.TempCreator); ok {
+		name, err := tc.MkdirTemp(dir, pattern)
+		if err != billy.ErrNotSupported {
+			return name, err
+		}
+	}
+
+	prefix, suffix, err := splitTempPattern("mkdirtemp", pattern)
+	if err != nil {
+		return "", err
+	}
+
+	basic, _ := fs.(billy.Basic)
+	if dir == "" {
+		dir = getTempDir(basic)
+	}
+
+	nconflict := 0
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, prefix+nextSuffix()+suffix)
+		// MkdirAll succeeds on the existing directories, which have to be
+		// told apart first.
+		if basic != nil {
+			if _, err := basic.Stat(name); err == nil {
+				if nconflict++; nconflict > 10 {
+					reseed()
+				}
+				continue
+			}
+		}
+
+		if err := fs.MkdirAll(name, 0700); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+
+	return "", &os.PathError{Op: "mkdirtemp", Path: filepath.Join(dir, pattern), Err: os.ErrExist}
+}
+
+// splitTempPattern splits pattern at its last "*", rejecting the patterns with a
+// path separator, which could lead outside of dir.
+func splitTempPattern(op, pattern string) (prefix, suffix string, err error) {
+	if strings.ContainsAny(pattern, "/"+string(filepath.Separator)) {
+		return "", "", &os.PathError{Op: op, Path: pattern, Err: errPatternHasSeparator}
+	}
+
+	if i := strings.LastIndex(pattern, "*"); i != -1 {
+		return pattern[:i], pattern[i+1:], nil
+	}
+
+	return pattern, "", nil
+}
+
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
 func getTempDir(fs billy.Basic) string {
 	ch, ok := fs.(billy.Chroot)
 	if !ok || ch.Root() == "" || ch.Root() == "/" || ch.Root() == string(filepath.Separator) {
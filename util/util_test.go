@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/util"
 )
@@ -42,6 +43,39 @@ func TestTempDir_WithDir(t *testing.T) {
 	}
 }
 
+func TestCreateTemp_Emulated(t *testing.T) {
+	// The struct hides memfs' own billy.TempCreator.
+	fs := struct{ billy.Filesystem }{memfs.New()}
+
+	f, err := util.CreateTemp(fs, "dir", "foo*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(filepath.Join("dir", "foo")) + `[0-9]+\.txt$`)
+	if !re.MatchString(f.Name()) {
+		t.Errorf("CreateTemp(fs, `dir`, `foo*.txt`) created bad name %s", f.Name())
+	}
+
+	name, err := util.MkdirTemp(fs, "dir", "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	re = regexp.MustCompile("^" + regexp.QuoteMeta(filepath.Join("dir", "bar")) + "[0-9]+$")
+	if !re.MatchString(name) {
+		t.Errorf("MkdirTemp(fs, `dir`, `bar`) created bad name %s", name)
+	}
+	if fi, err := fs.Stat(name); err != nil || !fi.IsDir() {
+		t.Errorf("MkdirTemp(fs, `dir`, `bar`) didn't create %s: %v", name, err)
+	}
+
+	if _, err := util.CreateTemp(fs, "dir", "foo/*"); err == nil {
+		t.Errorf("CreateTemp(fs, `dir`, `foo/*`) should fail")
+	}
+}
+
 func TestReadFile(t *testing.T) {
 	fs := memfs.New()
 	f, err := util.TempFile(fs, "", "")
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// NewVFS returns a VFS querying fs, to resolve paths against any billy
+// filesystem. The filesystems implementing VFS, such as the ones
+// implementing billy.Filesystem, are returned as they are. Otherwise,
+// without billy.Symlink Lstat is Stat and Readlink fails, there being no
+// link to read, and without billy.Dir ReadDir fails with
+// billy.ErrNotSupported.
+func NewVFS(fs billy.Basic) VFS {
+	if v, ok := fs.(VFS); ok {
+		return v
+	}
+
+	return &billyVFS{fs: fs}
+}
+
+type billyVFS struct {
+	fs billy.Basic
+}
+
+func (v *billyVFS) Stat(name string) (os.FileInfo, error) {
+	return v.fs.Stat(name)
+}
+
+func (v *billyVFS) Lstat(name string) (os.FileInfo, error) {
+	if s, ok := v.fs.(billy.Symlink); ok {
+		return s.Lstat(name)
+	}
+
+	return v.fs.Stat(name)
+}
+
+func (v *billyVFS) Readlink(name string) (string, error) {
+	if s, ok := v.fs.(billy.Symlink); ok {
+		return s.Readlink(name)
+	}
+
+	return "", &os.PathError{Op: "readlink", Path: name, Err: billy.ErrNotSupported}
+}
+
+func (v *billyVFS) ReadDir(name string) ([]os.FileInfo, error) {
+	if d, ok := v.fs.(billy.Dir); ok {
+		return d.ReadDir(name)
+	}
+
+	return nil, &os.PathError{Op: "readdir", Path: name, Err: billy.ErrNotSupported}
+}
@@ -0,0 +1,49 @@
+package util_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func TestNewVFSBasic(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, filepath.Join("dir", "file"), []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	vfs := util.NewVFS(struct{ billy.Basic }{fs})
+
+	fi, err := vfs.Lstat("dir")
+	if err != nil || !fi.IsDir() {
+		t.Fatalf("Lstat: got %v, %v", fi, err)
+	}
+	if fi, err := vfs.Stat(filepath.Join("dir", "file")); err != nil || fi.Size() != 3 {
+		t.Fatalf("Stat: got %v, %v", fi, err)
+	}
+
+	if _, err := vfs.Readlink("dir"); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("Readlink: expected billy.ErrNotSupported, got %v", err)
+	}
+	if _, err := vfs.ReadDir("dir"); !errors.Is(err, billy.ErrNotSupported) {
+		t.Errorf("ReadDir: expected billy.ErrNotSupported, got %v", err)
+	}
+
+	got, err := util.SecureJoinVFS("dir", filepath.Join("..", "file"), vfs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("dir", "file"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	var pe *os.PathError
+	if _, err := vfs.Stat("missing"); !errors.As(err, &pe) || !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
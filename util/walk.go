@@ -1,12 +1,21 @@
 package util
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/go-git/go-billy/v5"
 )
 
+// SkipAll is used as a return value from WalkFuncs and WalkDirFuncs to
+// indicate that all remaining files and directories are to be skipped. It is
+// not returned as an error by any function. It mirrors fs.SkipAll, which
+// isn't available in all the Go versions supported.
+var SkipAll = errors.New("skip everything and stop the walk")
+
 // walk recursively descends path, calling walkFn
 // adapted from https://golang.org/src/path/filepath/path.go
 func walk(fs billy.Filesystem, path string, info os.FileInfo, walkFn filepath.WalkFunc) error {
@@ -46,7 +55,7 @@ func walk(fs billy.Filesystem, path string, info os.FileInfo, walkFn filepath.Wa
 	return nil
 }
 
-// Walk walks the file tree rooted at root, calling fn for each file or 
+// Walk walks the file tree rooted at root, calling fn for each file or
 // directory in the tree, including root. All errors that arise visiting files
 // and directories are filtered by fn: see the WalkFunc documentation for
 // details.
@@ -54,7 +63,7 @@ func walk(fs billy.Filesystem, path string, info os.FileInfo, walkFn filepath.Wa
 // The files are walked in lexical order, which makes the output deterministic
 // but requires Walk to read an entire directory into memory before proceeding
 // to walk that directory. Walk does not follow symbolic links.
-// 
+//
 // Function adapted from https://github.com/golang/go/blob/3b770f2ccb1fa6fecc22ea822a19447b10b70c5c/src/path/filepath/path.go#L500
 func Walk(fs billy.Filesystem, root string, walkFn filepath.WalkFunc) error {
 	info, err := fs.Lstat(root)
@@ -63,10 +72,84 @@ func Walk(fs billy.Filesystem, root string, walkFn filepath.WalkFunc) error {
 	} else {
 		err = walk(fs, root, info, walkFn)
 	}
-	
-	if err == filepath.SkipDir {
+
+	if err == filepath.SkipDir || err == SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or
+// directory in the tree, including root, with the semantics of
+// filepath.WalkDir: fn may return filepath.SkipDir to skip a directory, or
+// the remaining files of the directory when called on a file, and SkipAll to
+// stop the walk.
+//
+// The files are walked in lexical order. WalkDir does not follow symbolic
+// links. Unlike Walk, WalkDir doesn't call Lstat on every visited file: the
+// fs.DirEntry values are built from the result of ReadDir.
+func WalkDir(fs billy.Filesystem, root string, fn fs.WalkDirFunc) error {
+	info, err := fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(fs, root, dirEntry{info}, fn)
+	}
+
+	if err == filepath.SkipDir || err == SkipAll {
 		return nil
 	}
-	
+
 	return err
 }
+
+// walkDir recursively descends path, calling fn.
+// adapted from https://golang.org/src/path/filepath/path.go
+func walkDir(fs billy.Filesystem, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			// Successfully skipped directory.
+			err = nil
+		}
+		return err
+	}
+
+	infos, err := fs.ReadDir(path)
+	if err != nil {
+		// Second call, to report ReadDir error.
+		err = fn(path, d, err)
+		if err != nil {
+			if err == filepath.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	for _, info := range infos {
+		name := filepath.Join(path, info.Name())
+		if err := walkDir(fs, name, dirEntry{info}, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dirEntry implements fs.DirEntry on top of an os.FileInfo.
+type dirEntry struct {
+	os.FileInfo
+}
+
+func (d dirEntry) Type() fs.FileMode {
+	return d.Mode().Type()
+}
+
+func (d dirEntry) Info() (fs.FileInfo, error) {
+	return d.FileInfo, nil
+}
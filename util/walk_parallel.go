@@ -0,0 +1,185 @@
+package util
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// WalkParallel walks the file tree rooted at root like WalkDir, reading up to
+// concurrency directories at the same time. fn is called concurrently from
+// several goroutines, and the tree isn't walked in lexical order: only the
+// entries of a given directory are visited in order, by the same goroutine,
+// and always after their directory.
+//
+// SkipDir skips the directory fn was called on or, when called on a file, the
+// remaining entries of its directory which were not yet visited; the
+// subdirectories already queued are still walked. SkipAll, or any other
+// error, stops the walk as soon as possible, the error being returned by
+// WalkParallel.
+//
+// The directories are only read concurrently when fs reports the
+// billy.ConcurrentCapability. Otherwise, or if concurrency is less than two,
+// WalkParallel is equivalent to WalkDir.
+func WalkParallel(fs billy.Filesystem, root string, concurrency int, fn fs.WalkDirFunc) error {
+	if concurrency < 2 || !billy.CapabilityCheck(fs, billy.ConcurrentCapability) {
+		return WalkDir(fs, root, fn)
+	}
+
+	info, err := fs.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else if !info.IsDir() {
+		err = fn(root, dirEntry{info}, nil)
+	} else {
+		err = newParallelWalker(fs, fn).run(root, dirEntry{info}, concurrency)
+	}
+
+	if err == filepath.SkipDir || err == SkipAll {
+		return nil
+	}
+
+	return err
+}
+
+type walkJob struct {
+	path string
+	d    fs.DirEntry
+}
+
+// parallelWalker walks a tree with a pool of workers, sharing a stack of
+// directories to read. Using a stack rather than a queue keeps the walk
+// mostly depth-first, bounding the number of pending directories.
+type parallelWalker struct {
+	fs billy.Filesystem
+	fn fs.WalkDirFunc
+
+	m       sync.Mutex
+	cond    *sync.Cond
+	stack   []walkJob
+	pending int // directories pushed and not done yet
+	stopped bool
+	err     error
+}
+
+func newParallelWalker(fs billy.Filesystem, fn fs.WalkDirFunc) *parallelWalker {
+	w := &parallelWalker{fs: fs, fn: fn}
+	w.cond = sync.NewCond(&w.m)
+	return w
+}
+
+func (w *parallelWalker) run(root string, d fs.DirEntry, concurrency int) error {
+	w.push(walkJob{root, d})
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+
+	return w.err
+}
+
+func (w *parallelWalker) work() {
+	for {
+		w.m.Lock()
+		for len(w.stack) == 0 && w.pending > 0 && !w.stopped {
+			w.cond.Wait()
+		}
+		if len(w.stack) == 0 || w.stopped {
+			w.m.Unlock()
+			return
+		}
+
+		job := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+		w.m.Unlock()
+
+		w.walkDir(job.path, job.d)
+		w.done()
+	}
+}
+
+func (w *parallelWalker) push(job walkJob) {
+	w.m.Lock()
+	w.stack = append(w.stack, job)
+	w.pending++
+	w.m.Unlock()
+	w.cond.Signal()
+}
+
+func (w *parallelWalker) done() {
+	w.m.Lock()
+	w.pending--
+	if w.pending == 0 {
+		w.cond.Broadcast()
+	}
+	w.m.Unlock()
+}
+
+func (w *parallelWalker) stop(err error) {
+	w.m.Lock()
+	if !w.stopped {
+		w.stopped = true
+		w.err = err
+	}
+	w.m.Unlock()
+	w.cond.Broadcast()
+}
+
+func (w *parallelWalker) isStopped() bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	return w.stopped
+}
+
+// walkDir calls fn for the directory at path, and then for each of its files,
+// pushing its subdirectories to the stack.
+func (w *parallelWalker) walkDir(path string, d fs.DirEntry) {
+	if w.isStopped() {
+		return
+	}
+
+	if err := w.fn(path, d, nil); err != nil {
+		if err != filepath.SkipDir {
+			w.stop(err)
+		}
+		return
+	}
+
+	infos, err := w.fs.ReadDir(path)
+	if err != nil {
+		if err := w.fn(path, d, err); err != nil && err != filepath.SkipDir {
+			w.stop(err)
+		}
+		return
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	for _, info := range infos {
+		if w.isStopped() {
+			return
+		}
+
+		name := filepath.Join(path, info.Name())
+		if info.IsDir() {
+			w.push(walkJob{name, dirEntry{info}})
+			continue
+		}
+
+		if err := w.fn(name, dirEntry{info}, nil); err != nil {
+			if err != filepath.SkipDir {
+				w.stop(err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package util_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func newWalkTree(t *testing.T, filesystem billy.Filesystem) {
+	t.Helper()
+
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 5; j++ {
+			name := fmt.Sprintf("root/%d/%d/file", i, j)
+			if err := util.WriteFile(filesystem, name, []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := util.WriteFile(filesystem, fmt.Sprintf("root/%d/file", i), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type pathCollector struct {
+	m     sync.Mutex
+	paths []string
+}
+
+func (c *pathCollector) add(path string) {
+	c.m.Lock()
+	c.paths = append(c.paths, filepath.ToSlash(path))
+	c.m.Unlock()
+}
+
+func (c *pathCollector) sorted() []string {
+	sort.Strings(c.paths)
+	return c.paths
+}
+
+func TestWalkParallel(t *testing.T) {
+	filesystem := osfs.New(t.TempDir())
+	newWalkTree(t, filesystem)
+
+	var expected pathCollector
+	err := util.WalkDir(filesystem, "root", func(path string, d fs.DirEntry, err error) error {
+		expected.add(path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got pathCollector
+	err = util.WalkParallel(filesystem, "root", 4, func(path string, d fs.DirEntry, err error) error {
+		got.add(path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got.sorted(), expected.sorted()) {
+		t.Errorf("expected %v, got %v", expected.paths, got.paths)
+	}
+}
+
+func TestWalkParallelSkip(t *testing.T) {
+	filesystem := osfs.New(t.TempDir())
+	newWalkTree(t, filesystem)
+
+	var got pathCollector
+	err := util.WalkParallel(filesystem, "root", 4, func(path string, d fs.DirEntry, err error) error {
+		got.add(path)
+		if d.IsDir() && d.Name() == "1" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range got.paths {
+		if matched, _ := filepath.Match("root/*/1/*", path); matched {
+			t.Errorf("unexpected path %q in skipped directory", path)
+		}
+		if matched, _ := filepath.Match("root/1/*", path); matched {
+			t.Errorf("unexpected path %q in skipped directory", path)
+		}
+	}
+	// root and root/1, then for each other directory: itself, its file, its
+	// skipped subdirectory and the four other ones with their file.
+	if len(got.paths) != 2+4*(3+4*2) {
+		t.Errorf("unexpected number of paths walked: %d", len(got.paths))
+	}
+}
+
+func TestWalkParallelStop(t *testing.T) {
+	filesystem := osfs.New(t.TempDir())
+	newWalkTree(t, filesystem)
+
+	errBoom := errors.New("boom")
+	err := util.WalkParallel(filesystem, "root", 4, func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			return errBoom
+		}
+		return nil
+	})
+	if err != errBoom {
+		t.Errorf("expected the error of fn, got %v", err)
+	}
+
+	err = util.WalkParallel(filesystem, "root", 4, func(path string, d fs.DirEntry, err error) error {
+		return util.SkipAll
+	})
+	if err != nil {
+		t.Errorf("expected SkipAll to be ignored, got %v", err)
+	}
+
+	err = util.WalkParallel(filesystem, "missing", 4, func(path string, d fs.DirEntry, err error) error {
+		return err
+	})
+	if err == nil {
+		t.Error("expected an error walking a missing root")
+	}
+}
+
+func TestWalkParallelSequential(t *testing.T) {
+	filesystem := memfs.New()
+	newWalkTree(t, filesystem)
+
+	var expected, got []string
+	util.WalkDir(filesystem, "root", func(path string, d fs.DirEntry, err error) error {
+		expected = append(expected, path)
+		return nil
+	})
+
+	// Hiding the capabilities of memfs, the walk happens in lexical order.
+	sequential := struct{ billy.Filesystem }{filesystem}
+	err := util.WalkParallel(sequential, "root", 4, func(path string, d fs.DirEntry, err error) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
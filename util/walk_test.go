@@ -3,6 +3,7 @@ package util_test
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -138,6 +139,91 @@ func (s *WalkSuite) TestWalkForwardsStatErrors(c *C) {
 	c.Assert(discoveredPaths, NotContain, filepath.FromSlash("path/to/some/subfolder/that/contain/file"))
 }
 
+func (s *WalkSuite) TestWalkSkipAll(c *C) {
+	filesystem := memfs.New()
+	createFile(c, filesystem, "a/file")
+	createFile(c, filesystem, "b/file")
+	discoveredPaths := []string{}
+	c.Assert(util.Walk(filesystem, "/", func(path string, info os.FileInfo, err error) error {
+		discoveredPaths = append(discoveredPaths, path)
+		if path == filepath.FromSlash("/a/file") {
+			return util.SkipAll
+		}
+		return nil
+	}), IsNil)
+	c.Assert(discoveredPaths, NotContain, filepath.FromSlash("/b"))
+}
+
+func walkDirPaths(c *C, filesystem billy.Filesystem, root string, fn func(path string, d fs.DirEntry) error) []string {
+	var paths []string
+	c.Assert(util.WalkDir(filesystem, root, func(path string, d fs.DirEntry, err error) error {
+		c.Assert(err, IsNil)
+		paths = append(paths, filepath.ToSlash(path))
+		return fn(path, d)
+	}), IsNil)
+	return paths
+}
+
+func (s *WalkSuite) TestWalkDirOrder(c *C) {
+	filesystem := memfs.New()
+	createFile(c, filesystem, "root/b/file")
+	createFile(c, filesystem, "root/a")
+	createFile(c, filesystem, "root/c")
+	c.Assert(filesystem.Symlink("a", "root/link"), IsNil)
+
+	types := map[string]fs.FileMode{}
+	paths := walkDirPaths(c, filesystem, "root", func(path string, d fs.DirEntry) error {
+		types[filepath.ToSlash(path)] = d.Type()
+		info, err := d.Info()
+		c.Assert(err, IsNil)
+		c.Assert(info.Name(), Equals, d.Name())
+		return nil
+	})
+	c.Assert(paths, DeepEquals, []string{"root", "root/a", "root/b", "root/b/file", "root/c", "root/link"})
+	c.Assert(types["root"], Equals, fs.ModeDir)
+	c.Assert(types["root/a"], Equals, fs.FileMode(0))
+	c.Assert(types["root/link"], Equals, fs.ModeSymlink)
+}
+
+func (s *WalkSuite) TestWalkDirSkip(c *C) {
+	filesystem := memfs.New()
+	createFile(c, filesystem, "root/a/file")
+	createFile(c, filesystem, "root/b/file1")
+	createFile(c, filesystem, "root/b/file2")
+	createFile(c, filesystem, "root/c/file")
+	createFile(c, filesystem, "root/d/file")
+
+	paths := walkDirPaths(c, filesystem, "root", func(path string, d fs.DirEntry) error {
+		switch filepath.ToSlash(path) {
+		case "root/a":
+			return filepath.SkipDir
+		case "root/b/file1":
+			return filepath.SkipDir
+		case "root/c/file":
+			return util.SkipAll
+		}
+		return nil
+	})
+	c.Assert(paths, DeepEquals, []string{"root", "root/a", "root/b", "root/b/file1", "root/c", "root/c/file"})
+}
+
+func (s *WalkSuite) TestWalkDirErrors(c *C) {
+	filesystem := memfs.New()
+	errBoom := errors.New("boom")
+
+	err := util.WalkDir(filesystem, "missing", func(path string, d fs.DirEntry, err error) error {
+		c.Assert(d, IsNil)
+		return err
+	})
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	createFile(c, filesystem, "root/file")
+	err = util.WalkDir(filesystem, "root", func(path string, d fs.DirEntry, err error) error {
+		return errBoom
+	})
+	c.Assert(err, Equals, errBoom)
+}
+
 func createFile(c *C, filesystem billy.Filesystem, path string) {
 	fd, err := filesystem.Create(path)
 	c.Assert(err, IsNil)